@@ -0,0 +1,110 @@
+// Команда allinone запускает API сервер и серверы хранения в одном процессе, чтобы
+// попробовать систему одной командой go run ./cmd/allinone
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/pkg/apiserver"
+	"TestCase/pkg/config"
+	"TestCase/pkg/storageserver"
+)
+
+// server - встроенный сервер, который останавливается при завершении процесса
+type server interface {
+	Shutdown(ctx context.Context) error
+}
+
+func main() {
+	// Конфигурация общая для всех серверов: флаги, переменные окружения и файл те же,
+	// что у cmd/api и cmd/storage
+	cfg, options, err := config.Load("allinone", os.Args[1:])
+	if err != nil {
+		log.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+	}
+
+	// Серверы хранения занимают chunk_count портов подряд начиная с storage_port
+	basePort, err := strconv.Atoi(cfg.StoragePort)
+	if err != nil {
+		log.Fatalf("Неверный порт сервера хранения %q", cfg.StoragePort)
+	}
+	cfg.StorageServers = make([]string, cfg.ChunkCount)
+	for i := range cfg.StorageServers {
+		cfg.StorageServers[i] = fmt.Sprintf("localhost:%d", basePort+i)
+	}
+	cfg.DiscoveryBackend = ""
+
+	if options.DumpConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Режим Gin задается до создания маршрутизаторов
+	gin.SetMode(cfg.GinMode)
+
+	// Серверы останавливаются в обратном порядке: сначала API, затем серверы хранения
+	var started []server
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for i := len(started) - 1; i >= 0; i-- {
+			if err := started[i].Shutdown(ctx); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+	}
+
+	for i := range cfg.StorageServers {
+		// unix сокет из listen относится только к API серверу
+		nodeCfg := *cfg
+		nodeCfg.StoragePort = strconv.Itoa(basePort + i)
+		nodeCfg.Listen = ""
+
+		node, err := storageserver.NewMemoryStorageServer(&nodeCfg, strconv.Itoa(i+1))
+		if err != nil {
+			stop()
+			log.Fatalf("Не удалось создать хранилище: %v", err)
+		}
+		if _, err := node.Start(context.Background()); err != nil {
+			stop()
+			log.Fatalf("Не удалось запустить сервер хранения %d: %v", i+1, err)
+		}
+		started = append(started, node)
+	}
+
+	api, err := apiserver.NewStreamingAPIServer(cfg)
+	if err != nil {
+		stop()
+		log.Fatalf("Не удалось создать сервер: %v", err)
+	}
+	if _, err := api.Start(context.Background()); err != nil {
+		stop()
+		log.Fatalf("Не удалось запустить сервер: %v", err)
+	}
+	started = append(started, api)
+
+	log.Printf("Система запущена: API http://localhost:%s, серверов хранения: %d", cfg.APIPort, len(cfg.StorageServers))
+
+	// Ожидаем сигнал завершения, чтобы дождаться текущих запросов и сохранить снимки
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Printf("Остановка системы")
+	stop()
+}
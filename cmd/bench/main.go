@@ -0,0 +1,144 @@
+// Команда bench загружает и скачивает файлы заданных размеров с заданной
+// параллельностью и сообщает пропускную способность, задержки и долю ошибок
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"TestCase/pkg/client"
+)
+
+// defaultAPIURL используется, если адрес API не задан ни флагом, ни переменной окружения
+const defaultAPIURL = "http://localhost:8080"
+
+// benchOptions содержит параметры нагрузки
+type benchOptions struct {
+	apiURL      string
+	apiKey      string
+	h2c         bool
+	sizes       []string
+	count       int
+	concurrency int
+	noDownload  bool
+	keep        bool
+	jsonOutput  bool
+}
+
+func main() {
+	// Ctrl+C прерывает нагрузку; уже собранные результаты выводятся
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(1)
+	}
+}
+
+// newRootCommand создает команду нагрузочного теста
+func newRootCommand() *cobra.Command {
+	opts := &benchOptions{}
+
+	apiURL := os.Getenv("STORAGE_API_URL")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+
+	cmd := &cobra.Command{
+		Use:   "storage-bench",
+		Short: "Нагрузочный тест кластера: загрузка и скачивание файлов",
+		Long: "Для каждого размера из --sizes загружает --count файлов со случайным содержимым в --concurrency потоков,\n" +
+			"затем скачивает их и удаляет. Сообщает пропускную способность, задержки (p50, p90, p99) и долю ошибок",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sizes, err := parseSizes(opts.sizes)
+			if err != nil {
+				return err
+			}
+			if opts.count < 1 {
+				return fmt.Errorf("--count должен быть больше нуля")
+			}
+			if opts.concurrency < 1 {
+				return fmt.Errorf("--concurrency должен быть больше нуля")
+			}
+
+			apiClient := client.NewAPIClient(opts.apiURL)
+			apiClient.SetAPIKey(opts.apiKey)
+			if opts.h2c {
+				apiClient.UseH2C()
+			}
+
+			runner := &benchRunner{
+				client:      apiClient,
+				count:       opts.count,
+				concurrency: opts.concurrency,
+				download:    !opts.noDownload,
+				keep:        opts.keep,
+			}
+			if !opts.jsonOutput {
+				runner.log = os.Stderr
+			}
+
+			results := runner.run(cmd.Context(), sizes)
+			if opts.jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(results); err != nil {
+					return err
+				}
+			} else {
+				printResults(os.Stdout, results)
+			}
+			return cmd.Context().Err()
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.apiURL, "api", apiURL, "адрес API сервера: http://host:port или unix:///path (переменная окружения STORAGE_API_URL)")
+	cmd.Flags().StringVar(&opts.apiKey, "api-key", os.Getenv("STORAGE_API_KEY"), "ключ API (переменная окружения STORAGE_API_KEY)")
+	cmd.Flags().BoolVar(&opts.h2c, "h2c", false, "обращаться к серверам по HTTP/2 без TLS (серверы запущены с http2_cleartext)")
+	cmd.Flags().StringSliceVar(&opts.sizes, "sizes", []string{"64K", "1M", "16M"}, "размеры файлов в байтах, с суффиксом K, M или G - в KiB, MiB или GiB")
+	cmd.Flags().IntVarP(&opts.count, "count", "n", 20, "число файлов каждого размера")
+	cmd.Flags().IntVarP(&opts.concurrency, "concurrency", "c", 4, "число одновременных запросов")
+	cmd.Flags().BoolVar(&opts.noDownload, "no-download", false, "только загружать файлы, не скачивая их")
+	cmd.Flags().BoolVar(&opts.keep, "keep", false, "не удалять загруженные файлы после теста")
+	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "выводить результат в формате JSON")
+
+	return cmd
+}
+
+// parseSizes разбирает размеры вида 4096, 64K, 1M или 1G
+func parseSizes(values []string) ([]int64, error) {
+	sizes := make([]int64, 0, len(values))
+	for _, value := range values {
+		text := strings.ToUpper(strings.TrimSpace(value))
+		multiplier := int64(1)
+		switch {
+		case strings.HasSuffix(text, "K"):
+			multiplier = 1 << 10
+		case strings.HasSuffix(text, "M"):
+			multiplier = 1 << 20
+		case strings.HasSuffix(text, "G"):
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			text = text[:len(text)-1]
+		}
+
+		size, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("неверный размер файла %q", value)
+		}
+		sizes = append(sizes, size*multiplier)
+	}
+	return sizes, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// operationStats - статистика операций одного вида с файлами одного размера
+type operationStats struct {
+	Operation  string  `json:"operation"` // upload или download
+	Size       int64   `json:"size"`
+	Count      int     `json:"count"`
+	Errors     int     `json:"errors"`
+	ErrorRate  float64 `json:"error_rate"`
+	FirstError string  `json:"first_error,omitempty"`
+
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	// Пропускная способность считается по успешным операциям за все время этапа
+	BytesPerSecond      float64 `json:"bytes_per_second"`
+	OperationsPerSecond float64 `json:"operations_per_second"`
+
+	// Задержки успешных операций в миллисекундах
+	LatencyP50 float64 `json:"latency_p50_ms"`
+	LatencyP90 float64 `json:"latency_p90_ms"`
+	LatencyP99 float64 `json:"latency_p99_ms"`
+	LatencyMax float64 `json:"latency_max_ms"`
+}
+
+// newOperationStats собирает статистику по результатам операций этапа длительностью elapsed
+func newOperationStats(operation string, size int64, results []operationResult, elapsed time.Duration) operationStats {
+	stats := operationStats{
+		Operation:      operation,
+		Size:           size,
+		Count:          len(results),
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+
+	latencies := make([]time.Duration, 0, len(results))
+	for _, result := range results {
+		if result.err != nil {
+			if stats.Errors == 0 {
+				stats.FirstError = result.err.Error()
+			}
+			stats.Errors++
+			continue
+		}
+		latencies = append(latencies, result.latency)
+	}
+	if stats.Count > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Count)
+	}
+	if elapsed > 0 {
+		stats.BytesPerSecond = float64(int64(len(latencies))*size) / elapsed.Seconds()
+		stats.OperationsPerSecond = float64(len(latencies)) / elapsed.Seconds()
+	}
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.LatencyP50 = milliseconds(percentile(latencies, 50))
+		stats.LatencyP90 = milliseconds(percentile(latencies, 90))
+		stats.LatencyP99 = milliseconds(percentile(latencies, 99))
+		stats.LatencyMax = milliseconds(latencies[len(latencies)-1])
+	}
+	return stats
+}
+
+// percentile возвращает перцентиль p отсортированных задержек методом ближайшего ранга
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (len(sorted)*p + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// milliseconds переводит длительность в миллисекунды
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// printResults выводит статистику таблицей
+func printResults(out io.Writer, results []operationStats) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ОПЕРАЦИЯ\tРАЗМЕР\tЧИСЛО\tОШИБКИ\tСКОРОСТЬ\tОП/С\tP50\tP90\tP99\tМАКС")
+	for _, stats := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d (%.1f%%)\t%s/s\t%.1f\t%.1f мс\t%.1f мс\t%.1f мс\t%.1f мс\n",
+			stats.Operation,
+			formatSize(stats.Size),
+			stats.Count,
+			stats.Errors,
+			stats.ErrorRate*100,
+			formatSize(int64(stats.BytesPerSecond)),
+			stats.OperationsPerSecond,
+			stats.LatencyP50,
+			stats.LatencyP90,
+			stats.LatencyP99,
+			stats.LatencyMax,
+		)
+	}
+	w.Flush()
+
+	for _, stats := range results {
+		if stats.FirstError != "" {
+			fmt.Fprintf(out, "Первая ошибка %s %s: %s\n", stats.Operation, formatSize(stats.Size), stats.FirstError)
+		}
+	}
+}
+
+// formatSize форматирует размер в байтах в читаемый вид
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"TestCase/pkg/client"
+)
+
+// benchRunner выполняет операции нагрузочного теста
+type benchRunner struct {
+	client      *client.APIClient
+	count       int
+	concurrency int
+	download    bool
+	keep        bool
+	log         io.Writer // сообщения о ходе теста; nil - без сообщений
+}
+
+// operationResult - результат одной операции
+type operationResult struct {
+	latency time.Duration
+	err     error
+}
+
+// run выполняет тест для каждого размера и возвращает статистику операций
+func (r *benchRunner) run(ctx context.Context, sizes []int64) []operationStats {
+	var results []operationStats
+	for _, size := range sizes {
+		if ctx.Err() != nil {
+			break
+		}
+
+		r.logf("Загрузка %d файлов по %s в %d потоков", r.count, formatSize(size), r.concurrency)
+		ids := make([]string, r.count)
+		base := make([]byte, size)
+		rand.New(rand.NewSource(time.Now().UnixNano())).Read(base)
+		stats := r.measure(ctx, "upload", size, r.count, func(i int) error {
+			// Номер файла в начале содержимого делает файлы разными, чтобы сервер не мог
+			// обойтись без передачи данных, а копировать случайные данные не приходится
+			prefix := make([]byte, 8)
+			binary.BigEndian.PutUint64(prefix, uint64(i))
+			prefix = prefix[:min(len(prefix), len(base))]
+			data := io.MultiReader(bytes.NewReader(prefix), bytes.NewReader(base[len(prefix):]))
+
+			metadata, err := r.client.UploadReader(ctx, fmt.Sprintf("bench-%d-%d.bin", size, i), data, size)
+			if err != nil {
+				return err
+			}
+			ids[i] = metadata.ID
+			return nil
+		})
+		results = append(results, stats)
+
+		uploaded := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if id != "" {
+				uploaded = append(uploaded, id)
+			}
+		}
+
+		if r.download && len(uploaded) > 0 && ctx.Err() == nil {
+			r.logf("Скачивание %d файлов по %s", len(uploaded), formatSize(size))
+			stats := r.measure(ctx, "download", size, len(uploaded), func(i int) error {
+				body, err := r.client.OpenDownload(ctx, uploaded[i])
+				if err != nil {
+					return err
+				}
+				defer body.Close()
+
+				received, err := io.Copy(io.Discard, body)
+				if err != nil {
+					return err
+				}
+				if received != size {
+					return fmt.Errorf("получено %d байт вместо %d", received, size)
+				}
+				return nil
+			})
+			results = append(results, stats)
+		}
+
+		if !r.keep {
+			// Файлы удаляются и после прерывания теста
+			for _, id := range uploaded {
+				if err := r.client.DeleteFileContext(context.Background(), id); err != nil {
+					r.logf("Не удалось удалить файл %s: %v", id, err)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// measure выполняет count операций operation в r.concurrency потоков и собирает статистику
+func (r *benchRunner) measure(ctx context.Context, name string, size int64, count int, operation func(i int) error) operationStats {
+	results := make([]operationResult, 0, count)
+	var mutex sync.Mutex
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	started := time.Now()
+	for w := 0; w < r.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				opStarted := time.Now()
+				err := operation(i)
+				result := operationResult{latency: time.Since(opStarted), err: err}
+
+				mutex.Lock()
+				results = append(results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+send:
+	for i := 0; i < count; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return newOperationStats(name, size, results, time.Since(started))
+}
+
+// logf выводит сообщение о ходе теста
+func (r *benchRunner) logf(format string, args ...interface{}) {
+	if r.log != nil {
+		fmt.Fprintf(r.log, format+"\n", args...)
+	}
+}
@@ -0,0 +1,1116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/fsck"
+	"TestCase/pkg/storage"
+)
+
+// newUploadCommand создает команду загрузки файлов
+func newUploadCommand(opts *cliOptions) *cobra.Command {
+	var name, uploadToken, baseID, prefix, storageClass string
+	var direct, public, dedup, recursive bool
+	var parallel, chunkCount, replication int
+	var chunkSize int64
+
+	cmd := &cobra.Command{
+		Use:   "upload <file>...",
+		Short: "Загрузить файлы в хранилище (\"-\" - стандартный ввод)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policy := []client.TransferOption{
+				client.WithChunkCount(chunkCount),
+				client.WithChunkSize(chunkSize),
+				client.WithReplicationFactor(replication),
+				client.WithStorageClass(storageClass),
+			}
+			if recursive {
+				if cmd.Flags().Changed("prefix") && len(args) != 1 {
+					return fmt.Errorf("--prefix можно задать только для одного каталога")
+				}
+				return uploadDirs(cmd, opts, args, prefix, cmd.Flags().Changed("prefix"), parallel, public, policy)
+			}
+			if cmd.Flags().Changed("prefix") || cmd.Flags().Changed("parallel") {
+				return fmt.Errorf("--prefix и --parallel используются только с --recursive")
+			}
+			if baseID != "" && len(args) != 1 {
+				return fmt.Errorf("новую версию файла можно загрузить только из одного файла")
+			}
+			if err := checkStdinUpload(args, direct || dedup || baseID != ""); err != nil {
+				return err
+			}
+			apiClient := opts.client()
+			uploaded := make([]*chunking.FileMetadata, 0, len(args))
+
+			for _, filePath := range args {
+				source, label := filePath, filepath.Base(filePath)
+				upload := apiClient.UploadFileContext
+				switch {
+				case direct:
+					upload = apiClient.UploadFileDirectContext
+				case dedup:
+					upload = apiClient.UploadFileDedupContext
+				case baseID != "":
+					upload = func(ctx context.Context, filePath string, opts ...client.TransferOption) (*chunking.FileMetadata, error) {
+						return apiClient.UploadFileDeltaContext(ctx, filePath, baseID, opts...)
+					}
+				case uploadToken != "":
+					upload = func(ctx context.Context, filePath string, opts ...client.TransferOption) (*chunking.FileMetadata, error) {
+						return apiClient.UploadFileWithTokenContext(ctx, filePath, uploadToken, opts...)
+					}
+				}
+				// Стандартный ввод передается потоком: размер заранее неизвестен, а имя
+				// файла задает --name
+				if filePath == "-" {
+					source, label = "стандартный ввод", name
+					upload = func(ctx context.Context, _ string, opts ...client.TransferOption) (*chunking.FileMetadata, error) {
+						if uploadToken != "" {
+							return apiClient.UploadReaderWithToken(ctx, name, os.Stdin, -1, uploadToken, opts...)
+						}
+						return apiClient.UploadReader(ctx, name, os.Stdin, -1, opts...)
+					}
+				}
+
+				metadata, err := upload(cmd.Context(), filePath, append(opts.transferOptions(label), policy...)...)
+				if err != nil {
+					return fmt.Errorf("не удалось загрузить %s: %w", source, err)
+				}
+				if public {
+					visibility, err := apiClient.SetFileVisibilityContext(cmd.Context(), metadata.ID, true)
+					if err != nil {
+						return fmt.Errorf("не удалось опубликовать %s: %w", source, err)
+					}
+					metadata.Public = true
+					if !opts.jsonOutput {
+						fmt.Printf("%s\t%s\t%s\n", metadata.ID, metadata.OriginalName, visibility.URL)
+					}
+					uploaded = append(uploaded, metadata)
+					continue
+				}
+
+				uploaded = append(uploaded, metadata)
+				if !opts.jsonOutput {
+					fmt.Printf("%s\t%s\n", metadata.ID, metadata.OriginalName)
+				}
+			}
+
+			if opts.jsonOutput {
+				return printJSON(uploaded)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "stdin", "имя файла при загрузке из стандартного ввода")
+	cmd.Flags().BoolVar(&direct, "direct", false, "загрузить куски напрямую на серверы хранения по плану API сервера")
+	cmd.Flags().StringVar(&uploadToken, "token", "", "загрузить файл по токену загрузки вместо ключа API")
+	cmd.Flags().BoolVar(&public, "public", false, "сделать файлы доступными по публичной ссылке без ключа API")
+	cmd.Flags().BoolVar(&dedup, "dedup", false, "не передавать данные, если файл с таким содержимым уже есть в хранилище")
+	cmd.Flags().StringVar(&baseID, "base", "", "загрузить новую версию файла с этим идентификатором, передав только измененные куски")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "загрузить каталоги с подкаталогами, сохранив структуру в логических путях файлов")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "префикс логических путей файлов каталога (по умолчанию имя каталога)")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "число файлов каталога, загружаемых одновременно")
+	cmd.Flags().IntVar(&chunkCount, "chunk-count", 0, "число кусков файла (по умолчанию выбирает сервер)")
+	cmd.Flags().Int64Var(&chunkSize, "chunk-size", 0, "желаемый размер куска файла в байтах")
+	cmd.Flags().IntVar(&replication, "replication", 0, "число копий каждого куска (по умолчанию replication_factor сервера)")
+	cmd.Flags().StringVar(&storageClass, "storage-class", "", "класс хранения из storage_classes сервера")
+	cmd.MarkFlagsMutuallyExclusive("direct", "token", "dedup", "base", "recursive")
+	cmd.MarkFlagsMutuallyExclusive("public", "token")
+	return cmd
+}
+
+// uploadDirs загружает каталоги dirs с подкаталогами и выводит идентификаторы файлов с их
+// логическими путями. Без заданного префикса логические пути начинаются с имени каталога.
+// policy задает политику хранения всех файлов
+func uploadDirs(cmd *cobra.Command, opts *cliOptions, dirs []string, prefix string, prefixSet bool, parallel int, public bool, policy []client.TransferOption) error {
+	if parallel < 1 {
+		return fmt.Errorf("--parallel должен быть положительным")
+	}
+	apiClient := opts.client()
+	manifests := make([]*client.DirManifest, 0, len(dirs))
+	failed := 0
+
+	for _, dir := range dirs {
+		dirPrefix := prefix
+		if !prefixSet {
+			dirPrefix = filepath.Base(filepath.Clean(dir))
+		}
+		transfer := append(opts.transferOptions(dir), client.WithConcurrency(parallel))
+		transfer = append(transfer, policy...)
+		manifest, err := apiClient.UploadDirContext(cmd.Context(), dir, dirPrefix, transfer...)
+		if manifest == nil {
+			return fmt.Errorf("не удалось загрузить %s: %w", dir, err)
+		}
+		manifests = append(manifests, manifest)
+
+		for _, file := range manifest.Files {
+			if public {
+				if _, err := apiClient.SetFileVisibilityContext(cmd.Context(), file.ID, true); err != nil {
+					return fmt.Errorf("не удалось опубликовать %s: %w", file.Path, err)
+				}
+			}
+			if !opts.jsonOutput {
+				fmt.Printf("%s\t%s\n", file.ID, file.Path)
+			}
+		}
+		for _, failure := range manifest.Failed {
+			fmt.Fprintf(os.Stderr, "не удалось загрузить %s: %s\n", filepath.Join(dir, filepath.FromSlash(failure.RelativePath)), failure.Error)
+		}
+		failed += len(manifest.Failed)
+		if err != nil && len(manifest.Failed) == 0 {
+			return fmt.Errorf("не удалось загрузить %s: %w", dir, err)
+		}
+	}
+
+	if opts.jsonOutput {
+		if err := printJSON(manifests); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("не удалось загрузить файлов: %d", failed)
+	}
+	return nil
+}
+
+// newSyncCommand создает команду двусторонней синхронизации каталога с файлами хранилища
+func newSyncCommand(opts *cliOptions) *cobra.Command {
+	var statePath string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "sync <localdir> <remote-prefix>",
+		Short: "Синхронизировать локальный каталог с файлами хранилища с префиксом пути в обе стороны",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := opts.client().SyncDirContext(cmd.Context(), args[0], args[1], client.SyncOptions{
+				StatePath: statePath,
+				DryRun:    dryRun,
+			})
+			if result == nil {
+				return fmt.Errorf("не удалось синхронизировать %s: %w", args[0], err)
+			}
+
+			if opts.jsonOutput {
+				if err := printJSON(result); err != nil {
+					return err
+				}
+			} else {
+				for _, action := range result.Actions {
+					if action.Error != "" {
+						fmt.Fprintf(os.Stderr, "%s %s: %s\n", action.Op, action.Path, action.Error)
+						continue
+					}
+					note := ""
+					if action.Conflict {
+						note = "\tконфликт: оставлена более новая версия"
+					}
+					fmt.Printf("%s\t%s%s\n", action.Op, action.Path, note)
+				}
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&statePath, "state", "", "файл состояния прошлой синхронизации (по умолчанию "+client.SyncStateName+" в каталоге)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "только показать действия, ничего не меняя")
+	return cmd
+}
+
+// newWatchCommand создает команду наблюдения за каталогом с загрузкой новых и измененных файлов
+func newWatchCommand(opts *cliOptions) *cobra.Command {
+	var debounce time.Duration
+	var initial bool
+	var parallel int
+
+	cmd := &cobra.Command{
+		Use:   "watch <localdir> <remote-prefix>",
+		Short: "Следить за каталогом и загружать новые и измененные файлы до прерывания (Ctrl+C)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := opts.client().WatchDirContext(cmd.Context(), args[0], client.WatchOptions{
+				Prefix:      args[1],
+				Debounce:    debounce,
+				Concurrency: parallel,
+				Initial:     initial,
+				Notify: func(event client.WatchEvent) {
+					switch {
+					case event.Err != nil:
+						fmt.Fprintf(os.Stderr, "%s: %v (попытка %d, повтор в %s)\n", event.RelativePath, event.Err,
+							event.Attempt, event.RetryAt.Local().Format(time.TimeOnly))
+					case event.Unchanged:
+						if !opts.quiet {
+							fmt.Fprintf(os.Stderr, "%s: не изменился\n", event.RelativePath)
+						}
+					default:
+						fmt.Printf("%s\t%s\n", event.Metadata.ID, event.Metadata.Path)
+					}
+				},
+			})
+			if err != nil && cmd.Context().Err() == nil {
+				return fmt.Errorf("не удалось наблюдать за %s: %w", args[0], err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&debounce, "debounce", 2*time.Second, "сколько файл не должен меняться перед загрузкой")
+	cmd.Flags().BoolVar(&initial, "initial", false, "при запуске загрузить уже лежащие в каталоге новые и измененные файлы")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "сколько файлов загружать одновременно")
+	return cmd
+}
+
+// checkStdinUpload проверяет, что стандартный ввод ("-") указан не больше одного раза и не
+// загружается способом, которому нужно читать файл повторно
+func checkStdinUpload(args []string, rereads bool) error {
+	count := 0
+	for _, arg := range args {
+		if arg == "-" {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("стандартный ввод можно загрузить только один раз")
+	}
+	if count == 1 && rereads {
+		return fmt.Errorf("--direct, --dedup и --base читают файл повторно и не работают со стандартным вводом")
+	}
+	return nil
+}
+
+// newReplaceCommand создает команду замены содержимого файла с сохранением идентификатора
+func newReplaceCommand(opts *cliOptions) *cobra.Command {
+	var ifMatch string
+
+	cmd := &cobra.Command{
+		Use:   "replace <id> <file>",
+		Short: "Заменить содержимое файла, сохранив его идентификатор",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fileID, filePath := args[0], args[1]
+			metadata, err := opts.client().ReplaceFileContext(cmd.Context(), fileID, filePath, ifMatch, opts.transferOptions(filepath.Base(filePath))...)
+			if err != nil {
+				return fmt.Errorf("не удалось заменить %s: %w", fileID, err)
+			}
+
+			if opts.jsonOutput {
+				return printJSON(metadata)
+			}
+			fmt.Printf("%s\t%s\n", metadata.ID, metadata.OriginalName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&ifMatch, "if-match", "", "заменить файл, только если его ETag совпадает с указанным")
+	return cmd
+}
+
+// newPatchCommand создает команду перезаписи части файла
+func newPatchCommand(opts *cliOptions) *cobra.Command {
+	var ifMatch string
+
+	cmd := &cobra.Command{
+		Use:   "patch <id> <offset> <file>",
+		Short: "Перезаписать часть файла с указанного байта содержимым локального файла",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fileID := args[0]
+			offset, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || offset < 0 {
+				return fmt.Errorf("неверное смещение %q", args[1])
+			}
+			data, err := os.ReadFile(args[2])
+			if err != nil {
+				return fmt.Errorf("не удалось прочитать %s: %w", args[2], err)
+			}
+
+			metadata, err := opts.client().PatchFileContext(cmd.Context(), fileID, offset, data, ifMatch)
+			if err != nil {
+				return fmt.Errorf("не удалось изменить %s: %w", fileID, err)
+			}
+
+			if opts.jsonOutput {
+				return printJSON(metadata)
+			}
+			fmt.Printf("%s\t%s\t%d\n", metadata.ID, metadata.OriginalName, metadata.Size)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&ifMatch, "if-match", "", "изменить файл, только если его ETag совпадает с указанным")
+	return cmd
+}
+
+// newDownloadCommand создает команду скачивания файла
+func newDownloadCommand(opts *cliOptions) *cobra.Command {
+	var output, consistency string
+	var resume, direct bool
+
+	cmd := &cobra.Command{
+		Use:   "download <id>",
+		Short: "Скачать файл из хранилища",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if consistency != "" && direct {
+				return fmt.Errorf("флаги --consistency и --direct несовместимы")
+			}
+			apiClient := opts.client()
+			apiClient.SetReadConsistency(consistency)
+
+			if output == "-" {
+				body, err := apiClient.OpenDownload(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				defer body.Close()
+
+				_, err = io.Copy(os.Stdout, body)
+				return err
+			}
+
+			metadata, err := apiClient.GetFileInfoContext(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			outputPath := output
+			if outputPath == "" {
+				outputPath = filepath.Base(metadata.OriginalName)
+			}
+
+			var stats client.TransferStats
+			transferOpts := append(opts.transferOptions(filepath.Base(outputPath)), client.WithStats(&stats))
+
+			if resume && direct {
+				return fmt.Errorf("флаги --resume и --direct несовместимы")
+			}
+			download := apiClient.DownloadFileContext
+			switch {
+			case resume:
+				download = apiClient.DownloadFileResumeContext
+			case direct:
+				download = apiClient.DownloadFileDirectContext
+			}
+			if err := download(cmd.Context(), metadata.ID, outputPath, transferOpts...); err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				return printJSON(map[string]interface{}{
+					"id":       metadata.ID,
+					"path":     outputPath,
+					"size":     metadata.Size,
+					"checksum": metadata.Checksum,
+					"stats":    stats,
+				})
+			}
+			fmt.Println(outputPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "путь для сохранения (по умолчанию исходное имя файла, \"-\" - стандартный вывод)")
+	cmd.Flags().BoolVar(&resume, "resume", false, "продолжить прерванное скачивание, дописав недостающую часть файла")
+	cmd.Flags().BoolVar(&direct, "direct", false, "скачать куски параллельно напрямую с серверов хранения")
+	cmd.Flags().StringVar(&consistency, "consistency", "", "сколько копий каждого куска должны совпасть перед отдачей: one, quorum или all (по умолчанию по настройке сервера)")
+	return cmd
+}
+
+// newListCommand создает команду вывода списка файлов
+func newListCommand(opts *cliOptions) *cobra.Command {
+	var long bool
+	var idle time.Duration
+	var dropBox string
+
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "Показать список файлов",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apiClient := opts.client()
+
+			filter := client.ListFilter{DropBox: dropBox}
+			if idle > 0 {
+				filter.AccessedBefore = time.Now().Add(-idle)
+			}
+
+			ids, err := apiClient.ListFilesFilteredContext(cmd.Context(), filter)
+			if err != nil {
+				return err
+			}
+
+			if !long {
+				if opts.jsonOutput {
+					return printJSON(ids)
+				}
+				for _, id := range ids {
+					fmt.Println(id)
+				}
+				return nil
+			}
+
+			files := make([]*chunking.FileMetadata, 0, len(ids))
+			for _, id := range ids {
+				metadata, err := apiClient.GetFileInfoContext(cmd.Context(), id)
+				if err != nil {
+					// Файл мог быть удален между запросами
+					continue
+				}
+				files = append(files, metadata)
+			}
+
+			if opts.jsonOutput {
+				return printJSON(files)
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "ID\tРАЗМЕР\tСОЗДАН\tСКАЧИВАНИЙ\tПОСЛЕДНЕЕ СКАЧИВАНИЕ\tИМЯ")
+			for _, metadata := range files {
+				lastAccess := "-"
+				if metadata.LastAccessedAt != nil {
+					lastAccess = metadata.LastAccessedAt.Local().Format(time.DateTime)
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					metadata.ID,
+					formatSize(metadata.Size),
+					metadata.CreatedAt.Local().Format(time.DateTime),
+					metadata.DownloadCount,
+					lastAccess,
+					metadata.OriginalName,
+				)
+			}
+			return writer.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVarP(&long, "long", "l", false, "показать размер, дату, число скачиваний и имя файлов")
+	cmd.Flags().DurationVar(&idle, "idle", 0, "только файлы, которые не скачивались дольше заданного времени, например 2160h")
+	cmd.Flags().StringVar(&dropBox, "drop-box", "", "только файлы, принятые через ящик с заданным идентификатором")
+	return cmd
+}
+
+// newRemoveCommand создает команду удаления файлов
+func newRemoveCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm <id>...",
+		Short: "Удалить файлы из хранилища",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apiClient := opts.client()
+
+			for _, id := range args {
+				if err := apiClient.DeleteFileContext(cmd.Context(), id); err != nil {
+					return fmt.Errorf("не удалось удалить %s: %w", id, err)
+				}
+				if !opts.jsonOutput {
+					fmt.Printf("Удален %s\n", id)
+				}
+			}
+
+			if opts.jsonOutput {
+				return printJSON(map[string]interface{}{"deleted": args})
+			}
+			return nil
+		},
+	}
+}
+
+// newInfoCommand создает команду вывода полных метаданных файла, включая куски
+func newInfoCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "info <id>",
+		Short: "Показать метаданные файла и список кусков",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			metadata, err := opts.client().GetFileInfoContext(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				return printJSON(metadata)
+			}
+
+			printStat(metadata)
+			fmt.Println()
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "КУСОК\tID\tРАЗМЕР\tКОНТРОЛЬНАЯ СУММА")
+			for _, chunk := range metadata.Chunks {
+				fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", chunk.Index, chunk.ID, formatSize(chunk.Size), chunk.Checksum)
+			}
+			return writer.Flush()
+		},
+	}
+}
+
+// newStatCommand создает команду краткой сводки о файле
+func newStatCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stat <id>",
+		Short: "Показать краткую сводку о файле",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			metadata, err := opts.client().GetFileInfoContext(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				return printJSON(map[string]interface{}{
+					"id":                 metadata.ID,
+					"name":               metadata.OriginalName,
+					"path":               metadata.Path,
+					"size":               metadata.Size,
+					"content_type":       metadata.ContentType,
+					"chunk_count":        metadata.ChunkCount,
+					"checksum":           metadata.Checksum,
+					"checksum_algorithm": metadata.ChecksumAlgorithm,
+					"created_at":         metadata.CreatedAt,
+					"public":             metadata.Public,
+					"owner":              metadata.Owner,
+				})
+			}
+
+			printStat(metadata)
+			return nil
+		},
+	}
+}
+
+// printStat выводит основные поля метаданных файла
+func printStat(metadata *chunking.FileMetadata) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "ID:\t%s\n", metadata.ID)
+	fmt.Fprintf(writer, "Имя:\t%s\n", metadata.OriginalName)
+	if metadata.Path != "" {
+		fmt.Fprintf(writer, "Путь:\t%s\n", metadata.Path)
+	}
+	fmt.Fprintf(writer, "Размер:\t%s (%d байт)\n", formatSize(metadata.Size), metadata.Size)
+	if metadata.ContentType != "" {
+		fmt.Fprintf(writer, "Тип:\t%s\n", metadata.ContentType)
+	}
+	fmt.Fprintf(writer, "Кусков:\t%d\n", metadata.ChunkCount)
+	fmt.Fprintf(writer, "Контрольная сумма:\t%s (%s)\n", metadata.Checksum, metadata.ChecksumAlgorithm)
+	fmt.Fprintf(writer, "Создан:\t%s\n", metadata.CreatedAt.Local().Format(time.DateTime))
+	if metadata.Owner != "" {
+		fmt.Fprintf(writer, "Владелец:\t%s\n", metadata.Owner)
+	}
+	if metadata.Public {
+		fmt.Fprintf(writer, "Доступ:\tпубличный\n")
+	}
+	writer.Flush()
+}
+
+// newHealthCommand создает команду проверки состояния кластера
+func newHealthCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "health",
+		Short: "Проверить состояние API сервера и серверов хранения",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := opts.client().HealthContext(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				if err := printJSON(status); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("Состояние: %s (серверов хранения доступно %d из %d)\n",
+					status.Status, status.HealthyServers, status.TotalServers)
+			}
+
+			if status.Status != "healthy" {
+				return fmt.Errorf("кластер в состоянии %s", status.Status)
+			}
+			return nil
+		},
+	}
+}
+
+// newTokenCommand создает команду выдачи токена однократной загрузки файла
+func newTokenCommand(opts *cliOptions) *cobra.Command {
+	var request client.UploadTokenRequest
+
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Выдать токен, по которому один файл можно загрузить без ключа API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			uploadToken, err := opts.client().CreateUploadTokenContext(cmd.Context(), request)
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				return printJSON(uploadToken)
+			}
+			fmt.Println(uploadToken.Token)
+			if !opts.quiet {
+				fmt.Fprintf(os.Stderr, "Файл получит идентификатор %s, токен действует до %s\n",
+					uploadToken.FileID, uploadToken.ExpiresAt.Local().Format(time.DateTime))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&request.ExpiresIn, "expires", 0, "срок действия токена (по умолчанию upload_token_ttl сервера)")
+	cmd.Flags().Int64Var(&request.MaxSize, "max-size", 0, "наибольший размер файла в байтах (по умолчанию max_file_size сервера)")
+	cmd.Flags().StringSliceVar(&request.ContentTypes, "type", nil, "разрешенный тип содержимого (тип/подтип или тип/*), можно указать несколько раз")
+	return cmd
+}
+
+// newDropBoxCommand создает команду создания ящика для приема файлов
+func newDropBoxCommand(opts *cliOptions) *cobra.Command {
+	var request client.DropBoxRequest
+
+	cmd := &cobra.Command{
+		Use:   "dropbox",
+		Short: "Создать ссылку, по которой внешние отправители могут только загружать файлы",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dropBox, err := opts.client().CreateDropBoxContext(cmd.Context(), request)
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				return printJSON(dropBox)
+			}
+			fmt.Println(dropBox.URL)
+			if !opts.quiet {
+				fmt.Fprintf(os.Stderr, "Ящик %s действует до %s, принятые файлы: ls --drop-box %s\n",
+					dropBox.ID, dropBox.ExpiresAt.Local().Format(time.DateTime), dropBox.ID)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&request.ExpiresIn, "expires", 0, "срок действия ящика (по умолчанию drop_box_max_ttl сервера)")
+	cmd.Flags().Int64Var(&request.MaxFileSize, "max-size", 0, "наибольший размер одного файла в байтах (по умолчанию max_file_size сервера)")
+	cmd.Flags().Int64Var(&request.MaxTotalSize, "max-total-size", 0, "наибольший суммарный размер файлов в байтах (0 - без ограничения)")
+	cmd.Flags().IntVar(&request.MaxFiles, "max-files", 0, "наибольшее число файлов (0 - без ограничения)")
+	cmd.Flags().StringSliceVar(&request.ContentTypes, "type", nil, "разрешенный тип содержимого (тип/подтип или тип/*), можно указать несколько раз")
+	return cmd
+}
+
+// newVisibilityCommand создает команду публикации файла или закрытия доступа по ссылке
+func newVisibilityCommand(opts *cliOptions, public bool) *cobra.Command {
+	use, short := "publish <id>", "Открыть доступ к файлу по публичной ссылке без ключа API"
+	if !public {
+		use, short = "unpublish <id>", "Закрыть доступ к файлу по публичной ссылке"
+	}
+
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			visibility, err := opts.client().SetFileVisibilityContext(cmd.Context(), args[0], public)
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				return printJSON(visibility)
+			}
+			if visibility.URL != "" {
+				fmt.Println(visibility.URL)
+			}
+			return nil
+		},
+	}
+}
+
+// newShareCommand создает команду выдачи права на файл другому клиенту API.
+// Без имени клиента команда показывает выданные права
+func newShareCommand(opts *cliOptions) *cobra.Command {
+	var write bool
+
+	cmd := &cobra.Command{
+		Use:   "share <id> [клиент]",
+		Short: "Выдать клиенту API право на файл или показать выданные права",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			apiClient := opts.client()
+
+			var acl *client.FileACL
+			var err error
+			if len(args) == 1 {
+				acl, err = apiClient.GetFileACLContext(cmd.Context(), args[0])
+			} else {
+				permission := chunking.PermissionRead
+				if write {
+					permission = chunking.PermissionReadWrite
+				}
+				acl, err = apiClient.GrantFileAccessContext(cmd.Context(), args[0], args[1], permission)
+			}
+			if err != nil {
+				return err
+			}
+
+			return printACL(opts, acl)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&write, "write", "w", false, "разрешить также удаление файла и изменение его видимости")
+	return cmd
+}
+
+// newUnshareCommand создает команду отзыва права клиента API на файл
+func newUnshareCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unshare <id> <клиент>",
+		Short: "Отозвать право клиента API на файл",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			acl, err := opts.client().RevokeFileAccessContext(cmd.Context(), args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return printACL(opts, acl)
+		},
+	}
+}
+
+// printACL выводит владельца файла и выданные права
+func printACL(opts *cliOptions, acl *client.FileACL) error {
+	if opts.jsonOutput {
+		return printJSON(acl)
+	}
+
+	names := make([]string, 0, len(acl.Grants))
+	for name := range acl.Grants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if acl.Owner != "" {
+		fmt.Fprintf(writer, "%s\tвладелец\n", acl.Owner)
+	}
+	for _, name := range names {
+		fmt.Fprintf(writer, "%s\t%s\n", name, acl.Grants[name])
+	}
+	return writer.Flush()
+}
+
+// newCatalogCommand создает команды резервного копирования каталога метаданных
+func newCatalogCommand(opts *cliOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "catalog",
+		Short: "Резервная копия и восстановление каталога метаданных файлов",
+	}
+	cmd.AddCommand(newCatalogExportCommand(opts), newCatalogImportCommand(opts), newCatalogRebuildCommand(opts))
+	return cmd
+}
+
+// newCatalogExportCommand создает команду выгрузки снимка каталога
+func newCatalogExportCommand(opts *cliOptions) *cobra.Command {
+	var output, format string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Выгрузить снимок каталога",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" || output == "-" {
+				return opts.client().ExportCatalogContext(cmd.Context(), os.Stdout, format)
+			}
+
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("не удалось создать файл: %w", err)
+			}
+			if err := opts.client().ExportCatalogContext(cmd.Context(), file, format); err != nil {
+				file.Close()
+				os.Remove(output)
+				return err
+			}
+			return file.Close()
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "путь для сохранения снимка (по умолчанию стандартный вывод)")
+	cmd.Flags().StringVar(&format, "format", client.CatalogFormatNDJSON, "формат снимка: ndjson, json, protobuf или msgpack")
+	return cmd
+}
+
+// newCatalogImportCommand создает команду загрузки снимка каталога
+func newCatalogImportCommand(opts *cliOptions) *cobra.Command {
+	var overwrite bool
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "import <файл|->",
+		Short: "Загрузить снимок каталога, например в новый API сервер",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var source io.Reader = os.Stdin
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("не удалось открыть файл: %w", err)
+				}
+				defer file.Close()
+				source = file
+			}
+
+			result, err := opts.client().ImportCatalogFormatContext(cmd.Context(), source, format, overwrite)
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				if err := printJSON(result); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("Сохранено: %d, пропущено: %d, с ошибкой: %d\n", result.Imported, result.Skipped, len(result.Failed))
+				for _, failure := range result.Failed {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", failure.ID, failure.Error)
+				}
+			}
+
+			if len(result.Failed) > 0 {
+				return fmt.Errorf("не удалось сохранить %d файлов", len(result.Failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "заменить метаданные файлов, уже бывших в каталоге")
+	cmd.Flags().StringVar(&format, "format", client.CatalogFormatJSON, "формат снимка: json (и ndjson), protobuf или msgpack")
+	return cmd
+}
+
+// newCatalogRebuildCommand создает команду восстановления каталога по манифестам
+func newCatalogRebuildCommand(opts *cliOptions) *cobra.Command {
+	var overwrite bool
+
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Восстановить каталог по манифестам файлов на серверах хранения",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := opts.client().RebuildCatalogContext(cmd.Context(), overwrite)
+			if err != nil {
+				return err
+			}
+
+			if opts.jsonOutput {
+				if err := printJSON(result); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("Серверов просмотрено: %d, файлов найдено: %d\n", result.NodesScanned, result.Manifests)
+				fmt.Printf("Сохранено: %d, пропущено: %d, с ошибкой: %d\n", result.Imported, result.Skipped, len(result.Failed))
+				for _, nodeErr := range result.NodeErrors {
+					fmt.Fprintf(os.Stderr, "сервер %s: %s\n", nodeErr.Node, nodeErr.Error)
+				}
+				for _, failure := range result.Failed {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", failure.ID, failure.Error)
+				}
+			}
+
+			if len(result.NodeErrors) > 0 || len(result.Failed) > 0 {
+				return fmt.Errorf("каталог восстановлен не полностью: %d серверов недоступно, %d файлов не сохранено",
+					len(result.NodeErrors), len(result.Failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "заменить метаданные файлов, уже бывших в каталоге")
+	return cmd
+}
+
+// newFsckCommand создает команду проверки согласованности каталога и серверов хранения.
+// С --catalog проверка выполняется без API сервера: по снимку каталога и прямым запросам
+// к серверам хранения
+func newFsckCommand(opts *cliOptions) *cobra.Command {
+	var verify bool
+	var catalogPath, secret string
+	var nodes []string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Сверить каталог с кусками на серверах хранения",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var report *fsck.Report
+			if catalogPath == "" {
+				var err error
+				report, err = opts.client().CheckConsistencyContext(cmd.Context(), verify)
+				if err != nil {
+					return err
+				}
+			} else {
+				file, err := os.Open(catalogPath)
+				if err != nil {
+					return fmt.Errorf("не удалось открыть снимок каталога: %w", err)
+				}
+				files, err := catalog.ReadSnapshot(file)
+				file.Close()
+				if err != nil {
+					return fmt.Errorf("неверный снимок каталога: %w", err)
+				}
+
+				report = fsck.Check(cmd.Context(), files, fsck.Options{
+					Verify: verify,
+					Nodes:  nodes,
+					Client: func(node string) fsck.Inventory {
+						storageOpts := []storage.ClientOption{storage.WithTimeout(timeout)}
+						storageClient := storage.NewStorageClient(storage.NodeURL(node), storageOpts...)
+						if opts.h2c {
+							storageClient = storage.NewH2CStorageClient(storage.NodeURL(node), storageOpts...)
+						}
+						storageClient.Secret = secret
+						return storageClient
+					},
+				})
+			}
+
+			if opts.jsonOutput {
+				if err := printJSON(report); err != nil {
+					return err
+				}
+			} else {
+				printFsckReport(report)
+			}
+
+			if !report.OK() {
+				return fmt.Errorf("найдено проблем: %d", len(report.Problems))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&verify, "verify", false, "пересчитать контрольные суммы кусков на серверах хранения")
+	cmd.Flags().StringVar(&catalogPath, "catalog", "", "проверить снимок каталога (catalog export) без API сервера")
+	cmd.Flags().StringSliceVar(&nodes, "node", nil, "сервер хранения host:port для проверки без API сервера, помимо упомянутых в снимке")
+	cmd.Flags().StringVar(&secret, "secret", os.Getenv("INTERNAL_SECRET"), "общий ключ серверов хранения для проверки без API сервера (переменная окружения INTERNAL_SECRET)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "таймаут запроса к серверу хранения при проверке без API сервера")
+	return cmd
+}
+
+// printFsckReport выводит отчет проверки согласованности таблицами
+func printFsckReport(report *fsck.Report) {
+	fmt.Printf("Файлов: %d, кусков: %d, потеряно кусков: %d\n", report.Files, report.Chunks, report.LostChunks)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "СЕРВЕР\tДОСТУПЕН\tКУСКОВ\tОБЪЕМ")
+	for _, node := range report.Nodes {
+		reachable := "да"
+		if !node.Reachable {
+			reachable = "нет"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", node.Node, reachable, node.Chunks, formatSize(node.Bytes))
+	}
+	writer.Flush()
+
+	if report.OK() {
+		fmt.Println("Проблем не найдено")
+		return
+	}
+
+	fmt.Println()
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ПРОБЛЕМА\tСЕРВЕР\tКУСОК\tФАЙЛ\tОЖИДАЛОСЬ\tФАКТИЧЕСКИ\tПОДРОБНОСТИ")
+	for _, problem := range report.Problems {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			problem.Kind, problem.Node, problem.ChunkID, problem.FileID, problem.Expected, problem.Actual, problem.Detail)
+	}
+	writer.Flush()
+}
+
+// newMirrorCommand создает команды зеркалирования в резервное развертывание
+func newMirrorCommand(opts *cliOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "Состояние зеркалирования файлов в резервное развертывание",
+	}
+	cmd.AddCommand(newMirrorStatusCommand(opts), newMirrorResyncCommand(opts))
+	return cmd
+}
+
+// newMirrorStatusCommand создает команду вывода состояния зеркалирования
+func newMirrorStatusCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Показать задержку и ошибки зеркалирования",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := opts.client().GetMirrorStatusContext(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if opts.jsonOutput {
+				return printJSON(status)
+			}
+
+			if !status.Enabled {
+				fmt.Println("Зеркалирование отключено")
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(writer, "Приемник:\t%s\n", status.Target)
+			fmt.Fprintf(writer, "Файлов в зеркале:\t%d\n", status.Files)
+			fmt.Fprintf(writer, "Ожидают отражения:\t%d\n", status.Pending)
+			fmt.Fprintf(writer, "Задержка:\t%s\n", formatSeconds(status.LagSeconds))
+			fmt.Fprintf(writer, "Последняя задержка:\t%s\n", formatSeconds(status.LastLagSeconds))
+			fmt.Fprintf(writer, "Отражено:\t%d\n", status.Mirrored)
+			fmt.Fprintf(writer, "Не отражено:\t%d\n", status.Failed)
+			if status.LastMirroredAt != nil {
+				fmt.Fprintf(writer, "Последнее отражение:\t%s\n", status.LastMirroredAt.Local().Format(time.DateTime))
+			}
+			if status.LastResyncAt != nil {
+				fmt.Fprintf(writer, "Последняя сверка:\t%s\n", status.LastResyncAt.Local().Format(time.DateTime))
+			}
+			if status.LastError != "" {
+				fmt.Fprintf(writer, "Последняя ошибка:\t%s (%s)\n", status.LastError, status.LastErrorAt.Local().Format(time.DateTime))
+			}
+			writer.Flush()
+			return nil
+		},
+	}
+}
+
+// newMirrorResyncCommand создает команду внеочередной сверки зеркала с каталогом
+func newMirrorResyncCommand(opts *cliOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resync",
+		Short: "Сверить приемник с каталогом и отразить пропущенные изменения",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.client().ResyncMirrorContext(cmd.Context()); err != nil {
+				return err
+			}
+			fmt.Println("Сверка зеркала запущена")
+			return nil
+		},
+	}
+}
+
+// formatSeconds записывает длительность в секундах с точностью до десятой доли
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds*10) * time.Second / 10).String()
+}
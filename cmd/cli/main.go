@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"TestCase/pkg/client"
+)
+
+// defaultAPIURL используется, если адрес API не задан ни флагом, ни переменной окружения
+const defaultAPIURL = "http://localhost:8080"
+
+// cliOptions содержит глобальные параметры командной строки
+type cliOptions struct {
+	apiURL     string
+	apiKey     string
+	jsonOutput bool
+	quiet      bool
+	h2c        bool
+	region     string
+}
+
+func main() {
+	// Ctrl+C отменяет текущую передачу
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(1)
+	}
+}
+
+// newRootCommand создает корневую команду со всеми подкомандами
+func newRootCommand() *cobra.Command {
+	opts := &cliOptions{}
+
+	apiURL := os.Getenv("STORAGE_API_URL")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+
+	root := &cobra.Command{
+		Use:          "storage-cli",
+		Short:        "Клиент распределенного хранилища файлов",
+		SilenceUsage: true,
+	}
+
+	root.PersistentFlags().StringVar(&opts.apiURL, "api", apiURL, "адрес API сервера: http://host:port или unix:///path (переменная окружения STORAGE_API_URL)")
+	root.PersistentFlags().StringVar(&opts.apiKey, "api-key", os.Getenv("STORAGE_API_KEY"), "ключ API (переменная окружения STORAGE_API_KEY)")
+	root.PersistentFlags().BoolVar(&opts.jsonOutput, "json", false, "выводить результат в формате JSON")
+	root.PersistentFlags().BoolVarP(&opts.quiet, "quiet", "q", false, "не показывать индикатор прогресса")
+	root.PersistentFlags().StringVar(&opts.region, "region", os.Getenv("STORAGE_REGION"), "регион клиента: при прямом скачивании куски читаются сначала с серверов этого региона (переменная окружения STORAGE_REGION)")
+	root.PersistentFlags().BoolVar(&opts.h2c, "h2c", false, "обращаться к серверам по HTTP/2 без TLS (серверы запущены с http2_cleartext)")
+
+	root.AddCommand(
+		newUploadCommand(opts),
+		newSyncCommand(opts),
+		newWatchCommand(opts),
+		newReplaceCommand(opts),
+		newPatchCommand(opts),
+		newDownloadCommand(opts),
+		newListCommand(opts),
+		newRemoveCommand(opts),
+		newInfoCommand(opts),
+		newStatCommand(opts),
+		newHealthCommand(opts),
+		newTokenCommand(opts),
+		newDropBoxCommand(opts),
+		newVisibilityCommand(opts, true),
+		newVisibilityCommand(opts, false),
+		newShareCommand(opts),
+		newUnshareCommand(opts),
+		newCatalogCommand(opts),
+		newFsckCommand(opts),
+		newMirrorCommand(opts),
+	)
+
+	return root
+}
+
+// client создает клиент API по текущим параметрам
+func (o *cliOptions) client() *client.APIClient {
+	apiClient := client.NewAPIClient(o.apiURL)
+	apiClient.SetAPIKey(o.apiKey)
+	apiClient.SetRegion(o.region)
+	if o.h2c {
+		apiClient.UseH2C()
+	}
+	return apiClient
+}
+
+// showProgress сообщает, нужно ли рисовать индикатор прогресса
+func (o *cliOptions) showProgress() bool {
+	return !o.quiet && !o.jsonOutput && isTerminal(os.Stderr)
+}
+
+// transferOptions возвращает опции передачи с индикатором прогресса, если он включен
+func (o *cliOptions) transferOptions(label string) []client.TransferOption {
+	if !o.showProgress() {
+		return nil
+	}
+
+	bar := newProgressBar(os.Stderr, label)
+	return []client.TransferOption{
+		client.WithProgress(func(progress client.Progress) {
+			bar.Update(progress)
+			if progress.Done {
+				bar.Finish()
+			}
+		}),
+	}
+}
+
+// printJSON выводит значение в формате JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// isTerminal проверяет, подключен ли файл к терминалу
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatSize форматирует размер в байтах в читаемый вид
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
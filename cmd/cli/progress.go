@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"TestCase/pkg/client"
+)
+
+// progressBarWidth - ширина полосы индикатора в символах
+const progressBarWidth = 30
+
+// progressBar рисует индикатор прогресса передачи в одну строку терминала
+type progressBar struct {
+	out       io.Writer
+	label     string
+	startTime time.Time
+}
+
+// newProgressBar создает индикатор прогресса
+func newProgressBar(out io.Writer, label string) *progressBar {
+	return &progressBar{
+		out:       out,
+		label:     label,
+		startTime: time.Now(),
+	}
+}
+
+// Update перерисовывает индикатор; подходит как client.ProgressFunc
+func (p *progressBar) Update(progress client.Progress) {
+	if progress.Total <= 0 {
+		// Размер неизвестен: показываем только объем и время
+		fmt.Fprintf(p.out, "\r%s %s %s", p.label, formatSize(progress.Transferred), time.Since(p.startTime).Truncate(time.Second))
+		return
+	}
+
+	filled := int(float64(progressBarWidth) * float64(progress.Transferred) / float64(progress.Total))
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+
+	fmt.Fprintf(p.out, "\r%s [%s%s] %3d%% %s/%s %s/s",
+		p.label,
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", progressBarWidth-filled),
+		progress.Transferred*100/progress.Total,
+		formatSize(progress.Transferred),
+		formatSize(progress.Total),
+		formatSize(int64(progress.Rate)),
+	)
+}
+
+// Finish переводит строку после завершения передачи
+func (p *progressBar) Finish() {
+	fmt.Fprintln(p.out)
+}
@@ -0,0 +1,174 @@
+// Команда migrate переносит все файлы из одного работающего развертывания в другое:
+// содержимое передается потоком, копия сверяется с источником, а журнал переноса
+// позволяет продолжить прерванный перенос и дозагрузить изменения повторным запуском
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"TestCase/pkg/client"
+)
+
+// migrateOptions содержит параметры переноса
+type migrateOptions struct {
+	sourceURL   string
+	sourceKey   string
+	targetURL   string
+	targetKey   string
+	h2c         bool
+	concurrency int
+	statePath   string
+	prefix      string
+	fullVerify  bool
+	dryRun      bool
+	jsonOutput  bool
+}
+
+func main() {
+	// Ctrl+C останавливает выдачу новых файлов; перенесенные остаются в журнале
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(1)
+	}
+}
+
+// newRootCommand создает команду переноса
+func newRootCommand() *cobra.Command {
+	opts := &migrateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "storage-migrate",
+		Short: "Перенос всех файлов из одного развертывания в другое",
+		Long: "Переносит файлы источника --source в приемник --target в --concurrency потоков, сверяя каждую копию\n" +
+			"по размеру и контрольной сумме. Перенесенные файлы записываются в журнал --state: повторный запуск\n" +
+			"пропускает их, а измененные в источнике переносит заново. Идентификаторы файлов в приемнике новые;\n" +
+			"соответствие идентификаторов источника и приемника - в журнале",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.sourceURL == "" || opts.targetURL == "" {
+				return fmt.Errorf("нужно указать --source и --target")
+			}
+			if opts.sourceURL == opts.targetURL {
+				return fmt.Errorf("источник и приемник совпадают")
+			}
+			if opts.concurrency < 1 {
+				return fmt.Errorf("--concurrency должен быть больше нуля")
+			}
+
+			source := newClient(opts.sourceURL, opts.sourceKey, opts.h2c)
+			target := newClient(opts.targetURL, opts.targetKey, opts.h2c)
+
+			ctx := cmd.Context()
+			ids, err := source.ListFilesFilteredContext(ctx, client.ListFilter{Prefix: opts.prefix})
+			if err != nil {
+				return fmt.Errorf("не удалось получить список файлов источника: %w", err)
+			}
+
+			// Пробный запуск читает журнал, но ничего в него не пишет
+			state, err := openState(opts.statePath, !opts.dryRun)
+			if err != nil {
+				return err
+			}
+			defer state.Close()
+
+			m := &migrator{
+				source:      source,
+				target:      target,
+				state:       state,
+				concurrency: opts.concurrency,
+				fullVerify:  opts.fullVerify,
+				dryRun:      opts.dryRun,
+			}
+			if !opts.jsonOutput {
+				m.log = os.Stderr
+			}
+
+			summary := m.run(ctx, ids)
+			if opts.jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(summary); err != nil {
+					return err
+				}
+			} else {
+				printSummary(os.Stdout, summary)
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if summary.Failed > 0 {
+				return fmt.Errorf("не удалось перенести файлов: %d", summary.Failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.sourceURL, "source", os.Getenv("MIGRATE_SOURCE_URL"), "адрес API сервера источника (переменная окружения MIGRATE_SOURCE_URL)")
+	cmd.Flags().StringVar(&opts.sourceKey, "source-api-key", os.Getenv("MIGRATE_SOURCE_API_KEY"), "ключ API источника (переменная окружения MIGRATE_SOURCE_API_KEY)")
+	cmd.Flags().StringVar(&opts.targetURL, "target", os.Getenv("MIGRATE_TARGET_URL"), "адрес API сервера приемника (переменная окружения MIGRATE_TARGET_URL)")
+	cmd.Flags().StringVar(&opts.targetKey, "target-api-key", os.Getenv("MIGRATE_TARGET_API_KEY"), "ключ API приемника (переменная окружения MIGRATE_TARGET_API_KEY)")
+	cmd.Flags().BoolVar(&opts.h2c, "h2c", false, "обращаться к серверам по HTTP/2 без TLS (серверы запущены с http2_cleartext)")
+	cmd.Flags().IntVarP(&opts.concurrency, "concurrency", "c", 4, "число одновременно переносимых файлов")
+	cmd.Flags().StringVar(&opts.statePath, "state", "migrate-state.jsonl", "журнал переноса; пустое значение - без журнала")
+	cmd.Flags().StringVar(&opts.prefix, "prefix", "", "переносить только файлы, логический путь которых начинается с префикса")
+	cmd.Flags().BoolVar(&opts.fullVerify, "full-verify", false, "перечитывать каждую копию из приемника и сверять контрольную сумму")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "только показать, какие файлы будут перенесены")
+	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "выводить итог в формате JSON")
+
+	return cmd
+}
+
+// newClient создает клиент API сервера
+func newClient(apiURL, apiKey string, h2c bool) *client.APIClient {
+	apiClient := client.NewAPIClient(apiURL)
+	apiClient.SetAPIKey(apiKey)
+	if h2c {
+		apiClient.UseH2C()
+	}
+	return apiClient
+}
+
+// printSummary выводит итог переноса
+func printSummary(w io.Writer, summary *migrationSummary) {
+	verb := "Перенесено"
+	if summary.DryRun {
+		verb = "Будет перенесено"
+	}
+	fmt.Fprintf(w, "Файлов в источнике: %d\n", summary.Files)
+	fmt.Fprintf(w, "%s: %d (%s)\n", verb, summary.Migrated, formatSize(summary.Bytes))
+	fmt.Fprintf(w, "Пропущено, уже перенесены: %d\n", summary.Skipped)
+	fmt.Fprintf(w, "Ошибок: %d\n", summary.Failed)
+	fmt.Fprintf(w, "Время: %s\n", summary.Duration.Round(time.Millisecond))
+	for _, failure := range summary.Errors {
+		fmt.Fprintf(w, "  %s: %s\n", failure.SourceID, failure.Error)
+	}
+}
+
+// formatSize записывает размер в байтах в удобочитаемом виде
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
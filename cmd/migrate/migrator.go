@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+)
+
+// migrator переносит файлы из одного развертывания в другое
+type migrator struct {
+	source      *client.APIClient
+	target      *client.APIClient
+	state       *migrationState
+	concurrency int
+	fullVerify  bool      // всегда перечитывать файл из приемника для сверки
+	dryRun      bool      // только сообщить, какие файлы будут перенесены
+	log         io.Writer // сообщения о ходе переноса; nil - без сообщений
+}
+
+// migrationSummary - итог переноса
+type migrationSummary struct {
+	Files    int                `json:"files"`    // файлов в источнике
+	Migrated int                `json:"migrated"` // перенесено за этот запуск; с dry_run - будет перенесено
+	Skipped  int                `json:"skipped"`  // уже перенесены и не изменились
+	Failed   int                `json:"failed"`
+	Bytes    int64              `json:"bytes"` // объем перенесенных файлов
+	DryRun   bool               `json:"dry_run,omitempty"`
+	Duration time.Duration      `json:"duration"`
+	Errors   []migrationFailure `json:"errors,omitempty"`
+}
+
+// migrationFailure - файл, который не удалось перенести
+type migrationFailure struct {
+	SourceID string `json:"source_id"`
+	Error    string `json:"error"`
+}
+
+// fileResult - итог переноса одного файла
+type fileResult int
+
+const (
+	fileMigrated fileResult = iota
+	fileSkipped
+)
+
+// run переносит файлы ids в r.concurrency потоков. Прерывание контекста останавливает
+// выдачу новых файлов; уже перенесенные остаются в журнале
+func (m *migrator) run(ctx context.Context, ids []string) *migrationSummary {
+	summary := &migrationSummary{Files: len(ids), DryRun: m.dryRun}
+	started := time.Now()
+	var mutex sync.Mutex
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < m.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				result, size, err := m.migrateFile(ctx, id)
+
+				mutex.Lock()
+				switch {
+				case err != nil:
+					summary.Failed++
+					summary.Errors = append(summary.Errors, migrationFailure{SourceID: id, Error: err.Error()})
+					m.logf("Не удалось перенести файл %s: %v", id, err)
+				case result == fileSkipped:
+					summary.Skipped++
+				default:
+					summary.Migrated++
+					summary.Bytes += size
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, id := range ids {
+		select {
+		case jobs <- id:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(summary.Errors, func(i, j int) bool { return summary.Errors[i].SourceID < summary.Errors[j].SourceID })
+	summary.Duration = time.Since(started)
+	return summary
+}
+
+// migrateFile переносит файл источника sourceID и возвращает итог и размер файла. Файл,
+// уже перенесенный с той же контрольной суммой, пропускается; измененный после переноса
+// файл переносится заново, а прежняя копия в приемнике удаляется
+func (m *migrator) migrateFile(ctx context.Context, sourceID string) (fileResult, int64, error) {
+	metadata, err := m.source.GetFileInfoContext(ctx, sourceID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("не удалось получить метаданные: %w", err)
+	}
+
+	previous, migrated := m.state.migrated(sourceID)
+	if migrated && previous.Checksum == metadata.Checksum && previous.Size == metadata.Size {
+		return fileSkipped, 0, nil
+	}
+	if m.dryRun {
+		m.logf("%s: %s (%s) будет перенесен", sourceID, metadata.OriginalName, formatSize(metadata.Size))
+		return fileMigrated, metadata.Size, nil
+	}
+
+	copied, err := m.copyFile(ctx, metadata)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := m.verify(ctx, metadata, copied); err != nil {
+		m.discard(copied.ID)
+		return 0, 0, err
+	}
+	if err := m.copyAccess(ctx, metadata, copied.ID); err != nil {
+		m.discard(copied.ID)
+		return 0, 0, err
+	}
+
+	if err := m.state.record(migrationRecord{
+		SourceID:   sourceID,
+		TargetID:   copied.ID,
+		Size:       metadata.Size,
+		Checksum:   metadata.Checksum,
+		MigratedAt: time.Now().UTC(),
+	}); err != nil {
+		return 0, 0, err
+	}
+
+	if migrated && previous.TargetID != copied.ID {
+		// Файл изменился в источнике после прошлого переноса: прежняя копия устарела
+		m.discard(previous.TargetID)
+	}
+
+	m.logf("%s -> %s: %s (%s)", sourceID, copied.ID, metadata.OriginalName, formatSize(metadata.Size))
+	return fileMigrated, metadata.Size, nil
+}
+
+// copyFile передает содержимое файла из источника в приемник потоком, не сохраняя его на
+// диск. Источник сверяет контрольную сумму в конце потока, поэтому поврежденные данные
+// прерывают загрузку
+func (m *migrator) copyFile(ctx context.Context, metadata *chunking.FileMetadata) (*chunking.FileMetadata, error) {
+	body, err := m.source.OpenDownload(ctx, metadata.ID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось скачать файл из источника: %w", err)
+	}
+	defer body.Close()
+
+	opts := []client.TransferOption{client.WithPath(metadata.Path)}
+	if metadata.ContentType != "" {
+		opts = append(opts, client.WithContentType(metadata.ContentType))
+	}
+	if algorithmOf(metadata) == chunking.HashSHA256 {
+		// Приемник сверит содержимое с контрольной суммой источника до сохранения
+		opts = append(opts, client.WithContentSHA256(metadata.Checksum))
+	}
+
+	copied, err := m.target.UploadReader(ctx, metadata.OriginalName, body, metadata.Size, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить файл в приемник: %w", err)
+	}
+	return copied, nil
+}
+
+// verify сверяет копию в приемнике с файлом источника. При одинаковых алгоритмах
+// контрольных сумм достаточно метаданных копии, иначе и с fullVerify копия перечитывается
+// из приемника и хешируется алгоритмом источника
+func (m *migrator) verify(ctx context.Context, metadata, copied *chunking.FileMetadata) error {
+	if copied.Size != metadata.Size {
+		return fmt.Errorf("размер копии %d не совпадает с размером в источнике %d", copied.Size, metadata.Size)
+	}
+
+	sameAlgorithm := algorithmOf(copied) == algorithmOf(metadata)
+	if sameAlgorithm && copied.Checksum != metadata.Checksum {
+		return fmt.Errorf("контрольная сумма копии не совпадает с источником")
+	}
+	if sameAlgorithm && !m.fullVerify {
+		return nil
+	}
+
+	body, err := m.target.OpenDownload(ctx, copied.ID)
+	if err != nil {
+		return fmt.Errorf("не удалось перечитать копию: %w", err)
+	}
+	defer body.Close()
+
+	hasher, err := chunking.NewHasher(algorithmOf(metadata))
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(hasher, body)
+	if err != nil {
+		return fmt.Errorf("не удалось перечитать копию: %w", err)
+	}
+	if size != metadata.Size {
+		return fmt.Errorf("перечитано %d байт копии вместо %d", size, metadata.Size)
+	}
+	if checksum := fmt.Sprintf("%x", hasher.Sum(nil)); checksum != metadata.Checksum {
+		return fmt.Errorf("контрольная сумма копии не совпадает с источником")
+	}
+	return nil
+}
+
+// copyAccess переносит публичность файла и права, выданные владельцем. Владельцем копии
+// становится клиент ключа API приемника
+func (m *migrator) copyAccess(ctx context.Context, metadata *chunking.FileMetadata, targetID string) error {
+	if metadata.Public {
+		if _, err := m.target.SetFileVisibilityContext(ctx, targetID, true); err != nil {
+			return fmt.Errorf("не удалось открыть публичный доступ к копии: %w", err)
+		}
+	}
+
+	principals := make([]string, 0, len(metadata.Grants))
+	for principal := range metadata.Grants {
+		principals = append(principals, principal)
+	}
+	sort.Strings(principals)
+	for _, principal := range principals {
+		if _, err := m.target.GrantFileAccessContext(ctx, targetID, principal, metadata.Grants[principal]); err != nil {
+			return fmt.Errorf("не удалось выдать права %s на копию: %w", principal, err)
+		}
+	}
+	return nil
+}
+
+// discard удаляет копию из приемника; удаление не прерывается отменой переноса
+func (m *migrator) discard(targetID string) {
+	err := m.target.DeleteFileContext(context.Background(), targetID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		m.logf("Не удалось удалить копию %s из приемника: %v", targetID, err)
+	}
+}
+
+func (m *migrator) logf(format string, args ...interface{}) {
+	if m.log != nil {
+		fmt.Fprintf(m.log, format+"\n", args...)
+	}
+}
+
+// algorithmOf возвращает алгоритм контрольной суммы файла; пустой означает SHA256
+func algorithmOf(metadata *chunking.FileMetadata) chunking.HashAlgorithm {
+	if metadata.ChecksumAlgorithm == "" {
+		return chunking.HashSHA256
+	}
+	return metadata.ChecksumAlgorithm
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// migrationRecord - перенесенный файл в журнале переноса
+type migrationRecord struct {
+	SourceID   string    `json:"source_id"`
+	TargetID   string    `json:"target_id"`
+	Size       int64     `json:"size"`
+	Checksum   string    `json:"checksum"` // контрольная сумма файла в источнике на момент переноса
+	MigratedAt time.Time `json:"migrated_at"`
+}
+
+// migrationState - журнал переноса: по строке JSON на перенесенный файл. По нему повторный
+// запуск пропускает уже перенесенные файлы, а файлы, измененные в источнике, переносит заново
+type migrationState struct {
+	mutex sync.Mutex
+	file  *os.File // nil - журнал не ведется
+	done  map[string]migrationRecord
+}
+
+// openState читает журнал path и, если writable, открывает его для дописывания; пустой
+// path - без журнала. Оборванная последняя строка, например после аварийной остановки,
+// пропускается
+func openState(path string, writable bool) (*migrationState, error) {
+	state := &migrationState{done: make(map[string]migrationRecord)}
+	if path == "" {
+		return state, nil
+	}
+
+	existing, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("не удалось открыть журнал переноса: %w", err)
+	default:
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			var record migrationRecord
+			if json.Unmarshal(scanner.Bytes(), &record) != nil || record.SourceID == "" {
+				continue
+			}
+			state.done[record.SourceID] = record
+		}
+		err := scanner.Err()
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать журнал переноса: %w", err)
+		}
+	}
+
+	if !writable {
+		return state, nil
+	}
+	state.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал переноса: %w", err)
+	}
+	return state, nil
+}
+
+// migrated возвращает запись о ранее перенесенном файле источника
+func (s *migrationState) migrated(sourceID string) (migrationRecord, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record, ok := s.done[sourceID]
+	return record, ok
+}
+
+// record дописывает в журнал перенесенный файл и сбрасывает журнал на диск, чтобы после
+// аварийной остановки файл не переносился повторно
+func (s *migrationState) record(record migrationRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.done[record.SourceID] = record
+	if s.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("не удалось записать журнал переноса: %w", err)
+	}
+	return s.file.Sync()
+}
+
+// Close закрывает журнал
+func (s *migrationState) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,37 @@
+// Package apidocs содержит спецификацию OpenAPI и страницу Swagger UI для API
+package apidocs
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+)
+
+// Spec - спецификация OpenAPI 3 для маршрутов /api/v1 API сервера и серверов хранения.
+// При изменении обработчиков спецификацию нужно обновлять вместе с ними
+//
+//go:embed openapi.json
+var Spec []byte
+
+// swaggerUIVersion - версия Swagger UI, загружаемая страницей документации
+const swaggerUIVersion = "5.11.0"
+
+// SwaggerUIPage возвращает HTML страницу Swagger UI, отображающую спецификацию по адресу specURL
+func SwaggerUIPage(specURL string) []byte {
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+  <meta charset="utf-8">
+  <title>Distributed File Storage API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "%[2]s", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`, swaggerUIVersion, html.EscapeString(specURL)))
+}
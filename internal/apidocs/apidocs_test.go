@@ -0,0 +1,36 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecIsValidJSON(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(Spec, &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	// Идентификаторы операций используются генераторами клиентов и должны быть уникальны
+	operationIDs := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for method, raw := range item {
+			if method == "servers" || method == "parameters" {
+				continue
+			}
+
+			var operation struct {
+				OperationID string `json:"operationId"`
+			}
+			require.NoError(t, json.Unmarshal(raw, &operation), "%s %s", method, path)
+			require.NotEmpty(t, operation.OperationID, "%s %s", method, path)
+			assert.False(t, operationIDs[operation.OperationID], "повтор operationId %s", operation.OperationID)
+			operationIDs[operation.OperationID] = true
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package apierror содержит коды ошибок API и их сообщения на поддерживаемых языках.
+// Код ошибки не зависит от языка ответа, поэтому клиенты проверяют код, а не текст
+package apierror
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Code - машиночитаемый код ошибки в ответе API
+type Code string
+
+// Language - язык сообщений об ошибках
+type Language string
+
+const (
+	Russian Language = "ru"
+	English Language = "en"
+)
+
+// DefaultLanguage - язык сообщений, если клиент не указал поддерживаемый язык
+const DefaultLanguage = Russian
+
+// Negotiate выбирает язык сообщений по заголовку Accept-Language с учетом весов q.
+// Регион языка не учитывается: en-US и en-GB означают английский
+func Negotiate(acceptLanguage string) Language {
+	type candidate struct {
+		language Language
+		weight   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		language := Language(primary)
+		if primary == "*" {
+			language = DefaultLanguage
+		}
+		if language == Russian || language == English {
+			candidates = append(candidates, candidate{language: language, weight: weight})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+	// При равных весах побеждает язык, указанный раньше
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	return candidates[0].language
+}
+
+// Message возвращает сообщение об ошибке code на языке language, подставляя args
+// в шаблон сообщения. Для неизвестного кода возвращается сам код
+func Message(language Language, code Code, args ...interface{}) string {
+	texts, ok := messages[code]
+	if !ok {
+		return string(code)
+	}
+
+	format := texts.ru
+	if language == English {
+		format = texts.en
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
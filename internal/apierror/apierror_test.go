@@ -0,0 +1,67 @@
+package apierror
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]Language{
+		"":                       Russian,
+		"en":                     English,
+		"en-US,en;q=0.9":         English,
+		"ru-RU, en;q=0.8":        Russian,
+		"de, en;q=0.5, ru;q=0.4": English,
+		"ru;q=0.3, EN-GB;q=0.7":  English,
+		"en;q=0, ru":             Russian,
+		"fr, de":                 Russian,
+		"*":                      Russian,
+		"en;q=abc, ru;q=0.1":     Russian,
+	}
+	for header, expected := range cases {
+		assert.Equal(t, expected, Negotiate(header), "Accept-Language: %q", header)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "File not found", Message(English, FileNotFound))
+	assert.Equal(t, "Файл не найден", Message(Russian, FileNotFound))
+	assert.Equal(t, "The file size exceeds the maximum allowed (10 bytes)", Message(English, FileTooLarge, 10))
+	assert.Equal(t, "unknown_code", Message(English, "unknown_code"))
+}
+
+// Шаблоны одного кода на разных языках должны принимать одинаковые аргументы
+func TestMessagesHaveAllLanguages(t *testing.T) {
+	verb := regexp.MustCompile(`%[a-z]`)
+	for code, texts := range messages {
+		assert.NotEmpty(t, texts.ru, code)
+		assert.NotEmpty(t, texts.en, code)
+		assert.Equal(t, verb.FindAllString(texts.ru, -1), verb.FindAllString(texts.en, -1), code)
+	}
+}
+
+func TestNewEnvelope(t *testing.T) {
+	envelope := New(English, FileTooLarge, "req-1", 10).WithDetail("max_size", 10)
+	assert.Equal(t, FileTooLarge, envelope.Error.Code)
+	assert.Equal(t, "The file size exceeds the maximum allowed (10 bytes)", envelope.Error.Message)
+	assert.Equal(t, "req-1", envelope.Error.RequestID)
+	assert.Equal(t, map[string]interface{}{"max_size": 10}, envelope.Error.Details)
+
+	data, err := json.Marshal(New(Russian, FileNotFound, ""))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"error":{"code":"file_not_found","message":"Файл не найден"}}`, string(data))
+}
+
+func TestRequestID(t *testing.T) {
+	assert.Equal(t, "client-id-42", RequestID("client-id-42"))
+
+	for _, header := range []string{"", "bad id", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		generated := RequestID(header)
+		assert.NotEqual(t, header, generated)
+		assert.Len(t, generated, 36)
+	}
+}
@@ -0,0 +1,68 @@
+package apierror
+
+import (
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader - заголовок с идентификатором запроса. Идентификатор из запроса
+// клиента сохраняется, иначе сервер создает новый и возвращает его в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора, принятого от клиента
+const maxRequestIDLength = 128
+
+// Envelope - тело ответа с ошибкой
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+// Error описывает ошибку в ответе API
+type Error struct {
+	Code      Code                   `json:"code"`                 // код ошибки, не зависящий от языка
+	Message   string                 `json:"message"`              // сообщение на языке клиента
+	Details   map[string]interface{} `json:"details,omitempty"`    // дополнительные сведения, зависящие от кода
+	RequestID string                 `json:"request_id,omitempty"` // идентификатор запроса для поиска в журналах
+}
+
+// New возвращает тело ответа с ошибкой code и сообщением на языке language
+func New(language Language, code Code, requestID string, args ...interface{}) Envelope {
+	return Envelope{Error: Error{
+		Code:      code,
+		Message:   Message(language, code, args...),
+		RequestID: requestID,
+	}}
+}
+
+// WithDetail добавляет к ошибке дополнительное сведение key
+func (e Envelope) WithDetail(key string, value interface{}) Envelope {
+	details := make(map[string]interface{}, len(e.Error.Details)+1)
+	for k, v := range e.Error.Details {
+		details[k] = v
+	}
+	details[key] = value
+	e.Error.Details = details
+	return e
+}
+
+// RequestID возвращает идентификатор запроса: значение заголовка X-Request-ID, если
+// оно допустимо, или новый случайный идентификатор
+func RequestID(header string) string {
+	if validRequestID(header) {
+		return header
+	}
+	return uuid.New().String()
+}
+
+// validRequestID допускает непустой идентификатор ограниченной длины из видимых символов ASCII,
+// чтобы значение клиента нельзя было использовать для подделки строк журнала
+func validRequestID(value string) bool {
+	if value == "" || len(value) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] <= ' ' || value[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
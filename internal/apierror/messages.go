@@ -0,0 +1,315 @@
+package apierror
+
+// Общие ошибки запросов
+const (
+	InvalidRequest      Code = "invalid_request"
+	InternalError       Code = "internal_error"
+	RouteNotFound       Code = "route_not_found"
+	APIKeyRequired      Code = "api_key_required"
+	AccessDenied        Code = "access_denied"
+	AdminRequired       Code = "admin_required"
+	PreconditionFailed  Code = "precondition_failed"
+	RangeNotSatisfiable Code = "range_not_satisfiable"
+	InvalidChecksum     Code = "invalid_checksum"
+	InvalidContentType  Code = "invalid_content_type"
+	InvalidPublicField  Code = "invalid_public_field"
+)
+
+// Файлы и каталог метаданных
+const (
+	FileNotFound          Code = "file_not_found"
+	FilesNotFound         Code = "files_not_found"
+	CatalogUnavailable    Code = "catalog_unavailable"
+	MetadataConflict      Code = "metadata_conflict"
+	CatalogError          Code = "catalog_error"
+	FileMissing           Code = "file_missing"
+	FilenameRequired      Code = "filename_required"
+	FileReadFailed        Code = "file_read_failed"
+	UploadSpoolFailed     Code = "upload_spool_failed"
+	FileTooLarge          Code = "file_too_large"
+	ContentSHA256Mismatch Code = "content_sha256_mismatch"
+	InvalidChunkCount     Code = "invalid_chunk_count"
+	InvalidChunkSize      Code = "invalid_chunk_size"
+	InvalidReplication    Code = "invalid_replication_factor"
+	UnknownStorageClass   Code = "unknown_storage_class"
+	StoreFailed           Code = "store_failed"
+	ModifyFailed          Code = "modify_failed"
+	InsufficientStorage   Code = "insufficient_storage"
+	ContentTypeNotAllowed Code = "content_type_not_allowed"
+	FilenameRejected      Code = "filename_rejected"
+	FileInfected          Code = "file_infected"
+	ScanFailed            Code = "scan_failed"
+	AssembleFailed        Code = "assemble_failed"
+	VerifyFailed          Code = "verify_failed"
+	ChecksumMismatch      Code = "checksum_mismatch"
+	ChunkSizesMismatch    Code = "chunk_sizes_mismatch"
+	InvalidDelta          Code = "invalid_delta"
+	InvalidOffset         Code = "invalid_offset"
+	EmptyPatch            Code = "empty_patch"
+	DuplicateNotFound     Code = "duplicate_not_found"
+	ArchiveIDsRequired    Code = "archive_ids_required"
+	ArchiveFormat         Code = "archive_format_unsupported"
+	InvalidFilename       Code = "invalid_filename"
+	InvalidDisposition    Code = "invalid_disposition"
+	InvalidPreviewBytes   Code = "invalid_preview_bytes"
+	InvalidConsistency    Code = "invalid_consistency"
+	ConsistencyNotReached Code = "consistency_not_reached"
+	InvalidMerkleChunk    Code = "invalid_merkle_chunk"
+	InvalidMerkleRange    Code = "invalid_merkle_range"
+)
+
+// Права доступа к файлам
+const (
+	UnknownPermission  Code = "unknown_permission"
+	UnknownPrincipal   Code = "unknown_principal"
+	FileHasNoOwner     Code = "file_has_no_owner"
+	OwnerHasFullAccess Code = "owner_has_full_access"
+	TokenUploadPublic  Code = "token_upload_public"
+)
+
+// Загрузка по URL
+const (
+	FetchURLRequired   Code = "fetch_url_required"
+	FetchURLInvalid    Code = "fetch_url_invalid"
+	FetchURLForbidden  Code = "fetch_url_forbidden"
+	FetchRequestFailed Code = "fetch_request_failed"
+	FetchFailed        Code = "fetch_failed"
+	FetchBadStatus     Code = "fetch_bad_status"
+	FetchReadFailed    Code = "fetch_read_failed"
+)
+
+// Токены загрузки, ящики для приема файлов и прямая загрузка
+const (
+	UploadTokensDisabled   Code = "upload_tokens_disabled"
+	UploadTokenRejected    Code = "upload_token_rejected"
+	UploadTokenUsed        Code = "upload_token_used"
+	UploadTokenRequired    Code = "upload_token_required"
+	NegativeTTL            Code = "negative_ttl"
+	TokenTTLTooLong        Code = "token_ttl_too_long"
+	NegativeMaxSize        Code = "negative_max_size"
+	MaxSizeTooLarge        Code = "max_size_too_large"
+	DropBoxesDisabled      Code = "drop_boxes_disabled"
+	NegativeDropBoxTTL     Code = "negative_drop_box_ttl"
+	DropBoxTTLTooLong      Code = "drop_box_ttl_too_long"
+	NegativeDropBoxLimits  Code = "negative_drop_box_limits"
+	DropBoxRejected        Code = "drop_box_rejected"
+	DropBoxFull            Code = "drop_box_full"
+	DropBoxQuota           Code = "drop_box_quota_exceeded"
+	DirectUploadDisabled   Code = "direct_upload_disabled"
+	DirectUploadScanner    Code = "direct_upload_scanner"
+	DirectUploadTypes      Code = "direct_upload_content_types"
+	NegativeSize           Code = "negative_size"
+	PlaceFailed            Code = "place_failed"
+	PlanRejected           Code = "plan_rejected"
+	PlanFileMismatch       Code = "plan_file_mismatch"
+	ReceiptRejected        Code = "receipt_rejected"
+	UploadAlreadyCommitted Code = "upload_already_committed"
+	ChunkNotUploaded       Code = "chunk_not_uploaded"
+	ChunkPlanMismatch      Code = "chunk_plan_mismatch"
+)
+
+// Администрирование серверов хранения
+const (
+	NodeNotFound            Code = "node_not_found"
+	NodeAlreadyDraining     Code = "node_already_draining"
+	NodeState               Code = "node_state_conflict"
+	RepairInProgress        Code = "repair_in_progress"
+	RebalanceInProgress     Code = "rebalance_in_progress"
+	RebalanceNotRunning     Code = "rebalance_not_running"
+	NegativeRebalanceLimits Code = "negative_rebalance_limits"
+	InvalidTimeParameter    Code = "invalid_time_parameter"
+	InvalidLimit            Code = "invalid_limit"
+	InvalidPageOffset       Code = "invalid_page_offset"
+	InvalidMaxDownloads     Code = "invalid_max_downloads"
+	AuditQueryUnsupported   Code = "audit_query_unsupported"
+	AuditReadFailed         Code = "audit_read_failed"
+	CatalogReadFailed       Code = "catalog_read_failed"
+	InvalidExportFormat     Code = "invalid_export_format"
+	InvalidCatalogSnapshot  Code = "invalid_catalog_snapshot"
+)
+
+// Ошибки серверов хранения
+const (
+	ChunkNotFound          Code = "chunk_not_found"
+	ChunkCorrupted         Code = "chunk_corrupted"
+	InvalidChunk           Code = "invalid_chunk"
+	InvalidRefs            Code = "invalid_refs"
+	InvalidTarget          Code = "invalid_target"
+	ChunkStoreFailed       Code = "chunk_store_failed"
+	StorageFull            Code = "storage_full"
+	ChunkReadFailed        Code = "chunk_read_failed"
+	ChunkDeleteFailed      Code = "chunk_delete_failed"
+	ChunkLinkFailed        Code = "chunk_link_failed"
+	ChunkTransferFailed    Code = "chunk_transfer_failed"
+	ChunkCleanupFailed     Code = "chunk_cleanup_failed"
+	ChunkListFailed        Code = "chunk_list_failed"
+	StorageInfoFailed      Code = "storage_info_failed"
+	MemoryInfoFailed       Code = "memory_info_failed"
+	ChunkUploadDisabled    Code = "chunk_upload_disabled"
+	ChunkTokenMismatch     Code = "chunk_token_mismatch"
+	ChunkTooLarge          Code = "chunk_too_large"
+	ChunkBodyReadFailed    Code = "chunk_body_read_failed"
+	ChunkTokenSizeMismatch Code = "chunk_token_size_mismatch"
+	ReceiptFailed          Code = "receipt_failed"
+	SignatureRequired      Code = "signature_required"
+	SignatureRejected      Code = "signature_rejected"
+	ReadTokenRejected      Code = "read_token_rejected"
+	FaultInjected          Code = "fault_injected"
+	InvalidManifest        Code = "invalid_manifest"
+	ManifestStoreFailed    Code = "manifest_store_failed"
+	ManifestDeleteFailed   Code = "manifest_delete_failed"
+	MirrorDisabled         Code = "mirror_disabled"
+	UploadHeaderRequired   Code = "upload_header_required"
+	InvalidGraphQLRequest  Code = "invalid_graphql_request"
+)
+
+// texts - сообщение об ошибке на каждом поддерживаемом языке
+type texts struct {
+	ru string
+	en string
+}
+
+// messages - шаблоны сообщений в формате fmt. Шаблоны одного кода принимают одинаковые аргументы
+var messages = map[Code]texts{
+	InvalidRequest:      {"Неверный запрос: %v", "Invalid request: %v"},
+	InternalError:       {"Внутренняя ошибка: %v", "Internal error: %v"},
+	RouteNotFound:       {"Маршрут %s %s не найден", "Route %s %s not found"},
+	APIKeyRequired:      {"Требуется ключ API в заголовке Authorization: Bearer <ключ> или X-API-Key", "An API key is required in the Authorization: Bearer <key> or X-API-Key header"},
+	AccessDenied:        {"Недостаточно прав для операции с файлом", "Insufficient permissions for this file operation"},
+	AdminRequired:       {"Операция доступна только ключам из api_admins", "The operation is only available to keys listed in api_admins"},
+	PreconditionFailed:  {"Файл изменился: условие If-Match или If-None-Match не выполнено", "The file has changed: the If-Match or If-None-Match condition is not met"},
+	RangeNotSatisfiable: {"Запрошенный диапазон за пределами файла", "The requested range is outside the file"},
+	InvalidChecksum:     {"Неверная контрольная сумма: ожидается SHA256 из 64 шестнадцатеричных символов", "Invalid checksum: expected a SHA256 of 64 hexadecimal characters"},
+	InvalidContentType:  {"Неверный тип %q, ожидается тип/подтип или тип/*", "Invalid type %q, expected type/subtype or type/*"},
+	InvalidPublicField:  {"Неверное значение поля public: %q", "Invalid value of the public field: %q"},
+
+	FileNotFound:          {"Файл не найден", "File not found"},
+	FilesNotFound:         {"Файлы не найдены", "Files not found"},
+	CatalogUnavailable:    {"Каталог файлов временно недоступен: нет лидера кластера метаданных", "The file catalog is temporarily unavailable: the metadata cluster has no leader"},
+	MetadataConflict:      {"Метаданные файла одновременно изменены другим запросом, повторите запрос", "The file metadata was changed by another request at the same time, retry the request"},
+	CatalogError:          {"Не удалось обратиться к каталогу файлов: %v", "Failed to access the file catalog: %v"},
+	FileMissing:           {"Не удалось получить файл из запроса", "Failed to get the file from the request"},
+	FilenameRequired:      {"Имя файла должно быть указано в заголовке X-Filename", "The file name must be set in the X-Filename header"},
+	FileReadFailed:        {"Не удалось прочитать файл", "Failed to read the file"},
+	UploadSpoolFailed:     {"Не удалось сохранить загружаемый файл во временный каталог", "Failed to buffer the uploaded file in the temporary directory"},
+	FileTooLarge:          {"Размер файла превышает максимально допустимый (%d байт)", "The file size exceeds the maximum allowed (%d bytes)"},
+	ContentSHA256Mismatch: {"SHA256 полученных данных %s не совпадает с переданной клиентом %s", "The SHA256 of the received data %s does not match the one sent by the client %s"},
+	InvalidChunkCount:     {"Неверное число кусков %q, ожидается от 1 до %d", "Invalid chunk count %q, expected 1 to %d"},
+	InvalidChunkSize:      {"Неверный размер куска %q, ожидается положительное число байт", "Invalid chunk size %q, expected a positive number of bytes"},
+	InvalidReplication:    {"Неверное число копий %q, ожидается от 1 до %d", "Invalid replication factor %q, expected 1 to %d"},
+	UnknownStorageClass:   {"Неизвестный класс хранения %q", "Unknown storage class %q"},
+	StoreFailed:           {"Не удалось сохранить файл: %v", "Failed to store the file: %v"},
+	ModifyFailed:          {"Не удалось изменить файл: %v", "Failed to modify the file: %v"},
+	InsufficientStorage:   {"Не удалось сохранить файл: %v", "Failed to store the file: %v"},
+	ContentTypeNotAllowed: {"Не удалось сохранить файл: %v", "Failed to store the file: %v"},
+	FilenameRejected:      {"Не удалось сохранить файл: %v", "Failed to store the file: %v"},
+	FileInfected:          {"Не удалось сохранить файл: %v", "Failed to store the file: %v"},
+	ScanFailed:            {"Не удалось сохранить файл: %v", "Failed to store the file: %v"},
+	AssembleFailed:        {"Не удалось собрать файл: %v", "Failed to assemble the file: %v"},
+	VerifyFailed:          {"Не удалось проверить файл: %v", "Failed to verify the file: %v"},
+	ChecksumMismatch:      {"Контрольная сумма собранного файла не совпадает", "The checksum of the assembled file does not match"},
+	ChunkSizesMismatch:    {"Размер кусков не совпадает с метаданными файла", "The chunk sizes do not match the file metadata"},
+	InvalidDelta:          {"Неверное поле delta: %v", "Invalid delta field: %v"},
+	InvalidOffset:         {"Поле offset должно быть числом от 0 до размера файла (%d байт)", "The offset field must be a number from 0 to the file size (%d bytes)"},
+	EmptyPatch:            {"Нет данных для записи", "No data to write"},
+	DuplicateNotFound:     {"Файл с таким содержимым не найден, загрузите данные", "No file with this content was found, upload the data"},
+	ArchiveIDsRequired:    {"Неверный формат запроса: требуется непустой список ids", "Invalid request format: a non-empty ids list is required"},
+	ArchiveFormat:         {"Поддерживаются форматы архива zip и tar", "Supported archive formats are zip and tar"},
+	InvalidFilename:       {"Неверное имя файла в параметре filename: %q", "Invalid file name in the filename parameter: %q"},
+	InvalidDisposition:    {"Неверное значение параметра disposition: %q, ожидается inline или attachment", "Invalid value of the disposition parameter: %q, expected inline or attachment"},
+	InvalidPreviewBytes:   {"Параметр bytes должен быть числом от 1 до %d", "The bytes parameter must be a number from 1 to %d"},
+	InvalidConsistency:    {"Неверный уровень согласованности чтения %q, ожидается one, quorum или all", "Invalid read consistency level %q, expected one, quorum or all"},
+	ConsistencyNotReached: {"Копии кусков не согласованы: %v", "The chunk replicas do not agree: %v"},
+	InvalidMerkleChunk:    {"Параметр chunk должен быть номером куска от 0 до %d", "The chunk parameter must be a chunk number from 0 to %d"},
+	InvalidMerkleRange:    {"Параметры offset и length должны задавать диапазон внутри файла (%d байт)", "The offset and length parameters must specify a range within the file (%d bytes)"},
+
+	UnknownPermission:  {"Неизвестное право %q, ожидается read или read-write", "Unknown permission %q, expected read or read-write"},
+	UnknownPrincipal:   {"Неизвестный клиент API %s", "Unknown API client %s"},
+	FileHasNoOwner:     {"У файла нет владельца: он доступен всем клиентам API", "The file has no owner: it is available to all API clients"},
+	OwnerHasFullAccess: {"Владелец файла всегда имеет полный доступ", "The file owner always has full access"},
+	TokenUploadPublic:  {"Файл, загружаемый по токену загрузки, нельзя сделать публичным", "A file uploaded with an upload token cannot be made public"},
+
+	FetchURLRequired:   {"Неверный формат запроса: требуется поле url", "Invalid request format: the url field is required"},
+	FetchURLInvalid:    {"Поддерживаются только абсолютные http и https URL", "Only absolute http and https URLs are supported"},
+	FetchURLForbidden:  {"Скачивание с адреса %s запрещено", "Fetching from address %s is forbidden"},
+	FetchRequestFailed: {"Не удалось создать запрос: %v", "Failed to create the request: %v"},
+	FetchFailed:        {"Не удалось получить ресурс: %v", "Failed to fetch the resource: %v"},
+	FetchBadStatus:     {"Удаленный сервер вернул статус %d", "The remote server returned status %d"},
+	FetchReadFailed:    {"Не удалось прочитать ресурс: %v", "Failed to read the resource: %v"},
+
+	UploadTokensDisabled:   {"Токены загрузки отключены: не задан upload_token_secret", "Upload tokens are disabled: upload_token_secret is not set"},
+	UploadTokenRejected:    {"Токен загрузки не принят: %v", "The upload token was rejected: %v"},
+	UploadTokenUsed:        {"Токен загрузки уже использован", "The upload token has already been used"},
+	UploadTokenRequired:    {"Не указан токен загрузки", "No upload token was provided"},
+	NegativeTTL:            {"Срок действия токена не может быть отрицательным", "The token lifetime cannot be negative"},
+	TokenTTLTooLong:        {"Срок действия токена превышает upload_token_ttl (%s)", "The token lifetime exceeds upload_token_ttl (%s)"},
+	NegativeMaxSize:        {"Наибольший размер файла не может быть отрицательным", "The maximum file size cannot be negative"},
+	MaxSizeTooLarge:        {"Наибольший размер файла превышает max_file_size (%d байт)", "The maximum file size exceeds max_file_size (%d bytes)"},
+	DropBoxesDisabled:      {"Ящики для приема файлов отключены: не задан upload_token_secret", "Drop boxes are disabled: upload_token_secret is not set"},
+	NegativeDropBoxTTL:     {"Срок действия ящика не может быть отрицательным", "The drop box lifetime cannot be negative"},
+	DropBoxTTLTooLong:      {"Срок действия ящика превышает drop_box_max_ttl (%s)", "The drop box lifetime exceeds drop_box_max_ttl (%s)"},
+	NegativeDropBoxLimits:  {"Ограничения ящика не могут быть отрицательными", "Drop box limits cannot be negative"},
+	DropBoxRejected:        {"Ссылка на ящик не принята: %v", "The drop box link was rejected: %v"},
+	DropBoxFull:            {"Ящик для приема файлов заполнен", "The drop box is full"},
+	DropBoxQuota:           {"Не удалось принять файл: %v", "Failed to accept the file: %v"},
+	DirectUploadDisabled:   {"Прямая загрузка недоступна: не задан upload_token_secret", "Direct upload is unavailable: upload_token_secret is not set"},
+	DirectUploadScanner:    {"Прямая загрузка недоступна: файлы проверяются антивирусом на API сервере", "Direct upload is unavailable: files are scanned by the antivirus on the API server"},
+	DirectUploadTypes:      {"Прямая загрузка недоступна: тип содержимого файлов проверяется на API сервере", "Direct upload is unavailable: file content types are checked on the API server"},
+	NegativeSize:           {"Размер файла не может быть отрицательным", "The file size cannot be negative"},
+	PlaceFailed:            {"Не удалось разместить куски: %v", "Failed to place the chunks: %v"},
+	PlanRejected:           {"План загрузки не принят: %v", "The upload plan was rejected: %v"},
+	PlanFileMismatch:       {"План выдан для другого файла", "The plan was issued for another file"},
+	ReceiptRejected:        {"Квитанция сервера хранения не принята: %v", "The storage server receipt was rejected: %v"},
+	UploadAlreadyCommitted: {"Загрузка уже завершена", "The upload has already been completed"},
+	ChunkNotUploaded:       {"Кусок %d не загружен: нет квитанции сервера хранения", "Chunk %d is not uploaded: there is no storage server receipt"},
+	ChunkPlanMismatch:      {"Размер куска %d не совпадает с планом", "The size of chunk %d does not match the plan"},
+
+	NodeNotFound:            {"Сервер хранения не найден", "Storage server not found"},
+	NodeAlreadyDraining:     {"Сервер хранения уже выводится или выведен", "The storage server is already draining or drained"},
+	NodeState:               {"Сервер хранения находится в состоянии %s", "The storage server is in state %s"},
+	RepairInProgress:        {"Восстановление копий уже выполняется", "Replica repair is already running"},
+	RebalanceInProgress:     {"Выравнивание уже выполняется", "Rebalancing is already running"},
+	RebalanceNotRunning:     {"Выравнивание не выполняется", "Rebalancing is not running"},
+	NegativeRebalanceLimits: {"Ограничения выравнивания не могут быть отрицательными", "Rebalancing limits cannot be negative"},
+	InvalidTimeParameter:    {"Неверный формат параметра %s: ожидается RFC 3339", "Invalid format of parameter %s: RFC 3339 expected"},
+	InvalidLimit:            {"Неверное значение параметра limit", "Invalid value of the limit parameter"},
+	InvalidPageOffset:       {"Неверное значение параметра offset", "Invalid value of the offset parameter"},
+	InvalidMaxDownloads:     {"Неверное значение параметра max_downloads", "Invalid value of the max_downloads parameter"},
+	AuditQueryUnsupported:   {"Журнал аудита не поддерживает запросы: включите приемник file", "The audit log does not support queries: enable the file sink"},
+	AuditReadFailed:         {"Не удалось прочитать журнал аудита: %v", "Failed to read the audit log: %v"},
+	CatalogReadFailed:       {"Не удалось прочитать каталог", "Failed to read the catalog"},
+	InvalidExportFormat:     {"Неверное значение параметра format: %q, ожидается ndjson, json, protobuf или msgpack", "Invalid value of the format parameter: %q, expected ndjson, json, protobuf or msgpack"},
+	InvalidCatalogSnapshot:  {"Неверный снимок каталога: %v", "Invalid catalog snapshot: %v"},
+
+	ChunkNotFound:          {"Кусок не найден", "Chunk not found"},
+	ChunkCorrupted:         {"Кусок поврежден: %v", "The chunk is corrupted: %v"},
+	InvalidChunk:           {"Неверный формат данных куска", "Invalid chunk data format"},
+	InvalidRefs:            {"Параметр refs должен быть положительным числом", "The refs parameter must be a positive number"},
+	InvalidTarget:          {"Параметр target должен быть адресом сервера хранения вида host:port", "The target parameter must be a storage server address of the form host:port"},
+	ChunkStoreFailed:       {"Не удалось сохранить кусок: %v", "Failed to store the chunk: %v"},
+	StorageFull:            {"Кусок не помещается на сервер хранения: %v", "The chunk does not fit on the storage server: %v"},
+	ChunkReadFailed:        {"Не удалось получить кусок: %v", "Failed to get the chunk: %v"},
+	ChunkDeleteFailed:      {"Не удалось удалить кусок: %v", "Failed to delete the chunk: %v"},
+	ChunkLinkFailed:        {"Не удалось добавить ссылку на кусок: %v", "Failed to add a reference to the chunk: %v"},
+	ChunkTransferFailed:    {"Не удалось передать кусок на сервер %s: %v", "Failed to transfer the chunk to server %s: %v"},
+	ChunkCleanupFailed:     {"Кусок передан, но не удален с сервера: %v", "The chunk was transferred but not deleted from the server: %v"},
+	ChunkListFailed:        {"Не удалось получить список кусков: %v", "Failed to list chunks: %v"},
+	StorageInfoFailed:      {"Не удалось получить информацию о хранилище: %v", "Failed to get storage information: %v"},
+	MemoryInfoFailed:       {"Не удалось получить информацию о памяти: %v", "Failed to get memory information: %v"},
+	ChunkUploadDisabled:    {"Прямая загрузка кусков отключена: не задан upload_token_secret", "Direct chunk upload is disabled: upload_token_secret is not set"},
+	ChunkTokenMismatch:     {"Токен выдан для другого куска", "The token was issued for another chunk"},
+	ChunkTooLarge:          {"Размер куска превышает указанный в токене (%d байт)", "The chunk size exceeds the size in the token (%d bytes)"},
+	ChunkBodyReadFailed:    {"Не удалось прочитать данные куска: %v", "Failed to read the chunk data: %v"},
+	ChunkTokenSizeMismatch: {"Размер куска %d байт не совпадает с указанным в токене (%d байт)", "The chunk size of %d bytes does not match the size in the token (%d bytes)"},
+	ReceiptFailed:          {"Не удалось выдать квитанцию: %v", "Failed to issue a receipt: %v"},
+	SignatureRequired:      {"Запрос к серверу хранения должен быть подписан общим ключом", "Requests to the storage server must be signed with the shared key"},
+	SignatureRejected:      {"Подпись запроса не принята: %v", "The request signature was rejected: %v"},
+	ReadTokenRejected:      {"Токен чтения куска не принят: %v", "The chunk read token was rejected: %v"},
+	FaultInjected:          {"Сбой внесен настройкой fault_error_rate", "The failure was injected by the fault_error_rate setting"},
+	InvalidManifest:        {"Неверный манифест файла: %v", "Invalid file manifest: %v"},
+	ManifestStoreFailed:    {"Не удалось сохранить манифест файла: %v", "Failed to store the file manifest: %v"},
+	ManifestDeleteFailed:   {"Не удалось удалить манифест файла: %v", "Failed to delete the file manifest: %v"},
+	MirrorDisabled:         {"Зеркалирование отключено: не задан mirror_url", "Mirroring is disabled: mirror_url is not set"},
+	UploadHeaderRequired:   {"Загрузка файла должна начинаться с заголовка с именем файла", "A file upload must start with a header carrying the file name"},
+	InvalidGraphQLRequest:  {"Неверный запрос GraphQL: ожидается JSON с непустым полем query", "Invalid GraphQL request: expected JSON with a non-empty query field"},
+}
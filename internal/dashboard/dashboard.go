@@ -0,0 +1,22 @@
+// Package dashboard содержит встроенную веб-панель администратора API сервера
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// static - страница панели, скрипт и стили. Данные панель получает из API /api/v1
+// с ключом API, который вводит пользователь
+//
+//go:embed static
+var static embed.FS
+
+// Assets возвращает файлы панели: index.html, app.js и style.css
+func Assets() fs.FS {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return assets
+}
@@ -0,0 +1,23 @@
+package dashboard
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssets(t *testing.T) {
+	for _, name := range []string{"index.html", "app.js", "style.css"} {
+		data, err := fs.ReadFile(Assets(), name)
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, data, name)
+	}
+
+	// Страница подключает скрипт и стили относительными путями, чтобы работать под /dashboard/
+	page, err := fs.ReadFile(Assets(), "index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `src="app.js"`)
+	assert.Contains(t, string(page), `href="style.css"`)
+}
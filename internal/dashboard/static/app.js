@@ -0,0 +1,286 @@
+// Панель администратора: читает состояние кластера и файлы через API /api/v1.
+// Ключ API запрашивается при первом ответе 401 и хранится до закрытия вкладки
+"use strict";
+
+const api = new URL("../api/v1/", window.location.href);
+const keyStorage = "dashboard-api-key";
+const pageSize = 50;
+const recentCount = 10;
+
+let offset = 0;
+let prefix = "";
+
+// request выполняет запрос к API с ключом из sessionStorage. При 401 запрашивает ключ
+// и повторяет запрос
+async function request(path, options = {}) {
+  const headers = new Headers(options.headers || {});
+  const key = sessionStorage.getItem(keyStorage);
+  if (key) {
+    headers.set("X-API-Key", key);
+  }
+  const response = await fetch(new URL(path, api), { ...options, headers });
+  if (response.status === 401) {
+    // Параллельные запросы получают 401 одновременно: ключ спрашивается один раз, а
+    // остальные повторяются с уже введенным
+    if (sessionStorage.getItem(keyStorage) !== key) {
+      return request(path, options);
+    }
+    const entered = window.prompt("Ключ API");
+    if (entered) {
+      sessionStorage.setItem(keyStorage, entered);
+      return request(path, options);
+    }
+  }
+  if (!response.ok) {
+    let message = response.status + " " + response.statusText;
+    try {
+      const body = await response.json();
+      if (body.error && body.error.message) {
+        message = body.error.message;
+      }
+    } catch (e) {
+      // тело ответа не JSON
+    }
+    throw new Error(path + ": " + message);
+  }
+  return response;
+}
+
+async function requestJSON(path, options) {
+  const response = await request(path, options);
+  return response.json();
+}
+
+function element(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined && text !== null) {
+    node.textContent = text;
+  }
+  if (className) {
+    node.className = className;
+  }
+  return node;
+}
+
+function row(cells) {
+  const tr = document.createElement("tr");
+  for (const cell of cells) {
+    const td = document.createElement("td");
+    if (cell instanceof Node) {
+      td.appendChild(cell);
+    } else {
+      td.textContent = cell === undefined || cell === null ? "" : cell;
+    }
+    tr.appendChild(td);
+  }
+  return tr;
+}
+
+function formatBytes(bytes) {
+  if (bytes === undefined || bytes === null) {
+    return "-";
+  }
+  const units = ["Б", "КБ", "МБ", "ГБ", "ТБ"];
+  let value = bytes;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return (unit === 0 ? value : value.toFixed(1)) + " " + units[unit];
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "-";
+}
+
+function showError(err) {
+  const box = document.getElementById("error");
+  box.textContent = err ? err.message : "";
+  box.hidden = !err;
+}
+
+function utilizationBar(utilization) {
+  if (utilization === undefined || utilization === null) {
+    return element("span", "-");
+  }
+  const bar = element("div", null, "bar");
+  const fill = element("div", null, utilization > 0.9 ? "fill high" : "fill");
+  fill.style.width = Math.round(utilization * 100) + "%";
+  bar.appendChild(fill);
+  bar.title = (utilization * 100).toFixed(1) + "%";
+  return bar;
+}
+
+async function loadNodes() {
+  const [stats, nodes] = await Promise.all([requestJSON("admin/stats"), requestJSON("admin/nodes")]);
+  const regions = {};
+  for (const node of nodes.nodes) {
+    regions[node.node] = node.region;
+  }
+
+  document.getElementById("summary").textContent =
+    "Файлов: " + stats.files +
+    ", данных: " + formatBytes(stats.logical_bytes) +
+    ", на серверах: " + formatBytes(stats.physical_bytes) +
+    ", дедупликация: " + stats.dedup_ratio.toFixed(2);
+
+  const body = document.getElementById("nodes");
+  body.replaceChildren();
+  for (const node of stats.nodes) {
+    const state = element("span", node.error ? "недоступен" : node.state, "state " + (node.error ? "failed" : node.state));
+    if (node.error) {
+      state.title = node.error;
+    }
+    body.appendChild(row([
+      node.node,
+      regions[node.node] || "",
+      state,
+      node.chunks,
+      formatBytes(node.used_bytes),
+      formatBytes(node.free_bytes),
+      utilizationBar(node.utilization),
+    ]));
+  }
+}
+
+async function loadRepair() {
+  const status = await requestJSON("admin/repair");
+  const box = document.getElementById("repair");
+  box.replaceChildren();
+
+  const last = status.last;
+  if (!last) {
+    box.appendChild(element("p", "Проходов восстановления еще не было"));
+    return;
+  }
+  const state = status.running ? "выполняется" : "завершен " + formatTime(last.finished_at);
+  box.appendChild(element("p",
+    "Проход начат " + formatTime(last.started_at) + ", " + state +
+    ". Проверено кусков: " + last.checked_chunks +
+    ", без нужного числа копий: " + last.under_replicated +
+    ", восстановлено: " + last.repaired_chunks +
+    ", не удалось: " + last.failed_chunks +
+    ", потеряно: " + last.lost_chunks));
+
+  if (last.dead_nodes && last.dead_nodes.length > 0) {
+    box.appendChild(element("p", "Не ответили: " + last.dead_nodes.join(", "), "warning"));
+  }
+  if (last.errors && last.errors.length > 0) {
+    const list = element("ul", null, "errors");
+    for (const message of last.errors) {
+      list.appendChild(element("li", message));
+    }
+    box.appendChild(list);
+  }
+}
+
+function downloadButton(file) {
+  const button = element("button", "Скачать");
+  button.type = "button";
+  button.addEventListener("click", async () => {
+    try {
+      const response = await request("files/" + encodeURIComponent(file.id));
+      const link = document.createElement("a");
+      link.href = URL.createObjectURL(await response.blob());
+      link.download = file.original_name;
+      link.click();
+      URL.revokeObjectURL(link.href);
+      showError(null);
+    } catch (err) {
+      showError(err);
+    }
+  });
+  return button;
+}
+
+function filesQuery(params) {
+  const query = new URLSearchParams(params);
+  return "dashboard/files?" + query.toString();
+}
+
+async function loadRecent() {
+  const page = await requestJSON(filesQuery({ limit: recentCount }));
+  const body = document.getElementById("recent");
+  body.replaceChildren();
+  for (const file of page.files) {
+    body.appendChild(row([file.original_name, file.path, formatBytes(file.size), formatTime(file.created_at), downloadButton(file)]));
+  }
+}
+
+async function loadFiles() {
+  const params = { limit: pageSize, offset: offset };
+  if (prefix) {
+    params.prefix = prefix;
+  }
+  const page = await requestJSON(filesQuery(params));
+  const body = document.getElementById("files");
+  body.replaceChildren();
+  for (const file of page.files) {
+    body.appendChild(row([
+      file.original_name,
+      file.path,
+      formatBytes(file.size),
+      formatTime(file.created_at),
+      file.public ? "публичный" : file.owner || "",
+      downloadButton(file),
+    ]));
+  }
+
+  const last = Math.min(offset + page.files.length, page.total_count);
+  document.getElementById("page").textContent = page.total_count === 0 ? "Файлов нет" : (offset + 1) + "-" + last + " из " + page.total_count;
+  document.getElementById("prev").disabled = offset === 0;
+  document.getElementById("next").disabled = last >= page.total_count;
+}
+
+async function refresh() {
+  try {
+    await Promise.all([loadNodes(), loadRepair(), loadRecent(), loadFiles()]);
+    document.getElementById("updated").textContent = "Обновлено " + new Date().toLocaleTimeString();
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+document.getElementById("refresh").addEventListener("click", refresh);
+
+document.getElementById("logout").addEventListener("click", () => {
+  sessionStorage.removeItem(keyStorage);
+  refresh();
+});
+
+document.getElementById("browse").addEventListener("submit", (event) => {
+  event.preventDefault();
+  prefix = event.target.elements.prefix.value.trim();
+  offset = 0;
+  loadFiles().catch(showError);
+});
+
+document.getElementById("prev").addEventListener("click", () => {
+  offset = Math.max(offset - pageSize, 0);
+  loadFiles().catch(showError);
+});
+
+document.getElementById("next").addEventListener("click", () => {
+  offset += pageSize;
+  loadFiles().catch(showError);
+});
+
+document.getElementById("upload").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const form = event.target;
+  const button = form.querySelector("button");
+  button.disabled = true;
+  try {
+    await request("files", { method: "POST", body: new FormData(form) });
+    form.reset();
+    await refresh();
+  } catch (err) {
+    showError(err);
+  } finally {
+    button.disabled = false;
+  }
+});
+
+refresh();
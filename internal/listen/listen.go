@@ -0,0 +1,51 @@
+// Package listen открывает сокет, на котором сервер принимает запросы: TCP адрес
+// или unix сокет для работы за локальным обратным прокси
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixScheme - префикс адреса unix сокета
+const unixScheme = "unix://"
+
+// Listen начинает принимать соединения по адресу host:port или unix:///path. Файл
+// сокета, оставшийся после аварийной остановки прежнего процесса, удаляется; при
+// закрытии слушателя файл удаляется автоматически
+func Listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixScheme)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// removeStaleSocket удаляет файл сокета, который никто не слушает. Занятый сокет и
+// файлы других типов не трогает, чтобы не отнять адрес у работающего сервера
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("файл %s существует и не является сокетом", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("сокет %s уже используется другим процессом", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,216 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+)
+
+// accessFlushTimeout ограничивает запись накопленной статистики при остановке сервера
+const accessFlushTimeout = 10 * time.Second
+
+// accessDelta - обращения к файлу, еще не записанные в каталог
+type accessDelta struct {
+	downloads int64
+	bytes     int64
+	last      time.Time
+}
+
+// applyTo добавляет обращения к статистике файла
+func (d *accessDelta) applyTo(metadata *chunking.FileMetadata) {
+	metadata.DownloadCount += d.downloads
+	metadata.BytesServed += d.bytes
+	if metadata.LastAccessedAt == nil || d.last.After(*metadata.LastAccessedAt) {
+		last := d.last
+		metadata.LastAccessedAt = &last
+	}
+}
+
+// accessTracker накапливает обращения к файлам в памяти и записывает их в каталог
+// раз в interval, чтобы каждое скачивание не требовало записи метаданных
+type accessTracker struct {
+	interval time.Duration // 0 - запись при каждом обращении
+
+	mutex   sync.Mutex
+	pending map[string]*accessDelta
+
+	flushMutex sync.Mutex // запись в каталог выполняется последовательно
+	done       chan struct{}
+}
+
+// newAccessTracker создает накопитель статистики обращений
+func newAccessTracker(interval time.Duration) *accessTracker {
+	return &accessTracker{
+		interval: interval,
+		pending:  make(map[string]*accessDelta),
+		done:     make(chan struct{}),
+	}
+}
+
+// add учитывает обращение к файлу; download - файл скачивается целиком или с начала
+func (t *accessTracker) add(fileID string, download bool, bytes int64, at time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delta := t.pending[fileID]
+	if delta == nil {
+		delta = &accessDelta{}
+		t.pending[fileID] = delta
+	}
+	if download {
+		delta.downloads++
+	}
+	delta.bytes += bytes
+	if at.After(delta.last) {
+		delta.last = at
+	}
+}
+
+// take забирает накопленные обращения для записи в каталог
+func (t *accessTracker) take() map[string]*accessDelta {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	pending := t.pending
+	t.pending = make(map[string]*accessDelta)
+	return pending
+}
+
+// restore возвращает обращения, которые не удалось записать, для следующей попытки
+func (t *accessTracker) restore(fileID string, delta *accessDelta) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if current := t.pending[fileID]; current != nil {
+		current.downloads += delta.downloads
+		current.bytes += delta.bytes
+		if delta.last.After(current.last) {
+			current.last = delta.last
+		}
+		return
+	}
+	t.pending[fileID] = delta
+}
+
+// merged возвращает метаданные с учетом еще не записанных обращений.
+// Сохраненные метаданные не изменяются: при наличии обращений возвращается копия
+func (t *accessTracker) merged(metadata *chunking.FileMetadata) *chunking.FileMetadata {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delta := t.pending[metadata.ID]
+	if delta == nil {
+		return metadata
+	}
+	updated := *metadata
+	delta.applyTo(&updated)
+	return &updated
+}
+
+// recordAccess учитывает скачивание файла или его части
+func (s *StreamingAPIServer) recordAccess(fileID string, download bool, bytes int64) {
+	s.access.add(fileID, download, bytes, time.Now().UTC())
+	if s.access.interval == 0 {
+		s.flushAccess(context.Background())
+	}
+}
+
+// flushAccess записывает накопленные обращения в метаданные файлов
+func (s *StreamingAPIServer) flushAccess(ctx context.Context) {
+	s.access.flushMutex.Lock()
+	defer s.access.flushMutex.Unlock()
+
+	for fileID, delta := range s.access.take() {
+		_, err := s.updateFile(ctx, fileID, func(metadata *chunking.FileMetadata) error {
+			delta.applyTo(metadata)
+			return nil
+		})
+		if errors.Is(err, catalog.ErrNotFound) {
+			continue // файл удален
+		}
+		if err != nil {
+			log.Printf("Не удалось записать статистику обращений к файлу %s: %v", fileID, err)
+			s.access.restore(fileID, delta)
+		}
+	}
+}
+
+// runAccessFlush записывает статистику обращений раз в interval, а при остановке
+// сервера - в последний раз
+func (s *StreamingAPIServer) runAccessFlush() {
+	defer close(s.access.done)
+
+	var tick <-chan time.Time
+	if s.access.interval > 0 {
+		ticker := time.NewTicker(s.access.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			s.flushAccess(context.Background())
+		case <-s.shutdown:
+			ctx, cancel := context.WithTimeout(context.Background(), accessFlushTimeout)
+			s.flushAccess(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// accessFilter - условия отбора файлов по статистике обращений
+type accessFilter struct {
+	before       time.Time // последнее обращение (или загрузка) раньше этого момента
+	after        time.Time // последнее обращение (или загрузка) позже этого момента
+	maxDownloads int64     // -1 - без ограничения
+}
+
+// parseAccessFilter читает параметры accessed_before, accessed_after (RFC 3339) и max_downloads
+func parseAccessFilter(c *gin.Context) (accessFilter, *requestError) {
+	filter := accessFilter{maxDownloads: -1}
+
+	for param, target := range map[string]*time.Time{"accessed_before": &filter.before, "accessed_after": &filter.after} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, newRequestError(http.StatusBadRequest, apierror.InvalidTimeParameter, param)
+			}
+			*target = parsed
+		}
+	}
+
+	if value := c.Query("max_downloads"); value != "" {
+		maxDownloads, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxDownloads < 0 {
+			return filter, newRequestError(http.StatusBadRequest, apierror.InvalidMaxDownloads)
+		}
+		filter.maxDownloads = maxDownloads
+	}
+
+	return filter, nil
+}
+
+// matches сообщает, подходит ли файл под условия. Для нескачанного файла
+// временем последнего обращения считается время загрузки
+func (f accessFilter) matches(metadata *chunking.FileMetadata) bool {
+	last := metadata.LastActivity()
+	if !f.before.IsZero() && !last.Before(f.before) {
+		return false
+	}
+	if !f.after.IsZero() && !last.After(f.after) {
+		return false
+	}
+	return f.maxDownloads < 0 || metadata.DownloadCount <= f.maxDownloads
+}
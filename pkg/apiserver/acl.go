@@ -0,0 +1,168 @@
+package apiserver
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// errAccessDenied возвращается, если клиенту не разрешена операция с файлом
+var errAccessDenied = errors.New("нет доступа к файлу")
+
+// fileAccess - уровень доступа, необходимый для операции с файлом
+type fileAccess int
+
+const (
+	accessRead  fileAccess = iota // скачивание и просмотр метаданных
+	accessWrite                   // удаление и изменение видимости
+	accessOwner                   // управление правами доступа
+)
+
+// grantRequest - выдача права доступа к файлу
+type grantRequest struct {
+	Permission chunking.Permission `json:"permission" binding:"required"`
+}
+
+// aclResponse - владелец файла и выданные права доступа
+type aclResponse struct {
+	ID     string                         `json:"id"`
+	Owner  string                         `json:"owner,omitempty"`
+	Grants map[string]chunking.Permission `json:"grants"`
+}
+
+// canAccess сообщает, разрешена ли клиенту principal операция с файлом. Права не
+// проверяются без ключей API и для файлов без владельца, загруженных без ключа. С
+// ключами пустой principal - анонимный клиент, которому доступны только файлы без
+// владельца
+func (r *runtimeSettings) canAccess(principal string, metadata *chunking.FileMetadata, access fileAccess) bool {
+	if len(r.apiKeys) == 0 || metadata.Owner == "" {
+		return true
+	}
+	if principal == "" {
+		return false
+	}
+	if principal == metadata.Owner {
+		return true
+	}
+	switch metadata.Grants[principal] {
+	case chunking.PermissionReadWrite:
+		return access <= accessWrite
+	case chunking.PermissionRead:
+		return access == accessRead
+	}
+	return false
+}
+
+// loadFile читает метаданные файла из параметра id и проверяет доступ к нему.
+// Файл, который клиент не может читать, для него не отличается от несуществующего.
+// При ошибке ответ уже отправлен
+func (s *StreamingAPIServer) loadFile(c *gin.Context, access fileAccess) (*chunking.FileMetadata, bool) {
+	metadata, err := s.catalog.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeCatalogError(c, err)
+		return nil, false
+	}
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	if !settings.canAccess(principal, metadata, accessRead) {
+		writeError(c, http.StatusNotFound, apierror.FileNotFound)
+		return nil, false
+	}
+	if !settings.canAccess(principal, metadata, access) {
+		writeError(c, http.StatusForbidden, apierror.AccessDenied)
+		return nil, false
+	}
+	return metadata, true
+}
+
+// getFileACL возвращает владельца файла и выданные права доступа
+func (s *StreamingAPIServer) getFileACL(c *gin.Context) {
+	metadata, ok := s.loadFile(c, accessOwner)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newACLResponse(metadata))
+}
+
+// grantFileAccess выдает клиенту API право на чтение или чтение и запись файла
+func (s *StreamingAPIServer) grantFileAccess(c *gin.Context) {
+	var request grantRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+	if !request.Permission.Valid() {
+		writeError(c, http.StatusBadRequest, apierror.UnknownPermission, request.Permission)
+		return
+	}
+
+	principal := c.Param("principal")
+	if !slices.ContainsFunc(s.current().apiKeys, func(key apiKey) bool { return key.name == principal }) {
+		writeError(c, http.StatusBadRequest, apierror.UnknownPrincipal, principal)
+		return
+	}
+
+	s.updateGrants(c, func(metadata *chunking.FileMetadata) {
+		if metadata.Grants == nil {
+			metadata.Grants = make(map[string]chunking.Permission)
+		}
+		metadata.Grants[principal] = request.Permission
+	})
+}
+
+// revokeFileAccess отзывает право доступа клиента API к файлу
+func (s *StreamingAPIServer) revokeFileAccess(c *gin.Context) {
+	principal := c.Param("principal")
+	s.updateGrants(c, func(metadata *chunking.FileMetadata) {
+		delete(metadata.Grants, principal)
+		if len(metadata.Grants) == 0 {
+			metadata.Grants = nil
+		}
+	})
+}
+
+// updateGrants изменяет права доступа к файлу от имени его владельца
+func (s *StreamingAPIServer) updateGrants(c *gin.Context, change func(metadata *chunking.FileMetadata)) {
+	metadata, ok := s.loadFile(c, accessOwner)
+	if !ok {
+		return
+	}
+	if metadata.Owner == "" {
+		writeError(c, http.StatusConflict, apierror.FileHasNoOwner)
+		return
+	}
+	if c.Param("principal") == metadata.Owner {
+		writeError(c, http.StatusBadRequest, apierror.OwnerHasFullAccess)
+		return
+	}
+
+	updated, err := s.updateFile(c.Request.Context(), metadata.ID, func(updated *chunking.FileMetadata) error {
+		updated.Grants = maps.Clone(updated.Grants)
+		change(updated)
+		return nil
+	})
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+	recordAuditFile(c.Request.Context(), updated)
+
+	c.JSON(http.StatusOK, newACLResponse(updated))
+}
+
+// newACLResponse описывает права доступа к файлу
+func newACLResponse(metadata *chunking.FileMetadata) aclResponse {
+	grants := metadata.Grants
+	if grants == nil {
+		grants = map[string]chunking.Permission{}
+	}
+	return aclResponse{ID: metadata.ID, Owner: metadata.Owner, Grants: grants}
+}
@@ -0,0 +1,185 @@
+package apiserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+)
+
+// archiveRequest описывает запрос на скачивание нескольких файлов одним архивом
+type archiveRequest struct {
+	IDs    []string `json:"ids" binding:"required"`
+	Format string   `json:"format"` // zip (по умолчанию) или tar
+}
+
+// archiveWriter абстрагирует формат архива
+type archiveWriter interface {
+	// Add начинает новую запись архива и возвращает writer для ее содержимого
+	Add(name string, size int64, modified time.Time) (io.Writer, error)
+	Close() error
+}
+
+// zipArchiveWriter пишет ZIP архив без сжатия, чтобы не ограничивать скорость отдачи
+type zipArchiveWriter struct {
+	writer *zip.Writer
+}
+
+func (z *zipArchiveWriter) Add(name string, size int64, modified time.Time) (io.Writer, error) {
+	return z.writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: modified,
+	})
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.writer.Close()
+}
+
+// tarArchiveWriter пишет TAR архив
+type tarArchiveWriter struct {
+	writer *tar.Writer
+}
+
+func (t *tarArchiveWriter) Add(name string, size int64, modified time.Time) (io.Writer, error) {
+	err := t.writer.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modified,
+		Typeflag: tar.TypeReg,
+	})
+	return t.writer, err
+}
+
+func (t *tarArchiveWriter) Close() error {
+	return t.writer.Close()
+}
+
+// downloadArchive отдает несколько файлов одним архивом, собирая его на лету из кусков
+func (s *StreamingAPIServer) downloadArchive(c *gin.Context) {
+	var req archiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) == 0 {
+		writeError(c, http.StatusBadRequest, apierror.ArchiveIDsRequired)
+		return
+	}
+
+	if req.Format == "" {
+		req.Format = "zip"
+	}
+	if req.Format != "zip" && req.Format != "tar" {
+		writeError(c, http.StatusBadRequest, apierror.ArchiveFormat)
+		return
+	}
+
+	// Проверяем наличие всех файлов до начала отправки архива
+	files := make([]*chunking.FileMetadata, 0, len(req.IDs))
+	var missing []string
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	for _, id := range req.IDs {
+		metadata, err := s.catalog.Get(c.Request.Context(), id)
+		switch {
+		case err == nil && settings.canAccess(principal, metadata, accessRead):
+			files = append(files, metadata)
+		case err == nil, errors.Is(err, catalog.ErrNotFound):
+			// Недоступный клиенту файл не отличается от несуществующего
+			missing = append(missing, id)
+		default:
+			writeCatalogError(c, err)
+			return
+		}
+	}
+
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, errorBody(c, apierror.FilesNotFound).WithDetail("missing", missing))
+		return
+	}
+
+	var archive archiveWriter
+	if req.Format == "zip" {
+		c.Header("Content-Type", "application/zip")
+		archive = &zipArchiveWriter{writer: zip.NewWriter(c.Writer)}
+	} else {
+		c.Header("Content-Type", "application/x-tar")
+		archive = &tarArchiveWriter{writer: tar.NewWriter(c.Writer)}
+	}
+	c.Header("Content-Disposition", contentDisposition(dispositionAttachment, "files."+req.Format))
+	c.Status(http.StatusOK)
+
+	usedNames := make(map[string]bool, len(files))
+	for _, metadata := range files {
+		name := archiveEntryName(metadata, usedNames)
+
+		// Ответ уже частично отправлен, поэтому при ошибке можем только оборвать соединение
+		entry, err := archive.Add(name, metadata.Size, metadata.CreatedAt)
+		if err != nil {
+			log.Printf("Не удалось добавить файл %s в архив: %v", metadata.ID, err)
+			abortResponse(c)
+			return
+		}
+		if err := s.writeFileContent(c.Request.Context(), entry, metadata); err != nil {
+			log.Printf("Не удалось записать файл %s в архив: %v", metadata.ID, err)
+			abortResponse(c)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		log.Printf("Не удалось завершить архив: %v", err)
+	}
+}
+
+// archiveEntryName возвращает безопасное и уникальное имя файла внутри архива
+func archiveEntryName(metadata *chunking.FileMetadata, usedNames map[string]bool) string {
+	name := path.Base(metadata.OriginalName)
+	if name == "" || name == "." || name == "/" {
+		name = metadata.ID
+	}
+
+	if usedNames[name] {
+		name = fmt.Sprintf("%s_%s", metadata.ID, name)
+	}
+	usedNames[name] = true
+
+	return name
+}
+
+// writeFileContent последовательно получает куски файла и пишет их в w, проверяя
+// контрольную сумму всего файла до записи последнего куска, и учитывает скачивание.
+// Следующие куски запрашиваются заранее, пока передается текущий
+func (s *StreamingAPIServer) writeFileContent(ctx context.Context, w io.Writer, metadata *chunking.FileMetadata) error {
+	if err := s.copyFileContent(ctx, w, metadata); err != nil {
+		return err
+	}
+	s.recordAccess(metadata.ID, true, metadata.Size)
+	return nil
+}
+
+// copyFileContent пишет содержимое файла в w, не учитывая скачивание. Файл проверяется по
+// контрольной сумме до записи последнего куска, поэтому поврежденный файл не попадает в w
+// целиком
+func (s *StreamingAPIServer) copyFileContent(ctx context.Context, w io.Writer, metadata *chunking.FileMetadata) error {
+	reader, err := s.newFileReader(ctx, metadata, 0)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = reader.WriteTo(w)
+	return err
+}
@@ -0,0 +1,82 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+)
+
+func TestArchiveAbortsOnChunkFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var node *corruptingNode
+	server := newTestServer(t, withStorageNodes(1), withNodeHandler(func(_ string, handler http.Handler) http.Handler {
+		node = &corruptingNode{handler: handler}
+		return node
+	}), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.ChunkCacheSize = 0
+	}))
+	api := server.api
+
+	var ids []string
+	for _, name := range []string{"first.txt", "second.txt"} {
+		metadata, err := api.UploadReader(ctx, name, strings.NewReader(name), int64(len(name)))
+		require.NoError(t, err)
+		ids = append(ids, `"`+metadata.ID+`"`)
+	}
+	body := `{"ids": [` + strings.Join(ids, ",") + `], "format": "tar"}`
+
+	for _, corrupt := range []bool{false, true} {
+		node.corrupt.Store(corrupt)
+		resp, err := http.Post(server.url+"/api/v1/files/archive", "application/json", strings.NewReader(body))
+		if err == nil {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		// Архив, который не удалось собрать, обрывается, а не завершается как целый
+		if corrupt {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestArchiveWithholdsFileWithWrongChecksum(t *testing.T) {
+	ctx := context.Background()
+
+	server := newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) { cfg.ChunkCount = 1 }))
+	api := server.api
+
+	content := strings.Repeat("content that does not match the file checksum ", 2048)
+	metadata, err := api.UploadReader(ctx, "mismatch.txt", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	_, err = server.updateFile(ctx, metadata.ID, func(updated *chunking.FileMetadata) error {
+		updated.Checksum = strings.Repeat("0", len(updated.Checksum))
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Куски целы, но файл не совпадает с контрольной суммой: его последний кусок не
+	// попадает в архив, а ответ обрывается
+	resp, err := http.Post(server.url+"/api/v1/files/archive", "application/json",
+		strings.NewReader(`{"ids": ["`+metadata.ID+`"], "format": "zip"}`))
+	var received []byte
+	if err == nil {
+		received, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	assert.Error(t, err)
+	assert.NotContains(t, string(received), content)
+}
@@ -0,0 +1,197 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/audit"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+)
+
+const (
+	// auditActorKey - ключ контекста gin, в который аутентификация кладет имя пользователя
+	auditActorKey = "audit.actor"
+
+	// auditErrorBodyLimit - сколько байт ответа с ошибкой сохраняется для журнала
+	auditErrorBodyLimit = 1024
+)
+
+// newAuditLogger создает журнал аудита по конфигурации; nil означает, что аудит отключен
+func newAuditLogger(cfg *config.Config) (*audit.Logger, error) {
+	var sinks []audit.Sink
+	for _, name := range cfg.AuditSinks {
+		switch strings.TrimSpace(name) {
+		case "file":
+			sink, err := audit.NewFileSink(cfg.AuditFile)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "stdout":
+			sinks = append(sinks, audit.NewWriterSink(os.Stdout))
+		case "", "none":
+		default:
+			return nil, fmt.Errorf("неизвестный приемник аудита: %s", name)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return audit.NewLogger(sinks, cfg.AuditRetention), nil
+}
+
+// isMutatingMethod сообщает, изменяет ли запрос с этим методом данные
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return false
+	}
+	return true
+}
+
+// auditResponseWriter сохраняет начало тела ответа с ошибкой для журнала аудита
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.Status() >= http.StatusBadRequest && w.body.Len() < auditErrorBodyLimit {
+		remaining := auditErrorBodyLimit - w.body.Len()
+		if len(data) < remaining {
+			remaining = len(data)
+		}
+		w.body.Write(data[:remaining])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// auditMiddleware записывает в журнал аудита каждый изменяющий запрос
+func (s *StreamingAPIServer) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// POST запросов GraphQL только читают метаданные
+		if !isMutatingMethod(c.Request.Method) || c.FullPath() == graphQLPath {
+			c.Next()
+			return
+		}
+
+		ctx, details := audit.WithDetails(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		actor := c.GetString(auditActorKey)
+		if actor == "" {
+			actor = "anonymous"
+		}
+
+		action := c.FullPath()
+		if action == "" {
+			action = c.Request.URL.Path
+		}
+
+		status := writer.Status()
+		entry := audit.Entry{
+			Actor:     actor,
+			Action:    c.Request.Method + " " + action,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Files:     details.Files(),
+			Threat:    details.Threat(),
+			Status:    status,
+			Success:   status < http.StatusBadRequest,
+			ClientIP:  c.ClientIP(),
+			RequestID: c.GetString(requestIDKey),
+		}
+		if !entry.Success {
+			entry.Error = auditErrorMessage(writer.body.Bytes())
+		}
+
+		s.audit.Record(entry)
+	}
+}
+
+// auditErrorMessage извлекает текст ошибки из JSON ответа или возвращает тело как есть
+func auditErrorMessage(body []byte) string {
+	var response apierror.Envelope
+	if err := json.Unmarshal(body, &response); err == nil && response.Error.Message != "" {
+		return response.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// recordAuditFile отмечает файл и его куски в записи аудита текущего запроса
+func recordAuditFile(ctx context.Context, metadata *chunking.FileMetadata) {
+	chunks := make([]string, 0, len(metadata.Chunks))
+	for _, chunk := range metadata.Chunks {
+		chunks = append(chunks, chunk.ID)
+	}
+	audit.AddFile(ctx, metadata.ID, chunks)
+}
+
+// queryAudit возвращает записи журнала аудита.
+// Параметры: actor, action, file_id, since и until (RFC 3339), limit.
+// Весь журнал доступен клиентам из api_admins, остальные видят только свои записи
+func (s *StreamingAPIServer) queryAudit(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		FileID: c.Query("file_id"),
+		Limit:  100,
+	}
+	if principal := c.GetString(principalKey); !s.current().isAdmin(principal) {
+		if filter.Actor != "" && filter.Actor != principal {
+			writeError(c, http.StatusForbidden, apierror.AdminRequired)
+			return
+		}
+		filter.Actor = principal
+	}
+
+	for param, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(c, http.StatusBadRequest, apierror.InvalidTimeParameter, param)
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			writeError(c, http.StatusBadRequest, apierror.InvalidLimit)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := s.audit.Query(filter)
+	if errors.Is(err, audit.ErrQueryNotSupported) {
+		writeError(c, http.StatusNotImplemented, apierror.AuditQueryUnsupported)
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.AuditReadFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,128 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+)
+
+// principalKey - ключ контекста gin с именем ключа API, которым подписан запрос;
+// пусто, если запрос выполнен без ключа
+const principalKey = "auth.principal"
+
+// principalContextKey - ключ контекста запроса с именем ключа API клиента для обработчиков
+// вне gin: gRPC API и резолверов GraphQL
+type principalContextKey struct{}
+
+// withPrincipal запоминает в контексте имя ключа API клиента
+func withPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// contextPrincipal возвращает имя ключа API клиента из контекста; пусто, если запрос
+// выполнен без ключа
+func contextPrincipal(ctx context.Context) string {
+	name, _ := ctx.Value(principalContextKey{}).(string)
+	return name
+}
+
+// principalMiddleware передает имя клиента из контекста gin в контекст запроса для
+// обработчиков, которые получают только context.Context: S3 шлюза и WebDAV
+func principalMiddleware(c *gin.Context) {
+	c.Request = c.Request.WithContext(withPrincipal(c.Request.Context(), c.GetString(principalKey)))
+}
+
+// apiKey - ключ клиента API из api_keys
+type apiKey struct {
+	name  string // имя клиента для журнала аудита
+	key   []byte
+	admin bool // клиент указан в api_admins
+}
+
+// requestAPIKey возвращает ключ API из заголовка Authorization: Bearer, пароля
+// Authorization: Basic (так ключ передают клиенты WebDAV) или X-API-Key
+func requestAPIKey(c *gin.Context) string {
+	if key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return key
+	}
+	if _, password, ok := c.Request.BasicAuth(); ok {
+		return password
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// authenticate ищет клиента по ключу. Ключи сравниваются за постоянное время,
+// чтобы время ответа не выдавало совпавшую часть ключа
+func (r *runtimeSettings) authenticate(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	var name string
+	for _, candidate := range r.apiKeys {
+		if subtle.ConstantTimeCompare(candidate.key, []byte(key)) == 1 {
+			name = candidate.name
+		}
+	}
+	return name, name != ""
+}
+
+// authMiddleware проверяет ключ API и запоминает имя клиента для журнала аудита.
+// Защищает REST API, S3 шлюз и WebDAV. Без настроенных api_keys API доступен без ключа.
+// Загрузка файла по токену загрузки,
+// скачивание по публичной ссылке и ящики для приема файлов не требуют ключа: токены
+// и видимость файла проверяют обработчики
+func (s *StreamingAPIServer) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings := s.current()
+
+		if name, ok := settings.authenticate(requestAPIKey(c)); ok {
+			c.Set(principalKey, name)
+			c.Set(auditActorKey, name)
+			c.Next()
+			return
+		}
+		if len(settings.apiKeys) == 0 || isUploadTokenRequest(c) || isPublicRequest(c) || isDropBoxRequest(c) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, apierror.APIKeyRequired))
+	}
+}
+
+// isAdmin сообщает, доступно ли клиенту principal администрирование. Без api_keys
+// администрирование, как и остальной API, доступно без ключа
+func (r *runtimeSettings) isAdmin(principal string) bool {
+	if len(r.apiKeys) == 0 {
+		return true
+	}
+	for _, candidate := range r.apiKeys {
+		if candidate.admin && candidate.name == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// adminMiddleware допускает к /api/v1/admin только клиентов из api_admins: выгрузка и
+// загрузка каталога раскрывают и меняют владельцев и права всех файлов
+func (s *StreamingAPIServer) adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.current().isAdmin(c.GetString(principalKey)) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, errorBody(c, apierror.AdminRequired))
+	}
+}
+
+// isUploadTokenRequest сообщает, является ли запрос загрузкой файла по токену загрузки
+func isUploadTokenRequest(c *gin.Context) bool {
+	isUpload := c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut
+	return isUpload && c.FullPath() == "/api/v1/files" && c.Query("token") != ""
+}
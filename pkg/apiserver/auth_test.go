@@ -0,0 +1,332 @@
+package apiserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/audit"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+func TestGatewaysRequireAPIKey(t *testing.T) {
+	server := newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.APIKeys = []string{"alice:alice-key"}
+	}))
+
+	do := func(method, path string, setKey func(*http.Request)) *http.Response {
+		var body io.Reader
+		if method == http.MethodPut {
+			body = strings.NewReader("content")
+		}
+		req, err := http.NewRequest(method, server.url+path, body)
+		require.NoError(t, err)
+		if setKey != nil {
+			setKey(req)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Без ключа и подписи S3 шлюз отвечает ошибкой S3 AccessDenied
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/s3"},
+		{http.MethodPut, "/s3/bucket/file.txt"},
+		{http.MethodGet, "/s3/bucket/file.txt"},
+	} {
+		req, err := http.NewRequest(request.method, server.url+request.path, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, request.method+" "+request.path)
+		assert.Contains(t, string(content), "<Code>AccessDenied</Code>", request.method+" "+request.path)
+	}
+
+	// WebDAV без ключа отвечает 401 и предлагает ввести ключ паролем
+	for _, request := range []struct{ method, path string }{
+		{"PROPFIND", "/webdav/"},
+		{http.MethodPut, "/webdav/docs/file.txt"},
+		{http.MethodDelete, "/webdav/docs/file.txt"},
+	} {
+		resp := do(request.method, request.path, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, request.method+" "+request.path)
+	}
+	resp := do("PROPFIND", "/webdav/", func(req *http.Request) { req.SetBasicAuth("alice", "wrong-key") })
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+
+	// С ключом в заголовке или паролем Basic запросы выполняются
+	resp = do(http.MethodPut, "/s3/bucket/file.txt", func(req *http.Request) { req.Header.Set("X-API-Key", "alice-key") })
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do(http.MethodGet, "/s3/bucket/file.txt", func(req *http.Request) { req.Header.Set("Authorization", "Bearer alice-key") })
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do("PROPFIND", "/webdav/", func(req *http.Request) { req.SetBasicAuth("alice", "alice-key") })
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("WWW-Authenticate"))
+}
+
+func TestS3GatewayAcceptsSigV4(t *testing.T) {
+	server := newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.APIKeys = []string{"alice:alice-key"}
+	}))
+
+	do := func(method, path, secret string, body, signedBody []byte) (int, string) {
+		req, err := http.NewRequest(method, server.url+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		signS3Request(req, "alice", secret, signedBody)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(content)
+	}
+
+	// Идентификатор ключа доступа - имя клиента, секретный ключ - ключ API
+	content := []byte("signed object")
+	status, _ := do(http.MethodPut, "/s3/bucket/signed.txt", "alice-key", content, content)
+	assert.Equal(t, http.StatusOK, status)
+	status, body := do(http.MethodGet, "/s3/bucket/signed.txt", "alice-key", nil, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, string(content), body)
+
+	status, body = do(http.MethodGet, "/s3/bucket/signed.txt", "wrong-key", nil, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, body, "<Code>SignatureDoesNotMatch</Code>")
+
+	// Тело, не совпадающее с подписанным X-Amz-Content-Sha256, не сохраняется
+	status, body = do(http.MethodPut, "/s3/bucket/signed.txt", "alice-key", []byte("tampered"), content)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "<Code>XAmzContentSHA256Mismatch</Code>")
+	_, body = do(http.MethodGet, "/s3/bucket/signed.txt", "alice-key", nil, nil)
+	assert.Equal(t, string(content), body)
+}
+
+func TestGatewaysCheckFileAccess(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.APIKeys = []string{"alice:alice-key", "bob:bob-key"}
+	}))
+
+	do := func(key, method, path, body string, headers ...string) (int, string) {
+		req, err := http.NewRequest(method, server.url+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.SetBasicAuth("", key)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(content)
+	}
+
+	// Файлы, загруженные через S3 и WebDAV, принадлежат загрузившему клиенту
+	status, _ := do("alice-key", http.MethodPut, "/s3/bucket/report.txt", "s3 report")
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do("alice-key", "MKCOL", "/webdav/docs", "")
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = do("alice-key", http.MethodPut, "/webdav/docs/report.txt", "dav report")
+	require.Equal(t, http.StatusCreated, status)
+	davFile, err := server.findFileByPath(ctx, "docs/report.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", davFile.Owner)
+
+	// Чужой файл не читается, не заменяется, не переносится и не удаляется
+	status, _ = do("bob-key", http.MethodGet, "/s3/bucket/report.txt", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	_, listing := do("bob-key", http.MethodGet, "/s3/bucket", "")
+	assert.NotContains(t, listing, "report.txt")
+	status, _ = do("bob-key", http.MethodPut, "/s3/bucket/report.txt", "overwritten")
+	assert.Equal(t, http.StatusForbidden, status)
+	do("bob-key", http.MethodDelete, "/s3/bucket/report.txt", "")
+
+	status, _ = do("bob-key", http.MethodGet, "/webdav/docs/report.txt", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	_, listing = do("bob-key", "PROPFIND", "/webdav/", "", "Depth", "infinity")
+	assert.NotContains(t, listing, "report.txt")
+	status, _ = do("bob-key", http.MethodPut, "/webdav/docs/report.txt", "overwritten")
+	assert.Equal(t, http.StatusForbidden, status)
+	do("bob-key", "MOVE", "/webdav/docs/report.txt", "", "Destination", server.url+"/webdav/docs/moved.txt")
+	do("bob-key", http.MethodDelete, "/webdav/docs/report.txt", "")
+	do("bob-key", http.MethodDelete, "/webdav/docs", "")
+
+	status, content := do("alice-key", http.MethodGet, "/s3/bucket/report.txt", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "s3 report", content)
+	status, content = do("alice-key", http.MethodGet, "/webdav/docs/report.txt", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "dav report", content)
+
+	// Право чтения открывает файл, но не разрешает его менять
+	status, _ = do("alice-key", http.MethodPut, "/api/v1/files/"+davFile.ID+"/acl/bob", `{"permission": "read"}`,
+		"Content-Type", "application/json")
+	require.Equal(t, http.StatusOK, status)
+	status, content = do("bob-key", http.MethodGet, "/webdav/docs/report.txt", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "dav report", content)
+	status, _ = do("bob-key", http.MethodPut, "/webdav/docs/report.txt", "overwritten")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do("bob-key", http.MethodDelete, "/webdav/docs/report.txt", "")
+	assert.NotEqual(t, http.StatusNoContent, status)
+
+	// Замена владельцем сохраняет выданные права
+	status, _ = do("alice-key", http.MethodPut, "/webdav/docs/report.txt", "dav report v2")
+	require.Equal(t, http.StatusCreated, status)
+	status, content = do("bob-key", http.MethodGet, "/webdav/docs/report.txt", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "dav report v2", content)
+}
+
+func TestAnonymousAccessWithAPIKeys(t *testing.T) {
+	owned := &chunking.FileMetadata{Owner: "alice"}
+	ownerless := &chunking.FileMetadata{}
+
+	withKeys := &runtimeSettings{apiKeys: []apiKey{{name: "alice", key: []byte("alice-key")}}}
+	assert.False(t, withKeys.canAccess("", owned, accessRead))
+	assert.True(t, withKeys.canAccess("", ownerless, accessWrite))
+	assert.True(t, withKeys.canAccess("alice", owned, accessOwner))
+
+	withoutKeys := &runtimeSettings{}
+	assert.True(t, withoutKeys.canAccess("", owned, accessWrite))
+}
+
+func TestAdminRequiresAdminKey(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t, withConfig(func(cfg *config.Config) {
+		cfg.APIKeys = []string{"root:root-key", "alice:alice-key", "bob:bob-key"}
+		cfg.APIAdmins = []string{"root"}
+	}))
+	require.NoError(t, server.catalog.Put(ctx, &chunking.FileMetadata{ID: "alice-file", OriginalName: "a.txt", Owner: "alice"}))
+
+	do := func(key, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, server.url+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(content)
+	}
+
+	// Ключ не из api_admins не получает доступа к администрированию
+	status, content := do("bob-key", http.MethodGet, "/api/v1/admin/catalog/export", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, content, string(apierror.AdminRequired))
+	status, _ = do("bob-key", http.MethodPost, "/api/v1/admin/catalog/import?overwrite=true",
+		`{"id":"alice-file","original_name":"a.txt","owner":"bob"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, content = do("root-key", http.MethodGet, "/api/v1/admin/catalog/export", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, content, "alice-file")
+
+	// Загрузка снимка не заменяет файл, который администратор не может изменять
+	status, content = do("root-key", http.MethodPost, "/api/v1/admin/catalog/import?overwrite=true",
+		`{"id":"alice-file","original_name":"a.txt","owner":"root"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, content, `"failed"`)
+	metadata, err := server.catalog.Get(ctx, "alice-file")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", metadata.Owner)
+}
+
+func TestEventsAndAuditFollowAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	baseURL := newSingleNodeTestServer(t, func(cfg *config.Config) {
+		cfg.APIKeys = append(cfg.APIKeys, "root:root-key")
+		cfg.APIAdmins = []string{"root"}
+		cfg.AuditSinks = []string{"file"}
+		cfg.AuditFile = filepath.Join(t.TempDir(), "audit.log")
+	})
+	get := func(key, path string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", key)
+		return http.DefaultClient.Do(req)
+	}
+
+	stream, err := get("bob-key", "/api/v1/events")
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	lines := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	upload := func(key, name string) {
+		api := client.NewAPIClient(baseURL)
+		api.SetAPIKey(key)
+		_, err := api.UploadReader(ctx, name, strings.NewReader(name), int64(len(name)))
+		require.NoError(t, err)
+	}
+	upload("alice-key", "alice-secret.txt")
+	upload("bob-key", "bob-notes.txt")
+
+	// Событие о чужом файле не доходит до клиента без права чтения
+	var received []string
+	for !strings.Contains(strings.Join(received, "\n"), "bob-notes.txt") {
+		select {
+		case line, ok := <-lines:
+			require.True(t, ok)
+			received = append(received, line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "событие о своем файле не получено")
+		}
+	}
+	assert.NotContains(t, strings.Join(received, "\n"), "alice-secret.txt")
+
+	// Журнал аудита без прав администратора показывает только свои записи
+	queryAudit := func(key, query string) (int, []audit.Entry) {
+		resp, err := get(key, "/api/v1/audit"+query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var entries []audit.Entry
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+		}
+		return resp.StatusCode, entries
+	}
+	status, entries := queryAudit("bob-key", "")
+	assert.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Equal(t, "bob", entry.Actor)
+	}
+	status, _ = queryAudit("bob-key", "?actor=alice")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, entries = queryAudit("root-key", "?actor=alice")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, entries)
+}
@@ -0,0 +1,172 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/metaformat"
+)
+
+// catalogImportResult - итог загрузки снимка каталога
+type catalogImportResult struct {
+	Imported int                 `json:"imported"`
+	Skipped  int                 `json:"skipped"` // файлы, уже бывшие в каталоге, без overwrite
+	Failed   []catalogImportFail `json:"failed,omitempty"`
+}
+
+// catalogImportFail - файл снимка, который не удалось сохранить в каталог
+type catalogImportFail struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// exportCatalog выгружает метаданные всех файлов в формате ndjson (по умолчанию), json,
+// protobuf (сообщение FileList) или msgpack (массив), чтобы сохранить резервную копию
+// каталога отдельно от данных кусков
+func (s *StreamingAPIServer) exportCatalog(c *gin.Context) {
+	format := c.DefaultQuery("format", "ndjson")
+	binaryFormat := metaformat.Format(format)
+	if format != "ndjson" && format != "json" && binaryFormat != metaformat.Protobuf && binaryFormat != metaformat.MessagePack {
+		writeError(c, http.StatusBadRequest, apierror.InvalidExportFormat, format)
+		return
+	}
+
+	files, err := s.catalog.List(c.Request.Context())
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.CatalogReadFailed)
+		return
+	}
+	// Порядок не зависит от хранилища каталога, поэтому снимки удобно сравнивать
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].CreatedAt.Equal(files[j].CreatedAt) {
+			return files[i].CreatedAt.Before(files[j].CreatedAt)
+		}
+		return files[i].ID < files[j].ID
+	})
+
+	exportedAt := time.Now().UTC()
+	filename := fmt.Sprintf("catalog-%s.%s", exportedAt.Format("20060102T150405Z"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("X-File-Count", strconv.Itoa(len(files)))
+
+	if format == "json" {
+		c.JSON(http.StatusOK, catalog.Snapshot{Version: catalog.SnapshotVersion, ExportedAt: exportedAt, Files: files})
+		return
+	}
+	if format != "ndjson" {
+		c.Header("Content-Type", binaryFormat.ContentType())
+		c.Status(http.StatusOK)
+		if err := metaformat.EncodeFiles(c.Writer, binaryFormat, files); err != nil {
+			log.Printf("Выгрузка каталога прервана: %v", err)
+		}
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for _, metadata := range files {
+		if err := encoder.Encode(metadata); err != nil {
+			log.Printf("Выгрузка каталога прервана: %v", err)
+			return
+		}
+	}
+}
+
+// importCatalog загружает снимок каталога в формате ndjson или json, а с Content-Type
+// application/x-protobuf или application/msgpack - в формате выгрузки protobuf или msgpack.
+// Файлы, уже бывшие в каталоге, пропускаются, а с overwrite=true заменяются метаданными
+// из снимка. Снимок проверяется целиком до изменения каталога
+func (s *StreamingAPIServer) importCatalog(c *gin.Context) {
+	overwrite, ok := parseOverwrite(c)
+	if !ok {
+		return
+	}
+
+	files, err := readCatalogSnapshot(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidCatalogSnapshot, err)
+		return
+	}
+
+	result := s.importFiles(c.Request.Context(), c.GetString(principalKey), files, overwrite)
+	log.Printf("Загружен снимок каталога: %d файлов сохранено, %d пропущено, %d с ошибкой",
+		result.Imported, result.Skipped, len(result.Failed))
+	c.JSON(http.StatusOK, result)
+}
+
+// readCatalogSnapshot читает снимок каталога из тела запроса в формате по Content-Type
+func readCatalogSnapshot(c *gin.Context) ([]*chunking.FileMetadata, error) {
+	format := requestMetadataFormat(c)
+	if format == metaformat.JSON {
+		return catalog.ReadSnapshot(c.Request.Body)
+	}
+	files, err := metaformat.DecodeFiles(c.Request.Body, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := catalog.ValidateSnapshot(files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// parseOverwrite разбирает параметр overwrite; при неверном значении отвечает ошибкой
+// и возвращает ok = false
+func parseOverwrite(c *gin.Context) (overwrite bool, ok bool) {
+	value := c.Query("overwrite")
+	if value == "" {
+		return false, true
+	}
+	overwrite, err := strconv.ParseBool(value)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, fmt.Errorf("неверное значение параметра overwrite: %q", value))
+		return false, false
+	}
+	return overwrite, true
+}
+
+// importFiles сохраняет метаданные файлов в каталог. Файлы, уже бывшие в каталоге,
+// пропускаются, а с overwrite заменяются, если клиент principal может их изменять:
+// иначе снимок с другим владельцем и правами позволил бы присвоить чужой файл
+func (s *StreamingAPIServer) importFiles(ctx context.Context, principal string, files []*chunking.FileMetadata, overwrite bool) catalogImportResult {
+	settings := s.current()
+	var result catalogImportResult
+	for _, metadata := range files {
+		existing, err := s.catalog.Get(ctx, metadata.ID)
+		switch {
+		case err == nil && !overwrite:
+			result.Skipped++
+			continue
+		case err == nil && !settings.canAccess(principal, existing, accessWrite):
+			result.Failed = append(result.Failed, catalogImportFail{ID: metadata.ID, Error: errAccessDenied.Error()})
+			continue
+		case err == nil:
+			metadata.Generation = existing.Generation
+		case errors.Is(err, catalog.ErrNotFound):
+			metadata.Generation = 0
+		default:
+			result.Failed = append(result.Failed, catalogImportFail{ID: metadata.ID, Error: err.Error()})
+			continue
+		}
+
+		if err := s.putMetadata(ctx, metadata); err != nil {
+			result.Failed = append(result.Failed, catalogImportFail{ID: metadata.ID, Error: err.Error()})
+			continue
+		}
+		result.Imported++
+	}
+	return result
+}
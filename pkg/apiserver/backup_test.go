@@ -0,0 +1,57 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+)
+
+func TestCatalogExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := newTestServer(t)
+	sourceClient := source.api
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, id := range []string{"b", "a"} {
+		require.NoError(t, source.catalog.Put(ctx, &chunking.FileMetadata{ID: id, OriginalName: id + ".txt", Size: 1, CreatedAt: created}))
+	}
+
+	for _, format := range []string{client.CatalogFormatNDJSON, client.CatalogFormatJSON, client.CatalogFormatProtobuf, client.CatalogFormatMessagePack} {
+		var snapshot bytes.Buffer
+		require.NoError(t, sourceClient.ExportCatalogContext(ctx, &snapshot, format))
+		if format == client.CatalogFormatNDJSON {
+			lines := strings.Split(strings.TrimSpace(snapshot.String()), "\n")
+			require.Len(t, lines, 2)
+			assert.Contains(t, lines[0], `"id":"a"`)
+		}
+
+		target := newTestServer(t)
+		targetClient := target.api
+		require.NoError(t, target.catalog.Put(ctx, &chunking.FileMetadata{ID: "a", OriginalName: "old.txt"}))
+
+		result, err := targetClient.ImportCatalogFormatContext(ctx, bytes.NewReader(snapshot.Bytes()), format, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+		assert.Equal(t, 1, result.Skipped)
+
+		result, err = targetClient.ImportCatalogFormatContext(ctx, bytes.NewReader(snapshot.Bytes()), format, true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Imported)
+
+		metadata, err := target.catalog.Get(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt", metadata.OriginalName)
+		assert.Equal(t, created, metadata.CreatedAt)
+	}
+
+	_, err := sourceClient.ImportCatalogContext(ctx, strings.NewReader(`{"original_name":"x"}`), false)
+	assert.ErrorContains(t, err, "invalid_catalog_snapshot")
+	assert.Error(t, sourceClient.ExportCatalogContext(ctx, &bytes.Buffer{}, "xml"))
+}
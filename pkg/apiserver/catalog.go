@@ -0,0 +1,105 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+)
+
+// catalogConnectTimeout ограничивает подключение к внешнему хранилищу каталога при запуске
+const catalogConnectTimeout = 10 * time.Second
+
+// updateAttempts ограничивает повторы изменения метаданных, которые одновременно изменил другой запрос
+const updateAttempts = 5
+
+// catalogPinger - внешнее хранилище каталога, доступность которого проверяет /health
+type catalogPinger interface {
+	Ping(ctx context.Context) error
+}
+
+// newCatalog создает хранилище каталога метаданных по конфигурации
+func newCatalog(cfg *config.Config) (catalog.Store, error) {
+	switch cfg.MetadataStore {
+	case "", "memory":
+		return catalog.NewMemoryStore(), nil
+	case "raft":
+		return catalog.NewRaftStore(catalog.RaftConfig{
+			NodeID:        cfg.RaftNodeID,
+			BindAddr:      cfg.RaftBind,
+			AdvertiseAddr: cfg.RaftAdvertise,
+			Dir:           cfg.RaftDir,
+			Peers:         cfg.RaftPeers,
+
+			Secret:           cfg.InternalSecret,
+			SignatureMaxSkew: cfg.InternalSignatureMaxSkew,
+			ForwardListen:    cfg.RaftForwardListen,
+		})
+	case "postgres", "redis":
+		ctx, cancel := context.WithTimeout(context.Background(), catalogConnectTimeout)
+		defer cancel()
+		if cfg.MetadataStore == "postgres" {
+			return catalog.NewPostgresStore(ctx, cfg.MetadataDSN)
+		}
+		return catalog.NewRedisStore(ctx, cfg.MetadataDSN)
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище метаданных: %s", cfg.MetadataStore)
+	}
+}
+
+// setupCatalogRoutes регистрирует служебные маршруты кластера метаданных. С
+// raft_forward_listen изменения от остальных узлов принимает отдельный внутренний сервер
+func (s *StreamingAPIServer) setupCatalogRoutes(router *gin.Engine) {
+	if raftStore, ok := s.catalog.(*catalog.RaftStore); ok && s.current().config.RaftForwardListen == "" {
+		router.POST(catalog.ForwardPath, gin.WrapH(raftStore))
+	}
+}
+
+// updateFile читает метаданные файла, изменяет их копию функцией change и сохраняет.
+// Если другой запрос успел сохранить новое поколение метаданных, изменение повторяется
+// над ним, поэтому одновременные изменения не теряются. change не изменяет срезы и карты
+// прочитанных метаданных; ошибка change прерывает изменение
+func (s *StreamingAPIServer) updateFile(ctx context.Context, fileID string, change func(metadata *chunking.FileMetadata) error) (*chunking.FileMetadata, error) {
+	for attempt := 1; ; attempt++ {
+		metadata, err := s.catalog.Get(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Сохраненные метаданные не изменяются: записываем измененную копию
+		updated := *metadata
+		if err := change(&updated); err != nil {
+			return nil, err
+		}
+		err = s.putMetadata(ctx, &updated)
+		if errors.Is(err, catalog.ErrConflict) && attempt < updateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &updated, nil
+	}
+}
+
+// writeCatalogError отвечает ошибкой каталога метаданных с подходящим статусом
+func writeCatalogError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		writeError(c, http.StatusNotFound, apierror.FileNotFound)
+	case errors.Is(err, catalog.ErrNoLeader):
+		writeError(c, http.StatusServiceUnavailable, apierror.CatalogUnavailable)
+	case errors.Is(err, catalog.ErrConflict):
+		writeError(c, http.StatusConflict, apierror.MetadataConflict)
+	default:
+		writeError(c, http.StatusInternalServerError, apierror.CatalogError, err)
+	}
+}
@@ -0,0 +1,95 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// parseSHA256 проверяет шестнадцатеричную запись SHA256 и приводит ее к нижнему регистру
+func parseSHA256(value string) (string, bool) {
+	value = strings.ToLower(value)
+	decoded, err := hex.DecodeString(value)
+	return value, err == nil && len(decoded) == 32
+}
+
+// contentSHA256Header - заголовок с SHA256 загружаемого файла, вычисленной клиентом
+const contentSHA256Header = "X-Content-SHA256"
+
+// verifyContentSHA256 сверяет полученные данные с SHA256 из заголовка X-Content-SHA256 или
+// поля формы sha256, если клиент ее передал. Так обнаруживается повреждение данных между
+// клиентом и API сервером до того, как файл будет сохранен
+func verifyContentSHA256(c *gin.Context, data []byte) *requestError {
+	value := c.GetHeader(contentSHA256Header)
+	if value == "" {
+		value = c.PostForm("sha256")
+	}
+	if value == "" {
+		return nil
+	}
+
+	expected, ok := parseSHA256(value)
+	if !ok {
+		return newRequestError(http.StatusBadRequest, apierror.InvalidChecksum)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return newRequestError(http.StatusBadRequest, apierror.ContentSHA256Mismatch, actual, expected)
+	}
+	return nil
+}
+
+// hasSHA256 сообщает, совпадает ли SHA256 всего файла с checksum. Контрольные суммы
+// файлов, загруженных с другим checksum_algorithm, с SHA256 не сравниваются
+func hasSHA256(metadata *chunking.FileMetadata, checksum string) bool {
+	algorithm := metadata.ChecksumAlgorithm
+	if algorithm == "" {
+		algorithm = chunking.DefaultHashAlgorithm
+	}
+	return algorithm == chunking.HashSHA256 && metadata.Checksum == checksum
+}
+
+// findFilesByChecksum возвращает доступные клиенту файлы с заданным SHA256 содержимого,
+// чтобы клиент мог не загружать данные, которые уже хранятся в кластере
+func (s *StreamingAPIServer) findFilesByChecksum(c *gin.Context) {
+	checksum, ok := parseSHA256(c.Param("sha256"))
+	if !ok {
+		writeError(c, http.StatusBadRequest, apierror.InvalidChecksum)
+		return
+	}
+
+	files, err := s.filesWithSHA256(c.Request.Context(), c.GetString(principalKey), checksum)
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+	for i, metadata := range files {
+		files[i] = s.access.merged(metadata)
+	}
+
+	writeMetadataList(c, http.StatusOK, files)
+}
+
+// filesWithSHA256 возвращает файлы с заданным SHA256 содержимого, которые клиент principal может читать
+func (s *StreamingAPIServer) filesWithSHA256(ctx context.Context, principal, checksum string) ([]*chunking.FileMetadata, error) {
+	all, err := s.catalog.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := s.current()
+	files := make([]*chunking.FileMetadata, 0)
+	for _, metadata := range all {
+		if hasSHA256(metadata, checksum) && settings.canAccess(principal, metadata, accessRead) {
+			files = append(files, metadata)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,152 @@
+package apiserver
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"TestCase/pkg/chunking"
+)
+
+// chunkCache хранит недавно прочитанные куски в памяти API сервера, чтобы популярный
+// файл не запрашивался с серверов хранения при каждом скачивании. Одновременные
+// запросы одного куска объединяются в одно обращение к серверу хранения.
+// Содержимое куска с заданным ID не меняется, поэтому записи не нужно сбрасывать:
+// куски удаленных файлов вытесняются как давно не используемые
+type chunkCache struct {
+	maxBytes int64
+
+	mutex    sync.Mutex
+	bytes    int64
+	order    *list.List // от недавно использованных к давним; значения - *chunkCacheEntry
+	entries  map[string]*list.Element
+	inflight map[string]*chunkFetch
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	coalesced atomic.Int64
+}
+
+// chunkCacheEntry - кусок в кэше
+type chunkCacheEntry struct {
+	id    string
+	chunk chunking.FileChunk
+}
+
+// chunkFetch - получение куска с серверов хранения, которого ждут одновременные запросы
+type chunkFetch struct {
+	done  chan struct{}
+	chunk *chunking.FileChunk
+	err   error
+}
+
+// chunkCacheStats - состояние кэша кусков
+type chunkCacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`    // куски, полученные с серверов хранения
+	Coalesced int64 `json:"coalesced"` // запросы, дождавшиеся уже начатого получения куска
+}
+
+// newChunkCache создает кэш кусков объемом maxBytes байт; при maxBytes 0 кэш отключен
+func newChunkCache(maxBytes int64) *chunkCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &chunkCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*chunkFetch),
+	}
+}
+
+// get возвращает кусок из кэша или получает его через fetch. Пока кусок получается,
+// остальные запросы того же куска ждут результата вместо повторного обращения.
+// Получение не прерывается отменой ctx, так как результата могут ждать другие запросы
+func (c *chunkCache) get(ctx context.Context, chunkID string, fetch func(ctx context.Context) (*chunking.FileChunk, error)) (*chunking.FileChunk, error) {
+	c.mutex.Lock()
+	if element, ok := c.entries[chunkID]; ok {
+		c.order.MoveToFront(element)
+		chunk := element.Value.(*chunkCacheEntry).chunk
+		c.mutex.Unlock()
+		c.hits.Add(1)
+		return &chunk, nil
+	}
+
+	current, ok := c.inflight[chunkID]
+	if ok {
+		c.coalesced.Add(1)
+	} else {
+		current = &chunkFetch{done: make(chan struct{})}
+		c.inflight[chunkID] = current
+		c.misses.Add(1)
+		go c.run(context.WithoutCancel(ctx), chunkID, current, fetch)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-current.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if current.err != nil {
+		return nil, current.err
+	}
+	chunk := *current.chunk
+	return &chunk, nil
+}
+
+// run получает кусок и сохраняет его в кэше. Ошибки не кэшируются
+func (c *chunkCache) run(ctx context.Context, chunkID string, current *chunkFetch, fetch func(ctx context.Context) (*chunking.FileChunk, error)) {
+	current.chunk, current.err = fetch(ctx)
+
+	c.mutex.Lock()
+	delete(c.inflight, chunkID)
+	if current.err == nil {
+		c.add(chunkID, *current.chunk)
+	}
+	c.mutex.Unlock()
+
+	close(current.done)
+}
+
+// add помещает кусок в кэш, вытесняя давно не использованные. Кусок больше
+// всего кэша не сохраняется. Вызывается под mutex
+func (c *chunkCache) add(chunkID string, chunk chunking.FileChunk) {
+	size := int64(len(chunk.Data))
+	if size > c.maxBytes {
+		return
+	}
+	if _, ok := c.entries[chunkID]; ok {
+		return
+	}
+
+	for c.bytes+size > c.maxBytes {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*chunkCacheEntry)
+		delete(c.entries, entry.id)
+		c.bytes -= int64(len(entry.chunk.Data))
+	}
+
+	c.entries[chunkID] = c.order.PushFront(&chunkCacheEntry{id: chunkID, chunk: chunk})
+	c.bytes += size
+}
+
+// stats возвращает состояние кэша
+func (c *chunkCache) stats() *chunkCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return &chunkCacheStats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Coalesced: c.coalesced.Load(),
+	}
+}
@@ -0,0 +1,51 @@
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"TestCase/pkg/config"
+)
+
+func TestAdaptiveChunkCount(t *testing.T) {
+	const MiB = 1024 * 1024
+	s := &StreamingAPIServer{nodes: newNodeRegistry()}
+	cfg := config.Defaults()
+	cfg.StorageServers = []string{"a:1", "b:1", "c:1", "d:1"}
+	settings := &runtimeSettings{config: cfg}
+
+	// Без target_chunk_size число кусков постоянно, кроме небольших файлов
+	assert.Equal(t, 1, s.chunkCount(settings, 100, uploadPolicy{}))
+	assert.Equal(t, cfg.ChunkCount, s.chunkCount(settings, 10*MiB, uploadPolicy{}))
+
+	cfg.TargetChunkSize = 8 * MiB
+	for _, test := range []struct {
+		size  int64
+		count int
+	}{
+		{size: 100, count: 1},
+		{size: 2 * MiB, count: 1},
+		{size: 8 * MiB, count: 1},
+		{size: 8*MiB + 1, count: 2},
+		{size: 24 * MiB, count: 3},
+		{size: 1024 * MiB, count: 4}, // не больше числа серверов
+	} {
+		assert.Equal(t, test.count, s.chunkCount(settings, test.size, uploadPolicy{}), "размер %d", test.size)
+	}
+
+	// Сервер в режиме обслуживания не получает новых кусков
+	s.nodes.update("d:1", func(status *nodeStatus) { status.State = nodeCordoned })
+	assert.Equal(t, 3, s.chunkCount(settings, 1024*MiB, uploadPolicy{}))
+
+	cfg.MinChunkCount, cfg.MaxChunkCount = 2, 16
+	assert.Equal(t, 1, s.chunkCount(settings, 100, uploadPolicy{})) // меньше small_file_threshold
+	assert.Equal(t, 2, s.chunkCount(settings, 2*MiB, uploadPolicy{}))
+	assert.Equal(t, 16, s.chunkCount(settings, 1024*MiB, uploadPolicy{}))
+
+	// Число кусков и размер куска из политики загрузки заменяют настройки сервера
+	assert.Equal(t, 5, s.chunkCount(settings, 100, uploadPolicy{ChunkCount: 5}))
+	assert.Equal(t, 3, s.chunkCount(settings, 3, uploadPolicy{ChunkCount: 5})) // без пустых кусков
+	assert.Equal(t, 4, s.chunkCount(settings, 4*MiB, uploadPolicy{ChunkSize: MiB}))
+	assert.Equal(t, 16, s.chunkCount(settings, 1024*MiB, uploadPolicy{ChunkSize: MiB})) // не больше max_chunk_count
+}
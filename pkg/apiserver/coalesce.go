@@ -0,0 +1,131 @@
+package apiserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// downloadCoalescer объединяет одновременные скачивания одного файла. При потоковой
+// передаче каждый кусок получается с серверов хранения один раз для всех скачиваний,
+// которым он нужен в это время, а файл, собираемый целиком, собирается и проверяется
+// один раз, и собранные байты отдаются всем ждущим запросам. В отличие от кэша кусков
+// работает и при отключенном кэше, и при чтении с уровнем согласованности выше one
+type downloadCoalescer struct {
+	group  singleflight.Group
+	chunks singleflight.Group
+
+	assembled atomic.Int64
+	coalesced atomic.Int64
+
+	chunkFetches    atomic.Int64
+	chunksCoalesced atomic.Int64
+}
+
+// downloadCoalescingStats - объединение одновременных скачиваний
+type downloadCoalescingStats struct {
+	Assembled       int64 `json:"assembled"`        // сборки файлов целиком для скачивания
+	Coalesced       int64 `json:"coalesced"`        // скачивания, дождавшиеся уже начатой сборки
+	ChunkFetches    int64 `json:"chunk_fetches"`    // получения кусков для потоковой передачи
+	ChunksCoalesced int64 `json:"chunks_coalesced"` // запросы кусков, дождавшиеся уже начатого получения
+}
+
+// do возвращает результат assemble для key. Пока файл собирается, остальные запросы того
+// же key ждут этой сборки. Сборка не прерывается отменой ctx, так как ее результата могут
+// ждать другие запросы
+func (d *downloadCoalescer) do(ctx context.Context, key string, assemble func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	assembleCtx := context.WithoutCancel(ctx)
+	var leader atomic.Bool
+	result := d.group.DoChan(key, func() (interface{}, error) {
+		leader.Store(true)
+		d.assembled.Add(1)
+		return assemble(assembleCtx)
+	})
+
+	select {
+	case res := <-result:
+		if !leader.Load() {
+			d.coalesced.Add(1)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// chunk возвращает результат fetch для куска key. Пока кусок получается, остальные
+// запросы того же key ждут этого получения. Получение не прерывается отменой ctx, так как
+// его результата могут ждать другие скачивания
+func (d *downloadCoalescer) chunk(ctx context.Context, key string, fetch func(ctx context.Context) (*chunking.FileChunk, error)) (*chunking.FileChunk, error) {
+	fetchCtx := context.WithoutCancel(ctx)
+	var leader atomic.Bool
+	result := d.chunks.DoChan(key, func() (interface{}, error) {
+		leader.Store(true)
+		d.chunkFetches.Add(1)
+		return fetch(fetchCtx)
+	})
+
+	select {
+	case res := <-result:
+		if !leader.Load() {
+			d.chunksCoalesced.Add(1)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*chunking.FileChunk), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// stats возвращает счетчики объединения скачиваний
+func (d *downloadCoalescer) stats() *downloadCoalescingStats {
+	return &downloadCoalescingStats{
+		Assembled:       d.assembled.Load(),
+		Coalesced:       d.coalesced.Load(),
+		ChunkFetches:    d.chunkFetches.Load(),
+		ChunksCoalesced: d.chunksCoalesced.Load(),
+	}
+}
+
+// assembleFile возвращает проверенное содержимое файла целиком. Одновременные скачивания
+// одной версии файла с одним уровнем согласованности получают общий результат; байты
+// общие, поэтому их нельзя изменять. Ошибки сборки и проверки возвращаются как
+// *requestError, ошибки получения кусков - как есть
+func (s *StreamingAPIServer) assembleFile(ctx context.Context, metadata *chunking.FileMetadata) ([]byte, error) {
+	key := metadata.ID + "\x00" + metadata.Checksum + "\x00" + string(contextReadConsistency(ctx))
+	return s.downloads.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		// Собираем куски файла
+		chunks, err := s.collectChunks(ctx, metadata.Chunks)
+		if err != nil {
+			return nil, err
+		}
+
+		// Собираем файл в памяти
+		fileData, err := s.reconstructFileInMemory(chunks)
+		if err != nil {
+			return nil, newRequestError(http.StatusInternalServerError, apierror.AssembleFailed, err)
+		}
+
+		// Проверяем целостность собранного файла до отправки клиентам
+		checksum, err := chunking.Checksum(metadata.ChecksumAlgorithm, fileData)
+		if err != nil {
+			return nil, newRequestError(http.StatusInternalServerError, apierror.VerifyFailed, err)
+		}
+		if checksum != metadata.Checksum {
+			log.Printf("Контрольная сумма файла %s не совпадает: ожидалась %s, получена %s", metadata.ID, metadata.Checksum, checksum)
+			return nil, newRequestError(http.StatusInternalServerError, apierror.ChecksumMismatch)
+		}
+		return fileData, nil
+	})
+}
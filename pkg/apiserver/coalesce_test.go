@@ -0,0 +1,148 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/config"
+)
+
+func TestDownloadCoalescer(t *testing.T) {
+	var downloads downloadCoalescer
+	release := make(chan struct{})
+	var calls atomic.Int32
+	assemble := func(ctx context.Context) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("content"), nil
+	}
+
+	// Одновременные запросы ждут одной сборки, в том числе после отмены первого из них
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := downloads.do(firstCtx, "file", assemble)
+		firstErr <- err
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := downloads.do(context.Background(), "file", assemble)
+			assert.NoError(t, err)
+			results[i] = data
+		}(i)
+	}
+	cancelFirst()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, data := range results {
+		assert.Equal(t, "content", string(data))
+	}
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, &downloadCoalescingStats{Assembled: 1, Coalesced: 3}, downloads.stats())
+
+	// Ошибка не запоминается: следующий запрос собирает файл заново
+	failure := errors.New("кусок недоступен")
+	_, err := downloads.do(context.Background(), "file", func(ctx context.Context) ([]byte, error) { return nil, failure })
+	assert.ErrorIs(t, err, failure)
+	data, err := downloads.do(context.Background(), "file", assemble)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, int64(3), downloads.stats().Assembled)
+}
+
+// heldNode - сервер хранения, который считает запросы кусков и не отвечает на них, пока не
+// закрыт release
+type heldNode struct {
+	handler http.Handler
+	release chan struct{}
+	reads   atomic.Int64
+}
+
+func (n *heldNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/chunks/") {
+		n.reads.Add(1)
+		<-n.release
+	}
+	n.handler.ServeHTTP(w, r)
+}
+
+func TestConcurrentDownloadsShareChunkFetches(t *testing.T) {
+	ctx := context.Background()
+
+	node := &heldNode{release: make(chan struct{})}
+	var started atomic.Int64
+	server := newTestServer(t, withStorageNodes(1), withNodeHandler(func(_ string, handler http.Handler) http.Handler {
+		node.handler = handler
+		return node
+	}), withAPIHandler(func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				started.Add(1)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 4
+		cfg.SmallFileThreshold = 0
+		cfg.ChunkCacheSize = 0
+		cfg.DownloadPrefetchChunks = 4
+	}))
+	api := server.api
+
+	// Загрузка только сохраняет куски и не ждет release
+	content := strings.Repeat("hot file ", 1000)
+	metadata, err := api.UploadReader(ctx, "hot.txt", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 4)
+
+	// Без кэша кусков одновременные скачивания ждут одного получения каждого куска
+	const downloads = 10
+	var wg sync.WaitGroup
+	results := make([]string, downloads)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, err := api.OpenDownload(ctx, metadata.ID)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer body.Close()
+			data, err := io.ReadAll(body)
+			assert.NoError(t, err)
+			results[i] = string(data)
+		}(i)
+	}
+	require.Eventually(t, func() bool { return started.Load() == downloads }, 5*time.Second, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	close(node.release)
+	wg.Wait()
+
+	for _, result := range results {
+		assert.Equal(t, content, result)
+	}
+	assert.Equal(t, int64(len(metadata.Chunks)), node.reads.Load())
+	assert.Equal(t, &downloadCoalescingStats{
+		ChunkFetches:    int64(len(metadata.Chunks)),
+		ChunksCoalesced: int64((downloads - 1) * len(metadata.Chunks)),
+	}, server.downloads.stats())
+}
@@ -0,0 +1,154 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// errPreconditionFailed возвращается, если текущая версия файла не соответствует
+// условию замены из заголовков If-Match или If-None-Match
+var errPreconditionFailed = errors.New("файл изменился: условие замены не выполнено")
+
+// fileETag возвращает ETag содержимого файла
+func fileETag(metadata *chunking.FileMetadata) string {
+	return "\"" + metadata.Checksum + "\""
+}
+
+// setCacheHeaders передает версию файла и правила кэширования, чтобы обратные прокси и
+// CDN могли хранить файл и проверять его актуальность условными запросами
+func setCacheHeaders(c *gin.Context, settings *runtimeSettings, metadata *chunking.FileMetadata) {
+	c.Header("ETag", fileETag(metadata))
+	if modified := metadata.LastModified(); !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	cacheControl := settings.config.CacheControl
+	if isPublicRequest(c) {
+		cacheControl = settings.config.PublicCacheControl
+	}
+	if cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+}
+
+// notModified сообщает, что у клиента уже есть текущая версия файла, по заголовкам
+// If-None-Match и If-Modified-Since. If-Modified-Since учитывается, только если нет
+// If-None-Match, и сравнивается с точностью до секунды, как передается Last-Modified
+func notModified(c *gin.Context, metadata *chunking.FileMetadata) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		return etagListMatches(header, fileETag(metadata), true)
+	}
+
+	header := c.GetHeader("If-Modified-Since")
+	modified := metadata.LastModified()
+	if header == "" || modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagListMatches проверяет, содержит ли список ETag из заголовка нужный ETag или *.
+// При weak слабые ETag сравниваются без префикса W/, иначе никогда не совпадают
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCondition - условие замены файла: If-Match разрешает замену только указанной
+// версии, If-None-Match: * - только создание нового файла
+type writeCondition struct {
+	ifMatch     string
+	ifNoneMatch string
+}
+
+// newWriteCondition читает условие замены из заголовков запроса
+func newWriteCondition(header http.Header) writeCondition {
+	return writeCondition{
+		ifMatch:     header.Get("If-Match"),
+		ifNoneMatch: header.Get("If-None-Match"),
+	}
+}
+
+// check проверяет условие по текущей версии файла; current равен nil, если файла нет
+func (w writeCondition) check(current *chunking.FileMetadata) error {
+	if w.ifMatch != "" && (current == nil || !etagListMatches(w.ifMatch, fileETag(current), false)) {
+		return errPreconditionFailed
+	}
+	if w.ifNoneMatch != "" && current != nil && etagListMatches(w.ifNoneMatch, fileETag(current), true) {
+		return errPreconditionFailed
+	}
+	return nil
+}
+
+// pathLocks выполняет замены файла по одному пути по очереди, чтобы условие замены
+// проверялось по версии, которая и будет заменена
+type pathLocks struct {
+	mutex sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock - блокировка пути и число ее ожидающих и удерживающих
+type pathLock struct {
+	sync.Mutex
+	users int
+}
+
+// newPathLocks создает пустой набор блокировок путей
+func newPathLocks() *pathLocks {
+	return &pathLocks{locks: make(map[string]*pathLock)}
+}
+
+// lock блокирует путь и возвращает функцию снятия блокировки
+func (l *pathLocks) lock(filePath string) func() {
+	l.mutex.Lock()
+	current := l.locks[filePath]
+	if current == nil {
+		current = &pathLock{}
+		l.locks[filePath] = current
+	}
+	current.users++
+	l.mutex.Unlock()
+
+	current.Lock()
+	return func() {
+		current.Unlock()
+
+		l.mutex.Lock()
+		current.users--
+		if current.users == 0 {
+			delete(l.locks, filePath)
+		}
+		l.mutex.Unlock()
+	}
+}
+
+// downloadFailed отвечает об ошибке сборки файла. Ошибка временная, поэтому ответ
+// не кэшируется, даже если кэширование файла разрешено
+func downloadFailed(c *gin.Context, code apierror.Code, args ...interface{}) {
+	c.Header("Cache-Control", "no-store")
+	writeError(c, http.StatusInternalServerError, code, args...)
+}
@@ -0,0 +1,156 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// readConsistency - сколько копий куска должны совпасть с контрольной суммой из метаданных,
+// прежде чем кусок будет отдан клиенту
+type readConsistency string
+
+const (
+	consistencyOne    readConsistency = "one"    // первая копия, прошедшая проверку
+	consistencyQuorum readConsistency = "quorum" // большинство копий куска
+	consistencyAll    readConsistency = "all"    // все копии куска
+)
+
+// consistencyHeader - заголовок с уровнем согласованности чтения; параметр consistency
+// запроса имеет приоритет
+const consistencyHeader = "X-Read-Consistency"
+
+// errConsistencyNotReached возвращается, если копий с верной контрольной суммой меньше,
+// чем требует уровень согласованности
+var errConsistencyNotReached = errors.New("недостаточно согласованных копий")
+
+// parseReadConsistency разбирает уровень согласованности чтения
+func parseReadConsistency(value string) (readConsistency, bool) {
+	switch level := readConsistency(value); level {
+	case consistencyOne, consistencyQuorum, consistencyAll:
+		return level, true
+	}
+	return "", false
+}
+
+// required возвращает, сколько из copies копий куска должны совпасть
+func (level readConsistency) required(copies int) int {
+	switch level {
+	case consistencyQuorum:
+		return copies/2 + 1
+	case consistencyAll:
+		return copies
+	}
+	return 1
+}
+
+type consistencyContextKey struct{}
+
+// withReadConsistency запоминает в контексте уровень согласованности чтения кусков
+func withReadConsistency(ctx context.Context, level readConsistency) context.Context {
+	return context.WithValue(ctx, consistencyContextKey{}, level)
+}
+
+// contextReadConsistency возвращает уровень согласованности чтения из контекста; без него
+// достаточно одной копии
+func contextReadConsistency(ctx context.Context) readConsistency {
+	level, ok := ctx.Value(consistencyContextKey{}).(readConsistency)
+	if !ok {
+		return consistencyOne
+	}
+	return level
+}
+
+// applyReadConsistency переносит в контекст запроса уровень согласованности из параметра
+// consistency или заголовка X-Read-Consistency, а без них - read_consistency из конфигурации.
+// При неверном уровне отвечает клиенту сам и возвращает false
+func applyReadConsistency(c *gin.Context, settings *runtimeSettings) bool {
+	value := c.Query("consistency")
+	if value == "" {
+		value = c.GetHeader(consistencyHeader)
+	}
+	if value == "" {
+		value = settings.config.ReadConsistency
+	}
+
+	level, ok := parseReadConsistency(value)
+	if !ok {
+		writeError(c, http.StatusBadRequest, apierror.InvalidConsistency, value)
+		return false
+	}
+	c.Request = c.Request.WithContext(withReadConsistency(c.Request.Context(), level))
+	return true
+}
+
+// chunksFailed отвечает клиенту ошибкой получения кусков файла
+func chunksFailed(c *gin.Context, err error) {
+	if errors.Is(err, errConsistencyNotReached) {
+		downloadFailed(c, apierror.ConsistencyNotReached, err)
+		return
+	}
+	downloadFailed(c, apierror.AssembleFailed, err)
+}
+
+// fetchResult - ответ одного сервера хранения при согласованном чтении
+type fetchResult struct {
+	node  string
+	chunk *chunking.FileChunk
+	err   error
+}
+
+// readChunkConsistent запрашивает кусок со всех его серверов одновременно и возвращает
+// его, как только required копий совпали с контрольной суммой из метаданных. Остальные
+// запросы отменяются. Копии с другой контрольной суммой записываются в лог
+func (s *StreamingAPIServer) readChunkConsistent(ctx context.Context, settings *runtimeSettings, chunkMeta chunking.FileChunk, level readConsistency) (*chunking.FileChunk, error) {
+	nodes := chunkMeta.Nodes()
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("в метаданных куска %s не указан сервер хранения", chunkMeta.ID)
+	}
+	required := level.required(len(nodes))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan fetchResult, len(nodes))
+	for _, node := range nodes {
+		go func(node string) {
+			chunk, err := settings.clientForNode(node).GetChunkContext(ctx, chunkMeta.ID)
+			results <- fetchResult{node: node, chunk: chunk, err: err}
+		}(node)
+	}
+
+	var agreed int
+	var agreedChunk *chunking.FileChunk
+	var lastErr error
+	for pending := len(nodes); pending > 0; pending-- {
+		result := <-results
+		switch {
+		case result.err != nil:
+			lastErr = fmt.Errorf("не удалось получить кусок %d с сервера %s: %w", chunkMeta.Index, result.node, result.err)
+			log.Printf("%v", lastErr)
+		case calculateChecksum(chunkMeta.Algorithm, result.chunk.Data) != chunkMeta.Checksum:
+			lastErr = fmt.Errorf("копия куска %d на сервере %s не совпадает с контрольной суммой из метаданных", chunkMeta.Index, result.node)
+			log.Printf("%v", lastErr)
+		default:
+			agreed++
+			agreedChunk = result.chunk
+		}
+
+		if agreed >= required {
+			return agreedChunk, nil
+		}
+		if agreed+pending-1 < required {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("кусок %d: %w (%s): совпали %d из %d копий, нужно %d: %v",
+		chunkMeta.Index, errConsistencyNotReached, level, agreed, len(nodes), required, lastErr)
+}
@@ -0,0 +1,89 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+func TestParseReadConsistency(t *testing.T) {
+	level, ok := parseReadConsistency("quorum")
+	assert.True(t, ok)
+	assert.Equal(t, 2, level.required(3))
+	assert.Equal(t, 3, level.required(4))
+	assert.Equal(t, 1, consistencyOne.required(3))
+	assert.Equal(t, 3, consistencyAll.required(3))
+
+	_, ok = parseReadConsistency("two")
+	assert.False(t, ok)
+}
+
+func TestReadConsistency(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t, withStorageNodes(3), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.ReplicationFactor = 3
+		cfg.ChunkCacheSize = 0
+	}))
+
+	api := server.api
+	metadata, err := api.UploadReader(ctx, "consistent.txt", strings.NewReader("original"), 8)
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 1)
+	chunk := metadata.Chunks[0]
+	require.Len(t, chunk.Replicas, 2)
+
+	// Копия на одном из серверов заменяется другими данными с собственной верной контрольной
+	// суммой: сервер хранения не заметит подмены, а метаданные файла заметят
+	tampered := chunk.Replicas[0]
+	storageClient := server.current().clientForNode(tampered)
+	require.NoError(t, storageClient.DeleteChunkContext(ctx, chunk.ID))
+	data := []byte("tampered")
+	require.NoError(t, storageClient.StoreChunkContext(ctx, &chunking.FileChunk{
+		ID:       chunk.ID,
+		FileID:   metadata.ID,
+		Data:     data,
+		Size:     int64(len(data)),
+		Checksum: calculateChecksum(chunk.Algorithm, data),
+	}))
+
+	download := func(level string) (string, error) {
+		api.SetReadConsistency(level)
+		body, err := api.OpenDownload(ctx, metadata.ID)
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		content, err := io.ReadAll(body)
+		return string(content), err
+	}
+
+	for _, level := range []string{"", client.ReadConsistencyOne, client.ReadConsistencyQuorum} {
+		content, err := download(level)
+		require.NoError(t, err, level)
+		assert.Equal(t, "original", content)
+	}
+
+	_, err = download(client.ReadConsistencyAll)
+	var apiErr *client.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Equal(t, string(apierror.ConsistencyNotReached), apiErr.Code)
+
+	// Неизвестный уровень отклоняется до чтения кусков
+	resp, err := http.Get(server.url + "/api/v1/files/" + metadata.ID + "?consistency=every")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,57 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"TestCase/internal/apierror"
+)
+
+// errContentTypeNotAllowed возвращается, если тип содержимого файла не входит в allowed_content_types
+var errContentTypeNotAllowed = errors.New("тип содержимого файла не разрешен")
+
+// detectContentType определяет MIME тип по первым 512 байтам данных
+// независимо от типа, заявленного клиентом
+func detectContentType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// checkContentType проверяет определенный тип по списку разрешенных. Элемент списка -
+// точный тип (application/pdf) или все подтипы (image/*); пустой список разрешает любые типы
+func checkContentType(allowed []string, detected string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(detected)
+	if err != nil {
+		mediaType = detected
+	}
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType {
+			return nil
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", errContentTypeNotAllowed, mediaType)
+}
+
+// parseContentTypes проверяет и приводит к каноническому виду список разрешенных типов
+// из запроса клиента
+func parseContentTypes(types []string) ([]string, *requestError) {
+	parsed := make([]string, 0, len(types))
+	for _, contentType := range types {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(contentType))
+		if err != nil || strings.Count(mediaType, "/") != 1 || strings.HasPrefix(mediaType, "*") {
+			return nil, newRequestError(http.StatusBadRequest, apierror.InvalidContentType, contentType)
+		}
+		parsed = append(parsed, mediaType)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,71 @@
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMiddleware разрешает обращения к API из браузера с других доменов.
+// Если список разрешенных источников пуст, заголовки CORS не добавляются
+func (s *StreamingAPIServer) corsMiddleware() gin.HandlerFunc {
+	cfg := s.current().config
+	allowedOrigins := make(map[string]bool, len(cfg.CORSAllowedOrigins))
+	allowAll := false
+	for _, origin := range cfg.CORSAllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			allowAll = true
+		}
+		allowedOrigins[origin] = true
+	}
+
+	allowedMethods := strings.Join(cfg.CORSAllowedMethods, ", ")
+	allowedHeaders := strings.Join(cfg.CORSAllowedHeaders, ", ")
+	exposedHeaders := strings.Join(cfg.CORSExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || (!allowAll && !allowedOrigins[origin]) {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		// С учетными данными браузер не принимает "*", поэтому возвращаем конкретный источник
+		if allowAll && !cfg.CORSAllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.CORSAllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if exposedHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", exposedHeaders)
+		}
+
+		// Предварительный запрос браузера обрабатываем сами; обычный OPTIONS (например, WebDAV) пропускаем дальше
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", allowedMethods)
+			if allowedHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowedHeaders)
+			} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			header.Set("Access-Control-Max-Age", maxAge)
+
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,104 @@
+package apiserver
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/internal/dashboard"
+	"TestCase/pkg/chunking"
+)
+
+// dashboardPath - адрес веб-панели администратора
+const dashboardPath = "/dashboard"
+
+// Размер страницы списка файлов панели по умолчанию и наибольший
+const (
+	dashboardDefaultLimit = 50
+	dashboardMaxLimit     = 500
+)
+
+// dashboardFilePage - страница списка файлов панели
+type dashboardFilePage struct {
+	TotalCount int                      `json:"total_count"`
+	Files      []*chunking.FileMetadata `json:"files"`
+}
+
+// setupDashboardRoutes регистрирует страницу панели администратора, если она включена.
+// Страница и скрипт отдаются без проверки ключа API: данные панель запрашивает из /api/v1
+func (s *StreamingAPIServer) setupDashboardRoutes(router *gin.Engine) {
+	if !s.current().config.DashboardEnabled {
+		return
+	}
+
+	assets := http.StripPrefix(dashboardPath, http.FileServer(http.FS(dashboard.Assets())))
+	router.GET(dashboardPath, func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, dashboardPath+"/")
+	})
+	router.GET(dashboardPath+"/*filepath", gin.WrapH(assets))
+}
+
+// listDashboardFiles возвращает страницу метаданных файлов, доступных клиенту, от новых
+// к старым. Параметр prefix ограничивает список логическими путями с этим префиксом.
+// В отличие от GET /files, отдающего только идентификаторы, ответ сразу содержит
+// метаданные для таблиц панели
+func (s *StreamingAPIServer) listDashboardFiles(c *gin.Context) {
+	limit, offset, ok := pageParams(c, dashboardDefaultLimit, dashboardMaxLimit)
+	if !ok {
+		return
+	}
+
+	var all []*chunking.FileMetadata
+	var err error
+	if prefix, ok := c.GetQuery("prefix"); ok {
+		all, err = s.filesWithPathPrefix(c.Request.Context(), prefix)
+	} else {
+		all, err = s.catalog.List(c.Request.Context())
+	}
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	var files []*chunking.FileMetadata
+	for _, metadata := range all {
+		if settings.canAccess(principal, metadata, accessRead) {
+			files = append(files, metadata)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].CreatedAt.After(files[j].CreatedAt)
+	})
+
+	page := &dashboardFilePage{TotalCount: len(files), Files: []*chunking.FileMetadata{}}
+	offset = min(offset, len(files))
+	for _, metadata := range files[offset:min(offset+limit, len(files))] {
+		page.Files = append(page.Files, s.access.merged(metadata))
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// pageParams читает параметры limit и offset. limit больше maxLimit уменьшается до него.
+// При неверных значениях отвечает клиенту сам и возвращает false
+func pageParams(c *gin.Context, defaultLimit, maxLimit int) (int, int, bool) {
+	limit, offset := defaultLimit, 0
+	var err error
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			writeError(c, http.StatusBadRequest, apierror.InvalidLimit)
+			return 0, 0, false
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			writeError(c, http.StatusBadRequest, apierror.InvalidPageOffset)
+			return 0, 0, false
+		}
+	}
+	return min(limit, maxLimit), offset, true
+}
@@ -0,0 +1,121 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+// newSingleNodeTestServer запускает API сервер с одним сервером хранения в памяти и
+// ключами API клиентов alice и bob; configure, если задана, меняет конфигурацию
+func newSingleNodeTestServer(t *testing.T, configure func(cfg *config.Config)) string {
+	return newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.APIKeys = []string{"alice:alice-key", "bob:bob-key"}
+	}), withConfig(configure)).url
+}
+
+func getDashboardFiles(t *testing.T, baseURL, apiKey, query string) (int, dashboardFilePage) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/v1/dashboard/files?"+query, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var page dashboardFilePage
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	}
+	return resp.StatusCode, page
+}
+
+func TestDashboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	baseURL := newSingleNodeTestServer(t, func(cfg *config.Config) { cfg.DashboardEnabled = true })
+
+	// Страница отдается без ключа API, /dashboard перенаправляет на каталог панели
+	resp, err := http.Get(baseURL + dashboardPath + "/")
+	require.NoError(t, err)
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Contains(t, string(page), "app.js")
+
+	resp, err = http.Get(baseURL + dashboardPath + "/app.js")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = noRedirect.Get(baseURL + dashboardPath)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, dashboardPath+"/", resp.Header.Get("Location"))
+
+	ctx := context.Background()
+	alice := client.NewAPIClient(baseURL)
+	alice.SetAPIKey("alice-key")
+	var uploaded []string
+	for i := 0; i < 3; i++ {
+		content := fmt.Sprintf("report %d", i)
+		metadata, err := alice.UploadReader(ctx, fmt.Sprintf("report-%d.txt", i), strings.NewReader(content),
+			int64(len(content)), client.WithPath(fmt.Sprintf("reports/report-%d.txt", i)))
+		require.NoError(t, err)
+		uploaded = append(uploaded, metadata.ID)
+	}
+	bob := client.NewAPIClient(baseURL)
+	bob.SetAPIKey("bob-key")
+	_, err = bob.UploadReader(ctx, "notes.txt", strings.NewReader("notes"), 5)
+	require.NoError(t, err)
+
+	// Файлы других клиентов не видны, новые идут первыми
+	status, files := getDashboardFiles(t, baseURL, "alice-key", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, files.TotalCount)
+	require.Len(t, files.Files, 3)
+	assert.Equal(t, []string{uploaded[2], uploaded[1], uploaded[0]}, []string{files.Files[0].ID, files.Files[1].ID, files.Files[2].ID})
+
+	status, files = getDashboardFiles(t, baseURL, "alice-key", "prefix=reports/&limit=1&offset=1")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, files.TotalCount)
+	require.Len(t, files.Files, 1)
+	assert.Equal(t, uploaded[1], files.Files[0].ID)
+
+	status, files = getDashboardFiles(t, baseURL, "alice-key", "offset=10")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, files.TotalCount)
+	assert.Empty(t, files.Files)
+
+	status, _ = getDashboardFiles(t, baseURL, "alice-key", "limit=-1")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = getDashboardFiles(t, baseURL, "alice-key", "offset=x")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestDashboardDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	baseURL := newSingleNodeTestServer(t, nil)
+
+	resp, err := http.Get(baseURL + dashboardPath + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	status, _ := getDashboardFiles(t, baseURL, "alice-key", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
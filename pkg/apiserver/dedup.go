@@ -0,0 +1,153 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+)
+
+// errNoDuplicate возвращается, если содержимое файла не удалось найти среди уже хранящихся
+var errNoDuplicate = errors.New("файл с таким содержимым не найден")
+
+// dedupRequest - создание файла из уже хранящегося в кластере содержимого
+type dedupRequest struct {
+	SHA256      string `json:"sha256" binding:"required"` // SHA256 содержимого файла
+	Size        *int64 `json:"size"`                      // размер файла; если указан, должен совпасть
+	Name        string `json:"name" binding:"required"`
+	ContentType string `json:"content_type"`
+	Path        string `json:"path"`
+	Public      bool   `json:"public"`
+}
+
+// uploadDuplicate создает файл, не передавая данных, если такое же содержимое уже хранится
+// в доступном клиенту файле: новые метаданные ссылаются на его куски. Если содержимого нет,
+// отвечает 404, и клиент загружает файл обычным способом
+func (s *StreamingAPIServer) uploadDuplicate(c *gin.Context) {
+	var request dedupRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+	checksum, ok := parseSHA256(request.SHA256)
+	if !ok {
+		writeError(c, http.StatusBadRequest, apierror.InvalidChecksum)
+		return
+	}
+
+	info := uploadInfo{
+		Name:        request.Name,
+		ContentType: request.ContentType,
+		Path:        cleanFilePath(request.Path),
+		Public:      request.Public,
+		Owner:       c.GetString(principalKey),
+	}
+
+	metadata, err := s.storeDuplicate(c.Request.Context(), info, checksum, request.Size)
+	if errors.Is(err, errNoDuplicate) {
+		writeError(c, http.StatusNotFound, apierror.DuplicateNotFound)
+		return
+	}
+	if err != nil {
+		writeStoreError(c, apierror.StoreFailed, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
+
+// storeDuplicate сохраняет метаданные нового файла, ссылающиеся на куски доступного
+// владельцу файла с тем же содержимым. Проверки имени и типа содержимого те же, что при загрузке.
+// Куски принадлежат новому файлу так же, как источнику: их удерживает только число ссылок
+func (s *StreamingAPIServer) storeDuplicate(ctx context.Context, info uploadInfo, checksum string, size *int64) (*chunking.FileMetadata, error) {
+	settings := s.current()
+	if err := settings.checkFilename(info.Name); err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.filesWithSHA256(ctx, info.Owner, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог: %w", err)
+	}
+
+	for _, source := range candidates {
+		if size != nil && source.Size != *size {
+			continue
+		}
+		if err := checkContentType(settings.config.AllowedContentTypes, source.DetectedContentType); err != nil {
+			return nil, err
+		}
+
+		metadata := &chunking.FileMetadata{
+			ID:           settings.fileIDScheme.NewID(),
+			OriginalName: info.Name,
+			Size:         source.Size,
+			Checksum:     source.Checksum,
+			ContentType:  info.ContentType,
+			ChunkCount:   source.ChunkCount,
+			Chunks:       slices.Clone(source.Chunks),
+
+			ChecksumAlgorithm:   source.ChecksumAlgorithm,
+			DetectedContentType: source.DetectedContentType,
+			Path:                info.Path,
+			CreatedAt:           time.Now().UTC(),
+			Public:              info.Public,
+			Owner:               info.Owner,
+		}
+		if metadata.ContentType == "" {
+			metadata.ContentType = source.ContentType
+		}
+		for i := range metadata.Chunks {
+			metadata.Chunks[i].FileID = metadata.ID
+			metadata.Chunks[i].Replicas = slices.Clone(source.Chunks[i].Replicas)
+		}
+
+		// Файл-источник мог быть удален или его сервер недоступен: тогда пробуем следующий
+		if err := s.linkChunks(ctx, settings, metadata.Chunks); err != nil {
+			log.Printf("Не удалось сослаться на куски файла %s: %v", source.ID, err)
+			continue
+		}
+		if err := s.putMetadata(ctx, metadata); err != nil {
+			s.deleteChunks(ctx, metadata)
+			return nil, fmt.Errorf("не удалось сохранить метаданные: %w", err)
+		}
+
+		s.events.Publish(events.NewFileEvent(events.FileUploaded, metadata))
+		recordAuditFile(ctx, metadata)
+		return metadata, nil
+	}
+
+	return nil, errNoDuplicate
+}
+
+// linkChunks добавляет ссылку нового файла на каждую копию кусков. Если ссылку на
+// какую-то копию добавить не удалось, уже добавленные ссылки снимаются
+func (s *StreamingAPIServer) linkChunks(ctx context.Context, settings *runtimeSettings, chunks []chunking.FileChunk) error {
+	type linkedCopy struct{ chunkID, node string }
+	var linked []linkedCopy
+
+	for _, chunk := range chunks {
+		for _, node := range chunk.Nodes() {
+			if _, err := settings.clientForNode(node).IncrementRefContext(ctx, chunk.ID); err != nil {
+				for _, link := range linked {
+					if _, releaseErr := settings.clientForNode(link.node).DecrementRefContext(context.WithoutCancel(ctx), link.chunkID); releaseErr != nil {
+						log.Printf("Не удалось снять ссылку на кусок %s с сервера %s: %v", link.chunkID, link.node, releaseErr)
+					}
+				}
+				return fmt.Errorf("кусок %s на сервере %s: %w", chunk.ID, node, err)
+			}
+			linked = append(linked, linkedCopy{chunkID: chunk.ID, node: node})
+		}
+	}
+	return nil
+}
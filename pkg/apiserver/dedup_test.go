@@ -0,0 +1,48 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+)
+
+func TestDuplicateOwnsChunks(t *testing.T) {
+	server := newTestServer(t, withStorageNodes(2))
+	ctx := context.Background()
+
+	content := strings.Repeat("dedup", 1000)
+	source, err := server.api.UploadReader(ctx, "source.txt", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(content))
+	size := int64(len(content))
+	duplicate, err := server.api.UploadDuplicate(client.DedupRequest{SHA256: hex.EncodeToString(sum[:]), Size: &size, Name: "copy.txt"})
+	require.NoError(t, err)
+	require.NotEqual(t, source.ID, duplicate.ID)
+
+	// Куски дубликата записаны на новый файл, а не на источник
+	for _, chunk := range duplicate.Chunks {
+		assert.Equal(t, duplicate.ID, chunk.FileID)
+	}
+	stored, err := server.api.GetFileInfoContext(ctx, duplicate.ID)
+	require.NoError(t, err)
+	assert.NoError(t, chunking.ValidateFileMetadata(stored))
+
+	// После удаления источника куски удерживает ссылка дубликата
+	require.NoError(t, server.api.DeleteFile(source.ID))
+	body, err := server.api.OpenDownload(ctx, duplicate.ID)
+	require.NoError(t, err)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
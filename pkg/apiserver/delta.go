@@ -0,0 +1,249 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+)
+
+// errInvalidDelta возвращается, если описание новой версии файла не согласуется с базовым файлом
+var errInvalidDelta = errors.New("неверное описание изменений")
+
+// deltaRequest - описание новой версии файла из поля delta формы загрузки
+type deltaRequest struct {
+	Name        string                  `json:"name"`         // по умолчанию имя базового файла
+	ContentType string                  `json:"content_type"` // по умолчанию тип базового файла
+	Path        string                  `json:"path"`
+	Public      bool                    `json:"public"`
+	Segments    []chunking.DeltaSegment `json:"segments"` // части новой версии по порядку
+}
+
+// getFileSignature возвращает контрольные суммы кусков файла, по которым клиент
+// определяет, какие данные новой версии файла нужно передать
+func (s *StreamingAPIServer) getFileSignature(c *gin.Context) {
+	metadata, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, chunking.NewSignature(metadata))
+}
+
+// uploadDelta создает новую версию файла из неизмененных кусков базового файла и
+// переданных клиентом новых данных. Поле file формы содержит новые данные всех
+// сегментов подряд, поле delta - описание версии. Базовый файл не изменяется
+func (s *StreamingAPIServer) uploadDelta(c *gin.Context) {
+	base, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+	settings := s.current()
+	maxFileSize := settings.config.MaxFileSize
+	policy, policyErr := s.parseUploadPolicy(c, settings)
+	if policyErr != nil {
+		writeRequestError(c, policyErr)
+		return
+	}
+
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	newData, _, ok := readFormFile(c, spool, maxFileSize)
+	if !ok {
+		return
+	}
+
+	var request deltaRequest
+	if err := json.Unmarshal([]byte(c.PostForm("delta")), &request); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidDelta, err)
+		return
+	}
+	size, err := deltaSize(base, request.Segments, int64(len(newData)))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidDelta, err)
+		return
+	}
+	if size > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return
+	}
+
+	info := uploadInfo{
+		Name:        request.Name,
+		ContentType: request.ContentType,
+		Path:        cleanFilePath(request.Path),
+		Public:      request.Public,
+		Owner:       c.GetString(principalKey),
+		Policy:      policy,
+	}
+	if info.Name == "" {
+		info.Name = base.OriginalName
+	}
+	if info.ContentType == "" {
+		info.ContentType = base.ContentType
+	}
+
+	metadata, err := s.storeDelta(c.Request.Context(), base, request.Segments, newData, size, info)
+	if err != nil {
+		writeStoreError(c, apierror.StoreFailed, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
+
+// deltaSize проверяет сегменты новой версии и возвращает ее размер
+func deltaSize(base *chunking.FileMetadata, segments []chunking.DeltaSegment, newSize int64) (int64, error) {
+	if len(segments) == 0 {
+		return 0, fmt.Errorf("%w: нет сегментов", errInvalidDelta)
+	}
+
+	var size, sent int64
+	for i, segment := range segments {
+		if segment.Base != nil {
+			if *segment.Base < 0 || *segment.Base >= len(base.Chunks) {
+				return 0, fmt.Errorf("%w: сегмент %d ссылается на несуществующий кусок %d", errInvalidDelta, i, *segment.Base)
+			}
+			size += base.Chunks[*segment.Base].Size
+			continue
+		}
+		if segment.Size <= 0 {
+			return 0, fmt.Errorf("%w: сегмент %d без куска базового файла должен иметь положительный размер", errInvalidDelta, i)
+		}
+		size += segment.Size
+		sent += segment.Size
+	}
+
+	if sent != newSize {
+		return 0, fmt.Errorf("%w: передано %d байт новых данных, а сегменты описывают %d", errInvalidDelta, newSize, sent)
+	}
+	return size, nil
+}
+
+// storeDelta собирает новую версию файла. Неизмененные куски не передаются на серверы
+// хранения повторно: новая версия ссылается на них, как файлы с одинаковым содержимым.
+// Файл целиком собирается в памяти, чтобы проверить его так же, как при обычной загрузке
+func (s *StreamingAPIServer) storeDelta(ctx context.Context, base *chunking.FileMetadata, segments []chunking.DeltaSegment, newData []byte, size int64, info uploadInfo) (*chunking.FileMetadata, error) {
+	settings := s.current()
+	fileID := settings.fileIDScheme.NewID()
+
+	// Данные неизмененных кусков нужны только для проверки и контрольной суммы файла
+	var baseIndexes []int
+	for _, segment := range segments {
+		if segment.Base != nil && !slices.Contains(baseIndexes, *segment.Base) {
+			baseIndexes = append(baseIndexes, *segment.Base)
+		}
+	}
+	baseMetas := make([]chunking.FileChunk, len(baseIndexes))
+	for i, index := range baseIndexes {
+		baseMetas[i] = base.Chunks[index]
+	}
+	baseChunks, err := s.collectChunks(ctx, baseMetas)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить куски базового файла: %w", err)
+	}
+
+	// Новые данные делятся на куски не больше, чем при обычной загрузке файла такого размера
+	chunkCount := int64(s.chunkCount(settings, size, info.Policy))
+	maxChunkSize := max((size+chunkCount-1)/chunkCount, 1)
+
+	fileData := make([]byte, 0, size)
+	var chunks, fresh, reused []chunking.FileChunk
+	var offset int64
+	for _, segment := range segments {
+		if segment.Base != nil {
+			chunk := base.Chunks[*segment.Base]
+			chunk.Index = len(chunks)
+			chunk.Replicas = slices.Clone(chunk.Replicas)
+			chunks = append(chunks, chunk)
+			reused = append(reused, chunk)
+			fileData = append(fileData, baseChunks[slices.Index(baseIndexes, *segment.Base)].Data...)
+			continue
+		}
+
+		for end := offset + segment.Size; offset < end; {
+			part := newData[offset:min(offset+maxChunkSize, end)]
+			chunk := chunking.FileChunk{
+				ID:       chunking.NewChunkID(),
+				FileID:   fileID,
+				Index:    len(chunks),
+				Data:     part,
+				Checksum: calculateChecksum(settings.hashAlgorithm, part),
+				Size:     int64(len(part)),
+
+				Algorithm: settings.hashAlgorithm,
+			}
+			chunks = append(chunks, chunk)
+			fresh = append(fresh, chunk)
+			fileData = append(fileData, part...)
+			offset += chunk.Size
+		}
+	}
+
+	detectedType, err := s.checkFile(ctx, settings, info, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	// На серверы хранения передаются только новые куски
+	if err := s.placeChunks(settings, fresh, info.Policy.replicationFactor(settings)); err != nil {
+		return nil, err
+	}
+	if err := s.distributeChunks(ctx, fresh); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить куски: %w", err)
+	}
+	if err := s.linkChunks(ctx, settings, reused); err != nil {
+		s.deleteChunks(ctx, &chunking.FileMetadata{Chunks: fresh})
+		return nil, fmt.Errorf("не удалось сослаться на куски базового файла: %w", err)
+	}
+
+	for i, next := 0, 0; i < len(chunks); i++ {
+		chunks[i].Data = nil
+		if next < len(fresh) && chunks[i].ID == fresh[next].ID {
+			chunks[i].Node = fresh[next].Node
+			chunks[i].Replicas = fresh[next].Replicas
+			next++
+		}
+	}
+
+	metadata := &chunking.FileMetadata{
+		ID:           fileID,
+		OriginalName: info.Name,
+		Size:         size,
+		Checksum:     calculateChecksum(settings.hashAlgorithm, fileData),
+		ContentType:  info.ContentType,
+		ChunkCount:   len(chunks),
+		Chunks:       chunks,
+
+		ChecksumAlgorithm:   settings.hashAlgorithm,
+		ReplicationFactor:   info.Policy.ReplicationFactor,
+		StorageClass:        info.Policy.StorageClass,
+		DetectedContentType: detectedType,
+		Path:                info.Path,
+		CreatedAt:           time.Now().UTC(),
+		Public:              info.Public,
+		Owner:               info.Owner,
+	}
+
+	// Метаданные ссылаются на новые и неизмененные куски, поэтому при ошибке снимаются все ссылки
+	if err := s.putMetadata(ctx, metadata); err != nil {
+		s.deleteChunks(ctx, metadata)
+		return nil, fmt.Errorf("не удалось сохранить метаданные: %w", err)
+	}
+	s.repairMissingCopies(metadata.Chunks, info.Policy.replicationFactor(settings))
+
+	s.events.Publish(events.NewFileEvent(events.FileUploaded, metadata))
+	recordAuditFile(ctx, metadata)
+
+	return metadata, nil
+}
@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/pkg/chunking"
+)
+
+// digestSHA256 - обозначение SHA256 в заголовках Digest и Want-Digest (RFC 3230, RFC 5843)
+const digestSHA256 = "sha-256"
+
+// wantsSHA256Digest разбирает заголовок Want-Digest вида "SHA-256;q=1, MD5;q=0.3".
+// Первое значение сообщает, можно ли отправить SHA-256: без заголовка сервер отправляет его
+// сам, а с заголовком - только если SHA-256 в нем есть и не отклонен q=0. Второе значение
+// сообщает, что клиент запросил SHA-256 явно
+func wantsSHA256Digest(header string) (bool, bool) {
+	if strings.TrimSpace(header) == "" {
+		return true, false
+	}
+
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), digestSHA256) {
+			continue
+		}
+		key, value, _ := strings.Cut(params, "=")
+		if strings.TrimSpace(key) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q <= 0 {
+				return false, false
+			}
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// digestValue записывает SHA256 в формате заголовка Digest: имя алгоритма и base64 суммы
+func digestValue(sum []byte) string {
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// setDigestHeader добавляет заголовок Digest с SHA256 содержимого файла, если клиент его
+// не отклонил в Want-Digest. Сумма берется из метаданных и известна только для файлов,
+// загруженных с checksum_algorithm sha256
+func setDigestHeader(c *gin.Context, metadata *chunking.FileMetadata) {
+	if ok, _ := wantsSHA256Digest(c.GetHeader("Want-Digest")); !ok {
+		return
+	}
+	algorithm := metadata.ChecksumAlgorithm
+	if algorithm == "" {
+		algorithm = chunking.DefaultHashAlgorithm
+	}
+	if algorithm != chunking.HashSHA256 {
+		return
+	}
+	sum, err := hex.DecodeString(metadata.Checksum)
+	if err != nil {
+		return
+	}
+	c.Header("Digest", digestValue(sum))
+}
+
+// needsComputedDigest сообщает, что клиент явно запросил SHA256 в Want-Digest, а в
+// метаданных сумма хранится другим алгоритмом, и ее нужно вычислить по содержимому
+func needsComputedDigest(c *gin.Context) bool {
+	if c.Writer.Header().Get("Digest") != "" {
+		return false
+	}
+	_, requested := wantsSHA256Digest(c.GetHeader("Want-Digest"))
+	return requested
+}
+
+// setComputedDigest вычисляет SHA256 собранного файла, если клиент явно запросил его в
+// Want-Digest, а в метаданных сумма хранится другим алгоритмом
+func setComputedDigest(c *gin.Context, data []byte) {
+	if !needsComputedDigest(c) {
+		return
+	}
+	sum := sha256.Sum256(data)
+	c.Header("Digest", digestValue(sum[:]))
+}
@@ -0,0 +1,28 @@
+package apiserver
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apidocs"
+)
+
+// openAPISpecPath - адрес спецификации OpenAPI
+const openAPISpecPath = "/api/v1/openapi.json"
+
+// setupDocsRoutes регистрирует спецификацию OpenAPI и, если включено, страницу Swagger UI
+func (s *StreamingAPIServer) setupDocsRoutes(router *gin.Engine) {
+	router.GET(openAPISpecPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", apidocs.Spec)
+	})
+
+	if !s.current().config.DocsEnabled {
+		return
+	}
+
+	page := apidocs.SwaggerUIPage(openAPISpecPath)
+	router.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	})
+}
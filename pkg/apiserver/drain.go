@@ -0,0 +1,324 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+)
+
+const (
+	// maxDrainPasses ограничивает повторные проходы по каталогу: загрузки, начатые
+	// до вывода сервера, могут сохранить на нем куски уже после первого прохода
+	maxDrainPasses = 3
+
+	// maxDrainErrors - сколько последних ошибок переноса показывается в ходе вывода
+	maxDrainErrors = 20
+)
+
+// chunkReference - кусок файла на выводимом сервере
+type chunkReference struct {
+	fileID string
+	index  int
+}
+
+// startDrain запускает вывод сервера хранения: сервер перестает получать новые куски,
+// а уже сохраненные переносятся на остальные серверы
+func (s *StreamingAPIServer) startDrain(c *gin.Context) {
+	node := c.Param("id")
+	if !s.knownNode(node) {
+		writeError(c, http.StatusNotFound, apierror.NodeNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := false
+	s.nodes.update(node, func(status *nodeStatus) {
+		if status.State == nodeDraining || status.State == nodeDrained {
+			return
+		}
+		status.State = nodeDraining
+		status.Drain = &drainProgress{StartedAt: time.Now().UTC()}
+		status.cancel = cancel
+		started = true
+	})
+	if !started {
+		cancel()
+		writeError(c, http.StatusConflict, apierror.NodeAlreadyDraining)
+		return
+	}
+
+	go s.drainNode(ctx, cancel, node)
+
+	log.Printf("Начат вывод сервера хранения %s", node)
+	c.JSON(http.StatusAccepted, gin.H{"node": node, "state": nodeDraining})
+}
+
+// cancelDrain останавливает вывод сервера и возвращает его в работу.
+// Уже перенесенные куски остаются на новых серверах
+func (s *StreamingAPIServer) cancelDrain(c *gin.Context) {
+	node := c.Param("id")
+	if !s.knownNode(node) {
+		writeError(c, http.StatusNotFound, apierror.NodeNotFound)
+		return
+	}
+
+	s.nodes.update(node, func(status *nodeStatus) {
+		if status.cancel != nil {
+			status.cancel()
+			status.cancel = nil
+		}
+		status.State = nodeActive
+	})
+
+	log.Printf("Сервер хранения %s возвращен в работу", node)
+	c.JSON(http.StatusOK, gin.H{"node": node, "state": nodeActive})
+}
+
+// drainNode переносит все куски с сервера и отмечает его выведенным
+func (s *StreamingAPIServer) drainNode(ctx context.Context, cancel context.CancelFunc, node string) {
+	defer cancel()
+
+	// Остановка API сервера прерывает перенос
+	go func() {
+		select {
+		case <-s.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	remaining := 0
+	for pass := 0; pass < maxDrainPasses && ctx.Err() == nil; pass++ {
+		chunks, err := s.chunksOnNode(ctx, node)
+		if err != nil {
+			s.recordDrainError(node, fmt.Errorf("не удалось прочитать каталог: %w", err), false)
+			break
+		}
+
+		remaining = len(chunks)
+		if remaining == 0 {
+			break
+		}
+		if pass == 0 {
+			s.nodes.update(node, func(status *nodeStatus) { status.Drain.TotalChunks = len(chunks) })
+		} else {
+			s.nodes.update(node, func(status *nodeStatus) { status.Drain.TotalChunks += len(chunks) })
+		}
+
+		for chunkID, references := range chunks {
+			if ctx.Err() != nil {
+				break
+			}
+
+			size, err := s.migrateChunk(ctx, node, chunkID, references)
+			if err != nil {
+				s.recordDrainError(node, fmt.Errorf("кусок %s: %w", chunkID, err), true)
+				continue
+			}
+			s.nodes.update(node, func(status *nodeStatus) {
+				status.Drain.MovedChunks++
+				status.Drain.MovedBytes += size
+			})
+		}
+	}
+
+	// Окончательный результат определяет повторная проверка каталога
+	if ctx.Err() == nil {
+		if chunks, err := s.chunksOnNode(ctx, node); err == nil {
+			remaining = len(chunks)
+		}
+	}
+
+	s.nodes.update(node, func(status *nodeStatus) {
+		if status.State != nodeDraining || ctx.Err() != nil {
+			return // вывод отменен
+		}
+
+		finished := time.Now().UTC()
+		status.Drain.FinishedAt = &finished
+		status.cancel = nil
+		if remaining == 0 {
+			status.State = nodeDrained
+		} else {
+			status.State = nodeFailed
+		}
+	})
+
+	if ctx.Err() != nil {
+		log.Printf("Вывод сервера хранения %s прерван", node)
+	} else if remaining == 0 {
+		log.Printf("Сервер хранения %s выведен, его можно выключить", node)
+	} else {
+		log.Printf("Вывод сервера хранения %s завершен с ошибками: осталось кусков %d", node, remaining)
+	}
+}
+
+// chunksOnNode находит в каталоге куски и копии кусков, хранящиеся на сервере,
+// сгруппированные по идентификатору
+func (s *StreamingAPIServer) chunksOnNode(ctx context.Context, node string) (map[string][]chunkReference, error) {
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(map[string][]chunkReference)
+	for _, metadata := range files {
+		for i, chunk := range metadata.Chunks {
+			if slices.Contains(chunk.Nodes(), node) {
+				chunks[chunk.ID] = append(chunks[chunk.ID], chunkReference{fileID: metadata.ID, index: i})
+			}
+		}
+	}
+	return chunks, nil
+}
+
+// migrateChunk выбирает для куска новый сервер и переносит кусок на него.
+// Возвращает размер перенесенного куска
+func (s *StreamingAPIServer) migrateChunk(ctx context.Context, node, chunkID string, references []chunkReference) (int64, error) {
+	settings := s.current()
+	if settings.findClient(node) == nil {
+		return 0, errors.New("сервер вышел из состава")
+	}
+
+	// Новый сервер выбирается так же, как для новых кусков, но по возможности
+	// не среди серверов, где уже лежат другие куски тех же файлов. Серверы с
+	// копиями этого куска не подходят: две копии на одном сервере бесполезны
+	var size int64
+	occupied := make(map[string]bool)
+	holding := make(map[string]bool)
+	for _, reference := range references {
+		if metadata, err := s.catalog.Get(ctx, reference.fileID); err == nil {
+			for _, other := range metadata.Chunks {
+				for _, otherNode := range other.Nodes() {
+					occupied[otherNode] = true
+					if other.ID == chunkID {
+						holding[otherNode] = true
+					}
+				}
+				if other.ID == chunkID {
+					size = other.Size
+				}
+			}
+		}
+	}
+	var candidates, preferred []string
+	for _, candidate := range s.nodes.schedulable(settings.config.StorageServers) {
+		if holding[candidate] {
+			continue
+		}
+		candidates = append(candidates, candidate)
+		if !occupied[candidate] {
+			preferred = append(preferred, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, errNoStorageServers
+	}
+	if len(preferred) > 0 {
+		candidates = preferred
+	}
+
+	placed := []chunking.FileChunk{{ID: chunkID, Size: size}}
+	if err := s.capacity.place(candidates, placed); err != nil {
+		return 0, err
+	}
+	return s.moveChunk(ctx, settings, node, placed[0].Node, chunkID, references)
+}
+
+// moveChunk передает кусок с сервера node на targetNode напрямую между серверами хранения,
+// переключает на него ссылающиеся файлы и снимает их ссылки со старого сервера.
+// Возвращает размер перенесенного куска
+func (s *StreamingAPIServer) moveChunk(ctx context.Context, settings *runtimeSettings, node, targetNode, chunkID string, references []chunkReference) (int64, error) {
+	source := settings.findClient(node)
+	if source == nil {
+		return 0, errors.New("сервер вышел из состава")
+	}
+	target := settings.clientForNode(targetNode)
+
+	// Старый сервер сохраняет свою копию, пока файлы не переключены на новый
+	result, err := source.MigrateChunkContext(ctx, chunkID, targetNode, true)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось передать на сервер %s: %w", targetNode, err)
+	}
+	// Вместе с куском передаются все его ссылки. Ссылки, которых нет среди переносимых
+	// файлов (например, от еще не завершенной загрузки), остаются только на старом сервере
+	for i := len(references); i < result.RefCount; i++ {
+		if _, err := target.DecrementRefContext(ctx, chunkID); err != nil {
+			log.Printf("Не удалось снять лишнюю ссылку на кусок %s с сервера %s: %v", chunkID, targetNode, err)
+		}
+	}
+
+	for _, reference := range references {
+		if err := s.switchChunkNode(ctx, reference, chunkID, node, targetNode); err != nil {
+			// Файл остался на старом сервере: снимаем лишнюю ссылку с нового
+			if _, releaseErr := target.DecrementRefContext(ctx, chunkID); releaseErr != nil {
+				log.Printf("Не удалось снять ссылку на кусок %s с сервера %s: %v", chunkID, targetNode, releaseErr)
+			}
+			if errors.Is(err, catalog.ErrNotFound) {
+				continue // файл удален во время переноса
+			}
+			return 0, err
+		}
+
+		if err := source.DeleteChunkContext(ctx, chunkID); err != nil {
+			log.Printf("Не удалось снять ссылку на кусок %s со старого сервера %s: %v", chunkID, node, err)
+		}
+	}
+
+	log.Printf("Кусок %s перенесен с сервера %s на %s", chunkID, node, targetNode)
+	return result.Size, nil
+}
+
+// switchChunkNode записывает в метаданные файла новый сервер куска вместо node
+func (s *StreamingAPIServer) switchChunkNode(ctx context.Context, reference chunkReference, chunkID, node, targetNode string) error {
+	return s.updateChunk(ctx, reference, chunkID, func(chunk *chunking.FileChunk) {
+		if chunk.Node == node {
+			chunk.Node = targetNode
+			return
+		}
+		chunk.Replicas = slices.Clone(chunk.Replicas)
+		if i := slices.Index(chunk.Replicas, node); i >= 0 {
+			chunk.Replicas[i] = targetNode
+		}
+	})
+}
+
+// updateChunk изменяет описание куска в метаданных файла
+func (s *StreamingAPIServer) updateChunk(ctx context.Context, reference chunkReference, chunkID string, update func(chunk *chunking.FileChunk)) error {
+	_, err := s.updateFile(ctx, reference.fileID, func(metadata *chunking.FileMetadata) error {
+		if reference.index >= len(metadata.Chunks) || metadata.Chunks[reference.index].ID != chunkID {
+			return catalog.ErrNotFound // файл заменен новой версией
+		}
+		metadata.Chunks = append([]chunking.FileChunk(nil), metadata.Chunks...)
+		update(&metadata.Chunks[reference.index])
+		return nil
+	})
+	return err
+}
+
+// recordDrainError запоминает ошибку в ходе вывода сервера; chunkFailed - не удалось перенести кусок
+func (s *StreamingAPIServer) recordDrainError(node string, err error, chunkFailed bool) {
+	log.Printf("Вывод сервера хранения %s: %v", node, err)
+	s.nodes.update(node, func(status *nodeStatus) {
+		if status.Drain == nil {
+			return
+		}
+		if chunkFailed {
+			status.Drain.FailedChunks++
+		}
+		status.Drain.Errors = append(status.Drain.Errors, err.Error())
+		if len(status.Drain.Errors) > maxDrainErrors {
+			status.Drain.Errors = status.Drain.Errors[len(status.Drain.Errors)-maxDrainErrors:]
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/token"
+)
+
+const (
+	// dropBoxPurpose - назначение токена ящика для приема файлов
+	dropBoxPurpose = "drop-box"
+
+	// dropBoxRoute - маршрут ящика для приема файлов без ключа API
+	dropBoxRoute = "/api/v1/drop/:token"
+)
+
+var (
+	// errDropBoxFull возвращается, если в ящик уже загружено max_files файлов
+	errDropBoxFull = errors.New("в ящик загружено наибольшее число файлов")
+
+	// errDropBoxQuota возвращается, если файл не помещается в max_total_size ящика
+	errDropBoxQuota = errors.New("файл превышает оставшийся объем ящика")
+)
+
+// dropBoxRequest - запрос ящика для приема файлов; все поля необязательны
+type dropBoxRequest struct {
+	ExpiresIn    int64    `json:"expires_in"`     // срок действия в секундах; 0 - drop_box_max_ttl
+	MaxFileSize  int64    `json:"max_file_size"`  // наибольший размер одного файла; 0 - max_file_size
+	MaxTotalSize int64    `json:"max_total_size"` // наибольший суммарный размер файлов; 0 - без ограничения
+	MaxFiles     int      `json:"max_files"`      // наибольшее число файлов; 0 - без ограничения
+	ContentTypes []string `json:"content_types"`  // разрешенные типы содержимого (тип/подтип или тип/*)
+}
+
+// dropBoxClaims - данные токена ящика. Ящик нигде не хранится: его ограничения
+// подписаны в токене, а загруженные файлы отмечены идентификатором ящика
+type dropBoxClaims struct {
+	ID           string    `json:"id"`
+	Owner        string    `json:"owner,omitempty"` // клиент API, создавший ящик; владелец загруженных файлов
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxFileSize  int64     `json:"max_file_size"`
+	MaxTotalSize int64     `json:"max_total_size,omitempty"`
+	MaxFiles     int       `json:"max_files,omitempty"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+}
+
+// dropBoxResponse - созданный ящик для приема файлов
+type dropBoxResponse struct {
+	ID           string    `json:"id"`
+	Token        string    `json:"token"`
+	URL          string    `json:"url"` // адрес для передачи внешнему отправителю
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxFileSize  int64     `json:"max_file_size"`
+	MaxTotalSize int64     `json:"max_total_size,omitempty"`
+	MaxFiles     int       `json:"max_files,omitempty"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+}
+
+// dropBoxStatus - ограничения ящика и занятый объем; имена загруженных файлов
+// отправителю не показываются
+type dropBoxStatus struct {
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxFileSize  int64     `json:"max_file_size"`
+	MaxTotalSize int64     `json:"max_total_size,omitempty"`
+	MaxFiles     int       `json:"max_files,omitempty"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+	Files        int       `json:"files"` // число загруженных файлов
+	Size         int64     `json:"size"`  // суммарный размер загруженных файлов
+}
+
+// dropBoxReceipt - подтверждение загрузки файла в ящик
+type dropBoxReceipt struct {
+	ID           string `json:"id"`
+	OriginalName string `json:"original_name"`
+	Size         int64  `json:"size"`
+	Checksum     string `json:"checksum"`
+}
+
+// dropBoxUsage - файлы, загруженные в ящик
+type dropBoxUsage struct {
+	files int
+	size  int64
+}
+
+// createDropBox создает ящик для приема файлов: ссылку, по которой внешний отправитель
+// без ключа API может только загружать файлы. Файлы получает создатель ящика
+func (s *StreamingAPIServer) createDropBox(c *gin.Context) {
+	settings := s.current()
+	secret := settings.config.UploadTokenSecret
+	if secret == "" {
+		writeError(c, http.StatusForbidden, apierror.DropBoxesDisabled)
+		return
+	}
+
+	// Тело запроса необязательно: без него создается ящик с ограничениями по умолчанию
+	var request dropBoxRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+
+	maxTTL := settings.config.DropBoxMaxTTL
+	expiresIn := time.Duration(request.ExpiresIn) * time.Second
+	switch {
+	case request.ExpiresIn < 0:
+		writeError(c, http.StatusBadRequest, apierror.NegativeDropBoxTTL)
+		return
+	case expiresIn > maxTTL:
+		writeError(c, http.StatusBadRequest, apierror.DropBoxTTLTooLong, maxTTL)
+		return
+	case expiresIn == 0:
+		expiresIn = maxTTL
+	}
+
+	maxFileSize := settings.config.MaxFileSize
+	switch {
+	case request.MaxFileSize < 0 || request.MaxTotalSize < 0 || request.MaxFiles < 0:
+		writeError(c, http.StatusBadRequest, apierror.NegativeDropBoxLimits)
+		return
+	case request.MaxFileSize > maxFileSize:
+		writeError(c, http.StatusBadRequest, apierror.MaxSizeTooLarge, maxFileSize)
+		return
+	case request.MaxFileSize == 0:
+		request.MaxFileSize = maxFileSize
+	}
+
+	contentTypes, typesErr := parseContentTypes(request.ContentTypes)
+	if typesErr != nil {
+		writeRequestError(c, typesErr)
+		return
+	}
+
+	claims := dropBoxClaims{
+		ID:           uuid.New().String(),
+		Owner:        c.GetString(principalKey),
+		ExpiresAt:    time.Now().Add(expiresIn).UTC(),
+		MaxFileSize:  request.MaxFileSize,
+		MaxTotalSize: request.MaxTotalSize,
+		MaxFiles:     request.MaxFiles,
+		ContentTypes: contentTypes,
+	}
+	signed, err := token.Sign(secret, dropBoxPurpose, claims, claims.ExpiresAt)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dropBoxResponse{
+		ID:           claims.ID,
+		Token:        signed,
+		URL:          "/api/v1/drop/" + url.PathEscape(signed),
+		ExpiresAt:    claims.ExpiresAt,
+		MaxFileSize:  claims.MaxFileSize,
+		MaxTotalSize: claims.MaxTotalSize,
+		MaxFiles:     claims.MaxFiles,
+		ContentTypes: claims.ContentTypes,
+	})
+}
+
+// getDropBox возвращает отправителю ограничения ящика и занятый в нем объем
+func (s *StreamingAPIServer) getDropBox(c *gin.Context) {
+	claims, ok := s.verifyDropBox(c)
+	if !ok {
+		return
+	}
+
+	usage, err := s.dropBoxUsage(c.Request.Context(), claims.ID)
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dropBoxStatus{
+		ExpiresAt:    claims.ExpiresAt,
+		MaxFileSize:  claims.MaxFileSize,
+		MaxTotalSize: claims.MaxTotalSize,
+		MaxFiles:     claims.MaxFiles,
+		ContentTypes: claims.ContentTypes,
+		Files:        usage.files,
+		Size:         usage.size,
+	})
+}
+
+// uploadToDropBox принимает файл в ящик. Файл остается закрытым и принадлежит
+// создателю ящика; отправитель получает только подтверждение загрузки
+func (s *StreamingAPIServer) uploadToDropBox(c *gin.Context) {
+	claims, ok := s.verifyDropBox(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	// Заполненный ящик отклоняет файл до чтения тела запроса
+	usage, err := s.dropBoxUsage(ctx, claims.ID)
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+	maxFileSize := min(claims.MaxFileSize, s.current().config.MaxFileSize)
+	if claims.MaxTotalSize > 0 {
+		maxFileSize = min(maxFileSize, claims.MaxTotalSize-usage.size)
+	}
+	if claims.MaxFiles > 0 && usage.files >= claims.MaxFiles || maxFileSize <= 0 {
+		writeError(c, http.StatusConflict, apierror.DropBoxFull)
+		return
+	}
+
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	fileData, header, ok := readFormFile(c, spool, maxFileSize)
+	if !ok {
+		return
+	}
+
+	// Пока файл сохраняется, его размер занимает место в ящике, чтобы одновременные
+	// загрузки не превысили ограничения
+	size := int64(len(fileData))
+	if err := s.dropBoxes.reserve(ctx, claims, size, s.dropBoxUsage); err != nil {
+		switch {
+		case errors.Is(err, errDropBoxFull):
+			writeError(c, http.StatusConflict, apierror.DropBoxFull)
+		case errors.Is(err, errDropBoxQuota):
+			writeError(c, http.StatusRequestEntityTooLarge, apierror.DropBoxQuota, err)
+		default:
+			writeCatalogError(c, err)
+		}
+		return
+	}
+	defer s.dropBoxes.release(claims.ID, size)
+
+	if c.GetString(auditActorKey) == "" {
+		actor := "drop-box"
+		if claims.Owner != "" {
+			actor += ":" + claims.Owner
+		}
+		c.Set(auditActorKey, actor)
+	}
+
+	metadata, err := s.storeFile(ctx, uploadInfo{
+		Name:         header.Filename,
+		ContentType:  header.Header.Get("Content-Type"),
+		Owner:        claims.Owner,
+		DropBox:      claims.ID,
+		ContentTypes: claims.ContentTypes,
+	}, fileData)
+	if err != nil {
+		writeStoreError(c, apierror.StoreFailed, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	c.JSON(http.StatusOK, dropBoxReceipt{
+		ID:           metadata.ID,
+		OriginalName: metadata.OriginalName,
+		Size:         metadata.Size,
+		Checksum:     metadata.Checksum,
+	})
+}
+
+// verifyDropBox проверяет токен ящика из параметра token. При ошибке ответ уже отправлен
+func (s *StreamingAPIServer) verifyDropBox(c *gin.Context) (*dropBoxClaims, bool) {
+	secret := s.current().config.UploadTokenSecret
+	if secret == "" {
+		writeError(c, http.StatusForbidden, apierror.DropBoxesDisabled)
+		return nil, false
+	}
+
+	var claims dropBoxClaims
+	if err := token.Verify(secret, dropBoxPurpose, c.Param("token"), &claims); err != nil {
+		writeError(c, http.StatusUnauthorized, apierror.DropBoxRejected, err)
+		return nil, false
+	}
+	return &claims, true
+}
+
+// dropBoxUsage подсчитывает файлы, загруженные в ящик
+func (s *StreamingAPIServer) dropBoxUsage(ctx context.Context, id string) (dropBoxUsage, error) {
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		return dropBoxUsage{}, err
+	}
+
+	var usage dropBoxUsage
+	for _, metadata := range files {
+		if metadata.DropBox == id {
+			usage.files++
+			usage.size += metadata.Size
+		}
+	}
+	return usage, nil
+}
+
+// isDropBoxRequest сообщает, является ли запрос обращением к ящику для приема файлов
+func isDropBoxRequest(c *gin.Context) bool {
+	return c.FullPath() == dropBoxRoute
+}
+
+// dropBoxRegistry учитывает файлы, которые сохраняются в ящики в данный момент
+type dropBoxRegistry struct {
+	mutex   sync.Mutex
+	pending map[string]dropBoxUsage // идентификатор ящика -> сохраняемые файлы
+}
+
+// newDropBoxRegistry создает пустой реестр ящиков
+func newDropBoxRegistry() *dropBoxRegistry {
+	return &dropBoxRegistry{pending: make(map[string]dropBoxUsage)}
+}
+
+// reserve занимает место для файла размером size, если ящик вместит его вместе с уже
+// загруженными (stored) и сохраняемыми файлами. Загруженные файлы подсчитываются под
+// блокировкой: файл, место которого освобождено, к этому времени уже есть в каталоге
+func (r *dropBoxRegistry) reserve(ctx context.Context, claims *dropBoxClaims, size int64, stored func(ctx context.Context, id string) (dropBoxUsage, error)) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	usage, err := stored(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	pending := r.pending[claims.ID]
+	if claims.MaxFiles > 0 && usage.files+pending.files >= claims.MaxFiles {
+		return errDropBoxFull
+	}
+	if claims.MaxTotalSize > 0 && usage.size+pending.size+size > claims.MaxTotalSize {
+		return fmt.Errorf("%w: осталось %d байт", errDropBoxQuota, max(claims.MaxTotalSize-usage.size-pending.size, 0))
+	}
+
+	r.pending[claims.ID] = dropBoxUsage{files: pending.files + 1, size: pending.size + size}
+	return nil
+}
+
+// release освобождает место, занятое reserve, после сохранения файла или ошибки
+func (r *dropBoxRegistry) release(id string, size int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pending := r.pending[id]
+	pending.files--
+	pending.size -= size
+	if pending.files <= 0 {
+		delete(r.pending, id)
+		return
+	}
+	r.pending[id] = pending
+}
@@ -0,0 +1,94 @@
+package apiserver
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+)
+
+// requestError - ошибка запроса, о которой клиенту сообщается с HTTP статусом и кодом ошибки
+type requestError struct {
+	status int
+	code   apierror.Code
+	args   []interface{}
+}
+
+// newRequestError создает ошибку запроса с сообщением code и аргументами его шаблона
+func newRequestError(status int, code apierror.Code, args ...interface{}) *requestError {
+	return &requestError{status: status, code: code, args: args}
+}
+
+func (e *requestError) Error() string {
+	return apierror.Message(apierror.DefaultLanguage, e.code, e.args...)
+}
+
+// requestLanguage выбирает язык сообщений об ошибках по заголовку Accept-Language запроса
+func requestLanguage(c *gin.Context) apierror.Language {
+	return apierror.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// requestIDKey - ключ контекста gin с идентификатором запроса
+const requestIDKey = "request_id"
+
+// requestIDMiddleware назначает запросу идентификатор и возвращает его в заголовке
+// X-Request-ID, чтобы ошибку клиента можно было найти в журналах сервера
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := apierror.RequestID(c.GetHeader(apierror.RequestIDHeader))
+		c.Set(requestIDKey, requestID)
+		c.Header(apierror.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// errorBody возвращает тело ответа с ошибкой: код, не зависящий от языка, сообщение на
+// языке клиента и идентификатор запроса
+func errorBody(c *gin.Context, code apierror.Code, args ...interface{}) apierror.Envelope {
+	return apierror.New(requestLanguage(c), code, c.GetString(requestIDKey), args...)
+}
+
+// writeError отвечает ошибкой code с HTTP статусом status
+func writeError(c *gin.Context, status int, code apierror.Code, args ...interface{}) {
+	c.JSON(status, errorBody(c, code, args...))
+}
+
+// abortedKey - ключ контекста gin: начатый ответ нельзя завершить, соединение нужно оборвать
+const abortedKey = "response.aborted"
+
+// abortResponse отмечает, что уже начатый ответ нельзя завершить, например если кусок
+// файла не получен после отправки части содержимого. abortMiddleware обрывает
+// соединение, и клиент получает ошибку передачи, а не усеченный ответ с кодом 200.
+// Обработчик должен сразу вернуться
+func abortResponse(c *gin.Context) {
+	c.Set(abortedKey, true)
+	c.Abort()
+}
+
+// abortMiddleware обрывает соединение запроса, отмеченного abortResponse, паникой
+// http.ErrAbortHandler, которую net/http обрабатывает без записи стека в журнал. Из
+// обработчика такую панику перехватил бы gin.Recovery и завершил ответ как обычный,
+// поэтому middleware регистрируется первым
+func abortMiddleware(c *gin.Context) {
+	c.Next()
+	if c.GetBool(abortedKey) {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// routeNotFound отвечает на запрос к несуществующему маршруту
+func routeNotFound(c *gin.Context) {
+	writeError(c, http.StatusNotFound, apierror.RouteNotFound, c.Request.Method, c.Request.URL.Path)
+}
+
+// writeRequestError отвечает ошибкой запроса
+func writeRequestError(c *gin.Context, err *requestError) {
+	writeError(c, err.status, err.code, err.args...)
+}
+
+// writeStoreError отвечает на ошибку сохранения файла. Ошибки проверок содержимого и
+// нехватки места получают собственные коды, остальные - код code
+func writeStoreError(c *gin.Context, code apierror.Code, err error) {
+	writeError(c, storeErrorStatus(err), storeErrorCode(err, code), err)
+}
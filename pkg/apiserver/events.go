@@ -0,0 +1,87 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+)
+
+const (
+	// eventStreamBuffer - размер буфера событий одного подписчика
+	eventStreamBuffer = 100
+
+	// eventStreamKeepAlive - период отправки комментария, чтобы прокси не закрывали соединение
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+// streamEvents отдает события файлов в реальном времени в формате Server-Sent Events.
+// Параметр types ограничивает поток перечисленными через запятую типами событий.
+// Клиент получает события только файлов, которые может читать
+func (s *StreamingAPIServer) streamEvents(c *gin.Context) {
+	principal := c.GetString(principalKey)
+	var filter map[events.Type]bool
+	if types := c.Query("types"); types != "" {
+		filter = make(map[events.Type]bool)
+		for _, eventType := range strings.Split(types, ",") {
+			filter[events.Type(strings.TrimSpace(eventType))] = true
+		}
+	}
+
+	eventsChan, unsubscribe := s.events.Subscribe(eventStreamBuffer)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // отключаем буферизацию в nginx
+	c.Status(http.StatusOK)
+
+	// Комментарий сразу отправляет заголовки, чтобы клиент знал, что подписка активна
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case event, ok := <-eventsChan:
+			if !ok {
+				return
+			}
+			if filter != nil && !filter[event.Type] {
+				continue
+			}
+			if !s.current().canAccess(principal, eventFile(event), accessRead) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// eventFile возвращает владельца и права файла события для проверки доступа
+func eventFile(event events.Event) *chunking.FileMetadata {
+	return &chunking.FileMetadata{ID: event.File.ID, Owner: event.File.Owner, Grants: event.File.Grants}
+}
@@ -0,0 +1,247 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+)
+
+// fetchRequest описывает запрос на загрузку файла по URL
+type fetchRequest struct {
+	URL    string `json:"url" binding:"required"`
+	Name   string `json:"name"`   // имя файла; по умолчанию берется из ответа или URL
+	Public bool   `json:"public"` // файл доступен по публичной ссылке
+}
+
+// maxFetchRedirects - сколько перенаправлений выполняется при скачивании ресурса
+const maxFetchRedirects = 10
+
+var (
+	// errFetchURLInvalid - URL ресурса или перенаправления не является абсолютным http или https URL
+	errFetchURLInvalid = errors.New("поддерживаются только абсолютные http и https URL")
+	// errFetchForbidden - адрес ресурса не разрешен настройками fetch_allowed_networks и fetch_denied_networks
+	errFetchForbidden = errors.New("скачивание с адреса запрещено")
+)
+
+// nonPublicNetworks - специальные сети, не покрытые проверками net.IP: общий адрес
+// провайдера (CGNAT), служебные, тестовые и зарезервированные сети IPv4 и NAT64,
+// через который IPv6 адрес может указывать на закрытый IPv4
+var nonPublicNetworks = mustParseNetworks(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+)
+
+// fetchAddressError - соединение с адресом, не разрешенным для скачивания
+type fetchAddressError struct {
+	addr string
+}
+
+func (e *fetchAddressError) Error() string {
+	return errFetchForbidden.Error() + ": " + e.addr
+}
+
+func (e *fetchAddressError) Unwrap() error {
+	return errFetchForbidden
+}
+
+// newFetchClient создает клиент для скачивания удаленных ресурсов. Адрес проверяется
+// при каждом соединении уже после разрешения имени, поэтому ни перенаправление, ни
+// смена DNS записи между проверкой и соединением не приводят запрос во внутреннюю сеть.
+// Прокси из окружения не используется: с ним проверялся бы адрес прокси, а не ресурса
+func newFetchClient(settings *runtimeSettings) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !settings.fetchAllowed(ip) {
+				return &fetchAddressError{addr: host}
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 30 * time.Minute, // удаленные файлы могут быть большими
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("больше %d перенаправлений", maxFetchRedirects)
+			}
+			return checkFetchURL(req.URL)
+		},
+	}
+}
+
+// checkFetchURL проверяет, что ресурс или перенаправление указывает на абсолютный http или https URL
+func checkFetchURL(sourceURL *url.URL) error {
+	if (sourceURL.Scheme != "http" && sourceURL.Scheme != "https") || sourceURL.Host == "" {
+		return errFetchURLInvalid
+	}
+	return nil
+}
+
+// fetchAllowed сообщает, можно ли скачивать ресурсы с адреса ip: адреса из
+// fetch_denied_networks запрещены всегда, из fetch_allowed_networks - разрешены,
+// остальные - только если это публичные адреса
+func (r *runtimeSettings) fetchAllowed(ip net.IP) bool {
+	for _, network := range r.fetchDeniedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	for _, network := range r.fetchAllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return isPublicIP(ip)
+}
+
+// isPublicIP сообщает, является ли ip публичным адресом: не локальным, не из закрытых
+// сетей, не link-local (в том числе адрес службы метаданных облака 169.254.169.254),
+// не групповым и не из специальных сетей
+func isPublicIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseNetworks разбирает сети в формате CIDR, заданные в коде
+func mustParseNetworks(entries ...string) []*net.IPNet {
+	networks, err := parseNetworks(entries)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+// fetchFileFromURL скачивает удаленный ресурс, разделяет его на куски и сохраняет
+func (s *StreamingAPIServer) fetchFileFromURL(c *gin.Context) {
+	var req fetchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.FetchURLRequired)
+		return
+	}
+
+	sourceURL, err := url.Parse(req.URL)
+	if err != nil || checkFetchURL(sourceURL) != nil {
+		writeError(c, http.StatusBadRequest, apierror.FetchURLInvalid)
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, sourceURL.String(), nil)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, apierror.FetchRequestFailed, err)
+		return
+	}
+
+	fetchClient := newFetchClient(s.current())
+	defer fetchClient.CloseIdleConnections()
+	resp, err := fetchClient.Do(httpReq)
+	var addrErr *fetchAddressError
+	switch {
+	case errors.As(err, &addrErr):
+		writeError(c, http.StatusForbidden, apierror.FetchURLForbidden, addrErr.addr)
+		return
+	case errors.Is(err, errFetchURLInvalid):
+		writeError(c, http.StatusBadRequest, apierror.FetchURLInvalid)
+		return
+	case err != nil:
+		writeError(c, http.StatusBadGateway, apierror.FetchFailed, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		writeError(c, http.StatusBadGateway, apierror.FetchBadStatus, resp.StatusCode)
+		return
+	}
+
+	// Отклоняем заведомо слишком большие ресурсы до начала скачивания
+	maxFileSize := s.current().config.MaxFileSize
+	if resp.ContentLength > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return
+	}
+
+	// Content-Length может отсутствовать, поэтому ограничиваем и фактическое чтение
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	fileData, err := spool.readAll(io.LimitReader(resp.Body, maxFileSize+1))
+	if errors.Is(err, errUploadSpool) {
+		uploadSpoolFailed(c, err)
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusBadGateway, apierror.FetchReadFailed, err)
+		return
+	}
+	if int64(len(fileData)) > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = remoteFileName(resp, sourceURL)
+	}
+
+	metadata, err := s.storeFile(c.Request.Context(), uploadInfo{
+		Name:        name,
+		ContentType: resp.Header.Get("Content-Type"),
+		Public:      req.Public,
+		Owner:       c.GetString(principalKey),
+	}, fileData)
+	if err != nil {
+		writeStoreError(c, apierror.StoreFailed, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
+
+// remoteFileName определяет имя файла по заголовку Content-Disposition или пути URL
+func remoteFileName(resp *http.Response, sourceURL *url.URL) string {
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != "" {
+		if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+			return path.Base(params["filename"])
+		}
+	}
+
+	if name := path.Base(sourceURL.Path); name != "" && name != "/" && name != "." {
+		return name
+	}
+
+	return sourceURL.Host
+}
@@ -0,0 +1,93 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/config"
+)
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	resource := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+			return
+		}
+		w.Write([]byte("internal secret"))
+	}))
+	defer resource.Close()
+
+	newAPI := func(allowed, denied []string) string {
+		return newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) {
+			cfg.ChunkCount = 1
+			cfg.FetchAllowedNetworks = allowed
+			cfg.FetchDeniedNetworks = denied
+		})).url
+	}
+	fetch := func(baseURL, sourceURL string) (*http.Response, apierror.Code) {
+		body, err := json.Marshal(fetchRequest{URL: sourceURL})
+		require.NoError(t, err)
+		resp, err := http.Post(baseURL+"/api/v1/files/fetch", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var envelope apierror.Envelope
+		if resp.StatusCode != http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&envelope))
+		}
+		return resp, envelope.Error.Code
+	}
+
+	// По умолчанию ресурсы на локальном адресе не скачиваются, в том числе по имени
+	baseURL := newAPI(nil, nil)
+	resp, code := fetch(baseURL, resource.URL+"/secret.txt")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, apierror.FetchURLForbidden, code)
+	resp, _ = fetch(baseURL, strings.Replace(resource.URL, "127.0.0.1", "localhost", 1)+"/secret.txt")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Закрытую сеть можно разрешить, а запрещенные сети важнее разрешенных
+	baseURL = newAPI([]string{"127.0.0.0/8"}, nil)
+	resp, _ = fetch(baseURL, resource.URL+"/secret.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, code = fetch(baseURL, resource.URL+"/redirect")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, apierror.FetchURLInvalid, code)
+
+	baseURL = newAPI([]string{"127.0.0.0/8"}, []string{"127.0.0.1/32"})
+	resp, _ = fetch(baseURL, resource.URL+"/secret.txt")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"8.8.8.8":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"::1":                false,
+		"fd00::1":            false,
+		"fe80::1":            false,
+		"::ffff:127.0.0.1":   false,
+		"64:ff9b::7f00:1":    false,
+		"255.255.255.255":    false,
+		"224.0.0.1":          false,
+		"ff02::1":            false,
+		"::ffff:203.0.113.1": true,
+	} {
+		assert.Equal(t, public, isPublicIP(net.ParseIP(addr)), addr)
+	}
+}
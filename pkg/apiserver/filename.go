@@ -0,0 +1,155 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errFilenameRejected возвращается, если имя файла нарушает правила из конфигурации
+var errFilenameRejected = errors.New("имя файла не разрешено")
+
+// checkFilename проверяет имя загружаемого файла по правилам allowed_extensions,
+// blocked_extensions, max_filename_length и forbidden_filename_patterns
+func (r *runtimeSettings) checkFilename(name string) error {
+	cfg := r.config
+
+	if cfg.MaxFilenameLength > 0 && utf8.RuneCountInString(name) > cfg.MaxFilenameLength {
+		return fmt.Errorf("%w: длина имени больше %d символов", errFilenameRejected, cfg.MaxFilenameLength)
+	}
+
+	lowerName := strings.ToLower(name)
+	if extension, ok := matchExtension(lowerName, cfg.BlockedExtensions); ok {
+		return fmt.Errorf("%w: расширение .%s запрещено", errFilenameRejected, extension)
+	}
+	if len(cfg.AllowedExtensions) > 0 {
+		if _, ok := matchExtension(lowerName, cfg.AllowedExtensions); !ok {
+			return fmt.Errorf("%w: допустимые расширения: %s", errFilenameRejected, strings.Join(cfg.AllowedExtensions, ", "))
+		}
+	}
+
+	for _, pattern := range r.filenamePatterns {
+		if pattern.MatchString(name) {
+			return fmt.Errorf("%w: имя соответствует запрещенному шаблону %s", errFilenameRejected, pattern)
+		}
+	}
+	return nil
+}
+
+// matchExtension ищет расширение из списка, которым заканчивается имя. Расширения
+// сравниваются без учета регистра и могут быть составными (tar.gz), точка в начале необязательна
+func matchExtension(lowerName string, extensions []string) (string, bool) {
+	for _, extension := range extensions {
+		extension = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(extension)), ".")
+		if extension != "" && strings.HasSuffix(lowerName, "."+extension) {
+			return extension, true
+		}
+	}
+	return "", false
+}
+
+// Способы показа файла в заголовке Content-Disposition
+const (
+	dispositionAttachment = "attachment" // браузер сохраняет файл
+	dispositionInline     = "inline"     // браузер показывает файл сам, если умеет
+)
+
+// contentDisposition формирует заголовок Content-Disposition по RFC 6266. Параметр
+// filename содержит ASCII-вариант имени для старых клиентов, а если имя в нем не
+// передается без искажений, добавляется filename* с именем в UTF-8 по RFC 8187
+func contentDisposition(disposition, name string) string {
+	if name == "" {
+		return disposition
+	}
+
+	fallback := asciiFilename(name)
+	header := disposition + `; filename="` + fallback + `"`
+	if fallback != name {
+		header += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return header
+}
+
+// asciiFilename заменяет символом подчеркивания все, что нельзя без экранирования
+// передать в строке в кавычках: не-ASCII и управляющие символы, кавычки и обратную косую черту
+func asciiFilename(name string) string {
+	var builder strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			builder.WriteByte('_')
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// encodeExtValue кодирует имя для filename*: байты UTF-8, кроме attr-char из RFC 8187,
+// записываются как %XX
+func encodeExtValue(name string) string {
+	const hex = "0123456789ABCDEF"
+	var builder strings.Builder
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if isAttrChar(b) {
+			builder.WriteByte(b)
+			continue
+		}
+		builder.WriteByte('%')
+		builder.WriteByte(hex[b>>4])
+		builder.WriteByte(hex[b&0x0f])
+	}
+	return builder.String()
+}
+
+// isAttrChar сообщает, можно ли передать байт в filename* без кодирования
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// downloadDisposition выбирает способ показа файла по параметру disposition запроса.
+// inline разрешается только для типов из inline_content_types: остальные файлы, например
+// HTML или SVG со скриптами, отдаются как вложение, чтобы не выполняться в браузере от
+// имени сервиса. ok ложно при неизвестном значении параметра
+func (r *runtimeSettings) downloadDisposition(requested, contentType string) (disposition string, ok bool) {
+	switch requested {
+	case "", dispositionAttachment:
+		return dispositionAttachment, true
+	case dispositionInline:
+		allowed := r.config.InlineContentTypes
+		if len(allowed) > 0 && checkContentType(allowed, contentType) == nil {
+			return dispositionInline, true
+		}
+		return dispositionAttachment, true
+	default:
+		return "", false
+	}
+}
+
+// downloadFilename возвращает имя для заголовка Content-Disposition: параметр filename
+// запроса, если он задан, иначе исходное имя файла. Параметр не может содержать
+// путь и управляющие символы, а его длина ограничена max_filename_length
+func (r *runtimeSettings) downloadFilename(override, originalName string) (string, bool) {
+	if override == "" {
+		return originalName, true
+	}
+	if !utf8.ValidString(override) || strings.ContainsAny(override, `/\`) || override == "." || override == ".." {
+		return "", false
+	}
+	for _, char := range override {
+		if unicode.IsControl(char) {
+			return "", false
+		}
+	}
+	maxLength := r.config.MaxFilenameLength
+	if maxLength > 0 && utf8.RuneCountInString(override) > maxLength {
+		return "", false
+	}
+	return override, true
+}
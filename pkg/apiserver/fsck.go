@@ -0,0 +1,33 @@
+package apiserver
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/fsck"
+)
+
+// checkConsistency сверяет каталог с кусками на всех серверах хранения и возвращает отчет
+// пакета fsck. verify=true пересчитывает контрольные суммы кусков на серверах
+func (s *StreamingAPIServer) checkConsistency(c *gin.Context) {
+	ctx := c.Request.Context()
+	settings := s.current()
+
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.CatalogReadFailed)
+		return
+	}
+
+	report := fsck.Check(ctx, files, fsck.Options{
+		Verify: c.Query("verify") == "true",
+		Nodes:  settings.config.StorageServers,
+		Client: func(node string) fsck.Inventory { return settings.clientForNode(node) },
+	})
+	log.Printf("Проверка согласованности: %d файлов, %d кусков, %d проблем, %d кусков потеряно",
+		report.Files, report.Chunks, len(report.Problems), report.LostChunks)
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,402 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// graphQLPath - адрес запросов GraphQL
+const graphQLPath = "/api/v1/graphql"
+
+// Размер страницы списка файлов GraphQL
+const (
+	graphQLDefaultLimit = 100
+	graphQLMaxLimit     = 1000
+)
+
+// graphQLRequest - запрос GraphQL в теле POST или в параметрах GET
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphQLScope - состояние одного запроса GraphQL: настройки на момент запроса и уже
+// найденные серверы хранения, чтобы сервер, упомянутый в размещении многих кусков,
+// опрашивался один раз
+type graphQLScope struct {
+	settings *runtimeSettings
+	server   *StreamingAPIServer
+
+	mutex sync.Mutex
+	nodes map[string]*graphQLNode
+}
+
+type graphQLScopeKey struct{}
+
+// scopeFrom возвращает состояние запроса GraphQL из контекста резолвера
+func scopeFrom(ctx context.Context) *graphQLScope {
+	return ctx.Value(graphQLScopeKey{}).(*graphQLScope)
+}
+
+// node возвращает сервер хранения по адресу, создавая его при первом обращении
+func (g *graphQLScope) node(address string) *graphQLNode {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if node, ok := g.nodes[address]; ok {
+		return node
+	}
+	status := g.server.nodes.snapshot(address)
+	node := &graphQLNode{
+		Node:         address,
+		Region:       g.settings.regions[address],
+		State:        status.State,
+		Member:       g.settings.findClient(address) != nil,
+		SafeToRemove: status.State == nodeDrained,
+		Drain:        status.Drain,
+		client:       g.settings.clientForNode(address),
+	}
+	g.nodes[address] = node
+	return node
+}
+
+// graphQLNode - сервер хранения в ответе GraphQL. Состояние самого сервера (health)
+// запрашивается, только если оно выбрано в запросе
+type graphQLNode struct {
+	Node         string         `json:"node"`
+	Region       string         `json:"region"`
+	State        string         `json:"state"`
+	Member       bool           `json:"member"`
+	SafeToRemove bool           `json:"safe_to_remove"`
+	Drain        *drainProgress `json:"drain"`
+
+	client     *storage.StorageClient
+	healthOnce sync.Once
+	healthDone chan struct{}
+	health     *graphQLNodeHealth
+}
+
+// graphQLNodeHealth - занятое и свободное место по данным сервера хранения
+type graphQLNodeHealth struct {
+	Reachable   bool     `json:"reachable"`
+	UsedBytes   *int64   `json:"used_bytes"`
+	FreeBytes   *int64   `json:"free_bytes"`
+	Utilization *float64 `json:"utilization"`
+	Error       string   `json:"error"`
+}
+
+// resolveHealth начинает опрос сервера и возвращает отложенный результат: GraphQL
+// дожидается его после обхода остальных полей, поэтому серверы опрашиваются параллельно
+func (n *graphQLNode) resolveHealth(ctx context.Context) func() (interface{}, error) {
+	n.healthOnce.Do(func() {
+		n.healthDone = make(chan struct{})
+		go func() {
+			defer close(n.healthDone)
+
+			nodeCtx, cancel := context.WithTimeout(ctx, statsNodeTimeout)
+			defer cancel()
+			status, err := n.client.StatusContext(nodeCtx)
+			if err != nil {
+				n.health = &graphQLNodeHealth{Error: err.Error()}
+				return
+			}
+			health := &graphQLNodeHealth{Reachable: true, UsedBytes: &status.UsedBytes, FreeBytes: status.FreeBytes}
+			if status.FreeBytes != nil && status.UsedBytes+*status.FreeBytes > 0 {
+				utilization := float64(status.UsedBytes) / float64(status.UsedBytes+*status.FreeBytes)
+				health.Utilization = &utilization
+			}
+			n.health = health
+		}()
+	})
+	return func() (interface{}, error) {
+		<-n.healthDone
+		return n.health, nil
+	}
+}
+
+// graphQLFilePage - страница списка файлов
+type graphQLFilePage struct {
+	TotalCount int                      `json:"total_count"`
+	Files      []*chunking.FileMetadata `json:"files"`
+}
+
+// graphQLInt64 - целое число за пределами 32 бит, которыми ограничен Int в GraphQL:
+// размеры в байтах и счетчики
+var graphQLInt64 = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Int64",
+	Description: "64-битное целое: размеры в байтах и счетчики",
+	Serialize: func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case int64:
+			return value
+		case *int64:
+			if value == nil {
+				return nil
+			}
+			return *value
+		case int:
+			return int64(value)
+		}
+		return nil
+	},
+})
+
+// newGraphQLSchema описывает схему запросов метаданных: файлы, размещение их кусков и
+// состояние серверов хранения. Схема только для чтения, изменений она не содержит
+func (s *StreamingAPIServer) newGraphQLSchema() (graphql.Schema, error) {
+	drainType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Drain",
+		Fields: graphql.Fields{
+			"total_chunks":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"moved_chunks":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"failed_chunks": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"moved_bytes":   &graphql.Field{Type: graphql.NewNonNull(graphQLInt64)},
+			"started_at":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"finished_at":   &graphql.Field{Type: graphql.DateTime},
+			"errors":        &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		},
+	})
+
+	healthType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NodeHealth",
+		Fields: graphql.Fields{
+			"reachable":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"used_bytes":  &graphql.Field{Type: graphQLInt64},
+			"free_bytes":  &graphql.Field{Type: graphQLInt64},
+			"utilization": &graphql.Field{Type: graphql.Float},
+			"error":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	nodeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Node",
+		Fields: graphql.Fields{
+			"node":           &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "Адрес сервера хранения host:port"},
+			"region":         &graphql.Field{Type: graphql.String},
+			"state":          &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "active, cordoned, draining, drained или failed"},
+			"member":         &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "Сервер входит в текущий состав storage_servers"},
+			"safe_to_remove": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"drain":          &graphql.Field{Type: drainType},
+			"health": &graphql.Field{
+				Type:        graphql.NewNonNull(healthType),
+				Description: "Занятое и свободное место по данным сервера; запрашивается у сервера",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*graphQLNode).resolveHealth(p.Context), nil
+				},
+			},
+		},
+	})
+
+	chunkType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Chunk",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"index":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"size":      &graphql.Field{Type: graphql.NewNonNull(graphQLInt64)},
+			"checksum":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"algorithm": &graphql.Field{Type: graphql.String},
+			"node":      &graphql.Field{Type: graphql.String, Description: "Основной сервер куска"},
+			"replicas":  &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"placement": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(nodeType))),
+				Description: "Серверы со всеми копиями куска, основной первым",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					scope := scopeFrom(p.Context)
+					var nodes []*graphQLNode
+					for _, address := range p.Source.(chunking.FileChunk).Nodes() {
+						nodes = append(nodes, scope.node(address))
+					}
+					return nodes, nil
+				},
+			},
+		},
+	})
+
+	fileType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "File",
+		Fields: graphql.Fields{
+			"id":                    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"original_name":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"path":                  &graphql.Field{Type: graphql.String},
+			"size":                  &graphql.Field{Type: graphql.NewNonNull(graphQLInt64)},
+			"checksum":              &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"checksum_algorithm":    &graphql.Field{Type: graphql.String},
+			"merkle_root":           &graphql.Field{Type: graphql.String, Description: "Корень Merkle дерева над кусками"},
+			"content_type":          &graphql.Field{Type: graphql.String},
+			"detected_content_type": &graphql.Field{Type: graphql.String},
+			"created_at":            &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"modified_at":           &graphql.Field{Type: graphql.DateTime, Description: "Время последней замены содержимого"},
+			"generation":            &graphql.Field{Type: graphql.NewNonNull(graphQLInt64), Description: "Поколение метаданных, растет при каждом изменении"},
+			"public":                &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"owner":                 &graphql.Field{Type: graphql.String},
+			"drop_box":              &graphql.Field{Type: graphql.String},
+			"download_count":        &graphql.Field{Type: graphql.NewNonNull(graphQLInt64)},
+			"bytes_served":          &graphql.Field{Type: graphql.NewNonNull(graphQLInt64)},
+			"last_accessed_at":      &graphql.Field{Type: graphql.DateTime},
+			"chunk_count":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"chunks":                &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(chunkType)))},
+		},
+	})
+
+	filePageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FilePage",
+		Fields: graphql.Fields{
+			"total_count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Файлов по условию без учета limit и offset"},
+			"files":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(fileType)))},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"file": &graphql.Field{
+				Type:        fileType,
+				Description: "Файл по идентификатору; null, если файла нет или он недоступен клиенту",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: s.resolveGraphQLFile,
+			},
+			"files": &graphql.Field{
+				Type:        graphql.NewNonNull(filePageType),
+				Description: "Файлы, доступные клиенту, в порядке каталога",
+				Args: graphql.FieldConfigArgument{
+					"prefix": &graphql.ArgumentConfig{Type: graphql.String, Description: "Префикс логического пути"},
+					"public": &graphql.ArgumentConfig{Type: graphql.Boolean},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphQLDefaultLimit},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: s.resolveGraphQLFiles,
+			},
+			"nodes": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(nodeType))),
+				Description: "Серверы хранения текущего состава",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					scope := scopeFrom(p.Context)
+					nodes := make([]*graphQLNode, 0, len(scope.settings.config.StorageServers))
+					for _, address := range scope.settings.config.StorageServers {
+						nodes = append(nodes, scope.node(address))
+					}
+					return nodes, nil
+				},
+			},
+			"node": &graphql.Field{
+				Type:        nodeType,
+				Description: "Сервер хранения по адресу host:port; null, если его нет в составе",
+				Args: graphql.FieldConfigArgument{
+					"node": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					scope := scopeFrom(p.Context)
+					address := p.Args["node"].(string)
+					if scope.settings.findClient(address) == nil {
+						return nil, nil
+					}
+					return scope.node(address), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// resolveGraphQLFile возвращает файл, если клиент может его читать
+func (s *StreamingAPIServer) resolveGraphQLFile(p graphql.ResolveParams) (interface{}, error) {
+	metadata, err := s.catalog.Get(p.Context, p.Args["id"].(string))
+	if errors.Is(err, catalog.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.current().canAccess(contextPrincipal(p.Context), metadata, accessRead) {
+		return nil, nil
+	}
+	return s.access.merged(metadata), nil
+}
+
+// resolveGraphQLFiles возвращает страницу файлов, доступных клиенту
+func (s *StreamingAPIServer) resolveGraphQLFiles(p graphql.ResolveParams) (interface{}, error) {
+	var all []*chunking.FileMetadata
+	var err error
+	if prefix, ok := p.Args["prefix"].(string); ok {
+		all, err = s.filesWithPathPrefix(p.Context, prefix)
+	} else {
+		all, err = s.catalog.List(p.Context)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings := s.current()
+	principal := contextPrincipal(p.Context)
+	public, filterPublic := p.Args["public"].(bool)
+	var files []*chunking.FileMetadata
+	for _, metadata := range all {
+		if filterPublic && metadata.Public != public {
+			continue
+		}
+		if settings.canAccess(principal, metadata, accessRead) {
+			files = append(files, metadata)
+		}
+	}
+
+	page := &graphQLFilePage{TotalCount: len(files), Files: []*chunking.FileMetadata{}}
+	limit := min(max(p.Args["limit"].(int), 0), graphQLMaxLimit)
+	offset := min(max(p.Args["offset"].(int), 0), len(files))
+	for _, metadata := range files[offset:min(offset+limit, len(files))] {
+		page.Files = append(page.Files, s.access.merged(metadata))
+	}
+	return page, nil
+}
+
+// serveGraphQL выполняет запрос GraphQL из тела POST ({"query", "variables",
+// "operationName"}) или из параметров GET с теми же именами. Ошибки выполнения запроса
+// возвращаются в поле errors ответа со статусом 200, как принято в GraphQL
+func (s *StreamingAPIServer) serveGraphQL(c *gin.Context) {
+	var req graphQLRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeError(c, http.StatusBadRequest, apierror.InvalidGraphQLRequest)
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidGraphQLRequest)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(c, http.StatusBadRequest, apierror.InvalidGraphQLRequest)
+		return
+	}
+
+	scope := &graphQLScope{settings: s.current(), server: s, nodes: make(map[string]*graphQLNode)}
+	ctx := withPrincipal(c.Request.Context(), c.GetString(principalKey))
+	ctx = context.WithValue(ctx, graphQLScopeKey{}, scope)
+
+	result := graphql.Do(graphql.Params{
+		Schema:         *s.graphQL,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,151 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+// graphQLResponse - ответ на запрос GraphQL
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func queryGraphQL(t *testing.T, baseURL, apiKey, query string, variables map[string]interface{}) graphQLResponse {
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, baseURL+graphQLPath, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result graphQLResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return result
+}
+
+func TestGraphQLMetadataQueries(t *testing.T) {
+	server := newTestServer(t, withStorageNodes(2), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 3
+		cfg.SmallFileThreshold = 0
+		cfg.GraphQLEnabled = true
+		cfg.APIKeys = []string{"alice:alice-key", "bob:bob-key"}
+	}))
+	nodes := server.nodes
+
+	apiClient := server.api
+	apiClient.SetAPIKey("alice-key")
+	content := bytes.Repeat([]byte("graphql"), 10_000)
+	metadata, err := apiClient.UploadReader(context.Background(), "report.txt", bytes.NewReader(content),
+		int64(len(content)), client.WithPath("reports/report.txt"))
+	require.NoError(t, err)
+
+	// Файл вместе с размещением кусков и состоянием серверов в одном запросе
+	result := queryGraphQL(t, server.url, "alice-key", `query($id: String!) {
+		file(id: $id) { id original_name path size generation chunk_count
+			chunks { index size placement { node state health { reachable used_bytes } } } }
+		nodes { node member state safe_to_remove health { reachable } }
+	}`, map[string]interface{}{"id": metadata.ID})
+	require.Empty(t, result.Errors)
+
+	var data struct {
+		File struct {
+			ID           string `json:"id"`
+			OriginalName string `json:"original_name"`
+			Path         string `json:"path"`
+			Size         int64  `json:"size"`
+			Generation   int64  `json:"generation"`
+			ChunkCount   int    `json:"chunk_count"`
+			Chunks       []struct {
+				Index     int   `json:"index"`
+				Size      int64 `json:"size"`
+				Placement []struct {
+					Node   string `json:"node"`
+					State  string `json:"state"`
+					Health struct {
+						Reachable bool   `json:"reachable"`
+						UsedBytes *int64 `json:"used_bytes"`
+					} `json:"health"`
+				} `json:"placement"`
+			} `json:"chunks"`
+		} `json:"file"`
+		Nodes []struct {
+			Node   string `json:"node"`
+			Member bool   `json:"member"`
+			State  string `json:"state"`
+			Health struct {
+				Reachable bool `json:"reachable"`
+			} `json:"health"`
+		} `json:"nodes"`
+	}
+	require.NoError(t, json.Unmarshal(result.Data, &data))
+	assert.Equal(t, metadata.ID, data.File.ID)
+	assert.Equal(t, "report.txt", data.File.OriginalName)
+	assert.Equal(t, "reports/report.txt", data.File.Path)
+	assert.Equal(t, int64(len(content)), data.File.Size)
+	require.Len(t, data.File.Chunks, 3)
+	var total int64
+	for _, chunk := range data.File.Chunks {
+		total += chunk.Size
+		require.NotEmpty(t, chunk.Placement)
+		assert.Contains(t, nodes, chunk.Placement[0].Node)
+		assert.Equal(t, nodeActive, chunk.Placement[0].State)
+		assert.True(t, chunk.Placement[0].Health.Reachable)
+		assert.NotNil(t, chunk.Placement[0].Health.UsedBytes)
+	}
+	assert.Equal(t, int64(len(content)), total)
+	require.Len(t, data.Nodes, 2)
+	for _, node := range data.Nodes {
+		assert.True(t, node.Member)
+		assert.True(t, node.Health.Reachable)
+	}
+
+	// Чужой закрытый файл не виден ни по идентификатору, ни в списке
+	result = queryGraphQL(t, server.url, "bob-key", `query($id: String!) {
+		file(id: $id) { id }
+		files(prefix: "reports/") { total_count files { id } }
+	}`, map[string]interface{}{"id": metadata.ID})
+	require.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"file": null, "files": {"total_count": 0, "files": []}}`, string(result.Data))
+
+	// Запрос GET с параметрами в адресе
+	query := url.Values{"query": {`{ files(prefix: "reports/", limit: 1) { total_count files { id } } }`}}
+	req, err := http.NewRequest(http.MethodGet, server.url+graphQLPath+"?"+query.Encode(), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "alice-key")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	resp.Body.Close()
+	assert.JSONEq(t, `{"files": {"total_count": 1, "files": [{"id": "`+metadata.ID+`"}]}}`, string(result.Data))
+
+	// Ошибка в запросе возвращается в поле errors
+	result = queryGraphQL(t, server.url, "alice-key", `{ files { unknown_field } }`, nil)
+	assert.NotEmpty(t, result.Errors)
+
+	// Запрос без query
+	req, err = http.NewRequest(http.MethodPost, server.url+graphQLPath, strings.NewReader(`{}`))
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "alice-key")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,383 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/audit"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client/storagepb"
+)
+
+// grpcMessageSize - наибольший объем данных в одном сообщении gRPC. Куски файла
+// отправляются частями, чтобы не упираться в ограничение размера сообщения получателя
+const grpcMessageSize = 256 << 10
+
+// grpcErrorDomain - домен ошибок в ErrorInfo, по коду Reason клиент отличает ошибки так
+// же, как по полю code ответов REST API
+const grpcErrorDomain = "storage"
+
+// grpcFileService реализует публичный gRPC API загрузки и скачивания файлов поверх тех же
+// операций, что и REST API: проверок содержимого, прав доступа, событий и аудита
+type grpcFileService struct {
+	storagepb.UnimplementedFileServiceServer
+	server *StreamingAPIServer
+}
+
+// NewGRPCServer создает gRPC сервер с публичным API файлов. Start запускает его сам на
+// адресе grpc_listen; приложение, встраивающее API сервер через Handler, может
+// обслуживать его на собственном слушателе. С tls_cert_file сервер принимает только TLS
+func (s *StreamingAPIServer) NewGRPCServer() (*grpc.Server, error) {
+	options := []grpc.ServerOption{grpc.StreamInterceptor(s.grpcAuth)}
+	if cfg := s.current().config; cfg.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(options...)
+	storagepb.RegisterFileServiceServer(server, &grpcFileService{server: s})
+	return server, nil
+}
+
+// grpcAuth проверяет ключ API из метаданных authorization (Bearer) или x-api-key так же,
+// как authMiddleware, и передает имя клиента обработчику через контекст
+func (s *StreamingAPIServer) grpcAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	settings := s.current()
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	key := firstMetadata(md, "x-api-key")
+	if bearer, ok := strings.CutPrefix(firstMetadata(md, "authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if name, ok := settings.authenticate(key); ok {
+		ctx := withPrincipal(stream.Context(), name)
+		return handler(srv, &grpcServerStream{ServerStream: stream, ctx: ctx})
+	}
+	if len(settings.apiKeys) > 0 {
+		return grpcError(stream.Context(), http.StatusUnauthorized, apierror.APIKeyRequired)
+	}
+	return handler(srv, stream)
+}
+
+// grpcServerStream подменяет контекст потока
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// firstMetadata возвращает первое значение ключа метаданных gRPC
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcError возвращает ошибку gRPC с сообщением code на языке из метаданных
+// accept-language. Код ошибки передается в ErrorInfo, а HTTP статус переводится в
+// ближайший код gRPC
+func grpcError(ctx context.Context, httpStatus int, code apierror.Code, args ...interface{}) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	language := apierror.Negotiate(firstMetadata(md, "accept-language"))
+
+	st := status.New(grpcCode(httpStatus), apierror.Message(language, code, args...))
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(code), Domain: grpcErrorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcRequestError возвращает ошибку запроса как ошибку gRPC
+func grpcRequestError(ctx context.Context, err *requestError) error {
+	return grpcError(ctx, err.status, err.code, err.args...)
+}
+
+// grpcCatalogError возвращает ошибку каталога как ошибку gRPC
+func grpcCatalogError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		return grpcError(ctx, http.StatusNotFound, apierror.FileNotFound)
+	case errors.Is(err, catalog.ErrNoLeader):
+		return grpcError(ctx, http.StatusServiceUnavailable, apierror.CatalogUnavailable)
+	case errors.Is(err, catalog.ErrConflict):
+		return grpcError(ctx, http.StatusConflict, apierror.MetadataConflict)
+	default:
+		return grpcError(ctx, http.StatusInternalServerError, apierror.CatalogError, err)
+	}
+}
+
+// grpcCode переводит HTTP статус ошибки в код gRPC
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return codes.ResourceExhausted
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// UploadStream принимает файлы из потока: заголовок, данные и признак конца каждого файла.
+// Каждый файл сохраняется так же, как при загрузке через REST API, и записывается в
+// журнал аудита. Ошибка любого файла завершает поток
+func (g *grpcFileService) UploadStream(stream storagepb.FileService_UploadStreamServer) error {
+	ctx := stream.Context()
+	var header *storagepb.UploadHeader
+	data := g.server.newUploadSpool()
+	defer func() { data.Close() }()
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			if header == nil {
+				return nil
+			}
+			// Закрытие потока завершает последний файл
+			req = &storagepb.UploadRequest{Message: &storagepb.UploadRequest_Finish{Finish: &storagepb.UploadFinish{}}}
+		} else if err != nil {
+			return err
+		}
+
+		switch message := req.Message.(type) {
+		case *storagepb.UploadRequest_Header:
+			if header != nil || message.Header.GetFilename() == "" {
+				return grpcError(ctx, http.StatusBadRequest, apierror.UploadHeaderRequired)
+			}
+			header = message.Header
+
+		case *storagepb.UploadRequest_Data:
+			if header == nil {
+				return grpcError(ctx, http.StatusBadRequest, apierror.UploadHeaderRequired)
+			}
+			if maxFileSize := g.server.current().config.MaxFileSize; data.size+int64(len(message.Data)) > maxFileSize {
+				return grpcError(ctx, http.StatusRequestEntityTooLarge, apierror.FileTooLarge, maxFileSize)
+			}
+			if _, err := data.Write(message.Data); err != nil {
+				log.Printf("Ошибка временного файла загрузки: %v", err)
+				return grpcError(ctx, http.StatusInternalServerError, apierror.UploadSpoolFailed)
+			}
+
+		case *storagepb.UploadRequest_Finish:
+			if header == nil {
+				return grpcError(ctx, http.StatusBadRequest, apierror.UploadHeaderRequired)
+			}
+			fileData, spoolErr := data.bytes()
+			if spoolErr != nil {
+				log.Printf("Ошибка временного файла загрузки: %v", spoolErr)
+				return grpcError(ctx, http.StatusInternalServerError, apierror.UploadSpoolFailed)
+			}
+			metadata, storeErr := g.server.storeGRPCUpload(ctx, header, fileData)
+			if storeErr != nil {
+				return storeErr
+			}
+			if sendErr := stream.Send(&storagepb.UploadResponse{File: storagepb.NewFile(metadata)}); sendErr != nil {
+				return sendErr
+			}
+			header = nil
+			data.Close()
+			data = g.server.newUploadSpool()
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// storeGRPCUpload проверяет и сохраняет файл, загруженный через gRPC, и записывает
+// загрузку в журнал аудита с HTTP статусом, который получил бы такой же запрос REST API
+func (s *StreamingAPIServer) storeGRPCUpload(ctx context.Context, header *storagepb.UploadHeader, data []byte) (*chunking.FileMetadata, error) {
+	ctx, details := audit.WithDetails(ctx)
+	metadata, reqErr := s.checkAndStoreGRPCUpload(ctx, header, data)
+
+	if s.audit != nil {
+		actor := contextPrincipal(ctx)
+		if actor == "" {
+			actor = "anonymous"
+		}
+		entry := audit.Entry{
+			Actor:   actor,
+			Action:  "GRPC " + storagepb.FileService_UploadStream_FullMethodName,
+			Method:  "GRPC",
+			Path:    storagepb.FileService_UploadStream_FullMethodName,
+			Files:   details.Files(),
+			Threat:  details.Threat(),
+			Status:  http.StatusOK,
+			Success: reqErr == nil,
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			entry.ClientIP, _, _ = net.SplitHostPort(p.Addr.String())
+		}
+		if reqErr != nil {
+			entry.Status = reqErr.status
+			entry.Error = reqErr.Error()
+		}
+		s.audit.Record(entry)
+	}
+
+	if reqErr != nil {
+		return nil, grpcRequestError(ctx, reqErr)
+	}
+	return metadata, nil
+}
+
+// checkAndStoreGRPCUpload выполняет проверки загрузки из uploadFile и сохраняет файл
+func (s *StreamingAPIServer) checkAndStoreGRPCUpload(ctx context.Context, header *storagepb.UploadHeader, data []byte) (*chunking.FileMetadata, *requestError) {
+	if expected := header.GetContentSha256(); expected != "" {
+		expected, ok := parseSHA256(expected)
+		if !ok {
+			return nil, newRequestError(http.StatusBadRequest, apierror.InvalidChecksum)
+		}
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			return nil, newRequestError(http.StatusBadRequest, apierror.ContentSHA256Mismatch, actual, expected)
+		}
+	}
+
+	metadata, err := s.storeFile(ctx, uploadInfo{
+		Name:        header.GetFilename(),
+		ContentType: header.GetContentType(),
+		Path:        cleanFilePath(header.GetPath()),
+		Public:      header.GetPublic(),
+		Owner:       contextPrincipal(ctx),
+	}, data)
+	if err != nil {
+		return nil, newRequestError(storeErrorStatus(err), storeErrorCode(err, apierror.StoreFailed), err)
+	}
+	return metadata, nil
+}
+
+// DownloadStream отвечает на каждый запрос потока заголовком с метаданными файла и
+// длиной диапазона, а затем его данными. Целый файл сверяется с контрольной суммой по
+// мере передачи, диапазон - по контрольным суммам покрывающих его кусков
+func (g *grpcFileService) DownloadStream(stream storagepb.FileService_DownloadStreamServer) error {
+	ctx := stream.Context()
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := g.server.sendGRPCDownload(ctx, stream, req); err != nil {
+			return err
+		}
+	}
+}
+
+// sendGRPCDownload проверяет доступ к файлу и отправляет запрошенный диапазон
+func (s *StreamingAPIServer) sendGRPCDownload(ctx context.Context, stream storagepb.FileService_DownloadStreamServer, req *storagepb.DownloadRequest) error {
+	metadata, err := s.catalog.Get(ctx, req.GetFileId())
+	if err != nil {
+		return grpcCatalogError(ctx, err)
+	}
+	principal := contextPrincipal(ctx)
+	if !s.current().canAccess(principal, metadata, accessRead) {
+		return grpcError(ctx, http.StatusNotFound, apierror.FileNotFound)
+	}
+
+	offset, length := req.GetOffset(), req.GetLength()
+	if length == 0 {
+		length = metadata.Size - offset
+	}
+	if offset < 0 || length < 0 || offset+length > metadata.Size {
+		return grpcError(ctx, http.StatusRequestedRangeNotSatisfiable, apierror.RangeNotSatisfiable)
+	}
+
+	header := &storagepb.DownloadHeader{File: storagepb.NewFile(metadata), Offset: offset, Length: length}
+	if err := stream.Send(&storagepb.DownloadResponse{Message: &storagepb.DownloadResponse_Header{Header: header}}); err != nil {
+		return err
+	}
+	if length == 0 {
+		return nil
+	}
+
+	writer := &grpcDataWriter{stream: stream}
+	if offset == 0 && length == metadata.Size {
+		if err := s.writeFileContent(ctx, writer, metadata); err != nil {
+			return grpcError(ctx, http.StatusInternalServerError, apierror.AssembleFailed, err)
+		}
+		return nil
+	}
+
+	window := byteRange{start: offset, end: offset + length - 1}
+	covering, skip := chunksForRange(metadata.Chunks, window)
+	chunks := s.readChunks(ctx, covering)
+	defer chunks.close()
+	remaining := length
+	for range covering {
+		chunk, err := chunks.next()
+		if err != nil {
+			return grpcError(ctx, http.StatusInternalServerError, apierror.AssembleFailed, err)
+		}
+		part := chunk.Data[min(skip, int64(len(chunk.Data))):]
+		part = part[:min(remaining, int64(len(part)))]
+		if _, err := writer.Write(part); err != nil {
+			return err
+		}
+		remaining -= int64(len(part))
+		skip = 0
+	}
+	if remaining != 0 {
+		return grpcError(ctx, http.StatusInternalServerError, apierror.ChunkSizesMismatch)
+	}
+
+	// Скачиванием считается только диапазон с начала файла, как и в REST API
+	s.recordAccess(metadata.ID, offset == 0, length)
+	return nil
+}
+
+// grpcDataWriter отправляет записанные данные сообщениями data, разбивая их на части
+// не больше grpcMessageSize
+type grpcDataWriter struct {
+	stream storagepb.FileService_DownloadStreamServer
+}
+
+func (w *grpcDataWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		part := p[written:min(written+grpcMessageSize, len(p))]
+		if err := w.stream.Send(&storagepb.DownloadResponse{Message: &storagepb.DownloadResponse_Data{Data: part}}); err != nil {
+			return written, err
+		}
+		written += len(part)
+	}
+	return written, nil
+}
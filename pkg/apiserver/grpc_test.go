@@ -0,0 +1,132 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/client/storagepb"
+	"TestCase/pkg/config"
+)
+
+// newGRPCTestClient запускает API сервер с gRPC API в памяти и возвращает клиент к нему
+func newGRPCTestClient(t *testing.T, configure func(cfg *config.Config)) *client.GRPCClient {
+	server := newTestServer(t, withStorageNodes(1), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 3
+	}), withConfig(configure))
+	grpcServer, err := server.NewGRPCServer()
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	grpcClient, err := client.NewGRPCClient("passthrough:///bufconn",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { grpcClient.Close() })
+	return grpcClient
+}
+
+func TestGRPCUploadDownload(t *testing.T) {
+	ctx := context.Background()
+	grpcClient := newGRPCTestClient(t, nil)
+
+	content := bytes.Repeat([]byte("0123456789"), 100_000)
+	metadata, err := grpcClient.UploadReader(ctx, "digits.txt", bytes.NewReader(content), int64(len(content)),
+		client.WithPath("docs/digits.txt"), client.WithContentType("text/plain"))
+	require.NoError(t, err)
+	assert.Equal(t, "digits.txt", metadata.OriginalName)
+	assert.Equal(t, "docs/digits.txt", metadata.Path)
+	assert.Equal(t, int64(len(content)), metadata.Size)
+
+	var downloaded bytes.Buffer
+	var stats client.TransferStats
+	got, err := grpcClient.Download(ctx, metadata.ID, &downloaded, client.WithStats(&stats))
+	require.NoError(t, err)
+	assert.Equal(t, metadata.Checksum, got.Checksum)
+	assert.Equal(t, content, downloaded.Bytes())
+	assert.Equal(t, int64(len(content)), stats.Bytes)
+
+	// Диапазон на стыке кусков
+	var part bytes.Buffer
+	_, err = grpcClient.DownloadRange(ctx, metadata.ID, 333_330, 20, &part)
+	require.NoError(t, err)
+	assert.Equal(t, content[333_330:333_350], part.Bytes())
+
+	_, err = grpcClient.DownloadRange(ctx, metadata.ID, int64(len(content)), 1, io.Discard)
+	var grpcErr *client.GRPCError
+	require.ErrorAs(t, err, &grpcErr)
+	assert.Equal(t, codes.OutOfRange, grpcErr.Code)
+	assert.Equal(t, "range_not_satisfiable", grpcErr.Reason)
+
+	_, err = grpcClient.Download(ctx, "missing", io.Discard)
+	assert.True(t, errors.Is(err, client.ErrNotFound))
+}
+
+func TestGRPCUploadStreamSeveralFiles(t *testing.T) {
+	ctx := context.Background()
+	grpcClient := newGRPCTestClient(t, nil)
+
+	stream, err := grpcClient.FileService().UploadStream(ctx)
+	require.NoError(t, err)
+	send := func(req *storagepb.UploadRequest) {
+		require.NoError(t, stream.Send(req))
+	}
+
+	send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Header{Header: &storagepb.UploadHeader{Filename: "first.txt"}}})
+	send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Data{Data: []byte("first file")}})
+	send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Finish{Finish: &storagepb.UploadFinish{}}})
+	first, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "first.txt", first.File.OriginalName)
+
+	// Закрытие потока завершает второй файл без признака конца
+	send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Header{Header: &storagepb.UploadHeader{Filename: "second.txt"}}})
+	send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Data{Data: []byte("second file")}})
+	require.NoError(t, stream.CloseSend())
+	second, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("second file")), second.File.Size)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	var downloaded bytes.Buffer
+	_, err = grpcClient.Download(ctx, first.File.Id, &downloaded)
+	require.NoError(t, err)
+	assert.Equal(t, "first file", downloaded.String())
+}
+
+func TestGRPCRequiresAPIKey(t *testing.T) {
+	ctx := context.Background()
+	grpcClient := newGRPCTestClient(t, func(cfg *config.Config) {
+		cfg.APIKeys = []string{"alice:secret"}
+	})
+
+	_, err := grpcClient.UploadReader(ctx, "a.txt", strings.NewReader("data"), 4)
+	assert.Equal(t, "api_key_required", client.ErrorCode(err))
+
+	grpcClient.SetAPIKey("secret")
+	metadata, err := grpcClient.UploadReader(ctx, "a.txt", strings.NewReader("data"), 4,
+		client.WithContentSHA256("3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", metadata.Owner)
+
+	_, err = grpcClient.UploadReader(ctx, "b.txt", strings.NewReader("data"), 4, client.WithContentSHA256(strings.Repeat("0", 64)))
+	assert.Equal(t, "content_sha256_mismatch", client.ErrorCode(err))
+}
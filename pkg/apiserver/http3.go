@@ -0,0 +1,33 @@
+package apiserver
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
+
+	"TestCase/pkg/config"
+)
+
+// newHTTP3Server создает экспериментальный сервер HTTP/3 (QUIC) на UDP порту с тем же
+// номером, что и TCP порт API сервера. Основным протоколом остается HTTP/1.1: клиенты
+// узнают о HTTP/3 из заголовка Alt-Svc ответов на скачивание
+func newHTTP3Server(cfg *config.Config, handler http.Handler) *http3.Server {
+	return &http3.Server{
+		Addr:           cfg.GetAPIAddress(),
+		Handler:        handler,
+		MaxHeaderBytes: cfg.HTTPMaxHeaderBytes,
+	}
+}
+
+// advertiseHTTP3 предлагает клиенту скачивать файлы по HTTP/3 заголовком Alt-Svc.
+// Клиенты с поддержкой QUIC переходят на него со следующего запроса, остальные его не замечают
+func (s *StreamingAPIServer) advertiseHTTP3(c *gin.Context) {
+	if s.http3 == nil || c.Request.ProtoMajor == 3 {
+		return
+	}
+	if err := s.http3.SetQuicHeaders(c.Writer.Header()); err != nil {
+		log.Printf("Не удалось добавить заголовок Alt-Svc: %v", err)
+	}
+}
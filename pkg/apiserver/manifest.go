@@ -0,0 +1,56 @@
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+	"TestCase/pkg/token"
+)
+
+// getFileManifest возвращает манифест файла: куски, их контрольные суммы и серверы
+// хранения. По нему клиент скачивает куски напрямую и параллельно, минуя API сервер.
+// Серверы каждого куска упорядочены так, что первыми идут серверы региона клиента из
+// параметра region, а без него - региона API сервера
+func (s *StreamingAPIServer) getFileManifest(c *gin.Context) {
+	metadata, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+
+	settings := s.current()
+	region := c.DefaultQuery("region", settings.config.Region)
+	ordered := *metadata
+	ordered.Chunks = make([]chunking.FileChunk, len(metadata.Chunks))
+	for i, chunk := range metadata.Chunks {
+		nodes := settings.preferRegion(chunk.Nodes(), region)
+		if len(nodes) > 0 {
+			chunk.Node, chunk.Replicas = nodes[0], nodes[1:]
+		}
+		ordered.Chunks[i] = chunk
+	}
+	manifest := chunking.NewManifest(&ordered, storage.NodeURL)
+
+	// Серверы хранения с internal_secret не отдают куски без подписи, поэтому клиент
+	// получает токен чтения каждого куска на срок upload_token_ttl
+	if settings.config.InternalSecret != "" {
+		expiresAt := time.Now().Add(settings.config.UploadTokenTTL)
+		for i := range manifest.Chunks {
+			readToken, err := token.Sign(settings.config.InternalSecret, storage.ReadTokenPurpose, storage.ReadClaims{ChunkID: manifest.Chunks[i].ID}, expiresAt)
+			if err != nil {
+				writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+				return
+			}
+			manifest.Chunks[i].Token = readToken
+		}
+	}
+
+	c.JSON(http.StatusOK, manifest)
+
+	// Данные при прямом скачивании отдают серверы хранения, поэтому байты не учитываются
+	s.recordAccess(metadata.ID, true, 0)
+}
@@ -0,0 +1,81 @@
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// getFileMerkleProof возвращает корень Merkle дерева файла и пути доказательства для
+// кусков: параметр chunk задает один кусок, offset и length - диапазон байт, и тогда в
+// ответ попадают все покрывающие его куски. Без параметров возвращается только корень.
+// По доказательству клиент проверяет скачанные куски, не получая остальные
+func (s *StreamingAPIServer) getFileMerkleProof(c *gin.Context) {
+	metadata, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+
+	indexes, ok := merkleIndexes(c, metadata)
+	if !ok {
+		return
+	}
+
+	proof, err := chunking.NewMerkleProof(metadata, indexes)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+		return
+	}
+	c.JSON(http.StatusOK, proof)
+}
+
+// merkleIndexes возвращает номера кусков из параметров chunk или offset и length.
+// При неверных параметрах отвечает клиенту сам и возвращает false
+func merkleIndexes(c *gin.Context, metadata *chunking.FileMetadata) ([]int, bool) {
+	if value, ok := c.GetQuery("chunk"); ok {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(metadata.Chunks) {
+			writeError(c, http.StatusBadRequest, apierror.InvalidMerkleChunk, max(len(metadata.Chunks)-1, 0))
+			return nil, false
+		}
+		return []int{index}, true
+	}
+
+	offsetValue, hasOffset := c.GetQuery("offset")
+	lengthValue, hasLength := c.GetQuery("length")
+	if !hasOffset && !hasLength {
+		return nil, true
+	}
+
+	// length 0 или без значения - до конца файла
+	offset, err := strconv.ParseInt(offsetValue, 10, 64)
+	if !hasOffset {
+		offset, err = 0, nil
+	}
+	var length int64
+	if err == nil && lengthValue != "" {
+		length, err = strconv.ParseInt(lengthValue, 10, 64)
+	}
+	if err != nil || offset < 0 || offset >= metadata.Size || length < 0 || length > metadata.Size-offset {
+		writeError(c, http.StatusBadRequest, apierror.InvalidMerkleRange, metadata.Size)
+		return nil, false
+	}
+	if length == 0 {
+		length = metadata.Size - offset
+	}
+
+	var indexes []int
+	var start int64
+	for i, chunk := range metadata.Chunks {
+		end := start + chunk.Size
+		if end > offset && start < offset+length {
+			indexes = append(indexes, i)
+		}
+		start = end
+	}
+	return indexes, true
+}
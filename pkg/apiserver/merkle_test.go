@@ -0,0 +1,76 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+)
+
+func TestMerkleVerifiedRange(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t, withStorageNodes(3), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 3
+		cfg.SmallFileThreshold = 0
+		cfg.ChunkCacheSize = 0
+	}))
+
+	api := server.api
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	metadata, err := api.UploadReader(ctx, "merkle.txt", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 3)
+	root, err := chunking.FileMerkleRoot(metadata)
+	require.NoError(t, err)
+	assert.Equal(t, root, metadata.MerkleRoot)
+
+	resp, err := http.Get(server.url + "/api/v1/files/" + metadata.ID)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, root, resp.Header.Get("X-Merkle-Root"))
+
+	// Диапазон внутри второго куска и диапазон через границу кусков
+	data, err := api.ReadVerifiedRangeContext(ctx, metadata.ID, 14, 4)
+	require.NoError(t, err)
+	assert.Equal(t, content[14:18], string(data))
+	data, err = api.ReadVerifiedRangeContext(ctx, metadata.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, content[10:], string(data))
+
+	proof, err := api.GetMerkleProofContext(ctx, metadata.ID, 14, 4)
+	require.NoError(t, err)
+	require.Len(t, proof.Chunks, 1)
+	assert.Equal(t, 1, proof.Chunks[0].Index)
+
+	for _, query := range []string{"chunk=3", "chunk=x", "offset=36", "offset=10&length=27"} {
+		resp, err := http.Get(server.url + "/api/v1/files/" + metadata.ID + "/merkle?" + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+
+	// Подмененный кусок с собственной верной контрольной суммой не сходится с корнем
+	chunk := metadata.Chunks[2]
+	storageClient := server.current().clientForNode(chunk.Node)
+	require.NoError(t, storageClient.DeleteChunkContext(ctx, chunk.ID))
+	forged := []byte(strings.ToUpper(content[24:]))
+	require.NoError(t, storageClient.StoreChunkContext(ctx, &chunking.FileChunk{
+		ID:       chunk.ID,
+		FileID:   metadata.ID,
+		Data:     forged,
+		Size:     int64(len(forged)),
+		Checksum: calculateChecksum(chunk.Algorithm, forged),
+	}))
+
+	_, err = api.ReadVerifiedRangeContext(ctx, metadata.ID, 30, 2)
+	assert.ErrorIs(t, err, chunking.ErrMerkleMismatch)
+	data, err = api.ReadVerifiedRangeContext(ctx, metadata.ID, 0, 12)
+	require.NoError(t, err)
+	assert.Equal(t, content[:12], string(data))
+}
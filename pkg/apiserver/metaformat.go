@@ -0,0 +1,58 @@
+package apiserver
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/metaformat"
+)
+
+// writeMetadata отвечает метаданными файла в формате, выбранном по заголовку Accept:
+// JSON, protobuf или MessagePack
+func writeMetadata(c *gin.Context, status int, metadata *chunking.FileMetadata) {
+	format := metaformat.Negotiate(c.GetHeader("Accept"))
+	c.Writer.Header().Add("Vary", "Accept")
+	if format == metaformat.JSON {
+		c.JSON(status, metadata)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := metaformat.EncodeFile(&buf, format, metadata); err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+		return
+	}
+	c.Data(status, format.ContentType(), buf.Bytes())
+}
+
+// writeMetadataList отвечает метаданными нескольких файлов в формате, выбранном по
+// заголовку Accept; в protobuf - сообщением FileList
+func writeMetadataList(c *gin.Context, status int, files []*chunking.FileMetadata) {
+	format := metaformat.Negotiate(c.GetHeader("Accept"))
+	c.Writer.Header().Add("Vary", "Accept")
+	if format == metaformat.JSON {
+		c.JSON(status, files)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := metaformat.EncodeFiles(&buf, format, files); err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+		return
+	}
+	c.Data(status, format.ContentType(), buf.Bytes())
+}
+
+// requestMetadataFormat возвращает формат метаданных в теле запроса по Content-Type.
+// Тело без двоичного формата читается как JSON
+func requestMetadataFormat(c *gin.Context) metaformat.Format {
+	format, ok := metaformat.FromContentType(c.GetHeader("Content-Type"))
+	if !ok {
+		return metaformat.JSON
+	}
+	return format
+}
@@ -0,0 +1,55 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/metaformat"
+)
+
+func TestMetadataContentNegotiation(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t)
+	apiClient := server.api
+	stored := &chunking.FileMetadata{
+		ID:           "file-1",
+		OriginalName: "data.bin",
+		Size:         10,
+		Checksum:     "0123",
+		ChunkCount:   2,
+		Chunks: []chunking.FileChunk{
+			{ID: "c0", Index: 0, FileID: "file-1", Size: 5, Checksum: "a", Node: "node1:8081", Replicas: []string{"node2:8081"}},
+			{ID: "c1", Index: 1, FileID: "file-1", Size: 5, Checksum: "b", Node: "node2:8081"},
+		},
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, server.catalog.Put(ctx, stored))
+	expected, err := server.catalog.Get(ctx, stored.ID)
+	require.NoError(t, err)
+
+	for _, format := range []metaformat.Format{metaformat.JSON, metaformat.Protobuf, metaformat.MessagePack} {
+		apiClient.SetMetadataFormat(format)
+		metadata, err := apiClient.GetFileInfoContext(ctx, stored.ID)
+		require.NoError(t, err, format)
+		assert.Equal(t, expected, metadata, format)
+	}
+
+	// Ответ в формате, который выбирает сервер по Accept
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/file-1/info", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/x-protobuf")
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, metaformat.MIMEProtobuf, recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Header().Values("Vary"), "Accept")
+	metadata, err := metaformat.DecodeFile(recorder.Body, metaformat.Protobuf)
+	require.NoError(t, err)
+	assert.Equal(t, expected, metadata)
+}
@@ -0,0 +1,101 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+	"TestCase/pkg/mirror"
+)
+
+// mirrorEventBuffer - размер буфера событий зеркала. Зеркало только ставит файлы в
+// очередь, поэтому буфер переполняется лишь при очень частых изменениях; потерянные
+// события подхватит сверка с каталогом
+const mirrorEventBuffer = 10000
+
+// mirrorSource - файлы этого API сервера для зеркала
+type mirrorSource struct {
+	s *StreamingAPIServer
+}
+
+func (m mirrorSource) Get(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	return m.s.catalog.Get(ctx, id)
+}
+
+func (m mirrorSource) List(ctx context.Context) ([]*chunking.FileMetadata, error) {
+	return m.s.catalog.List(ctx)
+}
+
+// Open передает содержимое файла через канал, чтобы не держать файл в памяти целиком.
+// Чтение копии не учитывается в статистике скачиваний
+func (m mirrorSource) Open(ctx context.Context, metadata *chunking.FileMetadata) (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(m.s.copyFileContent(ctx, writer, metadata))
+	}()
+	return reader, nil
+}
+
+// startMirror запускает зеркалирование файлов в приемник mirror_url
+func (s *StreamingAPIServer) startMirror(cfg *config.Config) error {
+	target := client.NewAPIClient(cfg.MirrorURL)
+	target.SetAPIKey(cfg.MirrorAPIKey)
+
+	replicator, err := mirror.New(mirror.Config{
+		StatePath:      cfg.MirrorStateFile,
+		Concurrency:    cfg.MirrorConcurrency,
+		MaxRetries:     cfg.MirrorMaxRetries,
+		RetryDelay:     cfg.MirrorRetryDelay,
+		ResyncInterval: cfg.MirrorResyncInterval,
+	}, mirrorSource{s: s}, target)
+	if err != nil {
+		return err
+	}
+
+	eventsChan, unsubscribe := s.events.Subscribe(mirrorEventBuffer)
+	s.mirror = replicator
+	s.mirrorTarget = cfg.MirrorURL
+	s.stopMirror = unsubscribe
+	s.mirrorStopped = make(chan struct{})
+
+	go func() {
+		defer close(s.mirrorStopped)
+		replicator.Consume(eventsChan)
+	}()
+	return nil
+}
+
+// mirrorStatus - состояние зеркалирования в ответе API
+type mirrorStatus struct {
+	Enabled bool   `json:"enabled"`
+	Target  string `json:"target,omitempty"`
+	*mirror.Status
+}
+
+// getMirrorStatus возвращает состояние зеркалирования и задержку отражения изменений
+func (s *StreamingAPIServer) getMirrorStatus(c *gin.Context) {
+	if s.mirror == nil {
+		c.JSON(http.StatusOK, mirrorStatus{})
+		return
+	}
+
+	status := s.mirror.Status()
+	c.JSON(http.StatusOK, mirrorStatus{Enabled: true, Target: s.mirrorTarget, Status: &status})
+}
+
+// resyncMirror запускает внеочередную сверку приемника с каталогом
+func (s *StreamingAPIServer) resyncMirror(c *gin.Context) {
+	if s.mirror == nil {
+		writeError(c, http.StatusConflict, apierror.MirrorDisabled)
+		return
+	}
+
+	s.mirror.Resync()
+	c.JSON(http.StatusAccepted, gin.H{"message": "Сверка зеркала запущена"})
+}
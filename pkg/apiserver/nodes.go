@@ -0,0 +1,177 @@
+package apiserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+)
+
+// Состояния сервера хранения с точки зрения размещения кусков
+const (
+	nodeActive   = "active"   // принимает новые куски
+	nodeCordoned = "cordoned" // обслуживание: куски читаются и удаляются, новые не размещаются
+	nodeDraining = "draining" // куски переносятся на другие серверы
+	nodeDrained  = "drained"  // кусков не осталось, сервер можно выключать
+	nodeFailed   = "failed"   // вывод прерван ошибками; можно запустить повторно
+)
+
+// drainProgress - ход вывода сервера хранения из эксплуатации
+type drainProgress struct {
+	TotalChunks  int        `json:"total_chunks"`  // куски, найденные на сервере
+	MovedChunks  int        `json:"moved_chunks"`  // перенесены на другие серверы
+	FailedChunks int        `json:"failed_chunks"` // не удалось перенести
+	MovedBytes   int64      `json:"moved_bytes"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	Errors       []string   `json:"errors,omitempty"` // последние ошибки переноса
+}
+
+// nodeStatus - состояние сервера хранения, заданное администратором
+type nodeStatus struct {
+	State string         `json:"state"`
+	Drain *drainProgress `json:"drain,omitempty"`
+
+	cancel context.CancelFunc // останавливает перенос кусков
+}
+
+// nodeRegistry хранит состояния серверов хранения. Серверы без записи активны.
+// Состояния хранятся в памяти этого API сервера и не переживают перезапуск
+type nodeRegistry struct {
+	mutex sync.Mutex
+	nodes map[string]*nodeStatus
+}
+
+// newNodeRegistry создает реестр, в котором все серверы активны
+func newNodeRegistry() *nodeRegistry {
+	return &nodeRegistry{nodes: make(map[string]*nodeStatus)}
+}
+
+// schedulable возвращает серверы, на которые можно размещать новые куски
+func (r *nodeRegistry) schedulable(servers []string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if status, ok := r.nodes[server]; !ok || status.State == nodeActive {
+			result = append(result, server)
+		}
+	}
+	return result
+}
+
+// snapshot возвращает копию состояния сервера для ответа API
+func (r *nodeRegistry) snapshot(node string) nodeStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	status, ok := r.nodes[node]
+	if !ok {
+		return nodeStatus{State: nodeActive}
+	}
+
+	result := nodeStatus{State: status.State}
+	if status.Drain != nil {
+		drain := *status.Drain
+		drain.Errors = append([]string(nil), status.Drain.Errors...)
+		result.Drain = &drain
+	}
+	return result
+}
+
+// update изменяет состояние сервера под блокировкой реестра
+func (r *nodeRegistry) update(node string, change func(status *nodeStatus)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	status, ok := r.nodes[node]
+	if !ok {
+		status = &nodeStatus{State: nodeActive}
+		r.nodes[node] = status
+	}
+	change(status)
+}
+
+// knownNode сообщает, входит ли сервер в текущий состав
+func (s *StreamingAPIServer) knownNode(node string) bool {
+	return s.current().findClient(node) != nil
+}
+
+// listNodes возвращает состояние всех серверов хранения
+func (s *StreamingAPIServer) listNodes(c *gin.Context) {
+	settings := s.current()
+	servers := settings.config.StorageServers
+
+	nodes := make([]gin.H, 0, len(servers))
+	for _, server := range servers {
+		status := s.nodes.snapshot(server)
+		nodes = append(nodes, gin.H{"node": server, "region": settings.regions[server], "state": status.State, "drain": status.Drain})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"nodes": nodes})
+}
+
+// getNode возвращает состояние сервера хранения и ход его вывода
+func (s *StreamingAPIServer) getNode(c *gin.Context) {
+	node := c.Param("id")
+	if !s.knownNode(node) {
+		writeError(c, http.StatusNotFound, apierror.NodeNotFound)
+		return
+	}
+
+	status := s.nodes.snapshot(node)
+	c.JSON(http.StatusOK, gin.H{
+		"node":           node,
+		"region":         s.current().regions[node],
+		"state":          status.State,
+		"drain":          status.Drain,
+		"safe_to_remove": status.State == nodeDrained,
+	})
+}
+
+// cordonNode переводит сервер хранения в режим обслуживания: уже сохраненные куски
+// читаются как обычно, а новые размещаются на остальных серверах. Куски не переносятся,
+// поэтому сервер можно перезапустить и вернуть в работу без перераспределения данных
+func (s *StreamingAPIServer) cordonNode(c *gin.Context) {
+	s.changeNodeState(c, nodeActive, nodeCordoned, "Сервер хранения %s переведен в режим обслуживания")
+}
+
+// uncordonNode возвращает сервер хранения из режима обслуживания
+func (s *StreamingAPIServer) uncordonNode(c *gin.Context) {
+	s.changeNodeState(c, nodeCordoned, nodeActive, "Сервер хранения %s возвращен из режима обслуживания")
+}
+
+// changeNodeState переводит сервер из состояния from в to. Повторный запрос
+// для сервера, уже находящегося в состоянии to, ничего не меняет
+func (s *StreamingAPIServer) changeNodeState(c *gin.Context, from, to, message string) {
+	node := c.Param("id")
+	if !s.knownNode(node) {
+		writeError(c, http.StatusNotFound, apierror.NodeNotFound)
+		return
+	}
+
+	var current string
+	s.nodes.update(node, func(status *nodeStatus) {
+		current = status.State
+		if current == from {
+			status.State = to
+		}
+	})
+
+	switch current {
+	case from:
+		log.Printf(message, node)
+	case to:
+	default:
+		writeError(c, http.StatusConflict, apierror.NodeState, current)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"node": node, "state": to})
+}
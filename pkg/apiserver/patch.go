@@ -0,0 +1,163 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// patchFile перезаписывает часть содержимого файла данными из поля file формы, начиная
+// с байта offset. Запись может выходить за конец файла и тогда увеличивает его, но не
+// может начинаться дальше конца. На серверы хранения передаются только куски,
+// пересекающиеся с записанным диапазоном
+func (s *StreamingAPIServer) patchFile(c *gin.Context) {
+	current, ok := s.loadFile(c, accessWrite)
+	if !ok {
+		return
+	}
+	maxFileSize := s.current().config.MaxFileSize
+
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	patch, _, ok := readFormFile(c, spool, maxFileSize)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.PostForm("offset"), 10, 64)
+	if err != nil || offset < 0 || offset > current.Size {
+		writeError(c, http.StatusBadRequest, apierror.InvalidOffset, current.Size)
+		return
+	}
+	if len(patch) == 0 {
+		writeError(c, http.StatusBadRequest, apierror.EmptyPatch)
+		return
+	}
+	if offset+int64(len(patch)) > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return
+	}
+
+	if err := newWriteCondition(c.Request.Header).check(current); err != nil {
+		writeError(c, http.StatusPreconditionFailed, apierror.PreconditionFailed)
+		return
+	}
+
+	metadata, err := s.patchContent(c.Request.Context(), current, offset, patch)
+	if err != nil {
+		writeReplaceError(c, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
+
+// patchContent записывает patch в файл с байта offset. Куски, пересекающиеся с
+// диапазоном записи, заменяются новыми кусками тех же размеров, данные за прежним концом
+// файла делятся на куски не больше, чем при обычной загрузке. Остальные куски не
+// изменяются. Файл целиком собирается в памяти, чтобы проверить его так же, как при
+// обычной загрузке, и посчитать контрольную сумму
+func (s *StreamingAPIServer) patchContent(ctx context.Context, current *chunking.FileMetadata, offset int64, patch []byte) (*chunking.FileMetadata, error) {
+	settings := s.current()
+	end := offset + int64(len(patch))
+	size := max(current.Size, end)
+
+	stored, err := s.collectChunks(ctx, current.Chunks)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить куски файла: %w", err)
+	}
+	fileData, err := s.reconstructFileInMemory(stored)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось собрать файл: %w", err)
+	}
+	fileData = append(fileData, make([]byte, size-int64(len(fileData)))...)
+	copy(fileData[offset:], patch)
+
+	info := uploadInfo{
+		Name:        current.OriginalName,
+		ContentType: current.ContentType,
+		Path:        current.Path,
+		Public:      current.Public,
+		Owner:       current.Owner,
+		DropBox:     current.DropBox,
+	}
+	detectedType, err := s.checkFile(ctx, settings, info, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks, fresh, stale []chunking.FileChunk
+	var positions []int
+	addFresh := func(part []byte) {
+		chunk := chunking.FileChunk{
+			ID:       chunking.NewChunkID(),
+			FileID:   current.ID,
+			Index:    len(chunks),
+			Data:     part,
+			Checksum: calculateChecksum(settings.hashAlgorithm, part),
+			Size:     int64(len(part)),
+
+			Algorithm: settings.hashAlgorithm,
+		}
+		positions = append(positions, len(chunks))
+		chunks = append(chunks, chunk)
+		fresh = append(fresh, chunk)
+	}
+
+	var start int64
+	for _, chunk := range current.Chunks {
+		chunkEnd := start + chunk.Size
+		if chunkEnd <= offset || start >= end {
+			chunk.Index = len(chunks)
+			chunks = append(chunks, chunk)
+		} else {
+			stale = append(stale, chunk)
+			addFresh(fileData[start:chunkEnd])
+		}
+		start = chunkEnd
+	}
+
+	policy := filePolicy(current)
+	chunkCount := int64(s.chunkCount(settings, size, policy))
+	maxChunkSize := max((size+chunkCount-1)/chunkCount, 1)
+	for start < size {
+		part := fileData[start:min(start+maxChunkSize, size)]
+		addFresh(part)
+		start += int64(len(part))
+	}
+
+	// На серверы хранения передаются только новые куски
+	if err := s.placeChunks(settings, fresh, policy.replicationFactor(settings)); err != nil {
+		return nil, err
+	}
+	if err := s.distributeChunks(ctx, fresh); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить куски: %w", err)
+	}
+	for i, position := range positions {
+		fresh[i].Data = nil
+		chunks[position] = fresh[i]
+	}
+
+	modifiedAt := time.Now().UTC()
+	metadata := *current
+	metadata.Size = size
+	metadata.Checksum = calculateChecksum(settings.hashAlgorithm, fileData)
+	metadata.ChecksumAlgorithm = settings.hashAlgorithm
+	metadata.ChunkCount = len(chunks)
+	metadata.Chunks = chunks
+	metadata.DetectedContentType = detectedType
+	metadata.ModifiedAt = &modifiedAt
+
+	if err := s.commitContent(ctx, &metadata, fresh, stale); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
@@ -0,0 +1,277 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// errInsufficientCapacity возвращается, если ни на одном сервере хранения нет места для куска
+var errInsufficientCapacity = errors.New("недостаточно свободного места на серверах хранения")
+
+// capacityTracker хранит свободное место серверов хранения, полученное при последнем опросе,
+// за вычетом кусков, размещенных с тех пор
+type capacityTracker struct {
+	mutex sync.Mutex
+	free  map[string]int64 // адрес сервера - свободные байты; нет записи - место неизвестно
+
+	stop chan struct{}
+	done chan struct{} // nil, если опрос не запущен
+}
+
+// newCapacityTracker создает пустую таблицу свободного места
+func newCapacityTracker() *capacityTracker {
+	return &capacityTracker{
+		free: make(map[string]int64),
+		stop: make(chan struct{}),
+	}
+}
+
+// start запускает опрос серверов хранения с заданным периодом
+func (t *capacityTracker) start(interval time.Duration, settings func() *runtimeSettings) {
+	t.done = make(chan struct{})
+	go t.run(interval, settings)
+}
+
+// run опрашивает серверы хранения до вызова close
+func (t *capacityTracker) run(interval time.Duration, settings func() *runtimeSettings) {
+	defer close(t.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		t.refresh(ctx, settings())
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// close останавливает опрос
+func (t *capacityTracker) close() {
+	if t.done == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+}
+
+// refresh запрашивает свободное место у всех серверов хранения. Серверы, которые
+// не ответили или не сообщают свободное место, не получают новых кусков, пока
+// место известно хотя бы у одного сервера
+func (t *capacityTracker) refresh(ctx context.Context, settings *runtimeSettings) {
+	free := make(map[string]int64, len(settings.storageClients))
+	for i, client := range settings.storageClients {
+		status, err := client.StatusContext(ctx)
+		if err != nil {
+			log.Printf("Не удалось получить свободное место сервера %s: %v", settings.config.StorageServers[i], err)
+			continue
+		}
+		if status.FreeBytes != nil {
+			free[settings.config.StorageServers[i]] = *status.FreeBytes
+		}
+	}
+
+	t.mutex.Lock()
+	t.free = free
+	t.mutex.Unlock()
+}
+
+// place выбирает сервер для каждого куска. Сервер выбирается случайно с вероятностью,
+// пропорциональной свободному месту, поэтому заполненные серверы получают меньше кусков,
+// а параллельные загрузки не попадают все на один сервер. Куски одного файла по возможности
+// размещаются на разных серверах. Если свободное место не известно ни у одного сервера,
+// куски распределяются по кругу
+func (t *capacityTracker) place(servers []string, chunks []chunking.FileChunk) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	free, known, anyKnown := t.freeLocked(servers)
+	if !anyKnown {
+		// Случайное начало круга, чтобы первые куски всех файлов не попадали на один сервер
+		offset := rand.Intn(len(servers))
+		for i := range chunks {
+			chunks[i].Node = servers[(offset+i)%len(servers)]
+		}
+		return nil
+	}
+
+	used := make([]bool, len(servers))
+	usedCount := 0
+	for i := range chunks {
+		if usedCount == len(servers) {
+			used = make([]bool, len(servers))
+			usedCount = 0
+		}
+
+		fits := func(j int) bool { return known[j] && free[j] >= chunks[i].Size }
+		target := pickWeighted(free, func(j int) bool { return fits(j) && !used[j] })
+		if target < 0 {
+			// Все подходящие серверы уже заняты кусками этого файла
+			target = pickWeighted(free, fits)
+		}
+		if target < 0 {
+			return errInsufficientCapacity
+		}
+
+		chunks[i].Node = servers[target]
+		free[target] -= chunks[i].Size
+		if !used[target] {
+			used[target] = true
+			usedCount++
+		}
+	}
+
+	t.reserveLocked(servers, free, known)
+	return nil
+}
+
+// placeReplicas добавляет каждому куску до copies копий на серверах, где его еще нет.
+// Серверы выбираются, как в place, с вероятностью, пропорциональной свободному месту.
+// Если подходящих серверов не хватает, кусок получает меньше копий: недостающие
+// создаст восстановление копий, когда серверы появятся
+func (t *capacityTracker) placeReplicas(servers []string, chunks []chunking.FileChunk, copies int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	free, known, anyKnown := t.freeLocked(servers)
+	for i := range chunks {
+		holding := make(map[string]bool)
+		for _, node := range chunks[i].Nodes() {
+			holding[node] = true
+		}
+
+		for n := 0; n < copies; n++ {
+			target := pickWeighted(free, func(j int) bool {
+				if holding[servers[j]] {
+					return false
+				}
+				// Пока свободное место неизвестно ни у одного сервера, выбираем среди всех
+				return !anyKnown || known[j] && free[j] >= chunks[i].Size
+			})
+			if target < 0 {
+				break
+			}
+
+			chunks[i].Replicas = append(chunks[i].Replicas, servers[target])
+			holding[servers[target]] = true
+			// Неизвестное место не уменьшается, иначе вес сервера станет отрицательным
+			if known[target] {
+				free[target] -= chunks[i].Size
+			}
+		}
+	}
+
+	t.reserveLocked(servers, free, known)
+}
+
+// freeLocked возвращает свободное место серверов и признак, известно ли оно хотя бы у одного
+func (t *capacityTracker) freeLocked(servers []string) ([]int64, []bool, bool) {
+	free := make([]int64, len(servers))
+	known := make([]bool, len(servers))
+	anyKnown := false
+	for i, server := range servers {
+		free[i], known[i] = t.free[server]
+		anyKnown = anyKnown || known[i]
+	}
+	return free, known, anyKnown
+}
+
+// reserveLocked учитывает размещенные куски до следующего опроса серверов
+func (t *capacityTracker) reserveLocked(servers []string, free []int64, known []bool) {
+	for i, server := range servers {
+		if known[i] {
+			t.free[server] = free[i]
+		}
+	}
+}
+
+// pickWeighted выбирает индекс среди допустимых с вероятностью, пропорциональной весу.
+// Возвращает -1, если допустимых индексов нет
+func pickWeighted(weights []int64, allowed func(int) bool) int {
+	var total int64
+	for i, weight := range weights {
+		if allowed(i) {
+			total += weight + 1 // +1, чтобы сервер без запаса тоже мог принять пустой кусок
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+
+	point := rand.Int63n(total)
+	for i, weight := range weights {
+		if !allowed(i) {
+			continue
+		}
+		if point <= weight {
+			return i
+		}
+		point -= weight + 1
+	}
+	return -1
+}
+
+// placeChunks выбирает серверы хранения для каждого нового куска: основной и factor-1
+// серверов для копий. Выводимые из эксплуатации серверы новых кусков не получают
+func (s *StreamingAPIServer) placeChunks(settings *runtimeSettings, chunks []chunking.FileChunk, factor int) error {
+	servers := s.nodes.schedulable(settings.config.StorageServers)
+	if len(servers) == 0 {
+		return errNoStorageServers
+	}
+	if err := s.capacity.place(servers, chunks); err != nil {
+		return err
+	}
+	if copies := factor - 1; copies > 0 {
+		s.capacity.placeReplicas(servers, chunks, copies)
+	}
+	return nil
+}
+
+// chunkCount возвращает, на сколько кусков делится файл размером size. Файл меньше
+// small_file_threshold сохраняется одним куском, чтобы его загрузка и скачивание
+// обращались к одному серверу хранения. С target_chunk_size число кусков растет с
+// размером файла от min_chunk_count до max_chunk_count, а без max_chunk_count - до
+// числа серверов, принимающих новые куски, чтобы большой файл передавался параллельно
+// со всех серверов. Без target_chunk_size файл делится на chunk_count кусков. Число
+// кусков или размер куска из политики загрузки policy заменяют эти настройки
+func (s *StreamingAPIServer) chunkCount(settings *runtimeSettings, size int64, policy uploadPolicy) int {
+	cfg := settings.config
+	switch {
+	case policy.ChunkCount > 0:
+		return policyChunkCount(policy.ChunkCount, size)
+	case policy.ChunkSize > 0:
+		count := (size + policy.ChunkSize - 1) / policy.ChunkSize
+		return policyChunkCount(int(min(count, int64(s.maxChunkCount(settings)))), size)
+	}
+	if size < cfg.SmallFileThreshold {
+		return 1
+	}
+	if cfg.TargetChunkSize <= 0 {
+		return cfg.ChunkCount
+	}
+
+	count := int((size + cfg.TargetChunkSize - 1) / cfg.TargetChunkSize)
+	maxCount := cfg.MaxChunkCount
+	if maxCount == 0 {
+		maxCount = max(len(s.nodes.schedulable(cfg.StorageServers)), cfg.MinChunkCount)
+	}
+	return max(min(count, maxCount), cfg.MinChunkCount, 1)
+}
+
+// policyChunkCount ограничивает число кусков из политики загрузки размером файла, чтобы
+// не получить пустых кусков
+func policyChunkCount(count int, size int64) int {
+	return int(max(min(int64(count), size), 1))
+}
@@ -0,0 +1,117 @@
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+// Параметры политики загрузки: параметр запроса и заголовок с тем же значением.
+// Параметр запроса имеет приоритет
+var (
+	chunkCountParam   = policyParam{query: "chunk_count", header: "X-Chunk-Count"}
+	chunkSizeParam    = policyParam{query: "chunk_size", header: "X-Chunk-Size"}
+	replicationParam  = policyParam{query: "replication_factor", header: "X-Replication-Factor"}
+	storageClassParam = policyParam{query: "storage_class", header: "X-Storage-Class"}
+)
+
+// policyParam - параметр политики загрузки
+type policyParam struct {
+	query  string
+	header string
+}
+
+// value возвращает значение параметра из запроса или заголовка
+func (p policyParam) value(c *gin.Context) string {
+	if value := c.Query(p.query); value != "" {
+		return value
+	}
+	return c.GetHeader(p.header)
+}
+
+// uploadPolicy - разбиение на куски и число копий, выбранные клиентом для одного файла.
+// Нулевые значения означают настройки сервера
+type uploadPolicy struct {
+	ChunkCount        int    // число кусков
+	ChunkSize         int64  // желаемый размер куска в байтах, если число кусков не задано
+	ReplicationFactor int    // число копий каждого куска
+	StorageClass      string // класс хранения из storage_classes
+}
+
+// filePolicy возвращает политику хранения, записанную в метаданных файла. Новые куски
+// файла при замене и изменении содержимого получают то же число копий
+func filePolicy(metadata *chunking.FileMetadata) uploadPolicy {
+	return uploadPolicy{ReplicationFactor: metadata.ReplicationFactor, StorageClass: metadata.StorageClass}
+}
+
+// replicationFactor возвращает число копий кусков файла с политикой p
+func (p uploadPolicy) replicationFactor(settings *runtimeSettings) int {
+	if p.ReplicationFactor > 0 {
+		return p.ReplicationFactor
+	}
+	return settings.config.ReplicationFactor
+}
+
+// fileReplicationFactor возвращает число копий кусков файла
+func fileReplicationFactor(settings *runtimeSettings, metadata *chunking.FileMetadata) int {
+	return filePolicy(metadata).replicationFactor(settings)
+}
+
+// parseUploadPolicy разбирает политику загрузки из параметров chunk_count, chunk_size,
+// replication_factor и storage_class запроса или заголовков X-Chunk-Count, X-Chunk-Size,
+// X-Replication-Factor и X-Storage-Class. Число кусков ограничено наибольшим из
+// chunk_count, max_chunk_count и числа серверов, принимающих куски, а число копий -
+// числом таких серверов. Класс хранения задает число копий, если оно не указано явно
+func (s *StreamingAPIServer) parseUploadPolicy(c *gin.Context, settings *runtimeSettings) (uploadPolicy, *requestError) {
+	var policy uploadPolicy
+	servers := len(s.nodes.schedulable(settings.config.StorageServers))
+
+	if value := chunkCountParam.value(c); value != "" {
+		maxCount := s.maxChunkCount(settings)
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 1 || count > maxCount {
+			return policy, newRequestError(http.StatusBadRequest, apierror.InvalidChunkCount, value, maxCount)
+		}
+		policy.ChunkCount = count
+	}
+
+	if value := chunkSizeParam.value(c); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size < 1 {
+			return policy, newRequestError(http.StatusBadRequest, apierror.InvalidChunkSize, value)
+		}
+		policy.ChunkSize = size
+	}
+
+	if class := storageClassParam.value(c); class != "" {
+		factor, ok := settings.storageClasses[class]
+		if !ok {
+			return policy, newRequestError(http.StatusBadRequest, apierror.UnknownStorageClass, class)
+		}
+		if factor > servers {
+			return policy, newRequestError(http.StatusBadRequest, apierror.InvalidReplication, strconv.Itoa(factor), servers)
+		}
+		policy.StorageClass = class
+		policy.ReplicationFactor = factor
+	}
+
+	if value := replicationParam.value(c); value != "" {
+		factor, err := strconv.Atoi(value)
+		if err != nil || factor < 1 || factor > servers {
+			return policy, newRequestError(http.StatusBadRequest, apierror.InvalidReplication, value, servers)
+		}
+		policy.ReplicationFactor = factor
+	}
+	return policy, nil
+}
+
+// maxChunkCount возвращает наибольшее число кусков, которое клиент может запросить при
+// загрузке: наибольшее из chunk_count, max_chunk_count и числа серверов, принимающих куски
+func (s *StreamingAPIServer) maxChunkCount(settings *runtimeSettings) int {
+	cfg := settings.config
+	return max(cfg.ChunkCount, cfg.MaxChunkCount, len(s.nodes.schedulable(cfg.StorageServers)))
+}
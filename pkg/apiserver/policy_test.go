@@ -0,0 +1,105 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+func TestUploadPolicyOverrides(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t, withStorageNodes(3), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 3
+		cfg.SmallFileThreshold = 0
+		cfg.StorageClasses = []string{"archive=3"}
+	}))
+	api := server.api
+	content := strings.Repeat("policy ", 100)
+	download := func(id string) string {
+		body, err := api.OpenDownload(ctx, id)
+		require.NoError(t, err)
+		defer body.Close()
+		downloaded, err := io.ReadAll(body)
+		require.NoError(t, err)
+		return string(downloaded)
+	}
+
+	// Число кусков и копий из политики загрузки заменяют настройки сервера
+	metadata, err := api.UploadReader(ctx, "backup.bin", strings.NewReader(content), int64(len(content)),
+		client.WithChunkCount(2), client.WithReplicationFactor(2))
+	require.NoError(t, err)
+	assert.Equal(t, 2, metadata.ChunkCount)
+	assert.Equal(t, 2, metadata.ReplicationFactor)
+	for _, chunk := range metadata.Chunks {
+		assert.Len(t, chunk.Nodes(), 2)
+	}
+	assert.Equal(t, content, download(metadata.ID))
+
+	// Замена содержимого сохраняет число копий файла
+	replacement := filepath.Join(t.TempDir(), "backup.bin")
+	require.NoError(t, os.WriteFile(replacement, []byte(content+"v2"), 0o644))
+	replaced, err := api.ReplaceFile(metadata.ID, replacement, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, replaced.ReplicationFactor)
+	for _, chunk := range replaced.Chunks {
+		assert.Len(t, chunk.Nodes(), 2)
+	}
+
+	// Класс хранения задает число копий
+	metadata, err = api.UploadReader(ctx, "archive.bin", strings.NewReader(content), int64(len(content)),
+		client.WithStorageClass("archive"), client.WithChunkSize(int64(len(content))))
+	require.NoError(t, err)
+	assert.Equal(t, "archive", metadata.StorageClass)
+	assert.Equal(t, 3, metadata.ReplicationFactor)
+	require.Len(t, metadata.Chunks, 1)
+	assert.Len(t, metadata.Chunks[0].Nodes(), 3)
+
+	// Параметры можно передать заголовками
+	req, err := http.NewRequest(http.MethodPut, server.url+"/api/v1/files", strings.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Set(filenameHeader, "hot.txt")
+	req.Header.Set("X-Chunk-Count", "1")
+	req.Header.Set("X-Replication-Factor", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var hot chunking.FileMetadata
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&hot))
+	resp.Body.Close()
+	require.Len(t, hot.Chunks, 1)
+	assert.Len(t, hot.Chunks[0].Nodes(), 1)
+
+	// Значения за пределами ограничений сервера отклоняются
+	for _, test := range []struct {
+		option client.TransferOption
+		code   apierror.Code
+	}{
+		{option: client.WithChunkCount(100), code: apierror.InvalidChunkCount},
+		{option: client.WithReplicationFactor(4), code: apierror.InvalidReplication},
+		{option: client.WithStorageClass("cold"), code: apierror.UnknownStorageClass},
+	} {
+		_, err := api.UploadReader(ctx, "rejected.bin", strings.NewReader(content), int64(len(content)), test.option)
+		var apiErr *client.APIError
+		require.True(t, errors.As(err, &apiErr), string(test.code))
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, string(test.code), apiErr.Code)
+	}
+	resp, err = http.Post(server.url+"/api/v1/files?chunk_size=0", "application/octet-stream", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,210 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	"TestCase/pkg/chunking"
+)
+
+// errFileChecksumMismatch - собранное содержимое файла не совпадает с его контрольной суммой
+var errFileChecksumMismatch = errors.New("контрольная сумма файла не совпадает")
+
+// chunkReadFunc получает один кусок с серверов хранения или из кэша
+type chunkReadFunc func(ctx context.Context, chunkMeta chunking.FileChunk) (*chunking.FileChunk, error)
+
+// chunkReader отдает куски файла по порядку. Пока вызывающий передает клиенту очередной
+// кусок, следующие window кусков уже запрашиваются, поэтому медленный ответ сервера
+// хранения не останавливает передачу. Заранее полученных, но еще не отданных кусков не
+// больше window
+type chunkReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	read   chunkReadFunc
+	chunks []chunking.FileChunk
+
+	position int
+	results  []chan chunkResult // nil без упреждающего чтения
+	slots    chan struct{}      // куски, запрошенные заранее
+}
+
+// chunkResult - итог получения куска
+type chunkResult struct {
+	chunk *chunking.FileChunk
+	err   error
+}
+
+// newChunkReader создает чтение кусков chunks с упреждением на window кусков; при window
+// 0 каждый кусок запрашивается, только когда он нужен. Чтение нужно завершить close
+func newChunkReader(ctx context.Context, chunks []chunking.FileChunk, window int, read chunkReadFunc) *chunkReader {
+	r := &chunkReader{read: read, chunks: chunks}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	if window < 1 || len(chunks) < 2 {
+		return r
+	}
+
+	r.results = make([]chan chunkResult, len(chunks))
+	for i := range r.results {
+		r.results[i] = make(chan chunkResult, 1)
+	}
+	r.slots = make(chan struct{}, window)
+	go r.prefetch()
+	return r
+}
+
+// readChunks создает чтение кусков с упреждением из download_prefetch_chunks.
+// Одновременные скачивания получают один кусок с одним уровнем согласованности одним
+// обращением к серверам хранения
+func (s *StreamingAPIServer) readChunks(ctx context.Context, chunks []chunking.FileChunk) *chunkReader {
+	settings := s.current()
+	return newChunkReader(ctx, chunks, settings.config.DownloadPrefetchChunks, func(ctx context.Context, chunkMeta chunking.FileChunk) (*chunking.FileChunk, error) {
+		key := chunkMeta.ID + "\x00" + string(contextReadConsistency(ctx))
+		return s.downloads.chunk(ctx, key, func(ctx context.Context) (*chunking.FileChunk, error) {
+			return s.readChunk(ctx, settings, chunkMeta)
+		})
+	})
+}
+
+// prefetch запрашивает куски по порядку, как только освобождается место в окне
+func (r *chunkReader) prefetch() {
+	for i, chunkMeta := range r.chunks {
+		select {
+		case r.slots <- struct{}{}:
+		case <-r.ctx.Done():
+			return
+		}
+		go func(result chan<- chunkResult, chunkMeta chunking.FileChunk) {
+			chunk, err := r.read(r.ctx, chunkMeta)
+			result <- chunkResult{chunk: chunk, err: err}
+		}(r.results[i], chunkMeta)
+	}
+}
+
+// next возвращает следующий кусок; после последнего - io.EOF
+func (r *chunkReader) next() (*chunking.FileChunk, error) {
+	if r.position >= len(r.chunks) {
+		return nil, io.EOF
+	}
+	i := r.position
+	r.position++
+	if r.results == nil {
+		return r.read(r.ctx, r.chunks[i])
+	}
+
+	select {
+	case result := <-r.results[i]:
+		<-r.slots
+		return result.chunk, result.err
+	case <-r.ctx.Done():
+		return nil, r.ctx.Err()
+	}
+}
+
+// close отменяет запросы кусков, которые уже не понадобятся
+func (r *chunkReader) close() {
+	r.cancel()
+}
+
+// fileReader отдает содержимое файла с позиции offset, получая куски через readChunks,
+// поэтому файл не собирается в памяти целиком. При чтении с начала файла содержимое
+// проверяется по контрольной сумме файла до отдачи последнего куска: поврежденный файл
+// не передается целиком. Чтение нужно завершить Close
+type fileReader struct {
+	chunks    *chunkReader
+	metadata  *chunking.FileMetadata
+	remaining int       // куски, которые еще не получены
+	skip      int64     // байты первого куска до offset
+	hasher    hash.Hash // nil при чтении не с начала файла
+	data      []byte    // еще не отданная часть текущего куска
+}
+
+// newFileReader создает чтение файла metadata с позиции offset
+func (s *StreamingAPIServer) newFileReader(ctx context.Context, metadata *chunking.FileMetadata, offset int64) (*fileReader, error) {
+	covering, skip := chunksForRange(metadata.Chunks, byteRange{start: offset, end: metadata.Size - 1})
+	r := &fileReader{metadata: metadata, remaining: len(covering), skip: skip}
+	if offset == 0 {
+		hasher, err := chunking.NewHasher(metadata.ChecksumAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		r.hasher = hasher
+	}
+	r.chunks = s.readChunks(ctx, covering)
+	return r, nil
+}
+
+// fill получает следующий кусок, если текущий уже отдан; в конце файла возвращает io.EOF
+func (r *fileReader) fill() error {
+	for len(r.data) == 0 {
+		if r.remaining == 0 {
+			return io.EOF
+		}
+		chunk, err := r.chunks.next()
+		if err != nil {
+			return err
+		}
+		r.remaining--
+
+		data := chunk.Data
+		if r.skip > 0 {
+			if r.skip > int64(len(data)) {
+				return fmt.Errorf("кусок %d короче, чем указано в метаданных файла", chunk.Index)
+			}
+			data = data[r.skip:]
+			r.skip = 0
+		}
+		if r.hasher != nil {
+			r.hasher.Write(chunk.Data)
+			if r.remaining == 0 {
+				if checksum := fmt.Sprintf("%x", r.hasher.Sum(nil)); checksum != r.metadata.Checksum {
+					return fmt.Errorf("%w: ожидалась %s, получена %s", errFileChecksumMismatch, r.metadata.Checksum, checksum)
+				}
+			}
+		}
+		r.data = data
+	}
+	return nil
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if err := r.fill(); err != nil {
+		return 0, err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// WriteTo пишет оставшееся содержимое в w по кускам; если w - http.Flusher, каждый
+// кусок сразу отправляется клиенту, не дожидаясь следующих
+func (r *fileReader) WriteTo(w io.Writer) (int64, error) {
+	flusher, _ := w.(http.Flusher)
+	var written int64
+	for {
+		if err := r.fill(); err != nil {
+			if err == io.EOF {
+				return written, nil
+			}
+			return written, err
+		}
+		n, err := w.Write(r.data)
+		written += int64(n)
+		r.data = r.data[n:]
+		if err != nil {
+			return written, err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Close отменяет запросы кусков, которые уже не понадобятся
+func (r *fileReader) Close() error {
+	r.chunks.close()
+	return nil
+}
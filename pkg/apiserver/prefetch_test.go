@@ -0,0 +1,246 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+)
+
+// prefetchSource - куски с подсчетом запросов; кусок с индексом failIndex не получается
+type prefetchSource struct {
+	mutex     sync.Mutex
+	requested []int
+	failIndex int
+}
+
+func (p *prefetchSource) read(ctx context.Context, chunkMeta chunking.FileChunk) (*chunking.FileChunk, error) {
+	p.mutex.Lock()
+	p.requested = append(p.requested, chunkMeta.Index)
+	p.mutex.Unlock()
+	if chunkMeta.Index == p.failIndex {
+		return nil, errors.New("сервер хранения недоступен")
+	}
+	return &chunking.FileChunk{Index: chunkMeta.Index, Data: []byte(fmt.Sprintf("chunk-%d", chunkMeta.Index))}, nil
+}
+
+func (p *prefetchSource) count() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.requested)
+}
+
+func prefetchChunkMetas(count int) []chunking.FileChunk {
+	chunks := make([]chunking.FileChunk, count)
+	for i := range chunks {
+		chunks[i] = chunking.FileChunk{Index: i, ID: fmt.Sprintf("chunk-%d", i)}
+	}
+	return chunks
+}
+
+func TestChunkReaderPrefetch(t *testing.T) {
+	source := &prefetchSource{failIndex: -1}
+	reader := newChunkReader(context.Background(), prefetchChunkMetas(6), 2, source.read)
+	defer reader.close()
+
+	chunk, err := reader.next()
+	require.NoError(t, err)
+	assert.Equal(t, "chunk-0", string(chunk.Data))
+
+	// Пока передается кусок 0, заранее запрошены куски 1 и 2, но не дальше
+	require.Eventually(t, func() bool { return source.count() == 3 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 3, source.count())
+
+	for i := 1; i < 6; i++ {
+		chunk, err := reader.next()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("chunk-%d", i), string(chunk.Data))
+	}
+	_, err = reader.next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 6, source.count())
+}
+
+func TestChunkReaderWithoutPrefetch(t *testing.T) {
+	source := &prefetchSource{failIndex: 2}
+	reader := newChunkReader(context.Background(), prefetchChunkMetas(4), 0, source.read)
+	defer reader.close()
+
+	for i := 0; i < 2; i++ {
+		chunk, err := reader.next()
+		require.NoError(t, err)
+		assert.Equal(t, i, chunk.Index)
+		assert.Equal(t, i+1, source.count())
+	}
+	_, err := reader.next()
+	assert.Error(t, err)
+}
+
+func TestChunkReaderError(t *testing.T) {
+	source := &prefetchSource{failIndex: 1}
+	reader := newChunkReader(context.Background(), prefetchChunkMetas(8), 3, source.read)
+
+	_, err := reader.next()
+	require.NoError(t, err)
+	_, err = reader.next()
+	assert.Error(t, err)
+
+	// После close куски дальше окна не запрашиваются
+	reader.close()
+	time.Sleep(20 * time.Millisecond)
+	assert.LessOrEqual(t, source.count(), 5)
+}
+
+// gatedNode - сервер хранения, который не отдает кусок blocked, пока не закрыт gate
+type gatedNode struct {
+	handler http.Handler
+
+	mutex   sync.Mutex
+	blocked string
+	gate    chan struct{}
+}
+
+func (n *gatedNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	blocked, gate := n.blocked, n.gate
+	n.mutex.Unlock()
+	if blocked != "" && r.Method == http.MethodGet && r.URL.Path == "/api/v1/chunks/"+blocked {
+		<-gate
+	}
+	n.handler.ServeHTTP(w, r)
+}
+
+// block задерживает выдачу куска chunkID до вызова возвращенной функции
+func (n *gatedNode) block(chunkID string) func() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.blocked, n.gate = chunkID, make(chan struct{})
+	gate := n.gate
+	return func() { close(gate) }
+}
+
+func TestDownloadStreamsBeforeLastChunk(t *testing.T) {
+	ctx := context.Background()
+
+	var node *gatedNode
+	server := newTestServer(t, withStorageNodes(1), withNodeHandler(func(_ string, handler http.Handler) http.Handler {
+		node = &gatedNode{handler: handler}
+		return node
+	}), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 4
+		cfg.SmallFileThreshold = 0
+		cfg.ChunkCacheSize = 0
+		cfg.DownloadPrefetchChunks = 1
+	}))
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	req, err := http.NewRequest(http.MethodPut, server.url+"/webdav/stream.bin", bytes.NewReader(content))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	metadata, err := server.findFileByPath(ctx, "stream.bin")
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 4)
+	firstSize := metadata.Chunks[0].Size
+
+	// Начало файла приходит клиенту, пока последний кусок еще не получен с сервера
+	// хранения, а после его получения файл передается целиком
+	for _, path := range []string{"/api/v1/files/" + metadata.ID, "/webdav/stream.bin"} {
+		release := node.block(metadata.Chunks[3].ID)
+		head := make([]byte, firstSize)
+		responses := make(chan *http.Response, 1)
+		go func() {
+			resp, err := http.Get(server.url + path)
+			if assert.NoError(t, err, path) {
+				_, err = io.ReadFull(resp.Body, head)
+				assert.NoError(t, err, path)
+			}
+			responses <- resp
+		}()
+
+		var resp *http.Response
+		select {
+		case resp = <-responses:
+		case <-time.After(5 * time.Second):
+			release()
+			resp = <-responses
+			t.Errorf("%s: начало файла не получено до последнего куска", path)
+		}
+		require.NotNil(t, resp, path)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, content[:firstSize], head, path)
+
+		release()
+		rest, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err, path)
+		assert.Equal(t, content, append(head, rest...), path)
+	}
+}
+
+// failingNode - сервер хранения, который отвечает ошибкой на запрос куска failed
+type failingNode struct {
+	handler http.Handler
+	failed  atomic.Value // string
+}
+
+func (n *failingNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if failed, _ := n.failed.Load().(string); failed != "" && r.URL.Path == "/api/v1/chunks/"+failed {
+		http.Error(w, "диск недоступен", http.StatusInternalServerError)
+		return
+	}
+	n.handler.ServeHTTP(w, r)
+}
+
+func TestDownloadAbortsOnLaterChunkFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var node *failingNode
+	server := newTestServer(t, withStorageNodes(1), withNodeHandler(func(_ string, handler http.Handler) http.Handler {
+		node = &failingNode{handler: handler}
+		return node
+	}), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 4
+		cfg.SmallFileThreshold = 0
+		cfg.ChunkCacheSize = 0
+	}))
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	req, err := http.NewRequest(http.MethodPut, server.url+"/s3/bucket/big.bin", bytes.NewReader(content))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	metadata, err := server.findFileByPath(ctx, "bucket/big.bin")
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 4)
+	node.failed.Store(metadata.Chunks[2].ID)
+
+	// Ответ уже начат, поэтому ошибка куска обрывает соединение, а не завершает ответ
+	// усеченным содержимым с кодом 200
+	for _, path := range []string{"/api/v1/files/" + metadata.ID, "/s3/bucket/big.bin"} {
+		resp, err := http.Get(server.url + path)
+		require.NoError(t, err, path)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		received, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Error(t, err, path)
+		assert.Less(t, len(received), len(content), path)
+	}
+}
@@ -0,0 +1,58 @@
+package apiserver
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+)
+
+const (
+	// defaultPreviewBytes - размер превью, если параметр bytes не указан
+	defaultPreviewBytes = 64 * 1024
+
+	// maxPreviewBytes - наибольший размер превью: за большим фрагментом нужно обращаться
+	// к скачиванию с заголовком Range
+	maxPreviewBytes = 1024 * 1024
+)
+
+// previewFile отдает первые байты файла, загружая с серверов хранения только покрывающие
+// их куски. Позволяет показать начало текста или определить формат, не скачивая файл
+func (s *StreamingAPIServer) previewFile(c *gin.Context) {
+	limit := int64(defaultPreviewBytes)
+	if value := c.Query("bytes"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxPreviewBytes {
+			writeError(c, http.StatusBadRequest, apierror.InvalidPreviewBytes, maxPreviewBytes)
+			return
+		}
+		limit = parsed
+	}
+
+	metadata, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+
+	// Превью открывается скриптом интерфейса, а не браузером: содержимое не показывается
+	// как страница, даже если файл - HTML
+	c.Header("Content-Disposition", contentDisposition(dispositionAttachment, metadata.OriginalName))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("X-File-Size", strconv.FormatInt(metadata.Size, 10))
+	contentType := fileContentType(metadata)
+
+	if metadata.Size == 0 {
+		c.Data(http.StatusOK, contentType, nil)
+		return
+	}
+
+	window := byteRange{start: 0, end: min(limit, metadata.Size) - 1}
+	data, ok := s.readRange(c, metadata, window)
+	if !ok {
+		return
+	}
+	c.DataFromReader(http.StatusOK, int64(len(data)), contentType, bytes.NewReader(data), nil)
+}
@@ -0,0 +1,87 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/config"
+)
+
+func TestWriteQuorum(t *testing.T) {
+	ctx := context.Background()
+
+	// Третий сервер хранения отвечает ошибкой на сохранение кусков, пока rejecting установлен
+	var rejecting atomic.Bool
+	nodes := newStorageNodes(t, 3, func(id string, handler http.Handler) http.Handler {
+		if id != "3" {
+			return handler
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rejecting.Load() && r.Method == http.MethodPost && r.URL.Path == "/api/v1/chunks" {
+				http.Error(w, "диск заполнен", http.StatusInsufficientStorage)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	})
+
+	newServer := func(quorum int) *testServer {
+		return newTestServer(t, withConfig(func(cfg *config.Config) {
+			cfg.StorageServers = nodes
+			cfg.ChunkCount = 1
+			cfg.ReplicationFactor = 3
+			cfg.WriteQuorum = quorum
+			cfg.RepairInterval = 0
+			cfg.ChunkCacheSize = 0
+		}))
+	}
+
+	rejecting.Store(true)
+
+	// Без кворума загрузка требует всех копий
+	api := newServer(0).api
+	_, err := api.UploadReader(ctx, "all.txt", strings.NewReader("all replicas"), 12)
+	require.Error(t, err)
+
+	// С кворумом 2 из 3 загрузка успешна, а отказавший сервер не попадает в метаданные
+	server := newServer(2)
+	api = server.api
+	metadata, err := api.UploadReader(ctx, "quorum.txt", strings.NewReader("two of three"), 12)
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 1)
+	for _, chunk := range metadata.Chunks {
+		assert.ElementsMatch(t, nodes[:2], chunk.Nodes())
+	}
+
+	body, err := api.OpenDownload(ctx, metadata.ID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "two of three", string(data))
+
+	// Недостающие копии создает восстановление, когда сервер снова принимает куски
+	rejecting.Store(false)
+	server.repair.request()
+	require.Eventually(t, func() bool {
+		stored, err := server.catalog.Get(ctx, metadata.ID)
+		if err != nil {
+			return false
+		}
+		for _, chunk := range stored.Chunks {
+			if len(chunk.Nodes()) < 3 {
+				server.repair.request()
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,127 @@
+package apiserver
+
+import (
+	"errors"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"TestCase/pkg/chunking"
+)
+
+// errRangeNotSatisfiable возвращается, если запрошенный диапазон лежит за пределами файла
+var errRangeNotSatisfiable = errors.New("запрошенный диапазон за пределами файла")
+
+// byteRange - диапазон байт файла включительно
+type byteRange struct {
+	start, end int64
+}
+
+// length возвращает число байт диапазона
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+// parseRange разбирает заголовок Range вида bytes=0-499, bytes=500- или bytes=-500.
+// Возвращает false, если заголовок нужно проигнорировать и отдать файл целиком:
+// он отсутствует, записан в неизвестных единицах или запрашивает несколько диапазонов
+func parseRange(header string, size int64) (byteRange, bool, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+
+	startText, endText, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return byteRange{}, false, nil
+	}
+
+	var result byteRange
+	if startText == "" {
+		// Последние N байт файла
+		suffix, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || suffix < 0 {
+			return byteRange{}, false, nil
+		}
+		if suffix == 0 || size == 0 {
+			return byteRange{}, false, errRangeNotSatisfiable
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return byteRange{start: size - suffix, end: size - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false, nil
+	}
+	if start >= size {
+		return byteRange{}, false, errRangeNotSatisfiable
+	}
+	result.start = start
+	result.end = size - 1
+
+	if endText != "" {
+		end, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+		if end < result.end {
+			result.end = end
+		}
+	}
+	return result, true, nil
+}
+
+// chunksForRange возвращает куски, покрывающие диапазон, и смещение начала диапазона
+// в первом из них. Куски идут в метаданных по порядку, их размеры известны без загрузки
+func chunksForRange(chunks []chunking.FileChunk, window byteRange) ([]chunking.FileChunk, int64) {
+	var offset int64
+	first, last := -1, -1
+	var skip int64
+	for i, chunk := range chunks {
+		chunkEnd := offset + chunk.Size - 1
+		if first < 0 && window.start <= chunkEnd {
+			first = i
+			skip = window.start - offset
+		}
+		if window.end <= chunkEnd {
+			last = i
+			break
+		}
+		offset += chunk.Size
+	}
+	if first < 0 {
+		return nil, 0
+	}
+	if last < 0 {
+		last = len(chunks) - 1
+	}
+	return chunks[first : last+1], skip
+}
+
+// fileContentType выбирает Content-Type для отдачи файла. Браузеры и плееры воспроизводят
+// медиа только с точным типом, поэтому общий тип, заявленный клиентом при загрузке,
+// уточняется по содержимому или расширению имени
+func fileContentType(metadata *chunking.FileMetadata) string {
+	for _, candidate := range []string{metadata.ContentType, metadata.DetectedContentType, mime.TypeByExtension(path.Ext(metadata.OriginalName))} {
+		if candidate != "" && !isGenericContentType(candidate) {
+			return candidate
+		}
+	}
+	if metadata.ContentType != "" {
+		return metadata.ContentType
+	}
+	return "application/octet-stream"
+}
+
+// isGenericContentType сообщает, что тип ничего не говорит о формате файла
+func isGenericContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return mediaType == "application/octet-stream"
+}
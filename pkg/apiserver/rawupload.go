@@ -0,0 +1,61 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+)
+
+// filenameHeader - заголовок с именем файла при загрузке телом запроса. Имя с не-ASCII
+// символами передается в процентной кодировке UTF-8
+const filenameHeader = "X-Filename"
+
+// streamingUploadRaw обрабатывает загрузку файла телом запроса PUT без формы multipart.
+// Размер может быть неизвестен заранее (Transfer-Encoding: chunked), поэтому так можно
+// загрузить данные из конвейера или командой curl --upload-file
+func (s *StreamingAPIServer) streamingUploadRaw(c *gin.Context) {
+	s.uploadFile(c, readRawUpload)
+}
+
+// readRawUpload читает файл из тела запроса, имя - из заголовка X-Filename, а путь и признак
+// публичного файла - из параметров path и public
+func readRawUpload(c *gin.Context, spool *uploadSpool, maxFileSize int64) (*uploadBody, bool) {
+	name, err := url.PathUnescape(c.GetHeader(filenameHeader))
+	if err != nil || name == "" {
+		writeError(c, http.StatusBadRequest, apierror.FilenameRequired)
+		return nil, false
+	}
+
+	// Длина тела без Content-Length становится известна только при чтении, поэтому чтение
+	// прерывается, как только прочитано больше допустимого
+	if c.Request.ContentLength > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return nil, false
+	}
+	fileData, err := spool.readAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize))
+	if errors.Is(err, errUploadSpool) {
+		uploadSpoolFailed(c, err)
+		return nil, false
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			fileTooLarge(c, maxFileSize)
+			return nil, false
+		}
+		writeError(c, http.StatusBadRequest, apierror.FileReadFailed)
+		return nil, false
+	}
+
+	return &uploadBody{
+		data:        fileData,
+		name:        name,
+		contentType: c.GetHeader("Content-Type"),
+		path:        c.Query("path"),
+		public:      c.Query("public"),
+	}, true
+}
@@ -0,0 +1,390 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+)
+
+const (
+	// maxRebalanceErrors - сколько последних ошибок показывается в ходе выравнивания
+	maxRebalanceErrors = 20
+
+	// rebalanceTolerance - допустимое отклонение объема сервера от среднего, при котором
+	// куски не переносятся: перенос ради нескольких процентов не окупает нагрузку
+	rebalanceTolerance = 0.05
+)
+
+// rebalanceRequest - параметры выравнивания; незаданные берутся из конфигурации
+type rebalanceRequest struct {
+	MaxBytesPerSecond  *int64 `json:"max_bytes_per_second"` // 0 - без ограничения
+	MaxConcurrentMoves int    `json:"max_concurrent_moves"`
+}
+
+// rebalanceProgress - ход выравнивания данных между серверами хранения
+type rebalanceProgress struct {
+	MaxBytesPerSecond  int64 `json:"max_bytes_per_second"`
+	MaxConcurrentMoves int   `json:"max_concurrent_moves"`
+
+	NodeBytes    map[string]int64 `json:"node_bytes"`    // объем кусков на серверах до выравнивания
+	TargetBytes  map[string]int64 `json:"target_bytes"`  // объем после выполнения всех переносов плана
+	PlannedMoves int              `json:"planned_moves"` // куски, которые нужно перенести
+	PlannedBytes int64            `json:"planned_bytes"`
+	MovedChunks  int              `json:"moved_chunks"`
+	MovedBytes   int64            `json:"moved_bytes"`
+	FailedChunks int              `json:"failed_chunks"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Cancelled  bool       `json:"cancelled,omitempty"`
+	Errors     []string   `json:"errors,omitempty"` // последние ошибки переноса
+}
+
+// rebalanceMove - перенос одного куска в плане выравнивания
+type rebalanceMove struct {
+	chunkID    string
+	size       int64
+	from, to   string
+	references []chunkReference
+}
+
+// rebalanceController хранит состояние выравнивания. Одновременно выполняется
+// не больше одного выравнивания
+type rebalanceController struct {
+	mutex   sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	last    *rebalanceProgress // текущее или последнее выравнивание; nil, если не запускалось
+}
+
+// snapshot возвращает признак выполнения и копию хода последнего выравнивания
+func (r *rebalanceController) snapshot() (bool, *rebalanceProgress) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.last == nil {
+		return r.running, nil
+	}
+	progress := *r.last
+	progress.Errors = slices.Clone(r.last.Errors)
+	return r.running, &progress
+}
+
+// update изменяет ход текущего выравнивания под блокировкой
+func (r *rebalanceController) update(change func(progress *rebalanceProgress)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	change(r.last)
+}
+
+// recordError запоминает ошибку выравнивания; chunkFailed - не удалось перенести кусок
+func (r *rebalanceController) recordError(err error, chunkFailed bool) {
+	log.Printf("Выравнивание данных: %v", err)
+	r.update(func(progress *rebalanceProgress) {
+		if chunkFailed {
+			progress.FailedChunks++
+		}
+		progress.Errors = append(progress.Errors, err.Error())
+		if len(progress.Errors) > maxRebalanceErrors {
+			progress.Errors = progress.Errors[len(progress.Errors)-maxRebalanceErrors:]
+		}
+	})
+}
+
+// transferThrottle выдерживает среднюю скорость переноса: каждый перенос занимает
+// время, пропорциональное размеру куска, и следующий начинается не раньше, чем оно истечет
+type transferThrottle struct {
+	mutex          sync.Mutex
+	bytesPerSecond int64
+	next           time.Time
+}
+
+// wait ожидает своей очереди на перенос bytes байт
+func (t *transferThrottle) wait(ctx context.Context, bytes int64) error {
+	if t.bytesPerSecond <= 0 {
+		return ctx.Err()
+	}
+
+	t.mutex.Lock()
+	start := time.Now()
+	if t.next.After(start) {
+		start = t.next
+	}
+	t.next = start.Add(time.Duration(float64(bytes) / float64(t.bytesPerSecond) * float64(time.Second)))
+	t.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// startRebalance запускает выравнивание объема данных между серверами хранения
+func (s *StreamingAPIServer) startRebalance(c *gin.Context) {
+	var request rebalanceRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+
+	config := s.current().config
+	bytesPerSecond := config.RebalanceBytesPerSecond
+	if request.MaxBytesPerSecond != nil {
+		bytesPerSecond = *request.MaxBytesPerSecond
+	}
+	concurrentMoves := config.RebalanceConcurrency
+	if request.MaxConcurrentMoves != 0 {
+		concurrentMoves = request.MaxConcurrentMoves
+	}
+	if bytesPerSecond < 0 || concurrentMoves < 0 {
+		writeError(c, http.StatusBadRequest, apierror.NegativeRebalanceLimits)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.rebalance.mutex.Lock()
+	if s.rebalance.running {
+		s.rebalance.mutex.Unlock()
+		cancel()
+		writeError(c, http.StatusConflict, apierror.RebalanceInProgress)
+		return
+	}
+	s.rebalance.running = true
+	s.rebalance.cancel = cancel
+	s.rebalance.last = &rebalanceProgress{
+		MaxBytesPerSecond:  bytesPerSecond,
+		MaxConcurrentMoves: concurrentMoves,
+		StartedAt:          time.Now().UTC(),
+	}
+	s.rebalance.mutex.Unlock()
+
+	go s.rebalanceNodes(ctx, cancel, bytesPerSecond, concurrentMoves)
+
+	log.Printf("Начато выравнивание данных: до %d байт/с, до %d переносов одновременно", bytesPerSecond, concurrentMoves)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":              "Выравнивание запущено",
+		"max_bytes_per_second": bytesPerSecond,
+		"max_concurrent_moves": concurrentMoves,
+	})
+}
+
+// getRebalanceStatus возвращает ход текущего или последнего выравнивания
+func (s *StreamingAPIServer) getRebalanceStatus(c *gin.Context) {
+	running, progress := s.rebalance.snapshot()
+	c.JSON(http.StatusOK, gin.H{"running": running, "last": progress})
+}
+
+// cancelRebalance останавливает выравнивание. Уже перенесенные куски остаются на новых серверах
+func (s *StreamingAPIServer) cancelRebalance(c *gin.Context) {
+	s.rebalance.mutex.Lock()
+	running := s.rebalance.running
+	if running {
+		s.rebalance.cancel()
+	}
+	s.rebalance.mutex.Unlock()
+
+	if !running {
+		writeError(c, http.StatusConflict, apierror.RebalanceNotRunning)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Выравнивание остановлено"})
+}
+
+// rebalanceNodes составляет план переносов и выполняет его с ограничением скорости
+// и числа одновременных переносов
+func (s *StreamingAPIServer) rebalanceNodes(ctx context.Context, cancel context.CancelFunc, bytesPerSecond int64, concurrentMoves int) {
+	defer cancel()
+
+	// Остановка API сервера прерывает выравнивание
+	go func() {
+		select {
+		case <-s.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	defer func() {
+		finished := time.Now().UTC()
+		s.rebalance.mutex.Lock()
+		s.rebalance.last.FinishedAt = &finished
+		s.rebalance.last.Cancelled = ctx.Err() != nil
+		s.rebalance.running = false
+		s.rebalance.cancel = nil
+		s.rebalance.mutex.Unlock()
+	}()
+
+	settings := s.current()
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		s.rebalance.recordError(fmt.Errorf("не удалось прочитать каталог: %w", err), false)
+		return
+	}
+
+	nodes := s.nodes.schedulable(settings.config.StorageServers)
+	moves, before, after := planRebalance(files, nodes)
+	var plannedBytes int64
+	for _, move := range moves {
+		plannedBytes += move.size
+	}
+	s.rebalance.update(func(progress *rebalanceProgress) {
+		progress.NodeBytes = before
+		progress.TargetBytes = after
+		progress.PlannedMoves = len(moves)
+		progress.PlannedBytes = plannedBytes
+	})
+
+	throttle := &transferThrottle{bytesPerSecond: bytesPerSecond}
+	semaphore := make(chan struct{}, concurrentMoves)
+	var wg sync.WaitGroup
+	for _, move := range moves {
+		if err := throttle.wait(ctx, move.size); err != nil {
+			break
+		}
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(move rebalanceMove) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			size, err := s.moveChunk(ctx, settings, move.from, move.to, move.chunkID, move.references)
+			if err != nil {
+				s.rebalance.recordError(fmt.Errorf("кусок %s: %w", move.chunkID, err), true)
+				return
+			}
+			s.rebalance.update(func(progress *rebalanceProgress) {
+				progress.MovedChunks++
+				progress.MovedBytes += size
+			})
+		}(move)
+	}
+	wg.Wait()
+
+	_, progress := s.rebalance.snapshot()
+	if ctx.Err() != nil {
+		log.Printf("Выравнивание данных прервано: перенесено кусков %d из %d", progress.MovedChunks, progress.PlannedMoves)
+	} else {
+		log.Printf("Выравнивание данных завершено: перенесено кусков %d (%d байт), не удалось %d",
+			progress.MovedChunks, progress.MovedBytes, progress.FailedChunks)
+	}
+}
+
+// planRebalance составляет план переносов кусков между серверами nodes так, чтобы объем
+// данных на них был близок к среднему. Куски переносятся с самого заполненного сервера на
+// наименее заполненный, пока разница превышает допустимую. Серверы, уже хранящие копию
+// куска, его не получают. Возвращает переносы и объем серверов до и после них
+func planRebalance(files []*chunking.FileMetadata, nodes []string) ([]rebalanceMove, map[string]int64, map[string]int64) {
+	load := make(map[string]int64, len(nodes))
+	for _, node := range nodes {
+		load[node] = 0
+	}
+
+	// Куски группируются по идентификатору: на один кусок могут ссылаться несколько файлов
+	type chunkInfo struct {
+		size       int64
+		holders    []string
+		references []chunkReference
+	}
+	chunks := make(map[string]*chunkInfo)
+	for _, metadata := range files {
+		for i, chunk := range metadata.Chunks {
+			info, ok := chunks[chunk.ID]
+			if !ok {
+				info = &chunkInfo{size: chunk.Size, holders: chunk.Nodes()}
+				chunks[chunk.ID] = info
+				for _, node := range info.holders {
+					if _, ok := load[node]; ok {
+						load[node] += chunk.Size
+					}
+				}
+			}
+			info.references = append(info.references, chunkReference{fileID: metadata.ID, index: i})
+		}
+	}
+
+	before := make(map[string]int64, len(load))
+	var total int64
+	for node, bytes := range load {
+		before[node] = bytes
+		total += bytes
+	}
+	if len(nodes) < 2 || total == 0 {
+		return nil, before, load
+	}
+	tolerance := int64(float64(total) / float64(len(nodes)) * rebalanceTolerance)
+
+	// Куски каждого сервера в порядке убывания размера: крупные куски выравнивают быстрее
+	onNode := make(map[string][]string, len(nodes))
+	for chunkID, info := range chunks {
+		for _, node := range info.holders {
+			if _, ok := load[node]; ok {
+				onNode[node] = append(onNode[node], chunkID)
+			}
+		}
+	}
+	for _, ids := range onNode {
+		sort.Slice(ids, func(i, j int) bool {
+			if chunks[ids[i]].size != chunks[ids[j]].size {
+				return chunks[ids[i]].size > chunks[ids[j]].size
+			}
+			return ids[i] < ids[j]
+		})
+	}
+
+	var moves []rebalanceMove
+	moved := make(map[string]bool)
+	for {
+		ordered := slices.Clone(nodes)
+		sort.Slice(ordered, func(i, j int) bool { return load[ordered[i]] > load[ordered[j]] })
+		from, to := ordered[0], ordered[len(ordered)-1]
+		gap := load[from] - load[to]
+		if gap <= tolerance {
+			break
+		}
+
+		// Перенос куска меньше разницы сокращает ее
+		var chosen string
+		for _, chunkID := range onNode[from] {
+			info := chunks[chunkID]
+			if !moved[chunkID] && info.size > 0 && info.size < gap && !slices.Contains(info.holders, to) {
+				chosen = chunkID
+				break
+			}
+		}
+		if chosen == "" {
+			break
+		}
+
+		info := chunks[chosen]
+		moved[chosen] = true
+		info.holders[slices.Index(info.holders, from)] = to
+		load[from] -= info.size
+		load[to] += info.size
+		moves = append(moves, rebalanceMove{chunkID: chosen, size: info.size, from: from, to: to, references: info.references})
+	}
+
+	return moves, before, load
+}
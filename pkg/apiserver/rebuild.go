@@ -0,0 +1,177 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// catalogRebuildResult - итог восстановления каталога по манифестам серверов хранения
+type catalogRebuildResult struct {
+	NodesScanned int                `json:"nodes_scanned"`
+	NodeErrors   []catalogNodeError `json:"node_errors,omitempty"` // серверы, манифесты которых не удалось прочитать
+	Manifests    int                `json:"manifests"`             // найдено файлов с манифестами
+	catalogImportResult
+}
+
+// catalogNodeError - сервер хранения, с которого не удалось прочитать манифесты
+type catalogNodeError struct {
+	Node  string `json:"node"`
+	Error string `json:"error"`
+}
+
+// putMetadata сохраняет метаданные файла в каталог, а затем записывает их манифест на
+// серверы хранения файла. Манифест нужен только для восстановления каталога, поэтому
+// ошибки его записи не прерывают запрос. Корень Merkle дерева пересчитывается по текущим
+// кускам файла
+func (s *StreamingAPIServer) putMetadata(ctx context.Context, metadata *chunking.FileMetadata) error {
+	root, err := chunking.FileMerkleRoot(metadata)
+	if err != nil {
+		return fmt.Errorf("не удалось вычислить корень Merkle дерева: %w", err)
+	}
+	metadata.MerkleRoot = root
+
+	if err := s.catalog.Put(ctx, metadata); err != nil {
+		return err
+	}
+	s.writeManifests(ctx, metadata)
+	return nil
+}
+
+// writeManifests записывает манифест файла на серверы, хранящие его куски, а манифест
+// файла без кусков - на все серверы хранения
+func (s *StreamingAPIServer) writeManifests(ctx context.Context, metadata *chunking.FileMetadata) {
+	ctx = context.WithoutCancel(ctx)
+	settings := s.current()
+	manifest := &storage.FileManifest{Metadata: metadata, UpdatedAt: time.Now().UTC()}
+
+	nodes := manifestNodes(metadata)
+	if len(nodes) == 0 {
+		nodes = slices.Clone(settings.config.StorageServers)
+	}
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+
+			if err := settings.clientForNode(node).PutManifestContext(ctx, manifest); err != nil {
+				log.Printf("Не удалось записать манифест файла %s на сервер %s: %v", metadata.ID, node, err)
+			}
+		}(node)
+	}
+	wg.Wait()
+}
+
+// deleteManifests удаляет манифест файла со всех серверов хранения и с серверов его
+// кусков. Манифест мог остаться на сервере, с которого куски уже перенесены, и без
+// удаления файл вернулся бы в каталог при восстановлении
+func (s *StreamingAPIServer) deleteManifests(ctx context.Context, metadata *chunking.FileMetadata) {
+	ctx = context.WithoutCancel(ctx)
+	settings := s.current()
+
+	nodes := manifestNodes(metadata)
+	for _, node := range settings.config.StorageServers {
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+
+			if err := settings.clientForNode(node).DeleteManifestContext(ctx, metadata.ID); err != nil {
+				log.Printf("Не удалось удалить манифест файла %s с сервера %s: %v", metadata.ID, node, err)
+			}
+		}(node)
+	}
+	wg.Wait()
+}
+
+// manifestNodes возвращает серверы, хранящие куски файла, без повторов
+func manifestNodes(metadata *chunking.FileMetadata) []string {
+	var nodes []string
+	for _, chunk := range metadata.Chunks {
+		for _, node := range chunk.Nodes() {
+			if !slices.Contains(nodes, node) {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+// rebuildCatalog восстанавливает каталог по манифестам, которые хранят серверы хранения.
+// Из нескольких копий манифеста файла берется записанная последней. Файлы, уже бывшие в
+// каталоге, пропускаются, а с overwrite=true заменяются метаданными из манифеста
+func (s *StreamingAPIServer) rebuildCatalog(c *gin.Context) {
+	overwrite, ok := parseOverwrite(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	settings := s.current()
+
+	var result catalogRebuildResult
+	var mutex sync.Mutex
+	newest := make(map[string]*storage.FileManifest)
+
+	var wg sync.WaitGroup
+	for i, client := range settings.storageClients {
+		wg.Add(1)
+		go func(node string, client *storage.StorageClient) {
+			defer wg.Done()
+
+			manifests, err := client.ListManifestsContext(ctx)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			result.NodesScanned++
+			if err != nil {
+				log.Printf("Не удалось прочитать манифесты с сервера %s: %v", node, err)
+				result.NodeErrors = append(result.NodeErrors, catalogNodeError{Node: node, Error: err.Error()})
+				return
+			}
+			for _, manifest := range manifests {
+				if manifest.Metadata == nil || manifest.Metadata.ID == "" {
+					continue
+				}
+				if current, exists := newest[manifest.Metadata.ID]; !exists || manifest.UpdatedAt.After(current.UpdatedAt) {
+					newest[manifest.Metadata.ID] = manifest
+				}
+			}
+		}(settings.config.StorageServers[i], client)
+	}
+	wg.Wait()
+
+	sort.Slice(result.NodeErrors, func(i, j int) bool {
+		return result.NodeErrors[i].Node < result.NodeErrors[j].Node
+	})
+
+	files := make([]*chunking.FileMetadata, 0, len(newest))
+	for _, manifest := range newest {
+		files = append(files, manifest.Metadata)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	result.Manifests = len(files)
+	result.catalogImportResult = s.importFiles(ctx, c.GetString(principalKey), files, overwrite)
+
+	log.Printf("Каталог восстановлен по манифестам %d серверов: %d файлов сохранено, %d пропущено, %d с ошибкой",
+		result.NodesScanned-len(result.NodeErrors), result.Imported, result.Skipped, len(result.Failed))
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,58 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+func TestCatalogRebuildFromManifests(t *testing.T) {
+	ctx := context.Background()
+
+	// Каталог в памяти пропадает вместе с API сервером, а манифесты остаются на сервере хранения
+	nodes := newStorageNodes(t, 1, nil)
+	newServer := func() *client.APIClient {
+		return newTestServer(t, withConfig(func(cfg *config.Config) {
+			cfg.StorageServers = nodes
+			cfg.ChunkCount = 1
+		})).api
+	}
+
+	api := newServer()
+	kept, err := api.UploadReader(ctx, "kept.txt", strings.NewReader("kept"), 4)
+	require.NoError(t, err)
+	deleted, err := api.UploadReader(ctx, "deleted.txt", strings.NewReader("deleted"), 7)
+	require.NoError(t, err)
+	require.NoError(t, api.DeleteFileContext(ctx, deleted.ID))
+
+	restored := newServer()
+	result, err := restored.RebuildCatalogContext(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.NodesScanned)
+	assert.Empty(t, result.NodeErrors)
+	assert.Equal(t, 1, result.Manifests)
+	assert.Equal(t, 1, result.Imported)
+
+	body, err := restored.OpenDownload(ctx, kept.ID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "kept", string(data))
+
+	_, err = restored.GetFileInfoContext(ctx, deleted.ID)
+	assert.Error(t, err)
+
+	// Повторное восстановление не трогает файлы, уже бывшие в каталоге
+	result, err = restored.RebuildCatalogContext(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 1, result.Skipped)
+}
@@ -0,0 +1,79 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/config"
+	"TestCase/pkg/storage"
+)
+
+func TestPreferRegion(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.StorageRegions = []string{"a:1=east", "b:1=west", "c:1=west"}
+	settings, err := newRuntimeSettings(cfg, nil)
+	require.NoError(t, err)
+
+	nodes := []string{"a:1", "b:1", "d:1", "c:1"}
+	assert.Equal(t, []string{"b:1", "c:1", "a:1", "d:1"}, settings.preferRegion(nodes, "west"))
+	assert.Equal(t, []string{"a:1", "b:1", "d:1", "c:1"}, settings.preferRegion(nodes, "east"))
+	assert.Equal(t, nodes, settings.preferRegion(nodes, ""))
+	assert.Equal(t, nodes, settings.preferRegion(nodes, "north"))
+}
+
+func TestRegionAwareReads(t *testing.T) {
+	ctx := context.Background()
+
+	// Второй сервер хранения после down обрывает соединения, как недоступный
+	var down atomic.Bool
+	server := newTestServer(t, withStorageNodes(2), withNodeHandler(func(id string, handler http.Handler) http.Handler {
+		if id != "2" {
+			return handler
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				panic(http.ErrAbortHandler)
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.ReplicationFactor = 2
+		cfg.ChunkCacheSize = 0
+		cfg.Region = "west"
+		cfg.StorageRegions = []string{cfg.StorageServers[0] + "=east", cfg.StorageServers[1] + "=west"}
+	}))
+	nodes := server.nodes
+
+	api := server.api
+	metadata, err := api.UploadReader(ctx, "region.txt", strings.NewReader("regional"), 8)
+	require.NoError(t, err)
+
+	// Без региона клиента первыми идут серверы региона API сервера
+	manifest, err := api.GetManifestContext(ctx, metadata.ID)
+	require.NoError(t, err)
+	require.Len(t, manifest.Chunks, 1)
+	assert.Equal(t, storage.NodeURL(nodes[1]), manifest.Chunks[0].StorageURL)
+	assert.Equal(t, []string{storage.NodeURL(nodes[0])}, manifest.Chunks[0].Mirrors)
+
+	api.SetRegion("east")
+	manifest, err = api.GetManifestContext(ctx, metadata.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.NodeURL(nodes[0]), manifest.Chunks[0].StorageURL)
+
+	// Без копий своего региона API сервер читает из другого
+	down.Store(true)
+	body, err := api.OpenDownload(ctx, metadata.ID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "regional", string(data))
+}
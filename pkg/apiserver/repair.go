@@ -0,0 +1,347 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+)
+
+// maxRepairErrors - сколько последних ошибок показывается в ходе восстановления копий
+const maxRepairErrors = 20
+
+// repairProgress - ход прохода восстановления копий кусков
+type repairProgress struct {
+	ReplicationFactor int        `json:"replication_factor"`
+	CheckedChunks     int        `json:"checked_chunks"`
+	UnderReplicated   int        `json:"under_replicated"` // куски, у которых доступных копий меньше, чем нужно их файлам
+	RepairedChunks    int        `json:"repaired_chunks"`  // получили недостающие копии
+	FailedChunks      int        `json:"failed_chunks"`    // не удалось создать копии
+	LostChunks        int        `json:"lost_chunks"`      // не осталось ни одной доступной копии
+	CopiedBytes       int64      `json:"copied_bytes"`
+	DeadNodes         []string   `json:"dead_nodes,omitempty"` // серверы с копиями, не ответившие на проверку
+	StartedAt         time.Time  `json:"started_at"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	Errors            []string   `json:"errors,omitempty"` // последние ошибки восстановления
+}
+
+// repairController периодически ищет куски с недостающими копиями (например, после
+// потери сервера хранения) и создает копии из уцелевших. Проход можно запустить
+// и через API администрирования
+type repairController struct {
+	mutex   sync.Mutex
+	running bool
+	last    *repairProgress // текущий или последний проход; nil, если проходов не было
+
+	trigger chan struct{} // запрос внеочередного прохода
+	done    chan struct{}
+}
+
+// newRepairController создает контроллер восстановления копий
+func newRepairController() *repairController {
+	return &repairController{
+		trigger: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// request запрашивает внеочередной проход. Если проход уже идет, следующий начнется
+// сразу после него
+func (r *repairController) request() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot возвращает признак выполнения и копию хода последнего прохода
+func (r *repairController) snapshot() (bool, *repairProgress) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.last == nil {
+		return r.running, nil
+	}
+	progress := *r.last
+	progress.DeadNodes = slices.Clone(r.last.DeadNodes)
+	progress.Errors = slices.Clone(r.last.Errors)
+	return r.running, &progress
+}
+
+// update изменяет ход текущего прохода под блокировкой
+func (r *repairController) update(change func(progress *repairProgress)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	change(r.last)
+}
+
+// recordError запоминает ошибку прохода
+func (r *repairController) recordError(err error) {
+	log.Printf("Восстановление копий: %v", err)
+	r.update(func(progress *repairProgress) {
+		progress.Errors = append(progress.Errors, err.Error())
+		if len(progress.Errors) > maxRepairErrors {
+			progress.Errors = progress.Errors[len(progress.Errors)-maxRepairErrors:]
+		}
+	})
+}
+
+// runRepair выполняет проходы восстановления раз в interval и по запросу до остановки сервера
+func (s *StreamingAPIServer) runRepair(interval time.Duration) {
+	defer close(s.repair.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.shutdown
+		cancel()
+	}()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-s.repair.trigger:
+		}
+		s.repairPass(ctx)
+	}
+}
+
+// repairPass проверяет все куски каталога и создает недостающие копии
+func (s *StreamingAPIServer) repairPass(ctx context.Context) {
+	settings := s.current()
+
+	s.repair.mutex.Lock()
+	s.repair.running = true
+	s.repair.last = &repairProgress{ReplicationFactor: settings.config.ReplicationFactor, StartedAt: time.Now().UTC()}
+	s.repair.mutex.Unlock()
+
+	defer func() {
+		finished := time.Now().UTC()
+		s.repair.update(func(progress *repairProgress) { progress.FinishedAt = &finished })
+		s.repair.mutex.Lock()
+		s.repair.running = false
+		s.repair.mutex.Unlock()
+	}()
+
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		s.repair.recordError(fmt.Errorf("не удалось прочитать каталог: %w", err))
+		return
+	}
+
+	// Куски группируются по идентификатору: на один кусок могут ссылаться несколько файлов.
+	// Кусок получает наибольшее число копий среди ссылающихся на него файлов
+	chunks := make(map[string][]chunkReference)
+	nodes := make(map[string][]string)
+	sizes := make(map[string]int64)
+	factors := make(map[string]int)
+	for _, metadata := range files {
+		fileFactor := fileReplicationFactor(settings, metadata)
+		for i, chunk := range metadata.Chunks {
+			factors[chunk.ID] = max(factors[chunk.ID], fileFactor)
+			chunks[chunk.ID] = append(chunks[chunk.ID], chunkReference{fileID: metadata.ID, index: i})
+			for _, node := range chunk.Nodes() {
+				if !slices.Contains(nodes[chunk.ID], node) {
+					nodes[chunk.ID] = append(nodes[chunk.ID], node)
+				}
+			}
+			sizes[chunk.ID] = chunk.Size
+		}
+	}
+
+	liveness := s.newLivenessProbe(settings)
+	for chunkID, references := range chunks {
+		if ctx.Err() != nil {
+			break
+		}
+		factor := factors[chunkID]
+
+		var live []string
+		for _, node := range nodes[chunkID] {
+			if liveness.alive(ctx, node) {
+				live = append(live, node)
+			}
+		}
+		s.repair.update(func(progress *repairProgress) { progress.CheckedChunks++ })
+
+		switch {
+		case len(live) == 0:
+			s.repair.update(func(progress *repairProgress) { progress.LostChunks++ })
+			s.repair.recordError(fmt.Errorf("кусок %s: не осталось доступных копий", chunkID))
+			continue
+		case len(live) >= factor && len(live) == len(nodes[chunkID]):
+			continue
+		}
+
+		if len(live) < factor {
+			s.repair.update(func(progress *repairProgress) { progress.UnderReplicated++ })
+		}
+
+		copied, err := s.repairChunk(ctx, settings, liveness, chunkID, sizes[chunkID], live, factor, references)
+		if err != nil {
+			s.repair.update(func(progress *repairProgress) { progress.FailedChunks++ })
+			s.repair.recordError(fmt.Errorf("кусок %s: %w", chunkID, err))
+			continue
+		}
+		if copied > 0 {
+			s.repair.update(func(progress *repairProgress) {
+				progress.RepairedChunks++
+				progress.CopiedBytes += copied
+			})
+		}
+	}
+
+	dead := liveness.dead()
+	s.repair.update(func(progress *repairProgress) { progress.DeadNodes = dead })
+
+	_, progress := s.repair.snapshot()
+	if progress.UnderReplicated > 0 || progress.LostChunks > 0 {
+		log.Printf("Восстановление копий: проверено кусков %d, восстановлено %d, не удалось %d, потеряно %d",
+			progress.CheckedChunks, progress.RepairedChunks, progress.FailedChunks, progress.LostChunks)
+	}
+}
+
+// repairChunk создает недостающие копии куска, передавая его с уцелевшего сервера
+// напрямую на новые, и записывает в метаданные файлов только доступные копии.
+// Возвращает число скопированных байт
+func (s *StreamingAPIServer) repairChunk(ctx context.Context, settings *runtimeSettings, liveness *livenessProbe, chunkID string, size int64,
+	live []string, factor int, references []chunkReference) (int64, error) {
+	var copied int64
+	var lastErr error
+
+	if need := factor - len(live); need > 0 {
+		// Новые копии размещаются так же, как копии новых кусков, на доступных серверах без этого куска
+		var candidates []string
+		for _, node := range s.nodes.schedulable(settings.config.StorageServers) {
+			if liveness.alive(ctx, node) {
+				candidates = append(candidates, node)
+			}
+		}
+		placed := []chunking.FileChunk{{ID: chunkID, Size: size, Replicas: live}}
+		s.capacity.placeReplicas(candidates, placed, need)
+		targets := placed[0].Replicas[len(live):]
+		if len(targets) == 0 {
+			return 0, errors.New("нет доступных серверов для новых копий")
+		}
+
+		source := settings.clientForNode(live[0])
+		for _, targetNode := range targets {
+			result, err := source.MigrateChunkContext(ctx, chunkID, targetNode, true)
+			if err != nil {
+				lastErr = fmt.Errorf("не удалось скопировать с сервера %s на %s: %w", live[0], targetNode, err)
+				continue
+			}
+
+			// Копия должна получить по ссылке от каждого файла, как и остальные копии
+			target := settings.clientForNode(targetNode)
+			for i := len(references); i < result.RefCount; i++ {
+				if _, err := target.DecrementRefContext(ctx, chunkID); err != nil {
+					log.Printf("Не удалось снять лишнюю ссылку на кусок %s с сервера %s: %v", chunkID, targetNode, err)
+				}
+			}
+			for i := result.RefCount; i < len(references); i++ {
+				if _, err := target.IncrementRefContext(ctx, chunkID); err != nil {
+					log.Printf("Не удалось добавить ссылку на кусок %s на сервере %s: %v", chunkID, targetNode, err)
+				}
+			}
+
+			live = append(live, targetNode)
+			copied += result.Size
+			log.Printf("Создана копия куска %s на сервере %s", chunkID, targetNode)
+		}
+	}
+
+	// Недоступные серверы исключаются из метаданных, чтобы чтение не обращалось к ним
+	for _, reference := range references {
+		err := s.updateChunk(ctx, reference, chunkID, func(chunk *chunking.FileChunk) {
+			chunk.Node = live[0]
+			chunk.Replicas = slices.Clone(live[1:])
+		})
+		if err != nil && !errors.Is(err, catalog.ErrNotFound) {
+			return copied, fmt.Errorf("не удалось обновить метаданные файла %s: %w", reference.fileID, err)
+		}
+	}
+
+	return copied, lastErr
+}
+
+// livenessProbe проверяет доступность серверов хранения и запоминает результат
+// на время одного прохода восстановления
+type livenessProbe struct {
+	settings *runtimeSettings
+	nodes    *nodeRegistry
+	results  map[string]bool
+}
+
+// newLivenessProbe создает проверку доступности для одного прохода
+func (s *StreamingAPIServer) newLivenessProbe(settings *runtimeSettings) *livenessProbe {
+	return &livenessProbe{settings: settings, nodes: s.nodes, results: make(map[string]bool)}
+}
+
+// alive сообщает, отвечает ли сервер хранения. Выведенные серверы считаются недоступными
+func (p *livenessProbe) alive(ctx context.Context, node string) bool {
+	if result, ok := p.results[node]; ok {
+		return result
+	}
+
+	result := p.nodes.snapshot(node).State != nodeDrained
+	if result {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		result = p.settings.clientForNode(node).HealthCheckContext(checkCtx) == nil
+		cancel()
+	}
+	p.results[node] = result
+	return result
+}
+
+// dead возвращает проверенные серверы, оказавшиеся недоступными
+func (p *livenessProbe) dead() []string {
+	var dead []string
+	for node, alive := range p.results {
+		if !alive {
+			dead = append(dead, node)
+		}
+	}
+	slices.Sort(dead)
+	return dead
+}
+
+// getRepairStatus возвращает ход текущего или последнего прохода восстановления копий
+func (s *StreamingAPIServer) getRepairStatus(c *gin.Context) {
+	running, progress := s.repair.snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"running":            running,
+		"replication_factor": s.current().config.ReplicationFactor,
+		"last":               progress,
+	})
+}
+
+// startRepair запускает внеочередной проход восстановления копий
+func (s *StreamingAPIServer) startRepair(c *gin.Context) {
+	if running, _ := s.repair.snapshot(); running {
+		writeError(c, http.StatusConflict, apierror.RepairInProgress)
+		return
+	}
+
+	s.repair.request()
+	c.JSON(http.StatusAccepted, gin.H{"message": "Восстановление копий запущено"})
+}
@@ -0,0 +1,109 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+)
+
+// replaceFileContent заменяет содержимое существующего файла данными из поля file формы.
+// Идентификатор, путь, владелец, права доступа и видимость файла сохраняются.
+// Заголовок If-Match разрешает замену только указанной версии файла
+func (s *StreamingAPIServer) replaceFileContent(c *gin.Context) {
+	current, ok := s.loadFile(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	fileData, header, ok := readFormFile(c, spool, s.current().config.MaxFileSize)
+	if !ok {
+		return
+	}
+
+	if err := newWriteCondition(c.Request.Header).check(current); err != nil {
+		writeError(c, http.StatusPreconditionFailed, apierror.PreconditionFailed)
+		return
+	}
+
+	info := uploadInfo{
+		Name:        header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Path:        current.Path,
+		Public:      current.Public,
+		Owner:       current.Owner,
+		DropBox:     current.DropBox,
+		Policy:      filePolicy(current),
+	}
+	metadata, err := s.swapContent(c.Request.Context(), current, info, fileData)
+	if err != nil {
+		writeReplaceError(c, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
+
+// writeReplaceError отвечает на ошибку изменения содержимого файла
+func writeReplaceError(c *gin.Context, err error) {
+	if errors.Is(err, catalog.ErrConflict) || errors.Is(err, catalog.ErrNoLeader) {
+		writeCatalogError(c, err)
+		return
+	}
+	writeStoreError(c, apierror.ModifyFailed, err)
+}
+
+// swapContent сохраняет новые куски файла и заменяет ими куски текущей версии в каталоге.
+// Новые куски получают свои идентификаторы, поэтому до записи в каталог файл по-прежнему
+// читается в текущей версии
+func (s *StreamingAPIServer) swapContent(ctx context.Context, current *chunking.FileMetadata, info uploadInfo, fileData []byte) (*chunking.FileMetadata, error) {
+	metadata, err := s.storeContent(ctx, s.current(), info, current.ID, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Замена не меняет ничего, кроме содержимого файла
+	modifiedAt := metadata.CreatedAt
+	metadata.CreatedAt = current.CreatedAt
+	metadata.ModifiedAt = &modifiedAt
+	metadata.Generation = current.Generation
+	metadata.Grants = current.Grants
+	metadata.DownloadCount = current.DownloadCount
+	metadata.BytesServed = current.BytesServed
+	metadata.LastAccessedAt = current.LastAccessedAt
+
+	if err := s.commitContent(ctx, metadata, metadata.Chunks, current.Chunks); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// commitContent записывает в каталог метаданные файла с измененным содержимым. Куски fresh
+// сохранены для новой версии, куски stale больше не нужны и удаляются только после записи.
+// Если файл за это время изменен другим запросом, удаляются новые куски и возвращается
+// catalog.ErrConflict
+func (s *StreamingAPIServer) commitContent(ctx context.Context, metadata *chunking.FileMetadata, fresh, stale []chunking.FileChunk) error {
+	if err := s.putMetadata(ctx, metadata); err != nil {
+		s.deleteChunks(ctx, &chunking.FileMetadata{Chunks: fresh})
+		if errors.Is(err, catalog.ErrConflict) || errors.Is(err, catalog.ErrNoLeader) {
+			return err
+		}
+		return fmt.Errorf("не удалось сохранить метаданные: %w", err)
+	}
+
+	s.deleteChunks(ctx, &chunking.FileMetadata{Chunks: stale})
+	s.repairMissingCopies(fresh, fileReplicationFactor(s.current(), metadata))
+	s.events.Publish(events.NewFileEvent(events.FileReplaced, metadata))
+	recordAuditFile(ctx, metadata)
+	return nil
+}
@@ -0,0 +1,78 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/config"
+)
+
+// corruptingNode - сервер хранения, который при corrupt отдает куски с чужими данными
+type corruptingNode struct {
+	handler http.Handler
+	corrupt atomic.Bool
+	reads   atomic.Int64
+}
+
+func (n *corruptingNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/api/v1/chunks/") {
+		n.handler.ServeHTTP(w, r)
+		return
+	}
+	n.reads.Add(1)
+	if !n.corrupt.Load() {
+		n.handler.ServeHTTP(w, r)
+		return
+	}
+	recorder := httptest.NewRecorder()
+	n.handler.ServeHTTP(recorder, r)
+	body := strings.Replace(recorder.Body.String(), `"data":"`, `"data":"AAAA`, 1)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(recorder.Code)
+	io.WriteString(w, body)
+}
+
+func TestCorruptChunkReadFromReplica(t *testing.T) {
+	ctx := context.Background()
+
+	var wrapped []*corruptingNode
+	server := newTestServer(t, withStorageNodes(2), withNodeHandler(func(id string, handler http.Handler) http.Handler {
+		node := &corruptingNode{handler: handler}
+		wrapped = append(wrapped, node)
+		return node
+	}), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 1
+		cfg.ReplicationFactor = 2
+		cfg.ChunkCacheSize = 0
+	}))
+	nodes := make(map[string]*corruptingNode)
+	for i, addr := range server.nodes {
+		nodes[addr] = wrapped[i]
+	}
+	api := server.api
+
+	content := "replicated content"
+	metadata, err := api.UploadReader(ctx, "file.txt", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, metadata.Chunks, 1)
+	primary := nodes[metadata.Chunks[0].Node]
+	require.NotNil(t, primary)
+	primary.corrupt.Store(true)
+
+	// Поврежденный кусок читается с копии, а не запрашивается повторно с того же сервера
+	body, err := api.OpenDownload(ctx, metadata.ID)
+	require.NoError(t, err)
+	downloaded, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, string(downloaded))
+	assert.Equal(t, int64(1), primary.reads.Load())
+}
@@ -0,0 +1,589 @@
+package apiserver
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+)
+
+// Шлюз реализует подмножество S3 REST API в path-style адресации:
+// /s3/{bucket}/{key}. Бакеты не хранятся отдельно и существуют неявно,
+// а ключ объекта сохраняется в метаданных как путь файла "bucket/key".
+// Подписи AWS Signature V4 проверяет s3AuthMiddleware.
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// s3Error описывает ошибку в формате S3
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// s3Object описывает объект в ответе ListObjectsV2
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// s3CommonPrefix описывает "каталог" при группировке по разделителю
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// s3ListBucketResult представляет ответ ListObjectsV2
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	KeyCount              int              `xml:"KeyCount"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+// s3Bucket описывает бакет в ответе ListBuckets
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// s3ListAllMyBucketsResult представляет ответ ListBuckets
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+// setupS3Routes регистрирует маршруты S3 шлюза. Запросы подписываются AWS Signature V4
+// ключами из api_keys или передают ключ API заголовком, как в REST API
+func (s *StreamingAPIServer) setupS3Routes(router *gin.Engine) {
+	s3 := router.Group("/s3", s.s3AuthMiddleware(), principalMiddleware)
+	{
+		s3.GET("", s.s3ListBuckets)
+		s3.PUT("/:bucket", s.s3CreateBucket)
+		s3.HEAD("/:bucket", s.s3HeadBucket)
+		s3.GET("/:bucket", s.s3ListObjects)
+		s3.DELETE("/:bucket", s.s3DeleteBucket)
+		s3.PUT("/:bucket/*key", s.s3PutObject)
+		s3.GET("/:bucket/*key", s.s3GetObject)
+		s3.HEAD("/:bucket/*key", s.s3HeadObject)
+		s3.DELETE("/:bucket/*key", s.s3DeleteObject)
+	}
+}
+
+// s3Key возвращает ключ объекта без ведущего слэша
+func s3Key(c *gin.Context) string {
+	return strings.TrimPrefix(c.Param("key"), "/")
+}
+
+// s3ObjectPath возвращает путь файла, соответствующий объекту бакета
+func s3ObjectPath(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// s3ETag возвращает ETag объекта
+func s3ETag(metadata *chunking.FileMetadata) string {
+	return fmt.Sprintf("\"%s\"", metadata.Checksum)
+}
+
+// writeS3Error отправляет ошибку в формате S3
+func writeS3Error(c *gin.Context, status int, code, message string) {
+	c.Header("Content-Type", "application/xml")
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+	c.XML(status, s3Error{Code: code, Message: message, Resource: c.Request.URL.Path})
+}
+
+// writeS3CatalogError отправляет ошибку каталога метаданных в формате S3
+func writeS3CatalogError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		writeS3Error(c, http.StatusNotFound, "NoSuchKey", "Объект не найден")
+	case errors.Is(err, catalog.ErrNoLeader):
+		writeS3Error(c, http.StatusServiceUnavailable, "ServiceUnavailable", "Каталог файлов временно недоступен")
+	default:
+		writeS3Error(c, http.StatusInternalServerError, "InternalError", fmt.Sprintf("Не удалось обратиться к каталогу файлов: %v", err))
+	}
+}
+
+// s3Access проверяет доступ клиента к объекту так же, как loadFile: объект, который
+// клиент не может читать, для него не отличается от отсутствующего. При ошибке ответ
+// уже отправлен
+func (s *StreamingAPIServer) s3Access(c *gin.Context, metadata *chunking.FileMetadata, access fileAccess) bool {
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	if !settings.canAccess(principal, metadata, accessRead) {
+		writeS3Error(c, http.StatusNotFound, "NoSuchKey", "Объект не найден")
+		return false
+	}
+	if !settings.canAccess(principal, metadata, access) {
+		writeS3AccessDenied(c)
+		return false
+	}
+	return true
+}
+
+// writeS3AccessDenied отвечает, что клиенту не разрешена операция с объектом
+func writeS3AccessDenied(c *gin.Context) {
+	writeS3Error(c, http.StatusForbidden, "AccessDenied", "Нет доступа к объекту")
+}
+
+// s3ListBuckets возвращает список бакетов, выведенный из путей файлов, доступных клиенту
+func (s *StreamingAPIServer) s3ListBuckets(c *gin.Context) {
+	buckets := make(map[string]time.Time)
+
+	files, err := s.catalog.List(c.Request.Context())
+	if err != nil {
+		writeS3CatalogError(c, err)
+		return
+	}
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	for _, metadata := range files {
+		if metadata.Path == "" || !settings.canAccess(principal, metadata, accessRead) {
+			continue
+		}
+		name := strings.SplitN(metadata.Path, "/", 2)[0]
+		if created, seen := buckets[name]; !seen || metadata.CreatedAt.Before(created) {
+			buckets[name] = metadata.CreatedAt
+		}
+	}
+
+	result := s3ListAllMyBucketsResult{Xmlns: s3Namespace}
+	for name, created := range buckets {
+		result.Buckets = append(result.Buckets, s3Bucket{Name: name, CreationDate: created.Format(time.RFC3339)})
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].Name < result.Buckets[j].Name
+	})
+
+	c.XML(http.StatusOK, result)
+}
+
+// s3CreateBucket создает бакет; бакеты существуют неявно, поэтому операция всегда успешна
+func (s *StreamingAPIServer) s3CreateBucket(c *gin.Context) {
+	c.Header("Location", "/"+c.Param("bucket"))
+	c.Status(http.StatusOK)
+}
+
+// s3HeadBucket проверяет существование бакета
+func (s *StreamingAPIServer) s3HeadBucket(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+// s3DeleteBucket удаляет пустой бакет
+func (s *StreamingAPIServer) s3DeleteBucket(c *gin.Context) {
+	files, err := s.filesWithPathPrefix(c.Request.Context(), c.Param("bucket")+"/")
+	if err != nil {
+		writeS3CatalogError(c, err)
+		return
+	}
+	if len(files) > 0 {
+		writeS3Error(c, http.StatusConflict, "BucketNotEmpty", "Бакет не пуст")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// s3ListObjects реализует ListObjectsV2; объекты, которые клиент не может читать, не выводятся
+func (s *StreamingAPIServer) s3ListObjects(c *gin.Context) {
+	bucket := c.Param("bucket")
+	prefix := c.Query("prefix")
+	delimiter := c.Query("delimiter")
+	startAfter := c.Query("start-after")
+	continuationToken := c.Query("continuation-token")
+
+	maxKeys := 1000
+	if value := c.Query("max-keys"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeS3Error(c, http.StatusBadRequest, "InvalidArgument", "Неверное значение max-keys")
+			return
+		}
+		if parsed < maxKeys {
+			maxKeys = parsed
+		}
+	}
+
+	// Токен продолжения содержит последний выданный ключ
+	after := startAfter
+	if continuationToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(continuationToken)
+		if err != nil {
+			writeS3Error(c, http.StatusBadRequest, "InvalidArgument", "Неверный токен продолжения")
+			return
+		}
+		after = string(decoded)
+	}
+
+	result := s3ListBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		StartAfter:        startAfter,
+		MaxKeys:           maxKeys,
+		ContinuationToken: continuationToken,
+	}
+
+	bucketPrefix := bucket + "/"
+	files, err := s.filesWithPathPrefix(c.Request.Context(), bucketPrefix+prefix)
+	if err != nil {
+		writeS3CatalogError(c, err)
+		return
+	}
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	seenPrefixes := make(map[string]bool)
+	lastKey := ""
+
+	for _, metadata := range files {
+		key := strings.TrimPrefix(metadata.Path, bucketPrefix)
+		if key <= after || !settings.canAccess(principal, metadata, accessRead) {
+			continue
+		}
+
+		// Ключи с разделителем после префикса группируются в общий префикс
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := key[:len(prefix)+idx+len(delimiter)]
+				if seenPrefixes[commonPrefix] || commonPrefix <= after {
+					continue
+				}
+				if result.KeyCount >= maxKeys {
+					result.IsTruncated = true
+					break
+				}
+				seenPrefixes[commonPrefix] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: commonPrefix})
+				result.KeyCount++
+				lastKey = commonPrefix
+				continue
+			}
+		}
+
+		if result.KeyCount >= maxKeys {
+			result.IsTruncated = true
+			break
+		}
+
+		result.Contents = append(result.Contents, s3Object{
+			Key:          key,
+			LastModified: metadata.LastModified().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         s3ETag(metadata),
+			Size:         metadata.Size,
+			StorageClass: "STANDARD",
+		})
+		result.KeyCount++
+		lastKey = key
+	}
+
+	if result.IsTruncated && lastKey != "" {
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+	}
+
+	c.XML(http.StatusOK, result)
+}
+
+// s3PutObject сохраняет объект, заменяя существующий с тем же ключом
+func (s *StreamingAPIServer) s3PutObject(c *gin.Context) {
+	bucket := c.Param("bucket")
+	key := s3Key(c)
+	if key == "" {
+		s.s3CreateBucket(c)
+		return
+	}
+
+	maxFileSize := s.current().config.MaxFileSize
+	if c.Request.ContentLength > maxFileSize {
+		writeS3Error(c, http.StatusBadRequest, "EntityTooLarge", "Размер объекта превышает максимально допустимый")
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if isAWSChunked(c.Request) {
+		body = newAWSChunkedReader(c.Request.Body, s3ChunkSigner(c))
+	}
+
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	fileData, err := spool.readAll(io.LimitReader(body, maxFileSize+1))
+	if errors.Is(err, errUploadSpool) {
+		log.Printf("Ошибка временного файла загрузки: %v", err)
+		writeS3Error(c, http.StatusInternalServerError, "InternalError", "Не удалось сохранить объект во временный каталог")
+		return
+	}
+	if errors.Is(err, errS3PayloadMismatch) {
+		writeS3Error(c, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "Тело запроса не совпадает с X-Amz-Content-Sha256")
+		return
+	}
+	if errors.Is(err, errS3ChunkSignature) {
+		writeS3Error(c, http.StatusForbidden, "SignatureDoesNotMatch", "Подпись куска aws-chunked не совпадает с вычисленной по секретному ключу")
+		return
+	}
+	if err != nil {
+		writeS3Error(c, http.StatusBadRequest, "IncompleteBody", fmt.Sprintf("Не удалось прочитать объект: %v", err))
+		return
+	}
+	if int64(len(fileData)) > maxFileSize {
+		writeS3Error(c, http.StatusBadRequest, "EntityTooLarge", "Размер объекта превышает максимально допустимый")
+		return
+	}
+
+	objectPath := s3ObjectPath(bucket, key)
+	unlock := s.pathLocks.lock(objectPath)
+	defer unlock()
+
+	metadata, err := s.replaceFile(c.Request.Context(), uploadInfo{
+		Name:        path.Base(key),
+		ContentType: c.GetHeader("Content-Type"),
+		Path:        objectPath,
+	}, fileData, newWriteCondition(c.Request.Header))
+	if errors.Is(err, errPreconditionFailed) {
+		writeS3Error(c, http.StatusPreconditionFailed, "PreconditionFailed", "Объект изменился: условие If-Match или If-None-Match не выполнено")
+		return
+	}
+	if errors.Is(err, errAccessDenied) {
+		writeS3AccessDenied(c)
+		return
+	}
+	if errors.Is(err, catalog.ErrNoLeader) {
+		writeS3CatalogError(c, err)
+		return
+	}
+	if err != nil {
+		writeS3Error(c, storeErrorStatus(err), "InternalError", fmt.Sprintf("Не удалось сохранить объект: %v", err))
+		return
+	}
+
+	c.Header("ETag", s3ETag(metadata))
+	c.Status(http.StatusOK)
+}
+
+// s3GetObject отдает содержимое объекта
+func (s *StreamingAPIServer) s3GetObject(c *gin.Context) {
+	key := s3Key(c)
+	if key == "" {
+		s.s3ListObjects(c)
+		return
+	}
+
+	metadata, err := s.findFileByPath(c.Request.Context(), s3ObjectPath(c.Param("bucket"), key))
+	if err != nil {
+		writeS3CatalogError(c, err)
+		return
+	}
+	if !s.s3Access(c, metadata, accessRead) {
+		return
+	}
+
+	setS3ObjectHeaders(c, metadata)
+	c.Status(http.StatusOK)
+
+	if err := s.writeFileContent(c.Request.Context(), c.Writer, metadata); err != nil {
+		log.Printf("Не удалось отдать объект %s: %v", metadata.Path, err)
+		abortResponse(c)
+	}
+}
+
+// s3HeadObject возвращает заголовки объекта без содержимого
+func (s *StreamingAPIServer) s3HeadObject(c *gin.Context) {
+	key := s3Key(c)
+	if key == "" {
+		s.s3HeadBucket(c)
+		return
+	}
+
+	metadata, err := s.findFileByPath(c.Request.Context(), s3ObjectPath(c.Param("bucket"), key))
+	if err != nil {
+		writeS3CatalogError(c, err)
+		return
+	}
+	if !s.s3Access(c, metadata, accessRead) {
+		return
+	}
+
+	setS3ObjectHeaders(c, metadata)
+	c.Status(http.StatusOK)
+}
+
+// s3DeleteObject удаляет объект; удаление отсутствующего объекта не считается ошибкой.
+// Объект, который клиент не может читать, для него отсутствует и не удаляется
+func (s *StreamingAPIServer) s3DeleteObject(c *gin.Context) {
+	key := s3Key(c)
+	if key == "" {
+		s.s3DeleteBucket(c)
+		return
+	}
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	metadata, err := s.findFileByPath(c.Request.Context(), s3ObjectPath(c.Param("bucket"), key))
+	if err == nil && settings.canAccess(principal, metadata, accessRead) {
+		if !settings.canAccess(principal, metadata, accessWrite) {
+			writeS3AccessDenied(c)
+			return
+		}
+		err = s.removeFile(c.Request.Context(), metadata.ID)
+	}
+	if err != nil && !errors.Is(err, catalog.ErrNotFound) {
+		writeS3CatalogError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setS3ObjectHeaders устанавливает заголовки, описывающие объект
+func setS3ObjectHeaders(c *gin.Context, metadata *chunking.FileMetadata) {
+	contentType := metadata.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(metadata.Size, 10))
+	c.Header("ETag", s3ETag(metadata))
+	c.Header("Last-Modified", metadata.LastModified().Format(http.TimeFormat))
+	c.Header("x-amz-meta-file-id", metadata.ID)
+	setChecksumHeaders(c, metadata)
+}
+
+// isAWSChunked определяет, передано ли тело в формате aws-chunked (потоковая подпись SigV4)
+func isAWSChunked(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked")
+}
+
+// awsChunkedReader декодирует тело в формате aws-chunked:
+// "<hex-размер>[;chunk-signature=...]\r\n<данные>\r\n", завершающийся куском нулевого размера.
+// С signer каждый кусок читается в память и отдается только после проверки его подписи
+type awsChunkedReader struct {
+	reader    *bufio.Reader
+	remaining int64
+	done      bool
+	signer    *awsChunkSigner
+	verified  []byte // проверенные, но еще не прочитанные данные куска
+}
+
+func newAWSChunkedReader(r io.Reader, signer *awsChunkSigner) *awsChunkedReader {
+	return &awsChunkedReader{reader: bufio.NewReader(r), signer: signer}
+}
+
+func (a *awsChunkedReader) Read(p []byte) (int, error) {
+	if len(a.verified) > 0 {
+		n := copy(p, a.verified)
+		a.verified = a.verified[n:]
+		return n, nil
+	}
+	if a.done {
+		return 0, io.EOF
+	}
+
+	if a.remaining == 0 {
+		line, err := a.reader.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("неверный формат aws-chunked: %w", err)
+		}
+
+		sizeField, params, _ := strings.Cut(line, ";")
+		sizeField = strings.TrimSpace(sizeField)
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("неверный размер куска aws-chunked: %q", sizeField)
+		}
+
+		if a.signer != nil {
+			signature, _ := strings.CutPrefix(strings.TrimSpace(params), "chunk-signature=")
+			if err := a.readSigned(size, signature); err != nil {
+				return 0, err
+			}
+			return a.Read(p)
+		}
+
+		// Кусок нулевого размера завершает тело; заголовки трейлера игнорируются
+		if size == 0 {
+			a.done = true
+			return 0, io.EOF
+		}
+		a.remaining = size
+	}
+
+	if int64(len(p)) > a.remaining {
+		p = p[:a.remaining]
+	}
+
+	n, err := a.reader.Read(p)
+	a.remaining -= int64(n)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	// После данных куска следует CRLF
+	if a.remaining == 0 {
+		if _, err := a.reader.Discard(2); err != nil {
+			return n, fmt.Errorf("неверный формат aws-chunked: %w", err)
+		}
+	}
+
+	return n, nil
+}
+
+// readSigned читает кусок размера size, проверяет его подпись и оставляет данные для
+// чтения. Кусок нулевого размера завершает тело
+func (a *awsChunkedReader) readSigned(size int64, signature string) error {
+	if size > maxAWSChunkSize {
+		return fmt.Errorf("кусок aws-chunked больше %d байт", maxAWSChunkSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(a.reader, data); err != nil {
+		return fmt.Errorf("неверный формат aws-chunked: %w", err)
+	}
+	if err := a.signer.verify(data, signature); err != nil {
+		return err
+	}
+	if size == 0 {
+		a.done = true
+		return nil
+	}
+	if _, err := a.reader.Discard(2); err != nil {
+		return fmt.Errorf("неверный формат aws-chunked: %w", err)
+	}
+	a.verified = data
+	return nil
+}
@@ -0,0 +1,366 @@
+package apiserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Клиенты S3 подписывают запросы AWS Signature V4. Идентификатор ключа доступа - имя
+// клиента из api_keys, секретный ключ - его ключ API. Проверяются подпись в заголовке
+// Authorization и в параметрах подписанной ссылки, а также тело: по X-Amz-Content-Sha256
+// или по подписям кусков aws-chunked
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4DateFormat      = "20060102T150405Z"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	sigV4SignedStreaming = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	// sigV4MaxSkew - допустимое расхождение времени подписи с часами сервера, как в S3
+	sigV4MaxSkew = 15 * time.Minute
+	// sigV4MaxExpires - наибольший срок действия подписанной ссылки, как в S3
+	sigV4MaxExpires = 7 * 24 * time.Hour
+	// maxAWSChunkSize - наибольший кусок aws-chunked, который читается в память для
+	// проверки подписи
+	maxAWSChunkSize = 16 << 20
+)
+
+// s3ChunkSignerKey - ключ контекста gin с проверкой подписей кусков aws-chunked
+const s3ChunkSignerKey = "s3.chunk_signer"
+
+var (
+	// errS3PayloadMismatch - тело запроса не совпадает с X-Amz-Content-Sha256
+	errS3PayloadMismatch = errors.New("тело запроса не совпадает с X-Amz-Content-Sha256")
+
+	// errS3ChunkSignature - подпись куска aws-chunked неверна
+	errS3ChunkSignature = errors.New("неверная подпись куска aws-chunked")
+)
+
+// sigV4Error - отказ в проверке подписи с кодом ошибки S3
+type sigV4Error struct {
+	code    string
+	message string
+}
+
+func (e *sigV4Error) Error() string {
+	return e.message
+}
+
+// sigV4Request - разобранная подпись запроса
+type sigV4Request struct {
+	accessKey     string
+	date          time.Time
+	scope         string // <дата>/<регион>/s3/aws4_request
+	signedHeaders []string
+	signature     string
+	payloadHash   string
+	presigned     bool
+	expires       time.Duration // срок действия подписанной ссылки
+	secret        []byte        // ключ API клиента accessKey, заполняется при проверке
+}
+
+// isSigV4Request сообщает, подписан ли запрос AWS Signature V4
+func isSigV4Request(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" ") ||
+		r.URL.Query().Get("X-Amz-Algorithm") != ""
+}
+
+// parseSigV4 разбирает подпись из заголовка Authorization или параметров ссылки
+func parseSigV4(r *http.Request) (*sigV4Request, error) {
+	var request sigV4Request
+	var credential, signedHeaders, amzDate string
+	if authorization, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" "); ok {
+		for _, part := range strings.Split(authorization, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch key {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				request.signature = value
+			}
+		}
+		amzDate = r.Header.Get("X-Amz-Date")
+		request.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if request.payloadHash == "" {
+			return nil, &sigV4Error{"InvalidRequest", "Запрос с подписью AWS Signature V4 должен содержать X-Amz-Content-Sha256"}
+		}
+	} else {
+		query := r.URL.Query()
+		if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+			return nil, &sigV4Error{"AuthorizationQueryParametersError", "Поддерживается только алгоритм " + sigV4Algorithm}
+		}
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		request.signature = query.Get("X-Amz-Signature")
+		amzDate = query.Get("X-Amz-Date")
+		seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > sigV4MaxExpires {
+			return nil, &sigV4Error{"AuthorizationQueryParametersError", "X-Amz-Expires должен быть от 1 секунды до 7 дней"}
+		}
+		request.expires = time.Duration(seconds) * time.Second
+		request.payloadHash = sigV4UnsignedPayload
+		request.presigned = true
+	}
+
+	accessKey, scope, ok := strings.Cut(credential, "/")
+	scopeParts := strings.Split(scope, "/")
+	if !ok || len(scopeParts) != 4 || scopeParts[2] != "s3" || scopeParts[3] != "aws4_request" ||
+		signedHeaders == "" || request.signature == "" {
+		return nil, &sigV4Error{"AuthorizationHeaderMalformed", "Неверный формат подписи AWS Signature V4"}
+	}
+	date, err := time.Parse(sigV4DateFormat, amzDate)
+	if err != nil || scopeParts[0] != amzDate[:8] {
+		return nil, &sigV4Error{"AuthorizationHeaderMalformed", "Неверная дата подписи AWS Signature V4"}
+	}
+	request.accessKey = accessKey
+	request.scope = scope
+	request.date = date
+	request.signedHeaders = strings.Split(signedHeaders, ";")
+	if !slices.Contains(request.signedHeaders, "host") {
+		return nil, &sigV4Error{"AuthorizationHeaderMalformed", "Подпись AWS Signature V4 должна покрывать заголовок Host"}
+	}
+	return &request, nil
+}
+
+// verifySigV4 проверяет подпись запроса в момент now и возвращает разобранную подпись.
+// Имя клиента - accessKey
+func (r *runtimeSettings) verifySigV4(req *http.Request, now time.Time) (*sigV4Request, error) {
+	request, err := parseSigV4(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range r.apiKeys {
+		if candidate.name == request.accessKey {
+			request.secret = candidate.key
+		}
+	}
+	if request.secret == nil {
+		return nil, &sigV4Error{"InvalidAccessKeyId", "Ключ доступа не найден в api_keys"}
+	}
+
+	if request.presigned {
+		if now.Before(request.date.Add(-sigV4MaxSkew)) || now.After(request.date.Add(request.expires)) {
+			return nil, &sigV4Error{"AccessDenied", "Срок действия подписанной ссылки истек"}
+		}
+	} else if now.Sub(request.date) > sigV4MaxSkew || request.date.Sub(now) > sigV4MaxSkew {
+		return nil, &sigV4Error{"RequestTimeTooSkewed", "Время подписи отличается от времени сервера больше чем на 15 минут"}
+	}
+
+	key := sigV4SigningKey(request.secret, request.scope)
+	expected := hex.EncodeToString(hmacSHA256(key, sigV4StringToSign(request, sigV4CanonicalRequest(req, request))))
+	if !hmac.Equal([]byte(expected), []byte(request.signature)) {
+		return nil, &sigV4Error{"SignatureDoesNotMatch", "Подпись запроса не совпадает с вычисленной по секретному ключу"}
+	}
+	return request, nil
+}
+
+// sigV4CanonicalRequest собирает канонический запрос, от которого вычисляется подпись
+func sigV4CanonicalRequest(req *http.Request, request *sigV4Request) string {
+	var params [][2]string
+	for key, values := range req.URL.Query() {
+		if request.presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, [2]string{sigV4Escape(key, true), sigV4Escape(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	query := make([]string, 0, len(params))
+	for _, param := range params {
+		query = append(query, param[0]+"="+param[1])
+	}
+
+	var headers strings.Builder
+	for _, name := range request.signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = req.Host
+		case "content-length":
+			value = req.Header.Get("Content-Length")
+			if value == "" && req.ContentLength >= 0 {
+				value = strconv.FormatInt(req.ContentLength, 10)
+			}
+		default:
+			var values []string
+			for _, headerValue := range req.Header.Values(name) {
+				values = append(values, strings.Join(strings.Fields(headerValue), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	path := sigV4Escape(req.URL.Path, false)
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(query, "&"),
+		headers.String(),
+		strings.Join(request.signedHeaders, ";"),
+		request.payloadHash,
+	}, "\n")
+}
+
+// sigV4StringToSign собирает строку, которую подписывает клиент
+func sigV4StringToSign(request *sigV4Request, canonicalRequest string) string {
+	return sigV4Algorithm + "\n" + request.date.Format(sigV4DateFormat) + "\n" + request.scope + "\n" + sha256Hex([]byte(canonicalRequest))
+}
+
+// sigV4SigningKey выводит ключ подписи из секретного ключа и области подписи
+func sigV4SigningKey(secret []byte, scope string) []byte {
+	key := append([]byte("AWS4"), secret...)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+// sigV4Escape кодирует строку для канонического запроса: все символы, кроме
+// незарезервированных по RFC 3986, и слэш, если не encodeSlash
+func sigV4Escape(value string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !encodeSlash:
+			escaped.WriteByte(b)
+		default:
+			escaped.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsChunkSigner проверяет подписи кусков aws-chunked: подпись каждого куска связана
+// с подписью предыдущего, начиная с подписи запроса
+type awsChunkSigner struct {
+	key       []byte
+	date      string
+	scope     string
+	previous  string
+	emptyHash string
+}
+
+func newAWSChunkSigner(request *sigV4Request) *awsChunkSigner {
+	return &awsChunkSigner{
+		key:       sigV4SigningKey(request.secret, request.scope),
+		date:      request.date.Format(sigV4DateFormat),
+		scope:     request.scope,
+		previous:  request.signature,
+		emptyHash: sha256Hex(nil),
+	}
+}
+
+// verify проверяет подпись signature куска data
+func (s *awsChunkSigner) verify(data []byte, signature string) error {
+	stringToSign := "AWS4-HMAC-SHA256-PAYLOAD\n" + s.date + "\n" + s.scope + "\n" + s.previous + "\n" + s.emptyHash + "\n" + sha256Hex(data)
+	expected := hex.EncodeToString(hmacSHA256(s.key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errS3ChunkSignature
+	}
+	s.previous = signature
+	return nil
+}
+
+// s3PayloadReader сверяет SHA-256 прочитанного тела с подписанным значением, когда
+// тело дочитано до конца
+type s3PayloadReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (r *s3PayloadReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+		return n, errS3PayloadMismatch
+	}
+	return n, err
+}
+
+// s3AuthMiddleware проверяет подпись AWS Signature V4 или ключ API в заголовке, как
+// authMiddleware, но отвечает ошибками S3 в XML. Без api_keys шлюз доступен без подписи
+func (s *StreamingAPIServer) s3AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings := s.current()
+		if len(settings.apiKeys) == 0 {
+			c.Next()
+			return
+		}
+
+		if isSigV4Request(c.Request) {
+			request, err := settings.verifySigV4(c.Request, time.Now())
+			var sigErr *sigV4Error
+			if errors.As(err, &sigErr) {
+				writeS3Error(c, http.StatusForbidden, sigErr.code, sigErr.message)
+				c.Abort()
+				return
+			}
+			switch {
+			case strings.HasPrefix(request.payloadHash, sigV4SignedStreaming):
+				c.Set(s3ChunkSignerKey, newAWSChunkSigner(request))
+			case request.payloadHash != sigV4UnsignedPayload && !strings.HasPrefix(request.payloadHash, "STREAMING-"):
+				c.Request.Body = &s3PayloadReader{ReadCloser: c.Request.Body, hash: sha256.New(), expected: request.payloadHash}
+			}
+			c.Set(principalKey, request.accessKey)
+			c.Set(auditActorKey, request.accessKey)
+			c.Next()
+			return
+		}
+
+		if name, ok := settings.authenticate(requestAPIKey(c)); ok {
+			c.Set(principalKey, name)
+			c.Set(auditActorKey, name)
+			c.Next()
+			return
+		}
+		writeS3Error(c, http.StatusForbidden, "AccessDenied", "Запрос должен быть подписан AWS Signature V4 или содержать ключ API")
+		c.Abort()
+	}
+}
+
+// s3ChunkSigner возвращает проверку подписей кусков aws-chunked запроса; nil, если
+// подписи кусков не проверяются
+func s3ChunkSigner(c *gin.Context) *awsChunkSigner {
+	signer, _ := c.Get(s3ChunkSignerKey)
+	chunkSigner, _ := signer.(*awsChunkSigner)
+	return chunkSigner
+}
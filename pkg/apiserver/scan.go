@@ -0,0 +1,59 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"TestCase/pkg/audit"
+	"TestCase/pkg/config"
+	"TestCase/pkg/scanner"
+)
+
+var (
+	// errFileInfected возвращается, если антивирус нашел угрозу в загружаемом файле
+	errFileInfected = errors.New("файл заражен")
+
+	// errScanFailed возвращается, если проверить файл не удалось, а scanner_fail_open выключен
+	errScanFailed = errors.New("не удалось проверить файл антивирусом")
+)
+
+// newScanner создает антивирус по конфигурации; nil означает, что файлы не проверяются
+func newScanner(cfg *config.Config) (scanner.Scanner, error) {
+	switch cfg.Scanner {
+	case "":
+		return nil, nil
+	case "clamav":
+		return scanner.NewClamAV(cfg.ScannerAddress, cfg.ScannerTimeout), nil
+	case "icap":
+		return scanner.NewICAP(cfg.ScannerAddress, cfg.ScannerTimeout)
+	default:
+		return nil, fmt.Errorf("неизвестный антивирус: %s", cfg.Scanner)
+	}
+}
+
+// scanFile проверяет файл антивирусом до распределения кусков по серверам хранения.
+// Найденная угроза записывается в журнал аудита запроса
+func (s *StreamingAPIServer) scanFile(ctx context.Context, name string, fileData []byte) error {
+	if s.scanner == nil {
+		return nil
+	}
+
+	result, err := s.scanner.Scan(ctx, bytes.NewReader(fileData))
+	if err != nil {
+		if s.current().config.ScannerFailOpen {
+			log.Printf("Файл %s принят без проверки антивирусом: %v", name, err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", errScanFailed, err)
+	}
+
+	if result.Infected {
+		log.Printf("Загрузка файла %s отклонена: найдена угроза %s", name, result.Threat)
+		audit.SetThreat(ctx, result.Threat)
+		return fmt.Errorf("%w: %s", errFileInfected, result.Threat)
+	}
+	return nil
+}
@@ -0,0 +1,1460 @@
+// Package apiserver содержит API сервер, который можно встроить в другую программу
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/quic-go/quic-go/http3"
+	"google.golang.org/grpc"
+
+	"TestCase/internal/apierror"
+	"TestCase/internal/listen"
+	"TestCase/pkg/audit"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+	"TestCase/pkg/events"
+	"TestCase/pkg/mirror"
+	"TestCase/pkg/scanner"
+	"TestCase/pkg/storage"
+	"TestCase/pkg/webhook"
+)
+
+// StreamingAPIServer представляет оптимизированный API сервер с потоковой обработкой.
+// Сервер создается NewStreamingAPIServer, запускается Start и останавливается Shutdown
+type StreamingAPIServer struct {
+	// Изменяемые при перезагрузке конфигурации параметры; обработчик запроса
+	// берет снимок один раз, чтобы работать с согласованными значениями
+	settings      atomic.Pointer[runtimeSettings]
+	settingsMutex sync.Mutex // упорядочивает замену снимка при SIGHUP и обновлении из реестра
+
+	// Наблюдение за составом серверов хранения в реестре; nil - статический список
+	discovery        context.CancelFunc
+	discoveryStopped chan struct{}
+
+	catalog catalog.Store // каталог метаданных файлов
+
+	// Свободное место серверов хранения для размещения новых кусков
+	capacity *capacityTracker
+
+	// Состояния серверов хранения, заданные администратором (обслуживание, вывод из эксплуатации)
+	nodes *nodeRegistry
+
+	// Восстановление недостающих копий кусков
+	repair *repairController
+
+	// Выравнивание объема данных между серверами хранения
+	rebalance *rebalanceController
+
+	// Статистика скачиваний файлов, еще не записанная в каталог
+	access *accessTracker
+
+	// Замены файлов по одному пути выполняются по очереди
+	pathLocks *pathLocks
+
+	// Недавно прочитанные куски; nil, если кэш отключен
+	chunkCache *chunkCache
+
+	// Одновременные скачивания одного файла целиком
+	downloads *downloadCoalescer
+
+	// Использованные токены однократной загрузки
+	uploadGrants *uploadGrantRegistry
+	dropBoxes    *dropBoxRegistry
+
+	// HTTP сервер, запущенный Start; nil, если сервер встроен через Handler
+	httpServer *http.Server
+
+	// Экспериментальный сервер HTTP/3 для скачивания; nil, если http3_enabled выключен
+	http3 *http3.Server
+
+	// Публичный gRPC API, запущенный Start; nil, если grpc_listen не задан
+	grpcServer *grpc.Server
+
+	// Схема запросов метаданных GraphQL; nil, если graphql_enabled выключен
+	graphQL *graphql.Schema
+
+	// События жизненного цикла файлов и их доставка через webhook
+	events          *events.Bus
+	webhooks        *webhook.Dispatcher
+	stopWebhooks    func()
+	webhooksStopped chan struct{}
+
+	// Зеркалирование файлов в резервное развертывание; nil, если mirror_url не задан
+	mirror        *mirror.Replicator
+	mirrorTarget  string
+	stopMirror    func()
+	mirrorStopped chan struct{}
+
+	// Журнал аудита изменяющих операций; nil, если аудит отключен
+	audit *audit.Logger
+
+	// Антивирус для загружаемых файлов; nil, если проверка отключена
+	scanner scanner.Scanner
+
+	// Закрывается при остановке сервера, чтобы завершить долгоживущие потоки событий
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// NewStreamingAPIServer создает новый потоковый API сервер
+func NewStreamingAPIServer(cfg *config.Config) (*StreamingAPIServer, error) {
+	// При обнаружении через реестр список серверов появится после первого ответа реестра
+	if cfg.DiscoveryBackend != "" {
+		discovered := *cfg
+		discovered.StorageServers = nil
+		cfg = &discovered
+	}
+
+	settings, err := newRuntimeSettings(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &StreamingAPIServer{
+		capacity:  newCapacityTracker(),
+		nodes:     newNodeRegistry(),
+		repair:    newRepairController(),
+		rebalance: &rebalanceController{},
+		access:    newAccessTracker(cfg.AccessStatsInterval),
+		events:    events.NewBus(),
+		shutdown:  make(chan struct{}),
+
+		uploadGrants: newUploadGrantRegistry(),
+		dropBoxes:    newDropBoxRegistry(),
+
+		pathLocks:  newPathLocks(),
+		chunkCache: newChunkCache(cfg.ChunkCacheSize),
+		downloads:  &downloadCoalescer{},
+	}
+	server.settings.Store(settings)
+
+	auditLogger, err := newAuditLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	server.audit = auditLogger
+
+	fileScanner, err := newScanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	server.scanner = fileScanner
+
+	if cfg.GraphQLEnabled {
+		schema, err := server.newGraphQLSchema()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось построить схему GraphQL: %w", err)
+		}
+		server.graphQL = &schema
+	}
+
+	if len(cfg.WebhookURLs) > 0 {
+		dispatcher, err := webhook.NewDispatcher(webhook.Config{
+			URLs:           cfg.WebhookURLs,
+			Secret:         cfg.WebhookSecret,
+			MaxRetries:     cfg.WebhookMaxRetries,
+			RetryDelay:     cfg.WebhookRetryDelay,
+			DeadLetterPath: cfg.WebhookDeadLetterFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		eventsChan, unsubscribe := server.events.Subscribe(1000)
+		server.webhooks = dispatcher
+		server.stopWebhooks = unsubscribe
+		server.webhooksStopped = make(chan struct{})
+
+		go func() {
+			defer close(server.webhooksStopped)
+			dispatcher.Consume(eventsChan)
+		}()
+	}
+
+	// Каталог метаданных: в памяти, реплицируемый через Raft или во внешней базе
+	store, err := newCatalog(cfg)
+	if err != nil {
+		return nil, err
+	}
+	server.catalog = store
+
+	if cfg.MirrorURL != "" {
+		if err := server.startMirror(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DiscoveryBackend != "" {
+		if err := server.startDiscovery(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.CapacityRefreshInterval > 0 {
+		server.capacity.start(cfg.CapacityRefreshInterval, server.current)
+	}
+	go server.runRepair(cfg.RepairInterval)
+	go server.runAccessFlush()
+
+	return server, nil
+}
+
+// beginShutdown сообщает долгоживущим обработчикам о начале остановки сервера
+func (s *StreamingAPIServer) beginShutdown() {
+	s.shutdownOnce.Do(func() {
+		close(s.shutdown)
+	})
+}
+
+// Close останавливает фоновые подсистемы сервера
+func (s *StreamingAPIServer) Close() error {
+	s.beginShutdown()
+
+	if s.discovery != nil {
+		s.discovery()
+		<-s.discoveryStopped
+	}
+	s.capacity.close()
+	<-s.repair.done
+	<-s.access.done
+
+	var errs []error
+	if s.webhooks != nil {
+		// Сначала прекращаем прием событий, затем отправляем уже полученные
+		s.stopWebhooks()
+		<-s.webhooksStopped
+		errs = append(errs, s.webhooks.Close())
+	}
+
+	if s.mirror != nil {
+		s.stopMirror()
+		<-s.mirrorStopped
+		errs = append(errs, s.mirror.Close())
+	}
+
+	if s.audit != nil {
+		errs = append(errs, s.audit.Close())
+	}
+
+	errs = append(errs, s.catalog.Close())
+
+	return errors.Join(errs...)
+}
+
+// calculateChecksum вычисляет контрольную сумму настроенным алгоритмом
+func calculateChecksum(algorithm chunking.HashAlgorithm, data []byte) string {
+	// Алгоритм проверен при загрузке конфигурации, поэтому ошибка невозможна
+	checksum, _ := chunking.Checksum(algorithm, data)
+	return checksum
+}
+
+// setupStreamingRoutes настраивает маршруты для потокового API
+func (s *StreamingAPIServer) setupStreamingRoutes() *gin.Engine {
+	router := gin.New()
+
+	// Обрыв начатых ответов, логирование и восстановление после паники обработчиков
+	router.Use(abortMiddleware)
+	router.Use(gin.Logger())
+	router.Use(gin.Recovery())
+
+	// Идентификатор запроса назначается первым, чтобы попасть в ответы всех обработчиков
+	router.Use(requestIDMiddleware())
+	router.NoRoute(routeNotFound)
+
+	// Служебные маршруты кластера метаданных регистрируются до CORS и аудита: это не запросы клиентов
+	s.setupCatalogRoutes(router)
+
+	// CORS обрабатывается до остальных middleware, чтобы предварительные запросы не попадали в аудит
+	if len(s.current().config.CORSAllowedOrigins) > 0 {
+		router.Use(s.corsMiddleware())
+	}
+
+	// Журнал аудита изменяющих запросов, включая S3 и WebDAV
+	if s.audit != nil {
+		router.Use(s.auditMiddleware())
+	}
+
+	// Проверка здоровья сервиса
+	router.GET("/health", s.healthCheck)
+
+	// API для работы с файлами
+	v1 := router.Group("/api/v1")
+	v1.Use(s.authMiddleware())
+	{
+		v1.POST("/files", s.streamingUploadFile)
+		v1.PUT("/files", s.streamingUploadRaw)
+		v1.POST("/files/fetch", s.fetchFileFromURL)
+		v1.POST("/files/dedup", s.uploadDuplicate)
+		v1.POST("/files/archive", s.downloadArchive)
+		v1.POST("/upload-tokens", s.createUploadToken)
+		v1.POST("/drop-boxes", s.createDropBox)
+		v1.GET("/drop/:token", s.getDropBox)
+		v1.POST("/drop/:token", s.uploadToDropBox)
+		v1.POST("/uploads", s.createUploadPlan)
+		v1.POST("/uploads/:id/commit", s.commitUpload)
+		v1.GET("/files/by-checksum/:sha256", s.findFilesByChecksum)
+		v1.GET("/files/:id", s.streamingDownloadFile)
+		v1.HEAD("/files/:id", s.streamingDownloadFile)
+		v1.GET("/files/:id/info", s.getFileInfo)
+		v1.GET("/files/:id/preview", s.previewFile)
+		v1.GET("/files/:id/manifest", s.getFileManifest)
+		v1.GET("/files/:id/signature", s.getFileSignature)
+		v1.GET("/files/:id/merkle", s.getFileMerkleProof)
+		v1.POST("/files/:id/delta", s.uploadDelta)
+		v1.PUT("/files/:id/visibility", s.setFileVisibility)
+		v1.GET("/files/:id/acl", s.getFileACL)
+		v1.PUT("/files/:id/acl/:principal", s.grantFileAccess)
+		v1.DELETE("/files/:id/acl/:principal", s.revokeFileAccess)
+		v1.GET("/public/:id", s.downloadPublicFile)
+		v1.HEAD("/public/:id", s.downloadPublicFile)
+		v1.PUT("/files/:id", s.replaceFileContent)
+		v1.PATCH("/files/:id", s.patchFile)
+		v1.DELETE("/files/:id", s.deleteFile)
+		v1.GET("/files", s.listFiles)
+		v1.GET("/events", s.streamEvents)
+
+		if s.audit != nil {
+			v1.GET("/audit", s.queryAudit)
+		}
+		if s.graphQL != nil {
+			v1.GET("/graphql", s.serveGraphQL)
+			v1.POST("/graphql", s.serveGraphQL)
+		}
+		if s.current().config.DashboardEnabled {
+			v1.GET("/dashboard/files", s.listDashboardFiles)
+		}
+
+		// Администрирование серверов хранения; адрес сервера указывается как host:port
+		admin := v1.Group("/admin", s.adminMiddleware())
+		admin.GET("/nodes", s.listNodes)
+		admin.GET("/nodes/:id", s.getNode)
+		admin.POST("/nodes/:id/drain", s.startDrain)
+		admin.DELETE("/nodes/:id/drain", s.cancelDrain)
+		admin.POST("/nodes/:id/cordon", s.cordonNode)
+		admin.DELETE("/nodes/:id/cordon", s.uncordonNode)
+		admin.GET("/repair", s.getRepairStatus)
+		admin.POST("/repair", s.startRepair)
+		admin.GET("/stats", s.getStats)
+		admin.GET("/rebalance", s.getRebalanceStatus)
+		admin.POST("/rebalance", s.startRebalance)
+		admin.DELETE("/rebalance", s.cancelRebalance)
+		admin.GET("/catalog/export", s.exportCatalog)
+		admin.POST("/catalog/import", s.importCatalog)
+		admin.POST("/catalog/rebuild", s.rebuildCatalog)
+		admin.GET("/fsck", s.checkConsistency)
+		admin.GET("/mirror", s.getMirrorStatus)
+		admin.POST("/mirror/resync", s.resyncMirror)
+	}
+
+	// Документация API
+	s.setupDocsRoutes(router)
+
+	// Веб-панель администратора
+	s.setupDashboardRoutes(router)
+
+	// S3-совместимый шлюз и WebDAV
+	s.setupS3Routes(router)
+	s.setupWebDAVRoutes(router)
+
+	return router
+}
+
+// healthCheck проверяет состояние сервиса
+func (s *StreamingAPIServer) healthCheck(c *gin.Context) {
+	settings := s.current()
+
+	// Проверяем доступность серверов хранения
+	var healthyServers int
+	for i, client := range settings.storageClients {
+		if err := client.HealthCheckContext(c.Request.Context()); err != nil {
+			log.Printf("Сервер хранения %d недоступен: %v", i, err)
+		} else {
+			healthyServers++
+		}
+	}
+
+	status := "healthy"
+	if healthyServers < settings.config.ChunkCount {
+		status = "degraded"
+	}
+
+	response := gin.H{
+		"status":          status,
+		"healthy_servers": healthyServers,
+		"total_servers":   len(settings.storageClients),
+		"timestamp":       time.Now().Unix(),
+	}
+
+	// Роль узла в кластере метаданных помогает найти лидера и узлы без кворума
+	if raftStore, ok := s.catalog.(*catalog.RaftStore); ok {
+		response["metadata"] = gin.H{
+			"state":  raftStore.State(),
+			"leader": raftStore.Leader(),
+		}
+	}
+	if pinger, ok := s.catalog.(catalogPinger); ok {
+		metadata := gin.H{"status": "healthy"}
+		if err := pinger.Ping(c.Request.Context()); err != nil {
+			log.Printf("Хранилище метаданных недоступно: %v", err)
+			metadata = gin.H{"status": "unavailable", "error": err.Error()}
+			response["status"] = "degraded"
+		}
+		response["metadata"] = metadata
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// streamingUploadFile обрабатывает загрузку файла формой multipart
+func (s *StreamingAPIServer) streamingUploadFile(c *gin.Context) {
+	s.uploadFile(c, readFormUpload)
+}
+
+// uploadBody - содержимое загружаемого файла и сведения о нем из запроса
+type uploadBody struct {
+	data        []byte
+	name        string
+	contentType string
+	path        string
+	public      string
+}
+
+// uploadBodyReader читает загружаемый файл из запроса через spool. При ошибке отвечает
+// клиенту сам и возвращает false
+type uploadBodyReader func(c *gin.Context, spool *uploadSpool, maxFileSize int64) (*uploadBody, bool)
+
+// uploadFile сохраняет файл, прочитанный из запроса функцией read, с учетом токена загрузки
+func (s *StreamingAPIServer) uploadFile(c *gin.Context, read uploadBodyReader) {
+	settings := s.current()
+	maxFileSize := settings.config.MaxFileSize
+	var info uploadInfo
+
+	// Загрузка по токену загрузки: файл получает идентификатор из токена и проходит его ограничения
+	if signed := c.Query("token"); signed != "" {
+		grant, grantErr := s.claimUploadGrant(c, settings, signed)
+		if grantErr != nil {
+			writeRequestError(c, grantErr)
+			return
+		}
+		// Токен остается использованным, только если файл сохранен
+		defer func() {
+			if c.Writer.Status() != http.StatusOK {
+				s.uploadGrants.release(grant.FileID)
+			}
+		}()
+
+		if grant.MaxSize < maxFileSize {
+			maxFileSize = grant.MaxSize
+		}
+		info.FileID = grant.FileID
+		info.Owner = grant.Issuer
+		info.ContentTypes = grant.ContentTypes
+		if c.GetString(auditActorKey) == "" {
+			actor := "upload-token"
+			if grant.Issuer != "" {
+				actor += ":" + grant.Issuer
+			}
+			c.Set(auditActorKey, actor)
+		}
+	}
+
+	policy, policyErr := s.parseUploadPolicy(c, settings)
+	if policyErr != nil {
+		writeRequestError(c, policyErr)
+		return
+	}
+
+	spool := s.newUploadSpool()
+	defer spool.Close()
+	body, ok := read(c, spool, maxFileSize)
+	if !ok {
+		return
+	}
+	if checksumErr := verifyContentSHA256(c, body.data); checksumErr != nil {
+		writeRequestError(c, checksumErr)
+		return
+	}
+
+	public, publicErr := parsePublicField(body.public)
+	if publicErr != nil {
+		writeRequestError(c, publicErr)
+		return
+	}
+
+	// Файл, загруженный по токену, остается закрытым: публикует его владелец ключа API
+	if public && info.FileID != "" {
+		writeError(c, http.StatusForbidden, apierror.TokenUploadPublic)
+		return
+	}
+
+	if info.FileID == "" {
+		info.Owner = c.GetString(principalKey)
+	}
+	info.Name = body.name
+	info.Public = public
+	info.ContentType = body.contentType
+	info.Path = cleanFilePath(body.path)
+	info.Policy = policy
+	metadata, err := s.storeFile(c.Request.Context(), info, body.data)
+	if err != nil {
+		writeStoreError(c, apierror.StoreFailed, err)
+		return
+	}
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
+
+// maxFormOverhead - запас сверх max_file_size на границы частей и остальные поля формы загрузки
+const maxFormOverhead = 1 << 20
+
+// readFormUpload читает файл из поля file формы multipart, а остальные сведения - из полей формы
+func readFormUpload(c *gin.Context, spool *uploadSpool, maxFileSize int64) (*uploadBody, bool) {
+	fileData, header, ok := readFormFile(c, spool, maxFileSize)
+	if !ok {
+		return nil, false
+	}
+	return &uploadBody{
+		data:        fileData,
+		name:        header.Filename,
+		contentType: header.Header.Get("Content-Type"),
+		path:        c.PostForm("path"),
+		public:      c.PostForm("public"),
+	}, true
+}
+
+// readFormFile читает файл из поля file формы multipart/form-data не больше maxFileSize
+// байт через spool. При ошибке ответ уже отправлен
+func readFormFile(c *gin.Context, spool *uploadSpool, maxFileSize int64) ([]byte, *multipart.FileHeader, bool) {
+	// Размер в заголовках может отсутствовать (chunked encoding) или не совпадать с
+	// фактическим, поэтому тело запроса ограничивается при чтении: запрос прерывается,
+	// как только прочитано больше допустимого
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize+maxFormOverhead)
+
+	// Получаем файл из формы
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			fileTooLarge(c, maxFileSize)
+			return nil, nil, false
+		}
+		writeError(c, http.StatusBadRequest, apierror.FileMissing)
+		return nil, nil, false
+	}
+	defer file.Close()
+
+	// Проверяем заявленный размер файла
+	if header.Size > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return nil, nil, false
+	}
+
+	// Читаем файл для chunking, не больше допустимого размера; большой файл - через
+	// временный файл в upload_dir
+	fileData, err := spool.readAll(io.LimitReader(file, maxFileSize+1))
+	if errors.Is(err, errUploadSpool) {
+		uploadSpoolFailed(c, err)
+		return nil, nil, false
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.FileReadFailed)
+		return nil, nil, false
+	}
+	if int64(len(fileData)) > maxFileSize {
+		fileTooLarge(c, maxFileSize)
+		return nil, nil, false
+	}
+
+	return fileData, header, true
+}
+
+// uploadSpoolFailed отвечает на ошибку временного файла загрузки
+func uploadSpoolFailed(c *gin.Context, err error) {
+	log.Printf("Ошибка временного файла загрузки: %v", err)
+	writeError(c, http.StatusInternalServerError, apierror.UploadSpoolFailed)
+}
+
+// fileTooLarge отвечает на загрузку файла больше max_file_size
+func fileTooLarge(c *gin.Context, maxFileSize int64) {
+	writeError(c, http.StatusRequestEntityTooLarge, apierror.FileTooLarge, maxFileSize)
+}
+
+// storeErrorStatus возвращает HTTP статус для ошибки сохранения файла
+func storeErrorStatus(err error) int {
+	if errors.Is(err, errInsufficientCapacity) || errors.Is(err, storage.ErrQuotaExceeded) {
+		return http.StatusInsufficientStorage
+	}
+	if errors.Is(err, errContentTypeNotAllowed) {
+		return http.StatusUnsupportedMediaType
+	}
+	if errors.Is(err, errFilenameRejected) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, errFileInfected) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, errScanFailed) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// storeErrorCode возвращает код ошибки сохранения файла: для ошибок проверок содержимого
+// и нехватки места - собственный код, для остальных - fallback
+func storeErrorCode(err error, fallback apierror.Code) apierror.Code {
+	switch {
+	case errors.Is(err, errInsufficientCapacity), errors.Is(err, storage.ErrQuotaExceeded):
+		return apierror.InsufficientStorage
+	case errors.Is(err, errContentTypeNotAllowed):
+		return apierror.ContentTypeNotAllowed
+	case errors.Is(err, errFilenameRejected):
+		return apierror.FilenameRejected
+	case errors.Is(err, errFileInfected):
+		return apierror.FileInfected
+	case errors.Is(err, errScanFailed):
+		return apierror.ScanFailed
+	}
+	return fallback
+}
+
+// uploadInfo описывает сохраняемый файл
+type uploadInfo struct {
+	Name        string // оригинальное имя файла
+	ContentType string // MIME тип, заявленный клиентом
+	Path        string // логический путь файла (необязательный)
+	Public      bool   // файл доступен по публичной ссылке
+	Owner       string // владелец файла; пусто - права доступа не проверяются
+	DropBox     string // ящик для приема файлов, через который загружен файл
+
+	Grants map[string]chunking.Permission // права доступа других клиентов, например сохраняемые при замене
+
+	FileID       string   // идентификатор файла; пусто - новый идентификатор
+	ContentTypes []string // дополнительно разрешенные типы содержимого, например из токена загрузки
+
+	Policy uploadPolicy // разбиение на куски и число копий, выбранные клиентом
+}
+
+// storeFile разделяет данные на куски, распределяет их по серверам хранения и сохраняет метаданные
+func (s *StreamingAPIServer) storeFile(ctx context.Context, info uploadInfo, fileData []byte) (*chunking.FileMetadata, error) {
+	// Генерируем ID файла
+	fileID := info.FileID
+	if fileID == "" {
+		fileID = s.current().fileIDScheme.NewID()
+	}
+
+	metadata, err := s.storeContent(ctx, s.current(), info, fileID, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сохраняем метаданные; без них куски недоступны, поэтому при ошибке удаляем их
+	if err := s.putMetadata(ctx, metadata); err != nil {
+		s.deleteChunks(ctx, metadata)
+		return nil, fmt.Errorf("не удалось сохранить метаданные: %w", err)
+	}
+	s.repairMissingCopies(metadata.Chunks, fileReplicationFactor(s.current(), metadata))
+
+	s.events.Publish(events.NewFileEvent(events.FileUploaded, metadata))
+	recordAuditFile(ctx, metadata)
+
+	return metadata, nil
+}
+
+// storeContent проверяет данные файла, разделяет их на куски и распределяет по серверам
+// хранения. Возвращает метаданные, которые еще не сохранены в каталоге
+func (s *StreamingAPIServer) storeContent(ctx context.Context, settings *runtimeSettings, info uploadInfo, fileID string, fileData []byte) (*chunking.FileMetadata, error) {
+	detectedType, err := s.checkFile(ctx, settings, info, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Разделяем файл на куски в памяти
+	chunks, err := chunkFileInMemory(fileData, fileID, s.chunkCount(settings, int64(len(fileData)), info.Policy), settings.hashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разделить файл: %w", err)
+	}
+
+	// Запоминаем сервер каждого куска: состав серверов может измениться после загрузки
+	if err := s.placeChunks(settings, chunks, info.Policy.replicationFactor(settings)); err != nil {
+		return nil, err
+	}
+
+	// Создаем метаданные файла
+	metadata := &chunking.FileMetadata{
+		ID:           fileID,
+		OriginalName: info.Name,
+		Size:         int64(len(fileData)),
+		Checksum:     calculateChecksum(settings.hashAlgorithm, fileData),
+		ContentType:  info.ContentType,
+		ChunkCount:   len(chunks),
+		Chunks:       chunks,
+
+		ChecksumAlgorithm:   settings.hashAlgorithm,
+		ReplicationFactor:   info.Policy.ReplicationFactor,
+		StorageClass:        info.Policy.StorageClass,
+		DetectedContentType: detectedType,
+		Path:                info.Path,
+		CreatedAt:           time.Now().UTC(),
+		Public:              info.Public,
+		Owner:               info.Owner,
+		Grants:              info.Grants,
+		DropBox:             info.DropBox,
+	}
+
+	// Сохраняем куски на серверах хранения
+	if err := s.distributeChunks(ctx, metadata.Chunks); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить куски: %w", err)
+	}
+
+	// Данные уже на серверах хранения, в метаданных остается только описание кусков
+	for i := range metadata.Chunks {
+		metadata.Chunks[i].Data = nil
+	}
+	return metadata, nil
+}
+
+// checkFile проверяет имя, тип содержимого и отсутствие угроз в файле перед сохранением
+// и возвращает тип, определенный по содержимому
+func (s *StreamingAPIServer) checkFile(ctx context.Context, settings *runtimeSettings, info uploadInfo, fileData []byte) (string, error) {
+	if err := settings.checkFilename(info.Name); err != nil {
+		return "", err
+	}
+
+	// Заявленному клиентом типу нельзя доверять, поэтому проверяется тип по содержимому
+	detectedType := detectContentType(fileData)
+	if err := checkContentType(settings.config.AllowedContentTypes, detectedType); err != nil {
+		return "", err
+	}
+	if err := checkContentType(info.ContentTypes, detectedType); err != nil {
+		return "", err
+	}
+
+	if err := s.scanFile(ctx, info.Name, fileData); err != nil {
+		return "", err
+	}
+	return detectedType, nil
+}
+
+// chunkFileInMemory разделяет файл на куски в памяти. Куски получают случайные
+// идентификаторы, поэтому при замене содержимого файла новые куски не совпадают со старыми
+func chunkFileInMemory(data []byte, fileID string, chunkCount int, algorithm chunking.HashAlgorithm) ([]chunking.FileChunk, error) {
+	fileSize := len(data)
+	chunkSize := fileSize / chunkCount
+
+	chunks := make([]chunking.FileChunk, chunkCount)
+
+	for i := 0; i < chunkCount; i++ {
+		start := i * chunkSize
+		end := start + chunkSize
+
+		// Последний кусок получает все оставшиеся данные
+		if i == chunkCount-1 {
+			end = fileSize
+		}
+
+		chunkData := data[start:end]
+
+		chunks[i] = chunking.FileChunk{
+			ID:       chunking.NewChunkID(),
+			FileID:   fileID,
+			Index:    i,
+			Data:     chunkData,
+			Checksum: calculateChecksum(algorithm, chunkData),
+			Size:     int64(len(chunkData)),
+
+			Algorithm: algorithm,
+		}
+	}
+
+	return chunks, nil
+}
+
+// distributeChunks распределяет куски файла и их копии по серверам хранения. Загрузка
+// успешна, если каждый кусок сохранен не меньше чем на write_quorum серверах: серверы,
+// не принявшие копию, исключаются из метаданных куска, а недостающие копии создает
+// восстановление (см. repairMissingCopies). Если кворум не набран, уже сохраненные копии
+// удаляются
+func (s *StreamingAPIServer) distributeChunks(ctx context.Context, chunks []chunking.FileChunk) error {
+	settings := s.current()
+	var wg sync.WaitGroup
+	errs := make([][]error, len(chunks)) // ошибка сохранения на каждом сервере куска
+
+	for i, chunk := range chunks {
+		if chunk.Node == "" {
+			return fmt.Errorf("в метаданных куска %s не указан сервер хранения", chunk.ID)
+		}
+
+		// Серверы хранения выбраны при размещении куска
+		nodes := chunk.Nodes()
+		errs[i] = make([]error, len(nodes))
+		for j, node := range nodes {
+			wg.Add(1)
+			go func(chunkIndex, nodeIndex int, chunkData chunking.FileChunk, node string) {
+				defer wg.Done()
+
+				// Пытаемся сохранить кусок
+				if err := settings.clientForNode(node).StoreChunkContext(ctx, &chunkData); err != nil {
+					errs[chunkIndex][nodeIndex] = fmt.Errorf("не удалось сохранить кусок %d на сервере %s: %w", chunkIndex, node, err)
+					return
+				}
+
+				log.Printf("Кусок %d сохранен на сервере %s", chunkIndex, node)
+			}(i, j, chunk, node)
+		}
+	}
+
+	wg.Wait()
+
+	var quorumErr error
+	var missing int // копии, которые создаст восстановление
+	for i := range chunks {
+		nodes := chunks[i].Nodes()
+		var durable []string
+		var lastErr error
+		for j, node := range nodes {
+			if errs[i][j] != nil {
+				lastErr = errs[i][j]
+				continue
+			}
+			durable = append(durable, node)
+		}
+		if len(durable) == len(nodes) {
+			continue
+		}
+
+		// Кворум не строже, чем сохранение на всех выбранных серверах
+		quorum := len(nodes)
+		if settings.config.WriteQuorum > 0 {
+			quorum = min(settings.config.WriteQuorum, len(nodes))
+		}
+		if len(durable) < quorum && quorumErr == nil {
+			quorumErr = fmt.Errorf("кусок %d сохранен на %d из %d серверов, нужно не меньше %d: %w",
+				i, len(durable), len(nodes), quorum, lastErr)
+		}
+		log.Printf("Предупреждение: %v", lastErr)
+
+		chunks[i].Node, chunks[i].Replicas = "", nil
+		if len(durable) > 0 {
+			chunks[i].Node, chunks[i].Replicas = durable[0], durable[1:]
+		}
+		missing += len(nodes) - len(durable)
+	}
+
+	if quorumErr != nil {
+		s.deleteChunks(ctx, &chunking.FileMetadata{Chunks: chunks})
+		return quorumErr
+	}
+	if missing > 0 {
+		log.Printf("Куски сохранены с кворумом записи, недостает копий: %d; их создаст восстановление", missing)
+	}
+	return nil
+}
+
+// repairMissingCopies запускает восстановление, если у кусков меньше копий, чем factor,
+// например после загрузки с кворумом записи. Восстановление находит куски по каталогу,
+// поэтому вызывается после сохранения метаданных
+func (s *StreamingAPIServer) repairMissingCopies(chunks []chunking.FileChunk, factor int) {
+	for _, chunk := range chunks {
+		if len(chunk.Nodes()) < factor {
+			s.repair.request()
+			return
+		}
+	}
+}
+
+// streamingDownloadFile обрабатывает скачивание файла с потоковой передачей.
+// Запрос с заголовком Range получает только запрошенный диапазон, для которого
+// с серверов хранения загружаются лишь покрывающие его куски; это позволяет
+// перематывать видео и аудио в браузерах и плеерах
+func (s *StreamingAPIServer) streamingDownloadFile(c *gin.Context) {
+	// Получаем метаданные файла
+	metadata, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+
+	s.serveFile(c, metadata)
+}
+
+// serveFile отдает содержимое файла целиком или диапазон из заголовка Range
+func (s *StreamingAPIServer) serveFile(c *gin.Context, metadata *chunking.FileMetadata) {
+	contentType := fileContentType(metadata)
+	settings := s.current()
+	filename, ok := settings.downloadFilename(c.Query("filename"), metadata.OriginalName)
+	if !ok {
+		writeError(c, http.StatusBadRequest, apierror.InvalidFilename, c.Query("filename"))
+		return
+	}
+	disposition, ok := settings.downloadDisposition(c.Query("disposition"), contentType)
+	if !ok {
+		writeError(c, http.StatusBadRequest, apierror.InvalidDisposition, c.Query("disposition"))
+		return
+	}
+	if !applyReadConsistency(c, settings) {
+		return
+	}
+	s.advertiseHTTP3(c)
+	setChecksumHeaders(c, metadata)
+	setDigestHeader(c, metadata)
+	c.Header("Content-Disposition", contentDisposition(disposition, filename))
+	if disposition == dispositionInline {
+		// Браузер не должен угадывать тип по содержимому: файл показывается только как
+		// проверенный тип из inline_content_types
+		c.Header("X-Content-Type-Options", "nosniff")
+	}
+	c.Header("Accept-Ranges", "bytes")
+	setCacheHeaders(c, settings, metadata)
+
+	if notModified(c, metadata) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	window, partial, err := parseRange(c.GetHeader("Range"), metadata.Size)
+	if errors.Is(err, errRangeNotSatisfiable) {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", metadata.Size))
+		writeError(c, http.StatusRequestedRangeNotSatisfiable, apierror.RangeNotSatisfiable)
+		return
+	}
+	if partial {
+		s.downloadRange(c, metadata, window, contentType)
+		return
+	}
+
+	if c.Request.Method == http.MethodHead {
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Length", strconv.FormatInt(metadata.Size, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	// Заголовок Digest с вычисленной суммой нужен до содержимого, поэтому такой файл
+	// собирается целиком; остальные скачивания передаются потоком кусков
+	if needsComputedDigest(c) {
+		s.serveAssembledFile(c, metadata, contentType)
+		return
+	}
+	s.streamFile(c, metadata, contentType)
+}
+
+// streamFile передает файл клиенту кусок за куском, заранее запрашивая следующие
+// download_prefetch_chunks кусков. Первый кусок получается до ответа, поэтому
+// недоступный файл получает ошибку в JSON; ошибка получения куска после начала передачи
+// или несовпадение контрольной суммы обрывают ответ
+func (s *StreamingAPIServer) streamFile(c *gin.Context, metadata *chunking.FileMetadata, contentType string) {
+	reader, err := s.newFileReader(c.Request.Context(), metadata, 0)
+	if err != nil {
+		downloadFailed(c, apierror.VerifyFailed, err)
+		return
+	}
+	defer reader.Close()
+
+	if err := reader.fill(); err != nil && err != io.EOF {
+		if errors.Is(err, errFileChecksumMismatch) {
+			log.Printf("Файл %s: %v", metadata.ID, err)
+			downloadFailed(c, apierror.ChecksumMismatch)
+		} else {
+			chunksFailed(c, err)
+		}
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(metadata.Size, 10))
+	c.Status(http.StatusOK)
+	written, err := reader.WriteTo(c.Writer)
+	if err != nil {
+		log.Printf("Не удалось отдать файл %s: %v", metadata.ID, err)
+		abortResponse(c)
+		return
+	}
+	s.recordAccess(metadata.ID, true, written)
+}
+
+// serveAssembledFile отдает файл, собранный целиком. Одновременные скачивания файла ждут
+// одной сборки
+func (s *StreamingAPIServer) serveAssembledFile(c *gin.Context, metadata *chunking.FileMetadata, contentType string) {
+	fileData, err := s.assembleFile(c.Request.Context(), metadata)
+	if err != nil {
+		var assembleErr *requestError
+		if errors.As(err, &assembleErr) {
+			downloadFailed(c, assembleErr.code, assembleErr.args...)
+		} else {
+			chunksFailed(c, err)
+		}
+		return
+	}
+
+	setComputedDigest(c, fileData)
+	c.DataFromReader(http.StatusOK, int64(len(fileData)), contentType, bytes.NewReader(fileData), nil)
+	s.recordAccess(metadata.ID, true, int64(len(fileData)))
+}
+
+// downloadRange отдает диапазон файла, загружая только покрывающие его куски.
+// Целостность каждого куска проверяется по его контрольной сумме при получении
+func (s *StreamingAPIServer) downloadRange(c *gin.Context, metadata *chunking.FileMetadata, window byteRange, contentType string) {
+	c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", window.start, window.end, metadata.Size))
+
+	if c.Request.Method == http.MethodHead {
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Length", strconv.FormatInt(window.length(), 10))
+		c.Status(http.StatusPartialContent)
+		return
+	}
+
+	data, ok := s.readRange(c, metadata, window)
+	if !ok {
+		return
+	}
+	c.DataFromReader(http.StatusPartialContent, int64(len(data)), contentType, bytes.NewReader(data), nil)
+
+	// Плееры запрашивают файл многими диапазонами: скачиванием считается только диапазон с начала файла
+	s.recordAccess(metadata.ID, window.start == 0, int64(len(data)))
+}
+
+// readRange загружает с серверов хранения только куски, покрывающие диапазон window, и
+// возвращает его байты. При ошибке отвечает клиенту сам и возвращает false
+func (s *StreamingAPIServer) readRange(c *gin.Context, metadata *chunking.FileMetadata, window byteRange) ([]byte, bool) {
+	covering, skip := chunksForRange(metadata.Chunks, window)
+	chunks, err := s.collectChunks(c.Request.Context(), covering)
+	if err != nil {
+		chunksFailed(c, err)
+		return nil, false
+	}
+
+	data, err := s.reconstructFileInMemory(chunks)
+	if err != nil {
+		downloadFailed(c, apierror.AssembleFailed, err)
+		return nil, false
+	}
+	if skip+window.length() > int64(len(data)) {
+		downloadFailed(c, apierror.ChunkSizesMismatch)
+		return nil, false
+	}
+
+	return data[skip : skip+window.length()], true
+}
+
+// setChecksumHeaders передает ожидаемую контрольную сумму файла, чтобы клиент мог проверить данные
+func setChecksumHeaders(c *gin.Context, metadata *chunking.FileMetadata) {
+	algorithm := metadata.ChecksumAlgorithm
+	if algorithm == "" {
+		algorithm = chunking.DefaultHashAlgorithm
+	}
+
+	c.Header("X-Checksum", metadata.Checksum)
+	c.Header("X-Checksum-Algorithm", string(algorithm))
+	if metadata.MerkleRoot != "" {
+		c.Header("X-Merkle-Root", metadata.MerkleRoot)
+	}
+}
+
+// reconstructFileInMemory собирает файл из кусков в памяти
+func (s *StreamingAPIServer) reconstructFileInMemory(chunks []chunking.FileChunk) ([]byte, error) {
+	var totalSize int
+	for _, chunk := range chunks {
+		totalSize += len(chunk.Data)
+	}
+
+	fileData := make([]byte, 0, totalSize)
+	for _, chunk := range chunks {
+		fileData = append(fileData, chunk.Data...)
+	}
+
+	return fileData, nil
+}
+
+// collectChunks собирает куски файла с серверов хранения в порядке их описаний
+func (s *StreamingAPIServer) collectChunks(ctx context.Context, chunkMetas []chunking.FileChunk) ([]chunking.FileChunk, error) {
+	settings := s.current()
+	chunks := make([]chunking.FileChunk, len(chunkMetas))
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(chunkMetas))
+
+	for i, chunkMeta := range chunkMetas {
+		wg.Add(1)
+		go func(chunkIndex int, chunkMetadata chunking.FileChunk) {
+			defer wg.Done()
+
+			chunk, err := s.readChunk(ctx, settings, chunkMetadata)
+			if err != nil {
+				errChan <- err
+				return
+			}
+
+			chunks[chunkIndex] = *chunk
+		}(i, chunkMeta)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// Проверяем ошибки
+	for err := range errChan {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// readChunk получает кусок из кэша API сервера или с серверов хранения. Если уровень
+// согласованности в контексте требует больше одной копии, кэш не используется
+func (s *StreamingAPIServer) readChunk(ctx context.Context, settings *runtimeSettings, chunkMeta chunking.FileChunk) (*chunking.FileChunk, error) {
+	if level := contextReadConsistency(ctx); level != consistencyOne {
+		return s.readChunkConsistent(ctx, settings, chunkMeta, level)
+	}
+	if s.chunkCache == nil {
+		return s.readChunkFromNodes(ctx, settings, chunkMeta)
+	}
+	return s.chunkCache.get(ctx, chunkMeta.ID, func(ctx context.Context) (*chunking.FileChunk, error) {
+		return s.readChunkFromNodes(ctx, settings, chunkMeta)
+	})
+}
+
+// readChunkFromNodes получает кусок с основного сервера хранения, а если он недоступен
+// или кусок на нем поврежден - с серверов с копиями. С region серверы региона API
+// сервера опрашиваются первыми
+func (s *StreamingAPIServer) readChunkFromNodes(ctx context.Context, settings *runtimeSettings, chunkMeta chunking.FileChunk) (*chunking.FileChunk, error) {
+	nodes := settings.preferRegion(chunkMeta.Nodes(), settings.config.Region)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("в метаданных куска %s не указан сервер хранения", chunkMeta.ID)
+	}
+
+	// Поврежденный кусок не запрашивается повторно с того же сервера: он снова отдаст те же
+	// данные, поэтому кусок читается со следующей копии
+	var lastErr error
+	for _, node := range nodes {
+		chunk, err := settings.clientForNode(node).GetChunkContext(ctx, chunkMeta.ID)
+		if err == nil {
+			return chunk, nil
+		}
+		lastErr = fmt.Errorf("не удалось получить кусок %d с сервера %s: %w", chunkMeta.Index, node, err)
+		if ctx.Err() != nil {
+			break
+		}
+		if len(nodes) > 1 {
+			log.Printf("%v", lastErr)
+		}
+	}
+	return nil, lastErr
+}
+
+// getFileInfo возвращает информацию о файле вместе со статистикой обращений
+func (s *StreamingAPIServer) getFileInfo(c *gin.Context) {
+	metadata, ok := s.loadFile(c, accessRead)
+	if !ok {
+		return
+	}
+
+	writeMetadata(c, http.StatusOK, s.access.merged(metadata))
+}
+
+// deleteFile удаляет файл
+func (s *StreamingAPIServer) deleteFile(c *gin.Context) {
+	metadata, ok := s.loadFile(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	if err := s.removeFile(c.Request.Context(), metadata.ID); err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Файл удален"})
+}
+
+// deleteChunks удаляет куски файла и их копии с серверов хранения.
+// Метаданные к этому моменту уже удалены, поэтому отмена запроса не прерывает удаление кусков
+func (s *StreamingAPIServer) deleteChunks(ctx context.Context, metadata *chunking.FileMetadata) {
+	ctx = context.WithoutCancel(ctx)
+	settings := s.current()
+
+	var wg sync.WaitGroup
+	for i, chunk := range metadata.Chunks {
+		for _, node := range chunk.Nodes() {
+			wg.Add(1)
+			go func(chunkIndex int, chunkID, node string) {
+				defer wg.Done()
+
+				if err := settings.clientForNode(node).DeleteChunkContext(ctx, chunkID); err != nil {
+					log.Printf("Не удалось удалить кусок %d с сервера %s: %v", chunkIndex, node, err)
+				}
+			}(i, chunk.ID, node)
+		}
+	}
+
+	wg.Wait()
+}
+
+// listFiles возвращает список всех файлов.
+// Параметр prefix ограничивает список файлами, путь которых начинается с указанного префикса,
+// а accessed_before, accessed_after и max_downloads - файлами по статистике обращений
+// (например, не скачивавшимися 90 дней)
+func (s *StreamingAPIServer) listFiles(c *gin.Context) {
+	filter, filterErr := parseAccessFilter(c)
+	if filterErr != nil {
+		writeRequestError(c, filterErr)
+		return
+	}
+
+	var all []*chunking.FileMetadata
+	var err error
+	if prefix, ok := c.GetQuery("prefix"); ok {
+		all, err = s.filesWithPathPrefix(c.Request.Context(), prefix)
+	} else {
+		all, err = s.catalog.List(c.Request.Context())
+	}
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+
+	settings := s.current()
+	principal := c.GetString(principalKey)
+	dropBox := c.Query("drop_box")
+	files := make([]string, 0, len(all))
+	for _, metadata := range all {
+		if dropBox != "" && metadata.DropBox != dropBox {
+			continue
+		}
+		if settings.canAccess(principal, metadata, accessRead) && filter.matches(s.access.merged(metadata)) {
+			files = append(files, metadata.ID)
+		}
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+// findFileByPath ищет файл по логическому пути; если файла нет, возвращает catalog.ErrNotFound
+func (s *StreamingAPIServer) findFileByPath(ctx context.Context, filePath string) (*chunking.FileMetadata, error) {
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, metadata := range files {
+		if metadata.Path == filePath {
+			return metadata, nil
+		}
+	}
+	return nil, catalog.ErrNotFound
+}
+
+// filesWithPathPrefix возвращает файлы, путь которых начинается с префикса, отсортированные по пути
+func (s *StreamingAPIServer) filesWithPathPrefix(ctx context.Context, prefix string) ([]*chunking.FileMetadata, error) {
+	all, err := s.catalog.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*chunking.FileMetadata, 0)
+	for _, metadata := range all {
+		if metadata.Path != "" && strings.HasPrefix(metadata.Path, prefix) {
+			files = append(files, metadata)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// removeFile удаляет метаданные файла, его куски и манифесты; если файла нет, возвращает catalog.ErrNotFound
+func (s *StreamingAPIServer) removeFile(ctx context.Context, fileID string) error {
+	metadata, err := s.catalog.Delete(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	s.deleteChunks(ctx, metadata)
+	s.deleteManifests(ctx, metadata)
+	s.events.Publish(events.NewFileEvent(events.FileDeleted, metadata))
+	recordAuditFile(ctx, metadata)
+	return nil
+}
+
+// replaceFile сохраняет новую версию файла по пути info.Path, если текущая версия
+// удовлетворяет условию, и удаляет текущую версию. Заменить файл может только клиент с
+// правом записи, а новая версия сохраняет владельца и права доступа текущей; новый файл
+// принадлежит клиенту из контекста. Вызывающий держит блокировку пути
+func (s *StreamingAPIServer) replaceFile(ctx context.Context, info uploadInfo, fileData []byte, condition writeCondition) (*chunking.FileMetadata, error) {
+	previous, err := s.findFileByPath(ctx, info.Path)
+	if err != nil && !errors.Is(err, catalog.ErrNotFound) {
+		return nil, fmt.Errorf("не удалось прочитать каталог: %w", err)
+	}
+	info.Owner = contextPrincipal(ctx)
+	if previous != nil {
+		if !s.current().canAccess(info.Owner, previous, accessWrite) {
+			return nil, errAccessDenied
+		}
+		info.Owner = previous.Owner
+		info.Grants = previous.Grants
+	}
+	if err := condition.check(previous); err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.storeFile(ctx, info, fileData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Старая версия удаляется только после успешного сохранения новой
+	if previous != nil {
+		if err := s.removeFile(ctx, previous.ID); err != nil && !errors.Is(err, catalog.ErrNotFound) {
+			log.Printf("Не удалось удалить предыдущую версию файла %s: %v", info.Path, err)
+		}
+	}
+	return metadata, nil
+}
+
+// Handler возвращает обработчик запросов API, например чтобы подключить его к своему
+// HTTP серверу или к httptest.Server. Режим Gin (gin.SetMode) задается до вызова
+func (s *StreamingAPIServer) Handler() http.Handler {
+	router := s.setupStreamingRoutes()
+	// h2c принимается наряду с HTTP/1.1, поэтому старые клиенты продолжают работать
+	router.UseH2C = s.current().config.HTTP2Cleartext
+	return router.Handler()
+}
+
+// Start начинает принимать запросы на адресе из конфигурации (listen или api_host и
+// api_port) и возвращает запущенный HTTP сервер. Запросы выполняются с контекстом ctx,
+// поэтому его отмена прерывает их. Сервер останавливается вызовом Shutdown
+func (s *StreamingAPIServer) Start(ctx context.Context) (*http.Server, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.httpServer != nil {
+		return nil, errors.New("сервер уже запущен")
+	}
+
+	cfg := s.current().config
+	address := cfg.GetAPIAddress()
+	if cfg.Listen != "" {
+		address = cfg.Listen
+	}
+	listener, err := listen.Listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var grpcListener net.Listener
+	if cfg.GRPCListen != "" {
+		grpcServer, err := s.NewGRPCServer()
+		if err == nil {
+			grpcListener, err = listen.Listen(cfg.GRPCListen)
+		}
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("не удалось запустить gRPC API: %w", err)
+		}
+		s.grpcServer = grpcServer
+	}
+	log.Printf("Запуск потокового API сервера на адресе %s", address)
+
+	router := s.setupStreamingRoutes()
+	router.UseH2C = cfg.HTTP2Cleartext
+
+	httpServer := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           router.Handler(),
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	// Shutdown не ждет завершения потоков событий, поэтому закрываем их сами
+	httpServer.RegisterOnShutdown(s.beginShutdown)
+	s.httpServer = httpServer
+
+	go func() {
+		var err error
+		if cfg.TLSCertFile != "" {
+			err = httpServer.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("API сервер остановлен: %v", err)
+		}
+	}()
+
+	if cfg.HTTP3Enabled {
+		s.http3 = newHTTP3Server(cfg, router)
+		log.Printf("Запуск экспериментального HTTP/3 сервера на UDP адресе %s", address)
+		go func() {
+			if err := s.http3.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil && err != http.ErrServerClosed {
+				log.Printf("HTTP/3 сервер остановлен: %v", err)
+			}
+		}()
+	}
+
+	if s.grpcServer != nil {
+		log.Printf("Запуск gRPC API на адресе %s", cfg.GRPCListen)
+		go func() {
+			if err := s.grpcServer.Serve(grpcListener); err != nil {
+				log.Printf("gRPC сервер остановлен: %v", err)
+			}
+		}()
+	}
+
+	return httpServer, nil
+}
+
+// Shutdown дожидается завершения текущих запросов, пока не истечет ctx, и останавливает
+// сервер вместе с фоновыми подсистемами. Для сервера, не запущенного через Start,
+// останавливаются только фоновые подсистемы
+func (s *StreamingAPIServer) Shutdown(ctx context.Context) error {
+	var errs []error
+	if s.httpServer != nil {
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("ошибка при остановке сервера: %w", err))
+		}
+	}
+	if s.http3 != nil {
+		if err := s.http3.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("ошибка при остановке HTTP/3 сервера: %w", err))
+		}
+	}
+	if s.grpcServer != nil {
+		// GracefulStop ждет завершения потоков без ограничения времени, поэтому по
+		// истечении ctx оставшиеся потоки обрываются
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpcServer.Stop()
+			errs = append(errs, fmt.Errorf("ошибка при остановке gRPC сервера: %w", ctx.Err()))
+		}
+	}
+	if err := s.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("ошибка при остановке фоновых подсистем: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+	"TestCase/pkg/storageserver"
+)
+
+func TestEmbeddedServers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	storageCfg := config.Defaults()
+	storageCfg.StoragePort = "0"
+	storageServer, err := storageserver.NewMemoryStorageServer(storageCfg, "1")
+	require.NoError(t, err)
+	storageHTTP, err := storageServer.Start(context.Background())
+	require.NoError(t, err)
+
+	cfg := config.Defaults()
+	cfg.APIHost = "127.0.0.1"
+	cfg.APIPort = "0"
+	cfg.StorageServers = []string{storageHTTP.Addr}
+	cfg.ChunkCount = 1
+	cfg.AuditSinks = nil
+	cfg.CapacityRefreshInterval = 0
+	server, err := NewStreamingAPIServer(cfg)
+	require.NoError(t, err)
+	apiHTTP, err := server.Start(context.Background())
+	require.NoError(t, err)
+
+	_, err = server.Start(context.Background())
+	assert.Error(t, err, "повторный запуск")
+
+	api := client.NewAPIClient("http://" + apiHTTP.Addr)
+	metadata, err := api.UploadReader(context.Background(), "hello.txt", strings.NewReader("hello"), 5)
+	require.NoError(t, err)
+
+	var downloaded bytes.Buffer
+	body, err := api.OpenDownload(context.Background(), metadata.ID)
+	require.NoError(t, err)
+	_, err = downloaded.ReadFrom(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", downloaded.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	require.NoError(t, storageServer.Shutdown(ctx))
+
+	_, err = api.GetFileInfoContext(ctx, metadata.ID)
+	assert.Error(t, err, "сервер остановлен")
+}
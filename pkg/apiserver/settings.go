@@ -0,0 +1,314 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+	"TestCase/pkg/discovery"
+	"TestCase/pkg/storage"
+)
+
+// errNoStorageServers возвращается, если не известно ни одного сервера хранения
+var errNoStorageServers = errors.New("нет доступных серверов хранения")
+
+// reloadableKeys - параметры конфигурации, которые применяются без перезапуска по SIGHUP
+var reloadableKeys = map[string]bool{
+	"storage_servers":    true,
+	"max_file_size":      true,
+	"chunk_count":        true,
+	"checksum_algorithm": true,
+	"replication_factor": true,
+	"write_quorum":       true,
+	"storage_classes":    true,
+	"read_consistency":   true,
+	"region":             true,
+	"storage_regions":    true,
+	"file_id_scheme":     true,
+
+	"small_file_threshold": true,
+	"target_chunk_size":    true,
+	"min_chunk_count":      true,
+	"max_chunk_count":      true,
+
+	"rebalance_max_bytes_per_second": true,
+	"rebalance_max_concurrent_moves": true,
+
+	"allowed_content_types":       true,
+	"allowed_extensions":          true,
+	"blocked_extensions":          true,
+	"max_filename_length":         true,
+	"forbidden_filename_patterns": true,
+
+	"api_keys":   true,
+	"api_admins": true,
+
+	"fetch_allowed_networks": true,
+	"fetch_denied_networks":  true,
+
+	"cache_control":        true,
+	"public_cache_control": true,
+	"inline_content_types": true,
+}
+
+// runtimeSettings - неизменяемый снимок параметров, которые можно изменить на лету
+type runtimeSettings struct {
+	config         *config.Config
+	storageClients []*storage.StorageClient // в порядке config.StorageServers
+	hashAlgorithm  chunking.HashAlgorithm
+	fileIDScheme   catalog.IDScheme
+
+	filenamePatterns []*regexp.Regexp // скомпилированные forbidden_filename_patterns
+
+	apiKeys []apiKey // разобранные api_keys
+
+	regions map[string]string // сервер хранения -> регион из storage_regions
+
+	storageClasses map[string]int // класс хранения -> число копий из storage_classes
+
+	fetchAllowedNetworks []*net.IPNet // разобранные fetch_allowed_networks
+	fetchDeniedNetworks  []*net.IPNet // разобранные fetch_denied_networks
+}
+
+// newRuntimeSettings проверяет конфигурацию и создает клиенты серверов хранения.
+// Клиенты серверов, уже известных в previous, используются повторно
+func newRuntimeSettings(cfg *config.Config, previous *runtimeSettings) (*runtimeSettings, error) {
+	hashAlgorithm, err := chunking.ParseHashAlgorithm(cfg.ChecksumAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	fileIDScheme, err := catalog.ParseIDScheme(cfg.FileIDScheme)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &runtimeSettings{config: cfg, hashAlgorithm: hashAlgorithm, fileIDScheme: fileIDScheme}
+	for _, pattern := range cfg.ForbiddenFilenamePatterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("неверное выражение forbidden_filename_patterns %q: %w", pattern, err)
+		}
+		settings.filenamePatterns = append(settings.filenamePatterns, compiled)
+	}
+	for _, entry := range cfg.APIKeys {
+		name, key, _ := strings.Cut(entry, ":")
+		settings.apiKeys = append(settings.apiKeys, apiKey{name: name, key: []byte(key), admin: slices.Contains(cfg.APIAdmins, name)})
+	}
+	settings.regions = make(map[string]string, len(cfg.StorageRegions))
+	for _, entry := range cfg.StorageRegions {
+		node, region, _ := strings.Cut(entry, "=")
+		settings.regions[node] = region
+	}
+	settings.storageClasses = make(map[string]int, len(cfg.StorageClasses))
+	for _, entry := range cfg.StorageClasses {
+		name, copies, _ := strings.Cut(entry, "=")
+		settings.storageClasses[name], _ = strconv.Atoi(copies)
+	}
+	if settings.fetchAllowedNetworks, err = parseNetworks(cfg.FetchAllowedNetworks); err != nil {
+		return nil, fmt.Errorf("неверная сеть fetch_allowed_networks: %w", err)
+	}
+	if settings.fetchDeniedNetworks, err = parseNetworks(cfg.FetchDeniedNetworks); err != nil {
+		return nil, fmt.Errorf("неверная сеть fetch_denied_networks: %w", err)
+	}
+	for _, serverAddr := range cfg.StorageServers {
+		client := previous.findClient(serverAddr)
+		if client == nil {
+			client = newStorageClient(cfg, serverAddr)
+		}
+		settings.storageClients = append(settings.storageClients, client)
+	}
+	return settings, nil
+}
+
+// parseNetworks разбирает список сетей в формате CIDR
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// findClient возвращает клиент сервера хранения по адресу или nil
+func (r *runtimeSettings) findClient(node string) *storage.StorageClient {
+	if r == nil {
+		return nil
+	}
+	for i, serverAddr := range r.config.StorageServers {
+		if serverAddr == node {
+			return r.storageClients[i]
+		}
+	}
+	return nil
+}
+
+// clientForNode возвращает клиент сервера хранения по адресу. Серверы куска
+// записываются в метаданные при загрузке и не зависят от порядка и числа серверов
+// в текущем составе. Сервер мог выйти из состава после загрузки файла, тогда к
+// нему обращаемся напрямую
+func (r *runtimeSettings) clientForNode(node string) *storage.StorageClient {
+	if client := r.findClient(node); client != nil {
+		return client
+	}
+	return newStorageClient(r.config, node)
+}
+
+// preferRegion упорядочивает серверы с копиями куска: сначала серверы региона region, затем
+// остальные. Внутри каждой группы порядок из метаданных сохраняется, поэтому основной
+// сервер куска остается первым в своей группе. Пустой region не меняет порядок
+func (r *runtimeSettings) preferRegion(nodes []string, region string) []string {
+	if region == "" || len(r.regions) == 0 {
+		return nodes
+	}
+
+	ordered := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if r.regions[node] == region {
+			ordered = append(ordered, node)
+		}
+	}
+	for _, node := range nodes {
+		if r.regions[node] != region {
+			ordered = append(ordered, node)
+		}
+	}
+	return ordered
+}
+
+// newStorageClient создает клиент сервера хранения по адресу host:port или
+// unix:///path. При http2_cleartext запросы ко всем серверам идут по HTTP/2 без TLS,
+// а с internal_secret подписываются общим ключом
+func newStorageClient(cfg *config.Config, node string) *storage.StorageClient {
+	client := storage.NewStorageClient(storage.NodeURL(node))
+	if cfg.HTTP2Cleartext {
+		client = storage.NewH2CStorageClient(storage.NodeURL(node))
+	}
+	client.Secret = cfg.InternalSecret
+	return client
+}
+
+// current возвращает действующие параметры сервера
+func (s *StreamingAPIServer) current() *runtimeSettings {
+	return s.settings.Load()
+}
+
+// Reload применяет перечитанную конфигурацию. Запросы, начатые до вызова,
+// дорабатывают со старым снимком параметров. Изменения параметров, требующих
+// перезапуска, только записываются в лог и не применяются
+func (s *StreamingAPIServer) Reload(cfg *config.Config) error {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	previous := s.current()
+
+	// Берем действующую конфигурацию и переносим в нее только перечитываемые параметры
+	applied := *previous.config
+	applied.MaxFileSize = cfg.MaxFileSize
+	applied.ChunkCount = cfg.ChunkCount
+	applied.SmallFileThreshold = cfg.SmallFileThreshold
+	applied.TargetChunkSize = cfg.TargetChunkSize
+	applied.MinChunkCount = cfg.MinChunkCount
+	applied.MaxChunkCount = cfg.MaxChunkCount
+	applied.ChecksumAlgorithm = cfg.ChecksumAlgorithm
+	applied.ReplicationFactor = cfg.ReplicationFactor
+	applied.WriteQuorum = cfg.WriteQuorum
+	applied.StorageClasses = cfg.StorageClasses
+	applied.ReadConsistency = cfg.ReadConsistency
+	applied.RebalanceBytesPerSecond = cfg.RebalanceBytesPerSecond
+	applied.RebalanceConcurrency = cfg.RebalanceConcurrency
+	applied.AllowedContentTypes = cfg.AllowedContentTypes
+	applied.AllowedExtensions = cfg.AllowedExtensions
+	applied.BlockedExtensions = cfg.BlockedExtensions
+	applied.MaxFilenameLength = cfg.MaxFilenameLength
+	applied.ForbiddenFilenamePatterns = cfg.ForbiddenFilenamePatterns
+	applied.APIKeys = cfg.APIKeys
+	applied.FetchAllowedNetworks = cfg.FetchAllowedNetworks
+	applied.FetchDeniedNetworks = cfg.FetchDeniedNetworks
+	// При обнаружении через реестр составом серверов управляет реестр
+	if s.discovery == nil {
+		applied.StorageServers = cfg.StorageServers
+	}
+
+	settings, err := newRuntimeSettings(&applied, previous)
+	if err != nil {
+		return err
+	}
+
+	var changed, ignored []string
+	for _, key := range previous.config.Diff(cfg) {
+		if key == "storage_servers" && s.discovery != nil {
+			continue
+		}
+		if reloadableKeys[key] {
+			changed = append(changed, key)
+		} else {
+			ignored = append(ignored, key)
+		}
+	}
+
+	if len(ignored) > 0 {
+		log.Printf("Изменения параметров %s вступят в силу только после перезапуска", strings.Join(ignored, ", "))
+	}
+	if len(changed) == 0 {
+		log.Printf("Конфигурация перечитана, изменений нет")
+		return nil
+	}
+
+	s.settings.Store(settings)
+	log.Printf("Конфигурация перечитана, применены параметры: %s", strings.Join(changed, ", "))
+	return nil
+}
+
+// startDiscovery запускает наблюдение за составом серверов хранения в реестре
+func (s *StreamingAPIServer) startDiscovery(cfg *config.Config) error {
+	provider, err := discovery.New(discovery.Config{
+		Backend:  cfg.DiscoveryBackend,
+		Endpoint: cfg.DiscoveryEndpoint,
+		Service:  cfg.DiscoveryService,
+		Prefix:   cfg.DiscoveryPrefix,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.discovery = cancel
+	s.discoveryStopped = make(chan struct{})
+
+	go func() {
+		defer close(s.discoveryStopped)
+		provider.Watch(ctx, s.setStorageServers)
+	}()
+	return nil
+}
+
+// setStorageServers заменяет состав серверов хранения. Уже загруженные куски
+// остаются на своих серверах, новые размещаются по обновленному списку
+func (s *StreamingAPIServer) setStorageServers(servers []string) {
+	s.settingsMutex.Lock()
+	defer s.settingsMutex.Unlock()
+
+	previous := s.current()
+	applied := *previous.config
+	applied.StorageServers = servers
+
+	// Алгоритм не меняется, поэтому ошибка невозможна
+	settings, _ := newRuntimeSettings(&applied, previous)
+	s.settings.Store(settings)
+
+	log.Printf("Состав серверов хранения обновлен (%d): %s", len(servers), strings.Join(servers, ", "))
+}
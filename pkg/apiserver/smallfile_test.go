@@ -0,0 +1,43 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/config"
+)
+
+func TestSmallFileSingleChunk(t *testing.T) {
+	ctx := context.Background()
+	api := newTestServer(t, withStorageNodes(3), withConfig(func(cfg *config.Config) {
+		cfg.ChunkCount = 3
+		cfg.SmallFileThreshold = 1024
+	})).api
+
+	for _, test := range []struct {
+		size   int
+		chunks int
+	}{
+		{size: 1023, chunks: 1},
+		{size: 1024, chunks: 3},
+		{size: 4096, chunks: 3},
+	} {
+		content := strings.Repeat("x", test.size)
+		metadata, err := api.UploadReader(ctx, "file.txt", strings.NewReader(content), int64(len(content)))
+		require.NoError(t, err)
+		assert.Len(t, metadata.Chunks, test.chunks, "размер %d", test.size)
+		assert.Equal(t, test.chunks, metadata.ChunkCount)
+
+		body, err := api.OpenDownload(ctx, metadata.ID)
+		require.NoError(t, err)
+		downloaded, err := io.ReadAll(body)
+		body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, content, string(downloaded))
+	}
+}
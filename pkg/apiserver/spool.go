@@ -0,0 +1,123 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errUploadSpool - не удалось записать загружаемые данные во временный файл
+var errUploadSpool = errors.New("не удалось сохранить загружаемые данные во временный файл")
+
+// uploadSpool накапливает загружаемый файл: пока он не больше upload_memory_limit, данные
+// хранятся в памяти, а большие файлы целиком записываются во временный файл в upload_dir
+// и отображаются в память, поэтому не занимают память процесса. Временный файл удаляется
+// в Close, как бы ни завершилась загрузка
+type uploadSpool struct {
+	dir         string
+	memoryLimit int64 // 0 - всегда в памяти
+
+	memory []byte
+	file   *os.File // nil, пока данные в памяти
+	size   int64
+	unmap  func() error
+}
+
+// newUploadSpool создает буфер загрузки по upload_dir и upload_memory_limit; его нужно закрыть
+func (s *StreamingAPIServer) newUploadSpool() *uploadSpool {
+	cfg := s.current().config
+	return &uploadSpool{dir: cfg.UploadDir, memoryLimit: cfg.UploadMemoryLimit}
+}
+
+// Write дописывает данные, перенося их во временный файл при превышении лимита памяти
+func (u *uploadSpool) Write(p []byte) (int, error) {
+	if u.file == nil && (u.memoryLimit == 0 || u.size+int64(len(p)) <= u.memoryLimit) {
+		u.memory = append(u.memory, p...)
+		u.size += int64(len(p))
+		return len(p), nil
+	}
+	if u.file == nil {
+		if err := u.spill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := u.file.Write(p)
+	u.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("%w: %w", errUploadSpool, err)
+	}
+	return n, nil
+}
+
+// spill создает временный файл и переносит в него накопленные в памяти данные
+func (u *uploadSpool) spill() error {
+	if u.dir != "" {
+		if err := os.MkdirAll(u.dir, 0o755); err != nil {
+			return fmt.Errorf("%w: %w", errUploadSpool, err)
+		}
+	}
+	file, err := os.CreateTemp(u.dir, "upload-*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUploadSpool, err)
+	}
+	u.file = file
+	if _, err := file.Write(u.memory); err != nil {
+		return fmt.Errorf("%w: %w", errUploadSpool, err)
+	}
+	u.memory = nil
+	return nil
+}
+
+// readAll читает r до конца и возвращает все загруженные данные. Данные из временного
+// файла действительны до Close. Ошибки временного файла оборачивают errUploadSpool,
+// ошибки чтения r возвращаются как есть
+func (u *uploadSpool) readAll(r io.Reader) ([]byte, error) {
+	if _, err := io.Copy(u, r); err != nil {
+		return nil, err
+	}
+	return u.bytes()
+}
+
+// bytes возвращает накопленные данные
+func (u *uploadSpool) bytes() ([]byte, error) {
+	if u.file == nil {
+		if u.memory == nil {
+			return []byte{}, nil
+		}
+		return u.memory, nil
+	}
+	if u.unmap != nil {
+		return nil, fmt.Errorf("%w: данные уже отображены в память", errUploadSpool)
+	}
+
+	data, unmap, err := mapFile(u.file, u.size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errUploadSpool, err)
+	}
+	u.unmap = unmap
+	return data, nil
+}
+
+// spilled сообщает, что данные записаны во временный файл
+func (u *uploadSpool) spilled() bool {
+	return u.file != nil
+}
+
+// Close освобождает данные и удаляет временный файл
+func (u *uploadSpool) Close() error {
+	u.memory = nil
+	if u.file == nil {
+		return nil
+	}
+
+	var errs []error
+	if u.unmap != nil {
+		errs = append(errs, u.unmap())
+		u.unmap = nil
+	}
+	errs = append(errs, u.file.Close(), os.Remove(u.file.Name()))
+	u.file = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package apiserver
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile читает первые size байт файла в память: на платформах без mmap временный файл
+// только ограничивает память, занятую во время приема данных
+func mapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(file, 0, size), data); err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
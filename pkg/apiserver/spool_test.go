@@ -0,0 +1,102 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+)
+
+func TestUploadSpool(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+
+	// Данные в пределах лимита остаются в памяти
+	spool := &uploadSpool{dir: dir, memoryLimit: 16}
+	data, err := spool.readAll(strings.NewReader("small"))
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(data))
+	assert.False(t, spool.spilled())
+	require.NoError(t, spool.Close())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	// Большие данные целиком переносятся во временный файл, который удаляет Close
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	spool = &uploadSpool{dir: dir, memoryLimit: 16}
+	data, err = spool.readAll(bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.True(t, spool.spilled())
+	assert.Equal(t, content, data)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Изменение данных не попадает во временный файл
+	data[0] = 'x'
+	stored, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+
+	require.NoError(t, spool.Close())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Без лимита все данные хранятся в памяти
+	spool = &uploadSpool{dir: dir}
+	data, err = spool.readAll(bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.False(t, spool.spilled())
+	assert.Equal(t, content, data)
+	require.NoError(t, spool.Close())
+}
+
+func TestUploadSpoolFailure(t *testing.T) {
+	// Каталог временных файлов нельзя создать: на его месте обычный файл
+	dir := filepath.Join(t.TempDir(), "uploads")
+	require.NoError(t, os.WriteFile(dir, nil, 0o644))
+
+	spool := &uploadSpool{dir: dir, memoryLimit: 4}
+	_, err := spool.readAll(strings.NewReader("too large"))
+	assert.ErrorIs(t, err, errUploadSpool)
+	assert.NoError(t, spool.Close())
+}
+
+func TestUploadSpilledToDisk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	uploadDir := filepath.Join(t.TempDir(), "uploads")
+	baseURL := newSingleNodeTestServer(t, func(cfg *config.Config) {
+		cfg.UploadDir = uploadDir
+		cfg.UploadMemoryLimit = 1024
+	})
+	api := client.NewAPIClient(baseURL)
+	api.SetAPIKey("alice-key")
+
+	ctx := context.Background()
+	content := strings.Repeat("spilled upload ", 1000)
+	metadata, err := api.UploadReader(ctx, "large.txt", strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), metadata.Size)
+
+	body, err := api.OpenDownload(ctx, metadata.ID)
+	require.NoError(t, err)
+	downloaded, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, string(downloaded))
+
+	// Временный файл удален после сохранения
+	entries, err := os.ReadDir(uploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
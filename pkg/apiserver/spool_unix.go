@@ -0,0 +1,21 @@
+//go:build unix
+
+package apiserver
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile отображает первые size байт файла в память. Страницы копируются при записи,
+// поэтому изменения данных не попадают в файл
+func mapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	if size == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
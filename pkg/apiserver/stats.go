@@ -0,0 +1,158 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/storage"
+)
+
+// statsNodeTimeout ограничивает ожидание ответа сервера хранения при сборе статистики
+const statsNodeTimeout = 5 * time.Second
+
+// clusterStats - сводная статистика хранилища
+type clusterStats struct {
+	Files int `json:"files"`
+
+	// Куски: ссылки из файлов, уникальные куски после дедупликации и их копии на серверах
+	ChunkReferences int `json:"chunk_references"`
+	UniqueChunks    int `json:"unique_chunks"`
+	ChunkCopies     int `json:"chunk_copies"`
+
+	// LogicalBytes - сумма размеров файлов, UniqueBytes - объем уникальных кусков,
+	// PhysicalBytes - объем всех копий кусков на серверах хранения
+	LogicalBytes  int64   `json:"logical_bytes"`
+	UniqueBytes   int64   `json:"unique_bytes"`
+	PhysicalBytes int64   `json:"physical_bytes"`
+	DedupRatio    float64 `json:"dedup_ratio"` // logical_bytes / unique_bytes; 0, если данных нет
+
+	Nodes        []nodeStats                  `json:"nodes"`
+	ContentTypes map[string]*contentTypeStats `json:"content_types"`
+
+	ChunkCache *chunkCacheStats         `json:"chunk_cache,omitempty"` // кэш кусков этого API сервера; нет, если кэш отключен
+	Downloads  *downloadCoalescingStats `json:"downloads"`             // объединение скачиваний на этом API сервере
+}
+
+// nodeStats - использование сервера хранения
+type nodeStats struct {
+	Node  string `json:"node"`
+	State string `json:"state"`
+
+	// Chunks и ChunkBytes - копии кусков на сервере по данным каталога
+	Chunks     int   `json:"chunks"`
+	ChunkBytes int64 `json:"chunk_bytes"`
+
+	// Занятое и свободное место по данным самого сервера; nil, если сервер не ответил
+	UsedBytes   *int64   `json:"used_bytes,omitempty"`
+	FreeBytes   *int64   `json:"free_bytes,omitempty"`
+	Utilization *float64 `json:"utilization,omitempty"` // доля занятого места от used_bytes + free_bytes
+	Error       string   `json:"error,omitempty"`
+}
+
+// contentTypeStats - файлы одного типа содержимого
+type contentTypeStats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// getStats возвращает сводную статистику по каталогу и всем серверам хранения
+func (s *StreamingAPIServer) getStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	settings := s.current()
+
+	files, err := s.catalog.List(ctx)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.CatalogReadFailed)
+		return
+	}
+
+	stats := clusterStats{
+		Files:        len(files),
+		Nodes:        make([]nodeStats, len(settings.config.StorageServers)),
+		ContentTypes: make(map[string]*contentTypeStats),
+	}
+	index := make(map[string]int, len(settings.config.StorageServers))
+	for i, server := range settings.config.StorageServers {
+		index[server] = i
+		stats.Nodes[i] = nodeStats{Node: server, State: s.nodes.snapshot(server).State}
+	}
+
+	seen := make(map[string]bool)
+	for _, metadata := range files {
+		stats.LogicalBytes += metadata.Size
+		stats.ChunkReferences += len(metadata.Chunks)
+
+		contentType := metadata.DetectedContentType
+		if contentType == "" {
+			contentType = metadata.ContentType
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		byType := stats.ContentTypes[contentType]
+		if byType == nil {
+			byType = &contentTypeStats{}
+			stats.ContentTypes[contentType] = byType
+		}
+		byType.Files++
+		byType.Bytes += metadata.Size
+
+		// Кусок, на который ссылаются несколько файлов, хранится один раз
+		for _, chunk := range metadata.Chunks {
+			if seen[chunk.ID] {
+				continue
+			}
+			seen[chunk.ID] = true
+
+			nodes := chunk.Nodes()
+			stats.UniqueChunks++
+			stats.UniqueBytes += chunk.Size
+			stats.ChunkCopies += len(nodes)
+			stats.PhysicalBytes += chunk.Size * int64(len(nodes))
+			for _, node := range nodes {
+				if i, ok := index[node]; ok {
+					stats.Nodes[i].Chunks++
+					stats.Nodes[i].ChunkBytes += chunk.Size
+				}
+			}
+		}
+	}
+	if stats.UniqueBytes > 0 {
+		stats.DedupRatio = float64(stats.LogicalBytes) / float64(stats.UniqueBytes)
+	}
+	if s.chunkCache != nil {
+		stats.ChunkCache = s.chunkCache.stats()
+	}
+	stats.Downloads = s.downloads.stats()
+
+	// Серверы хранения опрашиваются параллельно, чтобы недоступный сервер не задерживал ответ
+	var wg sync.WaitGroup
+	for i, client := range settings.storageClients {
+		wg.Add(1)
+		go func(node *nodeStats, client *storage.StorageClient) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, statsNodeTimeout)
+			defer cancel()
+			status, err := client.StatusContext(nodeCtx)
+			if err != nil {
+				node.Error = err.Error()
+				return
+			}
+			node.UsedBytes = &status.UsedBytes
+			node.FreeBytes = status.FreeBytes
+			if status.FreeBytes != nil && status.UsedBytes+*status.FreeBytes > 0 {
+				utilization := float64(status.UsedBytes) / float64(status.UsedBytes+*status.FreeBytes)
+				node.Utilization = &utilization
+			}
+		}(&stats.Nodes[i], client)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,127 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+)
+
+// syncOps возвращает действия синхронизации в виде "путь:действие"
+func syncOps(t *testing.T, result *client.SyncResult) []string {
+	ops := make([]string, 0, len(result.Actions))
+	for _, action := range result.Actions {
+		assert.Empty(t, action.Error, action.Path)
+		ops = append(ops, action.Path+":"+string(action.Op))
+	}
+	return ops
+}
+
+func TestSyncDir(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	baseURL := newSingleNodeTestServer(t, nil)
+	api := client.NewAPIClient(baseURL)
+	api.SetAPIKey("alice-key")
+
+	dir := t.TempDir()
+	write := func(name, content string, modTime time.Time) {
+		local := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(local), 0o755))
+		require.NoError(t, os.WriteFile(local, []byte(content), 0o644))
+		require.NoError(t, os.Chtimes(local, modTime, modTime))
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		return string(data)
+	}
+	remoteFiles := func() map[string]string {
+		ids, err := api.ListFilesFiltered(client.ListFilter{Prefix: "sync/"})
+		require.NoError(t, err)
+		files := make(map[string]string)
+		for _, id := range ids {
+			metadata, err := api.GetFileInfo(id)
+			require.NoError(t, err)
+			body, err := api.OpenDownload(ctx, id)
+			require.NoError(t, err)
+			var content strings.Builder
+			_, err = io.Copy(&content, body)
+			body.Close()
+			require.NoError(t, err)
+			files[metadata.Path] = content.String()
+		}
+		return files
+	}
+
+	past := time.Now().Add(-time.Hour)
+	write("a.txt", "alpha", past)
+	write("sub/b.txt", "bravo", past)
+	remote, err := api.UploadReader(ctx, "r.txt", strings.NewReader("remote"), 6, client.WithPath("sync/r.txt"))
+	require.NoError(t, err)
+	_, err = api.UploadReader(ctx, "other.txt", strings.NewReader("other"), 5, client.WithPath("elsewhere/other.txt"))
+	require.NoError(t, err)
+
+	// Пробный запуск ничего не меняет
+	result, err := api.SyncDir(dir, "sync", client.SyncOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:upload", "r.txt:download", "sub/b.txt:upload"}, syncOps(t, result))
+	_, err = os.Stat(filepath.Join(dir, client.SyncStateName))
+	assert.True(t, os.IsNotExist(err))
+
+	// Первый запуск объединяет обе стороны
+	result, err = api.SyncDir(dir, "/sync/", client.SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:upload", "r.txt:download", "sub/b.txt:upload"}, syncOps(t, result))
+	assert.Equal(t, "remote", read("r.txt"))
+	assert.Equal(t, map[string]string{"sync/a.txt": "alpha", "sync/r.txt": "remote", "sync/sub/b.txt": "bravo"}, remoteFiles())
+
+	result, err = api.SyncDir(dir, "sync", client.SyncOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Actions)
+	assert.Equal(t, 3, result.Unchanged)
+
+	// Изменения и удаления переносятся на другую сторону
+	write("a.txt", "alpha v2", time.Now())
+	require.NoError(t, os.Remove(filepath.Join(dir, "sub", "b.txt")))
+	replacement := filepath.Join(t.TempDir(), "r.txt")
+	require.NoError(t, os.WriteFile(replacement, []byte("remote v2"), 0o644))
+	_, err = api.ReplaceFile(remote.ID, replacement, "")
+	require.NoError(t, err)
+	_, err = api.UploadReader(ctx, "new.txt", strings.NewReader("new"), 3, client.WithPath("sync/deep/new.txt"))
+	require.NoError(t, err)
+
+	result, err = api.SyncDir(dir, "sync", client.SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:upload", "deep/new.txt:download", "r.txt:download", "sub/b.txt:delete-remote"}, syncOps(t, result))
+	assert.Equal(t, remote.ID, result.Actions[2].FileID)
+	assert.Equal(t, "remote v2", read("r.txt"))
+	assert.Equal(t, "new", read("deep/new.txt"))
+	assert.Equal(t, map[string]string{"sync/a.txt": "alpha v2", "sync/deep/new.txt": "new", "sync/r.txt": "remote v2"}, remoteFiles())
+
+	// Удаление в хранилище удаляет локальный файл, а изменение с обеих сторон оставляет
+	// более новую версию
+	newID := result.Actions[1].FileID
+	require.NoError(t, api.DeleteFile(newID))
+	write("r.txt", "local edit", time.Now().Add(time.Hour))
+	require.NoError(t, os.WriteFile(replacement, []byte("remote edit"), 0o644))
+	_, err = api.ReplaceFile(remote.ID, replacement, "")
+	require.NoError(t, err)
+
+	result, err = api.SyncDir(dir, "sync", client.SyncOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deep/new.txt:delete-local", "r.txt:upload"}, syncOps(t, result))
+	assert.True(t, result.Actions[1].Conflict)
+	_, err = os.Stat(filepath.Join(dir, "deep", "new.txt"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, map[string]string{"sync/a.txt": "alpha v2", "sync/r.txt": "local edit"}, remoteFiles())
+}
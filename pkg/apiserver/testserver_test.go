@@ -0,0 +1,111 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+	"TestCase/pkg/config"
+	"TestCase/pkg/storageserver"
+)
+
+// testServer - API сервер для тестов вместе с клиентом к нему
+type testServer struct {
+	*StreamingAPIServer
+	api   *client.APIClient
+	url   string   // адрес API сервера
+	nodes []string // адреса серверов хранения
+}
+
+// testServerOptions - настройки newTestServer
+type testServerOptions struct {
+	nodes     int
+	wrapNode  func(id string, handler http.Handler) http.Handler
+	wrapAPI   func(handler http.Handler) http.Handler
+	configure []func(cfg *config.Config)
+}
+
+// testServerOption меняет настройки newTestServer
+type testServerOption func(*testServerOptions)
+
+// withStorageNodes запускает count серверов хранения в памяти с идентификаторами "1", "2", ...
+func withStorageNodes(count int) testServerOption {
+	return func(o *testServerOptions) { o.nodes = count }
+}
+
+// withNodeHandler оборачивает обработчик каждого сервера хранения
+func withNodeHandler(wrap func(id string, handler http.Handler) http.Handler) testServerOption {
+	return func(o *testServerOptions) { o.wrapNode = wrap }
+}
+
+// withAPIHandler оборачивает обработчик API сервера
+func withAPIHandler(wrap func(handler http.Handler) http.Handler) testServerOption {
+	return func(o *testServerOptions) { o.wrapAPI = wrap }
+}
+
+// withConfig меняет конфигурацию API сервера перед его созданием; nil ничего не меняет
+func withConfig(configure func(cfg *config.Config)) testServerOption {
+	return func(o *testServerOptions) { o.configure = append(o.configure, configure) }
+}
+
+// newStorageNodes запускает count серверов хранения в памяти и возвращает их адреса
+func newStorageNodes(t *testing.T, count int, wrap func(id string, handler http.Handler) http.Handler) []string {
+	var nodes []string
+	for i := 1; i <= count; i++ {
+		id := strconv.Itoa(i)
+		storageServer, err := storageserver.NewMemoryStorageServer(config.Defaults(), id)
+		require.NoError(t, err)
+		handler := storageServer.Handler()
+		if wrap != nil {
+			handler = wrap(id, handler)
+		}
+		storageHTTP := httptest.NewServer(handler)
+		t.Cleanup(storageHTTP.Close)
+		nodes = append(nodes, strings.TrimPrefix(storageHTTP.URL, "http://"))
+	}
+	return nodes
+}
+
+// newTestServer создает API сервер и клиент к нему. Без опций серверов хранения нет
+func newTestServer(t *testing.T, opts ...testServerOption) *testServer {
+	gin.SetMode(gin.TestMode)
+
+	var options testServerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	nodes := newStorageNodes(t, options.nodes, options.wrapNode)
+
+	cfg := config.Defaults()
+	cfg.StorageServers = nodes
+	cfg.AuditSinks = nil
+	cfg.CapacityRefreshInterval = 0
+	for _, configure := range options.configure {
+		if configure != nil {
+			configure(cfg)
+		}
+	}
+	server, err := NewStreamingAPIServer(cfg)
+	require.NoError(t, err)
+	handler := server.Handler()
+	if options.wrapAPI != nil {
+		handler = options.wrapAPI(handler)
+	}
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Close()
+	})
+	return &testServer{
+		StreamingAPIServer: server,
+		api:                client.NewAPIClient(httpServer.URL),
+		url:                httpServer.URL,
+		nodes:              nodes,
+	}
+}
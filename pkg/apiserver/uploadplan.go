@@ -0,0 +1,315 @@
+package apiserver
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+	"TestCase/pkg/storage"
+	"TestCase/pkg/token"
+)
+
+// planTokenPurpose - назначение подписанного плана загрузки
+const planTokenPurpose = "upload-plan"
+
+// uploadPlanRequest - запрос плана прямой загрузки
+type uploadPlanRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Path        string `json:"path"`
+	Public      bool   `json:"public"`
+}
+
+// planClaims - данные плана, подписанные API сервером. Клиент возвращает их при
+// завершении загрузки, поэтому сервер не хранит незавершенные загрузки
+type planClaims struct {
+	FileID      string                 `json:"file_id"`
+	Name        string                 `json:"name"`
+	Size        int64                  `json:"size"`
+	ContentType string                 `json:"content_type,omitempty"`
+	Path        string                 `json:"path,omitempty"`
+	Public      bool                   `json:"public,omitempty"`
+	Owner       string                 `json:"owner,omitempty"`
+	Algorithm   chunking.HashAlgorithm `json:"algorithm"`
+	Chunks      []planChunk            `json:"chunks"`
+
+	ReplicationFactor int    `json:"replication_factor,omitempty"` // число копий из политики загрузки
+	StorageClass      string `json:"storage_class,omitempty"`
+}
+
+// planChunk - кусок в подписанном плане
+type planChunk struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+	Size  int64  `json:"size"`
+	Node  string `json:"node"`
+}
+
+// plannedChunk - кусок в ответе на запрос плана
+type plannedChunk struct {
+	ID         string `json:"id"`
+	Index      int    `json:"index"`
+	Offset     int64  `json:"offset"` // смещение куска от начала файла
+	Size       int64  `json:"size"`
+	Node       string `json:"node"`
+	StorageURL string `json:"storage_url"` // кусок загружается запросом PUT {storage_url}/api/v1/chunks/{id}
+	Token      string `json:"token"`       // токен загрузки для заголовка Authorization: Bearer
+}
+
+// uploadPlan - план прямой загрузки файла на серверы хранения
+type uploadPlan struct {
+	FileID            string                 `json:"file_id"`
+	ChecksumAlgorithm chunking.HashAlgorithm `json:"checksum_algorithm"`
+	ExpiresAt         time.Time              `json:"expires_at"`
+	Chunks            []plannedChunk         `json:"chunks"`
+	Plan              string                 `json:"plan"` // подписанный план, передается при завершении загрузки
+}
+
+// uploadCommitRequest - завершение прямой загрузки
+type uploadCommitRequest struct {
+	Plan     string   `json:"plan" binding:"required"`
+	Checksum string   `json:"checksum" binding:"required"` // контрольная сумма всего файла, вычисленная клиентом
+	Receipts []string `json:"receipts"`                    // квитанции серверов хранения по кускам
+}
+
+// directUploadUnavailable возвращает код ошибки, если прямая загрузка невозможна при
+// текущих настройках, и пустой код, если она включена. Проверки содержимого
+// файла выполняются на API сервере, поэтому при них данные должны пройти через него
+func (s *StreamingAPIServer) directUploadUnavailable(settings *runtimeSettings) apierror.Code {
+	switch {
+	case settings.config.UploadTokenSecret == "":
+		return apierror.DirectUploadDisabled
+	case s.scanner != nil:
+		return apierror.DirectUploadScanner
+	case len(settings.config.AllowedContentTypes) > 0:
+		return apierror.DirectUploadTypes
+	}
+	return ""
+}
+
+// createUploadPlan размещает куски будущего файла на серверах хранения и выдает токены
+// для их загрузки напрямую, минуя API сервер
+func (s *StreamingAPIServer) createUploadPlan(c *gin.Context) {
+	settings := s.current()
+	if code := s.directUploadUnavailable(settings); code != "" {
+		writeError(c, http.StatusForbidden, code)
+		return
+	}
+
+	var request uploadPlanRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+	if request.Size < 0 {
+		writeError(c, http.StatusBadRequest, apierror.NegativeSize)
+		return
+	}
+	if request.Size > settings.config.MaxFileSize {
+		fileTooLarge(c, settings.config.MaxFileSize)
+		return
+	}
+	if err := settings.checkFilename(request.Name); err != nil {
+		writeStoreError(c, apierror.StoreFailed, err)
+		return
+	}
+
+	policy, policyErr := s.parseUploadPolicy(c, settings)
+	if policyErr != nil {
+		writeRequestError(c, policyErr)
+		return
+	}
+
+	fileID := settings.fileIDScheme.NewID()
+	chunks := planChunks(fileID, request.Size, s.chunkCount(settings, request.Size, policy), settings.hashAlgorithm)
+	if err := s.placeChunks(settings, chunks, policy.replicationFactor(settings)); err != nil {
+		writeStoreError(c, apierror.PlaceFailed, err)
+		return
+	}
+
+	secret := settings.config.UploadTokenSecret
+	expiresAt := time.Now().Add(settings.config.UploadTokenTTL)
+	claims := planClaims{
+		FileID:      fileID,
+		Name:        request.Name,
+		Size:        request.Size,
+		ContentType: request.ContentType,
+		Path:        cleanFilePath(request.Path),
+		Public:      request.Public,
+		Owner:       c.GetString(principalKey),
+		Algorithm:   settings.hashAlgorithm,
+
+		ReplicationFactor: policy.ReplicationFactor,
+		StorageClass:      policy.StorageClass,
+	}
+	plan := uploadPlan{
+		FileID:            fileID,
+		ChecksumAlgorithm: settings.hashAlgorithm,
+		ExpiresAt:         expiresAt.UTC(),
+	}
+
+	var offset int64
+	for _, chunk := range chunks {
+		uploadToken, err := token.Sign(secret, storage.UploadTokenPurpose, storage.UploadClaims{
+			ChunkID:   chunk.ID,
+			FileID:    fileID,
+			Index:     chunk.Index,
+			Size:      chunk.Size,
+			Algorithm: settings.hashAlgorithm,
+		}, expiresAt)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+			return
+		}
+
+		claims.Chunks = append(claims.Chunks, planChunk{ID: chunk.ID, Index: chunk.Index, Size: chunk.Size, Node: chunk.Node})
+		plan.Chunks = append(plan.Chunks, plannedChunk{
+			ID:         chunk.ID,
+			Index:      chunk.Index,
+			Offset:     offset,
+			Size:       chunk.Size,
+			Node:       chunk.Node,
+			StorageURL: storage.NodeURL(chunk.Node),
+			Token:      uploadToken,
+		})
+		offset += chunk.Size
+	}
+
+	signedPlan, err := token.Sign(secret, planTokenPurpose, claims, expiresAt)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+		return
+	}
+	plan.Plan = signedPlan
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// planChunks делит файл размера size на куски так же, как chunkFileInMemory
+func planChunks(fileID string, size int64, chunkCount int, algorithm chunking.HashAlgorithm) []chunking.FileChunk {
+	chunkSize := size / int64(chunkCount)
+	chunks := make([]chunking.FileChunk, chunkCount)
+	for i := range chunks {
+		chunks[i] = chunking.FileChunk{
+			ID:     chunking.NewChunkID(),
+			FileID: fileID,
+			Index:  i,
+			Size:   chunkSize,
+
+			Algorithm: algorithm,
+		}
+	}
+	// Последний кусок получает все оставшиеся данные
+	chunks[chunkCount-1].Size = size - chunkSize*int64(chunkCount-1)
+	return chunks
+}
+
+// commitUpload завершает прямую загрузку: проверяет подписанный план и квитанции
+// серверов хранения по всем кускам и сохраняет метаданные файла
+func (s *StreamingAPIServer) commitUpload(c *gin.Context) {
+	settings := s.current()
+	secret := settings.config.UploadTokenSecret
+	if secret == "" {
+		writeError(c, http.StatusForbidden, apierror.DirectUploadDisabled)
+		return
+	}
+
+	var request uploadCommitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+
+	var claims planClaims
+	if err := token.Verify(secret, planTokenPurpose, request.Plan, &claims); err != nil {
+		writeError(c, http.StatusUnauthorized, apierror.PlanRejected, err)
+		return
+	}
+	if claims.FileID != c.Param("id") {
+		writeError(c, http.StatusBadRequest, apierror.PlanFileMismatch)
+		return
+	}
+
+	receipts := make(map[string]storage.ChunkReceipt, len(request.Receipts))
+	for _, signed := range request.Receipts {
+		var receipt storage.ChunkReceipt
+		if err := token.Verify(secret, storage.ReceiptTokenPurpose, signed, &receipt); err != nil {
+			writeError(c, http.StatusUnauthorized, apierror.ReceiptRejected, err)
+			return
+		}
+		receipts[receipt.ChunkID] = receipt
+	}
+
+	ctx := c.Request.Context()
+	if _, err := s.catalog.Get(ctx, claims.FileID); err == nil {
+		writeError(c, http.StatusConflict, apierror.UploadAlreadyCommitted)
+		return
+	}
+
+	metadata := &chunking.FileMetadata{
+		ID:           claims.FileID,
+		OriginalName: claims.Name,
+		Size:         claims.Size,
+		Checksum:     request.Checksum,
+		ContentType:  claims.ContentType,
+		ChunkCount:   len(claims.Chunks),
+
+		ChecksumAlgorithm: claims.Algorithm,
+		ReplicationFactor: claims.ReplicationFactor,
+		StorageClass:      claims.StorageClass,
+		Path:              claims.Path,
+		CreatedAt:         time.Now().UTC(),
+		Public:            claims.Public,
+		Owner:             claims.Owner,
+	}
+	for _, chunk := range claims.Chunks {
+		receipt, ok := receipts[chunk.ID]
+		if !ok {
+			writeError(c, http.StatusBadRequest, apierror.ChunkNotUploaded, chunk.Index)
+			return
+		}
+		if receipt.Size != chunk.Size {
+			writeError(c, http.StatusBadRequest, apierror.ChunkPlanMismatch, chunk.Index)
+			return
+		}
+		metadata.Chunks = append(metadata.Chunks, chunking.FileChunk{
+			ID:       chunk.ID,
+			FileID:   claims.FileID,
+			Index:    chunk.Index,
+			Size:     chunk.Size,
+			Checksum: receipt.Checksum,
+
+			Algorithm: claims.Algorithm,
+			Node:      chunk.Node,
+		})
+	}
+
+	// Одновременное завершение той же загрузки другим запросом отклоняется каталогом
+	err := s.putMetadata(ctx, metadata)
+	if errors.Is(err, catalog.ErrConflict) {
+		writeError(c, http.StatusConflict, apierror.UploadAlreadyCommitted)
+		return
+	}
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+
+	// Куски загружены только на основные серверы, копии создаст восстановление
+	if fileReplicationFactor(settings, metadata) > 1 {
+		s.repair.request()
+	}
+
+	s.events.Publish(events.NewFileEvent(events.FileUploaded, metadata))
+	recordAuditFile(ctx, metadata)
+
+	setDigestHeader(c, metadata)
+	writeMetadata(c, http.StatusOK, metadata)
+}
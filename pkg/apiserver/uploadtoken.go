@@ -0,0 +1,177 @@
+package apiserver
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/token"
+)
+
+// uploadGrantPurpose - назначение токена однократной загрузки файла
+const uploadGrantPurpose = "file-upload"
+
+// uploadTokenRequest - запрос токена загрузки; все поля необязательны
+type uploadTokenRequest struct {
+	ExpiresIn    int64    `json:"expires_in"`    // срок действия в секундах; 0 - upload_token_ttl
+	MaxSize      int64    `json:"max_size"`      // наибольший размер файла; 0 - max_file_size
+	ContentTypes []string `json:"content_types"` // разрешенные типы содержимого (тип/подтип или тип/*)
+}
+
+// uploadGrantClaims - данные токена, разрешающего загрузить один файл без ключа API
+type uploadGrantClaims struct {
+	FileID       string   `json:"file_id"` // идентификатор, который получит загруженный файл
+	MaxSize      int64    `json:"max_size"`
+	ContentTypes []string `json:"content_types,omitempty"`
+	Issuer       string   `json:"issuer,omitempty"` // клиент API, выдавший токен
+}
+
+// uploadToken - ответ на запрос токена загрузки
+type uploadToken struct {
+	Token        string    `json:"token"`
+	FileID       string    `json:"file_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxSize      int64     `json:"max_size"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+	UploadURL    string    `json:"upload_url"` // адрес для POST multipart/form-data с полем file
+}
+
+// createUploadToken выдает подписанный токен, по которому браузер или устройство без
+// ключа API может загрузить один файл с заданными ограничениями
+func (s *StreamingAPIServer) createUploadToken(c *gin.Context) {
+	settings := s.current()
+	secret := settings.config.UploadTokenSecret
+	if secret == "" {
+		writeError(c, http.StatusForbidden, apierror.UploadTokensDisabled)
+		return
+	}
+
+	// Тело запроса необязательно: без него выдается токен с ограничениями по умолчанию
+	var request uploadTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+
+	ttl := settings.config.UploadTokenTTL
+	expiresIn := time.Duration(request.ExpiresIn) * time.Second
+	switch {
+	case request.ExpiresIn < 0:
+		writeError(c, http.StatusBadRequest, apierror.NegativeTTL)
+		return
+	case expiresIn > ttl:
+		writeError(c, http.StatusBadRequest, apierror.TokenTTLTooLong, ttl)
+		return
+	case expiresIn == 0:
+		expiresIn = ttl
+	}
+
+	maxFileSize := settings.config.MaxFileSize
+	switch {
+	case request.MaxSize < 0:
+		writeError(c, http.StatusBadRequest, apierror.NegativeMaxSize)
+		return
+	case request.MaxSize > maxFileSize:
+		writeError(c, http.StatusBadRequest, apierror.MaxSizeTooLarge, maxFileSize)
+		return
+	case request.MaxSize == 0:
+		request.MaxSize = maxFileSize
+	}
+
+	contentTypes, typesErr := parseContentTypes(request.ContentTypes)
+	if typesErr != nil {
+		writeRequestError(c, typesErr)
+		return
+	}
+
+	claims := uploadGrantClaims{
+		FileID:       settings.fileIDScheme.NewID(),
+		MaxSize:      request.MaxSize,
+		ContentTypes: contentTypes,
+		Issuer:       c.GetString(principalKey),
+	}
+	expiresAt := time.Now().Add(expiresIn)
+	signed, err := token.Sign(secret, uploadGrantPurpose, claims, expiresAt)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.InternalError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, uploadToken{
+		Token:        signed,
+		FileID:       claims.FileID,
+		ExpiresAt:    expiresAt.UTC(),
+		MaxSize:      claims.MaxSize,
+		ContentTypes: claims.ContentTypes,
+		UploadURL:    "/api/v1/files?token=" + url.QueryEscape(signed),
+	})
+}
+
+// claimUploadGrant проверяет токен загрузки и отмечает его использованным
+func (s *StreamingAPIServer) claimUploadGrant(c *gin.Context, settings *runtimeSettings, signed string) (*uploadGrantClaims, *requestError) {
+	secret := settings.config.UploadTokenSecret
+	if secret == "" {
+		return nil, newRequestError(http.StatusForbidden, apierror.UploadTokensDisabled)
+	}
+
+	var claims uploadGrantClaims
+	if err := token.Verify(secret, uploadGrantPurpose, signed, &claims); err != nil {
+		return nil, newRequestError(http.StatusUnauthorized, apierror.UploadTokenRejected, err)
+	}
+
+	// Файл с идентификатором из токена появляется только после успешной загрузки по нему,
+	// в том числе через другой API сервер
+	if _, err := s.catalog.Get(c.Request.Context(), claims.FileID); err == nil {
+		return nil, newRequestError(http.StatusConflict, apierror.UploadTokenUsed)
+	}
+	if !s.uploadGrants.claim(claims.FileID, settings.config.UploadTokenTTL) {
+		return nil, newRequestError(http.StatusConflict, apierror.UploadTokenUsed)
+	}
+
+	return &claims, nil
+}
+
+// uploadGrantRegistry запоминает токены загрузки, по которым идет или завершилась
+// загрузка, чтобы по одному токену нельзя было загрузить несколько файлов
+type uploadGrantRegistry struct {
+	mutex sync.Mutex
+	used  map[string]time.Time // идентификатор файла из токена -> когда запись можно забыть
+}
+
+// newUploadGrantRegistry создает пустой реестр токенов загрузки
+func newUploadGrantRegistry() *uploadGrantRegistry {
+	return &uploadGrantRegistry{used: make(map[string]time.Time)}
+}
+
+// claim отмечает токен использованным; false - токен уже использован. Запись хранится
+// ttl - не меньше оставшегося срока действия токена
+func (r *uploadGrantRegistry) claim(fileID string, ttl time.Duration) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for id, forgetAt := range r.used {
+		if now.After(forgetAt) {
+			delete(r.used, id)
+		}
+	}
+
+	if _, used := r.used[fileID]; used {
+		return false
+	}
+	r.used[fileID] = now.Add(ttl)
+	return true
+}
+
+// release снова разрешает загрузку по токену, если загрузка не удалась
+func (r *uploadGrantRegistry) release(fileID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.used, fileID)
+}
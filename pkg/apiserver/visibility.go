@@ -0,0 +1,107 @@
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+)
+
+// publicRoute - маршрут скачивания публичного файла без ключа API
+const publicRoute = "/api/v1/public/:id"
+
+// visibilityRequest - изменение видимости файла
+type visibilityRequest struct {
+	Public *bool `json:"public" binding:"required"`
+}
+
+// visibilityResponse - видимость файла и его постоянная публичная ссылка
+type visibilityResponse struct {
+	ID     string `json:"id"`
+	Public bool   `json:"public"`
+	URL    string `json:"url,omitempty"` // ссылка для скачивания без ключа API; только у публичного файла
+}
+
+// publicFileURL возвращает путь публичной ссылки на файл
+func publicFileURL(fileID string) string {
+	return "/api/v1/public/" + fileID
+}
+
+// parsePublicField разбирает признак публичного файла из поля формы или параметра загрузки
+func parsePublicField(value string) (bool, *requestError) {
+	if value == "" {
+		return false, nil
+	}
+	public, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newRequestError(http.StatusBadRequest, apierror.InvalidPublicField, value)
+	}
+	return public, nil
+}
+
+// isPublicRequest сообщает, является ли запрос скачиванием по публичной ссылке
+func isPublicRequest(c *gin.Context) bool {
+	return c.FullPath() == publicRoute
+}
+
+// downloadPublicFile отдает публичный файл без ключа API. Для закрытого файла
+// отвечает так же, как для несуществующего, чтобы ссылка не выдавала его наличие
+func (s *StreamingAPIServer) downloadPublicFile(c *gin.Context) {
+	metadata, err := s.catalog.Get(c.Request.Context(), c.Param("id"))
+	if err == nil && !metadata.Public {
+		writeError(c, http.StatusNotFound, apierror.FileNotFound)
+		return
+	}
+	if err != nil {
+		writeCatalogError(c, err)
+		return
+	}
+
+	s.serveFile(c, metadata)
+}
+
+// setFileVisibility делает файл публичным или закрытым
+func (s *StreamingAPIServer) setFileVisibility(c *gin.Context) {
+	var request visibilityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	metadata, ok := s.loadFile(c, accessWrite)
+	if !ok {
+		return
+	}
+
+	if metadata.Public != *request.Public {
+		updated, err := s.updateFile(ctx, metadata.ID, func(updated *chunking.FileMetadata) error {
+			updated.Public = *request.Public
+			return nil
+		})
+		if err != nil {
+			writeCatalogError(c, err)
+			return
+		}
+		metadata = updated
+		event := events.NewFileEvent(events.FileVisibilityChanged, metadata)
+		event.Data = map[string]interface{}{"public": metadata.Public}
+		s.events.Publish(event)
+	}
+	recordAuditFile(ctx, metadata)
+
+	c.JSON(http.StatusOK, newVisibilityResponse(metadata.ID, metadata.Public))
+}
+
+// newVisibilityResponse описывает видимость файла
+func newVisibilityResponse(fileID string, public bool) visibilityResponse {
+	response := visibilityResponse{ID: fileID, Public: public}
+	if public {
+		response.URL = publicFileURL(fileID)
+	}
+	return response
+}
@@ -0,0 +1,74 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/client"
+)
+
+func TestWatchDir(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	baseURL := newSingleNodeTestServer(t, nil)
+	api := client.NewAPIClient(baseURL)
+	api.SetAPIKey("alice-key")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0o644))
+
+	events := make(chan client.WatchEvent, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- api.WatchDirContext(ctx, dir, client.WatchOptions{
+			Prefix:   "watch",
+			Debounce: 50 * time.Millisecond,
+			Initial:  true,
+			Notify:   func(event client.WatchEvent) { events <- event },
+		})
+	}()
+	next := func() client.WatchEvent {
+		select {
+		case event := <-events:
+			require.NoError(t, event.Err)
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "загрузка не произошла")
+			return client.WatchEvent{}
+		}
+	}
+
+	// Файл, лежавший в каталоге при запуске
+	event := next()
+	assert.Equal(t, "old.txt", event.RelativePath)
+	assert.Equal(t, "watch/old.txt", event.Metadata.Path)
+
+	// Новый файл в новом подкаталоге
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "new.txt"), []byte("new"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("hidden"), 0o644))
+	event = next()
+	assert.Equal(t, "sub/new.txt", event.RelativePath)
+	assert.Equal(t, "watch/sub/new.txt", event.Metadata.Path)
+	newID := event.Metadata.ID
+
+	// Изменение заменяет содержимое с сохранением идентификатора
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "new.txt"), []byte("new v2"), 0o644))
+	event = next()
+	assert.Equal(t, newID, event.Metadata.ID)
+	assert.Equal(t, int64(6), event.Metadata.Size)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	ids, err := api.ListFilesFiltered(client.ListFilter{Prefix: "watch/"})
+	require.NoError(t, err)
+	assert.Len(t, ids, 2)
+}
@@ -0,0 +1,625 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+)
+
+// WebDAV отображает пространство путей файлов (поле path метаданных) на дерево каталогов.
+// Каталоги существуют неявно, пока в них есть файлы; пустые каталоги, созданные через
+// MKCOL, хранятся только в памяти API сервера.
+
+const webdavPrefix = "/webdav"
+
+// webdavMethods перечисляет методы, которые обрабатывает WebDAV
+var webdavMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// setupWebDAVRoutes регистрирует обработчик WebDAV. Запросы проверяются ключом API, а имя
+// клиента передается файловой системе через контекст запроса
+func (s *StreamingAPIServer) setupWebDAVRoutes(router *gin.Engine) {
+	handler := &webdav.Handler{
+		Prefix:     webdavPrefix,
+		FileSystem: &davFileSystem{server: s, dirs: make(map[string]bool)},
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	serve := func(c *gin.Context) {
+		if c.Request.Method == http.MethodPut {
+			s.davPut(c, handler)
+			return
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+
+	auth := s.authMiddleware()
+	for _, method := range webdavMethods {
+		router.Handle(method, webdavPrefix, s.davAuthChallenge, auth, principalMiddleware, serve)
+		router.Handle(method, webdavPrefix+"/*path", s.davAuthChallenge, auth, principalMiddleware, serve)
+	}
+}
+
+// davAuthChallenge предлагает клиенту WebDAV ввести ключ API паролем Basic, если запрос
+// не прошел проверку ключа: без заголовка WWW-Authenticate клиенты не спрашивают учетные
+// данные у пользователя
+func (s *StreamingAPIServer) davAuthChallenge(c *gin.Context) {
+	settings := s.current()
+	if len(settings.apiKeys) == 0 {
+		return
+	}
+	if _, ok := settings.authenticate(requestAPIKey(c)); !ok {
+		c.Header("WWW-Authenticate", `Basic realm="webdav", charset="UTF-8"`)
+	}
+}
+
+// davPut записывает файл через WebDAV, проверив условие If-Match или If-None-Match.
+// Путь блокируется до конца записи, чтобы условие проверялось по заменяемой версии
+func (s *StreamingAPIServer) davPut(c *gin.Context, handler *webdav.Handler) {
+	filePath := cleanFilePath(c.Param("path"))
+	unlock := s.pathLocks.lock(filePath)
+	defer unlock()
+
+	current, err := s.findFileByPath(c.Request.Context(), filePath)
+	if err != nil && !errors.Is(err, catalog.ErrNotFound) {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if current != nil && !s.current().canAccess(c.GetString(principalKey), current, accessWrite) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	if err := newWriteCondition(c.Request.Header).check(current); err != nil {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+
+	handler.ServeHTTP(c.Writer, c.Request)
+}
+
+// cleanFilePath приводит путь к виду "a/b/c" без ведущего и завершающего слэша
+func cleanFilePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// davFileSystem реализует webdav.FileSystem поверх метаданных API сервера. Права доступа
+// проверяются для клиента из контекста запроса: файлы, которые он не может читать, для
+// него не существуют, а изменение и удаление требуют права записи
+type davFileSystem struct {
+	server *StreamingAPIServer
+
+	dirsMutex sync.RWMutex
+	dirs      map[string]bool // явно созданные каталоги
+}
+
+// canAccess сообщает, разрешена ли клиенту из контекста операция с файлом
+func (d *davFileSystem) canAccess(ctx context.Context, metadata *chunking.FileMetadata, access fileAccess) bool {
+	return d.server.current().canAccess(contextPrincipal(ctx), metadata, access)
+}
+
+// findFile возвращает файл с указанным путем или nil, если его нет или клиент не может его читать
+func (d *davFileSystem) findFile(ctx context.Context, filePath string) (*chunking.FileMetadata, error) {
+	metadata, err := d.server.findFileByPath(ctx, filePath)
+	if errors.Is(err, catalog.ErrNotFound) || (err == nil && !d.canAccess(ctx, metadata, accessRead)) {
+		return nil, nil
+	}
+	return metadata, err
+}
+
+// visibleFiles возвращает файлы с префиксом пути, которые клиент может читать
+func (d *davFileSystem) visibleFiles(ctx context.Context, prefix string) ([]*chunking.FileMetadata, error) {
+	files, err := d.server.filesWithPathPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	visible := files[:0]
+	for _, metadata := range files {
+		if d.canAccess(ctx, metadata, accessRead) {
+			visible = append(visible, metadata)
+		}
+	}
+	return visible, nil
+}
+
+// writableFiles возвращает все файлы каталога dirPath или os.ErrPermission, если клиент
+// не может изменить хотя бы один из них: каталог переносится и удаляется только целиком
+func (d *davFileSystem) writableFiles(ctx context.Context, dirPath string) ([]*chunking.FileMetadata, error) {
+	files, err := d.server.filesWithPathPrefix(ctx, dirPath+"/")
+	if err != nil {
+		return nil, err
+	}
+	for _, metadata := range files {
+		if !d.canAccess(ctx, metadata, accessWrite) {
+			return nil, os.ErrPermission
+		}
+	}
+	return files, nil
+}
+
+// isDir проверяет, существует ли каталог с указанным путем
+func (d *davFileSystem) isDir(ctx context.Context, dirPath string) (bool, error) {
+	if dirPath == "" {
+		return true, nil
+	}
+
+	d.dirsMutex.RLock()
+	explicit := d.dirs[dirPath]
+	d.dirsMutex.RUnlock()
+	if explicit {
+		return true, nil
+	}
+
+	files, err := d.visibleFiles(ctx, dirPath+"/")
+	return len(files) > 0, err
+}
+
+// parentExists проверяет, существует ли каталог, в котором лежит путь
+func (d *davFileSystem) parentExists(ctx context.Context, name string) (bool, error) {
+	parent := path.Dir(name)
+	if parent == "." {
+		return true, nil
+	}
+	return d.isDir(ctx, parent)
+}
+
+// notExist возвращает ошибку каталога, если она есть, иначе os.ErrNotExist
+func notExist(err error) error {
+	if err != nil {
+		return err
+	}
+	return os.ErrNotExist
+}
+
+func (d *davFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	dirPath := cleanFilePath(name)
+	if dirPath == "" {
+		return os.ErrExist
+	}
+
+	isDir, err := d.isDir(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+	metadata, err := d.findFile(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+	if isDir || metadata != nil {
+		return os.ErrExist
+	}
+
+	if exists, err := d.parentExists(ctx, dirPath); err != nil || !exists {
+		return notExist(err)
+	}
+
+	d.dirsMutex.Lock()
+	d.dirs[dirPath] = true
+	d.dirsMutex.Unlock()
+
+	return nil
+}
+
+func (d *davFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	filePath := cleanFilePath(name)
+
+	metadata, err := d.findFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	isDir, err := d.isDir(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Запись создает новую версию файла при закрытии
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		if isDir {
+			return nil, os.ErrInvalid
+		}
+		if exists, err := d.parentExists(ctx, filePath); err != nil || !exists {
+			return nil, notExist(err)
+		}
+		if metadata == nil && flag&os.O_CREATE == 0 {
+			return nil, os.ErrNotExist
+		}
+		if metadata != nil && !d.canAccess(ctx, metadata, accessWrite) {
+			return nil, os.ErrPermission
+		}
+		return &davWriteFile{fs: d, ctx: ctx, path: filePath}, nil
+	}
+
+	if metadata != nil {
+		return &davReadFile{fs: d, ctx: ctx, metadata: metadata}, nil
+	}
+
+	if isDir {
+		return &davDir{fs: d, ctx: ctx, path: filePath}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+func (d *davFileSystem) RemoveAll(ctx context.Context, name string) error {
+	target := cleanFilePath(name)
+	if target == "" {
+		return os.ErrInvalid
+	}
+
+	metadata, err := d.findFile(ctx, target)
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		if !d.canAccess(ctx, metadata, accessWrite) {
+			return os.ErrPermission
+		}
+		return d.server.removeFile(ctx, metadata.ID)
+	}
+
+	if isDir, err := d.isDir(ctx, target); err != nil || !isDir {
+		return notExist(err)
+	}
+
+	files, err := d.writableFiles(ctx, target)
+	if err != nil {
+		return err
+	}
+	for _, metadata := range files {
+		if err := d.server.removeFile(ctx, metadata.ID); err != nil && !errors.Is(err, catalog.ErrNotFound) {
+			return err
+		}
+	}
+
+	d.dirsMutex.Lock()
+	for dir := range d.dirs {
+		if dir == target || strings.HasPrefix(dir, target+"/") {
+			delete(d.dirs, dir)
+		}
+	}
+	d.dirsMutex.Unlock()
+
+	return nil
+}
+
+func (d *davFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldPath := cleanFilePath(oldName)
+	newPath := cleanFilePath(newName)
+	if oldPath == "" || newPath == "" {
+		return os.ErrInvalid
+	}
+
+	// Переименование файла
+	metadata, err := d.findFile(ctx, oldPath)
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		if !d.canAccess(ctx, metadata, accessWrite) {
+			return os.ErrPermission
+		}
+		// Скрытый от клиента файл на новом пути не заменяется
+		if existing, err := d.server.findFileByPath(ctx, newPath); err == nil && !d.canAccess(ctx, existing, accessWrite) {
+			return os.ErrPermission
+		}
+		return d.moveFile(ctx, metadata.ID, oldPath, newPath, true)
+	}
+
+	if isDir, err := d.isDir(ctx, oldPath); err != nil || !isDir {
+		return notExist(err)
+	}
+
+	// Переименование каталога переносит все вложенные файлы
+	files, err := d.writableFiles(ctx, oldPath)
+	if err != nil {
+		return err
+	}
+	for _, metadata := range files {
+		if err := d.moveFile(ctx, metadata.ID, metadata.Path, newPath+strings.TrimPrefix(metadata.Path, oldPath), false); err != nil {
+			return err
+		}
+	}
+
+	d.dirsMutex.Lock()
+	for dir := range d.dirs {
+		if dir == oldPath || strings.HasPrefix(dir, oldPath+"/") {
+			delete(d.dirs, dir)
+			d.dirs[newPath+strings.TrimPrefix(dir, oldPath)] = true
+		}
+	}
+	d.dirsMutex.Unlock()
+
+	return nil
+}
+
+// moveFile переносит файл с пути from на путь to, а при rename и меняет его имя. Если
+// файл успели перенести или удалить другим запросом, возвращается os.ErrNotExist
+func (d *davFileSystem) moveFile(ctx context.Context, fileID, from, to string, rename bool) error {
+	_, err := d.server.updateFile(ctx, fileID, func(metadata *chunking.FileMetadata) error {
+		if metadata.Path != from {
+			return os.ErrNotExist
+		}
+		metadata.Path = to
+		if rename {
+			metadata.OriginalName = path.Base(to)
+		}
+		return nil
+	})
+	if errors.Is(err, catalog.ErrNotFound) {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+func (d *davFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	target := cleanFilePath(name)
+
+	metadata, err := d.findFile(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if metadata != nil {
+		return newDavFileInfo(metadata), nil
+	}
+
+	if isDir, err := d.isDir(ctx, target); err != nil || !isDir {
+		return nil, notExist(err)
+	}
+	return &davDirInfo{name: path.Base("/" + target)}, nil
+}
+
+// children возвращает непосредственное содержимое каталога
+func (d *davFileSystem) children(ctx context.Context, dirPath string) ([]os.FileInfo, error) {
+	prefix := ""
+	if dirPath != "" {
+		prefix = dirPath + "/"
+	}
+
+	files, err := d.visibleFiles(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]os.FileInfo)
+	for _, metadata := range files {
+		rest := strings.TrimPrefix(metadata.Path, prefix)
+		if name, _, nested := strings.Cut(rest, "/"); nested {
+			entries[name] = &davDirInfo{name: name}
+		} else {
+			entries[rest] = newDavFileInfo(metadata)
+		}
+	}
+
+	d.dirsMutex.RLock()
+	for dir := range d.dirs {
+		if rest, ok := strings.CutPrefix(dir, prefix); ok && rest != "" {
+			name, _, _ := strings.Cut(rest, "/")
+			if _, exists := entries[name]; !exists {
+				entries[name] = &davDirInfo{name: name}
+			}
+		}
+	}
+	d.dirsMutex.RUnlock()
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, info := range entries {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	return infos, nil
+}
+
+// davFileInfo описывает файл хранилища
+type davFileInfo struct {
+	metadata *chunking.FileMetadata
+}
+
+func newDavFileInfo(metadata *chunking.FileMetadata) *davFileInfo {
+	return &davFileInfo{metadata: metadata}
+}
+
+func (f *davFileInfo) Name() string       { return path.Base(f.metadata.Path) }
+func (f *davFileInfo) Size() int64        { return f.metadata.Size }
+func (f *davFileInfo) Mode() fs.FileMode  { return 0644 }
+func (f *davFileInfo) ModTime() time.Time { return f.metadata.LastModified() }
+func (f *davFileInfo) IsDir() bool        { return false }
+func (f *davFileInfo) Sys() interface{}   { return nil }
+
+// ContentType избавляет WebDAV от чтения начала файла для определения типа
+func (f *davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if f.metadata.ContentType != "" {
+		return f.metadata.ContentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// ETag использует контрольную сумму файла
+func (f *davFileInfo) ETag(ctx context.Context) (string, error) {
+	return fmt.Sprintf("\"%s\"", f.metadata.Checksum), nil
+}
+
+// davDirInfo описывает каталог
+type davDirInfo struct {
+	name string
+}
+
+func (d *davDirInfo) Name() string       { return d.name }
+func (d *davDirInfo) Size() int64        { return 0 }
+func (d *davDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (d *davDirInfo) ModTime() time.Time { return time.Time{} }
+func (d *davDirInfo) IsDir() bool        { return true }
+func (d *davDirInfo) Sys() interface{}   { return nil }
+
+// davDir представляет открытый каталог
+type davDir struct {
+	fs     *davFileSystem
+	ctx    context.Context
+	path   string
+	offset int
+}
+
+func (d *davDir) Close() error                                 { return nil }
+func (d *davDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *davDir) Write(p []byte) (int, error)                  { return 0, os.ErrInvalid }
+
+func (d *davDir) Stat() (os.FileInfo, error) {
+	return &davDirInfo{name: path.Base("/" + d.path)}, nil
+}
+
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	children, err := d.fs.children(d.ctx, d.path)
+	if err != nil {
+		return nil, err
+	}
+	if d.offset >= len(children) {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+
+	children = children[d.offset:]
+	if count > 0 && count < len(children) {
+		children = children[:count]
+	}
+	d.offset += len(children)
+
+	return children, nil
+}
+
+// davReadFile читает файл из хранилища потоком кусков с текущей позиции. Куски
+// запрашиваются при первом чтении, а после Seek на другую позицию чтение начинается заново
+type davReadFile struct {
+	fs       *davFileSystem
+	ctx      context.Context
+	metadata *chunking.FileMetadata
+	reader   *fileReader // nil, пока файл не читается
+	start    int64       // позиция, с которой начато чтение reader
+	offset   int64
+}
+
+func (f *davReadFile) Read(p []byte) (int, error) {
+	if f.reader == nil {
+		reader, err := f.fs.server.newFileReader(f.ctx, f.metadata, f.offset)
+		if err != nil {
+			return 0, err
+		}
+		f.reader = reader
+		f.start = f.offset
+	}
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF {
+		// Скачиванием считается только чтение с начала файла, как для диапазонов
+		f.fs.server.recordAccess(f.metadata.ID, f.start == 0, f.offset-f.start)
+	}
+	return n, err
+}
+
+// Seek не запрашивает куски, чтобы запросы размера не требовали чтения файла
+func (f *davReadFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.metadata.Size
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+
+	if offset != f.offset {
+		f.Close()
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *davReadFile) Close() error {
+	if f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+	return nil
+}
+
+func (f *davReadFile) Write(p []byte) (int, error)              { return 0, os.ErrPermission }
+func (f *davReadFile) Readdir(count int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *davReadFile) Stat() (os.FileInfo, error)               { return newDavFileInfo(f.metadata), nil }
+
+// davWriteFile накапливает содержимое и сохраняет файл в хранилище при закрытии
+type davWriteFile struct {
+	fs     *davFileSystem
+	ctx    context.Context
+	path   string
+	buffer bytes.Buffer
+	closed bool
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	maxFileSize := f.fs.server.current().config.MaxFileSize
+	if int64(f.buffer.Len()+len(p)) > maxFileSize {
+		return 0, fmt.Errorf("размер файла превышает максимально допустимый (%d байт)", maxFileSize)
+	}
+	return f.buffer.Write(p)
+}
+
+func (f *davWriteFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	// Условие записи проверено davPut под блокировкой пути
+	_, err := f.fs.server.replaceFile(f.ctx, uploadInfo{
+		Name:        path.Base(f.path),
+		ContentType: mime.TypeByExtension(path.Ext(f.path)),
+		Path:        f.path,
+	}, f.buffer.Bytes(), writeCondition{})
+	if err != nil {
+		return err
+	}
+
+	// Каталог больше не пуст и существует неявно
+	f.fs.dirsMutex.Lock()
+	delete(f.fs.dirs, path.Dir(f.path))
+	f.fs.dirsMutex.Unlock()
+
+	return nil
+}
+
+func (f *davWriteFile) Read(p []byte) (int, error)                   { return 0, os.ErrPermission }
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (f *davWriteFile) Readdir(count int) ([]fs.FileInfo, error)     { return nil, os.ErrInvalid }
+
+func (f *davWriteFile) Stat() (os.FileInfo, error) {
+	return &davFileInfo{metadata: &chunking.FileMetadata{
+		Path:      f.path,
+		Size:      int64(f.buffer.Len()),
+		CreatedAt: time.Now().UTC(),
+	}}, nil
+}
@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrQueryNotSupported возвращается, если ни один приемник не поддерживает запросы
+var ErrQueryNotSupported = errors.New("журнал аудита не поддерживает запросы")
+
+// FileRecord описывает файл, затронутый операцией, и его куски
+type FileRecord struct {
+	ID     string   `json:"id"`
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+// Entry описывает одну изменяющую операцию
+type Entry struct {
+	ID        string       `json:"id"`
+	Timestamp time.Time    `json:"timestamp"`
+	Actor     string       `json:"actor"`
+	Action    string       `json:"action"`
+	Method    string       `json:"method"`
+	Path      string       `json:"path"`
+	Files     []FileRecord `json:"files,omitempty"`
+	Status    int          `json:"status"`
+	Success   bool         `json:"success"`
+	Error     string       `json:"error,omitempty"`
+	Threat    string       `json:"threat,omitempty"` // угроза, найденная антивирусом в загружаемом файле
+	ClientIP  string       `json:"client_ip"`
+	RequestID string       `json:"request_id,omitempty"` // идентификатор запроса из заголовка X-Request-ID
+}
+
+// Filter задает условия выборки записей журнала
+type Filter struct {
+	Actor  string
+	Action string
+	FileID string
+	Since  time.Time
+	Until  time.Time
+	Limit  int // 0 - без ограничения; при ограничении возвращаются самые новые записи
+}
+
+// Match проверяет, подходит ли запись под фильтр
+func (f Filter) Match(entry Entry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.FileID != "" {
+		for _, file := range entry.Files {
+			if file.ID == f.FileID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Sink принимает записи журнала аудита
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// QueryableSink - приемник, из которого можно читать записи
+type QueryableSink interface {
+	Sink
+	Query(filter Filter) ([]Entry, error)
+	// Prune удаляет записи старше указанного момента
+	Prune(before time.Time) error
+}
+
+// Logger записывает операции во все настроенные приемники
+type Logger struct {
+	sinks     []Sink
+	retention time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewLogger создает журнал аудита. Если retention больше нуля,
+// записи старше этого срока периодически удаляются из приемников, поддерживающих запросы
+func NewLogger(sinks []Sink, retention time.Duration) *Logger {
+	l := &Logger{
+		sinks:     sinks,
+		retention: retention,
+		stop:      make(chan struct{}),
+	}
+
+	if retention > 0 {
+		l.wg.Add(1)
+		go l.retentionLoop()
+	}
+
+	return l
+}
+
+// Record дополняет запись идентификатором и временем и передает ее приемникам
+func (l *Logger) Record(entry Entry) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(entry); err != nil {
+			log.Printf("Не удалось записать событие аудита %s: %v", entry.ID, err)
+		}
+	}
+}
+
+// Query возвращает записи из первого приемника, поддерживающего запросы
+func (l *Logger) Query(filter Filter) ([]Entry, error) {
+	for _, sink := range l.sinks {
+		if queryable, ok := sink.(QueryableSink); ok {
+			return queryable.Query(filter)
+		}
+	}
+	return nil, ErrQueryNotSupported
+}
+
+// Close останавливает очистку и закрывает приемники
+func (l *Logger) Close() error {
+	close(l.stop)
+	l.wg.Wait()
+
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retentionLoop периодически удаляет устаревшие записи
+func (l *Logger) retentionLoop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		l.prune()
+
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *Logger) prune() {
+	before := time.Now().Add(-l.retention)
+	for _, sink := range l.sinks {
+		if queryable, ok := sink.(QueryableSink); ok {
+			if err := queryable.Prune(before); err != nil {
+				log.Printf("Не удалось удалить устаревшие записи аудита: %v", err)
+			}
+		}
+	}
+}
+
+// detailsKey - ключ контекста для сведений о затронутых файлах
+type detailsKey struct{}
+
+// Details накапливает сведения о файлах, затронутых во время обработки запроса
+type Details struct {
+	mutex  sync.Mutex
+	files  []FileRecord
+	threat string
+}
+
+// Files возвращает накопленные сведения о файлах
+func (d *Details) Files() []FileRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return append([]FileRecord(nil), d.files...)
+}
+
+// Threat возвращает угрозу, найденную в загружаемом файле
+func (d *Details) Threat() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.threat
+}
+
+// WithDetails возвращает контекст, в который обработчики могут добавлять сведения о файлах
+func WithDetails(ctx context.Context) (context.Context, *Details) {
+	details := &Details{}
+	return context.WithValue(ctx, detailsKey{}, details), details
+}
+
+// AddFile отмечает файл и его куски как затронутые текущей операцией.
+// Если контекст не содержит сведений аудита, вызов ничего не делает
+func AddFile(ctx context.Context, fileID string, chunks []string) {
+	details, ok := ctx.Value(detailsKey{}).(*Details)
+	if !ok {
+		return
+	}
+
+	details.mutex.Lock()
+	details.files = append(details.files, FileRecord{ID: fileID, Chunks: chunks})
+	details.mutex.Unlock()
+}
+
+// SetThreat отмечает, что загружаемый файл отклонен из-за найденной угрозы.
+// Если контекст не содержит сведений аудита, вызов ничего не делает
+func SetThreat(ctx context.Context, threat string) {
+	details, ok := ctx.Value(detailsKey{}).(*Details)
+	if !ok {
+		return
+	}
+
+	details.mutex.Lock()
+	details.threat = threat
+	details.mutex.Unlock()
+}
@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSinkQueryAndPrune(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer sink.Close()
+
+	now := time.Now().UTC()
+	require.NoError(t, sink.Write(Entry{ID: "old", Timestamp: now.Add(-48 * time.Hour), Action: "DELETE /api/v1/files/:id", Files: []FileRecord{{ID: "a"}}}))
+	require.NoError(t, sink.Write(Entry{ID: "new", Timestamp: now, Action: "POST /api/v1/files", Files: []FileRecord{{ID: "b"}}}))
+
+	entries, err := sink.Query(Filter{FileID: "a"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "old", entries[0].ID)
+
+	entries, err = sink.Query(Filter{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "new", entries[0].ID)
+
+	require.NoError(t, sink.Prune(now.Add(-24*time.Hour)))
+
+	// После очистки журнал продолжает принимать записи
+	require.NoError(t, sink.Write(Entry{ID: "after", Timestamp: now}))
+
+	entries, err = sink.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "new", entries[0].ID)
+	assert.Equal(t, "after", entries[1].ID)
+}
+
+func TestDetailsCollectFiles(t *testing.T) {
+	ctx, details := WithDetails(context.Background())
+	AddFile(ctx, "file", []string{"file_chunk_0"})
+	SetThreat(ctx, "Eicar-Signature")
+
+	// Без сведений аудита в контексте вызов игнорируется
+	AddFile(context.Background(), "other", nil)
+	SetThreat(context.Background(), "other")
+
+	files := details.Files()
+	require.Len(t, files, 1)
+	assert.Equal(t, "file", files[0].ID)
+	assert.Equal(t, []string{"file_chunk_0"}, files[0].Chunks)
+	assert.Equal(t, "Eicar-Signature", details.Threat())
+}
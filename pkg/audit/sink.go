@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSink дописывает записи в файл в формате JSON Lines
+type FileSink struct {
+	path  string
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// NewFileSink открывает файл журнала на дозапись, создавая его при необходимости
+func NewFileSink(path string) (*FileSink, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("не удалось создать директорию журнала аудита: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал аудита: %w", err)
+	}
+
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Write дописывает запись в конец файла
+func (s *FileSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать запись: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Query читает журнал и возвращает подходящие записи в хронологическом порядке
+func (s *FileSink) Query(filter Filter) ([]Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := make([]Entry, 0)
+	err := s.scan(func(entry Entry) {
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// Prune удаляет записи старше указанного момента, переписывая файл целиком.
+// Новый файл подменяет старый атомарно, поэтому журнал не теряется при сбое
+func (s *FileSink) Prune(before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный файл: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	removed := 0
+	var writeErr error
+
+	err = s.scan(func(entry Entry) {
+		if entry.Timestamp.Before(before) {
+			removed++
+			return
+		}
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		if err != nil && writeErr == nil {
+			writeErr = err
+		}
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+
+	if err != nil || removed == 0 {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("не удалось заменить журнал аудита: %w", err)
+	}
+
+	// Открываем файл заново: старый дескриптор указывает на удаленный файл
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал аудита: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+
+	return nil
+}
+
+// Close закрывает файл журнала
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
+
+// scan последовательно читает записи журнала; вызывается под блокировкой
+func (s *FileSink) scan(fn func(Entry)) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал аудита: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var entry Entry
+		// Поврежденные строки пропускаем, чтобы одна запись не делала журнал нечитаемым
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		fn(entry)
+	}
+
+	return scanner.Err()
+}
+
+// WriterSink пишет записи в формате JSON Lines в произвольный поток, например stdout
+type WriterSink struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// NewWriterSink создает приемник, пишущий в writer
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{writer: writer}
+}
+
+// Write выводит запись одной строкой JSON
+func (s *WriterSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать запись: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
+
+// Close ничего не делает: поток принадлежит вызывающему коду
+func (s *WriterSink) Close() error {
+	return nil
+}
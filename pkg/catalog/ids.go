@@ -0,0 +1,47 @@
+package catalog
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// IDScheme определяет формат идентификаторов новых файлов
+type IDScheme string
+
+const (
+	IDSchemeUUIDv4 IDScheme = "uuidv4" // случайный UUID, используется по умолчанию
+	IDSchemeUUIDv7 IDScheme = "uuidv7" // UUID с временем создания в старших битах
+	IDSchemeULID   IDScheme = "ulid"   // ULID: 26 символов Crockford base32, начинается со времени создания
+
+	// DefaultIDScheme используется, если схема не указана
+	DefaultIDScheme = IDSchemeUUIDv4
+)
+
+// ParseIDScheme разбирает название схемы идентификаторов файлов
+func ParseIDScheme(name string) (IDScheme, error) {
+	scheme := IDScheme(name)
+	switch scheme {
+	case "":
+		return DefaultIDScheme, nil
+	case IDSchemeUUIDv4, IDSchemeUUIDv7, IDSchemeULID:
+		return scheme, nil
+	default:
+		return "", fmt.Errorf("неизвестная схема идентификаторов файлов: %s", name)
+	}
+}
+
+// NewID создает идентификатор файла. Идентификаторы uuidv7 и ulid начинаются со времени
+// создания, но каталог не упорядочивает файлы по идентификатору
+func (s IDScheme) NewID() string {
+	switch s {
+	case IDSchemeUUIDv7:
+		// Ошибка возможна только при отказе источника случайных чисел
+		return uuid.Must(uuid.NewV7()).String()
+	case IDSchemeULID:
+		return ulid.Make().String()
+	default:
+		return uuid.New().String()
+	}
+}
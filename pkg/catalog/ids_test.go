@@ -0,0 +1,33 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDScheme(t *testing.T) {
+	scheme, err := ParseIDScheme("")
+	require.NoError(t, err)
+	assert.Equal(t, IDSchemeUUIDv4, scheme)
+
+	_, err = ParseIDScheme("snowflake")
+	assert.Error(t, err)
+}
+
+func TestNewIDIsTimeSortable(t *testing.T) {
+	lengths := map[IDScheme]int{IDSchemeUUIDv4: 36, IDSchemeUUIDv7: 36, IDSchemeULID: 26}
+	for scheme, length := range lengths {
+		assert.Len(t, scheme.NewID(), length, scheme)
+	}
+
+	for _, scheme := range []IDScheme{IDSchemeUUIDv7, IDSchemeULID} {
+		previous := scheme.NewID()
+		for i := 0; i < 1000; i++ {
+			id := scheme.NewID()
+			require.Greater(t, id, previous, scheme)
+			previous = id
+		}
+	}
+}
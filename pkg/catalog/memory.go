@@ -0,0 +1,105 @@
+package catalog
+
+import (
+	"context"
+	"sync"
+
+	"TestCase/pkg/chunking"
+)
+
+// MemoryStore хранит каталог в памяти процесса; каталог теряется при перезапуске
+type MemoryStore struct {
+	files map[string]*chunking.FileMetadata
+	mutex sync.RWMutex
+}
+
+// NewMemoryStore создает пустой каталог в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{files: make(map[string]*chunking.FileMetadata)}
+}
+
+// Get возвращает метаданные файла
+func (m *MemoryStore) Get(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	metadata, exists := m.files[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return metadata, nil
+}
+
+// List возвращает метаданные всех файлов
+func (m *MemoryStore) List(ctx context.Context) ([]*chunking.FileMetadata, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	files := make([]*chunking.FileMetadata, 0, len(m.files))
+	for _, metadata := range m.files {
+		files = append(files, metadata)
+	}
+	return files, nil
+}
+
+// Put сохраняет метаданные файла, если их поколение совпадает с сохраненным.
+// Сохраненные метаданные не изменяются: для правки нужно сохранить измененную копию
+func (m *MemoryStore) Put(ctx context.Context, metadata *chunking.FileMetadata) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.put(metadata, true)
+}
+
+// put сохраняет метаданные со следующим поколением; при check - только если поколение
+// совпадает с сохраненным. Вызывается под mutex
+func (m *MemoryStore) put(metadata *chunking.FileMetadata, check bool) error {
+	var current int64
+	if stored, exists := m.files[metadata.ID]; exists {
+		current = stored.Generation
+	}
+	if check && metadata.Generation != current {
+		return ErrConflict
+	}
+
+	metadata.Generation = current + 1
+	m.files[metadata.ID] = metadata
+	return nil
+}
+
+// Delete удаляет файл из каталога
+func (m *MemoryStore) Delete(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	metadata, exists := m.files[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	delete(m.files, id)
+	return metadata, nil
+}
+
+// Close ничего не делает: каталог в памяти не держит ресурсов
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// snapshot возвращает копию каталога для сохранения снимка
+func (m *MemoryStore) snapshot() map[string]*chunking.FileMetadata {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	files := make(map[string]*chunking.FileMetadata, len(m.files))
+	for id, metadata := range m.files {
+		files[id] = metadata
+	}
+	return files
+}
+
+// restore заменяет каталог целиком
+func (m *MemoryStore) restore(files map[string]*chunking.FileMetadata) {
+	m.mutex.Lock()
+	m.files = files
+	m.mutex.Unlock()
+}
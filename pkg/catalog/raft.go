@@ -0,0 +1,518 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// ForwardPath - путь API сервера, на который узлы пересылают изменения каталога лидеру
+const ForwardPath = "/internal/raft/apply"
+
+// maxForwardSize ограничивает размер изменения, пересланного лидеру
+const maxForwardSize = 32 << 20
+
+// ErrNoLeader возвращается, если в кластере нет лидера и изменение некому применить
+var ErrNoLeader = errors.New("в кластере метаданных нет лидера")
+
+// RaftConfig содержит настройки узла кластера метаданных
+type RaftConfig struct {
+	// NodeID - адрес API сервера этого узла, доступный остальным узлам, например http://api1:8080.
+	// По нему узлы пересылают изменения лидеру
+	NodeID string
+	// BindAddr - адрес для обмена сообщениями Raft, например 0.0.0.0:7000
+	BindAddr string
+	// AdvertiseAddr - адрес Raft, по которому узел доступен остальным; по умолчанию BindAddr
+	AdvertiseAddr string
+	// Dir - директория журнала и снимков Raft
+	Dir string
+	// Peers - все узлы кластера, включая этот, в формате NodeID=AdvertiseAddr.
+	// Используется только при первом запуске, чтобы сформировать кластер
+	Peers []string
+	// ApplyTimeout ограничивает ожидание применения изменения; по умолчанию 10 секунд
+	ApplyTimeout time.Duration
+	// Secret - общий ключ HMAC, которым подписываются изменения, пересылаемые лидеру.
+	// Пусто - изменения принимаются без подписи
+	Secret string
+	// SignatureMaxSkew - допустимое расхождение времени подписи и часов узла; по умолчанию минута
+	SignatureMaxSkew time.Duration
+	// ForwardListen - адрес host:port отдельного HTTP сервера, на котором узел принимает
+	// пересланные изменения. Пусто - обработчик подключает вызывающий, например к API
+	ForwardListen string
+}
+
+// raftCommand - запись журнала Raft
+type raftCommand struct {
+	Op       string                 `json:"op"` // put или delete
+	ID       string                 `json:"id,omitempty"`
+	Metadata *chunking.FileMetadata `json:"metadata,omitempty"`
+
+	// Checked - put записывает метаданные, только если их поколение совпадает с сохраненным.
+	// Записи журнала без поколений применяются без проверки
+	Checked bool `json:"checked,omitempty"`
+}
+
+// validate проверяет, что команду можно применить к каталогу
+func (c *raftCommand) validate() error {
+	switch c.Op {
+	case "put":
+		if c.Metadata == nil || c.Metadata.ID == "" {
+			return errors.New("put без метаданных или идентификатора файла")
+		}
+	case "delete":
+		if c.ID == "" {
+			return errors.New("delete без идентификатора файла")
+		}
+	default:
+		return fmt.Errorf("неизвестная операция %q", c.Op)
+	}
+	return nil
+}
+
+// raftResult - результат применения команды, в том числе пересланной лидеру
+type raftResult struct {
+	Metadata *chunking.FileMetadata `json:"metadata,omitempty"`
+	NotFound bool                   `json:"not_found,omitempty"`
+	Conflict bool                   `json:"conflict,omitempty"` // поколение метаданных устарело
+	Index    uint64                 `json:"index"`              // индекс записи в журнале
+}
+
+// RaftStore реплицирует каталог между несколькими API серверами через журнал Raft.
+// Чтение выполняется из локальной копии, изменения применяются через лидера:
+// узел, не являющийся лидером, пересылает их на ForwardPath лидера
+type RaftStore struct {
+	config     RaftConfig
+	raft       *raft.Raft
+	fsm        *raftFSM
+	logStore   *raftboltdb.BoltStore
+	transport  *raft.NetworkTransport
+	httpClient *http.Client
+
+	signatures    *storage.SignatureVerifier // проверка подписей пересланных изменений; nil без Secret
+	forwardServer *http.Server               // сервер ForwardListen; nil, если обработчик подключен к API
+}
+
+// NewRaftStore запускает узел кластера метаданных. При первом запуске узел
+// формирует кластер из Peers; при повторном восстанавливает каталог из журнала и снимков
+func NewRaftStore(cfg RaftConfig) (*RaftStore, error) {
+	if cfg.ApplyTimeout <= 0 {
+		cfg.ApplyTimeout = 10 * time.Second
+	}
+	if cfg.AdvertiseAddr == "" {
+		cfg.AdvertiseAddr = cfg.BindAddr
+	}
+	if cfg.SignatureMaxSkew <= 0 {
+		cfg.SignatureMaxSkew = time.Minute
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию Raft: %w", err)
+	}
+
+	advertise, err := net.ResolveTCPAddr("tcp", cfg.AdvertiseAddr)
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес Raft %s: %w", cfg.AdvertiseAddr, err)
+	}
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(cfg.NodeID)
+	raftConfig.LogOutput = log.Writer()
+	raftConfig.LogLevel = "INFO"
+
+	logStore, err := raftboltdb.NewBoltStore(filepath.Join(cfg.Dir, "raft.db"))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал Raft: %w", err)
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(cfg.Dir, 2, log.Writer())
+	if err != nil {
+		logStore.Close()
+		return nil, fmt.Errorf("не удалось открыть снимки Raft: %w", err)
+	}
+
+	transport, err := raft.NewTCPTransport(cfg.BindAddr, advertise, 3, 10*time.Second, log.Writer())
+	if err != nil {
+		logStore.Close()
+		return nil, fmt.Errorf("не удалось открыть транспорт Raft: %w", err)
+	}
+
+	store := &RaftStore{
+		config:     cfg,
+		fsm:        &raftFSM{store: NewMemoryStore()},
+		logStore:   logStore,
+		transport:  transport,
+		httpClient: &http.Client{Timeout: cfg.ApplyTimeout},
+	}
+	if cfg.Secret != "" {
+		store.signatures = storage.NewSignatureVerifier(cfg.Secret, cfg.SignatureMaxSkew)
+	}
+
+	hasState, err := raft.HasExistingState(logStore, logStore, snapshots)
+	if err != nil {
+		store.closeStores()
+		return nil, fmt.Errorf("не удалось прочитать состояние Raft: %w", err)
+	}
+
+	store.raft, err = raft.NewRaft(raftConfig, store.fsm, logStore, logStore, snapshots, transport)
+	if err != nil {
+		store.closeStores()
+		return nil, fmt.Errorf("не удалось запустить Raft: %w", err)
+	}
+
+	if !hasState {
+		configuration, err := peersConfiguration(cfg)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		// Все узлы формируют кластер с одинаковым составом, поэтому
+		// повторная попытка на других узлах безопасна и завершается ErrCantBootstrap
+		if err := store.raft.BootstrapCluster(configuration).Error(); err != nil && !errors.Is(err, raft.ErrCantBootstrap) {
+			store.Close()
+			return nil, fmt.Errorf("не удалось сформировать кластер Raft: %w", err)
+		}
+	}
+
+	if cfg.ForwardListen != "" {
+		if err := store.serveForward(); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// serveForward запускает внутренний HTTP сервер, принимающий изменения от остальных узлов
+// на ForwardPath, отдельно от API для клиентов
+func (r *RaftStore) serveForward() error {
+	listener, err := net.Listen("tcp", r.config.ForwardListen)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть адрес пересылки изменений %s: %w", r.config.ForwardListen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(ForwardPath, r)
+	r.forwardServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := r.forwardServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Сервер пересылки изменений каталога остановлен: %v", err)
+		}
+	}()
+	return nil
+}
+
+// peersConfiguration разбирает список узлов кластера
+func peersConfiguration(cfg RaftConfig) (raft.Configuration, error) {
+	peers := cfg.Peers
+	if len(peers) == 0 {
+		// Кластер из одного узла
+		peers = []string{cfg.NodeID + "=" + cfg.AdvertiseAddr}
+	}
+
+	var configuration raft.Configuration
+	for _, peer := range peers {
+		separator := strings.LastIndex(peer, "=")
+		if separator <= 0 || separator == len(peer)-1 {
+			return configuration, fmt.Errorf("неверное описание узла Raft %q, ожидается NodeID=адрес", peer)
+		}
+		configuration.Servers = append(configuration.Servers, raft.Server{
+			ID:      raft.ServerID(peer[:separator]),
+			Address: raft.ServerAddress(peer[separator+1:]),
+		})
+	}
+	return configuration, nil
+}
+
+// Get возвращает метаданные файла из локальной копии каталога
+func (r *RaftStore) Get(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	return r.fsm.store.Get(ctx, id)
+}
+
+// List возвращает метаданные всех файлов из локальной копии каталога
+func (r *RaftStore) List(ctx context.Context) ([]*chunking.FileMetadata, error) {
+	return r.fsm.store.List(ctx)
+}
+
+// Put реплицирует метаданные файла на все узлы. Поколение проверяется при применении
+// записи журнала, поэтому узлы одинаково отклоняют устаревшую запись
+func (r *RaftStore) Put(ctx context.Context, metadata *chunking.FileMetadata) error {
+	result, err := r.apply(ctx, raftCommand{Op: "put", Metadata: metadata, Checked: true})
+	if err != nil {
+		return err
+	}
+	if result.Conflict {
+		return ErrConflict
+	}
+	if result.Metadata == nil {
+		return errors.New("изменение каталога не применено")
+	}
+	metadata.Generation = result.Metadata.Generation
+	return nil
+}
+
+// Delete удаляет файл из каталога на всех узлах
+func (r *RaftStore) Delete(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	result, err := r.apply(ctx, raftCommand{Op: "delete", ID: id})
+	if err != nil {
+		return nil, err
+	}
+	if result.NotFound {
+		return nil, ErrNotFound
+	}
+	return result.Metadata, nil
+}
+
+// Leader возвращает NodeID текущего лидера или пустую строку
+func (r *RaftStore) Leader() string {
+	_, id := r.raft.LeaderWithID()
+	return string(id)
+}
+
+// State возвращает роль узла: Leader, Follower или Candidate
+func (r *RaftStore) State() string {
+	return r.raft.State().String()
+}
+
+// Close останавливает узел
+func (r *RaftStore) Close() error {
+	var err error
+	if r.forwardServer != nil {
+		err = r.forwardServer.Close()
+	}
+	err = errors.Join(err, r.raft.Shutdown().Error())
+	return errors.Join(err, r.closeStores())
+}
+
+func (r *RaftStore) closeStores() error {
+	return errors.Join(r.transport.Close(), r.logStore.Close())
+}
+
+// apply применяет команду на лидере или пересылает ее лидеру
+func (r *RaftStore) apply(ctx context.Context, command raftCommand) (*raftResult, error) {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать команду: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.ApplyTimeout)
+	defer cancel()
+
+	leader, err := r.waitLeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if leader == r.config.NodeID {
+		return r.applyLocal(data)
+	}
+
+	result, err := r.forward(ctx, leader, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Дожидаемся записи в локальной копии, чтобы следующее чтение на этом узле ее увидело
+	for r.raft.AppliedIndex() < result.Index {
+		select {
+		case <-ctx.Done():
+			return result, nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return result, nil
+}
+
+// waitLeader ждет выбора лидера и возвращает его NodeID
+func (r *RaftStore) waitLeader(ctx context.Context) (string, error) {
+	for {
+		if leader := r.Leader(); leader != "" {
+			return leader, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ErrNoLeader
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// applyLocal записывает команду в журнал; вызывается только на лидере
+func (r *RaftStore) applyLocal(data []byte) (*raftResult, error) {
+	future := r.raft.Apply(data, r.config.ApplyTimeout)
+	if err := future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+			return nil, ErrNoLeader
+		}
+		return nil, fmt.Errorf("не удалось применить изменение каталога: %w", err)
+	}
+
+	result := future.Response().(*raftResult)
+	result.Index = future.Index()
+	return result, nil
+}
+
+// forward пересылает команду лидеру по HTTP
+func (r *RaftStore) forward(ctx context.Context, leader string, data []byte) (*raftResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(leader, "/")+ForwardPath, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос к лидеру: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.config.Secret != "" {
+		if err := storage.SignRequest(req, r.config.Secret); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось переслать изменение лидеру %s: %w", leader, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return nil, ErrNoLeader
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("лидер %s вернул статус %d: %s", leader, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result raftResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать ответ лидера: %w", err)
+	}
+	return &result, nil
+}
+
+// ServeHTTP применяет изменение, пересланное другим узлом. С Secret принимаются только
+// запросы, подписанные общим ключом. Если узел уже не лидер, возвращается 503, и
+// отправитель повторяет попытку после выбора нового лидера
+func (r *RaftStore) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if r.signatures != nil {
+		if err := r.signatures.Verify(req); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxForwardSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Неверная команда попала бы в журнал всех узлов, поэтому проверяется до записи
+	var command raftCommand
+	if err := json.Unmarshal(data, &command); err != nil {
+		http.Error(w, fmt.Sprintf("неверная команда: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := command.validate(); err != nil {
+		http.Error(w, fmt.Sprintf("неверная команда: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if r.raft.State() != raft.Leader {
+		http.Error(w, ErrNoLeader.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	result, err := r.applyLocal(data)
+	if errors.Is(err, ErrNoLeader) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// raftFSM применяет записи журнала к каталогу в памяти
+type raftFSM struct {
+	store *MemoryStore
+}
+
+// Apply применяет запись журнала к каталогу. Неверные записи пропускаются: журнал мог
+// получить их от узла, который еще не проверял команды
+func (f *raftFSM) Apply(entry *raft.Log) interface{} {
+	var command raftCommand
+	err := json.Unmarshal(entry.Data, &command)
+	if err == nil {
+		err = command.validate()
+	}
+	if err != nil {
+		// Запись уже в журнале, поэтому остается только пропустить ее
+		log.Printf("Пропущена неверная запись журнала Raft %d: %v", entry.Index, err)
+		return &raftResult{}
+	}
+
+	ctx := context.Background()
+	switch command.Op {
+	case "put":
+		f.store.mutex.Lock()
+		err := f.store.put(command.Metadata, command.Checked)
+		f.store.mutex.Unlock()
+		return &raftResult{Metadata: command.Metadata, Conflict: errors.Is(err, ErrConflict)}
+	case "delete":
+		metadata, err := f.store.Delete(ctx, command.ID)
+		return &raftResult{Metadata: metadata, NotFound: errors.Is(err, ErrNotFound)}
+	}
+	return &raftResult{}
+}
+
+func (f *raftFSM) Snapshot() (raft.FSMSnapshot, error) {
+	return &raftSnapshot{files: f.store.snapshot()}, nil
+}
+
+func (f *raftFSM) Restore(snapshot io.ReadCloser) error {
+	defer snapshot.Close()
+
+	files := make(map[string]*chunking.FileMetadata)
+	if err := json.NewDecoder(snapshot).Decode(&files); err != nil {
+		return fmt.Errorf("не удалось прочитать снимок каталога: %w", err)
+	}
+	f.store.restore(files)
+	return nil
+}
+
+// raftSnapshot - снимок каталога, который Raft сохраняет для сокращения журнала
+type raftSnapshot struct {
+	files map[string]*chunking.FileMetadata
+}
+
+func (s *raftSnapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s.files); err != nil {
+		sink.Cancel()
+		return fmt.Errorf("не удалось сохранить снимок каталога: %w", err)
+	}
+	return sink.Close()
+}
+
+func (s *raftSnapshot) Release() {}
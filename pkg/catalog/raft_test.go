@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// testSecret - общий ключ, которым узлы кластера подписывают пересылаемые изменения
+const testSecret = "raft-secret"
+
+// freeAddr возвращает свободный локальный адрес для транспорта Raft
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// startCluster запускает кластер из count узлов; NodeID узла - адрес его HTTP сервера
+func startCluster(t *testing.T, count int) []*RaftStore {
+	handlers := make([]http.Handler, count)
+	servers := make([]*httptest.Server, count)
+	raftAddrs := make([]string, count)
+	var peers []string
+
+	for i := range servers {
+		i := i
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(servers[i].Close)
+		raftAddrs[i] = freeAddr(t)
+		peers = append(peers, servers[i].URL+"="+raftAddrs[i])
+	}
+
+	stores := make([]*RaftStore, count)
+	for i := range stores {
+		store, err := NewRaftStore(RaftConfig{
+			NodeID:       servers[i].URL,
+			BindAddr:     raftAddrs[i],
+			Dir:          t.TempDir(),
+			Peers:        peers,
+			ApplyTimeout: 10 * time.Second,
+			Secret:       testSecret,
+		})
+		require.NoError(t, err)
+		handlers[i] = store
+		stores[i] = store
+	}
+	return stores
+}
+
+func TestRaftStoreReplicatesAndSurvivesLeaderLoss(t *testing.T) {
+	stores := startCluster(t, 3)
+	ctx := context.Background()
+
+	leader, err := stores[0].waitLeader(ctx)
+	require.NoError(t, err)
+
+	// Пишем через узел, не являющийся лидером: изменение пересылается лидеру
+	var follower, leaderStore *RaftStore
+	for _, store := range stores {
+		if store.config.NodeID == leader {
+			leaderStore = store
+		} else if follower == nil {
+			follower = store
+		}
+	}
+	require.NoError(t, follower.Put(ctx, &chunking.FileMetadata{ID: "file-1", OriginalName: "a.txt"}))
+
+	// Запись сразу видна на узле, через который выполнена
+	metadata, err := follower.Get(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", metadata.OriginalName)
+
+	// Потеря лидера не приводит к потере каталога
+	require.NoError(t, leaderStore.Close())
+
+	var survivors []*RaftStore
+	for _, store := range stores {
+		if store != leaderStore {
+			survivors = append(survivors, store)
+			t.Cleanup(func() { store.Close() })
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		leader := survivors[0].Leader()
+		return leader != "" && leader != leaderStore.config.NodeID
+	}, 15*time.Second, 100*time.Millisecond)
+
+	require.NoError(t, survivors[0].Put(ctx, &chunking.FileMetadata{ID: "file-2", OriginalName: "b.txt"}))
+	deleted, err := survivors[1].Delete(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", deleted.OriginalName)
+
+	_, err = survivors[1].Delete(ctx, "file-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Остальные узлы применяют изменения асинхронно
+	for i, store := range survivors {
+		assert.Eventually(t, func() bool {
+			files, err := store.List(ctx)
+			return err == nil && len(files) == 1 && files[0].ID == "file-2"
+		}, 5*time.Second, 50*time.Millisecond, fmt.Sprintf("узел %d", i))
+	}
+}
+
+func TestRaftForwardRejectsUnsignedAndInvalidCommands(t *testing.T) {
+	// Изменения принимает отдельный внутренний сервер узла
+	forwardAddr := freeAddr(t)
+	store, err := NewRaftStore(RaftConfig{
+		NodeID:        "http://" + forwardAddr,
+		BindAddr:      freeAddr(t),
+		Dir:           t.TempDir(),
+		Secret:        testSecret,
+		ForwardListen: forwardAddr,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	_, err = store.waitLeader(context.Background())
+	require.NoError(t, err)
+
+	post := func(body string, sign bool) int {
+		req, err := http.NewRequest(http.MethodPost, store.config.NodeID+ForwardPath, strings.NewReader(body))
+		require.NoError(t, err)
+		if sign {
+			require.NoError(t, storage.SignRequest(req, testSecret))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Без подписи изменение не применяется
+	assert.Equal(t, http.StatusUnauthorized, post(`{"op":"put","metadata":{"id":"forged"}}`, false))
+	_, err = store.Get(context.Background(), "forged")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Команды, которые нельзя применить, не попадают в журнал
+	for _, body := range []string{`{"op":"put"}`, `{"op":"put","metadata":{}}`, `{"op":"delete"}`, `{"op":"drop"}`} {
+		assert.Equal(t, http.StatusBadRequest, post(body, true), body)
+	}
+	assert.Equal(t, http.StatusOK, post(`{"op":"put","metadata":{"id":"signed"}}`, true))
+	_, err = store.Get(context.Background(), "signed")
+	assert.NoError(t, err)
+}
+
+func TestRaftFSMSkipsInvalidEntries(t *testing.T) {
+	fsm := &raftFSM{store: NewMemoryStore()}
+	for _, data := range []string{`{"op":"put"}`, `{"op":"put","metadata":{}}`, `{"op":"delete"}`, `not json`} {
+		assert.NotPanics(t, func() {
+			result := fsm.Apply(&raft.Log{Index: 1, Data: []byte(data)}).(*raftResult)
+			assert.Nil(t, result.Metadata)
+		}, data)
+	}
+	files, err := fsm.store.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
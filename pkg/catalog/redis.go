@@ -0,0 +1,134 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"TestCase/pkg/chunking"
+)
+
+// redisCatalogKey - хэш Redis, в котором поле - идентификатор файла, значение - метаданные в JSON
+const redisCatalogKey = "catalog:files"
+
+// redisDeleteScript атомарно читает и удаляет поле, чтобы два сервера не удалили файл дважды
+var redisDeleteScript = redis.NewScript(`
+local value = redis.call('HGET', KEYS[1], ARGV[1])
+if value then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return value
+`)
+
+// redisPutScript атомарно сравнивает поколение сохраненных метаданных с ожидаемым и
+// записывает новые. Возвращает 0, если поколение не совпало
+var redisPutScript = redis.NewScript(`
+local value = redis.call('HGET', KEYS[1], ARGV[1])
+local current = 0
+if value then
+	current = tonumber(cjson.decode(value).generation) or 0
+end
+if current ~= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// RedisStore хранит каталог в хэше Redis.
+// Несколько API серверов с одним Redis видят общий каталог
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore подключается к Redis по адресу вида redis://:password@localhost:6379/0
+func NewRedisStore(ctx context.Context, dsn string) (*RedisStore, error) {
+	options, err := redis.ParseURL(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес Redis: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("не удалось подключиться к Redis: %w", err)
+	}
+
+	return &RedisStore{client: client}, nil
+}
+
+// Get возвращает метаданные файла
+func (r *RedisStore) Get(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	data, err := r.client.HGet(ctx, redisCatalogKey, id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать метаданные файла %s: %w", id, err)
+	}
+	return decodeMetadata(data)
+}
+
+// List возвращает метаданные всех файлов
+func (r *RedisStore) List(ctx context.Context) ([]*chunking.FileMetadata, error) {
+	values, err := r.client.HVals(ctx, redisCatalogKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог: %w", err)
+	}
+
+	files := make([]*chunking.FileMetadata, 0, len(values))
+	for _, value := range values {
+		metadata, err := decodeMetadata([]byte(value))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, metadata)
+	}
+	return files, nil
+}
+
+// Put сохраняет метаданные файла, если их поколение совпадает с сохраненным
+func (r *RedisStore) Put(ctx context.Context, metadata *chunking.FileMetadata) error {
+	next := *metadata
+	next.Generation++
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать метаданные: %w", err)
+	}
+
+	stored, err := redisPutScript.Run(ctx, r.client, []string{redisCatalogKey}, metadata.ID, metadata.Generation, data).Int()
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить метаданные файла %s: %w", metadata.ID, err)
+	}
+	if stored == 0 {
+		return ErrConflict
+	}
+
+	metadata.Generation = next.Generation
+	return nil
+}
+
+// Delete удаляет файл из каталога
+func (r *RedisStore) Delete(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	data, err := redisDeleteScript.Run(ctx, r.client, []string{redisCatalogKey}, id).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось удалить метаданные файла %s: %w", id, err)
+	}
+	return decodeMetadata([]byte(data))
+}
+
+// Ping проверяет доступность Redis
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close закрывает соединения с Redis
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,81 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// SnapshotVersion - версия формата снимка каталога
+const SnapshotVersion = 1
+
+// Snapshot - снимок каталога в формате json. В формате ndjson снимок - это метаданные
+// файлов по одному объекту в строке
+type Snapshot struct {
+	Version    int                      `json:"version"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Files      []*chunking.FileMetadata `json:"files"`
+}
+
+// ReadSnapshot читает снимок каталога: объект json с полем files или метаданные файлов
+// по одному объекту json в строке. У каждого файла должен быть свой идентификатор
+func ReadSnapshot(r io.Reader) ([]*chunking.FileMetadata, error) {
+	var files []*chunking.FileMetadata
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		var probe struct {
+			Version int             `json:"version"`
+			Files   json.RawMessage `json:"files"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return nil, err
+		}
+		if probe.Files != nil {
+			if probe.Version > SnapshotVersion {
+				return nil, fmt.Errorf("версия снимка %d не поддерживается", probe.Version)
+			}
+			var snapshotFiles []*chunking.FileMetadata
+			if err := json.Unmarshal(probe.Files, &snapshotFiles); err != nil {
+				return nil, err
+			}
+			files = append(files, snapshotFiles...)
+			continue
+		}
+
+		var metadata chunking.FileMetadata
+		if err := json.Unmarshal(raw, &metadata); err != nil {
+			return nil, err
+		}
+		files = append(files, &metadata)
+	}
+	if err := ValidateSnapshot(files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ValidateSnapshot проверяет, что у каждого файла снимка есть свой идентификатор. Ее
+// вызывает ReadSnapshot, а снимки в других форматах нужно проверять отдельно
+func ValidateSnapshot(files []*chunking.FileMetadata) error {
+	seen := make(map[string]bool, len(files))
+	for i, metadata := range files {
+		if metadata == nil || metadata.ID == "" {
+			return fmt.Errorf("у файла %d нет идентификатора", i+1)
+		}
+		if seen[metadata.ID] {
+			return fmt.Errorf("файл %s встречается в снимке несколько раз", metadata.ID)
+		}
+		seen[metadata.ID] = true
+	}
+	return nil
+}
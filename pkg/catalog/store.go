@@ -0,0 +1,29 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"TestCase/pkg/chunking"
+)
+
+// ErrNotFound возвращается, если файла нет в каталоге
+var ErrNotFound = errors.New("файл не найден")
+
+// ErrConflict возвращается, если метаданные файла изменены с момента их чтения
+var ErrConflict = errors.New("метаданные файла изменены другим запросом")
+
+// Store хранит каталог метаданных файлов
+type Store interface {
+	// Get возвращает метаданные файла или ErrNotFound
+	Get(ctx context.Context, id string) (*chunking.FileMetadata, error)
+	// List возвращает метаданные всех файлов в произвольном порядке
+	List(ctx context.Context) ([]*chunking.FileMetadata, error)
+	// Put добавляет файл или заменяет метаданные существующего. Поколение metadata должно
+	// совпадать с сохраненным (у нового файла - 0), иначе возвращается ErrConflict. При
+	// успехе поколение metadata увеличивается на единицу
+	Put(ctx context.Context, metadata *chunking.FileMetadata) error
+	// Delete удаляет файл из каталога и возвращает его метаданные или ErrNotFound
+	Delete(ctx context.Context, id string) (*chunking.FileMetadata, error)
+	Close() error
+}
@@ -0,0 +1,97 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+)
+
+// testStore проверяет общее поведение реализаций Store
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	first := &chunking.FileMetadata{ID: "file-1", OriginalName: "a.txt", Size: 3, Chunks: []chunking.FileChunk{{ID: "file-1_0", Node: "node1:8081"}}}
+	require.NoError(t, store.Put(ctx, first))
+	require.NoError(t, store.Put(ctx, &chunking.FileMetadata{ID: "file-2", OriginalName: "b.txt"}))
+
+	stored, err := store.Get(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, first, stored)
+
+	assert.EqualValues(t, 1, first.Generation)
+
+	renamed := *first
+	renamed.OriginalName = "c.txt"
+	require.NoError(t, store.Put(ctx, &renamed))
+	stored, err = store.Get(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "c.txt", stored.OriginalName)
+	assert.EqualValues(t, 2, stored.Generation)
+
+	// Запись, основанная на устаревшем поколении, отклоняется и не изменяет каталог
+	stale := *first
+	stale.OriginalName = "stale.txt"
+	assert.ErrorIs(t, store.Put(ctx, &stale), ErrConflict)
+	assert.EqualValues(t, 1, stale.Generation)
+	assert.ErrorIs(t, store.Put(ctx, &chunking.FileMetadata{ID: "file-1"}), ErrConflict)
+	assert.ErrorIs(t, store.Put(ctx, &chunking.FileMetadata{ID: "file-3", Generation: 1}), ErrConflict)
+	stored, err = store.Get(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "c.txt", stored.OriginalName)
+
+	files, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	deleted, err := store.Delete(ctx, "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "c.txt", deleted.OriginalName)
+	_, err = store.Delete(ctx, "file-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	files, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	store, err := NewRedisStore(context.Background(), "redis://"+server.Addr()+"/0")
+	require.NoError(t, err)
+	defer store.Close()
+
+	testStore(t, store)
+}
+
+// TestPostgresStore требует запущенный PostgreSQL, например
+// CATALOG_TEST_POSTGRES_DSN=postgres://postgres@localhost:5432/postgres?sslmode=disable
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("CATALOG_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CATALOG_TEST_POSTGRES_DSN не задан")
+	}
+
+	ctx := context.Background()
+	store, err := NewPostgresStore(ctx, dsn)
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.db.ExecContext(ctx, `TRUNCATE file_catalog`)
+	require.NoError(t, err)
+
+	testStore(t, store)
+}
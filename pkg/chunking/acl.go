@@ -0,0 +1,14 @@
+package chunking
+
+// Permission - право доступа к файлу, выданное его владельцем другому клиенту API
+type Permission string
+
+const (
+	PermissionRead      Permission = "read"       // скачивание и просмотр метаданных
+	PermissionReadWrite Permission = "read-write" // также удаление файла и изменение его видимости
+)
+
+// Valid сообщает, известно ли право доступа
+func (p Permission) Valid() bool {
+	return p == PermissionRead || p == PermissionReadWrite
+}
@@ -1,20 +1,46 @@
 package chunking
 
 import (
-	"crypto/sha256"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 // FileChunk представляет один кусок файла
 type FileChunk struct {
-	ID       string `json:"id"`       // уникальный идентификатор куска
-	Index    int    `json:"index"`    // номер куска (0-5)
-	FileID   string `json:"file_id"`  // идентификатор исходного файла
-	Size     int64  `json:"size"`     // размер куска в байтах
-	Checksum string `json:"checksum"` // контрольная сумма куска
-	Data     []byte `json:"data"`     // данные куска
+	ID       string `json:"id"`             // случайный идентификатор куска, см. NewChunkID
+	Index    int    `json:"index"`          // номер куска (0-5)
+	FileID   string `json:"file_id"`        // идентификатор исходного файла
+	Size     int64  `json:"size"`           // размер куска в байтах
+	Checksum string `json:"checksum"`       // контрольная сумма куска
+	Data     []byte `json:"data,omitempty"` // данные куска (в метаданных файла не хранятся)
+
+	Algorithm HashAlgorithm `json:"algorithm,omitempty"` // алгоритм контрольной суммы (пусто - sha256)
+	Node      string        `json:"node,omitempty"`      // адрес сервера хранения, выбранный при загрузке
+	Replicas  []string      `json:"replicas,omitempty"`  // серверы хранения с копиями куска, кроме Node
+	RefCount  int           `json:"ref_count,omitempty"` // число файлов, ссылающихся на кусок на сервере хранения
+}
+
+// NewChunkID возвращает случайный идентификатор куска из 128 бит. Идентификатор не
+// выводится из идентификатора файла, поэтому по известному файлу нельзя подобрать его
+// куски на серверах хранения: куски файла известны только из его метаданных
+func NewChunkID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprintf("не удалось получить случайные данные: %v", err))
+	}
+	return hex.EncodeToString(id)
+}
+
+// Nodes возвращает все серверы хранения с копиями куска, начиная с основного
+func (c FileChunk) Nodes() []string {
+	if c.Node == "" {
+		return append([]string(nil), c.Replicas...)
+	}
+	return append([]string{c.Node}, c.Replicas...)
 }
 
 // FileMetadata содержит метаданные файла
@@ -25,11 +51,76 @@ type FileMetadata struct {
 	Checksum     string      `json:"checksum"`      // контрольная сумма файла
 	ChunkCount   int         `json:"chunk_count"`   // количество кусков
 	Chunks       []FileChunk `json:"chunks"`        // информация о кусках
-	ContentType  string      `json:"content_type"`  // MIME тип файла
+	ContentType  string      `json:"content_type"`  // MIME тип файла, заявленный клиентом
+
+	ChecksumAlgorithm HashAlgorithm `json:"checksum_algorithm,omitempty"` // алгоритм контрольных сумм
+
+	// MerkleRoot - корень Merkle дерева над контрольными суммами кусков (см. MerkleRoot). По
+	// нему клиент проверяет отдельные куски и диапазоны без скачивания всего файла
+	MerkleRoot string `json:"merkle_root,omitempty"`
+
+	// Политика хранения, выбранная клиентом при загрузке: число копий кусков файла (0 -
+	// replication_factor сервера) и класс хранения из storage_classes сервера
+	ReplicationFactor int    `json:"replication_factor,omitempty"`
+	StorageClass      string `json:"storage_class,omitempty"`
+
+	DetectedContentType string    `json:"detected_content_type,omitempty"` // MIME тип, определенный по содержимому
+	Path                string    `json:"path,omitempty"`                  // логический путь файла (например, bucket/key)
+	CreatedAt           time.Time `json:"created_at"`                      // время загрузки файла
+
+	// ModifiedAt - время последней замены содержимого файла; nil - содержимое не заменялось
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+
+	// Generation - поколение метаданных. Каталог увеличивает его при каждом сохранении
+	// и отклоняет запись, основанную на устаревшем поколении
+	Generation int64 `json:"generation"`
+
+	// Public - файл доступен для скачивания по публичной ссылке без ключа API
+	Public bool `json:"public,omitempty"`
+
+	// Владелец файла и права, выданные им другим клиентам API. Клиенты определяются по
+	// именам ключей API; у файла без владельца права не проверяются
+	Owner  string                `json:"owner,omitempty"`
+	Grants map[string]Permission `json:"grants,omitempty"`
+
+	// Ящик для приема файлов, через который файл загружен без ключа API
+	DropBox string `json:"drop_box,omitempty"`
+
+	// Статистика обращений к файлу. API сервер накапливает ее в памяти и записывает
+	// в каталог периодически, поэтому в каталоге она может немного отставать
+	DownloadCount  int64      `json:"download_count"`             // число скачиваний файла целиком или с начала
+	BytesServed    int64      `json:"bytes_served"`               // отданные клиентам байты, включая диапазоны
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"` // время последнего скачивания; nil - не скачивался
+}
+
+// LastActivity возвращает время последнего скачивания, а для нескачанного файла - время загрузки
+func (m *FileMetadata) LastActivity() time.Time {
+	if m.LastAccessedAt != nil {
+		return *m.LastAccessedAt
+	}
+	return m.CreatedAt
+}
+
+// LastModified возвращает время последней замены содержимого, а для незамененного файла - время загрузки
+func (m *FileMetadata) LastModified() time.Time {
+	if m.ModifiedAt != nil {
+		return *m.ModifiedAt
+	}
+	return m.CreatedAt
 }
 
 // ChunkFile разделяет файл на заданное количество частей
 func ChunkFile(filePath string, chunkCount int, fileID string) (*FileMetadata, error) {
+	return ChunkFileWithAlgorithm(filePath, chunkCount, fileID, DefaultHashAlgorithm)
+}
+
+// ChunkFileWithAlgorithm разделяет файл на части, вычисляя контрольные суммы указанным алгоритмом
+func ChunkFileWithAlgorithm(filePath string, chunkCount int, fileID string, algorithm HashAlgorithm) (*FileMetadata, error) {
+	hasher, err := NewHasher(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
@@ -48,7 +139,6 @@ func ChunkFile(filePath string, chunkCount int, fileID string) (*FileMetadata, e
 
 	// Вычисляем контрольную сумму всего файла
 	file.Seek(0, 0)
-	hasher := sha256.New()
 	if _, err := io.Copy(hasher, file); err != nil {
 		return nil, fmt.Errorf("не удалось вычислить контрольную сумму файла: %w", err)
 	}
@@ -61,6 +151,8 @@ func ChunkFile(filePath string, chunkCount int, fileID string) (*FileMetadata, e
 		Checksum:     fileChecksum,
 		ChunkCount:   chunkCount,
 		Chunks:       make([]FileChunk, chunkCount),
+
+		ChecksumAlgorithm: algorithm,
 	}
 
 	// Разделяем файл на куски
@@ -79,17 +171,20 @@ func ChunkFile(filePath string, chunkCount int, fileID string) (*FileMetadata, e
 		}
 
 		// Вычисляем контрольную сумму куска
-		chunkHasher := sha256.New()
-		chunkHasher.Write(chunkData)
-		chunkChecksum := fmt.Sprintf("%x", chunkHasher.Sum(nil))
+		chunkChecksum, err := Checksum(algorithm, chunkData)
+		if err != nil {
+			return nil, err
+		}
 
 		chunk := FileChunk{
-			ID:       fmt.Sprintf("%s_chunk_%d", fileID, i),
+			ID:       NewChunkID(),
 			Index:    i,
 			FileID:   fileID,
 			Size:     currentChunkSize,
 			Checksum: chunkChecksum,
 			Data:     chunkData,
+
+			Algorithm: algorithm,
 		}
 
 		metadata.Chunks[i] = chunk
@@ -147,10 +242,11 @@ func ValidateChunk(chunk *FileChunk) error {
 		return fmt.Errorf("размер данных не соответствует заявленному размеру")
 	}
 
-	// Проверяем контрольную сумму
-	hasher := sha256.New()
-	hasher.Write(chunk.Data)
-	checksum := fmt.Sprintf("%x", hasher.Sum(nil))
+	// Проверяем контрольную сумму алгоритмом, которым она была вычислена
+	checksum, err := Checksum(chunk.Algorithm, chunk.Data)
+	if err != nil {
+		return err
+	}
 
 	if checksum != chunk.Checksum {
 		return fmt.Errorf("контрольная сумма куска не совпадает")
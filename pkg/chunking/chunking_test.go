@@ -34,7 +34,7 @@ func TestChunkFile(t *testing.T) {
 	for _, chunk := range metadata.Chunks {
 		totalSize += chunk.Size
 		assert.Equal(t, fileID, chunk.FileID)
-		assert.NotEmpty(t, chunk.ID)
+		assert.NotContains(t, chunk.ID, fileID) // по идентификатору файла кусок не найти
 		assert.NotEmpty(t, chunk.Checksum)
 		assert.NotNil(t, chunk.Data)
 	}
@@ -51,6 +51,16 @@ func TestChunkFile(t *testing.T) {
 	}
 }
 
+func TestNewChunkID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := NewChunkID()
+		assert.Regexp(t, `^[0-9a-f]{32}$`, id)
+		assert.False(t, seen[id], "повтор идентификатора %s", id)
+		seen[id] = true
+	}
+}
+
 func TestReconstructFile(t *testing.T) {
 	// Создаем временный файл для тестирования
 	tempDir := t.TempDir()
@@ -203,3 +213,41 @@ func createTempFile(t *testing.T, data []byte) string {
 	require.NoError(t, err)
 	return tempFile
 }
+
+func TestChunkFileWithAlgorithms(t *testing.T) {
+	tempFile := createTempFile(t, []byte("data for pluggable checksum algorithms"))
+
+	for _, algorithm := range []HashAlgorithm{HashSHA256, HashBLAKE3, HashXXHash} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			metadata, err := ChunkFileWithAlgorithm(tempFile, 3, "test-file", algorithm)
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, metadata.ChecksumAlgorithm)
+
+			for _, chunk := range metadata.Chunks {
+				assert.Equal(t, algorithm, chunk.Algorithm)
+				assert.NoError(t, ValidateChunk(&chunk))
+			}
+
+			// Кусок с подмененным алгоритмом не должен проходить проверку
+			tampered := metadata.Chunks[0]
+			tampered.Algorithm = HashSHA256
+			if algorithm != HashSHA256 {
+				assert.Error(t, ValidateChunk(&tampered))
+			}
+		})
+	}
+
+	_, err := ParseHashAlgorithm("md5")
+	assert.Error(t, err)
+}
+
+func TestFileChunkNodes(t *testing.T) {
+	chunk := FileChunk{Node: "node1:8081", Replicas: []string{"node2:8081", "node3:8081"}}
+	assert.Equal(t, []string{"node1:8081", "node2:8081", "node3:8081"}, chunk.Nodes())
+
+	// Изменение результата не затрагивает копии куска
+	chunk.Nodes()[1] = "other"
+	assert.Equal(t, "node2:8081", chunk.Replicas[0])
+
+	assert.Empty(t, FileChunk{}.Nodes())
+}
@@ -0,0 +1,124 @@
+package chunking
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Signature - контрольные суммы кусков файла. По ней клиент находит куски, которые
+// не изменились в новой версии файла, и передает серверу только остальные данные
+type Signature struct {
+	FileID            string           `json:"file_id"`
+	Size              int64            `json:"size"`
+	Checksum          string           `json:"checksum"`
+	ChecksumAlgorithm HashAlgorithm    `json:"checksum_algorithm,omitempty"`
+	Chunks            []SignatureChunk `json:"chunks"` // куски в порядке следования в файле
+}
+
+// SignatureChunk - расположение и контрольная сумма куска файла
+type SignatureChunk struct {
+	Index     int           `json:"index"`
+	Offset    int64         `json:"offset"` // смещение куска от начала файла
+	Size      int64         `json:"size"`
+	Checksum  string        `json:"checksum"`
+	Algorithm HashAlgorithm `json:"algorithm,omitempty"`
+}
+
+// DeltaSegment - часть новой версии файла: кусок базового файла без изменений
+// или новые данные, которые передаются в теле запроса по порядку
+type DeltaSegment struct {
+	Base *int  `json:"base,omitempty"` // индекс куска базового файла
+	Size int64 `json:"size,omitempty"` // размер новых данных; для куска базового файла не указывается
+}
+
+// NewSignature строит сигнатуру по метаданным файла
+func NewSignature(metadata *FileMetadata) *Signature {
+	signature := &Signature{
+		FileID:            metadata.ID,
+		Size:              metadata.Size,
+		Checksum:          metadata.Checksum,
+		ChecksumAlgorithm: metadata.ChecksumAlgorithm,
+		Chunks:            make([]SignatureChunk, len(metadata.Chunks)),
+	}
+
+	var offset int64
+	for i, chunk := range metadata.Chunks {
+		signature.Chunks[i] = SignatureChunk{
+			Index:     i,
+			Offset:    offset,
+			Size:      chunk.Size,
+			Checksum:  chunk.Checksum,
+			Algorithm: chunk.Algorithm,
+		}
+		offset += chunk.Size
+	}
+	return signature
+}
+
+// Diff описывает новую версию файла размером size относительно файла с сигнатурой
+// signature. Кусок базового файла ищется на прежнем месте и на месте, сдвинутом на
+// изменение размера файла, поэтому находятся неизмененные куски до и после вставки,
+// удаления или правки в одном месте файла, в том числе при дописывании в конец
+func Diff(signature *Signature, r io.ReaderAt, size int64) ([]DeltaSegment, error) {
+	type match struct {
+		base   int
+		offset int64
+		size   int64
+	}
+
+	var matches []match
+	shift := size - signature.Size
+	for i, chunk := range signature.Chunks {
+		if chunk.Size == 0 {
+			continue
+		}
+		for _, offset := range []int64{chunk.Offset, chunk.Offset + shift} {
+			if offset < 0 || offset+chunk.Size > size {
+				continue
+			}
+			same, err := sameChunk(r, offset, chunk)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				matches = append(matches, match{base: i, offset: offset, size: chunk.Size})
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].offset < matches[j].offset })
+
+	var segments []DeltaSegment
+	var position int64
+	for _, m := range matches {
+		// Совпадения не должны перекрываться: кусок, пересекающийся с предыдущим, передается заново
+		if m.offset < position {
+			continue
+		}
+		if m.offset > position {
+			segments = append(segments, DeltaSegment{Size: m.offset - position})
+		}
+		base := m.base
+		segments = append(segments, DeltaSegment{Base: &base})
+		position = m.offset + m.size
+	}
+	if position < size {
+		segments = append(segments, DeltaSegment{Size: size - position})
+	}
+
+	return segments, nil
+}
+
+// sameChunk сообщает, совпадают ли данные по смещению offset с куском базового файла
+func sameChunk(r io.ReaderAt, offset int64, chunk SignatureChunk) (bool, error) {
+	hasher, err := NewHasher(chunk.Algorithm)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(hasher, io.NewSectionReader(r, offset, chunk.Size)); err != nil {
+		return false, fmt.Errorf("не удалось прочитать данные: %w", err)
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)) == chunk.Checksum, nil
+}
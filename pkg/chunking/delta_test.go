@@ -0,0 +1,54 @@
+package chunking
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	base := []byte("aaaaaaaaaabbbbbbbbbbccccccccccddddddddddeeeeeeeeeeffffffffff")
+	path := filepath.Join(t.TempDir(), "base.txt")
+	require.NoError(t, os.WriteFile(path, base, 0o644))
+	metadata, err := ChunkFile(path, 6, "base")
+	require.NoError(t, err)
+	signature := NewSignature(metadata)
+
+	// describe сводит сегменты к строке: цифра - кусок базового файла, +N - новые данные
+	describe := func(segments []DeltaSegment) string {
+		parts := make([]string, 0, len(segments))
+		for _, segment := range segments {
+			if segment.Base != nil {
+				parts = append(parts, string(rune('0'+*segment.Base)))
+			} else {
+				parts = append(parts, "+"+strings.Repeat("#", int(segment.Size)))
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	cases := []struct {
+		name    string
+		updated string
+		want    string
+	}{
+		{"без изменений", string(base), "0 1 2 3 4 5"},
+		{"дописывание", string(base) + "gg", "0 1 2 3 4 5 +##"},
+		{"правка на месте", strings.Replace(string(base), "cccc", "CCCC", 1), "0 1 +########## 3 4 5"},
+		{"вставка", strings.Replace(string(base), "cccccccccc", "cccccXYccccc", 1), "0 1 +############ 3 4 5"},
+		{"вставка на границе кусков", strings.Replace(string(base), "cd", "cXYd", 1), "0 1 2 +## 3 4 5"},
+		{"удаление в начале", string(base[3:]), "+####### 1 2 3 4 5"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			segments, err := Diff(signature, bytes.NewReader([]byte(tc.updated)), int64(len(tc.updated)))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, describe(segments))
+		})
+	}
+}
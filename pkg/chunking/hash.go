@@ -0,0 +1,61 @@
+package chunking
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// HashAlgorithm определяет алгоритм вычисления контрольных сумм
+type HashAlgorithm string
+
+const (
+	HashSHA256 HashAlgorithm = "sha256" // криптостойкий, используется по умолчанию
+	HashBLAKE3 HashAlgorithm = "blake3" // криптостойкий и значительно быстрее SHA256
+	HashXXHash HashAlgorithm = "xxhash" // некриптографический, только для защиты от случайных повреждений
+
+	// DefaultHashAlgorithm используется, если алгоритм не указан
+	DefaultHashAlgorithm = HashSHA256
+)
+
+// ParseHashAlgorithm разбирает название алгоритма контрольных сумм
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	algorithm := HashAlgorithm(name)
+	switch algorithm {
+	case "":
+		return DefaultHashAlgorithm, nil
+	case HashSHA256, HashBLAKE3, HashXXHash:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("неизвестный алгоритм контрольных сумм: %s", name)
+	}
+}
+
+// NewHasher создает hash.Hash для указанного алгоритма.
+// Пустое значение соответствует SHA256 для совместимости со старыми метаданными
+func NewHasher(algorithm HashAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case "", HashSHA256:
+		return sha256.New(), nil
+	case HashBLAKE3:
+		return blake3.New(32, nil), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf("неизвестный алгоритм контрольных сумм: %s", algorithm)
+	}
+}
+
+// Checksum вычисляет контрольную сумму данных в шестнадцатеричном виде
+func Checksum(algorithm HashAlgorithm, data []byte) (string, error) {
+	hasher, err := NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	hasher.Write(data)
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
@@ -0,0 +1,57 @@
+package chunking
+
+// Manifest описывает расположение кусков файла для скачивания напрямую с серверов хранения
+type Manifest struct {
+	FileID            string          `json:"file_id"`
+	Name              string          `json:"name"`
+	Size              int64           `json:"size"`
+	Checksum          string          `json:"checksum"`
+	ChecksumAlgorithm HashAlgorithm   `json:"checksum_algorithm,omitempty"`
+	Chunks            []ManifestChunk `json:"chunks"` // куски в порядке следования в файле
+}
+
+// ManifestChunk - кусок файла и сервер хранения, с которого его можно получить
+type ManifestChunk struct {
+	ID         string        `json:"id"`
+	Index      int           `json:"index"`
+	Offset     int64         `json:"offset"` // смещение куска от начала файла
+	Size       int64         `json:"size"`
+	Checksum   string        `json:"checksum"`
+	Algorithm  HashAlgorithm `json:"algorithm,omitempty"`
+	Node       string        `json:"node"`              // адрес сервера хранения
+	StorageURL string        `json:"storage_url"`       // базовый URL API сервера хранения
+	Mirrors    []string      `json:"mirrors,omitempty"` // базовые URL серверов хранения с копиями куска
+	Token      string        `json:"token,omitempty"`   // токен чтения куска, если серверы хранения требуют подписи запросов
+}
+
+// NewManifest строит манифест по метаданным файла. storageURL возвращает базовый URL
+// API сервера хранения по его адресу
+func NewManifest(metadata *FileMetadata, storageURL func(node string) string) *Manifest {
+	manifest := &Manifest{
+		FileID:            metadata.ID,
+		Name:              metadata.OriginalName,
+		Size:              metadata.Size,
+		Checksum:          metadata.Checksum,
+		ChecksumAlgorithm: metadata.ChecksumAlgorithm,
+		Chunks:            make([]ManifestChunk, len(metadata.Chunks)),
+	}
+
+	var offset int64
+	for i, chunk := range metadata.Chunks {
+		manifest.Chunks[i] = ManifestChunk{
+			ID:         chunk.ID,
+			Index:      chunk.Index,
+			Offset:     offset,
+			Size:       chunk.Size,
+			Checksum:   chunk.Checksum,
+			Algorithm:  chunk.Algorithm,
+			Node:       chunk.Node,
+			StorageURL: storageURL(chunk.Node),
+		}
+		for _, replica := range chunk.Replicas {
+			manifest.Chunks[i].Mirrors = append(manifest.Chunks[i].Mirrors, storageURL(replica))
+		}
+		offset += chunk.Size
+	}
+	return manifest
+}
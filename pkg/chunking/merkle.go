@@ -0,0 +1,230 @@
+package chunking
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Merkle дерево строится над контрольными суммами кусков файла по RFC 9162 (раздел 2.1):
+// листья - контрольные суммы кусков в шестнадцатеричном виде в порядке следования, узлы
+// хешируются алгоритмом контрольной суммы файла. По корню дерева и пути доказательства
+// можно проверить любой кусок, не получая остальные
+
+const (
+	merkleLeafPrefix = 0x00 // префикс хеша листа
+	merkleNodePrefix = 0x01 // префикс хеша внутреннего узла
+)
+
+// ErrMerkleMismatch возвращается, если кусок не сходится с корнем Merkle дерева файла
+var ErrMerkleMismatch = errors.New("кусок не соответствует корню Merkle дерева файла")
+
+// MerkleProof - корень Merkle дерева файла и пути доказательства для части его кусков
+type MerkleProof struct {
+	FileID            string        `json:"file_id"`
+	Size              int64         `json:"size"`
+	ChecksumAlgorithm HashAlgorithm `json:"checksum_algorithm,omitempty"` // алгоритм узлов дерева
+	Root              string        `json:"root"`
+	ChunkCount        int           `json:"chunk_count"`
+	Chunks            []MerkleChunk `json:"chunks"` // запрошенные куски по порядку
+}
+
+// MerkleChunk - расположение куска файла и путь доказательства от его листа до корня
+type MerkleChunk struct {
+	SignatureChunk
+	Path []string `json:"path"` // хеши соседних поддеревьев от листа к корню
+}
+
+// merkleHash хеширует префикс и части алгоритмом algorithm
+func merkleHash(algorithm HashAlgorithm, prefix byte, parts ...[]byte) ([]byte, error) {
+	hasher, err := NewHasher(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	hasher.Write([]byte{prefix})
+	for _, part := range parts {
+		hasher.Write(part)
+	}
+	return hasher.Sum(nil), nil
+}
+
+// merkleSplit возвращает наибольшую степень двойки, меньшую n (n > 1)
+func merkleSplit(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// merkleTreeHash вычисляет корень дерева над листьями leaves
+func merkleTreeHash(algorithm HashAlgorithm, leaves []string) ([]byte, error) {
+	switch len(leaves) {
+	case 0:
+		hasher, err := NewHasher(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		return hasher.Sum(nil), nil
+	case 1:
+		return merkleHash(algorithm, merkleLeafPrefix, []byte(leaves[0]))
+	}
+
+	k := merkleSplit(len(leaves))
+	left, err := merkleTreeHash(algorithm, leaves[:k])
+	if err != nil {
+		return nil, err
+	}
+	right, err := merkleTreeHash(algorithm, leaves[k:])
+	if err != nil {
+		return nil, err
+	}
+	return merkleHash(algorithm, merkleNodePrefix, left, right)
+}
+
+// MerkleRoot вычисляет корень Merkle дерева над контрольными суммами кусков checksums
+func MerkleRoot(algorithm HashAlgorithm, checksums []string) (string, error) {
+	root, err := merkleTreeHash(algorithm, checksums)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(root), nil
+}
+
+// MerklePath возвращает путь доказательства для куска index: хеши соседних поддеревьев
+// от листа к корню
+func MerklePath(algorithm HashAlgorithm, checksums []string, index int) ([]string, error) {
+	if index < 0 || index >= len(checksums) {
+		return nil, fmt.Errorf("куска %d нет в файле из %d кусков", index, len(checksums))
+	}
+
+	var path []string
+	for len(checksums) > 1 {
+		k := merkleSplit(len(checksums))
+		sibling := checksums[k:]
+		if index >= k {
+			sibling = checksums[:k]
+		}
+		hash, err := merkleTreeHash(algorithm, sibling)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, hex.EncodeToString(hash))
+
+		if index < k {
+			checksums = checksums[:k]
+		} else {
+			checksums, index = checksums[k:], index-k
+		}
+	}
+
+	// Путь собран от корня к листу, а проверяется от листа
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// VerifyMerklePath проверяет, что кусок index с контрольной суммой checksum входит в файл
+// из count кусков с корнем root. Возвращает ErrMerkleMismatch, если путь не сходится
+func VerifyMerklePath(algorithm HashAlgorithm, root, checksum string, index, count int, path []string) error {
+	if index < 0 || index >= count {
+		return fmt.Errorf("%w: куска %d нет в файле из %d кусков", ErrMerkleMismatch, index, count)
+	}
+	expected, err := hex.DecodeString(root)
+	if err != nil {
+		return fmt.Errorf("неверный корень Merkle дерева: %w", err)
+	}
+
+	hash, err := merkleHash(algorithm, merkleLeafPrefix, []byte(checksum))
+	if err != nil {
+		return err
+	}
+	fn, sn := index, count-1
+	for _, encoded := range path {
+		sibling, err := hex.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("неверный хеш в пути доказательства: %w", err)
+		}
+		if sn == 0 {
+			return fmt.Errorf("%w: путь длиннее высоты дерева", ErrMerkleMismatch)
+		}
+
+		if fn%2 == 1 || fn == sn {
+			hash, err = merkleHash(algorithm, merkleNodePrefix, sibling, hash)
+			for fn%2 == 0 && fn != 0 {
+				fn, sn = fn/2, sn/2
+			}
+		} else {
+			hash, err = merkleHash(algorithm, merkleNodePrefix, hash, sibling)
+		}
+		if err != nil {
+			return err
+		}
+		fn, sn = fn/2, sn/2
+	}
+
+	if sn != 0 || !bytes.Equal(hash, expected) {
+		return ErrMerkleMismatch
+	}
+	return nil
+}
+
+// FileMerkleRoot вычисляет корень Merkle дерева над кусками файла
+func FileMerkleRoot(metadata *FileMetadata) (string, error) {
+	return MerkleRoot(metadata.ChecksumAlgorithm, chunkChecksums(metadata))
+}
+
+// chunkChecksums возвращает контрольные суммы кусков файла по порядку
+func chunkChecksums(metadata *FileMetadata) []string {
+	checksums := make([]string, len(metadata.Chunks))
+	for i, chunk := range metadata.Chunks {
+		checksums[i] = chunk.Checksum
+	}
+	return checksums
+}
+
+// NewMerkleProof строит корень Merkle дерева файла и пути доказательства для кусков indexes
+func NewMerkleProof(metadata *FileMetadata, indexes []int) (*MerkleProof, error) {
+	checksums := chunkChecksums(metadata)
+	root, err := MerkleRoot(metadata.ChecksumAlgorithm, checksums)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &MerkleProof{
+		FileID:            metadata.ID,
+		Size:              metadata.Size,
+		ChecksumAlgorithm: metadata.ChecksumAlgorithm,
+		Root:              root,
+		ChunkCount:        len(checksums),
+		Chunks:            make([]MerkleChunk, 0, len(indexes)),
+	}
+	signature := NewSignature(metadata)
+	for _, index := range indexes {
+		path, err := MerklePath(metadata.ChecksumAlgorithm, checksums, index)
+		if err != nil {
+			return nil, err
+		}
+		proof.Chunks = append(proof.Chunks, MerkleChunk{SignatureChunk: signature.Chunks[index], Path: path})
+	}
+	return proof, nil
+}
+
+// VerifyChunk проверяет данные куска chunk из доказательства: их контрольную сумму и путь
+// от куска до корня root. root, как и число кусков доказательства, нужно сверять с
+// доверенным источником, например с метаданными файла, а не брать из того же ответа
+func (p *MerkleProof) VerifyChunk(root string, chunk MerkleChunk, data []byte) error {
+	if int64(len(data)) != chunk.Size {
+		return fmt.Errorf("%w: размер куска %d - %d байт, ожидалось %d", ErrMerkleMismatch, chunk.Index, len(data), chunk.Size)
+	}
+	checksum, err := Checksum(chunk.Algorithm, data)
+	if err != nil {
+		return err
+	}
+	if checksum != chunk.Checksum {
+		return fmt.Errorf("%w: контрольная сумма куска %d не совпадает", ErrMerkleMismatch, chunk.Index)
+	}
+	return VerifyMerklePath(p.ChecksumAlgorithm, root, chunk.Checksum, chunk.Index, p.ChunkCount, chunk.Path)
+}
@@ -0,0 +1,88 @@
+package chunking
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Контрольные значения из тестов Certificate Transparency (RFC 6962)
+func TestMerkleRootReferenceVectors(t *testing.T) {
+	var leaves []string
+	for _, encoded := range []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"} {
+		leaf, err := hex.DecodeString(encoded)
+		require.NoError(t, err)
+		leaves = append(leaves, string(leaf))
+	}
+
+	root, err := MerkleRoot(HashSHA256, leaves[:1])
+	require.NoError(t, err)
+	assert.Equal(t, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d", root)
+
+	root, err = MerkleRoot(HashSHA256, leaves)
+	require.NoError(t, err)
+	assert.Equal(t, "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328", root)
+
+	root, err = MerkleRoot(HashSHA256, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", root)
+}
+
+func TestMerklePathVerifies(t *testing.T) {
+	for _, algorithm := range []HashAlgorithm{HashSHA256, HashBLAKE3, HashXXHash} {
+		for count := 1; count <= 9; count++ {
+			checksums := make([]string, count)
+			for i := range checksums {
+				checksums[i] = fmt.Sprintf("chunk-%d", i)
+			}
+			root, err := MerkleRoot(algorithm, checksums)
+			require.NoError(t, err)
+
+			for index := range checksums {
+				path, err := MerklePath(algorithm, checksums, index)
+				require.NoError(t, err)
+				assert.NoError(t, VerifyMerklePath(algorithm, root, checksums[index], index, count, path), "%s: %d из %d", algorithm, index, count)
+
+				// Другой кусок или другое место в файле не сходятся с корнем
+				assert.ErrorIs(t, VerifyMerklePath(algorithm, root, "forged", index, count, path), ErrMerkleMismatch)
+				if count > 1 {
+					other := (index + 1) % count
+					assert.ErrorIs(t, VerifyMerklePath(algorithm, root, checksums[index], other, count, path), ErrMerkleMismatch)
+				}
+			}
+		}
+	}
+
+	_, err := MerklePath(HashSHA256, []string{"a"}, 1)
+	assert.Error(t, err)
+}
+
+func TestMerkleProofVerifyChunk(t *testing.T) {
+	data := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+	metadata := &FileMetadata{ID: "file-1", Size: 16, ChecksumAlgorithm: HashBLAKE3}
+	for i, part := range data {
+		checksum, err := Checksum(HashSHA256, part)
+		require.NoError(t, err)
+		metadata.Chunks = append(metadata.Chunks, FileChunk{Index: i, Size: int64(len(part)), Checksum: checksum, Algorithm: HashSHA256})
+	}
+	root, err := FileMerkleRoot(metadata)
+	require.NoError(t, err)
+
+	proof, err := NewMerkleProof(metadata, []int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, root, proof.Root)
+	assert.Equal(t, 3, proof.ChunkCount)
+	require.Len(t, proof.Chunks, 2)
+	assert.Equal(t, int64(5), proof.Chunks[0].Offset)
+
+	assert.NoError(t, proof.VerifyChunk(root, proof.Chunks[0], data[1]))
+	assert.NoError(t, proof.VerifyChunk(root, proof.Chunks[1], data[2]))
+	assert.ErrorIs(t, proof.VerifyChunk(root, proof.Chunks[0], []byte("SECOND")), ErrMerkleMismatch)
+	assert.ErrorIs(t, proof.VerifyChunk(root, proof.Chunks[1], data[1]), ErrMerkleMismatch)
+
+	_, err = NewMerkleProof(metadata, []int{3})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"TestCase/pkg/chunking"
+)
+
+// FileACL - владелец файла и права доступа, выданные им другим клиентам API
+type FileACL struct {
+	ID     string                         `json:"id"`
+	Owner  string                         `json:"owner,omitempty"`
+	Grants map[string]chunking.Permission `json:"grants"` // имя ключа API -> право
+}
+
+// GetFileACL возвращает права доступа к файлу. Доступно только владельцу файла
+func (ac *APIClient) GetFileACL(fileID string) (*FileACL, error) {
+	return ac.GetFileACLContext(context.Background(), fileID)
+}
+
+// GetFileACLContext возвращает права доступа к файлу с учетом контекста
+func (ac *APIClient) GetFileACLContext(ctx context.Context, fileID string) (*FileACL, error) {
+	var acl FileACL
+	if err := ac.sendJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v1/files/%s/acl", fileID), nil, &acl); err != nil {
+		return nil, err
+	}
+	return &acl, nil
+}
+
+// GrantFileAccess выдает клиенту API с именем ключа principal право на файл
+func (ac *APIClient) GrantFileAccess(fileID, principal string, permission chunking.Permission) (*FileACL, error) {
+	return ac.GrantFileAccessContext(context.Background(), fileID, principal, permission)
+}
+
+// GrantFileAccessContext выдает право на файл с учетом контекста
+func (ac *APIClient) GrantFileAccessContext(ctx context.Context, fileID, principal string, permission chunking.Permission) (*FileACL, error) {
+	var acl FileACL
+	request := map[string]chunking.Permission{"permission": permission}
+	if err := ac.sendJSON(ctx, http.MethodPut, aclPath(fileID, principal), request, &acl); err != nil {
+		return nil, err
+	}
+	return &acl, nil
+}
+
+// RevokeFileAccess отзывает право клиента API на файл
+func (ac *APIClient) RevokeFileAccess(fileID, principal string) (*FileACL, error) {
+	return ac.RevokeFileAccessContext(context.Background(), fileID, principal)
+}
+
+// RevokeFileAccessContext отзывает право на файл с учетом контекста
+func (ac *APIClient) RevokeFileAccessContext(ctx context.Context, fileID, principal string) (*FileACL, error) {
+	var acl FileACL
+	if err := ac.sendJSON(ctx, http.MethodDelete, aclPath(fileID, principal), nil, &acl); err != nil {
+		return nil, err
+	}
+	return &acl, nil
+}
+
+// aclPath возвращает путь права доступа клиента к файлу
+func aclPath(fileID, principal string) string {
+	return fmt.Sprintf("/api/v1/files/%s/acl/%s", fileID, url.PathEscape(principal))
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"TestCase/pkg/metaformat"
+)
+
+// Форматы снимка каталога
+const (
+	CatalogFormatNDJSON      = "ndjson"   // метаданные файлов по одному объекту JSON в строке
+	CatalogFormatJSON        = "json"     // один объект JSON со списком files
+	CatalogFormatProtobuf    = "protobuf" // сообщение storagepb.FileList
+	CatalogFormatMessagePack = "msgpack"  // массив метаданных файлов в MessagePack
+)
+
+// CatalogImportResult - итог загрузки снимка каталога
+type CatalogImportResult struct {
+	Imported int                    `json:"imported"`
+	Skipped  int                    `json:"skipped"` // файлы, уже бывшие в каталоге, без overwrite
+	Failed   []CatalogImportFailure `json:"failed,omitempty"`
+}
+
+// CatalogImportFailure - файл снимка, который не удалось сохранить в каталог
+type CatalogImportFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// ExportCatalog записывает в w снимок каталога метаданных в формате format
+// (CatalogFormatNDJSON, CatalogFormatJSON, CatalogFormatProtobuf или CatalogFormatMessagePack)
+func (ac *APIClient) ExportCatalog(w io.Writer, format string) error {
+	return ac.ExportCatalogContext(context.Background(), w, format)
+}
+
+// ExportCatalogContext записывает в w снимок каталога с учетом контекста
+func (ac *APIClient) ExportCatalogContext(ctx context.Context, w io.Writer, format string) error {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ac.baseURL+"/api/v1/admin/catalog/export?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("не удалось получить снимок каталога: %w", err)
+	}
+	return nil
+}
+
+// ImportCatalog загружает снимок каталога из r в формате CatalogFormatNDJSON или
+// CatalogFormatJSON. Файлы, уже бывшие в каталоге, пропускаются, а с overwrite
+// заменяются метаданными из снимка
+func (ac *APIClient) ImportCatalog(r io.Reader, overwrite bool) (*CatalogImportResult, error) {
+	return ac.ImportCatalogContext(context.Background(), r, overwrite)
+}
+
+// ImportCatalogContext загружает снимок каталога с учетом контекста
+func (ac *APIClient) ImportCatalogContext(ctx context.Context, r io.Reader, overwrite bool) (*CatalogImportResult, error) {
+	return ac.ImportCatalogFormatContext(ctx, r, CatalogFormatJSON, overwrite)
+}
+
+// ImportCatalogFormat загружает снимок каталога из r в любом из форматов ExportCatalog.
+// Форматы JSON сервер различает сам, а двоичные указываются в format
+func (ac *APIClient) ImportCatalogFormat(r io.Reader, format string, overwrite bool) (*CatalogImportResult, error) {
+	return ac.ImportCatalogFormatContext(context.Background(), r, format, overwrite)
+}
+
+// ImportCatalogFormatContext загружает снимок каталога в формате format с учетом контекста
+func (ac *APIClient) ImportCatalogFormatContext(ctx context.Context, r io.Reader, format string, overwrite bool) (*CatalogImportResult, error) {
+	path := "/api/v1/admin/catalog/import"
+	if overwrite {
+		path += "?overwrite=true"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ac.baseURL+path, r)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	switch format {
+	case CatalogFormatProtobuf, CatalogFormatMessagePack:
+		req.Header.Set("Content-Type", metaformat.Format(format).ContentType())
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var result CatalogImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+	return &result, nil
+}
+
+// CatalogRebuildResult - итог восстановления каталога по манифестам серверов хранения
+type CatalogRebuildResult struct {
+	NodesScanned int                `json:"nodes_scanned"`
+	NodeErrors   []CatalogNodeError `json:"node_errors,omitempty"`
+	Manifests    int                `json:"manifests"` // найдено файлов с манифестами
+	CatalogImportResult
+}
+
+// CatalogNodeError - сервер хранения, с которого не удалось прочитать манифесты
+type CatalogNodeError struct {
+	Node  string `json:"node"`
+	Error string `json:"error"`
+}
+
+// RebuildCatalog восстанавливает каталог по манифестам файлов, которые хранят серверы
+// хранения. Файлы, уже бывшие в каталоге, пропускаются, а с overwrite заменяются
+func (ac *APIClient) RebuildCatalog(overwrite bool) (*CatalogRebuildResult, error) {
+	return ac.RebuildCatalogContext(context.Background(), overwrite)
+}
+
+// RebuildCatalogContext восстанавливает каталог по манифестам с учетом контекста
+func (ac *APIClient) RebuildCatalogContext(ctx context.Context, overwrite bool) (*CatalogRebuildResult, error) {
+	path := "/api/v1/admin/catalog/rebuild"
+	if overwrite {
+		path += "?overwrite=true"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ac.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var result CatalogRebuildResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+	return &result, nil
+}
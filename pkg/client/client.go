@@ -2,105 +2,309 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"maps"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"TestCase/pkg/chunking"
+	"TestCase/pkg/metaformat"
+	"TestCase/pkg/storage"
 )
 
 // APIClient представляет клиент для работы с API сервером
 type APIClient struct {
 	baseURL    string
 	httpClient *http.Client
+	apiKey     string // ключ API; пусто - запросы без ключа
+	socket     string // путь к unix сокету API сервера; пусто - соединение по TCP
+	region     string // регион клиента для прямого скачивания; пусто - регион API сервера
+
+	metadataFormat metaformat.Format // формат метаданных в ответах; пусто - JSON
+	consistency    string            // уровень согласованности чтения при скачивании; пусто - по настройке сервера
+
+	opts    []Option              // опции, переданные при создании
+	options storage.ClientOptions // настройки, примененные к текущему транспорту
 }
 
-// NewAPIClient создает новый клиент для API сервера
-func NewAPIClient(baseURL string) *APIClient {
-	return &APIClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute, // увеличенный таймаут для больших файлов
-		},
+// defaultTimeout - таймаут запроса к API серверу, если он не задан опцией;
+// увеличен для больших файлов
+const defaultTimeout = 5 * time.Minute
+
+// NewAPIClient создает новый клиент для API сервера. baseURL - адрес http(s)://host:port
+// или unix:///path для сервера, слушающего unix сокет. Опции opts задают таймаут,
+// транспорт, TLS, повтор запросов и журнал
+func NewAPIClient(baseURL string, opts ...Option) *APIClient {
+	ac := &APIClient{baseURL: baseURL, opts: opts}
+	var transport http.RoundTripper
+	if socket, ok := storage.UnixSocketPath(baseURL); ok {
+		ac.baseURL = storage.UnixBaseURL()
+		ac.socket = socket
+		transport = storage.UnixTransport(socket)
 	}
+	ac.configure(transport)
+	return ac
+}
+
+// configure создает HTTP клиент с транспортом transport и опциями клиента поверх него
+func (ac *APIClient) configure(transport http.RoundTripper) {
+	ac.options = storage.NewClientOptions(defaultTimeout, transport, ac.opts...)
+	ac.httpClient = ac.options.HTTPClient()
+}
+
+// SetAPIKey задает ключ API, передаваемый в заголовке X-API-Key каждого запроса к API серверу
+func (ac *APIClient) SetAPIKey(key string) {
+	ac.apiKey = key
 }
 
-// UploadFile загружает файл на сервер
-func (ac *APIClient) UploadFile(filePath string) (*chunking.FileMetadata, error) {
+// SetRegion задает регион клиента: при прямом скачивании куски читаются сначала с серверов
+// хранения этого региона и только при их недоступности - из других регионов
+func (ac *APIClient) SetRegion(region string) {
+	ac.region = region
+}
+
+// Уровни согласованности чтения: сколько копий каждого куска должны совпасть с контрольной
+// суммой из метаданных, прежде чем API сервер отдаст данные
+const (
+	ReadConsistencyOne    = "one"    // первая копия, прошедшая проверку
+	ReadConsistencyQuorum = "quorum" // большинство копий
+	ReadConsistencyAll    = "all"    // все копии
+)
+
+// SetReadConsistency задает уровень согласованности чтения при скачивании через API сервер
+// (ReadConsistencyOne, ReadConsistencyQuorum или ReadConsistencyAll). Более строгий уровень
+// надежнее обнаруживает поврежденные копии, но ждет ответа нескольких серверов хранения.
+// На прямое скачивание не влияет
+func (ac *APIClient) SetReadConsistency(level string) {
+	ac.consistency = level
+}
+
+// requestConsistency передает в запросе скачивания уровень согласованности из SetReadConsistency
+func (ac *APIClient) requestConsistency(req *http.Request) {
+	if ac.consistency != "" {
+		req.Header.Set("X-Read-Consistency", ac.consistency)
+	}
+}
+
+// UseH2C переключает клиент на HTTP/2 без TLS (h2c): запросы к API серверу и к серверам
+// хранения при прямой передаче мультиплексируются в одном соединении на сервер. Серверы
+// должны быть запущены с http2_cleartext. Транспорт из WithTransport остается в силе
+func (ac *APIClient) UseH2C() {
+	if ac.socket != "" {
+		ac.configure(storage.H2CUnixTransport(ac.socket))
+		return
+	}
+	ac.configure(storage.H2CTransport())
+}
+
+// storageClient возвращает клиент сервера хранения для прямой передачи кусков. Сервер
+// на unix сокете доступен только через собственный транспорт
+func (ac *APIClient) storageClient(storageURL string) *storage.StorageClient {
+	if _, ok := storage.UnixSocketPath(storageURL); ok {
+		client := storage.NewStorageClient(storageURL, ac.opts...)
+		client.HTTPClient.Timeout = ac.httpClient.Timeout
+		return client
+	}
+	return &storage.StorageClient{
+		BaseURL:    storageURL,
+		HTTPClient: ac.httpClient,
+		Retry:      ac.options.Retry,
+		Logger:     ac.options.Logger,
+	}
+}
+
+// do отправляет запрос к API серверу с ключом API, повторяя его по политике из WithRetryPolicy
+func (ac *APIClient) do(req *http.Request) (*http.Response, error) {
+	if ac.apiKey != "" {
+		req.Header.Set("X-API-Key", ac.apiKey)
+	}
+	return ac.options.Retry.Do(ac.httpClient, req, ac.options.Logger, nil)
+}
+
+// UploadFile загружает файл на сервер.
+// Опции WithProgress и WithStats позволяют следить за передачей и получить ее статистику
+func (ac *APIClient) UploadFile(filePath string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.UploadFileContext(context.Background(), filePath, opts...)
+}
+
+// UploadFileContext загружает файл на сервер с учетом контекста
+func (ac *APIClient) UploadFileContext(ctx context.Context, filePath string, opts ...TransferOption) (*chunking.FileMetadata, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
 	}
 	defer file.Close()
 
-	// Создаем multipart форму
-	var buffer bytes.Buffer
-	writer := multipart.NewWriter(&buffer)
-
-	// Добавляем файл в форму
-	fileWriter, err := writer.CreateFormFile("file", filepath.Base(filePath))
+	info, err := file.Stat()
 	if err != nil {
+		return nil, fmt.Errorf("не удалось получить размер файла: %w", err)
+	}
+
+	return ac.UploadReader(ctx, filepath.Base(filePath), file, info.Size(), opts...)
+}
+
+// UploadReader загружает на сервер данные из r под именем name, не буферизуя их целиком.
+// Если размер заранее неизвестен, size должен быть отрицательным
+func (ac *APIClient) UploadReader(ctx context.Context, name string, r io.Reader, size int64, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.uploadReader(ctx, "/api/v1/files", name, r, size, opts...)
+}
+
+// uploadReader отправляет данные из r формой multipart на адрес path API сервера
+func (ac *APIClient) uploadReader(ctx context.Context, path, name string, r io.Reader, size int64, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.uploadForm(ctx, path, nil, name, r, size, opts...)
+}
+
+// uploadForm отправляет формой multipart поля fields и данные из r в поле file
+func (ac *APIClient) uploadForm(ctx context.Context, path string, fields map[string]string, name string, r io.Reader, size int64, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.sendForm(ctx, http.MethodPost, path, nil, fields, name, r, size, opts...)
+}
+
+// quoteEscaper экранирует имя файла в заголовке части формы так же, как multipart.CreateFormFile
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// sendForm отправляет запросом method форму multipart с полями fields и данными из r в
+// поле file и дополнительными заголовками headers
+func (ac *APIClient) sendForm(ctx context.Context, method, path string, headers map[string]string, fields map[string]string, name string, r io.Reader, size int64, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	options := newTransferOptions(opts)
+	if options.path != "" {
+		fields = maps.Clone(fields)
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields["path"] = options.path
+	}
+
+	// Заголовок и окончание multipart формы формируем заранее,
+	// а содержимое файла передаем между ними потоком
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+
+	for field, value := range fields {
+		if err := writer.WriteField(field, value); err != nil {
+			return nil, fmt.Errorf("не удалось создать форму файла: %w", err)
+		}
+	}
+	contentType := options.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(name)))
+	partHeader.Set("Content-Type", contentType)
+	if _, err := writer.CreatePart(partHeader); err != nil {
 		return nil, fmt.Errorf("не удалось создать форму файла: %w", err)
 	}
+	header := bytes.NewReader(append([]byte(nil), form.Bytes()...))
 
-	if _, err := io.Copy(fileWriter, file); err != nil {
-		return nil, fmt.Errorf("не удалось скопировать файл в форму: %w", err)
+	form.Reset()
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("не удалось создать форму файла: %w", err)
 	}
+	trailer := bytes.NewReader(form.Bytes())
 
-	writer.Close()
+	content := newTransferReader(r, size, options)
+	defer content.finish()
 
 	// Отправляем запрос
-	url := fmt.Sprintf("%s/api/v1/files", ac.baseURL)
-	req, err := http.NewRequest("POST", url, &buffer)
+	req, err := http.NewRequestWithContext(ctx, method, ac.baseURL+options.policy.withQuery(path), io.MultiReader(header, content, trailer))
 	if err != nil {
 		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
 	}
 
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if options.contentSHA256 != "" {
+		req.Header.Set("X-Content-SHA256", options.contentSHA256)
+	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	ac.acceptMetadata(req)
+	if size >= 0 {
+		req.ContentLength = header.Size() + size + trailer.Size()
+	}
 
-	resp, err := ac.httpClient.Do(req)
+	resp, err := ac.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
-	// Читаем ответ
-	var metadata chunking.FileMetadata
-	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
-		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
-	}
+	return decodeMetadata(resp)
+}
 
-	return &metadata, nil
+// ReplaceFile заменяет содержимое файла fileID содержимым локального файла, сохраняя
+// идентификатор. Непустой etag разрешает замену только версии с этим ETag
+func (ac *APIClient) ReplaceFile(fileID, filePath, etag string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.ReplaceFileContext(context.Background(), fileID, filePath, etag, opts...)
 }
 
-// DownloadFile скачивает файл с сервера
-func (ac *APIClient) DownloadFile(fileID, outputPath string) error {
-	url := fmt.Sprintf("%s/files/%s", ac.baseURL, fileID)
+// ReplaceFileContext заменяет содержимое файла с учетом контекста
+func (ac *APIClient) ReplaceFileContext(ctx context.Context, fileID, filePath, etag string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
 
-	resp, err := ac.httpClient.Get(url)
+	info, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("не удалось отправить запрос: %w", err)
+		return nil, fmt.Errorf("не удалось получить размер файла: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("файл не найден")
+	var headers map[string]string
+	if etag != "" {
+		headers = map[string]string{"If-Match": etag}
 	}
+	path := fmt.Sprintf("/api/v1/files/%s", fileID)
+	return ac.sendForm(ctx, http.MethodPut, path, headers, nil, filepath.Base(filePath), file, info.Size(), opts...)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+// PatchFile перезаписывает данными data часть файла fileID, начиная с байта offset.
+// Запись за конец файла увеличивает его. Непустой etag разрешает запись только в версию
+// с этим ETag
+func (ac *APIClient) PatchFile(fileID string, offset int64, data []byte, etag string) (*chunking.FileMetadata, error) {
+	return ac.PatchFileContext(context.Background(), fileID, offset, data, etag)
+}
+
+// PatchFileContext перезаписывает часть файла с учетом контекста
+func (ac *APIClient) PatchFileContext(ctx context.Context, fileID string, offset int64, data []byte, etag string) (*chunking.FileMetadata, error) {
+	var headers map[string]string
+	if etag != "" {
+		headers = map[string]string{"If-Match": etag}
 	}
+	fields := map[string]string{"offset": strconv.FormatInt(offset, 10)}
+	path := fmt.Sprintf("/api/v1/files/%s", fileID)
+	return ac.sendForm(ctx, http.MethodPatch, path, headers, fields, "patch", bytes.NewReader(data), int64(len(data)))
+}
+
+// DownloadFile скачивает файл с сервера.
+// Опции WithProgress и WithStats позволяют следить за передачей и получить ее статистику
+func (ac *APIClient) DownloadFile(fileID, outputPath string, opts ...TransferOption) error {
+	return ac.DownloadFileContext(context.Background(), fileID, outputPath, opts...)
+}
+
+// DownloadFileContext скачивает файл с сервера с учетом контекста
+func (ac *APIClient) DownloadFileContext(ctx context.Context, fileID, outputPath string, opts ...TransferOption) error {
+	body, err := ac.OpenDownload(ctx, fileID, opts...)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
 
 	// Создаем выходной файл
 	outputFile, err := os.Create(outputPath)
@@ -109,77 +313,219 @@ func (ac *APIClient) DownloadFile(fileID, outputPath string) error {
 	}
 	defer outputFile.Close()
 
-	// Копируем данные
-	if _, err := io.Copy(outputFile, resp.Body); err != nil {
+	// Контрольная сумма проверяется при чтении до конца, поврежденный файл не оставляем
+	if _, err := io.Copy(outputFile, body); err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
 		return fmt.Errorf("не удалось записать данные в файл: %w", err)
 	}
 
 	return nil
 }
 
+// OpenDownload начинает скачивание файла и возвращает поток его содержимого.
+// Контрольная сумма сверяется при достижении конца потока: вместо io.EOF
+// Read вернет ошибку, если данные повреждены. Поток нужно закрыть
+func (ac *APIClient) OpenDownload(ctx context.Context, fileID string, opts ...TransferOption) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/api/v1/files/%s", ac.baseURL, fileID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	ac.requestConsistency(req)
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError(resp)
+		resp.Body.Close()
+		return nil, apiErr
+	}
+
+	hasher, err := chunking.NewHasher(chunking.HashAlgorithm(resp.Header.Get("X-Checksum-Algorithm")))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return &checksumReader{
+		reader:   newTransferReader(resp.Body, resp.ContentLength, newTransferOptions(opts)),
+		body:     resp.Body,
+		hasher:   hasher,
+		expected: resp.Header.Get("X-Checksum"),
+	}, nil
+}
+
+// checksumReader вычисляет контрольную сумму по мере чтения и сверяет ее в конце потока
+type checksumReader struct {
+	reader   *transferReader
+	body     io.Closer
+	hasher   hash.Hash
+	expected string
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hasher.Write(p[:n])
+
+	// Сверяем с контрольной суммой, переданной сервером
+	if err == io.EOF && r.expected != "" {
+		if actual := fmt.Sprintf("%x", r.hasher.Sum(nil)); actual != r.expected {
+			return n, fmt.Errorf("%w: ожидалась %s, получена %s", ErrChecksumMismatch, r.expected, actual)
+		}
+	}
+
+	return n, err
+}
+
+func (r *checksumReader) Close() error {
+	r.reader.finish()
+	return r.body.Close()
+}
+
 // GetFileInfo получает информацию о файле
 func (ac *APIClient) GetFileInfo(fileID string) (*chunking.FileMetadata, error) {
-	url := fmt.Sprintf("%s/files/%s/info", ac.baseURL, fileID)
+	return ac.GetFileInfoContext(context.Background(), fileID)
+}
 
-	resp, err := ac.httpClient.Get(url)
+// GetFileInfoContext получает информацию о файле с учетом контекста
+func (ac *APIClient) GetFileInfoContext(ctx context.Context, fileID string) (*chunking.FileMetadata, error) {
+	url := fmt.Sprintf("%s/api/v1/files/%s/info", ac.baseURL, fileID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
 	}
-	defer resp.Body.Close()
+	ac.acceptMetadata(req)
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("файл не найден")
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
-	var metadata chunking.FileMetadata
-	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
-		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
-	}
+	return decodeMetadata(resp)
+}
+
+// FindFilesByChecksum находит файлы, SHA256 содержимого которых равна checksum. Если такой
+// файл уже есть, загружать данные повторно не нужно
+func (ac *APIClient) FindFilesByChecksum(checksum string) ([]*chunking.FileMetadata, error) {
+	return ac.FindFilesByChecksumContext(context.Background(), checksum)
+}
 
-	return &metadata, nil
+// FindFilesByChecksumContext находит файлы по SHA256 содержимого с учетом контекста
+func (ac *APIClient) FindFilesByChecksumContext(ctx context.Context, checksum string) ([]*chunking.FileMetadata, error) {
+	var files []*chunking.FileMetadata
+	if err := ac.sendJSON(ctx, http.MethodGet, "/api/v1/files/by-checksum/"+url.PathEscape(checksum), nil, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
 }
 
 // DeleteFile удаляет файл с сервера
 func (ac *APIClient) DeleteFile(fileID string) error {
-	url := fmt.Sprintf("%s/files/%s", ac.baseURL, fileID)
+	return ac.DeleteFileContext(context.Background(), fileID)
+}
 
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
+// DeleteFileContext удаляет файл с сервера с учетом контекста
+func (ac *APIClient) DeleteFileContext(ctx context.Context, fileID string) error {
+	url := fmt.Sprintf("%s/api/v1/files/%s", ac.baseURL, fileID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("не удалось создать запрос: %w", err)
 	}
 
-	resp, err := ac.httpClient.Do(req)
+	resp, err := ac.do(req)
 	if err != nil {
 		return fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return newAPIError(resp)
 	}
 
 	return nil
 }
 
+// ListFilter - условия отбора файлов в списке; нулевые значения не ограничивают список
+type ListFilter struct {
+	Prefix string // начало логического пути файла
+
+	// Время последнего скачивания, а для нескачанных файлов - время загрузки
+	AccessedBefore time.Time
+	AccessedAfter  time.Time
+
+	MaxDownloads *int64 // наибольшее число скачиваний
+
+	DropBox string // идентификатор ящика, через который файлы приняты
+}
+
+// query возвращает параметры запроса списка
+func (f ListFilter) query() url.Values {
+	query := url.Values{}
+	if f.Prefix != "" {
+		query.Set("prefix", f.Prefix)
+	}
+	if !f.AccessedBefore.IsZero() {
+		query.Set("accessed_before", f.AccessedBefore.UTC().Format(time.RFC3339))
+	}
+	if !f.AccessedAfter.IsZero() {
+		query.Set("accessed_after", f.AccessedAfter.UTC().Format(time.RFC3339))
+	}
+	if f.MaxDownloads != nil {
+		query.Set("max_downloads", strconv.FormatInt(*f.MaxDownloads, 10))
+	}
+	if f.DropBox != "" {
+		query.Set("drop_box", f.DropBox)
+	}
+	return query
+}
+
 // ListFiles получает список всех файлов
 func (ac *APIClient) ListFiles() ([]string, error) {
-	url := fmt.Sprintf("%s/api/v1/files", ac.baseURL)
+	return ac.ListFilesContext(context.Background())
+}
+
+// ListFilesContext получает список всех файлов с учетом контекста
+func (ac *APIClient) ListFilesContext(ctx context.Context) ([]string, error) {
+	return ac.ListFilesFilteredContext(ctx, ListFilter{})
+}
+
+// ListFilesFiltered получает список файлов, подходящих под условия
+func (ac *APIClient) ListFilesFiltered(filter ListFilter) ([]string, error) {
+	return ac.ListFilesFilteredContext(context.Background(), filter)
+}
+
+// ListFilesFilteredContext получает список файлов, подходящих под условия, с учетом контекста
+func (ac *APIClient) ListFilesFilteredContext(ctx context.Context, filter ListFilter) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/files", ac.baseURL)
+	if query := filter.query(); len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
 
-	resp, err := ac.httpClient.Get(url)
+	resp, err := ac.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp)
 	}
 
 	var files []string
@@ -192,9 +538,19 @@ func (ac *APIClient) ListFiles() ([]string, error) {
 
 // HealthCheck проверяет доступность API сервера
 func (ac *APIClient) HealthCheck() error {
+	return ac.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext проверяет доступность API сервера с учетом контекста
+func (ac *APIClient) HealthCheckContext(ctx context.Context) error {
 	url := fmt.Sprintf("%s/health", ac.baseURL)
 
-	resp, err := ac.httpClient.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
 	if err != nil {
 		return fmt.Errorf("сервер недоступен: %w", err)
 	}
@@ -206,3 +562,43 @@ func (ac *APIClient) HealthCheck() error {
 
 	return nil
 }
+
+// HealthStatus описывает состояние API сервера и серверов хранения
+type HealthStatus struct {
+	Status         string `json:"status"`
+	HealthyServers int    `json:"healthy_servers"`
+	TotalServers   int    `json:"total_servers"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// Health возвращает подробное состояние API сервера
+func (ac *APIClient) Health() (*HealthStatus, error) {
+	return ac.HealthContext(context.Background())
+}
+
+// HealthContext возвращает подробное состояние API сервера с учетом контекста
+func (ac *APIClient) HealthContext(ctx context.Context) (*HealthStatus, error) {
+	url := fmt.Sprintf("%s/health", ac.baseURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("сервер недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("сервер вернул код состояния %d", resp.StatusCode)
+	}
+
+	var status HealthStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+
+	return &status, nil
+}
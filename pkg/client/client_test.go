@@ -0,0 +1,771 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"TestCase/internal/listen"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+func TestUploadReader(t *testing.T) {
+	payload := "streamed content"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files", r.URL.Path)
+
+		// Размер известен, поэтому запрос должен идти с Content-Length
+		assert.Greater(t, r.ContentLength, int64(len(payload)))
+
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, payload, string(data))
+
+		json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "id", OriginalName: header.Filename, Size: int64(len(data))})
+	}))
+	defer server.Close()
+
+	metadata, err := NewAPIClient(server.URL).UploadReader(context.Background(), "data.txt", strings.NewReader(payload), int64(len(payload)))
+	require.NoError(t, err)
+	assert.Equal(t, "data.txt", metadata.OriginalName)
+	assert.Equal(t, int64(len(payload)), metadata.Size)
+}
+
+func TestUploadReaderPathAndContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		file.Close()
+
+		assert.Equal(t, `report "q1".pdf`, header.Filename)
+		assert.Equal(t, "application/pdf", header.Header.Get("Content-Type"))
+		assert.Equal(t, "reports/q1.pdf", r.FormValue("path"))
+		json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "id"})
+	}))
+	defer server.Close()
+
+	_, err := NewAPIClient(server.URL).UploadReader(context.Background(), `report "q1".pdf`, strings.NewReader("%PDF"), 4,
+		WithPath("reports/q1.pdf"), WithContentType("application/pdf"))
+	require.NoError(t, err)
+}
+
+func TestUploadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":             "alpha",
+		"docs/b.json":       `{"b":1}`,
+		"docs/deep/c.bin":   "charlie",
+		"docs/deep/bad.bin": "rejected",
+	}
+	for name, content := range files {
+		local := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(local), 0o755))
+		require.NoError(t, os.WriteFile(local, []byte(content), 0o644))
+	}
+
+	var mutex sync.Mutex
+	received := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+
+		if header.Filename == "bad.bin" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"upload_rejected","message":"rejected"}}`))
+			return
+		}
+		if header.Filename == "b.json" {
+			assert.Equal(t, "application/json", header.Header.Get("Content-Type"))
+		}
+		mutex.Lock()
+		received[r.FormValue("path")] = string(data)
+		mutex.Unlock()
+		json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "id-" + header.Filename, Size: int64(len(data))})
+	}))
+	defer server.Close()
+
+	var last Progress
+	manifest, err := NewAPIClient(server.URL).UploadDir(dir, "backup", WithConcurrency(2),
+		WithProgress(func(p Progress) { last = p }))
+	require.Error(t, err)
+	require.NotNil(t, manifest)
+
+	// Структура каталога сохраняется в логических путях, а ошибка файла не прерывает загрузку
+	assert.Equal(t, map[string]string{
+		"backup/a.txt":           "alpha",
+		"backup/docs/b.json":     `{"b":1}`,
+		"backup/docs/deep/c.bin": "charlie",
+	}, received)
+	require.Len(t, manifest.Files, 3)
+	assert.Equal(t, DirFile{RelativePath: "docs/b.json", Path: "backup/docs/b.json", ID: "id-b.json", Size: 7}, manifest.Files[1])
+	require.Len(t, manifest.Failed, 1)
+	assert.Equal(t, "docs/deep/bad.bin", manifest.Failed[0].RelativePath)
+	assert.Equal(t, int64(19), manifest.Bytes)
+
+	assert.True(t, last.Done)
+	assert.Equal(t, int64(27), last.Total)
+
+	_, err = NewAPIClient(server.URL).UploadDir(filepath.Join(dir, "a.txt"), "")
+	assert.Error(t, err)
+}
+
+func TestOpenDownloadVerifiesChecksum(t *testing.T) {
+	payload := []byte("downloaded content")
+	checksum, err := chunking.Checksum(chunking.HashSHA256, payload)
+	require.NoError(t, err)
+
+	serve := func(checksum string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Checksum", checksum)
+			w.Header().Set("X-Checksum-Algorithm", string(chunking.HashSHA256))
+			w.Write(payload)
+		}))
+	}
+
+	valid := serve(checksum)
+	defer valid.Close()
+
+	body, err := NewAPIClient(valid.URL).OpenDownload(context.Background(), "id")
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+	body.Close()
+
+	corrupted := serve(strings.Repeat("0", len(checksum)))
+	defer corrupted.Close()
+
+	body, err = NewAPIClient(corrupted.URL).OpenDownload(context.Background(), "id")
+	require.NoError(t, err)
+	_, err = io.ReadAll(body)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	body.Close()
+}
+
+func TestListFilesFiltered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files", r.URL.Path)
+		assert.Equal(t, "2026-01-02T03:04:05Z", r.URL.Query().Get("accessed_before"))
+		assert.Equal(t, "0", r.URL.Query().Get("max_downloads"))
+		assert.False(t, r.URL.Query().Has("accessed_after"))
+		json.NewEncoder(w).Encode([]string{"idle"})
+	}))
+	defer server.Close()
+
+	maxDownloads := int64(0)
+	ids, err := NewAPIClient(server.URL).ListFilesFiltered(ListFilter{
+		AccessedBefore: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		MaxDownloads:   &maxDownloads,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"idle"}, ids)
+}
+
+func TestUploadToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, []byte("image"), 0o644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/upload-tokens":
+			// Токен выдается по ключу API
+			assert.Equal(t, "secret-key", r.Header.Get("X-API-Key"))
+			var request map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.EqualValues(t, 600, request["expires_in"])
+			assert.EqualValues(t, 1024, request["max_size"])
+			json.NewEncoder(w).Encode(UploadToken{Token: "signed+token", FileID: "granted", MaxSize: 1024})
+		case "/api/v1/files":
+			// Загрузка по токену идет без ключа
+			assert.Empty(t, r.Header.Get("X-API-Key"))
+			assert.Equal(t, "signed+token", r.URL.Query().Get("token"))
+			json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "granted"})
+		default:
+			t.Errorf("неожиданный запрос %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	issuer := NewAPIClient(server.URL)
+	issuer.SetAPIKey("secret-key")
+	uploadToken, err := issuer.CreateUploadToken(UploadTokenRequest{ExpiresIn: 10 * time.Minute, MaxSize: 1024})
+	require.NoError(t, err)
+	assert.Equal(t, "granted", uploadToken.FileID)
+
+	metadata, err := NewAPIClient(server.URL).UploadFileWithToken(path, uploadToken.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "granted", metadata.ID)
+
+	// Поток неизвестного размера, например стандартный ввод
+	metadata, err = NewAPIClient(server.URL).UploadReaderWithToken(context.Background(), "photo.jpg", strings.NewReader("image"), -1, uploadToken.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "granted", metadata.ID)
+}
+
+// roundTripFunc позволяет передать функцию как транспорт
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClientOptions(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "id"})
+	}))
+	defer server.Close()
+
+	// Запросы идут через переданный транспорт, а ответ 503 повторяется
+	intercepted := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		intercepted++
+		req.Header.Set("X-Traced", "1")
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	apiClient := NewAPIClient(server.URL,
+		WithTransport(transport),
+		WithTimeout(time.Second),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, Delay: time.Millisecond}),
+	)
+
+	metadata, err := apiClient.GetFileInfo("id")
+	require.NoError(t, err)
+	assert.Equal(t, "id", metadata.ID)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 2, intercepted)
+
+	// h2c меняет транспорт по умолчанию, но не переданный явно
+	apiClient.UseH2C()
+	_, err = apiClient.GetFileInfo("id")
+	require.NoError(t, err)
+	assert.Equal(t, 3, intercepted)
+}
+
+func TestSetFileVisibility(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/v1/files/id/visibility", r.URL.Path)
+		var request map[string]bool
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.True(t, request["public"])
+		json.NewEncoder(w).Encode(Visibility{ID: "id", Public: true, URL: "/api/v1/public/id"})
+	}))
+	defer server.Close()
+
+	visibility, err := NewAPIClient(server.URL).SetFileVisibility("id", true)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/api/v1/public/id", visibility.URL)
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/files/plain/visibility" {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("X-Request-ID", "req-1")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(`{"error":{"code":"precondition_failed","message":"changed","details":{"etag":"v2"},"request_id":"req-1"}}`))
+	}))
+	defer server.Close()
+
+	c := NewAPIClient(server.URL)
+	_, err := c.SetFileVisibility("id", true)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusPreconditionFailed, apiErr.StatusCode)
+	assert.Equal(t, "precondition_failed", apiErr.Code)
+	assert.Equal(t, "changed", apiErr.Message)
+	assert.Equal(t, map[string]interface{}{"etag": "v2"}, apiErr.Details)
+	assert.Equal(t, "req-1", apiErr.RequestID)
+	assert.Equal(t, "precondition_failed", ErrorCode(fmt.Errorf("обертка: %w", err)))
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	_, err = c.SetFileVisibility("plain", true)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Empty(t, apiErr.Code)
+	assert.Equal(t, "bad gateway", apiErr.Message)
+
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusNotFound, Code: "file_not_found"}, ErrNotFound)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusInsufficientStorage}, ErrQuotaExceeded)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusRequestEntityTooLarge, Code: "drop_box_quota_exceeded"}, ErrQuotaExceeded)
+	assert.ErrorIs(t, &APIError{StatusCode: http.StatusInternalServerError, Code: "checksum_mismatch"}, ErrChecksumMismatch)
+}
+
+func TestGrantAndRevokeFileAccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files/id/acl/bob", r.URL.Path)
+		acl := FileACL{ID: "id", Owner: "alice", Grants: map[string]chunking.Permission{}}
+		switch r.Method {
+		case http.MethodPut:
+			var request map[string]chunking.Permission
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			acl.Grants["bob"] = request["permission"]
+		case http.MethodDelete:
+			assert.Zero(t, r.ContentLength)
+		}
+		json.NewEncoder(w).Encode(acl)
+	}))
+	defer server.Close()
+
+	apiClient := NewAPIClient(server.URL)
+	acl, err := apiClient.GrantFileAccess("id", "bob", chunking.PermissionReadWrite)
+	require.NoError(t, err)
+	assert.Equal(t, chunking.PermissionReadWrite, acl.Grants["bob"])
+
+	acl, err = apiClient.RevokeFileAccess("id", "bob")
+	require.NoError(t, err)
+	assert.Empty(t, acl.Grants)
+}
+
+func TestDropBox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	require.NoError(t, os.WriteFile(path, []byte("report"), 0o644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/drop-boxes":
+			assert.Equal(t, "secret-key", r.Header.Get("X-API-Key"))
+			var request map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.EqualValues(t, 86400, request["expires_in"])
+			assert.EqualValues(t, 3, request["max_files"])
+			json.NewEncoder(w).Encode(DropBox{ID: "box", Token: "signed", URL: "/api/v1/drop/signed", MaxFiles: 3})
+		case "/api/v1/drop/signed":
+			// Отправитель обращается к ящику без ключа
+			assert.Empty(t, r.Header.Get("X-API-Key"))
+			if r.Method == http.MethodGet {
+				json.NewEncoder(w).Encode(DropBoxStatus{MaxFiles: 3, Files: 1, Size: 6})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "received", "original_name": "report.pdf", "size": 6})
+		default:
+			t.Errorf("неожиданный запрос %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	owner := NewAPIClient(server.URL)
+	owner.SetAPIKey("secret-key")
+	dropBox, err := owner.CreateDropBox(DropBoxRequest{ExpiresIn: 24 * time.Hour, MaxFiles: 3})
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/api/v1/drop/signed", dropBox.URL)
+
+	sender := NewAPIClient(server.URL)
+	metadata, err := sender.UploadFileToDropBox(path, dropBox.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "received", metadata.ID)
+	assert.Equal(t, "report.pdf", metadata.OriginalName)
+
+	status, err := sender.GetDropBox(dropBox.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Files)
+}
+
+func TestFindFilesByChecksum(t *testing.T) {
+	checksum := strings.Repeat("ab", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files/by-checksum/"+checksum, r.URL.Path)
+		json.NewEncoder(w).Encode([]chunking.FileMetadata{{ID: "stored", Checksum: checksum}})
+	}))
+	defer server.Close()
+
+	files, err := NewAPIClient(server.URL).FindFilesByChecksum(checksum)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "stored", files[0].ID)
+}
+
+func TestUploadFileDedup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	require.NoError(t, os.WriteFile(path, []byte("video"), 0o644))
+	sum := sha256.Sum256([]byte("video"))
+
+	stored := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/files/dedup":
+			var request DedupRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, hex.EncodeToString(sum[:]), request.SHA256)
+			assert.EqualValues(t, 5, *request.Size)
+			if !stored {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "linked", OriginalName: request.Name})
+		case "/api/v1/files":
+			// Данные загружаются с уже вычисленной суммой, чтобы сервер их проверил
+			assert.Equal(t, hex.EncodeToString(sum[:]), r.Header.Get("X-Content-SHA256"))
+			stored = true
+			json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "uploaded"})
+		}
+	}))
+	defer server.Close()
+
+	apiClient := NewAPIClient(server.URL)
+
+	// Первый раз содержимого нет, и файл загружается целиком
+	metadata, err := apiClient.UploadFileDedup(path)
+	require.NoError(t, err)
+	assert.Equal(t, "uploaded", metadata.ID)
+
+	metadata, err = apiClient.UploadFileDedup(path)
+	require.NoError(t, err)
+	assert.Equal(t, "linked", metadata.ID)
+	assert.Equal(t, "video.mp4", metadata.OriginalName)
+}
+
+func TestUploadFileDelta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("aaaaXXbbbb"), 0o644))
+
+	signature := chunking.Signature{FileID: "base", Size: 8}
+	for i, data := range []string{"aaaa", "bbbb"} {
+		sum := sha256.Sum256([]byte(data))
+		signature.Chunks = append(signature.Chunks, chunking.SignatureChunk{
+			Index: i, Offset: int64(4 * i), Size: 4, Checksum: hex.EncodeToString(sum[:]), Algorithm: chunking.HashSHA256,
+		})
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/files/base/signature":
+			json.NewEncoder(w).Encode(signature)
+		case "/api/v1/files/base/delta":
+			file, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			data, err := io.ReadAll(file)
+			require.NoError(t, err)
+			// Передаются только новые данные
+			assert.Equal(t, "XX", string(data))
+
+			var request struct {
+				Name     string                  `json:"name"`
+				Segments []chunking.DeltaSegment `json:"segments"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(r.FormValue("delta")), &request))
+			assert.Equal(t, "notes.txt", request.Name)
+			require.Len(t, request.Segments, 3)
+			assert.Equal(t, 0, *request.Segments[0].Base)
+			assert.EqualValues(t, 2, request.Segments[1].Size)
+			assert.Equal(t, 1, *request.Segments[2].Base)
+			json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "new", Size: 10})
+		}
+	}))
+	defer server.Close()
+
+	metadata, err := NewAPIClient(server.URL).UploadFileDelta(path, "base")
+	require.NoError(t, err)
+	assert.Equal(t, "new", metadata.ID)
+}
+
+func TestReplaceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("new content"), 0o644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/v1/files/file-1", r.URL.Path)
+		assert.Equal(t, `"abc"`, r.Header.Get("If-Match"))
+
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, "new content", string(data))
+		assert.Equal(t, "notes.txt", header.Filename)
+		json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "file-1", Size: int64(len(data))})
+	}))
+	defer server.Close()
+
+	metadata, err := NewAPIClient(server.URL).ReplaceFile("file-1", path, `"abc"`)
+	require.NoError(t, err)
+	assert.Equal(t, "file-1", metadata.ID)
+	assert.EqualValues(t, 11, metadata.Size)
+}
+
+func TestPatchFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/api/v1/files/file-1", r.URL.Path)
+		assert.Empty(t, r.Header.Get("If-Match"))
+		assert.Equal(t, "4096", r.FormValue("offset"))
+
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, "XYZ", string(data))
+		json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "file-1", Size: 8192})
+	}))
+	defer server.Close()
+
+	metadata, err := NewAPIClient(server.URL).PatchFile("file-1", 4096, []byte("XYZ"), "")
+	require.NoError(t, err)
+	assert.Equal(t, "file-1", metadata.ID)
+}
+
+func TestUseH2C(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 2, r.ProtoMajor)
+		json.NewEncoder(w).Encode(Visibility{ID: "id", Public: true})
+	})
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer server.Close()
+
+	c := NewAPIClient(server.URL)
+	c.UseH2C()
+	for i := 0; i < 3; i++ {
+		_, err := c.SetFileVisibility("id", true)
+		require.NoError(t, err)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := listen.Listen("unix://" + socket)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files/id/visibility", r.URL.Path)
+		json.NewEncoder(w).Encode(Visibility{ID: "id", Public: true})
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	visibility, err := NewAPIClient("unix://"+socket).SetFileVisibility("id", true)
+	require.NoError(t, err)
+	assert.True(t, visibility.Public)
+
+	// Сокет занят работающим сервером, второй слушатель его не отнимает
+	_, err = listen.Listen("unix://" + socket)
+	assert.Error(t, err)
+}
+
+func TestContextCancelsRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := NewAPIClient(server.URL).GetFileInfoContext(ctx, "id")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestTransferProgressAndStats(t *testing.T) {
+	payload := []byte(strings.Repeat("x", 64*1024))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	var updates []Progress
+	var stats TransferStats
+
+	body, err := NewAPIClient(server.URL).OpenDownload(context.Background(), "id",
+		WithProgress(func(p Progress) { updates = append(updates, p) }),
+		WithProgressInterval(0),
+		WithStats(&stats),
+	)
+	require.NoError(t, err)
+	_, err = io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+
+	require.NotEmpty(t, updates)
+	last := updates[len(updates)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, int64(len(payload)), last.Transferred)
+	assert.Equal(t, int64(len(payload)), last.Total)
+
+	assert.Equal(t, int64(len(payload)), stats.Bytes)
+	assert.False(t, stats.StartedAt.IsZero())
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	payload := []byte(strings.Repeat("resumable content ", 100))
+	checksum, err := chunking.Checksum(chunking.HashSHA256, payload)
+	require.NoError(t, err)
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("X-Checksum", checksum)
+		w.Header().Set("X-Checksum-Algorithm", string(chunking.HashSHA256))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer server.Close()
+
+	apiClient := NewAPIClient(server.URL)
+	output := filepath.Join(t.TempDir(), "file")
+
+	// Начало файла уже скачано: запрашивается только остаток
+	require.NoError(t, os.WriteFile(output, payload[:700], 0o644))
+	require.NoError(t, apiClient.DownloadFileResume("id", output))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+	assert.Equal(t, []string{"bytes=700-"}, ranges)
+
+	// Файл скачан целиком: остается только проверить контрольную сумму
+	require.NoError(t, apiClient.DownloadFileResume("id", output))
+
+	// Локальная часть не совпадает с файлом на сервере: файл удаляется
+	corrupted := append([]byte("x"), payload[1:700]...)
+	require.NoError(t, os.WriteFile(output, corrupted, 0o644))
+	assert.Error(t, apiClient.DownloadFileResume("id", output))
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadFileDirect(t *testing.T) {
+	payload := []byte(strings.Repeat("direct content ", 100))
+	checksum, err := chunking.Checksum(chunking.HashSHA256, payload)
+	require.NoError(t, err)
+
+	// Каждый кусок лежит на своем сервере хранения
+	const chunkCount = 3
+	chunkSize := len(payload) / chunkCount
+	metadata := &chunking.FileMetadata{ID: "id", Size: int64(len(payload)), Checksum: checksum}
+	storageURLs := make(map[string]string)
+	for i := 0; i < chunkCount; i++ {
+		data := payload[i*chunkSize:]
+		if i < chunkCount-1 {
+			data = data[:chunkSize]
+		}
+		chunkChecksum, err := chunking.Checksum(chunking.HashSHA256, data)
+		require.NoError(t, err)
+		chunk := chunking.FileChunk{ID: fmt.Sprintf("chunk-%d", i), Index: i, Size: int64(len(data)), Checksum: chunkChecksum, Node: fmt.Sprintf("node-%d", i)}
+
+		node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/chunks/"+chunk.ID, r.URL.Path)
+			withData := chunk
+			withData.Data = data
+			json.NewEncoder(w).Encode(withData)
+		}))
+		defer node.Close()
+
+		storageURLs[chunk.Node] = node.URL
+		metadata.Chunks = append(metadata.Chunks, chunk)
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Данные файла через API сервер не запрашиваются
+		assert.Equal(t, "/api/v1/files/id/manifest", r.URL.Path)
+		json.NewEncoder(w).Encode(chunking.NewManifest(metadata, func(node string) string {
+			return storageURLs[node]
+		}))
+	}))
+	defer api.Close()
+
+	output := filepath.Join(t.TempDir(), "file")
+	var stats TransferStats
+	require.NoError(t, NewAPIClient(api.URL).DownloadFileDirect("id", output, WithConcurrency(2), WithStats(&stats)))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+	assert.Equal(t, int64(len(payload)), stats.Bytes)
+
+	// Манифест с неверной суммой файла: собранный файл не сохраняется
+	metadata.Checksum = strings.Repeat("0", len(checksum))
+	assert.Error(t, NewAPIClient(api.URL).DownloadFileDirect("id", output))
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUploadFileDirect(t *testing.T) {
+	payload := []byte(strings.Repeat("direct upload ", 100))
+	input := filepath.Join(t.TempDir(), "data.bin")
+	require.NoError(t, os.WriteFile(input, payload, 0o644))
+
+	var mutex sync.Mutex
+	stored := make(map[string][]byte)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "Bearer token-"+r.URL.Path, r.Header.Get("Authorization"))
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mutex.Lock()
+		stored[r.URL.Path] = data
+		mutex.Unlock()
+		json.NewEncoder(w).Encode(storage.UploadResult{Receipt: "receipt-" + r.URL.Path})
+	}))
+	defer node.Close()
+
+	half := int64(len(payload) / 2)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		switch r.URL.Path {
+		case "/api/v1/uploads":
+			assert.Equal(t, "data.bin", request["name"])
+			chunks := []map[string]interface{}{
+				{"id": "a", "offset": 0, "size": half, "storage_url": node.URL, "token": "token-/api/v1/chunks/a"},
+				{"id": "b", "offset": half, "size": int64(len(payload)) - half, "storage_url": node.URL, "token": "token-/api/v1/chunks/b"},
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"file_id": "id", "checksum_algorithm": "sha256", "chunks": chunks, "plan": "plan"})
+		case "/api/v1/uploads/id/commit":
+			checksum, err := chunking.Checksum(chunking.HashSHA256, payload)
+			require.NoError(t, err)
+			assert.Equal(t, "plan", request["plan"])
+			assert.Equal(t, checksum, request["checksum"])
+			assert.Equal(t, []interface{}{"receipt-/api/v1/chunks/a", "receipt-/api/v1/chunks/b"}, request["receipts"])
+			json.NewEncoder(w).Encode(chunking.FileMetadata{ID: "id", Size: int64(len(payload))})
+		default:
+			t.Errorf("неожиданный запрос %s", r.URL.Path)
+		}
+	}))
+	defer api.Close()
+
+	metadata, err := NewAPIClient(api.URL).UploadFileDirect(input)
+	require.NoError(t, err)
+	assert.Equal(t, "id", metadata.ID)
+	assert.Equal(t, payload, append(stored["/api/v1/chunks/a"], stored["/api/v1/chunks/b"]...))
+}
@@ -0,0 +1,253 @@
+// Package clienttest содержит реализацию client.FileService в памяти для модульных тестов
+// приложений, работающих с хранилищем
+package clienttest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+)
+
+// FileService хранит файлы в памяти и ведет себя как client.APIClient: отсутствующий файл
+// дает *client.APIError с кодом file_not_found, который errors.Is сопоставляет с
+// client.ErrNotFound. Опции передачи (прогресс, статистика) не применяются
+type FileService struct {
+	// Err, если задана, возвращается каждым методом, например чтобы проверить поведение
+	// приложения при недоступном сервере
+	Err error
+
+	mutex  sync.Mutex
+	order  []string // идентификаторы в порядке загрузки
+	files  map[string]*storedFile
+	nextID int
+}
+
+// storedFile - метаданные и содержимое файла
+type storedFile struct {
+	metadata chunking.FileMetadata
+	data     []byte
+}
+
+var _ client.FileService = (*FileService)(nil)
+
+// NewFileService создает пустое хранилище файлов
+func NewFileService() *FileService {
+	return &FileService{files: make(map[string]*storedFile)}
+}
+
+// UploadReader сохраняет данные из r под именем name
+func (f *FileService) UploadReader(ctx context.Context, name string, r io.Reader, size int64, opts ...client.TransferOption) (*chunking.FileMetadata, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать данные: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.nextID++
+	metadata := chunking.FileMetadata{
+		ID:                fmt.Sprintf("file-%d", f.nextID),
+		OriginalName:      name,
+		Size:              int64(len(data)),
+		Checksum:          hex.EncodeToString(sum[:]),
+		ChecksumAlgorithm: chunking.HashSHA256,
+		CreatedAt:         time.Now(),
+		Generation:        1,
+	}
+	f.files[metadata.ID] = &storedFile{metadata: metadata, data: data}
+	f.order = append(f.order, metadata.ID)
+	return copyMetadata(&metadata), nil
+}
+
+// UploadFileContext сохраняет содержимое локального файла
+func (f *FileService) UploadFileContext(ctx context.Context, filePath string, opts ...client.TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+	return f.UploadReader(ctx, filepath.Base(filePath), file, -1, opts...)
+}
+
+// OpenDownload возвращает поток содержимого файла
+func (f *FileService) OpenDownload(ctx context.Context, fileID string, opts ...client.TransferOption) (io.ReadCloser, error) {
+	data, err := f.Content(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// DownloadFileContext записывает содержимое файла в outputPath
+func (f *FileService) DownloadFileContext(ctx context.Context, fileID, outputPath string, opts ...client.TransferOption) error {
+	data, err := f.Content(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return fmt.Errorf("не удалось записать данные в файл: %w", err)
+	}
+	return nil
+}
+
+// GetFileInfoContext возвращает метаданные файла
+func (f *FileService) GetFileInfoContext(ctx context.Context, fileID string) (*chunking.FileMetadata, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	stored, ok := f.files[fileID]
+	if !ok {
+		return nil, notFound()
+	}
+	return copyMetadata(&stored.metadata), nil
+}
+
+// ListFilesFilteredContext возвращает идентификаторы файлов, подходящих под условия, в
+// порядке загрузки, а с условием Prefix, как и API сервер, - в порядке путей
+func (f *FileService) ListFilesFilteredContext(ctx context.Context, filter client.ListFilter) ([]string, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ids := make([]string, 0, len(f.order))
+	for _, id := range f.order {
+		if matches(&f.files[id].metadata, filter) {
+			ids = append(ids, id)
+		}
+	}
+	if filter.Prefix != "" {
+		sort.SliceStable(ids, func(i, j int) bool {
+			return f.files[ids[i]].metadata.Path < f.files[ids[j]].metadata.Path
+		})
+	}
+	return ids, nil
+}
+
+// FindFilesByChecksumContext возвращает файлы с заданной SHA256 содержимого
+func (f *FileService) FindFilesByChecksumContext(ctx context.Context, checksum string) ([]*chunking.FileMetadata, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+
+	checksum = strings.ToLower(checksum)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	files := make([]*chunking.FileMetadata, 0)
+	for _, id := range f.order {
+		if metadata := &f.files[id].metadata; metadata.Checksum == checksum {
+			files = append(files, copyMetadata(metadata))
+		}
+	}
+	return files, nil
+}
+
+// DeleteFileContext удаляет файл
+func (f *FileService) DeleteFileContext(ctx context.Context, fileID string) error {
+	if err := f.check(ctx); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.files[fileID]; !ok {
+		return notFound()
+	}
+	delete(f.files, fileID)
+	for i, id := range f.order {
+		if id == fileID {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Content возвращает копию содержимого файла, чтобы тест мог проверить загруженные данные
+func (f *FileService) Content(ctx context.Context, fileID string) ([]byte, error) {
+	if err := f.check(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	stored, ok := f.files[fileID]
+	if !ok {
+		return nil, notFound()
+	}
+	return append([]byte(nil), stored.data...), nil
+}
+
+// Update изменяет метаданные файла, например чтобы задать путь, ящик или статистику
+// скачиваний для проверки фильтров списка
+func (f *FileService) Update(fileID string, update func(*chunking.FileMetadata)) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	stored, ok := f.files[fileID]
+	if !ok {
+		return notFound()
+	}
+	update(&stored.metadata)
+	return nil
+}
+
+// check возвращает ошибку, с которой должен завершиться любой метод
+func (f *FileService) check(ctx context.Context) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	return ctx.Err()
+}
+
+// matches проверяет файл условиями списка так же, как API сервер
+func matches(metadata *chunking.FileMetadata, filter client.ListFilter) bool {
+	if filter.Prefix != "" && (metadata.Path == "" || !strings.HasPrefix(metadata.Path, filter.Prefix)) {
+		return false
+	}
+	if filter.DropBox != "" && metadata.DropBox != filter.DropBox {
+		return false
+	}
+	activity := metadata.LastActivity()
+	if !filter.AccessedBefore.IsZero() && !activity.Before(filter.AccessedBefore) {
+		return false
+	}
+	if !filter.AccessedAfter.IsZero() && !activity.After(filter.AccessedAfter) {
+		return false
+	}
+	if filter.MaxDownloads != nil && metadata.DownloadCount > *filter.MaxDownloads {
+		return false
+	}
+	return true
+}
+
+// notFound возвращает ошибку, которую API сервер отдает для отсутствующего файла
+func notFound() error {
+	return &client.APIError{StatusCode: http.StatusNotFound, Code: "file_not_found", Message: "Файл не найден"}
+}
+
+// copyMetadata возвращает копию метаданных, которую вызывающий может изменять
+func copyMetadata(metadata *chunking.FileMetadata) *chunking.FileMetadata {
+	copied := *metadata
+	return &copied
+}
@@ -0,0 +1,60 @@
+package clienttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+)
+
+// archive загружает отчет через client.FileService - так его использовало бы приложение
+func archive(ctx context.Context, files client.FileService, report string) (string, error) {
+	metadata, err := files.UploadReader(ctx, "report.txt", strings.NewReader(report), int64(len(report)))
+	if err != nil {
+		return "", err
+	}
+	return metadata.ID, nil
+}
+
+func TestFileService(t *testing.T) {
+	ctx := context.Background()
+	files := NewFileService()
+
+	id, err := archive(ctx, files, "quarterly")
+	require.NoError(t, err)
+
+	body, err := files.OpenDownload(ctx, id)
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "quarterly", string(data))
+
+	metadata, err := files.GetFileInfoContext(ctx, id)
+	require.NoError(t, err)
+	found, err := files.FindFilesByChecksumContext(ctx, strings.ToUpper(metadata.Checksum))
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, id, found[0].ID)
+
+	require.NoError(t, files.Update(id, func(m *chunking.FileMetadata) { m.Path = "reports/q1.txt" }))
+	ids, err := files.ListFilesFilteredContext(ctx, client.ListFilter{Prefix: "reports/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{id}, ids)
+
+	require.NoError(t, files.DeleteFileContext(ctx, id))
+	_, err = files.GetFileInfoContext(ctx, id)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.Equal(t, "file_not_found", client.ErrorCode(err))
+
+	unavailable := errors.New("кластер недоступен")
+	files.Err = unavailable
+	_, err = archive(ctx, files, "quarterly")
+	assert.ErrorIs(t, err, unavailable)
+}
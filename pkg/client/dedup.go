@@ -0,0 +1,109 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"TestCase/pkg/chunking"
+)
+
+// ErrNoDuplicate возвращается UploadDuplicate, если в кластере нет доступного файла с таким
+// содержимым и данные нужно загрузить
+var ErrNoDuplicate = errors.New("файл с таким содержимым не найден")
+
+// DedupRequest - создание файла из содержимого, которое уже хранится в кластере
+type DedupRequest struct {
+	SHA256      string `json:"sha256"`         // SHA256 содержимого в шестнадцатеричном виде
+	Size        *int64 `json:"size,omitempty"` // размер файла; если указан, должен совпасть
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Public      bool   `json:"public,omitempty"`
+}
+
+// UploadDuplicate создает файл без передачи данных, если файл с тем же SHA256 уже хранится
+// в кластере и доступен клиенту. Если такого файла нет, возвращает ErrNoDuplicate
+func (ac *APIClient) UploadDuplicate(request DedupRequest) (*chunking.FileMetadata, error) {
+	return ac.UploadDuplicateContext(context.Background(), request)
+}
+
+// UploadDuplicateContext создает файл из уже хранящегося содержимого с учетом контекста
+func (ac *APIClient) UploadDuplicateContext(ctx context.Context, request DedupRequest) (*chunking.FileMetadata, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ac.baseURL+"/api/v1/files/dedup", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ac.acceptMetadata(req)
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoDuplicate
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	return decodeMetadata(resp)
+}
+
+// UploadFileDedup загружает файл, передавая данные, только если такого содержимого еще
+// нет в кластере: сначала отправляется SHA256 файла
+func (ac *APIClient) UploadFileDedup(filePath string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.UploadFileDedupContext(context.Background(), filePath, opts...)
+}
+
+// UploadFileDedupContext загружает файл без передачи уже хранящихся данных с учетом контекста
+func (ac *APIClient) UploadFileDedupContext(ctx context.Context, filePath string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+
+	name := filepath.Base(filePath)
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	options := newTransferOptions(opts)
+	metadata, err := ac.UploadDuplicateContext(ctx, DedupRequest{
+		SHA256:      checksum,
+		Size:        &size,
+		Name:        name,
+		ContentType: options.contentType,
+		Path:        options.path,
+	})
+	if !errors.Is(err, ErrNoDuplicate) {
+		return metadata, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+	// Сумма уже вычислена: сервер проверит по ней переданные данные
+	opts = append(opts, WithContentSHA256(checksum))
+	return ac.uploadReader(ctx, "/api/v1/files", name, file, size, opts...)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"TestCase/pkg/chunking"
+)
+
+// GetFileSignature получает контрольные суммы кусков файла
+func (ac *APIClient) GetFileSignature(fileID string) (*chunking.Signature, error) {
+	return ac.GetFileSignatureContext(context.Background(), fileID)
+}
+
+// GetFileSignatureContext получает контрольные суммы кусков файла с учетом контекста
+func (ac *APIClient) GetFileSignatureContext(ctx context.Context, fileID string) (*chunking.Signature, error) {
+	var signature chunking.Signature
+	if err := ac.sendJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v1/files/%s/signature", fileID), nil, &signature); err != nil {
+		return nil, err
+	}
+	return &signature, nil
+}
+
+// UploadFileDelta загружает новую версию файла baseID, передавая только данные, которых
+// нет среди кусков базового файла. Новая версия получает новый идентификатор, базовый
+// файл не изменяется
+func (ac *APIClient) UploadFileDelta(filePath, baseID string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.UploadFileDeltaContext(context.Background(), filePath, baseID, opts...)
+}
+
+// UploadFileDeltaContext загружает новую версию файла с учетом контекста
+func (ac *APIClient) UploadFileDeltaContext(ctx context.Context, filePath, baseID string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить размер файла: %w", err)
+	}
+
+	signature, err := ac.GetFileSignatureContext(ctx, baseID)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := chunking.Diff(signature, file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	// Передаются только новые данные сегментов, подряд
+	var parts []io.Reader
+	var offset, size int64
+	for _, segment := range segments {
+		if segment.Base != nil {
+			offset += signature.Chunks[*segment.Base].Size
+			continue
+		}
+		parts = append(parts, io.NewSectionReader(file, offset, segment.Size))
+		offset += segment.Size
+		size += segment.Size
+	}
+
+	name := filepath.Base(filePath)
+	delta, err := json.Marshal(map[string]interface{}{"name": name, "segments": segments})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	path := fmt.Sprintf("/api/v1/files/%s/delta", baseID)
+	return ac.uploadForm(ctx, path, map[string]string{"delta": string(delta)}, name, io.MultiReader(parts...), size, opts...)
+}
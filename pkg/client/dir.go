@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DirManifest - итог загрузки каталога: идентификаторы загруженных файлов по их
+// относительным путям и файлы, которые загрузить не удалось
+type DirManifest struct {
+	Root   string         `json:"root"`             // загруженный локальный каталог
+	Prefix string         `json:"prefix,omitempty"` // префикс логических путей в хранилище
+	Files  []DirFile      `json:"files"`            // по возрастанию относительного пути
+	Failed []DirFileError `json:"failed,omitempty"`
+	Bytes  int64          `json:"bytes"` // объем загруженных файлов
+}
+
+// DirFile - загруженный файл каталога
+type DirFile struct {
+	RelativePath string `json:"relative_path"` // путь относительно каталога через "/"
+	Path         string `json:"path"`          // логический путь в хранилище
+	ID           string `json:"id"`
+	Size         int64  `json:"size"`
+	Checksum     string `json:"checksum"`
+}
+
+// DirFileError - файл каталога, который не удалось загрузить
+type DirFileError struct {
+	RelativePath string `json:"relative_path"`
+	Error        string `json:"error"`
+}
+
+// dirEntry - обычный файл каталога, найденный при обходе
+type dirEntry struct {
+	path         string // локальный путь
+	relativePath string
+	size         int64
+	modTime      time.Time
+}
+
+// UploadDir загружает все обычные файлы каталога dir с подкаталогами, по WithConcurrency
+// файлов одновременно. Логическим путем файла становится его путь относительно dir с
+// префиксом prefix, поэтому структура каталогов сохраняется. WithProgress и WithStats
+// относятся ко всей загрузке. Ошибки отдельных файлов не прерывают загрузку остальных:
+// такие файлы попадают в Failed манифеста, а UploadDir возвращает и манифест, и ошибку
+func (ac *APIClient) UploadDir(dir, prefix string, opts ...TransferOption) (*DirManifest, error) {
+	return ac.UploadDirContext(context.Background(), dir, prefix, opts...)
+}
+
+// UploadDirContext загружает каталог с учетом контекста
+func (ac *APIClient) UploadDirContext(ctx context.Context, dir, prefix string, opts ...TransferOption) (*DirManifest, error) {
+	entries, err := walkUploadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	options := newTransferOptions(opts)
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+	progress := newTransferReader(nil, total, options)
+	defer progress.finish()
+
+	concurrency := options.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	files := make([]*DirFile, len(entries))
+	failures := make([]error, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			failures[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, entry dirEntry) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			files[i], failures[i] = ac.uploadDirFile(ctx, entry, prefix, options, progress)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	manifest := &DirManifest{Root: dir, Prefix: prefix, Files: []DirFile{}}
+	for i, entry := range entries {
+		if failures[i] != nil {
+			manifest.Failed = append(manifest.Failed, DirFileError{RelativePath: entry.relativePath, Error: failures[i].Error()})
+			continue
+		}
+		manifest.Files = append(manifest.Files, *files[i])
+		manifest.Bytes += files[i].Size
+	}
+	if err := ctx.Err(); err != nil {
+		return manifest, err
+	}
+	if len(manifest.Failed) > 0 {
+		return manifest, fmt.Errorf("не удалось загрузить %d из %d файлов каталога %s", len(manifest.Failed), len(entries), dir)
+	}
+	return manifest, nil
+}
+
+// uploadDirFile загружает файл каталога и учитывает переданные байты в progress
+func (ac *APIClient) uploadDirFile(ctx context.Context, entry dirEntry, prefix string, options *transferOptions, progress *transferReader) (*DirFile, error) {
+	file, err := os.Open(entry.path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+
+	remotePath := path.Join(prefix, entry.relativePath)
+	fileOpts := []TransferOption{WithPath(remotePath), withPolicy(options.policy)}
+	if contentType := mime.TypeByExtension(filepath.Ext(entry.path)); contentType != "" {
+		fileOpts = append(fileOpts, WithContentType(contentType))
+	}
+	reader := &progressReader{reader: file, progress: progress}
+	metadata, err := ac.UploadReader(ctx, filepath.Base(entry.path), reader, entry.size, fileOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &DirFile{
+		RelativePath: entry.relativePath,
+		Path:         remotePath,
+		ID:           metadata.ID,
+		Size:         metadata.Size,
+		Checksum:     metadata.Checksum,
+	}, nil
+}
+
+// walkUploadDir возвращает обычные файлы каталога dir с подкаталогами по возрастанию
+// относительного пути. Символические ссылки и специальные файлы пропускаются
+func walkUploadDir(dir string) ([]dirEntry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть каталог: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s не является каталогом", dir)
+	}
+
+	var entries []dirEntry
+	err = filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, dirEntry{path: filePath, relativePath: filepath.ToSlash(relativePath), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось обойти каталог: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].relativePath < entries[j].relativePath
+	})
+	return entries, nil
+}
@@ -0,0 +1,379 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// GetManifest получает манифест файла: куски и серверы хранения, на которых они лежат.
+// Если задан регион клиента (SetRegion), первыми у каждого куска идут серверы этого региона
+func (ac *APIClient) GetManifest(fileID string) (*chunking.Manifest, error) {
+	return ac.GetManifestContext(context.Background(), fileID)
+}
+
+// GetManifestContext получает манифест файла с учетом контекста
+func (ac *APIClient) GetManifestContext(ctx context.Context, fileID string) (*chunking.Manifest, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/files/%s/manifest", ac.baseURL, fileID)
+	if ac.region != "" {
+		endpoint += "?region=" + url.QueryEscape(ac.region)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var manifest chunking.Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// DownloadFileDirect скачивает куски файла напрямую с серверов хранения и собирает
+// файл локально, не нагружая API сервер передачей данных. Куски скачиваются
+// параллельно (см. WithConcurrency), их контрольные суммы и контрольная сумма
+// собранного файла сверяются с манифестом. Серверы хранения должны быть доступны клиенту
+func (ac *APIClient) DownloadFileDirect(fileID, outputPath string, opts ...TransferOption) error {
+	return ac.DownloadFileDirectContext(context.Background(), fileID, outputPath, opts...)
+}
+
+// DownloadFileDirectContext скачивает файл напрямую с серверов хранения с учетом контекста
+func (ac *APIClient) DownloadFileDirectContext(ctx context.Context, fileID, outputPath string, opts ...TransferOption) error {
+	manifest, err := ac.GetManifestContext(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("не удалось создать выходной файл: %w", err)
+	}
+	defer outputFile.Close()
+
+	if err := ac.downloadChunks(ctx, manifest, outputFile, newTransferOptions(opts)); err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
+		return err
+	}
+
+	// Куски проверены по отдельности, но порядок сборки проверяет только сумма всего файла
+	if err := verifyFile(outputFile, manifest); err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
+		return err
+	}
+
+	return nil
+}
+
+// downloadChunks скачивает куски манифеста и записывает их в файл по их смещениям
+func (ac *APIClient) downloadChunks(ctx context.Context, manifest *chunking.Manifest, output io.WriterAt, options *transferOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := newTransferReader(nil, manifest.Size, options)
+	defer progress.finish()
+
+	concurrency := options.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for _, chunkMeta := range manifest.Chunks {
+		wg.Add(1)
+		go func(chunkMeta chunking.ManifestChunk) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			if err := ac.downloadChunk(ctx, chunkMeta, output); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("не удалось получить кусок %d с сервера %s: %w", chunkMeta.Index, chunkMeta.Node, err)
+					cancel()
+				})
+				return
+			}
+			progress.add(chunkMeta.Size, false)
+		}(chunkMeta)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// downloadChunk получает кусок с сервера хранения, а при ошибке - с серверов с его
+// копиями, и записывает его по смещению
+func (ac *APIClient) downloadChunk(ctx context.Context, chunkMeta chunking.ManifestChunk, output io.WriterAt) error {
+	var lastErr error
+	for _, storageURL := range append([]string{chunkMeta.StorageURL}, chunkMeta.Mirrors...) {
+		chunk, err := ac.fetchManifestChunk(ctx, storageURL, chunkMeta)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if _, err := output.WriteAt(chunk.Data, chunkMeta.Offset); err != nil {
+			return fmt.Errorf("не удалось записать кусок в файл: %w", err)
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// fetchManifestChunk получает кусок с сервера хранения и сверяет его с манифестом
+func (ac *APIClient) fetchManifestChunk(ctx context.Context, storageURL string, chunkMeta chunking.ManifestChunk) (*chunking.FileChunk, error) {
+	storageClient := ac.storageClient(storageURL)
+
+	// GetChunkContext сверяет данные с контрольной суммой, пришедшей с куском,
+	// а сервер мог вернуть не тот кусок, поэтому сверяем и с манифестом
+	chunk, err := storageClient.ReadChunkContext(ctx, chunkMeta.ID, chunkMeta.Token)
+	if err != nil {
+		return nil, err
+	}
+	if chunk.Checksum != chunkMeta.Checksum || int64(len(chunk.Data)) != chunkMeta.Size {
+		return nil, fmt.Errorf("%w: кусок не совпадает с манифестом", storage.ErrChunkCorrupted)
+	}
+	return chunk, nil
+}
+
+// verifyFile сверяет контрольную сумму собранного файла с манифестом
+func verifyFile(file *os.File, manifest *chunking.Manifest) error {
+	hasher, err := chunking.NewHasher(manifest.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("не удалось прочитать выходной файл: %w", err)
+	}
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("не удалось прочитать выходной файл: %w", err)
+	}
+
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != manifest.Checksum {
+		return fmt.Errorf("%w: ожидалась %s, получена %s", ErrChecksumMismatch, manifest.Checksum, actual)
+	}
+	return nil
+}
+
+// directUploadPlan - план прямой загрузки, выданный API сервером
+type directUploadPlan struct {
+	FileID            string                 `json:"file_id"`
+	ChecksumAlgorithm chunking.HashAlgorithm `json:"checksum_algorithm"`
+	Chunks            []struct {
+		ID         string `json:"id"`
+		Index      int    `json:"index"`
+		Offset     int64  `json:"offset"`
+		Size       int64  `json:"size"`
+		Node       string `json:"node"`
+		StorageURL string `json:"storage_url"`
+		Token      string `json:"token"`
+	} `json:"chunks"`
+	Plan string `json:"plan"`
+}
+
+// UploadFileDirect загружает файл, передавая куски напрямую на серверы хранения:
+// API сервер только выдает план размещения с токенами и сохраняет метаданные по
+// квитанциям серверов хранения. Требует upload_token_secret на API сервере и серверах хранения
+func (ac *APIClient) UploadFileDirect(filePath string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.UploadFileDirectContext(context.Background(), filePath, opts...)
+}
+
+// UploadFileDirectContext загружает файл напрямую на серверы хранения с учетом контекста
+func (ac *APIClient) UploadFileDirectContext(ctx context.Context, filePath string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить размер файла: %w", err)
+	}
+
+	options := newTransferOptions(opts)
+	var plan directUploadPlan
+	request := map[string]interface{}{"name": filepath.Base(filePath), "size": info.Size()}
+	if err := ac.postJSON(ctx, options.policy.withQuery("/api/v1/uploads"), request, &plan); err != nil {
+		return nil, err
+	}
+
+	// Контрольная сумма файла вычисляется клиентом: данные не проходят через API сервер
+	hasher, err := chunking.NewHasher(plan.ChecksumAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(hasher, io.NewSectionReader(file, 0, info.Size())); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+
+	receipts, err := ac.uploadChunks(ctx, file, &plan, info.Size(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata chunking.FileMetadata
+	commit := map[string]interface{}{
+		"plan":     plan.Plan,
+		"checksum": fmt.Sprintf("%x", hasher.Sum(nil)),
+		"receipts": receipts,
+	}
+	if err := ac.postJSON(ctx, fmt.Sprintf("/api/v1/uploads/%s/commit", plan.FileID), commit, &metadata); err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// uploadChunks параллельно загружает куски файла на серверы хранения по плану и
+// возвращает квитанции серверов
+func (ac *APIClient) uploadChunks(ctx context.Context, file io.ReaderAt, plan *directUploadPlan, size int64, options *transferOptions) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := newTransferReader(nil, size, options)
+	defer progress.finish()
+
+	concurrency := options.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	receipts := make([]string, len(plan.Chunks))
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i := range plan.Chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			chunk := plan.Chunks[i]
+			storageClient := ac.storageClient(chunk.StorageURL)
+			data := &progressReader{reader: io.NewSectionReader(file, chunk.Offset, chunk.Size), progress: progress}
+
+			result, err := storageClient.UploadChunkContext(ctx, chunk.ID, chunk.Token, data, chunk.Size)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("не удалось загрузить кусок %d на сервер %s: %w", chunk.Index, chunk.Node, err)
+					cancel()
+				})
+				return
+			}
+			receipts[i] = result.Receipt
+		}(i)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// progressReader сообщает о прочитанных байтах общему счетчику передачи
+type progressReader struct {
+	reader   io.Reader
+	progress *transferReader
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.progress.add(int64(n), false)
+	return n, err
+}
+
+// postJSON отправляет JSON запрос к API серверу и разбирает JSON ответ в result
+func (ac *APIClient) postJSON(ctx context.Context, path string, request, result interface{}) error {
+	return ac.sendJSON(ctx, http.MethodPost, path, request, result)
+}
+
+// sendJSON отправляет запрос с методом method и разбирает JSON ответ в result; метаданные
+// файлов запрашиваются в формате из SetMetadataFormat. При request == nil запрос
+// отправляется без тела
+func (ac *APIClient) sendJSON(ctx context.Context, method, path string, request, result interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("не удалось сериализовать запрос: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, ac.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch result.(type) {
+	case *chunking.FileMetadata, *[]*chunking.FileMetadata:
+		ac.acceptMetadata(req)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+
+	return decodeResult(resp, result)
+}
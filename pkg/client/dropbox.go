@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// DropBoxRequest - ограничения создаваемого ящика для приема файлов; нулевые значения
+// означают ограничения сервера по умолчанию или их отсутствие
+type DropBoxRequest struct {
+	ExpiresIn    time.Duration // срок действия, с точностью до секунды
+	MaxFileSize  int64         // наибольший размер одного файла
+	MaxTotalSize int64         // наибольший суммарный размер файлов
+	MaxFiles     int           // наибольшее число файлов
+	ContentTypes []string      // разрешенные типы содержимого (тип/подтип или тип/*)
+}
+
+// DropBox - ящик для приема файлов от внешних отправителей без ключа API
+type DropBox struct {
+	ID           string    `json:"id"` // идентификатор ящика для отбора принятых файлов
+	Token        string    `json:"token"`
+	URL          string    `json:"url"` // полный адрес ящика для передачи отправителю
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxFileSize  int64     `json:"max_file_size"`
+	MaxTotalSize int64     `json:"max_total_size,omitempty"`
+	MaxFiles     int       `json:"max_files,omitempty"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+}
+
+// DropBoxStatus - ограничения ящика и занятый в нем объем
+type DropBoxStatus struct {
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxFileSize  int64     `json:"max_file_size"`
+	MaxTotalSize int64     `json:"max_total_size,omitempty"`
+	MaxFiles     int       `json:"max_files,omitempty"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+	Files        int       `json:"files"` // число загруженных файлов
+	Size         int64     `json:"size"`  // суммарный размер загруженных файлов
+}
+
+// CreateDropBox создает ящик, в который внешние отправители могут загружать файлы без
+// ключа API, но не могут их просматривать и скачивать. Требует upload_token_secret на API сервере
+func (ac *APIClient) CreateDropBox(request DropBoxRequest) (*DropBox, error) {
+	return ac.CreateDropBoxContext(context.Background(), request)
+}
+
+// CreateDropBoxContext создает ящик для приема файлов с учетом контекста
+func (ac *APIClient) CreateDropBoxContext(ctx context.Context, request DropBoxRequest) (*DropBox, error) {
+	body := map[string]interface{}{
+		"expires_in":     int64(request.ExpiresIn / time.Second),
+		"max_file_size":  request.MaxFileSize,
+		"max_total_size": request.MaxTotalSize,
+		"max_files":      request.MaxFiles,
+		"content_types":  request.ContentTypes,
+	}
+
+	var dropBox DropBox
+	if err := ac.postJSON(ctx, "/api/v1/drop-boxes", body, &dropBox); err != nil {
+		return nil, err
+	}
+	dropBox.URL = ac.baseURL + dropBox.URL
+	return &dropBox, nil
+}
+
+// GetDropBox получает ограничения ящика и занятый в нем объем по токену ящика
+func (ac *APIClient) GetDropBox(dropBoxToken string) (*DropBoxStatus, error) {
+	return ac.GetDropBoxContext(context.Background(), dropBoxToken)
+}
+
+// GetDropBoxContext получает состояние ящика с учетом контекста
+func (ac *APIClient) GetDropBoxContext(ctx context.Context, dropBoxToken string) (*DropBoxStatus, error) {
+	var status DropBoxStatus
+	if err := ac.sendJSON(ctx, http.MethodGet, dropBoxPath(dropBoxToken), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// UploadFileToDropBox загружает файл в ящик по токену ящика. В ответе заполнены только
+// идентификатор, имя, размер и контрольная сумма файла
+func (ac *APIClient) UploadFileToDropBox(filePath, dropBoxToken string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.UploadFileToDropBoxContext(context.Background(), filePath, dropBoxToken, opts...)
+}
+
+// UploadFileToDropBoxContext загружает файл в ящик с учетом контекста
+func (ac *APIClient) UploadFileToDropBoxContext(ctx context.Context, filePath, dropBoxToken string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить размер файла: %w", err)
+	}
+
+	return ac.uploadReader(ctx, dropBoxPath(dropBoxToken), filepath.Base(filePath), file, info.Size(), opts...)
+}
+
+// dropBoxPath возвращает путь ящика для приема файлов
+func dropBoxPath(dropBoxToken string) string {
+	return "/api/v1/drop/" + url.PathEscape(dropBoxToken)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound возвращается, если файл или другой запрошенный объект не найден на сервере
+	ErrNotFound = errors.New("не найдено")
+
+	// ErrChecksumMismatch возвращается, если контрольная сумма скачанного файла не совпадает
+	// с переданной сервером или сервер сам обнаружил несовпадение при сборке файла
+	ErrChecksumMismatch = errors.New("контрольная сумма файла не совпадает")
+
+	// ErrQuotaExceeded возвращается, если файл не помещается на серверы хранения или в
+	// оставшийся объем ящика
+	ErrQuotaExceeded = errors.New("недостаточно места для файла")
+)
+
+// APIError - ошибка, которую вернул API сервер. Code не зависит от языка сообщения,
+// поэтому ошибки различаются по коду, а не по тексту
+type APIError struct {
+	StatusCode int                    // HTTP статус ответа
+	Code       string                 // машиночитаемый код ошибки; пусто, если тело не в формате API
+	Message    string                 // сообщение сервера или тело ответа как есть
+	Details    map[string]interface{} // дополнительные сведения, зависящие от кода
+	RequestID  string                 // идентификатор запроса для поиска в журналах сервера
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("сервер вернул ошибку %d", e.StatusCode)
+	if e.Code != "" {
+		message += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		message += " [запрос " + e.RequestID + "]"
+	}
+	return message
+}
+
+// Is сопоставляет ошибку сервера с ErrNotFound, ErrChecksumMismatch и ErrQuotaExceeded,
+// чтобы их можно было проверить errors.Is независимо от текста сообщения
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrChecksumMismatch:
+		return e.Code == "checksum_mismatch"
+	case ErrQuotaExceeded:
+		return e.StatusCode == http.StatusInsufficientStorage || e.Code == "drop_box_quota_exceeded"
+	}
+	return false
+}
+
+// ErrorCode возвращает код ошибки API сервера из цепочки err или пустую строку. Для
+// ошибок gRPC API это Reason
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	var grpcErr *GRPCError
+	if errors.As(err, &grpcErr) {
+		return grpcErr.Reason
+	}
+	return ""
+}
+
+// newAPIError читает ответ с ошибкой. Тело не в формате ошибок API попадает в Message как есть
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	var envelope struct {
+		Error struct {
+			Code      string                 `json:"code"`
+			Message   string                 `json:"message"`
+			Details   map[string]interface{} `json:"details"`
+			RequestID string                 `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error.Code == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+
+	apiErr.Code = envelope.Error.Code
+	apiErr.Message = envelope.Error.Message
+	apiErr.Details = envelope.Error.Details
+	if envelope.Error.RequestID != "" {
+		apiErr.RequestID = envelope.Error.RequestID
+	}
+	return apiErr
+}
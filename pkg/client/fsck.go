@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"TestCase/pkg/fsck"
+)
+
+// CheckConsistency сверяет каталог API сервера с кусками на серверах хранения. С verify
+// серверы хранения пересчитывают контрольные суммы кусков
+func (ac *APIClient) CheckConsistency(verify bool) (*fsck.Report, error) {
+	return ac.CheckConsistencyContext(context.Background(), verify)
+}
+
+// CheckConsistencyContext сверяет каталог с кусками с учетом контекста
+func (ac *APIClient) CheckConsistencyContext(ctx context.Context, verify bool) (*fsck.Report, error) {
+	path := "/api/v1/admin/fsck"
+	if verify {
+		path += "?verify=true"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ac.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var report fsck.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+	return &report, nil
+}
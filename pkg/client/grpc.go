@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client/storagepb"
+)
+
+// grpcChunkSize - объем данных в одном сообщении загрузки через gRPC
+const grpcChunkSize = 256 << 10
+
+// GRPCClient - клиент публичного gRPC API (grpc_listen API сервера). Загружает и скачивает
+// файлы потоками без форм multipart. Для нескольких файлов в одном потоке можно
+// пользоваться заглушками storagepb напрямую через FileService
+type GRPCClient struct {
+	conn   *grpc.ClientConn
+	files  storagepb.FileServiceClient
+	apiKey string
+}
+
+// NewGRPCClient подключается к gRPC API по адресу target (host:port или unix:///path).
+// Без параметров соединение устанавливается без TLS; для TLS передайте
+// grpc.WithTransportCredentials
+func NewGRPCClient(target string, opts ...grpc.DialOption) (*GRPCClient, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к gRPC API: %w", err)
+	}
+	return &GRPCClient{conn: conn, files: storagepb.NewFileServiceClient(conn)}, nil
+}
+
+// SetAPIKey задает ключ API, передаваемый в метаданных authorization каждого вызова
+func (gc *GRPCClient) SetAPIKey(key string) {
+	gc.apiKey = key
+}
+
+// FileService возвращает заглушку сервиса с тем же соединением. Ключ API в ее вызовы
+// добавляет Context
+func (gc *GRPCClient) FileService() storagepb.FileServiceClient {
+	return gc.files
+}
+
+// Context добавляет к ctx ключ API клиента
+func (gc *GRPCClient) Context(ctx context.Context) context.Context {
+	if gc.apiKey == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+gc.apiKey)
+}
+
+// Close закрывает соединение
+func (gc *GRPCClient) Close() error {
+	return gc.conn.Close()
+}
+
+// UploadReader загружает данные из r под именем name. Размер size нужен только для
+// прогресса; если он неизвестен, size должен быть отрицательным. Учитываются параметры
+// WithPath, WithContentType, WithContentSHA256, WithProgress и WithStats
+func (gc *GRPCClient) UploadReader(ctx context.Context, name string, r io.Reader, size int64, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	options := newTransferOptions(opts)
+	ctx, cancel := context.WithCancel(gc.Context(ctx))
+	defer cancel()
+
+	stream, err := gc.files.UploadStream(ctx)
+	if err != nil {
+		return nil, grpcClientError(err)
+	}
+
+	header := &storagepb.UploadHeader{
+		Filename:      name,
+		ContentType:   options.contentType,
+		Path:          options.path,
+		ContentSha256: options.contentSHA256,
+	}
+	if err := stream.Send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Header{Header: header}}); err != nil {
+		return nil, uploadSendError(stream, err)
+	}
+
+	content := newTransferReader(r, size, options)
+	defer content.finish()
+	buffer := make([]byte, grpcChunkSize)
+	for {
+		n, readErr := io.ReadFull(content, buffer)
+		if n > 0 {
+			if err := stream.Send(&storagepb.UploadRequest{Message: &storagepb.UploadRequest_Data{Data: buffer[:n]}}); err != nil {
+				return nil, uploadSendError(stream, err)
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("не удалось прочитать данные: %w", readErr)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, grpcClientError(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, grpcClientError(err)
+	}
+	return resp.GetFile().Metadata(), nil
+}
+
+// uploadSendError возвращает причину обрыва потока загрузки. Если поток закрыл сервер,
+// ошибка отправки - io.EOF, а его ответ с ошибкой читается из потока
+func uploadSendError(stream storagepb.FileService_UploadStreamClient, err error) error {
+	if err == io.EOF {
+		_, err = stream.Recv()
+	}
+	return grpcClientError(err)
+}
+
+// Download записывает содержимое файла в w и возвращает метаданные файла
+func (gc *GRPCClient) Download(ctx context.Context, fileID string, w io.Writer, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return gc.DownloadRange(ctx, fileID, 0, 0, w, opts...)
+}
+
+// DownloadRange записывает в w length байт файла, начиная со смещения offset; length 0 -
+// до конца файла. Учитываются параметры WithProgress и WithStats
+func (gc *GRPCClient) DownloadRange(ctx context.Context, fileID string, offset, length int64, w io.Writer, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	options := newTransferOptions(opts)
+	ctx, cancel := context.WithCancel(gc.Context(ctx))
+	defer cancel()
+
+	stream, err := gc.files.DownloadStream(ctx)
+	if err != nil {
+		return nil, grpcClientError(err)
+	}
+	if err := stream.Send(&storagepb.DownloadRequest{FileId: fileID, Offset: offset, Length: length}); err != nil {
+		return nil, grpcClientError(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, grpcClientError(err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, grpcClientError(err)
+	}
+	header := resp.GetHeader()
+	if header == nil {
+		return nil, errors.New("сервер не прислал заголовок скачивания")
+	}
+
+	progress := newTransferReader(nil, header.GetLength(), options)
+	defer progress.finish()
+	var received int64
+	for received < header.GetLength() {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, grpcClientError(err)
+		}
+		data := resp.GetData()
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("не удалось записать данные: %w", err)
+		}
+		received += int64(len(data))
+		progress.add(int64(len(data)), received >= header.GetLength())
+	}
+	return header.GetFile().Metadata(), nil
+}
+
+// GRPCError - ошибка, которую вернул gRPC API. Reason совпадает с кодом ошибки REST API
+type GRPCError struct {
+	Code    codes.Code
+	Reason  string // машиночитаемый код ошибки; пусто, если сервер его не передал
+	Message string
+}
+
+func (e *GRPCError) Error() string {
+	message := fmt.Sprintf("сервер вернул ошибку gRPC %s", e.Code)
+	if e.Reason != "" {
+		message += " (" + e.Reason + ")"
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// Is сопоставляет ошибку с ErrNotFound, ErrChecksumMismatch и ErrQuotaExceeded так же,
+// как APIError
+func (e *GRPCError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == codes.NotFound
+	case ErrChecksumMismatch:
+		return e.Reason == "checksum_mismatch"
+	case ErrQuotaExceeded:
+		return e.Reason == "insufficient_storage"
+	}
+	return false
+}
+
+// grpcClientError переводит статус gRPC в GRPCError; ошибки без статуса и отмена
+// контекста возвращаются как есть
+func grpcClientError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.Canceled || st.Code() == codes.DeadlineExceeded {
+		return err
+	}
+
+	grpcErr := &GRPCError{Code: st.Code(), Message: st.Message()}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			grpcErr.Reason = info.GetReason()
+		}
+	}
+	return grpcErr
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"TestCase/pkg/chunking"
+)
+
+// GetMerkleProof получает корень Merkle дерева файла и пути доказательства для кусков,
+// покрывающих length байт с offset; length 0 - до конца файла
+func (ac *APIClient) GetMerkleProof(fileID string, offset, length int64) (*chunking.MerkleProof, error) {
+	return ac.GetMerkleProofContext(context.Background(), fileID, offset, length)
+}
+
+// GetMerkleProofContext получает пути доказательства для диапазона с учетом контекста
+func (ac *APIClient) GetMerkleProofContext(ctx context.Context, fileID string, offset, length int64) (*chunking.MerkleProof, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	query.Set("length", strconv.FormatInt(length, 10))
+
+	var proof chunking.MerkleProof
+	if err := ac.sendJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v1/files/%s/merkle?%s", fileID, query.Encode()), nil, &proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// ReadVerifiedRange возвращает length байт файла с offset (length 0 - до конца файла),
+// проверив каждый покрывающий их кусок по корню Merkle дерева из метаданных файла. С API
+// сервера скачиваются только эти куски, а не весь файл
+func (ac *APIClient) ReadVerifiedRange(fileID string, offset, length int64) ([]byte, error) {
+	return ac.ReadVerifiedRangeContext(context.Background(), fileID, offset, length)
+}
+
+// ReadVerifiedRangeContext возвращает проверенный диапазон файла с учетом контекста
+func (ac *APIClient) ReadVerifiedRangeContext(ctx context.Context, fileID string, offset, length int64) ([]byte, error) {
+	metadata, err := ac.GetFileInfoContext(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.MerkleRoot == "" {
+		return nil, fmt.Errorf("у файла %s нет корня Merkle дерева", fileID)
+	}
+
+	proof, err := ac.GetMerkleProofContext(ctx, fileID, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if proof.ChunkCount != len(metadata.Chunks) || len(proof.Chunks) == 0 {
+		return nil, fmt.Errorf("%w: доказательство не соответствует метаданным файла", chunking.ErrMerkleMismatch)
+	}
+	if length == 0 {
+		length = metadata.Size - offset
+	}
+
+	// Куски скачиваются целиком, иначе их нельзя проверить
+	first, last := proof.Chunks[0], proof.Chunks[len(proof.Chunks)-1]
+	start, end := first.Offset, last.Offset+last.Size
+	if offset < start || offset+length > end {
+		return nil, fmt.Errorf("%w: куски доказательства не покрывают диапазон", chunking.ErrMerkleMismatch)
+	}
+	data, err := ac.readRange(ctx, fileID, start, end-start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Расположение кусков не входит в дерево, поэтому сверяется с метаданными
+	offsets := chunking.NewSignature(metadata).Chunks
+	for i, chunk := range proof.Chunks {
+		index := first.Index + i
+		if chunk.Index != index || index >= len(offsets) || chunk.Offset != offsets[index].Offset || chunk.Size != offsets[index].Size {
+			return nil, fmt.Errorf("%w: расположение куска %d не совпадает с метаданными файла", chunking.ErrMerkleMismatch, chunk.Index)
+		}
+		from := chunk.Offset - start
+		if from < 0 || from+chunk.Size > int64(len(data)) {
+			return nil, fmt.Errorf("%w: кусок %d вне полученного диапазона", chunking.ErrMerkleMismatch, chunk.Index)
+		}
+		if err := proof.VerifyChunk(metadata.MerkleRoot, chunk, data[from:from+chunk.Size]); err != nil {
+			return nil, err
+		}
+	}
+	return data[offset-start : offset-start+length], nil
+}
+
+// readRange скачивает length байт файла с offset через запрос с заголовком Range
+func (ac *APIClient) readRange(ctx context.Context, fileID string, offset, length int64) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/files/%s", ac.baseURL, fileID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	ac.requestConsistency(req)
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, newAPIError(resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ответ: %w", err)
+	}
+	if int64(len(data)) != length {
+		return nil, fmt.Errorf("получено %d байт вместо %d", len(data), length)
+	}
+	return data, nil
+}
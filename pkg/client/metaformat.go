@@ -0,0 +1,64 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/metaformat"
+)
+
+// SetMetadataFormat задает формат, в котором API сервер возвращает метаданные файлов
+// при загрузке, запросе сведений и поиске по контрольной сумме. metaformat.Protobuf и
+// metaformat.MessagePack короче JSON и быстрее разбираются; сервер без их поддержки
+// ответит в JSON, и клиент разберет ответ по его Content-Type
+func (ac *APIClient) SetMetadataFormat(format metaformat.Format) {
+	ac.metadataFormat = format
+}
+
+// acceptMetadata просит сервер ответить метаданными в формате из SetMetadataFormat
+func (ac *APIClient) acceptMetadata(req *http.Request) {
+	if ac.metadataFormat != "" && ac.metadataFormat != metaformat.JSON {
+		req.Header.Set("Accept", ac.metadataFormat.ContentType()+", application/json;q=0.5")
+	}
+}
+
+// responseFormat возвращает формат метаданных в ответе по его Content-Type
+func responseFormat(resp *http.Response) metaformat.Format {
+	format, ok := metaformat.FromContentType(resp.Header.Get("Content-Type"))
+	if !ok {
+		return metaformat.JSON
+	}
+	return format
+}
+
+// decodeMetadata читает метаданные файла из ответа
+func decodeMetadata(resp *http.Response) (*chunking.FileMetadata, error) {
+	metadata, err := metaformat.DecodeFile(resp.Body, responseFormat(resp))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+	return metadata, nil
+}
+
+// decodeResult разбирает ответ в result: метаданные файлов - в формате ответа, остальное
+// как JSON
+func decodeResult(resp *http.Response, result interface{}) error {
+	var err error
+	switch result := result.(type) {
+	case *chunking.FileMetadata:
+		var metadata *chunking.FileMetadata
+		if metadata, err = metaformat.DecodeFile(resp.Body, responseFormat(resp)); err == nil {
+			*result = *metadata
+		}
+	case *[]*chunking.FileMetadata:
+		*result, err = metaformat.DecodeFiles(resp.Body, responseFormat(resp))
+	default:
+		err = json.NewDecoder(resp.Body).Decode(result)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось десериализовать ответ: %w", err)
+	}
+	return nil
+}
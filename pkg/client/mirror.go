@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MirrorStatus - состояние зеркалирования файлов в резервное развертывание
+type MirrorStatus struct {
+	Enabled bool   `json:"enabled"`
+	Target  string `json:"target,omitempty"` // адрес API приемника
+
+	Files   int `json:"files"`   // файлов, отраженных в приемник
+	Pending int `json:"pending"` // файлов с неотраженными изменениями
+
+	// LagSeconds - возраст самого старого неотраженного изменения; 0 - все отражено.
+	// LastLagSeconds - задержка отражения последнего отраженного файла
+	LagSeconds     float64 `json:"lag_seconds"`
+	LastLagSeconds float64 `json:"last_lag_seconds"`
+
+	Mirrored int64 `json:"mirrored"` // отражено изменений с запуска API сервера
+	Failed   int64 `json:"failed"`   // изменений, не отраженных после всех попыток
+
+	LastMirroredAt *time.Time `json:"last_mirrored_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	LastResyncAt   *time.Time `json:"last_resync_at,omitempty"`
+}
+
+// GetMirrorStatus возвращает состояние зеркалирования и задержку отражения изменений
+func (ac *APIClient) GetMirrorStatus() (*MirrorStatus, error) {
+	return ac.GetMirrorStatusContext(context.Background())
+}
+
+// GetMirrorStatusContext возвращает состояние зеркалирования с учетом контекста
+func (ac *APIClient) GetMirrorStatusContext(ctx context.Context) (*MirrorStatus, error) {
+	var status MirrorStatus
+	if err := ac.sendJSON(ctx, http.MethodGet, "/api/v1/admin/mirror", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ResyncMirror запускает внеочередную сверку приемника зеркала с каталогом
+func (ac *APIClient) ResyncMirror() error {
+	return ac.ResyncMirrorContext(context.Background())
+}
+
+// ResyncMirrorContext запускает сверку зеркала с учетом контекста
+func (ac *APIClient) ResyncMirrorContext(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ac.baseURL+"/api/v1/admin/mirror/resync", nil)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return newAPIError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"TestCase/pkg/storage"
+)
+
+// Option настраивает HTTP клиент, которым APIClient обращается к API серверу и к серверам
+// хранения при прямой передаче. Подходят и опции пакета storage
+type Option = storage.ClientOption
+
+// RetryPolicy - политика повтора запросов, см. storage.RetryPolicy
+type RetryPolicy = storage.RetryPolicy
+
+// Logger принимает сообщения клиента о запросах и их повторах. Подходит *log.Logger
+type Logger = storage.Logger
+
+// WithTimeout задает таймаут запроса вместо 5 минут по умолчанию
+func WithTimeout(timeout time.Duration) Option {
+	return storage.WithTimeout(timeout)
+}
+
+// WithTransport задает транспорт запросов, например с прокси или инструментированием
+func WithTransport(transport http.RoundTripper) Option {
+	return storage.WithTransport(transport)
+}
+
+// WithTLSConfig задает настройки TLS, например собственный корневой сертификат
+func WithTLSConfig(config *tls.Config) Option {
+	return storage.WithTLSConfig(config)
+}
+
+// WithRetryPolicy включает повтор идемпотентных запросов при сетевых ошибках и перегрузке сервера
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return storage.WithRetryPolicy(policy)
+}
+
+// WithLogger задает журнал, в который записываются запросы клиента и их повторы
+func WithLogger(logger Logger) Option {
+	return storage.WithLogger(logger)
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"TestCase/pkg/chunking"
+)
+
+// DownloadFileResume скачивает файл, продолжая с места обрыва: если outputPath уже содержит
+// начало файла, запрашивается только оставшийся диапазон, который дописывается в конец.
+// После скачивания контрольная сумма всего файла сверяется с переданной сервером.
+// При обрыве соединения скачанная часть сохраняется для следующего вызова, а при
+// несовпадении контрольной суммы файл удаляется
+func (ac *APIClient) DownloadFileResume(fileID, outputPath string, opts ...TransferOption) error {
+	return ac.DownloadFileResumeContext(context.Background(), fileID, outputPath, opts...)
+}
+
+// DownloadFileResumeContext - DownloadFileResume с контекстом
+func (ac *APIClient) DownloadFileResumeContext(ctx context.Context, fileID, outputPath string, opts ...TransferOption) error {
+	file, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть выходной файл: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("не удалось получить размер выходного файла: %w", err)
+	}
+	offset := info.Size()
+
+	resp, err := ac.requestFrom(ctx, fileID, offset)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			return fmt.Errorf("сервер вернул неожиданный диапазон %q", resp.Header.Get("Content-Range"))
+		}
+	case http.StatusOK:
+		// Сервер не поддерживает диапазоны: скачиваем файл заново
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || offset != total {
+			// Локальный файл длиннее файла на сервере: это не его начало
+			resp.Body.Close()
+			if err := file.Truncate(0); err != nil {
+				return fmt.Errorf("не удалось очистить выходной файл: %w", err)
+			}
+			file.Close()
+			return ac.DownloadFileResumeContext(ctx, fileID, outputPath, opts...)
+		}
+		// Файл уже скачан целиком, остается проверить контрольную сумму
+	default:
+		return newAPIError(resp)
+	}
+
+	hasher, err := chunking.NewHasher(chunking.HashAlgorithm(resp.Header.Get("X-Checksum-Algorithm")))
+	if err != nil {
+		return err
+	}
+
+	// Уже скачанная часть учитывается в контрольной сумме всего файла
+	if _, err := io.Copy(hasher, io.LimitReader(file, offset)); err != nil {
+		return fmt.Errorf("не удалось прочитать выходной файл: %w", err)
+	}
+	if err := file.Truncate(offset); err != nil {
+		return fmt.Errorf("не удалось подготовить выходной файл: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("не удалось подготовить выходной файл: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		content := newTransferReader(resp.Body, resp.ContentLength, newTransferOptions(opts))
+		_, err := io.Copy(io.MultiWriter(file, hasher), content)
+		content.finish()
+		if err != nil {
+			return fmt.Errorf("скачивание прервано, повторный вызов продолжит его: %w", err)
+		}
+	}
+
+	expected := resp.Header.Get("X-Checksum")
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); expected != "" && actual != expected {
+		file.Close()
+		os.Remove(outputPath)
+		return fmt.Errorf("%w: ожидалась %s, получена %s", ErrChecksumMismatch, expected, actual)
+	}
+
+	return nil
+}
+
+// requestFrom запрашивает содержимое файла начиная с байта offset
+func (ac *APIClient) requestFrom(ctx context.Context, fileID string, offset int64) (*http.Response, error) {
+	url := fmt.Sprintf("%s/api/v1/files/%s", ac.baseURL, fileID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	ac.requestConsistency(req)
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	return resp, nil
+}
+
+// parseContentRange разбирает заголовок вида "bytes 100-199/1000" или "bytes */1000" и
+// возвращает начало диапазона (-1 для второй формы) и размер файла
+func parseContentRange(header string) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("неверный заголовок Content-Range %q", header)
+	}
+	window, totalText, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("неверный заголовок Content-Range %q", header)
+	}
+	total, err := strconv.ParseInt(totalText, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("неверный заголовок Content-Range %q", header)
+	}
+	if window == "*" {
+		return -1, total, nil
+	}
+
+	startText, _, _ := strings.Cut(window, "-")
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("неверный заголовок Content-Range %q", header)
+	}
+	return start, total, nil
+}
@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"TestCase/pkg/chunking"
+)
+
+// FileService - основные операции с файлами хранилища. Его реализует APIClient, а
+// clienttest.FileService хранит файлы в памяти, чтобы приложения могли проверять код,
+// работающий с хранилищем, модульными тестами без запущенного кластера
+type FileService interface {
+	UploadReader(ctx context.Context, name string, r io.Reader, size int64, opts ...TransferOption) (*chunking.FileMetadata, error)
+	UploadFileContext(ctx context.Context, filePath string, opts ...TransferOption) (*chunking.FileMetadata, error)
+	OpenDownload(ctx context.Context, fileID string, opts ...TransferOption) (io.ReadCloser, error)
+	DownloadFileContext(ctx context.Context, fileID, outputPath string, opts ...TransferOption) error
+	GetFileInfoContext(ctx context.Context, fileID string) (*chunking.FileMetadata, error)
+	ListFilesFilteredContext(ctx context.Context, filter ListFilter) ([]string, error)
+	FindFilesByChecksumContext(ctx context.Context, checksum string) ([]*chunking.FileMetadata, error)
+	DeleteFileContext(ctx context.Context, fileID string) error
+}
+
+var _ FileService = (*APIClient)(nil)
@@ -0,0 +1,136 @@
+// Package storagepb содержит сгенерированные по storage.proto сообщения и заглушки
+// публичного gRPC API, а также преобразование метаданных файла в сообщения и обратно
+package storagepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative storage.proto
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"TestCase/pkg/chunking"
+)
+
+// NewFile переносит метаданные файла вместе с кусками в сообщение File
+func NewFile(metadata *chunking.FileMetadata) *File {
+	file := &File{
+		Id:                  metadata.ID,
+		OriginalName:        metadata.OriginalName,
+		Size:                metadata.Size,
+		Checksum:            metadata.Checksum,
+		ChecksumAlgorithm:   string(metadata.ChecksumAlgorithm),
+		ContentType:         metadata.ContentType,
+		Path:                metadata.Path,
+		ChunkCount:          int32(metadata.ChunkCount),
+		Public:              metadata.Public,
+		Owner:               metadata.Owner,
+		CreatedAt:           timestamppb.New(metadata.CreatedAt),
+		Generation:          metadata.Generation,
+		DetectedContentType: metadata.DetectedContentType,
+		ModifiedAt:          newTimestamp(metadata.ModifiedAt),
+		DropBox:             metadata.DropBox,
+		DownloadCount:       metadata.DownloadCount,
+		BytesServed:         metadata.BytesServed,
+		LastAccessedAt:      newTimestamp(metadata.LastAccessedAt),
+		MerkleRoot:          metadata.MerkleRoot,
+	}
+	for _, chunk := range metadata.Chunks {
+		file.Chunks = append(file.Chunks, &Chunk{
+			Id:        chunk.ID,
+			Index:     int32(chunk.Index),
+			FileId:    chunk.FileID,
+			Size:      chunk.Size,
+			Checksum:  chunk.Checksum,
+			Algorithm: string(chunk.Algorithm),
+			Node:      chunk.Node,
+			Replicas:  chunk.Replicas,
+		})
+	}
+	if len(metadata.Grants) > 0 {
+		file.Grants = make(map[string]string, len(metadata.Grants))
+		for principal, permission := range metadata.Grants {
+			file.Grants[principal] = string(permission)
+		}
+	}
+	return file
+}
+
+// Metadata возвращает метаданные файла из сообщения
+func (f *File) Metadata() *chunking.FileMetadata {
+	metadata := &chunking.FileMetadata{
+		ID:                  f.GetId(),
+		OriginalName:        f.GetOriginalName(),
+		Size:                f.GetSize(),
+		Checksum:            f.GetChecksum(),
+		ChecksumAlgorithm:   chunking.HashAlgorithm(f.GetChecksumAlgorithm()),
+		ContentType:         f.GetContentType(),
+		Path:                f.GetPath(),
+		ChunkCount:          int(f.GetChunkCount()),
+		Public:              f.GetPublic(),
+		Owner:               f.GetOwner(),
+		CreatedAt:           f.GetCreatedAt().AsTime(),
+		Generation:          f.GetGeneration(),
+		DetectedContentType: f.GetDetectedContentType(),
+		ModifiedAt:          timestampTime(f.GetModifiedAt()),
+		DropBox:             f.GetDropBox(),
+		DownloadCount:       f.GetDownloadCount(),
+		BytesServed:         f.GetBytesServed(),
+		LastAccessedAt:      timestampTime(f.GetLastAccessedAt()),
+		MerkleRoot:          f.GetMerkleRoot(),
+	}
+	for _, chunk := range f.GetChunks() {
+		metadata.Chunks = append(metadata.Chunks, chunking.FileChunk{
+			ID:        chunk.GetId(),
+			Index:     int(chunk.GetIndex()),
+			FileID:    chunk.GetFileId(),
+			Size:      chunk.GetSize(),
+			Checksum:  chunk.GetChecksum(),
+			Algorithm: chunking.HashAlgorithm(chunk.GetAlgorithm()),
+			Node:      chunk.GetNode(),
+			Replicas:  chunk.GetReplicas(),
+		})
+	}
+	if len(f.GetGrants()) > 0 {
+		metadata.Grants = make(map[string]chunking.Permission, len(f.GetGrants()))
+		for principal, permission := range f.GetGrants() {
+			metadata.Grants[principal] = chunking.Permission(permission)
+		}
+	}
+	return metadata
+}
+
+// NewFileList переносит метаданные нескольких файлов в сообщение FileList
+func NewFileList(files []*chunking.FileMetadata) *FileList {
+	list := &FileList{Files: make([]*File, 0, len(files))}
+	for _, metadata := range files {
+		list.Files = append(list.Files, NewFile(metadata))
+	}
+	return list
+}
+
+// Metadata возвращает метаданные файлов из сообщения
+func (l *FileList) Metadata() []*chunking.FileMetadata {
+	files := make([]*chunking.FileMetadata, 0, len(l.GetFiles()))
+	for _, file := range l.GetFiles() {
+		files = append(files, file.Metadata())
+	}
+	return files
+}
+
+// newTimestamp переводит необязательное время в сообщение; nil остается незаданным
+func newTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// timestampTime возвращает время из сообщения или nil, если оно не задано
+func timestampTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
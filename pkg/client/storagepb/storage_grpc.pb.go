@@ -0,0 +1,225 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: storage.proto
+
+// Публичный gRPC API потоковой загрузки и скачивания файлов. Повторяет загрузку и
+// скачивание REST API для клиентов, которым удобнее gRPC, чем формы multipart.
+// Сообщения File и FileList служат также форматом application/x-protobuf метаданных
+// в REST API
+
+package storagepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	FileService_UploadStream_FullMethodName   = "/storage.v1.FileService/UploadStream"
+	FileService_DownloadStream_FullMethodName = "/storage.v1.FileService/DownloadStream"
+)
+
+// FileServiceClient is the client API for FileService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FileServiceClient interface {
+	// UploadStream загружает файлы. Для каждого файла клиент отправляет заголовок, данные
+	// одним или несколькими сообщениями и признак конца файла, а сервер отвечает метаданными
+	// сохраненного файла. Закрытие потока клиентом тоже завершает файл
+	UploadStream(ctx context.Context, opts ...grpc.CallOption) (FileService_UploadStreamClient, error)
+	// DownloadStream скачивает файлы. На каждый запрос сервер отвечает заголовком с
+	// метаданными файла и длиной данных, а затем самими данными
+	DownloadStream(ctx context.Context, opts ...grpc.CallOption) (FileService_DownloadStreamClient, error)
+}
+
+type fileServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFileServiceClient(cc grpc.ClientConnInterface) FileServiceClient {
+	return &fileServiceClient{cc}
+}
+
+func (c *fileServiceClient) UploadStream(ctx context.Context, opts ...grpc.CallOption) (FileService_UploadStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FileService_ServiceDesc.Streams[0], FileService_UploadStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fileServiceUploadStreamClient{stream}
+	return x, nil
+}
+
+type FileService_UploadStreamClient interface {
+	Send(*UploadRequest) error
+	Recv() (*UploadResponse, error)
+	grpc.ClientStream
+}
+
+type fileServiceUploadStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *fileServiceUploadStreamClient) Send(m *UploadRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *fileServiceUploadStreamClient) Recv() (*UploadResponse, error) {
+	m := new(UploadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *fileServiceClient) DownloadStream(ctx context.Context, opts ...grpc.CallOption) (FileService_DownloadStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FileService_ServiceDesc.Streams[1], FileService_DownloadStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fileServiceDownloadStreamClient{stream}
+	return x, nil
+}
+
+type FileService_DownloadStreamClient interface {
+	Send(*DownloadRequest) error
+	Recv() (*DownloadResponse, error)
+	grpc.ClientStream
+}
+
+type fileServiceDownloadStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *fileServiceDownloadStreamClient) Send(m *DownloadRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *fileServiceDownloadStreamClient) Recv() (*DownloadResponse, error) {
+	m := new(DownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FileServiceServer is the server API for FileService service.
+// All implementations must embed UnimplementedFileServiceServer
+// for forward compatibility
+type FileServiceServer interface {
+	// UploadStream загружает файлы. Для каждого файла клиент отправляет заголовок, данные
+	// одним или несколькими сообщениями и признак конца файла, а сервер отвечает метаданными
+	// сохраненного файла. Закрытие потока клиентом тоже завершает файл
+	UploadStream(FileService_UploadStreamServer) error
+	// DownloadStream скачивает файлы. На каждый запрос сервер отвечает заголовком с
+	// метаданными файла и длиной данных, а затем самими данными
+	DownloadStream(FileService_DownloadStreamServer) error
+	mustEmbedUnimplementedFileServiceServer()
+}
+
+// UnimplementedFileServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedFileServiceServer struct {
+}
+
+func (UnimplementedFileServiceServer) UploadStream(FileService_UploadStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadStream not implemented")
+}
+func (UnimplementedFileServiceServer) DownloadStream(FileService_DownloadStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadStream not implemented")
+}
+func (UnimplementedFileServiceServer) mustEmbedUnimplementedFileServiceServer() {}
+
+// UnsafeFileServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FileServiceServer will
+// result in compilation errors.
+type UnsafeFileServiceServer interface {
+	mustEmbedUnimplementedFileServiceServer()
+}
+
+func RegisterFileServiceServer(s grpc.ServiceRegistrar, srv FileServiceServer) {
+	s.RegisterService(&FileService_ServiceDesc, srv)
+}
+
+func _FileService_UploadStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileServiceServer).UploadStream(&fileServiceUploadStreamServer{stream})
+}
+
+type FileService_UploadStreamServer interface {
+	Send(*UploadResponse) error
+	Recv() (*UploadRequest, error)
+	grpc.ServerStream
+}
+
+type fileServiceUploadStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *fileServiceUploadStreamServer) Send(m *UploadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *fileServiceUploadStreamServer) Recv() (*UploadRequest, error) {
+	m := new(UploadRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _FileService_DownloadStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileServiceServer).DownloadStream(&fileServiceDownloadStreamServer{stream})
+}
+
+type FileService_DownloadStreamServer interface {
+	Send(*DownloadResponse) error
+	Recv() (*DownloadRequest, error)
+	grpc.ServerStream
+}
+
+type fileServiceDownloadStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *fileServiceDownloadStreamServer) Send(m *DownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *fileServiceDownloadStreamServer) Recv() (*DownloadRequest, error) {
+	m := new(DownloadRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FileService_ServiceDesc is the grpc.ServiceDesc for FileService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FileService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storage.v1.FileService",
+	HandlerType: (*FileServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadStream",
+			Handler:       _FileService_UploadStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadStream",
+			Handler:       _FileService_DownloadStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "storage.proto",
+}
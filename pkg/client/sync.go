@@ -0,0 +1,453 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// SyncStateName - имя файла состояния синхронизации в локальном каталоге по умолчанию.
+// Сам файл не синхронизируется
+const SyncStateName = ".storage-sync.json"
+
+// syncTempSuffix - окончание имени временного файла, в который скачивается файл хранилища
+// перед заменой локального. Такие файлы не синхронизируются
+const syncTempSuffix = ".sync-tmp"
+
+// SyncOp - действие синхронизации над файлом
+type SyncOp string
+
+// Действия синхронизации
+const (
+	SyncUpload       SyncOp = "upload"        // загрузить локальный файл в хранилище
+	SyncDownload     SyncOp = "download"      // скачать файл хранилища в локальный каталог
+	SyncDeleteLocal  SyncOp = "delete-local"  // удалить локальный файл, удаленный в хранилище
+	SyncDeleteRemote SyncOp = "delete-remote" // удалить файл хранилища, удаленный локально
+)
+
+// SyncOptions - параметры синхронизации каталога
+type SyncOptions struct {
+	StatePath string // файл состояния; по умолчанию SyncStateName в синхронизируемом каталоге
+	DryRun    bool   // только определить действия, ничего не меняя
+}
+
+// SyncAction - действие синхронизации над одним файлом
+type SyncAction struct {
+	Path     string `json:"path"` // путь относительно каталога через "/"
+	Op       SyncOp `json:"op"`
+	FileID   string `json:"file_id,omitempty"`
+	Conflict bool   `json:"conflict,omitempty"` // файл изменен с обеих сторон, выбрана более новая версия
+	Error    string `json:"error,omitempty"`
+}
+
+// SyncResult - итог синхронизации каталога
+type SyncResult struct {
+	Actions   []SyncAction `json:"actions"` // по возрастанию пути
+	Unchanged int          `json:"unchanged"`
+}
+
+// syncState - состояние после прошлой синхронизации: по нему отличаются удаленные с
+// одной стороны файлы от новых с другой
+type syncState struct {
+	Prefix string                   `json:"prefix"`
+	Files  map[string]syncStateFile `json:"files"`
+}
+
+// syncStateFile - файл, одинаковый в каталоге и в хранилище после прошлой синхронизации
+type syncStateFile struct {
+	ID        string                 `json:"id"`
+	Checksum  string                 `json:"checksum"` // контрольная сумма файла в хранилище
+	Algorithm chunking.HashAlgorithm `json:"checksum_algorithm,omitempty"`
+	Size      int64                  `json:"size"`
+	ModTime   time.Time              `json:"mod_time"` // время изменения локального файла
+}
+
+// syncFile - состояние файла с одним относительным путем в каталоге, в хранилище и после
+// прошлой синхронизации; отсутствующие стороны равны nil
+type syncFile struct {
+	path   string
+	local  *dirEntry
+	remote *chunking.FileMetadata
+	state  *syncStateFile
+
+	checksums map[chunking.HashAlgorithm]string // контрольные суммы локального файла
+}
+
+// SyncDir синхронизирует локальный каталог dir с файлами хранилища, логические пути
+// которых начинаются с prefix, в обе стороны. Файлы сравниваются по контрольным суммам из
+// метаданных, передаются только изменившиеся. Состояние прошлой синхронизации позволяет
+// отличить удаление файла с одной стороны от нового файла на другой: удаление переносится
+// на другую сторону, если файл там с тех пор не менялся. Без состояния (первый запуск)
+// ничего не удаляется. Если файл изменен с обеих сторон, остается более новая версия.
+// Новые локальные файлы загружаются без передачи данных, если такое содержимое уже есть в
+// кластере. Ошибки отдельных файлов не прерывают синхронизацию остальных: они записываются
+// в Error действий, а SyncDir возвращает и итог, и ошибку
+func (ac *APIClient) SyncDir(dir, prefix string, options SyncOptions) (*SyncResult, error) {
+	return ac.SyncDirContext(context.Background(), dir, prefix, options)
+}
+
+// SyncDirContext синхронизирует каталог с учетом контекста
+func (ac *APIClient) SyncDirContext(ctx context.Context, dir, prefix string, options SyncOptions) (*SyncResult, error) {
+	prefix = strings.Trim(path.Clean("/"+prefix), "/")
+	statePath := options.StatePath
+	if statePath == "" {
+		statePath = filepath.Join(dir, SyncStateName)
+	}
+	state, err := loadSyncState(statePath, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ac.collectSyncFiles(ctx, dir, prefix, statePath, state)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Actions: []SyncAction{}}
+	failed := 0
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		action, err := file.plan()
+		if err != nil {
+			result.Actions = append(result.Actions, SyncAction{Path: file.path, Error: err.Error()})
+			failed++
+			continue
+		}
+		if action == nil {
+			if file.local != nil && file.remote != nil {
+				result.Unchanged++
+				state.Files[file.path] = syncStateFile{
+					ID:        file.remote.ID,
+					Checksum:  file.remote.Checksum,
+					Algorithm: file.remote.ChecksumAlgorithm,
+					Size:      file.local.size,
+					ModTime:   file.local.modTime,
+				}
+			} else {
+				delete(state.Files, file.path)
+			}
+			continue
+		}
+
+		if !options.DryRun {
+			if err := ac.applySyncAction(ctx, dir, prefix, file, action, state); err != nil {
+				action.Error = err.Error()
+				failed++
+			}
+		}
+		result.Actions = append(result.Actions, *action)
+	}
+
+	if options.DryRun {
+		return result, nil
+	}
+	if err := saveSyncState(statePath, state); err != nil {
+		return result, err
+	}
+	if failed > 0 {
+		return result, fmt.Errorf("не удалось синхронизировать файлов: %d", failed)
+	}
+	return result, nil
+}
+
+// collectSyncFiles сводит локальные файлы, файлы хранилища с префиксом prefix и состояние
+// прошлой синхронизации по относительным путям
+func (ac *APIClient) collectSyncFiles(ctx context.Context, dir, prefix, statePath string, state *syncState) ([]*syncFile, error) {
+	byPath := make(map[string]*syncFile)
+	file := func(relativePath string) *syncFile {
+		if byPath[relativePath] == nil {
+			byPath[relativePath] = &syncFile{path: relativePath, checksums: make(map[chunking.HashAlgorithm]string)}
+		}
+		return byPath[relativePath]
+	}
+
+	entries, err := walkUploadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	absState, _ := filepath.Abs(statePath)
+	for i := range entries {
+		entry := &entries[i]
+		if strings.HasSuffix(entry.path, syncTempSuffix) {
+			continue
+		}
+		if absEntry, _ := filepath.Abs(entry.path); absEntry == absState {
+			continue
+		}
+		file(entry.relativePath).local = entry
+	}
+
+	listPrefix := prefix
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+	ids, err := ac.ListFilesFilteredContext(ctx, ListFilter{Prefix: listPrefix})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		metadata, err := ac.GetFileInfoContext(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Путь файла хранилища должен оставаться внутри каталога. Из нескольких файлов с
+		// одним путем синхронизируется самый новый
+		relativePath := strings.TrimPrefix(metadata.Path, listPrefix)
+		if relativePath == "" || !filepath.IsLocal(filepath.FromSlash(relativePath)) {
+			continue
+		}
+		current := file(relativePath)
+		if current.remote == nil || metadata.CreatedAt.After(current.remote.CreatedAt) {
+			current.remote = metadata
+		}
+	}
+
+	for relativePath := range state.Files {
+		saved := state.Files[relativePath]
+		file(relativePath).state = &saved
+	}
+
+	files := make([]*syncFile, 0, len(byPath))
+	for _, file := range byPath {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// plan выбирает действие над файлом; nil - файл одинаков с обеих сторон или удален с обеих
+func (f *syncFile) plan() (*SyncAction, error) {
+	switch {
+	case f.local == nil && f.remote == nil:
+		return nil, nil
+
+	case f.remote == nil:
+		// Файл удален в хранилище: удаление переносится, если локально он не менялся
+		if f.state != nil {
+			changed, err := f.localChanged()
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				return &SyncAction{Path: f.path, Op: SyncDeleteLocal, FileID: f.state.ID}, nil
+			}
+		}
+		return &SyncAction{Path: f.path, Op: SyncUpload}, nil
+
+	case f.local == nil:
+		if f.state != nil && !f.remoteChanged() {
+			return &SyncAction{Path: f.path, Op: SyncDeleteRemote, FileID: f.remote.ID}, nil
+		}
+		return &SyncAction{Path: f.path, Op: SyncDownload, FileID: f.remote.ID}, nil
+	}
+
+	checksum, err := f.localChecksum(f.remote.ChecksumAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if checksum == f.remote.Checksum {
+		return nil, nil
+	}
+
+	localChanged := true
+	if f.state != nil {
+		if localChanged, err = f.localChanged(); err != nil {
+			return nil, err
+		}
+	}
+	remoteChanged := f.state == nil || f.remoteChanged()
+	switch {
+	case localChanged && !remoteChanged:
+		return &SyncAction{Path: f.path, Op: SyncUpload, FileID: f.remote.ID}, nil
+	case remoteChanged && !localChanged:
+		return &SyncAction{Path: f.path, Op: SyncDownload, FileID: f.remote.ID}, nil
+	}
+
+	// Файл изменен с обеих сторон: остается более новая версия
+	if f.local.modTime.After(f.remote.LastModified()) {
+		return &SyncAction{Path: f.path, Op: SyncUpload, FileID: f.remote.ID, Conflict: true}, nil
+	}
+	return &SyncAction{Path: f.path, Op: SyncDownload, FileID: f.remote.ID, Conflict: true}, nil
+}
+
+// localChanged сообщает, изменился ли локальный файл после прошлой синхронизации. Файл с
+// прежними размером и временем изменения не перечитывается
+func (f *syncFile) localChanged() (bool, error) {
+	if f.local.size == f.state.Size && f.local.modTime.Equal(f.state.ModTime) {
+		return false, nil
+	}
+	checksum, err := f.localChecksum(f.state.Algorithm)
+	if err != nil {
+		return false, err
+	}
+	return checksum != f.state.Checksum, nil
+}
+
+// remoteChanged сообщает, изменился ли файл хранилища после прошлой синхронизации
+func (f *syncFile) remoteChanged() bool {
+	return f.remote.ID != f.state.ID || f.remote.Checksum != f.state.Checksum
+}
+
+// localChecksum возвращает контрольную сумму локального файла алгоритмом algorithm
+func (f *syncFile) localChecksum(algorithm chunking.HashAlgorithm) (string, error) {
+	if checksum, ok := f.checksums[algorithm]; ok {
+		return checksum, nil
+	}
+	checksum, err := fileChecksum(f.local.path, algorithm)
+	if err != nil {
+		return "", err
+	}
+	f.checksums[algorithm] = checksum
+	return checksum, nil
+}
+
+// fileChecksum вычисляет контрольную сумму содержимого файла алгоритмом algorithm
+func fileChecksum(filePath string, algorithm chunking.HashAlgorithm) (string, error) {
+	hasher, err := chunking.NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// applySyncAction выполняет действие и обновляет состояние синхронизации
+func (ac *APIClient) applySyncAction(ctx context.Context, dir, prefix string, file *syncFile, action *SyncAction, state *syncState) error {
+	localPath := filepath.Join(dir, filepath.FromSlash(file.path))
+
+	switch action.Op {
+	case SyncUpload:
+		var opts []TransferOption
+		if contentType := mime.TypeByExtension(filepath.Ext(localPath)); contentType != "" {
+			opts = append(opts, WithContentType(contentType))
+		}
+		var metadata *chunking.FileMetadata
+		var err error
+		if file.remote != nil {
+			// Замена разрешена, только если файл хранилища не изменился после сравнения
+			metadata, err = ac.ReplaceFileContext(ctx, file.remote.ID, localPath, `"`+file.remote.Checksum+`"`, opts...)
+		} else {
+			opts = append(opts, WithPath(path.Join(prefix, file.path)))
+			metadata, err = ac.UploadFileDedupContext(ctx, localPath, opts...)
+		}
+		if err != nil {
+			return err
+		}
+		action.FileID = metadata.ID
+		return recordSyncFile(state, file.path, localPath, metadata)
+
+	case SyncDownload:
+		if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+			return fmt.Errorf("не удалось создать каталог: %w", err)
+		}
+		// Файл скачивается рядом и заменяет локальный, только если скачан целиком и
+		// сошлась контрольная сумма
+		tempPath := localPath + syncTempSuffix
+		if err := ac.DownloadFileContext(ctx, file.remote.ID, tempPath); err != nil {
+			os.Remove(tempPath)
+			return err
+		}
+		if err := os.Rename(tempPath, localPath); err != nil {
+			os.Remove(tempPath)
+			return fmt.Errorf("не удалось заменить файл: %w", err)
+		}
+		return recordSyncFile(state, file.path, localPath, file.remote)
+
+	case SyncDeleteLocal:
+		if err := os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("не удалось удалить файл: %w", err)
+		}
+		delete(state.Files, file.path)
+		return nil
+
+	case SyncDeleteRemote:
+		if err := ac.DeleteFileContext(ctx, file.remote.ID); err != nil {
+			return err
+		}
+		delete(state.Files, file.path)
+		return nil
+	}
+	return fmt.Errorf("неизвестное действие синхронизации %q", action.Op)
+}
+
+// recordSyncFile запоминает, что локальный файл localPath совпадает с файлом хранилища metadata
+func recordSyncFile(state *syncState, relativePath, localPath string, metadata *chunking.FileMetadata) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("не удалось получить сведения о файле: %w", err)
+	}
+	state.Files[relativePath] = syncStateFile{
+		ID:        metadata.ID,
+		Checksum:  metadata.Checksum,
+		Algorithm: metadata.ChecksumAlgorithm,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+	}
+	return nil
+}
+
+// loadSyncState читает состояние прошлой синхронизации. Состояние для другого префикса
+// не используется, как и отсутствующее
+func loadSyncState(statePath, prefix string) (*syncState, error) {
+	empty := &syncState{Prefix: prefix, Files: make(map[string]syncStateFile)}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать состояние синхронизации: %w", err)
+	}
+
+	var state syncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать состояние синхронизации %s: %w", statePath, err)
+	}
+	if state.Prefix != prefix || state.Files == nil {
+		return empty, nil
+	}
+	return &state, nil
+}
+
+// saveSyncState записывает состояние синхронизации через временный файл, чтобы прерванная
+// запись не испортила прежнее
+func saveSyncState(statePath string, state *syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать состояние синхронизации: %w", err)
+	}
+	tempPath := statePath + syncTempSuffix
+	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+		return fmt.Errorf("не удалось записать состояние синхронизации: %w", err)
+	}
+	if err := os.Rename(tempPath, statePath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("не удалось записать состояние синхронизации: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultProgressInterval - минимальный интервал между вызовами обработчика прогресса
+const defaultProgressInterval = 100 * time.Millisecond
+
+// defaultConcurrency - число одновременно скачиваемых кусков по умолчанию
+const defaultConcurrency = 4
+
+// Progress описывает текущее состояние передачи
+type Progress struct {
+	Transferred int64   // передано байт
+	Total       int64   // общий размер; -1, если неизвестен
+	Rate        float64 // средняя скорость, байт в секунду
+	Done        bool    // передача завершена, это последний вызов обработчика
+}
+
+// ProgressFunc вызывается по мере передачи данных
+type ProgressFunc func(Progress)
+
+// TransferStats содержит итоговую статистику передачи
+type TransferStats struct {
+	Bytes     int64         `json:"bytes"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Rate      float64       `json:"rate"` // средняя скорость, байт в секунду
+}
+
+// TransferOption настраивает загрузку или скачивание файла
+type TransferOption func(*transferOptions)
+
+type transferOptions struct {
+	progress         ProgressFunc
+	progressInterval time.Duration
+	stats            *TransferStats
+	concurrency      int
+	contentSHA256    string
+	contentType      string
+	path             string
+	policy           uploadPolicy // политика хранения загружаемого файла
+}
+
+// WithProgress задает обработчик прогресса передачи.
+// Обработчик вызывается не чаще интервала прогресса и обязательно по завершении передачи
+func WithProgress(fn ProgressFunc) TransferOption {
+	return func(o *transferOptions) {
+		o.progress = fn
+	}
+}
+
+// WithProgressInterval задает минимальный интервал между вызовами обработчика прогресса
+func WithProgressInterval(interval time.Duration) TransferOption {
+	return func(o *transferOptions) {
+		o.progressInterval = interval
+	}
+}
+
+// WithStats задает структуру, в которую будет записана статистика передачи
+func WithStats(stats *TransferStats) TransferOption {
+	return func(o *transferOptions) {
+		o.stats = stats
+	}
+}
+
+// WithConcurrency задает число кусков, передаваемых одновременно при прямой загрузке и
+// скачивании, и число файлов, загружаемых одновременно UploadDir
+func WithConcurrency(n int) TransferOption {
+	return func(o *transferOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithContentSHA256 передает при загрузке SHA256 содержимого в шестнадцатеричной записи.
+// Сервер сверяет с ней полученные данные и отклоняет загрузку, если они повреждены в пути
+func WithContentSHA256(checksum string) TransferOption {
+	return func(o *transferOptions) {
+		o.contentSHA256 = checksum
+	}
+}
+
+// WithContentType задает при загрузке тип содержимого файла вместо application/octet-stream
+func WithContentType(contentType string) TransferOption {
+	return func(o *transferOptions) {
+		o.contentType = contentType
+	}
+}
+
+// WithPath задает при загрузке логический путь файла, например bucket/key
+func WithPath(path string) TransferOption {
+	return func(o *transferOptions) {
+		o.path = path
+	}
+}
+
+// uploadPolicy - политика хранения загружаемого файла; нулевые значения - настройки сервера
+type uploadPolicy struct {
+	chunkCount        int
+	chunkSize         int64
+	replicationFactor int
+	storageClass      string
+}
+
+// WithChunkCount задает при загрузке число кусков файла вместо выбранного сервером.
+// Сервер отклоняет число больше допустимого в его настройках
+func WithChunkCount(n int) TransferOption {
+	return func(o *transferOptions) {
+		o.policy.chunkCount = n
+	}
+}
+
+// WithChunkSize задает при загрузке желаемый размер куска файла в байтах
+func WithChunkSize(size int64) TransferOption {
+	return func(o *transferOptions) {
+		o.policy.chunkSize = size
+	}
+}
+
+// WithReplicationFactor задает при загрузке число копий каждого куска файла вместо
+// replication_factor сервера, например 1 для временных файлов
+func WithReplicationFactor(n int) TransferOption {
+	return func(o *transferOptions) {
+		o.policy.replicationFactor = n
+	}
+}
+
+// WithStorageClass задает при загрузке класс хранения из storage_classes сервера
+func WithStorageClass(class string) TransferOption {
+	return func(o *transferOptions) {
+		o.policy.storageClass = class
+	}
+}
+
+// withPolicy задает всю политику хранения загружаемого файла
+func withPolicy(policy uploadPolicy) TransferOption {
+	return func(o *transferOptions) {
+		o.policy = policy
+	}
+}
+
+// withQuery добавляет к пути запроса загрузки параметры политики хранения
+func (p uploadPolicy) withQuery(path string) string {
+	query := make(url.Values)
+	if p.chunkCount > 0 {
+		query.Set("chunk_count", strconv.Itoa(p.chunkCount))
+	}
+	if p.chunkSize > 0 {
+		query.Set("chunk_size", strconv.FormatInt(p.chunkSize, 10))
+	}
+	if p.replicationFactor > 0 {
+		query.Set("replication_factor", strconv.Itoa(p.replicationFactor))
+	}
+	if p.storageClass != "" {
+		query.Set("storage_class", p.storageClass)
+	}
+	if len(query) == 0 {
+		return path
+	}
+	if strings.Contains(path, "?") {
+		return path + "&" + query.Encode()
+	}
+	return path + "?" + query.Encode()
+}
+
+func newTransferOptions(opts []TransferOption) *transferOptions {
+	options := &transferOptions{progressInterval: defaultProgressInterval, concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// transferReader считает переданные байты, сообщает о прогрессе и заполняет статистику
+type transferReader struct {
+	reader   io.Reader
+	options  *transferOptions
+	total    int64
+	read     int64
+	started  time.Time
+	reported time.Time
+	finished bool
+	mutex    sync.Mutex
+}
+
+func newTransferReader(r io.Reader, total int64, options *transferOptions) *transferReader {
+	if total < 0 {
+		total = -1
+	}
+	now := time.Now()
+	return &transferReader{
+		reader:   r,
+		options:  options,
+		total:    total,
+		started:  now,
+		reported: now,
+	}
+}
+
+func (r *transferReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.add(int64(n), err == io.EOF)
+	return n, err
+}
+
+// add учитывает n переданных байт. Используется и напрямую, когда данные
+// передаются несколькими потоками, а не читаются из одного reader
+func (r *transferReader) add(n int64, done bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.read += n
+	if done {
+		r.finishLocked()
+	} else if r.options.progress != nil && time.Since(r.reported) >= r.options.progressInterval {
+		r.reported = time.Now()
+		r.options.progress(r.progressLocked())
+	}
+}
+
+// finish фиксирует итог передачи, если поток не был дочитан до конца
+func (r *transferReader) finish() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.finishLocked()
+}
+
+func (r *transferReader) finishLocked() {
+	if r.finished {
+		return
+	}
+	r.finished = true
+
+	if r.options.progress != nil {
+		progress := r.progressLocked()
+		progress.Done = true
+		r.options.progress(progress)
+	}
+
+	if r.options.stats != nil {
+		duration := time.Since(r.started)
+		*r.options.stats = TransferStats{
+			Bytes:     r.read,
+			StartedAt: r.started,
+			Duration:  duration,
+			Rate:      rate(r.read, duration),
+		}
+	}
+}
+
+func (r *transferReader) progressLocked() Progress {
+	return Progress{
+		Transferred: r.read,
+		Total:       r.total,
+		Rate:        rate(r.read, time.Since(r.started)),
+	}
+}
+
+// rate вычисляет среднюю скорость передачи в байтах в секунду
+func rate(bytes int64, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(bytes) / duration.Seconds()
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// UploadTokenRequest - ограничения выдаваемого токена загрузки; нулевые значения
+// означают ограничения сервера по умолчанию
+type UploadTokenRequest struct {
+	ExpiresIn    time.Duration // срок действия, с точностью до секунды
+	MaxSize      int64         // наибольший размер файла
+	ContentTypes []string      // разрешенные типы содержимого (тип/подтип или тип/*)
+}
+
+// UploadToken - токен однократной загрузки файла
+type UploadToken struct {
+	Token        string    `json:"token"`
+	FileID       string    `json:"file_id"` // идентификатор, который получит загруженный файл
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxSize      int64     `json:"max_size"`
+	ContentTypes []string  `json:"content_types,omitempty"`
+	UploadURL    string    `json:"upload_url"`
+}
+
+// CreateUploadToken получает токен, по которому один файл можно загрузить без ключа API.
+// Требует upload_token_secret на API сервере
+func (ac *APIClient) CreateUploadToken(request UploadTokenRequest) (*UploadToken, error) {
+	return ac.CreateUploadTokenContext(context.Background(), request)
+}
+
+// CreateUploadTokenContext получает токен загрузки с учетом контекста
+func (ac *APIClient) CreateUploadTokenContext(ctx context.Context, request UploadTokenRequest) (*UploadToken, error) {
+	body := map[string]interface{}{
+		"expires_in":    int64(request.ExpiresIn / time.Second),
+		"max_size":      request.MaxSize,
+		"content_types": request.ContentTypes,
+	}
+
+	var uploadToken UploadToken
+	if err := ac.postJSON(ctx, "/api/v1/upload-tokens", body, &uploadToken); err != nil {
+		return nil, err
+	}
+	return &uploadToken, nil
+}
+
+// UploadFileWithToken загружает файл по токену загрузки вместо ключа API
+func (ac *APIClient) UploadFileWithToken(filePath, uploadToken string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	return ac.UploadFileWithTokenContext(context.Background(), filePath, uploadToken, opts...)
+}
+
+// UploadFileWithTokenContext загружает файл по токену загрузки с учетом контекста
+func (ac *APIClient) UploadFileWithTokenContext(ctx context.Context, filePath, uploadToken string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить размер файла: %w", err)
+	}
+
+	return ac.UploadReaderWithToken(ctx, filepath.Base(filePath), file, info.Size(), uploadToken, opts...)
+}
+
+// UploadReaderWithToken загружает по токену загрузки данные из r под именем name, не
+// буферизуя их целиком. Если размер заранее неизвестен, size должен быть отрицательным
+func (ac *APIClient) UploadReaderWithToken(ctx context.Context, name string, r io.Reader, size int64, uploadToken string, opts ...TransferOption) (*chunking.FileMetadata, error) {
+	path := "/api/v1/files?token=" + url.QueryEscape(uploadToken)
+	return ac.uploadReader(ctx, path, name, r, size, opts...)
+}
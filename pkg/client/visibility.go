@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Visibility - видимость файла
+type Visibility struct {
+	ID     string `json:"id"`
+	Public bool   `json:"public"`
+	URL    string `json:"url,omitempty"` // полный адрес для скачивания без ключа API; только у публичного файла
+}
+
+// SetFileVisibility делает файл публичным (доступным по ссылке без ключа API) или закрытым
+func (ac *APIClient) SetFileVisibility(fileID string, public bool) (*Visibility, error) {
+	return ac.SetFileVisibilityContext(context.Background(), fileID, public)
+}
+
+// SetFileVisibilityContext изменяет видимость файла с учетом контекста
+func (ac *APIClient) SetFileVisibilityContext(ctx context.Context, fileID string, public bool) (*Visibility, error) {
+	var visibility Visibility
+	path := fmt.Sprintf("/api/v1/files/%s/visibility", fileID)
+	if err := ac.sendJSON(ctx, http.MethodPut, path, map[string]bool{"public": public}, &visibility); err != nil {
+		return nil, err
+	}
+
+	if visibility.URL != "" {
+		visibility.URL = ac.baseURL + visibility.URL
+	}
+	return &visibility, nil
+}
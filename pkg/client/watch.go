@@ -0,0 +1,427 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"TestCase/pkg/chunking"
+)
+
+// Параметры наблюдения за каталогом по умолчанию
+const (
+	defaultWatchDebounce      = 2 * time.Second
+	defaultWatchRetryInterval = 5 * time.Second
+	defaultWatchMaxRetry      = 5 * time.Minute
+)
+
+// WatchOptions - параметры наблюдения за каталогом
+type WatchOptions struct {
+	Prefix string // префикс логических путей файлов в хранилище
+
+	// Debounce - сколько файл не должен меняться, чтобы считаться записанным; по умолчанию
+	// 2 секунды
+	Debounce time.Duration
+
+	// RetryInterval - задержка перед повторной загрузкой после ошибки (по умолчанию 5
+	// секунд); удваивается с каждой попыткой до MaxRetryInterval (по умолчанию 5 минут)
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	Concurrency int  // файлов, загружаемых одновременно; по умолчанию 4
+	Initial     bool // при запуске загрузить уже лежащие в каталоге новые и измененные файлы
+
+	// Notify, если задана, вызывается после каждой попытки загрузки
+	Notify func(WatchEvent)
+}
+
+// WatchEvent - итог попытки загрузить файл из наблюдаемого каталога
+type WatchEvent struct {
+	RelativePath string                 // путь относительно каталога через "/"
+	Metadata     *chunking.FileMetadata // файл в хранилище; nil при ошибке
+	Unchanged    bool                   // содержимое уже совпадает с хранилищем, данные не передавались
+	Attempt      int                    // номер попытки, начиная с 1
+	Err          error                  // ошибка попытки; загрузка будет повторена через RetryAt
+	RetryAt      time.Time
+}
+
+// watchResult - итог загрузки файла, выполненной в отдельной горутине
+type watchResult struct {
+	relativePath string
+	metadata     *chunking.FileMetadata
+	unchanged    bool
+	gone         bool // файл удален до загрузки
+	err          error
+}
+
+// watchedFile - состояние файла наблюдаемого каталога
+type watchedFile struct {
+	timer      *time.Timer // ожидание окончания записи или повторной попытки
+	generation int         // номер последнего таймера; сработавшие раньше устарели
+	uploading  bool
+	dirty      bool // файл изменился во время загрузки
+	attempts   int  // неудачные попытки подряд
+}
+
+// watchTick - срабатывание таймера файла
+type watchTick struct {
+	relativePath string
+	generation   int
+}
+
+// WatchDir следит за каталогом dir с подкаталогами и загружает новые и измененные файлы,
+// когда они перестают меняться в течение Debounce. Логическим путем файла становится его
+// путь относительно dir с префиксом Prefix. Файл, уже загруженный в хранилище по этому
+// пути, заменяется с сохранением идентификатора, а не изменившееся содержимое не
+// передается. Неудавшиеся загрузки повторяются с растущей задержкой, пока не пройдут.
+// Удаление локальных файлов в хранилище не переносится (для этого есть SyncDir), файлы
+// с именами, начинающимися с точки, пропускаются. Работает до ошибки наблюдения
+func (ac *APIClient) WatchDir(dir string, options WatchOptions) error {
+	return ac.WatchDirContext(context.Background(), dir, options)
+}
+
+// WatchDirContext следит за каталогом до отмены контекста
+func (ac *APIClient) WatchDirContext(ctx context.Context, dir string, options WatchOptions) error {
+	if options.Debounce <= 0 {
+		options.Debounce = defaultWatchDebounce
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = defaultWatchRetryInterval
+	}
+	if options.MaxRetryInterval < options.RetryInterval {
+		options.MaxRetryInterval = max(defaultWatchMaxRetry, options.RetryInterval)
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = defaultConcurrency
+	}
+	prefix := strings.Trim(path.Clean("/"+options.Prefix), "/")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("не удалось начать наблюдение: %w", err)
+	}
+	defer watcher.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Файлы, уже загруженные по логическим путям с префиксом: их содержимое заменяется
+	remote, err := ac.remoteFilesByPath(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	w := &dirWatcher{
+		client:    ac,
+		dir:       dir,
+		prefix:    prefix,
+		options:   options,
+		watcher:   watcher,
+		remote:    remote,
+		files:     make(map[string]*watchedFile),
+		ready:     make(chan watchTick),
+		results:   make(chan watchResult),
+		semaphore: make(chan struct{}, options.Concurrency),
+	}
+	if err := w.addTree(ctx, dir, options.Initial); err != nil {
+		return err
+	}
+	return w.run(ctx)
+}
+
+// remoteFilesByPath возвращает файлы хранилища с префиксом prefix по путям относительно
+// него; из нескольких файлов с одним путем берется самый новый
+func (ac *APIClient) remoteFilesByPath(ctx context.Context, prefix string) (map[string]*chunking.FileMetadata, error) {
+	listPrefix := prefix
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+	ids, err := ac.ListFilesFilteredContext(ctx, ListFilter{Prefix: listPrefix})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*chunking.FileMetadata)
+	for _, id := range ids {
+		metadata, err := ac.GetFileInfoContext(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		relativePath := strings.TrimPrefix(metadata.Path, listPrefix)
+		if relativePath == "" {
+			continue
+		}
+		if current := files[relativePath]; current == nil || metadata.CreatedAt.After(current.CreatedAt) {
+			files[relativePath] = metadata
+		}
+	}
+	return files, nil
+}
+
+// dirWatcher - состояние наблюдения за каталогом. Поля, кроме каналов, меняются только
+// в цикле run
+type dirWatcher struct {
+	client  *APIClient
+	dir     string
+	prefix  string
+	options WatchOptions
+	watcher *fsnotify.Watcher
+
+	remote map[string]*chunking.FileMetadata // файлы хранилища по относительным путям
+	files  map[string]*watchedFile
+
+	ready     chan watchTick   // файл готов к загрузке
+	results   chan watchResult // итоги загрузок
+	semaphore chan struct{}
+}
+
+// run обрабатывает события файловой системы, таймеры и итоги загрузок до отмены контекста
+func (w *dirWatcher) run(ctx context.Context) error {
+	defer w.stopTimers()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(ctx, event)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("ошибка наблюдения за каталогом: %w", err)
+
+		case tick := <-w.ready:
+			w.startUpload(ctx, tick)
+
+		case result := <-w.results:
+			w.finishUpload(ctx, result)
+		}
+	}
+}
+
+// handleEvent откладывает загрузку созданного или измененного файла, а новые каталоги
+// добавляет к наблюдению вместе с их файлами
+func (w *dirWatcher) handleEvent(ctx context.Context, event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	if skipWatched(event.Name) {
+		return
+	}
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		// Файлы могли появиться в каталоге раньше, чем он попал под наблюдение
+		w.addTree(ctx, event.Name, true)
+		return
+	}
+	if info.Mode().IsRegular() {
+		w.scheduleFile(ctx, event.Name)
+	}
+}
+
+// addTree добавляет к наблюдению каталог root с подкаталогами; с uploadFiles его файлы
+// ставятся в очередь загрузки
+func (w *dirWatcher) addTree(ctx context.Context, root string, uploadFiles bool) error {
+	return filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Каталог мог быть удален во время обхода
+			if errors.Is(err, fs.ErrNotExist) && filePath != w.dir {
+				return nil
+			}
+			return err
+		}
+		if filePath != w.dir && skipWatched(filePath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := w.watcher.Add(filePath); err != nil {
+				return fmt.Errorf("не удалось наблюдать за каталогом %s: %w", filePath, err)
+			}
+			return nil
+		}
+		if uploadFiles && d.Type().IsRegular() {
+			w.scheduleFile(ctx, filePath)
+		}
+		return nil
+	})
+}
+
+// skipWatched сообщает, что файл или каталог не загружается: его имя начинается с точки
+func skipWatched(filePath string) bool {
+	return strings.HasPrefix(filepath.Base(filePath), ".")
+}
+
+// scheduleFile загружает файл filePath, когда он не будет меняться в течение Debounce.
+// Файл, который сейчас загружается, будет загружен еще раз
+func (w *dirWatcher) scheduleFile(ctx context.Context, filePath string) {
+	relativePath, err := filepath.Rel(w.dir, filePath)
+	if err != nil {
+		return
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	file := w.files[relativePath]
+	if file == nil {
+		file = &watchedFile{}
+		w.files[relativePath] = file
+	}
+	if file.uploading {
+		file.dirty = true
+		return
+	}
+	w.schedule(ctx, relativePath, file, w.options.Debounce)
+}
+
+// schedule загружает файл через delay, отменяя прежний таймер
+func (w *dirWatcher) schedule(ctx context.Context, relativePath string, file *watchedFile, delay time.Duration) {
+	if file.timer != nil {
+		file.timer.Stop()
+	}
+	file.generation++
+	tick := watchTick{relativePath: relativePath, generation: file.generation}
+	file.timer = time.AfterFunc(delay, func() {
+		select {
+		case w.ready <- tick:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// startUpload загружает файл в отдельной горутине, если таймер не устарел
+func (w *dirWatcher) startUpload(ctx context.Context, tick watchTick) {
+	relativePath := tick.relativePath
+	file := w.files[relativePath]
+	if file == nil || file.uploading || file.generation != tick.generation {
+		return
+	}
+	file.timer = nil
+	file.uploading = true
+	remote := w.remote[relativePath]
+
+	go func() {
+		select {
+		case w.semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		result := w.upload(ctx, relativePath, remote)
+		<-w.semaphore
+
+		select {
+		case w.results <- result:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// upload загружает файл или заменяет содержимое уже загруженного по тому же пути
+func (w *dirWatcher) upload(ctx context.Context, relativePath string, remote *chunking.FileMetadata) watchResult {
+	result := watchResult{relativePath: relativePath}
+	localPath := filepath.Join(w.dir, filepath.FromSlash(relativePath))
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() {
+		result.gone = true
+		return result
+	}
+
+	var opts []TransferOption
+	if contentType := mime.TypeByExtension(filepath.Ext(localPath)); contentType != "" {
+		opts = append(opts, WithContentType(contentType))
+	}
+	if remote != nil {
+		checksum, err := fileChecksum(localPath, remote.ChecksumAlgorithm)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		if checksum == remote.Checksum && info.Size() == remote.Size {
+			result.metadata, result.unchanged = remote, true
+			return result
+		}
+		result.metadata, result.err = w.client.ReplaceFileContext(ctx, remote.ID, localPath, "", opts...)
+		if !errors.Is(result.err, ErrNotFound) {
+			return result
+		}
+		// Файл удалили из хранилища: загружается заново
+	}
+
+	opts = append(opts, WithPath(path.Join(w.prefix, relativePath)))
+	result.metadata, result.err = w.client.UploadFileDedupContext(ctx, localPath, opts...)
+	return result
+}
+
+// finishUpload учитывает итог загрузки: при ошибке планирует повтор, а если файл
+// изменился во время загрузки - загружает его снова
+func (w *dirWatcher) finishUpload(ctx context.Context, result watchResult) {
+	file := w.files[result.relativePath]
+	if file == nil {
+		return
+	}
+	file.uploading = false
+	if result.gone {
+		delete(w.files, result.relativePath)
+		return
+	}
+
+	event := WatchEvent{
+		RelativePath: result.relativePath,
+		Metadata:     result.metadata,
+		Unchanged:    result.unchanged,
+		Attempt:      file.attempts + 1,
+		Err:          result.err,
+	}
+	if result.err != nil {
+		file.attempts++
+		delay := w.options.RetryInterval << min(file.attempts-1, 30)
+		if delay <= 0 || delay > w.options.MaxRetryInterval {
+			delay = w.options.MaxRetryInterval
+		}
+		event.RetryAt = time.Now().Add(delay)
+		file.dirty = false
+		w.schedule(ctx, result.relativePath, file, delay)
+	} else {
+		file.attempts = 0
+		w.remote[result.relativePath] = result.metadata
+		if file.dirty {
+			file.dirty = false
+			w.schedule(ctx, result.relativePath, file, w.options.Debounce)
+		} else {
+			delete(w.files, result.relativePath)
+		}
+	}
+	if w.options.Notify != nil {
+		w.options.Notify(event)
+	}
+}
+
+// stopTimers останавливает отложенные загрузки
+func (w *dirWatcher) stopTimers() {
+	for _, file := range w.files {
+		if file.timer != nil {
+			file.timer.Stop()
+		}
+	}
+}
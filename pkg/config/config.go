@@ -0,0 +1,476 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config содержит конфигурацию приложения
+type Config struct {
+	// Настройки API сервера
+	APIPort string `yaml:"api_port"`
+	APIHost string `yaml:"api_host"`
+
+	// Настройки серверов хранения
+	StorageServers []string `yaml:"storage_servers"`
+	StoragePort    string   `yaml:"storage_port"`
+
+	// Listen - адрес unix сокета unix:///path, на котором сервер принимает запросы вместо
+	// TCP порта, например за локальным обратным прокси. Пусто - api_host:api_port у API
+	// сервера и storage_port у сервера хранения
+	Listen string `yaml:"listen"`
+
+	// Ограничения HTTP соединений API сервера и серверов хранения
+	HTTPReadHeaderTimeout time.Duration `yaml:"http_read_header_timeout"` // время на чтение заголовков запроса; не дает медленным клиентам занимать соединения
+	HTTPReadTimeout       time.Duration `yaml:"http_read_timeout"`        // время на чтение запроса вместе с телом; 0 - без ограничения
+	HTTPWriteTimeout      time.Duration `yaml:"http_write_timeout"`       // время на отправку ответа; 0 - без ограничения, чтобы не обрывать большие скачивания и потоки событий
+	HTTPIdleTimeout       time.Duration `yaml:"http_idle_timeout"`        // время простоя keep-alive соединения между запросами
+	HTTPMaxHeaderBytes    int           `yaml:"http_max_header_bytes"`    // наибольший размер заголовков запроса в байтах
+	GinMode               string        `yaml:"gin_mode"`                 // режим Gin: release, debug или test
+	HTTP2Cleartext        bool          `yaml:"http2_cleartext"`          // HTTP/2 без TLS (h2c) между API сервером и серверами хранения
+
+	// HTTPS и экспериментальный HTTP/3 (QUIC) API сервера
+	TLSCertFile  string `yaml:"tls_cert_file"` // сертификат API сервера в PEM; вместе с tls_key_file включает HTTPS
+	TLSKeyFile   string `yaml:"tls_key_file"`  // закрытый ключ сертификата в PEM
+	HTTP3Enabled bool   `yaml:"http3_enabled"` // принимать HTTP/3 на UDP порту API сервера и предлагать его при скачивании через Alt-Svc
+
+	// GRPCListen - адрес host:port или unix:///path публичного gRPC API загрузки и
+	// скачивания файлов. С tls_cert_file gRPC тоже работает по TLS. Пусто - gRPC выключен
+	GRPCListen string `yaml:"grpc_listen"`
+
+	// Размещение кусков с учетом свободного места на серверах хранения
+	StorageCapacity         int64         `yaml:"storage_capacity"`          // объем данных сервера хранения в байтах; 0 - по свободной памяти системы
+	CapacityRefreshInterval time.Duration `yaml:"capacity_refresh_interval"` // период опроса свободного места; 0 - размещать по кругу
+
+	// Копии кусков на нескольких серверах хранения
+	ReplicationFactor int           `yaml:"replication_factor"` // число копий каждого куска на разных серверах
+	RepairInterval    time.Duration `yaml:"repair_interval"`    // период поиска и восстановления недостающих копий; 0 - только по запросу
+
+	// WriteQuorum - сколько копий каждого куска должно сохраниться, чтобы загрузка считалась
+	// успешной. Недостающие копии создает восстановление. 0 - все replication_factor копий
+	WriteQuorum int `yaml:"write_quorum"`
+
+	// StorageClasses - классы хранения в формате имя=число_копий, например archive=3. Клиент
+	// выбирает класс при загрузке, и файл получает его число копий вместо replication_factor
+	StorageClasses []string `yaml:"storage_classes"`
+
+	// ReadConsistency - сколько копий куска должны совпасть с контрольной суммой из метаданных,
+	// прежде чем кусок будет отдан при скачивании: one - первая ответившая копия, quorum -
+	// большинство копий, all - все копии. Запрос может задать свой уровень
+	ReadConsistency string `yaml:"read_consistency"`
+
+	// Регионы для чтения с ближайших копий: куски читаются сначала с серверов хранения
+	// региона API сервера и только при их недоступности - из других регионов
+	Region         string   `yaml:"region"`          // регион API сервера; пусто - порядок копий не меняется
+	StorageRegions []string `yaml:"storage_regions"` // регионы серверов хранения в формате адрес=регион
+
+	// Ограничения выравнивания данных между серверами хранения по умолчанию
+	RebalanceBytesPerSecond int64 `yaml:"rebalance_max_bytes_per_second"` // средняя скорость переноса; 0 - без ограничения
+	RebalanceConcurrency    int   `yaml:"rebalance_max_concurrent_moves"` // одновременно переносимые куски
+
+	// Обнаружение серверов хранения через реестр вместо storage_servers
+	DiscoveryBackend  string `yaml:"discovery_backend"`  // consul или etcd; пусто - статический список storage_servers
+	DiscoveryEndpoint string `yaml:"discovery_endpoint"` // адрес HTTP API реестра
+	DiscoveryService  string `yaml:"discovery_service"`  // имя сервиса серверов хранения в Consul
+	DiscoveryPrefix   string `yaml:"discovery_prefix"`   // префикс ключей с адресами серверов в etcd
+
+	// Настройки файлов
+	MaxFileSize int64  `yaml:"max_file_size"` // в байтах
+	ChunkCount  int    `yaml:"chunk_count"`   // количество частей для разделения файла
+	UploadDir   string `yaml:"upload_dir"`    // директория для временных файлов
+	StorageDir  string `yaml:"storage_dir"`   // директория для хранения частей файлов
+
+	// Число кусков по размеру файла: с TargetChunkSize файл делится на куски примерно
+	// такого размера, но не меньше MinChunkCount и не больше MaxChunkCount (0 - числа
+	// серверов хранения, принимающих куски). Без TargetChunkSize - всегда ChunkCount кусков
+	TargetChunkSize int64 `yaml:"target_chunk_size"` // в байтах
+	MinChunkCount   int   `yaml:"min_chunk_count"`
+	MaxChunkCount   int   `yaml:"max_chunk_count"`
+
+	// SmallFileThreshold - файлы меньше этого размера в байтах сохраняются одним куском, а
+	// не chunk_count кусками на разных серверах; 0 - всегда chunk_count кусков
+	SmallFileThreshold int64 `yaml:"small_file_threshold"`
+
+	// Сети, из которых сервер скачивает ресурсы для /files/fetch. По умолчанию разрешены только
+	// публичные адреса; адрес проверяется после разрешения имени при каждом соединении
+	FetchAllowedNetworks []string `yaml:"fetch_allowed_networks"` // CIDR закрытых сетей, из которых скачивать разрешено
+	FetchDeniedNetworks  []string `yaml:"fetch_denied_networks"`  // CIDR, из которых скачивать запрещено; важнее разрешенных
+
+	// UploadMemoryLimit - сколько байт загружаемого файла держать в памяти; файл больше
+	// этого целиком записывается во временный файл в upload_dir. 0 - всегда в памяти
+	UploadMemoryLimit int64 `yaml:"upload_memory_limit"`
+
+	ChecksumAlgorithm string `yaml:"checksum_algorithm"` // алгоритм контрольных сумм: sha256, blake3, xxhash
+
+	AllowedContentTypes []string `yaml:"allowed_content_types"` // разрешенные типы содержимого, например image/*; пустой список - любые
+
+	// Правила для имен загружаемых файлов
+	AllowedExtensions         []string `yaml:"allowed_extensions"`          // разрешенные расширения, например pdf или tar.gz; пустой список - любые
+	BlockedExtensions         []string `yaml:"blocked_extensions"`          // запрещенные расширения, например exe
+	MaxFilenameLength         int      `yaml:"max_filename_length"`         // максимальная длина имени в символах; 0 - без ограничения
+	ForbiddenFilenamePatterns []string `yaml:"forbidden_filename_patterns"` // регулярные выражения запрещенных имен
+
+	// Проверка загружаемых файлов антивирусом
+	Scanner         string        `yaml:"scanner"`           // clamav или icap; пусто - без проверки
+	ScannerAddress  string        `yaml:"scanner_address"`   // host:port clamd или icap://host:port/service
+	ScannerTimeout  time.Duration `yaml:"scanner_timeout"`   // предельное время проверки одного файла
+	ScannerFailOpen bool          `yaml:"scanner_fail_open"` // принимать файлы, если проверка не удалась
+
+	// Аутентификация клиентов API
+	APIKeys   []string `yaml:"api_keys"`   // ключи в формате имя:ключ; пустой список - API доступен без ключа
+	APIAdmins []string `yaml:"api_admins"` // имена ключей из api_keys, которым доступно администрирование /api/v1/admin
+
+	// Подписанные токены загрузки: прямая загрузка кусков на серверы хранения и однократная загрузка файла без ключа API
+	UploadTokenSecret string        `yaml:"upload_token_secret"` // общий ключ подписи API сервера и серверов хранения; пусто - токены загрузки отключены
+	UploadTokenTTL    time.Duration `yaml:"upload_token_ttl"`    // срок действия плана загрузки и токенов; наибольший срок выдаваемых токенов
+	DropBoxMaxTTL     time.Duration `yaml:"drop_box_max_ttl"`    // наибольший срок действия ссылки для приема файлов
+
+	// Подпись внутренних запросов API сервера к серверам хранения
+	InternalSecret           string        `yaml:"internal_secret"`             // общий ключ HMAC; пусто - серверы хранения принимают запросы без подписи
+	InternalSignatureMaxSkew time.Duration `yaml:"internal_signature_max_skew"` // допустимое расхождение времени подписи и часов сервера хранения
+
+	// Хранение каталога метаданных файлов
+	MetadataStore string   `yaml:"metadata_store"` // memory, raft, postgres или redis
+	FileIDScheme  string   `yaml:"file_id_scheme"` // формат идентификаторов новых файлов: uuidv4, uuidv7 или ulid
+	MetadataDSN   string   `yaml:"metadata_dsn"`   // строка подключения к PostgreSQL или Redis
+	RaftNodeID    string   `yaml:"raft_node_id"`   // адрес API этого узла или raft_forward_listen, доступный остальным узлам
+	RaftBind      string   `yaml:"raft_bind"`      // адрес для обмена сообщениями Raft
+	RaftAdvertise string   `yaml:"raft_advertise"` // адрес Raft для остальных узлов; пусто - raft_bind
+	RaftDir       string   `yaml:"raft_dir"`       // директория журнала и снимков Raft
+	RaftPeers     []string `yaml:"raft_peers"`     // узлы кластера в формате raft_node_id=raft_advertise
+
+	// RaftForwardListen - адрес host:port внутреннего HTTP сервера, на который остальные узлы
+	// пересылают изменения каталога лидеру. Пусто - изменения принимаются на адресе API
+	RaftForwardListen string `yaml:"raft_forward_listen"`
+
+	// Настройки сохранения хранилища на диск
+	PersistenceEnabled bool          `yaml:"storage_persistence"` // включить журнал и снимки хранилища в памяти
+	SnapshotInterval   time.Duration `yaml:"snapshot_interval"`   // период создания снимков
+	SyncWrites         bool          `yaml:"storage_sync_writes"` // fsync после каждой записи в журнал
+
+	// Внесение сбоев в ответы сервера хранения для проверки повторов, восстановления и репликации
+	FaultInjection     bool          `yaml:"fault_injection"`      // включить внесение сбоев; не для рабочих кластеров
+	FaultLatency       time.Duration `yaml:"fault_latency"`        // задержка перед каждым ответом
+	FaultLatencyJitter time.Duration `yaml:"fault_latency_jitter"` // наибольшая случайная добавка к задержке
+	FaultErrorRate     float64       `yaml:"fault_error_rate"`     // доля запросов, на которые отвечается ошибкой 500, от 0 до 1
+	FaultTruncateRate  float64       `yaml:"fault_truncate_rate"`  // доля ответов с кусками, которые обрываются на середине, от 0 до 1
+
+	// Настройки webhook уведомлений
+	WebhookURLs           []string      `yaml:"webhook_urls"`             // адреса получателей событий
+	WebhookSecret         string        `yaml:"webhook_secret"`           // ключ подписи событий
+	WebhookMaxRetries     int           `yaml:"webhook_max_retries"`      // количество повторных попыток доставки
+	WebhookRetryDelay     time.Duration `yaml:"webhook_retry_delay"`      // задержка перед первым повтором
+	WebhookDeadLetterFile string        `yaml:"webhook_dead_letter_file"` // журнал недоставленных событий
+
+	// Асинхронное зеркалирование файлов в резервное развертывание
+	MirrorURL            string        `yaml:"mirror_url"`             // адрес API приемника; пустой отключает зеркалирование
+	MirrorAPIKey         string        `yaml:"mirror_api_key"`         // ключ API приемника
+	MirrorStateFile      string        `yaml:"mirror_state_file"`      // журнал соответствия файлов и их копий
+	MirrorConcurrency    int           `yaml:"mirror_concurrency"`     // число одновременно отражаемых файлов
+	MirrorMaxRetries     int           `yaml:"mirror_max_retries"`     // количество повторных попыток отражения файла
+	MirrorRetryDelay     time.Duration `yaml:"mirror_retry_delay"`     // задержка перед первым повтором
+	MirrorResyncInterval time.Duration `yaml:"mirror_resync_interval"` // период сверки приемника с каталогом; 0 - только при запуске
+
+	// Настройки журнала аудита
+	AuditSinks     []string      `yaml:"audit_sinks"`     // приемники: file, stdout; none отключает аудит
+	AuditFile      string        `yaml:"audit_file"`      // файл журнала для приемника file
+	AuditRetention time.Duration `yaml:"audit_retention"` // срок хранения записей; 0 - хранить бессрочно
+
+	// Кэширование скачиваемых файлов обратными прокси и CDN
+	CacheControl       string `yaml:"cache_control"`        // заголовок Cache-Control при скачивании по ключу API; пусто - не передавать
+	PublicCacheControl string `yaml:"public_cache_control"` // заголовок Cache-Control при скачивании по публичной ссылке
+
+	// InlineContentTypes - типы содержимого, которые браузер может показать сам при
+	// скачивании с ?disposition=inline, например image/* или application/pdf. Файлы
+	// остальных типов всегда отдаются как вложение; пустой список отключает показ
+	InlineContentTypes []string `yaml:"inline_content_types"`
+
+	// ChunkCacheSize - объем кэша недавно прочитанных кусков на API сервере в байтах; 0 - без кэша
+	ChunkCacheSize int64 `yaml:"chunk_cache_size"`
+
+	// DownloadPrefetchChunks - сколько следующих кусков запрашивать с серверов хранения,
+	// пока клиенту передается текущий, при последовательной передаче файла; 0 - без
+	// упреждающего чтения
+	DownloadPrefetchChunks int `yaml:"download_prefetch_chunks"`
+
+	// AccessStatsInterval - период записи статистики скачиваний файлов в каталог; 0 - при каждом скачивании
+	AccessStatsInterval time.Duration `yaml:"access_stats_interval"`
+
+	// Настройки CORS
+	CORSAllowedOrigins   []string      `yaml:"cors_allowed_origins"`   // разрешенные источники; пустой список отключает CORS, * - любой источник
+	CORSAllowedMethods   []string      `yaml:"cors_allowed_methods"`   // методы, разрешенные в предварительных запросах
+	CORSAllowedHeaders   []string      `yaml:"cors_allowed_headers"`   // заголовки запроса; пустой список разрешает запрошенные браузером
+	CORSExposedHeaders   []string      `yaml:"cors_exposed_headers"`   // заголовки ответа, доступные скриптам
+	CORSAllowCredentials bool          `yaml:"cors_allow_credentials"` // разрешить передачу cookie и заголовка Authorization
+	CORSMaxAge           time.Duration `yaml:"cors_max_age"`           // время кэширования предварительного запроса
+
+	DocsEnabled      bool `yaml:"docs_enabled"`      // страница Swagger UI по адресу /docs
+	GraphQLEnabled   bool `yaml:"graphql_enabled"`   // запросы метаданных GraphQL по адресу /api/v1/graphql
+	DashboardEnabled bool `yaml:"dashboard_enabled"` // веб-панель администратора по адресу /dashboard/
+
+	// envErrors - ошибки разбора переменных окружения, о которых сообщает Validate
+	envErrors []error
+}
+
+// Defaults возвращает конфигурацию со значениями по умолчанию
+func Defaults() *Config {
+	return &Config{
+		APIPort:                  "8080",
+		APIHost:                  "0.0.0.0",
+		StoragePort:              "8081",
+		HTTPReadHeaderTimeout:    10 * time.Second,
+		HTTPIdleTimeout:          2 * time.Minute,
+		HTTPMaxHeaderBytes:       1 << 20, // 1 MiB
+		GinMode:                  "release",
+		CapacityRefreshInterval:  15 * time.Second,
+		ReplicationFactor:        1,
+		ReadConsistency:          "one",
+		RepairInterval:           10 * time.Minute,
+		RebalanceBytesPerSecond:  50 * 1024 * 1024, // 50 MiB/s
+		RebalanceConcurrency:     2,
+		DiscoveryService:         "storage",
+		DiscoveryPrefix:          "/storage/nodes/",
+		MaxFileSize:              10 * 1024 * 1024 * 1024, // 10 GiB
+		ChunkCount:               6,
+		UploadDir:                "./uploads",
+		UploadMemoryLimit:        32 * 1024 * 1024, // 32 MiB
+		SmallFileThreshold:       1024 * 1024,      // 1 MiB
+		MinChunkCount:            1,
+		StorageDir:               "./storage",
+		ChecksumAlgorithm:        "sha256",
+		MaxFilenameLength:        255,
+		ScannerTimeout:           time.Minute,
+		UploadTokenTTL:           time.Hour,
+		DropBoxMaxTTL:            7 * 24 * time.Hour,
+		InternalSignatureMaxSkew: time.Minute,
+		MetadataStore:            "memory",
+		FileIDScheme:             "uuidv4",
+		RaftBind:                 "0.0.0.0:7000",
+		RaftDir:                  "./raft",
+		PersistenceEnabled:       false,
+		SnapshotInterval:         5 * time.Minute,
+		SyncWrites:               false,
+		WebhookMaxRetries:        5,
+		WebhookRetryDelay:        time.Second,
+		WebhookDeadLetterFile:    "./webhook_dead_letter.jsonl",
+		MirrorStateFile:          "./mirror_state.jsonl",
+		MirrorConcurrency:        2,
+		MirrorMaxRetries:         5,
+		MirrorRetryDelay:         time.Second,
+		MirrorResyncInterval:     time.Hour,
+		AuditSinks:               []string{"file"},
+		AuditFile:                "./audit.log",
+		AuditRetention:           90 * 24 * time.Hour,
+		CacheControl:             "private, no-cache",
+		PublicCacheControl:       "public, max-age=3600",
+		InlineContentTypes:       []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "video/*", "audio/*", "text/plain"},
+		ChunkCacheSize:           256 * 1024 * 1024, // 256 MiB
+		DownloadPrefetchChunks:   4,
+		AccessStatsInterval:      30 * time.Second,
+		CORSAllowedMethods:       []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		CORSExposedHeaders:       []string{"ETag", "X-Checksum", "X-Checksum-Algorithm", "Content-Disposition", "Content-Length", "Content-Range", "Accept-Ranges", "Digest", "X-Merkle-Root"},
+		CORSMaxAge:               10 * time.Minute,
+		DocsEnabled:              true,
+		StorageServers:           []string{"localhost:8081", "localhost:8082", "localhost:8083", "localhost:8084", "localhost:8085", "localhost:8086"},
+	}
+}
+
+// NewConfig создает новую конфигурацию с значениями по умолчанию,
+// переопределенными переменными окружения
+func NewConfig() *Config {
+	cfg := Defaults()
+	cfg.applyEnv()
+	return cfg
+}
+
+// applyEnv переопределяет значения заданными переменными окружения
+func (c *Config) applyEnv() {
+	c.APIPort = getEnv("API_PORT", c.APIPort)
+	c.APIHost = getEnv("API_HOST", c.APIHost)
+	c.StoragePort = getEnv("STORAGE_PORT", c.StoragePort)
+	c.Listen = getEnv("LISTEN", c.Listen)
+	c.HTTPReadHeaderTimeout = c.getEnvDuration("HTTP_READ_HEADER_TIMEOUT", c.HTTPReadHeaderTimeout)
+	c.HTTPReadTimeout = c.getEnvDuration("HTTP_READ_TIMEOUT", c.HTTPReadTimeout)
+	c.HTTPWriteTimeout = c.getEnvDuration("HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout)
+	c.HTTPIdleTimeout = c.getEnvDuration("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout)
+	c.HTTPMaxHeaderBytes = c.getEnvInt("HTTP_MAX_HEADER_BYTES", c.HTTPMaxHeaderBytes)
+	c.GinMode = getEnv("GIN_MODE", c.GinMode)
+	c.HTTP2Cleartext = c.getEnvBool("HTTP2_CLEARTEXT", c.HTTP2Cleartext)
+	c.TLSCertFile = getEnv("TLS_CERT_FILE", c.TLSCertFile)
+	c.TLSKeyFile = getEnv("TLS_KEY_FILE", c.TLSKeyFile)
+	c.HTTP3Enabled = c.getEnvBool("HTTP3_ENABLED", c.HTTP3Enabled)
+	c.GRPCListen = getEnv("GRPC_LISTEN", c.GRPCListen)
+	c.StorageCapacity = c.getEnvInt64("STORAGE_CAPACITY", c.StorageCapacity)
+	c.CapacityRefreshInterval = c.getEnvDuration("CAPACITY_REFRESH_INTERVAL", c.CapacityRefreshInterval)
+	c.ReplicationFactor = c.getEnvInt("REPLICATION_FACTOR", c.ReplicationFactor)
+	c.WriteQuorum = c.getEnvInt("WRITE_QUORUM", c.WriteQuorum)
+	c.StorageClasses = getEnvSlice("STORAGE_CLASSES", c.StorageClasses)
+	c.ReadConsistency = getEnv("READ_CONSISTENCY", c.ReadConsistency)
+	c.RepairInterval = c.getEnvDuration("REPAIR_INTERVAL", c.RepairInterval)
+	c.Region = getEnv("REGION", c.Region)
+	c.StorageRegions = getEnvSlice("STORAGE_REGIONS", c.StorageRegions)
+	c.RebalanceBytesPerSecond = c.getEnvInt64("REBALANCE_MAX_BYTES_PER_SECOND", c.RebalanceBytesPerSecond)
+	c.RebalanceConcurrency = c.getEnvInt("REBALANCE_MAX_CONCURRENT_MOVES", c.RebalanceConcurrency)
+	c.DiscoveryBackend = getEnv("DISCOVERY_BACKEND", c.DiscoveryBackend)
+	c.DiscoveryEndpoint = getEnv("DISCOVERY_ENDPOINT", c.DiscoveryEndpoint)
+	c.DiscoveryService = getEnv("DISCOVERY_SERVICE", c.DiscoveryService)
+	c.DiscoveryPrefix = getEnv("DISCOVERY_PREFIX", c.DiscoveryPrefix)
+	c.MaxFileSize = c.getEnvInt64("MAX_FILE_SIZE", c.MaxFileSize)
+	c.ChunkCount = c.getEnvInt("CHUNK_COUNT", c.ChunkCount)
+	c.UploadDir = getEnv("UPLOAD_DIR", c.UploadDir)
+	c.UploadMemoryLimit = c.getEnvInt64("UPLOAD_MEMORY_LIMIT", c.UploadMemoryLimit)
+	c.FetchAllowedNetworks = getEnvSlice("FETCH_ALLOWED_NETWORKS", c.FetchAllowedNetworks)
+	c.FetchDeniedNetworks = getEnvSlice("FETCH_DENIED_NETWORKS", c.FetchDeniedNetworks)
+	c.SmallFileThreshold = c.getEnvInt64("SMALL_FILE_THRESHOLD", c.SmallFileThreshold)
+	c.TargetChunkSize = c.getEnvInt64("TARGET_CHUNK_SIZE", c.TargetChunkSize)
+	c.MinChunkCount = c.getEnvInt("MIN_CHUNK_COUNT", c.MinChunkCount)
+	c.MaxChunkCount = c.getEnvInt("MAX_CHUNK_COUNT", c.MaxChunkCount)
+	c.StorageDir = getEnv("STORAGE_DIR", c.StorageDir)
+	c.ChecksumAlgorithm = getEnv("CHECKSUM_ALGORITHM", c.ChecksumAlgorithm)
+	c.AllowedContentTypes = getEnvSlice("ALLOWED_CONTENT_TYPES", c.AllowedContentTypes)
+	c.AllowedExtensions = getEnvSlice("ALLOWED_EXTENSIONS", c.AllowedExtensions)
+	c.BlockedExtensions = getEnvSlice("BLOCKED_EXTENSIONS", c.BlockedExtensions)
+	c.MaxFilenameLength = c.getEnvInt("MAX_FILENAME_LENGTH", c.MaxFilenameLength)
+	c.ForbiddenFilenamePatterns = getEnvSlice("FORBIDDEN_FILENAME_PATTERNS", c.ForbiddenFilenamePatterns)
+	c.Scanner = getEnv("SCANNER", c.Scanner)
+	c.ScannerAddress = getEnv("SCANNER_ADDRESS", c.ScannerAddress)
+	c.ScannerTimeout = c.getEnvDuration("SCANNER_TIMEOUT", c.ScannerTimeout)
+	c.ScannerFailOpen = c.getEnvBool("SCANNER_FAIL_OPEN", c.ScannerFailOpen)
+	c.APIKeys = getEnvSlice("API_KEYS", c.APIKeys)
+	c.APIAdmins = getEnvSlice("API_ADMINS", c.APIAdmins)
+	c.UploadTokenSecret = getEnv("UPLOAD_TOKEN_SECRET", c.UploadTokenSecret)
+	c.UploadTokenTTL = c.getEnvDuration("UPLOAD_TOKEN_TTL", c.UploadTokenTTL)
+	c.InternalSecret = getEnv("INTERNAL_SECRET", c.InternalSecret)
+	c.InternalSignatureMaxSkew = c.getEnvDuration("INTERNAL_SIGNATURE_MAX_SKEW", c.InternalSignatureMaxSkew)
+	c.DropBoxMaxTTL = c.getEnvDuration("DROP_BOX_MAX_TTL", c.DropBoxMaxTTL)
+	c.MetadataStore = getEnv("METADATA_STORE", c.MetadataStore)
+	c.FileIDScheme = getEnv("FILE_ID_SCHEME", c.FileIDScheme)
+	c.MetadataDSN = getEnv("METADATA_DSN", c.MetadataDSN)
+	c.RaftNodeID = getEnv("RAFT_NODE_ID", c.RaftNodeID)
+	c.RaftBind = getEnv("RAFT_BIND", c.RaftBind)
+	c.RaftAdvertise = getEnv("RAFT_ADVERTISE", c.RaftAdvertise)
+	c.RaftDir = getEnv("RAFT_DIR", c.RaftDir)
+	c.RaftPeers = getEnvSlice("RAFT_PEERS", c.RaftPeers)
+	c.RaftForwardListen = getEnv("RAFT_FORWARD_LISTEN", c.RaftForwardListen)
+	c.PersistenceEnabled = c.getEnvBool("STORAGE_PERSISTENCE", c.PersistenceEnabled)
+	c.SnapshotInterval = c.getEnvDuration("SNAPSHOT_INTERVAL", c.SnapshotInterval)
+	c.SyncWrites = c.getEnvBool("STORAGE_SYNC_WRITES", c.SyncWrites)
+	c.FaultInjection = c.getEnvBool("FAULT_INJECTION", c.FaultInjection)
+	c.FaultLatency = c.getEnvDuration("FAULT_LATENCY", c.FaultLatency)
+	c.FaultLatencyJitter = c.getEnvDuration("FAULT_LATENCY_JITTER", c.FaultLatencyJitter)
+	c.FaultErrorRate = c.getEnvFloat("FAULT_ERROR_RATE", c.FaultErrorRate)
+	c.FaultTruncateRate = c.getEnvFloat("FAULT_TRUNCATE_RATE", c.FaultTruncateRate)
+	c.WebhookURLs = getEnvSlice("WEBHOOK_URLS", c.WebhookURLs)
+	c.WebhookSecret = getEnv("WEBHOOK_SECRET", c.WebhookSecret)
+	c.WebhookMaxRetries = c.getEnvInt("WEBHOOK_MAX_RETRIES", c.WebhookMaxRetries)
+	c.WebhookRetryDelay = c.getEnvDuration("WEBHOOK_RETRY_DELAY", c.WebhookRetryDelay)
+	c.WebhookDeadLetterFile = getEnv("WEBHOOK_DEAD_LETTER_FILE", c.WebhookDeadLetterFile)
+	c.MirrorURL = getEnv("MIRROR_URL", c.MirrorURL)
+	c.MirrorAPIKey = getEnv("MIRROR_API_KEY", c.MirrorAPIKey)
+	c.MirrorStateFile = getEnv("MIRROR_STATE_FILE", c.MirrorStateFile)
+	c.MirrorConcurrency = c.getEnvInt("MIRROR_CONCURRENCY", c.MirrorConcurrency)
+	c.MirrorMaxRetries = c.getEnvInt("MIRROR_MAX_RETRIES", c.MirrorMaxRetries)
+	c.MirrorRetryDelay = c.getEnvDuration("MIRROR_RETRY_DELAY", c.MirrorRetryDelay)
+	c.MirrorResyncInterval = c.getEnvDuration("MIRROR_RESYNC_INTERVAL", c.MirrorResyncInterval)
+	c.AuditSinks = getEnvSlice("AUDIT_SINKS", c.AuditSinks)
+	c.AuditFile = getEnv("AUDIT_FILE", c.AuditFile)
+	c.AuditRetention = c.getEnvDuration("AUDIT_RETENTION", c.AuditRetention)
+	c.CacheControl = getEnv("CACHE_CONTROL", c.CacheControl)
+	c.PublicCacheControl = getEnv("PUBLIC_CACHE_CONTROL", c.PublicCacheControl)
+	c.InlineContentTypes = getEnvSlice("INLINE_CONTENT_TYPES", c.InlineContentTypes)
+	c.ChunkCacheSize = c.getEnvInt64("CHUNK_CACHE_SIZE", c.ChunkCacheSize)
+	c.DownloadPrefetchChunks = c.getEnvInt("DOWNLOAD_PREFETCH_CHUNKS", c.DownloadPrefetchChunks)
+	c.AccessStatsInterval = c.getEnvDuration("ACCESS_STATS_INTERVAL", c.AccessStatsInterval)
+	c.CORSAllowedOrigins = getEnvSlice("CORS_ALLOWED_ORIGINS", c.CORSAllowedOrigins)
+	c.CORSAllowedMethods = getEnvSlice("CORS_ALLOWED_METHODS", c.CORSAllowedMethods)
+	c.CORSAllowedHeaders = getEnvSlice("CORS_ALLOWED_HEADERS", c.CORSAllowedHeaders)
+	c.CORSExposedHeaders = getEnvSlice("CORS_EXPOSED_HEADERS", c.CORSExposedHeaders)
+	c.CORSAllowCredentials = c.getEnvBool("CORS_ALLOW_CREDENTIALS", c.CORSAllowCredentials)
+	c.CORSMaxAge = c.getEnvDuration("CORS_MAX_AGE", c.CORSMaxAge)
+	c.DocsEnabled = c.getEnvBool("DOCS_ENABLED", c.DocsEnabled)
+	c.GraphQLEnabled = c.getEnvBool("GRAPHQL_ENABLED", c.GraphQLEnabled)
+	c.DashboardEnabled = c.getEnvBool("DASHBOARD_ENABLED", c.DashboardEnabled)
+	c.StorageServers = getEnvSlice("STORAGE_SERVERS", c.StorageServers)
+}
+
+// getEnv возвращает значение переменной окружения или значение по умолчанию
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt возвращает значение переменной окружения как int или значение по умолчанию
+func (c *Config) getEnvInt(key string, defaultValue int) int {
+	return parseEnv(c, key, defaultValue, strconv.Atoi)
+}
+
+// getEnvInt64 возвращает значение переменной окружения как int64 или значение по умолчанию
+func (c *Config) getEnvInt64(key string, defaultValue int64) int64 {
+	return parseEnv(c, key, defaultValue, func(value string) (int64, error) {
+		return strconv.ParseInt(value, 10, 64)
+	})
+}
+
+// getEnvBool возвращает значение переменной окружения как bool или значение по умолчанию
+func (c *Config) getEnvBool(key string, defaultValue bool) bool {
+	return parseEnv(c, key, defaultValue, strconv.ParseBool)
+}
+
+// getEnvFloat возвращает значение переменной окружения как float64 или значение по умолчанию
+func (c *Config) getEnvFloat(key string, defaultValue float64) float64 {
+	return parseEnv(c, key, defaultValue, func(value string) (float64, error) {
+		return strconv.ParseFloat(value, 64)
+	})
+}
+
+// getEnvDuration возвращает значение переменной окружения как time.Duration или значение по умолчанию
+func (c *Config) getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	return parseEnv(c, key, defaultValue, time.ParseDuration)
+}
+
+// parseEnv разбирает значение переменной окружения. Неверное значение не применяется,
+// а запоминается, чтобы Validate сообщил о нем при запуске
+func parseEnv[T any](c *Config, key string, defaultValue T, parse func(string) (T, error)) T {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Errorf("неверное значение переменной %s=%q", key, value))
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvSlice возвращает значение переменной окружения как слайс строк или значение по умолчанию
+func getEnvSlice(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		return strings.Split(value, ",")
+	}
+	return defaultValue
+}
+
+// GetAPIAddress возвращает полный адрес API сервера
+func (c *Config) GetAPIAddress() string {
+	return fmt.Sprintf("%s:%s", c.APIHost, c.APIPort)
+}
+
+// GetStorageAddress возвращает адрес сервера хранения по индексу
+func (c *Config) GetStorageAddress(index int) string {
+	if index < 0 || index >= len(c.StorageServers) {
+		return ""
+	}
+	return c.StorageServers[index]
+}
+
+// GetStorageCount возвращает количество серверов хранения
+func (c *Config) GetStorageCount() int {
+	return len(c.StorageServers)
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptions содержит параметры запуска, не относящиеся к самой конфигурации
+type LoadOptions struct {
+	ConfigFile string // путь к файлу конфигурации
+	DumpConfig bool   // вывести итоговую конфигурацию и завершиться
+}
+
+// Load собирает конфигурацию с приоритетом: флаги > переменные окружения > файл > значения по умолчанию.
+// Файл задается флагом -config (или переменной CONFIG_FILE) в формате YAML или JSON.
+// Для каждого поля конфигурации есть флаг с именем его ключа в файле, например -api-port
+func Load(name string, args []string) (*Config, *LoadOptions, error) {
+	cfg := Defaults()
+	options := &LoadOptions{}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&options.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "путь к файлу конфигурации (YAML или JSON)")
+	flags.BoolVar(&options.DumpConfig, "dump-config", false, "вывести итоговую конфигурацию в формате YAML и завершиться")
+
+	// Значения флагов применяются после файла и окружения, поэтому сначала только запоминаем их
+	overrides := make(map[string]string)
+	for _, field := range configFields(cfg) {
+		key := field.key
+		setOverride := func(value string) error {
+			overrides[key] = value
+			return nil
+		}
+
+		// Логические флаги можно указывать без значения, например -storage-persistence
+		if field.value.Kind() == reflect.Bool {
+			flags.BoolFunc(strings.ReplaceAll(key, "_", "-"), field.usage, setOverride)
+		} else {
+			flags.Func(strings.ReplaceAll(key, "_", "-"), field.usage, setOverride)
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if options.ConfigFile != "" {
+		if err := cfg.loadFile(options.ConfigFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cfg.applyEnv()
+
+	for _, field := range configFields(cfg) {
+		if value, ok := overrides[field.key]; ok {
+			if err := setFieldValue(field.value, value); err != nil {
+				return nil, nil, fmt.Errorf("неверное значение флага -%s: %w", strings.ReplaceAll(field.key, "_", "-"), err)
+			}
+		}
+	}
+
+	return cfg, options, nil
+}
+
+// loadFile читает конфигурацию из файла поверх текущих значений.
+// JSON является подмножеством YAML, поэтому оба формата разбираются одинаково
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // опечатка в ключе не должна молча игнорироваться
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("не удалось разобрать файл конфигурации %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Dump выводит конфигурацию в формате YAML, скрывая секреты
+func (c *Config) Dump(w io.Writer) error {
+	masked := *c
+	if masked.WebhookSecret != "" {
+		masked.WebhookSecret = "***"
+	}
+	if masked.MirrorAPIKey != "" {
+		masked.MirrorAPIKey = "***"
+	}
+	if masked.UploadTokenSecret != "" {
+		masked.UploadTokenSecret = "***"
+	}
+	if masked.InternalSecret != "" {
+		masked.InternalSecret = "***"
+	}
+	masked.APIKeys = make([]string, len(c.APIKeys))
+	for i, entry := range c.APIKeys {
+		name, _, _ := strings.Cut(entry, ":")
+		masked.APIKeys[i] = name + ":***"
+	}
+	if parsed, err := url.Parse(masked.MetadataDSN); err == nil && parsed.User != nil {
+		masked.MetadataDSN = parsed.Redacted()
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&masked); err != nil {
+		return fmt.Errorf("не удалось сериализовать конфигурацию: %w", err)
+	}
+	return encoder.Close()
+}
+
+// Diff возвращает ключи полей, значения которых в other отличаются от текущих
+func (c *Config) Diff(other *Config) []string {
+	otherFields := configFields(other)
+
+	var changed []string
+	for i, field := range configFields(c) {
+		if !reflect.DeepEqual(field.value.Interface(), otherFields[i].value.Interface()) {
+			changed = append(changed, field.key)
+		}
+	}
+	return changed
+}
+
+// configField описывает поле конфигурации, доступное через флаг
+type configField struct {
+	key   string
+	usage string
+	value reflect.Value
+}
+
+// configFields возвращает поля конфигурации с ключами из тегов yaml
+func configFields(cfg *Config) []configField {
+	value := reflect.ValueOf(cfg).Elem()
+	valueType := value.Type()
+
+	fields := make([]configField, 0, valueType.NumField())
+	for i := 0; i < valueType.NumField(); i++ {
+		key := strings.Split(valueType.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		fields = append(fields, configField{
+			key:   key,
+			usage: fmt.Sprintf("значение %s (по умолчанию %v)", key, value.Field(i).Interface()),
+			value: value.Field(i),
+		})
+	}
+	return fields
+}
+
+// setFieldValue присваивает полю значение из строки флага
+func setFieldValue(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case int, int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(parsed))
+	case []string:
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"TestCase/pkg/chunking"
+)
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки сразу,
+// чтобы сервер не запускался с заведомо неработоспособными параметрами
+func (c *Config) Validate() error {
+	errs := append([]error(nil), c.envErrors...)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.APIPort), "api_port: неверный номер порта %q", c.APIPort)
+	check(validPort(c.StoragePort), "storage_port: неверный номер порта %q", c.StoragePort)
+	if c.Listen != "" {
+		socket, ok := strings.CutPrefix(c.Listen, unixScheme)
+		check(ok && socket != "", "listen: неверный адрес %q, ожидается unix:///path", c.Listen)
+		check(!c.HTTP3Enabled, "listen: HTTP/3 не работает через unix сокет, отключите http3_enabled")
+	}
+
+	check(c.HTTPReadHeaderTimeout >= 0, "http_read_header_timeout: не может быть отрицательным")
+	check(c.HTTPReadTimeout >= 0, "http_read_timeout: не может быть отрицательным")
+	check(c.HTTPWriteTimeout >= 0, "http_write_timeout: не может быть отрицательным")
+	check(c.HTTPIdleTimeout >= 0, "http_idle_timeout: не может быть отрицательным")
+	check(c.HTTPMaxHeaderBytes >= 0, "http_max_header_bytes: не может быть отрицательным")
+	check((c.TLSCertFile == "") == (c.TLSKeyFile == ""), "tls_cert_file, tls_key_file: сертификат и ключ задаются вместе")
+	check(!c.HTTP3Enabled || c.TLSCertFile != "", "http3_enabled: HTTP/3 работает только с TLS, задайте tls_cert_file и tls_key_file")
+	if socket, ok := strings.CutPrefix(c.GRPCListen, unixScheme); ok {
+		check(socket != "", "grpc_listen: неверный адрес %q, ожидается host:port или unix:///path", c.GRPCListen)
+	} else if c.GRPCListen != "" {
+		_, port, err := net.SplitHostPort(c.GRPCListen)
+		check(err == nil && validPort(port), "grpc_listen: неверный адрес %q, ожидается host:port или unix:///path", c.GRPCListen)
+	}
+	switch c.GinMode {
+	case "release", "debug", "test":
+	default:
+		errs = append(errs, fmt.Errorf("gin_mode: неизвестный режим %q, ожидается release, debug или test", c.GinMode))
+	}
+
+	// При обнаружении через реестр список серверов заполняется во время работы
+	static := c.DiscoveryBackend == ""
+	switch c.DiscoveryBackend {
+	case "":
+	case "consul", "etcd":
+		parsed, err := url.Parse(c.DiscoveryEndpoint)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "",
+			"discovery_endpoint: неверный адрес реестра %q, ожидается http(s) URL", c.DiscoveryEndpoint)
+		check(c.DiscoveryBackend != "consul" || c.DiscoveryService != "", "discovery_service: не указано имя сервиса в Consul")
+		check(c.DiscoveryBackend != "etcd" || c.DiscoveryPrefix != "", "discovery_prefix: не указан префикс ключей в etcd")
+	default:
+		errs = append(errs, fmt.Errorf("discovery_backend: неизвестный реестр %q, ожидается consul или etcd", c.DiscoveryBackend))
+	}
+
+	check(!static || len(c.StorageServers) > 0, "storage_servers: не указан ни один сервер хранения")
+	seen := make(map[string]bool, len(c.StorageServers))
+	for _, server := range c.StorageServers {
+		if socket, ok := strings.CutPrefix(server, unixScheme); ok {
+			check(socket != "", "storage_servers: не указан путь к сокету в адресе %q", server)
+		} else {
+			host, port, err := net.SplitHostPort(server)
+			check(err == nil && host != "" && validPort(port), "storage_servers: неверный адрес %q, ожидается host:port или unix:///path", server)
+		}
+		check(!seen[server], "storage_servers: адрес %s указан дважды", server)
+		seen[server] = true
+	}
+
+	check(c.StorageCapacity >= 0, "storage_capacity: не может быть отрицательным")
+	check(c.CapacityRefreshInterval >= 0, "capacity_refresh_interval: не может быть отрицательным")
+	check(c.ReplicationFactor > 0, "replication_factor: должен быть больше нуля")
+	check(!static || len(c.StorageServers) == 0 || c.ReplicationFactor <= len(c.StorageServers),
+		"replication_factor: %d копий больше числа серверов хранения (%d)", c.ReplicationFactor, len(c.StorageServers))
+	check(c.RepairInterval >= 0, "repair_interval: не может быть отрицательным")
+	check(c.WriteQuorum >= 0 && c.WriteQuorum <= c.ReplicationFactor,
+		"write_quorum: должен быть от 0 до replication_factor (%d)", c.ReplicationFactor)
+	classes := make(map[string]bool)
+	for _, entry := range c.StorageClasses {
+		name, copies, ok := strings.Cut(entry, "=")
+		factor, err := strconv.Atoi(copies)
+		check(ok && name != "" && err == nil && factor > 0, "storage_classes: неверная запись %q, ожидается имя=число_копий", entry)
+		check(!static || len(c.StorageServers) == 0 || factor <= len(c.StorageServers),
+			"storage_classes: в классе %s %d копий больше числа серверов хранения (%d)", name, factor, len(c.StorageServers))
+		check(!classes[name], "storage_classes: класс %s указан дважды", name)
+		classes[name] = true
+	}
+	switch c.ReadConsistency {
+	case "one", "quorum", "all":
+	default:
+		errs = append(errs, fmt.Errorf("read_consistency: неизвестный уровень %q, ожидается one, quorum или all", c.ReadConsistency))
+	}
+
+	regionNodes := make(map[string]bool)
+	for _, entry := range c.StorageRegions {
+		node, region, ok := strings.Cut(entry, "=")
+		check(ok && node != "" && region != "", "storage_regions: неверная запись %q, ожидается адрес=регион", entry)
+		check(!regionNodes[node], "storage_regions: адрес %s указан дважды", node)
+		regionNodes[node] = true
+	}
+	check(c.RebalanceBytesPerSecond >= 0, "rebalance_max_bytes_per_second: не может быть отрицательным")
+	check(c.RebalanceConcurrency > 0, "rebalance_max_concurrent_moves: должен быть больше нуля")
+
+	check(c.MaxFileSize > 0, "max_file_size: должен быть больше нуля")
+	check(c.UploadMemoryLimit >= 0, "upload_memory_limit: не может быть отрицательным")
+	for _, network := range c.FetchAllowedNetworks {
+		_, _, err := net.ParseCIDR(network)
+		check(err == nil, "fetch_allowed_networks: неверная сеть %q, ожидается CIDR", network)
+	}
+	for _, network := range c.FetchDeniedNetworks {
+		_, _, err := net.ParseCIDR(network)
+		check(err == nil, "fetch_denied_networks: неверная сеть %q, ожидается CIDR", network)
+	}
+	check(c.SmallFileThreshold >= 0, "small_file_threshold: не может быть отрицательным")
+	check(c.TargetChunkSize >= 0, "target_chunk_size: не может быть отрицательным")
+	check(c.MinChunkCount >= 1, "min_chunk_count: должен быть больше нуля")
+	check(c.MaxChunkCount == 0 || c.MaxChunkCount >= c.MinChunkCount, "max_chunk_count: должен быть не меньше min_chunk_count")
+	check(c.ChunkCount > 0, "chunk_count: должен быть больше нуля")
+	check(!static || len(c.StorageServers) == 0 || c.ChunkCount <= len(c.StorageServers),
+		"chunk_count: %d кусков больше числа серверов хранения (%d)", c.ChunkCount, len(c.StorageServers))
+
+	if _, err := chunking.ParseHashAlgorithm(c.ChecksumAlgorithm); err != nil {
+		errs = append(errs, fmt.Errorf("checksum_algorithm: %w", err))
+	}
+
+	for _, contentType := range c.AllowedContentTypes {
+		check(validContentTypePattern(contentType), "allowed_content_types: неверный тип %q, ожидается тип/подтип или тип/*", contentType)
+	}
+	for _, contentType := range c.InlineContentTypes {
+		check(validContentTypePattern(contentType), "inline_content_types: неверный тип %q, ожидается тип/подтип или тип/*", contentType)
+	}
+
+	names := make(map[string]bool)
+	for _, entry := range c.APIKeys {
+		name, key, ok := strings.Cut(entry, ":")
+		check(ok && name != "" && key != "", "api_keys: неверная запись, ожидается имя:ключ")
+		check(!names[name], "api_keys: имя %s указано дважды", name)
+		names[name] = true
+	}
+	for _, name := range c.APIAdmins {
+		check(names[name], "api_admins: ключ %s не указан в api_keys", name)
+	}
+
+	check(c.MaxFilenameLength >= 0, "max_filename_length: не может быть отрицательной")
+	for _, pattern := range c.ForbiddenFilenamePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("forbidden_filename_patterns: неверное выражение %q: %w", pattern, err))
+		}
+	}
+
+	switch c.Scanner {
+	case "":
+	case "clamav":
+		host, port, err := net.SplitHostPort(c.ScannerAddress)
+		check(err == nil && host != "" && validPort(port), "scanner_address: неверный адрес clamd %q, ожидается host:port", c.ScannerAddress)
+	case "icap":
+		parsed, err := url.Parse(c.ScannerAddress)
+		check(err == nil && parsed.Scheme == "icap" && parsed.Host != "",
+			"scanner_address: неверный адрес сервиса ICAP %q, ожидается icap://host:port/service", c.ScannerAddress)
+	default:
+		errs = append(errs, fmt.Errorf("scanner: неизвестный антивирус %q, ожидается clamav или icap", c.Scanner))
+	}
+	check(c.ScannerTimeout >= 0, "scanner_timeout: не может быть отрицательным")
+	check(c.UploadTokenTTL > 0, "upload_token_ttl: должен быть положительным")
+	check(c.DropBoxMaxTTL > 0, "drop_box_max_ttl: должен быть положительным")
+	check(c.InternalSignatureMaxSkew > 0, "internal_signature_max_skew: должен быть положительным")
+
+	switch c.MetadataStore {
+	case "memory":
+	case "raft":
+		parsed, err := url.Parse(c.RaftNodeID)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "",
+			"raft_node_id: ожидается адрес API этого узла, например http://api1:8080, получено %q", c.RaftNodeID)
+		check(c.RaftDir != "", "raft_dir: не указана директория журнала Raft")
+		check(c.InternalSecret != "", "internal_secret: обязателен с metadata_store raft, им подписываются изменения, пересылаемые лидеру")
+		if c.RaftForwardListen != "" {
+			_, port, err := net.SplitHostPort(c.RaftForwardListen)
+			check(err == nil && validPort(port), "raft_forward_listen: неверный адрес %q, ожидается host:port", c.RaftForwardListen)
+		}
+		raftAddr := c.RaftAdvertise
+		if raftAddr == "" {
+			raftAddr = c.RaftBind
+		}
+		host, port, err := net.SplitHostPort(raftAddr)
+		check(err == nil && validPort(port), "raft_bind: неверный адрес %q, ожидается host:port", raftAddr)
+		check(err != nil || (host != "" && !net.ParseIP(host).IsUnspecified()),
+			"raft_advertise: адрес %q недоступен остальным узлам, укажите адрес этого узла", raftAddr)
+		for _, peer := range c.RaftPeers {
+			check(strings.Contains(peer, "="), "raft_peers: неверное описание узла %q, ожидается raft_node_id=адрес", peer)
+		}
+	case "postgres", "redis":
+		// Саму строку подключения не выводим: в ней может быть пароль
+		parsed, err := url.Parse(c.MetadataDSN)
+		check(err == nil && parsed.Scheme != "" && parsed.Host != "",
+			"metadata_dsn: неверная строка подключения, ожидается URL вида %s://host:port/...", c.MetadataStore)
+	default:
+		errs = append(errs, fmt.Errorf("metadata_store: неизвестное хранилище %q, ожидается memory, raft, postgres или redis", c.MetadataStore))
+	}
+	switch c.FileIDScheme {
+	case "uuidv4", "uuidv7", "ulid":
+	default:
+		errs = append(errs, fmt.Errorf("file_id_scheme: неизвестная схема %q, ожидается uuidv4, uuidv7 или ulid", c.FileIDScheme))
+	}
+
+	check(!c.PersistenceEnabled || c.SnapshotInterval > 0, "snapshot_interval: должен быть больше нуля при включенном storage_persistence")
+
+	check(c.FaultLatency >= 0, "fault_latency: не может быть отрицательной")
+	check(c.FaultLatencyJitter >= 0, "fault_latency_jitter: не может быть отрицательной")
+	check(c.FaultErrorRate >= 0 && c.FaultErrorRate <= 1, "fault_error_rate: должна быть от 0 до 1")
+	check(c.FaultTruncateRate >= 0 && c.FaultTruncateRate <= 1, "fault_truncate_rate: должна быть от 0 до 1")
+
+	for _, address := range c.WebhookURLs {
+		parsed, err := url.Parse(address)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "",
+			"webhook_urls: неверный адрес %q, ожидается http(s) URL", address)
+	}
+	check(c.WebhookMaxRetries >= 0, "webhook_max_retries: не может быть отрицательным")
+	check(c.WebhookRetryDelay >= 0, "webhook_retry_delay: не может быть отрицательной")
+
+	if c.MirrorURL != "" {
+		parsed, err := url.Parse(c.MirrorURL)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https" || parsed.Scheme == "unix") && (parsed.Host != "" || parsed.Path != ""),
+			"mirror_url: неверный адрес %q, ожидается http(s) URL или unix:///path", c.MirrorURL)
+		check(c.MirrorConcurrency > 0, "mirror_concurrency: должно быть больше нуля")
+	}
+	check(c.MirrorMaxRetries >= 0, "mirror_max_retries: не может быть отрицательным")
+	check(c.MirrorRetryDelay >= 0, "mirror_retry_delay: не может быть отрицательной")
+	check(c.MirrorResyncInterval >= 0, "mirror_resync_interval: не может быть отрицательным")
+
+	for _, sink := range c.AuditSinks {
+		switch strings.TrimSpace(sink) {
+		case "file":
+			check(c.AuditFile != "", "audit_file: не указан файл для приемника file")
+		case "stdout", "", "none":
+		default:
+			errs = append(errs, fmt.Errorf("audit_sinks: неизвестный приемник %q", sink))
+		}
+	}
+	check(c.AuditRetention >= 0, "audit_retention: не может быть отрицательным")
+	check(c.ChunkCacheSize >= 0, "chunk_cache_size: не может быть отрицательным")
+	check(c.DownloadPrefetchChunks >= 0, "download_prefetch_chunks: не может быть отрицательным")
+	check(c.AccessStatsInterval >= 0, "access_stats_interval: не может быть отрицательным")
+
+	check(c.CORSMaxAge >= 0, "cors_max_age: не может быть отрицательным")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("неверная конфигурация:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validContentTypePattern проверяет элемент списка типов содержимого: тип/подтип или тип/*
+func validContentTypePattern(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(contentType))
+	return err == nil && strings.Count(mediaType, "/") == 1 && !strings.HasPrefix(mediaType, "*")
+}
+
+// unixScheme - префикс адреса unix сокета в listen и storage_servers
+const unixScheme = "unix://"
+
+// validPort проверяет, что строка содержит номер TCP порта
+func validPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	assert.NoError(t, Defaults().Validate())
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Defaults()
+	cfg.StorageServers = []string{"node1:8081", "node1:8081", "node2"}
+	cfg.ChunkCount = 6
+	cfg.ReplicationFactor = 4
+	cfg.WriteQuorum = 5
+	cfg.StorageClasses = []string{"archive=5", "archive=1", "hot"}
+	cfg.RebalanceConcurrency = 0
+	cfg.APIKeys = []string{"ci:key", "ci:other", "no-separator"}
+	cfg.APIAdmins = []string{"ci", "root"}
+	cfg.ChecksumAlgorithm = "md5"
+	cfg.AuditSinks = []string{"syslog"}
+	cfg.AllowedContentTypes = []string{"image/*", "pdf"}
+	cfg.ForbiddenFilenamePatterns = []string{`^\.`, `(unclosed`}
+	cfg.HTTPIdleTimeout = -time.Second
+	cfg.GinMode = "verbose"
+	cfg.HTTP3Enabled = true
+	cfg.FileIDScheme = "snowflake"
+	cfg.InlineContentTypes = []string{"image/*", "*/*"}
+	cfg.FetchAllowedNetworks = []string{"10.0.0.0/8", "intranet"}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "адрес node1:8081 указан дважды")
+	assert.Contains(t, err.Error(), `неверный адрес "node2"`)
+	assert.Contains(t, err.Error(), "6 кусков больше числа серверов хранения (3)")
+	assert.Contains(t, err.Error(), "4 копий больше числа серверов хранения (3)")
+	assert.Contains(t, err.Error(), "write_quorum: должен быть от 0 до replication_factor (4)")
+	assert.Contains(t, err.Error(), `fetch_allowed_networks: неверная сеть "intranet"`)
+	assert.Contains(t, err.Error(), "в классе archive 5 копий больше числа серверов хранения (3)")
+	assert.Contains(t, err.Error(), "storage_classes: класс archive указан дважды")
+	assert.Contains(t, err.Error(), `storage_classes: неверная запись "hot"`)
+	assert.Contains(t, err.Error(), "rebalance_max_concurrent_moves")
+	assert.Contains(t, err.Error(), "api_keys: имя ci указано дважды")
+	assert.Contains(t, err.Error(), "api_keys: неверная запись")
+	assert.Contains(t, err.Error(), "api_admins: ключ root не указан в api_keys")
+	assert.NotContains(t, err.Error(), "ключ ci не указан")
+	assert.Contains(t, err.Error(), "checksum_algorithm")
+	assert.Contains(t, err.Error(), `неизвестный приемник "syslog"`)
+	assert.Contains(t, err.Error(), `allowed_content_types: неверный тип "pdf"`)
+	assert.NotContains(t, err.Error(), `"image/*"`)
+	assert.Contains(t, err.Error(), `forbidden_filename_patterns: неверное выражение "(unclosed"`)
+	assert.Contains(t, err.Error(), "http_idle_timeout: не может быть отрицательным")
+	assert.Contains(t, err.Error(), `gin_mode: неизвестный режим "verbose"`)
+	assert.Contains(t, err.Error(), "http3_enabled: HTTP/3 работает только с TLS")
+	assert.Contains(t, err.Error(), `file_id_scheme: неизвестная схема "snowflake"`)
+	assert.Contains(t, err.Error(), `inline_content_types: неверный тип "*/*"`)
+}
+
+func TestValidateUnixSockets(t *testing.T) {
+	cfg := Defaults()
+	cfg.Listen = "unix:///run/api.sock"
+	cfg.StorageServers = []string{"unix:///run/storage1.sock", "node2:8081"}
+	cfg.ChunkCount = 2
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Listen = "/run/api.sock"
+	cfg.StorageServers = []string{"unix://", "node2:8081"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `listen: неверный адрес "/run/api.sock"`)
+	assert.Contains(t, err.Error(), `не указан путь к сокету в адресе "unix://"`)
+}
+
+func TestValidateReportsMalformedEnv(t *testing.T) {
+	t.Setenv("CHUNK_COUNT", "six")
+	t.Setenv("SNAPSHOT_INTERVAL", "5")
+
+	cfg := NewConfig()
+	assert.Equal(t, 6, cfg.ChunkCount) // неверное значение не применяется
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `CHUNK_COUNT="six"`)
+	assert.Contains(t, err.Error(), `SNAPSHOT_INTERVAL="5"`)
+}
+
+func TestValidateRaftRequiresInternalSecret(t *testing.T) {
+	cfg := Defaults()
+	cfg.MetadataStore = "raft"
+	cfg.RaftNodeID = "http://api1:7001"
+	cfg.RaftAdvertise = "api1:7000"
+	cfg.RaftForwardListen = "0.0.0.0:7001"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "internal_secret: обязателен с metadata_store raft")
+
+	cfg.InternalSecret = "secret"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.RaftForwardListen = "7001"
+	assert.ErrorContains(t, cfg.Validate(), `raft_forward_listen: неверный адрес "7001"`)
+}
@@ -0,0 +1,106 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// consulWait - сколько Consul держит блокирующий запрос без изменений
+const consulWait = "5m"
+
+// Consul получает серверы хранения из каталога сервисов Consul.
+// В список попадают только экземпляры, прошедшие проверки здоровья
+type Consul struct {
+	endpoint   string
+	service    string
+	httpClient *http.Client
+}
+
+// NewConsul создает Provider для сервиса service в Consul с HTTP API по адресу endpoint
+func NewConsul(endpoint, service string) *Consul {
+	return &Consul{
+		endpoint: endpoint,
+		service:  service,
+		// Таймаут не задаем: блокирующий запрос ограничен параметром wait и контекстом
+		httpClient: &http.Client{},
+	}
+}
+
+// consulServiceEntry - элемент ответа /v1/health/service
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// Watch отслеживает состав сервиса блокирующими запросами Consul
+func (c *Consul) Watch(ctx context.Context, update func([]string)) error {
+	var index uint64
+	return watchLoop(ctx, "Consul", func(ctx context.Context, publish func([]string)) error {
+		servers, nextIndex, err := c.fetch(ctx, index)
+		if err != nil {
+			return err
+		}
+
+		// Индекс может уменьшиться после перезапуска Consul, тогда начинаем заново
+		if nextIndex < index {
+			nextIndex = 0
+		}
+		index = nextIndex
+
+		publish(servers)
+		return nil
+	}, update)
+}
+
+// fetch запрашивает экземпляры сервиса, дожидаясь изменений после index
+func (c *Consul) fetch(ctx context.Context, index uint64) ([]string, uint64, error) {
+	query := url.Values{"passing": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWait)
+	}
+
+	requestURL := fmt.Sprintf("%s/v1/health/service/%s?%s", c.endpoint, url.PathEscape(c.service), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("не удалось выполнить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, 0, err
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("не удалось разобрать ответ: %w", err)
+	}
+
+	servers := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Адрес сервиса может быть не задан, тогда используется адрес узла
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		servers = append(servers, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+
+	nextIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return servers, nextIndex, nil
+}
@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Поддерживаемые источники списка серверов хранения
+	BackendConsul = "consul"
+	BackendEtcd   = "etcd"
+
+	// retryDelay - начальная задержка перед повторным обращением после ошибки
+	retryDelay = time.Second
+
+	// maxRetryDelay - предельная задержка между повторами
+	maxRetryDelay = 30 * time.Second
+)
+
+// Provider отслеживает список серверов хранения во внешнем реестре
+type Provider interface {
+	// Watch вызывает update с отсортированным списком адресов host:port при запуске
+	// и при каждом изменении состава, пока не отменен ctx. Ошибки реестра не прерывают
+	// наблюдение: запрос повторяется с нарастающей задержкой
+	Watch(ctx context.Context, update func(servers []string)) error
+}
+
+// Config содержит настройки подключения к реестру
+type Config struct {
+	Backend  string // consul или etcd
+	Endpoint string // адрес HTTP API реестра, например http://localhost:8500
+	Service  string // имя сервиса в Consul
+	Prefix   string // префикс ключей в etcd; значение ключа - адрес host:port
+}
+
+// New создает Provider для указанного реестра
+func New(cfg Config) (Provider, error) {
+	endpoint := strings.TrimRight(cfg.Endpoint, "/")
+	switch cfg.Backend {
+	case BackendConsul:
+		return NewConsul(endpoint, cfg.Service), nil
+	case BackendEtcd:
+		return NewEtcd(endpoint, cfg.Prefix), nil
+	default:
+		return nil, fmt.Errorf("неизвестный реестр серверов хранения: %s", cfg.Backend)
+	}
+}
+
+// watchLoop повторяет poll, пока не отменен контекст, и передает в update только изменившиеся списки.
+// poll сам ожидает изменений (блокирующий запрос Consul, поток наблюдения etcd)
+func watchLoop(ctx context.Context, name string, poll func(ctx context.Context, publish func([]string)) error, update func([]string)) error {
+	var current []string
+	published := false
+	publish := func(servers []string) {
+		sort.Strings(servers)
+		if published && equal(current, servers) {
+			return
+		}
+		current, published = servers, true
+		update(servers)
+	}
+
+	delay := retryDelay
+	for {
+		err := poll(ctx, publish)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			delay = retryDelay
+			continue
+		}
+
+		log.Printf("Ошибка обращения к %s, повтор через %v: %v", name, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// checkStatus превращает ответ с неуспешным статусом в ошибку
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("реестр вернул статус %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
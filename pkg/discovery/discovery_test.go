@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectUpdates запускает наблюдение и возвращает канал с полученными списками
+func collectUpdates(t *testing.T, provider Provider) <-chan []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	updates := make(chan []string, 10)
+	go provider.Watch(ctx, func(servers []string) {
+		updates <- servers
+	})
+	return updates
+}
+
+func nextUpdate(t *testing.T, updates <-chan []string) []string {
+	select {
+	case servers := <-updates:
+		return servers
+	case <-time.After(5 * time.Second):
+		t.Fatal("список серверов не получен")
+		return nil
+	}
+}
+
+func TestConsulWatch(t *testing.T) {
+	changed := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/storage", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("passing"))
+
+		entries := `[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8081}}]`
+		switch r.URL.Query().Get("index") {
+		case "":
+			w.Header().Set("X-Consul-Index", "1")
+		case "1":
+			// Блокирующий запрос возвращается после изменения состава
+			<-changed
+			w.Header().Set("X-Consul-Index", "2")
+			entries = `[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8081}},
+				{"Node":{"Address":"10.0.0.9"},"Service":{"Address":"10.0.0.2","Port":8082}}]`
+		default:
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, entries)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := New(Config{Backend: BackendConsul, Endpoint: server.URL + "/", Service: "storage"})
+	require.NoError(t, err)
+	updates := collectUpdates(t, provider)
+
+	assert.Equal(t, []string{"10.0.0.1:8081"}, nextUpdate(t, updates))
+	close(changed)
+	assert.Equal(t, []string{"10.0.0.1:8081", "10.0.0.2:8082"}, nextUpdate(t, updates))
+}
+
+func TestEtcdWatch(t *testing.T) {
+	var mutex sync.Mutex
+	servers := []string{"node2:8082", "node1:8081"}
+	changed := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("/storage/")), request["key"])
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("/storage0")), request["range_end"])
+
+			mutex.Lock()
+			kvs := make([]map[string]string, 0, len(servers))
+			for _, server := range servers {
+				kvs = append(kvs, map[string]string{"value": base64.StdEncoding.EncodeToString([]byte(server))})
+			}
+			mutex.Unlock()
+			json.NewEncoder(w).Encode(map[string]any{"header": map[string]string{"revision": "7"}, "kvs": kvs})
+		case "/v3/watch":
+			create := request["create_request"].(map[string]any)
+			assert.Equal(t, "8", create["start_revision"])
+
+			fmt.Fprintln(w, `{"result":{"created":true}}`)
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-changed:
+				fmt.Fprintln(w, `{"result":{"events":[{"type":"DELETE"}]}}`)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	updates := collectUpdates(t, NewEtcd(server.URL, "/storage/"))
+	assert.Equal(t, []string{"node1:8081", "node2:8082"}, nextUpdate(t, updates))
+
+	mutex.Lock()
+	servers = []string{"node1:8081"}
+	mutex.Unlock()
+	close(changed)
+
+	assert.Equal(t, []string{"node1:8081"}, nextUpdate(t, updates))
+}
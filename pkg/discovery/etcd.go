@@ -0,0 +1,184 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Etcd получает серверы хранения из ключей etcd с общим префиксом через HTTP API v3.
+// Значение каждого ключа - адрес сервера в формате host:port
+type Etcd struct {
+	endpoint   string
+	prefix     string
+	httpClient *http.Client
+}
+
+// NewEtcd создает Provider для ключей с префиксом prefix в etcd с HTTP API по адресу endpoint
+func NewEtcd(endpoint, prefix string) *Etcd {
+	return &Etcd{
+		endpoint: endpoint,
+		prefix:   prefix,
+		// Таймаут не задаем: поток наблюдения открыт, пока не отменен контекст
+		httpClient: &http.Client{},
+	}
+}
+
+// etcdRangeResponse - ответ /v3/kv/range; числа int64 передаются строками
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []struct {
+		Value string `json:"value"`
+	} `json:"kvs"`
+}
+
+// etcdWatchResponse - сообщение потока /v3/watch
+type etcdWatchResponse struct {
+	Result struct {
+		Events   []json.RawMessage `json:"events"`
+		Canceled bool              `json:"canceled"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Watch читает текущий список и перечитывает его при каждом изменении ключей с префиксом
+func (e *Etcd) Watch(ctx context.Context, update func([]string)) error {
+	return watchLoop(ctx, "etcd", func(ctx context.Context, publish func([]string)) error {
+		servers, revision, err := e.list(ctx)
+		if err != nil {
+			return err
+		}
+		publish(servers)
+
+		return e.watch(ctx, revision+1, func() error {
+			servers, _, err := e.list(ctx)
+			if err != nil {
+				return err
+			}
+			publish(servers)
+			return nil
+		})
+	}, update)
+}
+
+// list возвращает адреса из ключей с префиксом и ревизию хранилища на момент чтения
+func (e *Etcd) list(ctx context.Context) ([]string, int64, error) {
+	key, rangeEnd := e.keyRange()
+
+	var response etcdRangeResponse
+	err := e.post(ctx, "/v3/kv/range", map[string]string{"key": key, "range_end": rangeEnd}, func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&response)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	servers := make([]string, 0, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("не удалось декодировать значение ключа: %w", err)
+		}
+		if server := strings.TrimSpace(string(value)); server != "" {
+			servers = append(servers, server)
+		}
+	}
+
+	revision, _ := strconv.ParseInt(response.Header.Revision, 10, 64)
+	return servers, revision, nil
+}
+
+// watch открывает поток наблюдения с ревизии startRevision и вызывает changed на каждое изменение.
+// Возвращает управление, когда поток закрыт сервером или отменен контекст
+func (e *Etcd) watch(ctx context.Context, startRevision int64, changed func() error) error {
+	key, rangeEnd := e.keyRange()
+	request := map[string]any{
+		"create_request": map[string]any{
+			"key":            key,
+			"range_end":      rangeEnd,
+			"start_revision": strconv.FormatInt(startRevision, 10),
+		},
+	}
+
+	return e.post(ctx, "/v3/watch", request, func(body io.Reader) error {
+		decoder := json.NewDecoder(body)
+		for {
+			var message etcdWatchResponse
+			if err := decoder.Decode(&message); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return fmt.Errorf("не удалось прочитать поток наблюдения: %w", err)
+			}
+
+			if message.Error != nil {
+				return fmt.Errorf("ошибка наблюдения: %s", message.Error.Message)
+			}
+			if message.Result.Canceled {
+				return errors.New("наблюдение отменено сервером etcd")
+			}
+			if len(message.Result.Events) == 0 {
+				continue
+			}
+
+			if err := changed(); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// post отправляет JSON запрос к etcd и передает тело успешного ответа в read
+func (e *Etcd) post(ctx context.Context, path string, request any, read func(io.Reader) error) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось выполнить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return read(resp.Body)
+}
+
+// keyRange возвращает границы диапазона ключей с префиксом в кодировке base64, как требует API
+func (e *Etcd) keyRange() (string, string) {
+	return base64.StdEncoding.EncodeToString([]byte(e.prefix)),
+		base64.StdEncoding.EncodeToString(prefixEnd([]byte(e.prefix)))
+}
+
+// prefixEnd возвращает первый ключ после всех ключей с префиксом
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Префикс из одних 0xff: диапазон до конца пространства ключей
+	return []byte{0}
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"TestCase/pkg/chunking"
+)
+
+// Type определяет тип события жизненного цикла файла
+type Type string
+
+const (
+	FileUploaded        Type = "file.uploaded"
+	FileReplaced        Type = "file.replaced"
+	FileDeleted         Type = "file.deleted"
+	FileExpired         Type = "file.expired"
+	FileRepairCompleted Type = "file.repair_completed"
+
+	FileVisibilityChanged Type = "file.visibility_changed"
+)
+
+// FileRef содержит основные сведения о файле, к которому относится событие
+type FileRef struct {
+	ID                string                 `json:"id"`
+	Name              string                 `json:"name"`
+	Path              string                 `json:"path,omitempty"`
+	Size              int64                  `json:"size"`
+	Checksum          string                 `json:"checksum"`
+	ChecksumAlgorithm chunking.HashAlgorithm `json:"checksum_algorithm,omitempty"`
+
+	// Владелец и права файла не передаются подписчикам, а нужны, чтобы отдавать событие
+	// только клиентам с доступом к файлу
+	Owner  string                         `json:"-"`
+	Grants map[string]chunking.Permission `json:"-"`
+}
+
+// Event описывает событие жизненного цикла файла
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      Type                   `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	File      FileRef                `json:"file"`
+	Data      map[string]interface{} `json:"data,omitempty"` // дополнительные сведения, зависящие от типа события
+}
+
+// NewFileEvent создает событие указанного типа для файла
+func NewFileEvent(eventType Type, metadata *chunking.FileMetadata) Event {
+	return Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		File: FileRef{
+			ID:                metadata.ID,
+			Name:              metadata.OriginalName,
+			Path:              metadata.Path,
+			Size:              metadata.Size,
+			Checksum:          metadata.Checksum,
+			ChecksumAlgorithm: metadata.ChecksumAlgorithm,
+			Owner:             metadata.Owner,
+			Grants:            metadata.Grants,
+		},
+	}
+}
+
+// Bus рассылает события всем подписчикам.
+// Публикация не блокируется: если подписчик не успевает обрабатывать события, они для него теряются
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[int]chan Event
+	nextID      int
+}
+
+// NewBus создает новую шину событий
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[int]chan Event),
+	}
+}
+
+// Publish отправляет событие всем подписчикам
+func (b *Bus) Publish(event Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Подписчик %d не успевает обрабатывать события, событие %s (%s) пропущено", id, event.ID, event.Type)
+		}
+	}
+}
+
+// Subscribe регистрирует подписчика с буфером указанного размера.
+// Возвращает канал событий и функцию отписки, которая закрывает канал
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.nextID
+	b.nextID++
+
+	ch := make(chan Event, buffer)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, id)
+			b.mutex.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,311 @@
+// Package fsck сверяет каталог метаданных с кусками на серверах хранения: находит
+// пропавшие и лишние куски, расхождения размеров и контрольных сумм. Проверка работает
+// и на API сервере, и без него - по снимку каталога и прямым запросам к серверам хранения
+package fsck
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// Kind - вид найденной проблемы
+type Kind string
+
+const (
+	// MissingChunk - по метаданным кусок хранится на сервере, но на сервере его нет
+	MissingChunk Kind = "missing_chunk"
+	// OrphanChunk - кусок на сервере не записан в метаданных ни одного файла на этот сервер
+	OrphanChunk Kind = "orphan_chunk"
+	// SizeMismatch - размер данных куска на сервере отличается от размера в метаданных
+	SizeMismatch Kind = "size_mismatch"
+	// ChecksumMismatch - контрольная сумма куска на сервере отличается от метаданных
+	ChecksumMismatch Kind = "checksum_mismatch"
+	// CorruptedChunk - данные куска не совпадают с его контрольной суммой (только с Verify)
+	CorruptedChunk Kind = "corrupted_chunk"
+	// RefCountMismatch - число ссылок на кусок на сервере отличается от числа файлов,
+	// размещающих на нем кусок
+	RefCountMismatch Kind = "ref_count_mismatch"
+	// InvalidMetadata - метаданные файла противоречат сами себе
+	InvalidMetadata Kind = "invalid_metadata"
+	// UnreachableNode - сервер хранения не ответил; его куски не проверены
+	UnreachableNode Kind = "unreachable_node"
+)
+
+// Problem - одна найденная проблема
+type Problem struct {
+	Kind     Kind   `json:"kind"`
+	Node     string `json:"node,omitempty"`
+	FileID   string `json:"file_id,omitempty"` // для проблем куска - первый по идентификатору файл, ссылающийся на кусок
+	ChunkID  string `json:"chunk_id,omitempty"`
+	Expected string `json:"expected,omitempty"` // значение по метаданным
+	Actual   string `json:"actual,omitempty"`   // значение на сервере
+	Detail   string `json:"detail,omitempty"`
+}
+
+// NodeReport - итог проверки одного сервера хранения
+type NodeReport struct {
+	Node      string `json:"node"`
+	Reachable bool   `json:"reachable"`
+	Chunks    int    `json:"chunks"` // кусков на сервере
+	Bytes     int64  `json:"bytes"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report - отчет о проверке
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Verified   bool      `json:"verified"` // контрольные суммы пересчитаны по данным
+
+	Files      int `json:"files"`
+	Chunks     int `json:"chunks"`      // различных кусков в каталоге
+	LostChunks int `json:"lost_chunks"` // куски без единой исправной копии на доступных серверах
+
+	Nodes    []NodeReport `json:"nodes"`
+	Counts   map[Kind]int `json:"counts"` // число проблем каждого вида
+	Problems []Problem    `json:"problems"`
+}
+
+// OK сообщает, что проблем не найдено
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Inventory - сведения о кусках сервера хранения. Его реализует storage.StorageClient
+type Inventory interface {
+	InventoryContext(ctx context.Context, verify bool) ([]storage.ChunkStat, error)
+}
+
+// Options задает параметры проверки
+type Options struct {
+	// Verify пересчитывает контрольные суммы кусков на серверах по данным
+	Verify bool
+	// Nodes - серверы хранения, проверяемые помимо упомянутых в метаданных, например весь
+	// текущий состав: лишние куски ищутся и на серверах без файлов
+	Nodes []string
+	// Client возвращает источник сведений о кусках сервера по его адресу
+	Client func(node string) Inventory
+}
+
+// placement - кусок, который по метаданным хранится на сервере
+type placement struct {
+	size      int64
+	checksum  string
+	algorithm chunking.HashAlgorithm
+	files     []string // файлы, размещающие кусок на сервере
+}
+
+// Check сверяет метаданные files с кусками на серверах хранения и возвращает отчет.
+// Серверы опрашиваются параллельно. Куски, которые загружаются во время проверки, могут
+// оказаться в отчете лишними, поэтому проверку лучше запускать без активной загрузки
+func Check(ctx context.Context, files []*chunking.FileMetadata, opts Options) *Report {
+	report := &Report{
+		StartedAt: time.Now().UTC(),
+		Verified:  opts.Verify,
+		Files:     len(files),
+		Counts:    make(map[Kind]int),
+		Problems:  []Problem{},
+	}
+
+	expected := make(map[string]map[string]*placement) // сервер -> кусок -> размещение
+	chunkNodes := make(map[string][]string)            // кусок -> серверы по метаданным
+	for _, metadata := range files {
+		report.checkMetadata(metadata)
+		for _, chunk := range metadata.Chunks {
+			for _, node := range chunk.Nodes() {
+				if expected[node] == nil {
+					expected[node] = make(map[string]*placement)
+				}
+				entry := expected[node][chunk.ID]
+				if entry == nil {
+					entry = &placement{size: chunk.Size, checksum: chunk.Checksum, algorithm: chunk.Algorithm}
+					expected[node][chunk.ID] = entry
+				}
+				entry.files = append(entry.files, metadata.ID)
+				if !slices.Contains(chunkNodes[chunk.ID], node) {
+					chunkNodes[chunk.ID] = append(chunkNodes[chunk.ID], node)
+				}
+			}
+		}
+	}
+	report.Chunks = len(chunkNodes)
+
+	nodes := slices.Clone(opts.Nodes)
+	for node := range expected {
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	inventories := make([][]storage.ChunkStat, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			inventories[i], errs[i] = opts.Client(node).InventoryContext(ctx, opts.Verify)
+		}(i, node)
+	}
+	wg.Wait()
+
+	healthy := make(map[string]bool) // куски, у которых есть исправная копия
+	unchecked := make(map[string]bool)
+	for i, node := range nodes {
+		nodeReport := NodeReport{Node: node, Reachable: errs[i] == nil}
+		if errs[i] != nil {
+			nodeReport.Error = errs[i].Error()
+			report.Nodes = append(report.Nodes, nodeReport)
+			report.add(Problem{Kind: UnreachableNode, Node: node, Detail: errs[i].Error()})
+			for chunkID := range expected[node] {
+				unchecked[chunkID] = true
+			}
+			continue
+		}
+
+		for _, stat := range inventories[i] {
+			nodeReport.Chunks++
+			nodeReport.Bytes += stat.Size
+		}
+		report.Nodes = append(report.Nodes, nodeReport)
+		report.checkNode(node, expected[node], inventories[i], chunkNodes, healthy)
+	}
+
+	for chunkID := range chunkNodes {
+		if !healthy[chunkID] && !unchecked[chunkID] {
+			report.LostChunks++
+		}
+	}
+
+	sort.SliceStable(report.Problems, func(i, j int) bool {
+		a, b := report.Problems[i], report.Problems[j]
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		if a.ChunkID != b.ChunkID {
+			return a.ChunkID < b.ChunkID
+		}
+		return a.FileID < b.FileID
+	})
+	report.FinishedAt = time.Now().UTC()
+	return report
+}
+
+// checkMetadata проверяет, что метаданные файла не противоречат сами себе
+func (r *Report) checkMetadata(metadata *chunking.FileMetadata) {
+	if metadata.ChunkCount != len(metadata.Chunks) {
+		r.add(Problem{
+			Kind:     InvalidMetadata,
+			FileID:   metadata.ID,
+			Expected: strconv.Itoa(metadata.ChunkCount),
+			Actual:   strconv.Itoa(len(metadata.Chunks)),
+			Detail:   "число кусков не совпадает с chunk_count",
+		})
+	}
+
+	var size int64
+	for i, chunk := range metadata.Chunks {
+		size += chunk.Size
+		if len(chunk.Nodes()) == 0 {
+			r.add(Problem{
+				Kind:    InvalidMetadata,
+				FileID:  metadata.ID,
+				ChunkID: chunk.ID,
+				Detail:  fmt.Sprintf("у куска %d не указан сервер хранения", i),
+			})
+		}
+	}
+	if size != metadata.Size {
+		r.add(Problem{
+			Kind:     InvalidMetadata,
+			FileID:   metadata.ID,
+			Expected: strconv.FormatInt(metadata.Size, 10),
+			Actual:   strconv.FormatInt(size, 10),
+			Detail:   "сумма размеров кусков не совпадает с размером файла",
+		})
+	}
+}
+
+// checkNode сверяет куски сервера node с размещенными на нем по метаданным и отмечает в
+// healthy куски с исправной копией
+func (r *Report) checkNode(node string, expected map[string]*placement, stats []storage.ChunkStat,
+	chunkNodes map[string][]string, healthy map[string]bool) {
+	present := make(map[string]bool, len(stats))
+	for _, stat := range stats {
+		present[stat.ID] = true
+
+		entry := expected[stat.ID]
+		if entry == nil {
+			detail := "кусок не упоминается в каталоге"
+			if len(chunkNodes[stat.ID]) > 0 {
+				detail = "по каталогу кусок хранится на других серверах"
+			}
+			r.add(Problem{Kind: OrphanChunk, Node: node, ChunkID: stat.ID, Actual: strconv.FormatInt(stat.Size, 10), Detail: detail})
+			continue
+		}
+
+		fileID := slices.Min(entry.files)
+		ok := true
+		if stat.Size != entry.size {
+			ok = false
+			r.add(Problem{
+				Kind:     SizeMismatch,
+				Node:     node,
+				FileID:   fileID,
+				ChunkID:  stat.ID,
+				Expected: strconv.FormatInt(entry.size, 10),
+				Actual:   strconv.FormatInt(stat.Size, 10),
+			})
+		}
+		if want, got := checksumString(entry.algorithm, entry.checksum), checksumString(stat.Algorithm, stat.Checksum); want != got {
+			ok = false
+			r.add(Problem{Kind: ChecksumMismatch, Node: node, FileID: fileID, ChunkID: stat.ID, Expected: want, Actual: got})
+		}
+		if stat.Corrupted {
+			ok = false
+			r.add(Problem{Kind: CorruptedChunk, Node: node, FileID: fileID, ChunkID: stat.ID, Detail: stat.Error})
+		}
+		if stat.RefCount != len(entry.files) {
+			r.add(Problem{
+				Kind:     RefCountMismatch,
+				Node:     node,
+				FileID:   fileID,
+				ChunkID:  stat.ID,
+				Expected: strconv.Itoa(len(entry.files)),
+				Actual:   strconv.Itoa(stat.RefCount),
+			})
+		}
+		if ok {
+			healthy[stat.ID] = true
+		}
+	}
+
+	for chunkID, entry := range expected {
+		if !present[chunkID] {
+			r.add(Problem{Kind: MissingChunk, Node: node, FileID: slices.Min(entry.files), ChunkID: chunkID})
+		}
+	}
+}
+
+// add добавляет проблему в отчет
+func (r *Report) add(problem Problem) {
+	r.Problems = append(r.Problems, problem)
+	r.Counts[problem.Kind]++
+}
+
+// checksumString записывает контрольную сумму вместе с алгоритмом
+func checksumString(algorithm chunking.HashAlgorithm, checksum string) string {
+	if algorithm == "" {
+		algorithm = chunking.HashSHA256
+	}
+	return string(algorithm) + ":" + checksum
+}
@@ -0,0 +1,93 @@
+package fsck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// fakeInventory возвращает заданные сведения о кусках или ошибку
+type fakeInventory struct {
+	stats []storage.ChunkStat
+	err   error
+}
+
+func (f fakeInventory) InventoryContext(ctx context.Context, verify bool) ([]storage.ChunkStat, error) {
+	return f.stats, f.err
+}
+
+func TestCheck(t *testing.T) {
+	files := []*chunking.FileMetadata{
+		{
+			ID: "f1", Size: 10, ChunkCount: 2,
+			Chunks: []chunking.FileChunk{
+				{ID: "c1", Size: 4, Checksum: "x1", Node: "n1", Replicas: []string{"n2"}},
+				{ID: "c2", Size: 6, Checksum: "x2", Node: "n1"},
+			},
+		},
+		{
+			ID: "f2", Size: 5, ChunkCount: 1,
+			Chunks: []chunking.FileChunk{{ID: "c3", Size: 4, Checksum: "x3", Node: "n3"}},
+		},
+	}
+	inventories := map[string]fakeInventory{
+		"n1": {stats: []storage.ChunkStat{
+			{ID: "c1", Size: 4, Checksum: "x1", RefCount: 1, Corrupted: true},
+			{ID: "c2", Size: 5, Checksum: "x2", RefCount: 2},
+		}},
+		"n2": {stats: []storage.ChunkStat{
+			{ID: "c1", Size: 4, Checksum: "x1", RefCount: 1},
+			{ID: "c9", Size: 1, Checksum: "x9", RefCount: 1},
+		}},
+		"n3": {err: errors.New("нет связи")},
+		"n4": {},
+	}
+
+	report := Check(context.Background(), files, Options{
+		Verify: true,
+		Nodes:  []string{"n4"},
+		Client: func(node string) Inventory { return inventories[node] },
+	})
+
+	assert.Equal(t, 2, report.Files)
+	assert.Equal(t, 3, report.Chunks)
+	assert.Equal(t, 1, report.LostChunks, "у c2 нет исправной копии, c3 не проверен")
+	require.Len(t, report.Nodes, 4)
+	assert.False(t, report.Nodes[2].Reachable)
+
+	assert.Equal(t, map[Kind]int{
+		CorruptedChunk:   1,
+		SizeMismatch:     1,
+		RefCountMismatch: 1,
+		OrphanChunk:      1,
+		UnreachableNode:  1,
+		InvalidMetadata:  1,
+	}, report.Counts)
+	assert.Contains(t, report.Problems, Problem{Kind: SizeMismatch, Node: "n1", FileID: "f1", ChunkID: "c2", Expected: "6", Actual: "5"})
+	assert.Contains(t, report.Problems, Problem{Kind: OrphanChunk, Node: "n2", ChunkID: "c9", Actual: "1", Detail: "кусок не упоминается в каталоге"})
+	assert.False(t, report.OK())
+}
+
+func TestCheckReportsMissingChunks(t *testing.T) {
+	files := []*chunking.FileMetadata{{
+		ID: "f1", Size: 4, ChunkCount: 1,
+		Chunks: []chunking.FileChunk{{ID: "c1", Size: 4, Checksum: "x1", Algorithm: chunking.HashSHA256, Node: "n1", Replicas: []string{"n2"}}},
+	}}
+	inventories := map[string]fakeInventory{
+		"n1": {stats: []storage.ChunkStat{{ID: "c1", Size: 4, Checksum: "x1", RefCount: 1}}},
+		"n2": {},
+	}
+
+	report := Check(context.Background(), files, Options{
+		Client: func(node string) Inventory { return inventories[node] },
+	})
+
+	assert.Equal(t, []Problem{{Kind: MissingChunk, Node: "n2", FileID: "f1", ChunkID: "c1"}}, report.Problems)
+	assert.Zero(t, report.LostChunks)
+}
@@ -0,0 +1,194 @@
+// Package metaformat кодирует метаданные файлов и их кусков в JSON, protobuf или
+// MessagePack. Двоичные форматы короче JSON и дешевле в разборе, поэтому подходят для
+// конвейеров, загружающих много файлов. Формат выбирается по заголовкам Accept и
+// Content-Type; в protobuf метаданные передаются сообщениями storagepb.File и
+// storagepb.FileList, в MessagePack - с теми же именами полей, что и в JSON
+package metaformat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client/storagepb"
+)
+
+// Format - формат метаданных
+type Format string
+
+const (
+	JSON        Format = "json"
+	Protobuf    Format = "protobuf"
+	MessagePack Format = "msgpack"
+)
+
+// Типы содержимого форматов
+const (
+	MIMEJSON        = "application/json"
+	MIMEProtobuf    = "application/x-protobuf"
+	MIMEMessagePack = "application/msgpack"
+)
+
+// maxMessageSize ограничивает размер сообщения protobuf, которое читают DecodeFile и
+// DecodeFiles: сообщение разбирается только целиком
+const maxMessageSize = 256 << 20
+
+// msgpackHandle кодирует время расширением timestamp MessagePack, которое понимают
+// библиотеки других языков
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// ContentType возвращает тип содержимого формата
+func (f Format) ContentType() string {
+	switch f {
+	case Protobuf:
+		return MIMEProtobuf
+	case MessagePack:
+		return MIMEMessagePack
+	}
+	return MIMEJSON
+}
+
+// FromContentType возвращает формат по типу содержимого. Для protobuf и MessagePack
+// понимаются и распространенные синонимы типов; ok = false, если тип не относится ни к
+// одному формату
+func FromContentType(contentType string) (format Format, ok bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case MIMEJSON:
+		return JSON, true
+	case MIMEProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return Protobuf, true
+	case MIMEMessagePack, "application/x-msgpack", "application/vnd.msgpack":
+		return MessagePack, true
+	}
+	return "", false
+}
+
+// Negotiate выбирает формат ответа по заголовку Accept: формат с наибольшим весом, а
+// при равных весах - перечисленный первым. Если Accept пуст или в нем нет известных
+// форматов (например, только */*), ответ остается в JSON
+func Negotiate(accept string) Format {
+	format, weight := JSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		candidate, ok := FromContentType(mediaType)
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > weight {
+			format, weight = candidate, q
+		}
+	}
+	return format
+}
+
+// EncodeFile записывает метаданные файла в формате format
+func EncodeFile(w io.Writer, format Format, metadata *chunking.FileMetadata) error {
+	switch format {
+	case Protobuf:
+		return writeMessage(w, storagepb.NewFile(metadata))
+	case MessagePack:
+		return codec.NewEncoder(w, msgpackHandle).Encode(metadata)
+	}
+	return json.NewEncoder(w).Encode(metadata)
+}
+
+// DecodeFile читает метаданные файла в формате format
+func DecodeFile(r io.Reader, format Format) (*chunking.FileMetadata, error) {
+	switch format {
+	case Protobuf:
+		var file storagepb.File
+		if err := readMessage(r, &file); err != nil {
+			return nil, err
+		}
+		return file.Metadata(), nil
+	case MessagePack:
+		var metadata chunking.FileMetadata
+		if err := codec.NewDecoder(r, msgpackHandle).Decode(&metadata); err != nil {
+			return nil, err
+		}
+		return &metadata, nil
+	}
+	var metadata chunking.FileMetadata
+	if err := json.NewDecoder(r).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// EncodeFiles записывает метаданные нескольких файлов: в protobuf - сообщением FileList,
+// в MessagePack и JSON - массивом
+func EncodeFiles(w io.Writer, format Format, files []*chunking.FileMetadata) error {
+	if files == nil {
+		files = []*chunking.FileMetadata{}
+	}
+	switch format {
+	case Protobuf:
+		return writeMessage(w, storagepb.NewFileList(files))
+	case MessagePack:
+		return codec.NewEncoder(w, msgpackHandle).Encode(files)
+	}
+	return json.NewEncoder(w).Encode(files)
+}
+
+// DecodeFiles читает метаданные нескольких файлов, записанные EncodeFiles
+func DecodeFiles(r io.Reader, format Format) ([]*chunking.FileMetadata, error) {
+	switch format {
+	case Protobuf:
+		var list storagepb.FileList
+		if err := readMessage(r, &list); err != nil {
+			return nil, err
+		}
+		return list.Metadata(), nil
+	case MessagePack:
+		var files []*chunking.FileMetadata
+		if err := codec.NewDecoder(r, msgpackHandle).Decode(&files); err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+	var files []*chunking.FileMetadata
+	if err := json.NewDecoder(r).Decode(&files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func writeMessage(w io.Writer, message proto.Message) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func readMessage(r io.Reader, message proto.Message) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxMessageSize {
+		return fmt.Errorf("сообщение protobuf больше %d байт", maxMessageSize)
+	}
+	return proto.Unmarshal(data, message)
+}
@@ -0,0 +1,101 @@
+package metaformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+)
+
+func testMetadata() *chunking.FileMetadata {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	accessedAt := createdAt.Add(time.Hour)
+	return &chunking.FileMetadata{
+		ID:           "file-1",
+		OriginalName: "report.pdf",
+		Size:         300,
+		Checksum:     "abc",
+		ChunkCount:   2,
+		Chunks: []chunking.FileChunk{
+			{ID: "c0", Index: 0, FileID: "file-1", Size: 150, Checksum: "c0sum", Node: "node1:8081", Replicas: []string{"node2:8081"}},
+			{ID: "c1", Index: 1, FileID: "file-1", Size: 150, Checksum: "c1sum", Algorithm: chunking.HashSHA256, Node: "node2:8081"},
+		},
+		ContentType:       "application/pdf",
+		ChecksumAlgorithm: chunking.HashSHA256,
+		Path:              "reports/report.pdf",
+		CreatedAt:         createdAt,
+		Generation:        7,
+		Public:            true,
+		Owner:             "alice",
+		Grants:            map[string]chunking.Permission{"bob": chunking.PermissionRead},
+		DownloadCount:     3,
+		BytesServed:       900,
+		LastAccessedAt:    &accessedAt,
+		MerkleRoot:        "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+}
+
+func TestEncodeDecodeFile(t *testing.T) {
+	for _, format := range []Format{JSON, Protobuf, MessagePack} {
+		t.Run(string(format), func(t *testing.T) {
+			metadata := testMetadata()
+			var buf bytes.Buffer
+			require.NoError(t, EncodeFile(&buf, format, metadata))
+
+			decoded, err := DecodeFile(&buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, metadata, decoded)
+		})
+	}
+}
+
+func TestEncodeDecodeFiles(t *testing.T) {
+	files := []*chunking.FileMetadata{testMetadata(), {ID: "file-2", OriginalName: "empty.txt", CreatedAt: time.Unix(0, 0).UTC()}}
+	for _, format := range []Format{JSON, Protobuf, MessagePack} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, EncodeFiles(&buf, format, files))
+
+			decoded, err := DecodeFiles(&buf, format)
+			require.NoError(t, err)
+			require.Len(t, decoded, 2)
+			assert.Equal(t, files[0], decoded[0])
+			assert.Equal(t, "file-2", decoded[1].ID)
+		})
+	}
+}
+
+func TestMessagePackUsesJSONFieldNames(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, EncodeFile(&buf, MessagePack, testMetadata()))
+	assert.Contains(t, buf.String(), "original_name")
+	assert.NotContains(t, buf.String(), "OriginalName")
+
+	jsonData, err := json.Marshal(testMetadata())
+	require.NoError(t, err)
+	assert.Less(t, buf.Len(), len(jsonData))
+}
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, JSON, Negotiate(""))
+	assert.Equal(t, JSON, Negotiate("*/*"))
+	assert.Equal(t, Protobuf, Negotiate("application/x-protobuf"))
+	assert.Equal(t, Protobuf, Negotiate("application/protobuf, */*;q=0.1"))
+	assert.Equal(t, MessagePack, Negotiate("application/json;q=0.5, application/vnd.msgpack"))
+	assert.Equal(t, JSON, Negotiate("application/json, application/msgpack;q=0.9"))
+	assert.Equal(t, JSON, Negotiate("application/x-protobuf;q=0"))
+}
+
+func TestFromContentType(t *testing.T) {
+	format, ok := FromContentType("application/x-msgpack; charset=binary")
+	assert.True(t, ok)
+	assert.Equal(t, MessagePack, format)
+
+	_, ok = FromContentType("text/plain")
+	assert.False(t, ok)
+}
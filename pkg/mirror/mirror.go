@@ -0,0 +1,524 @@
+// Package mirror асинхронно отражает файлы в резервное развертывание: по событиям
+// загрузки, замены и удаления копирует новые версии файлов в приемник через его API и
+// удаляет копии удаленных файлов. Периодическая сверка с каталогом находит изменения,
+// события о которых потеряны, например при остановке сервера
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/events"
+)
+
+// Config содержит настройки зеркалирования
+type Config struct {
+	StatePath      string        // журнал соответствия файлов и их копий; пустой - только в памяти
+	Concurrency    int           // число одновременно отражаемых файлов
+	MaxRetries     int           // количество повторных попыток после первой неудачи
+	RetryDelay     time.Duration // задержка перед первым повтором, далее удваивается
+	ResyncInterval time.Duration // период сверки с каталогом; 0 - только при запуске
+}
+
+// Source - развертывание, файлы которого отражаются
+type Source interface {
+	// Get возвращает метаданные файла или catalog.ErrNotFound
+	Get(ctx context.Context, id string) (*chunking.FileMetadata, error)
+	// List возвращает метаданные всех файлов
+	List(ctx context.Context) ([]*chunking.FileMetadata, error)
+	// Open возвращает поток содержимого файла; ошибка контрольной суммы возвращается при
+	// чтении вместо io.EOF
+	Open(ctx context.Context, metadata *chunking.FileMetadata) (io.ReadCloser, error)
+}
+
+// Target - API приемника. Его реализует client.APIClient
+type Target interface {
+	UploadReader(ctx context.Context, name string, r io.Reader, size int64, opts ...client.TransferOption) (*chunking.FileMetadata, error)
+	DeleteFileContext(ctx context.Context, fileID string) error
+	SetFileVisibilityContext(ctx context.Context, fileID string, public bool) (*client.Visibility, error)
+	GrantFileAccessContext(ctx context.Context, fileID, principal string, permission chunking.Permission) (*client.FileACL, error)
+	RevokeFileAccessContext(ctx context.Context, fileID, principal string) (*client.FileACL, error)
+}
+
+var _ Target = (*client.APIClient)(nil)
+
+// Status - состояние зеркалирования
+type Status struct {
+	Files   int `json:"files"`   // файлов, отраженных в приемник
+	Pending int `json:"pending"` // файлов с неотраженными изменениями, включая отражаемые сейчас
+
+	// LagSeconds - возраст самого старого неотраженного изменения; 0 - все отражено.
+	// LastLagSeconds - задержка от изменения до отражения у последнего отраженного файла
+	LagSeconds     float64 `json:"lag_seconds"`
+	LastLagSeconds float64 `json:"last_lag_seconds"`
+
+	Mirrored int64 `json:"mirrored"` // отражено изменений с запуска
+	Failed   int64 `json:"failed"`   // изменений, не отраженных после всех попыток; их подхватит сверка
+
+	LastMirroredAt *time.Time `json:"last_mirrored_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	LastResyncAt   *time.Time `json:"last_resync_at,omitempty"`
+}
+
+// Replicator отражает изменения файлов источника в приемник. Изменения одного файла
+// объединяются: отражается текущее состояние файла в источнике, поэтому порядок событий
+// не важен, а повтор безопасен
+type Replicator struct {
+	config Config
+	source Source
+	target Target
+	state  *state
+
+	mutex    sync.Mutex
+	wake     *sync.Cond
+	pending  map[string]time.Time // файл -> время самого раннего неотраженного изменения
+	inflight map[string]time.Time
+	retrying map[string]time.Time
+	attempts map[string]int
+	closed   bool
+	status   Status
+
+	resync chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New открывает журнал и запускает отражение и сверку с каталогом. Первая сверка
+// выполняется сразу, чтобы отразить изменения, сделанные без зеркалирования
+func New(cfg Config, source Source, target Target) (*Replicator, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Second
+	}
+
+	st, err := openState(cfg.StatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replicator{
+		config:   cfg,
+		source:   source,
+		target:   target,
+		state:    st,
+		pending:  make(map[string]time.Time),
+		inflight: make(map[string]time.Time),
+		retrying: make(map[string]time.Time),
+		attempts: make(map[string]int),
+		resync:   make(chan struct{}, 1),
+	}
+	r.wake = sync.NewCond(&r.mutex)
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < cfg.Concurrency; i++ {
+		r.wg.Add(1)
+		go r.worker()
+	}
+	r.wg.Add(1)
+	go r.runResync()
+
+	return r, nil
+}
+
+// Consume ставит в очередь файлы из событий канала, пока он не будет закрыт
+func (r *Replicator) Consume(ch <-chan events.Event) {
+	for event := range ch {
+		switch event.Type {
+		case events.FileUploaded, events.FileReplaced, events.FileDeleted, events.FileExpired, events.FileVisibilityChanged:
+			r.enqueue(event.File.ID, event.Timestamp)
+		}
+	}
+}
+
+// Resync запрашивает внеочередную сверку с каталогом
+func (r *Replicator) Resync() {
+	select {
+	case r.resync <- struct{}{}:
+	default:
+	}
+}
+
+// Status возвращает состояние зеркалирования
+func (r *Replicator) Status() Status {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	status := r.status
+	status.Files = r.state.len()
+
+	// Файл может одновременно отражаться и ждать очереди из-за нового изменения
+	oldest := make(map[string]time.Time)
+	for _, queue := range []map[string]time.Time{r.pending, r.inflight, r.retrying} {
+		for id, since := range queue {
+			if current, ok := oldest[id]; !ok || since.Before(current) {
+				oldest[id] = since
+			}
+		}
+	}
+	status.Pending = len(oldest)
+
+	var earliest time.Time
+	for _, since := range oldest {
+		if earliest.IsZero() || since.Before(earliest) {
+			earliest = since
+		}
+	}
+	if !earliest.IsZero() {
+		status.LagSeconds = time.Since(earliest).Seconds()
+	}
+	return status
+}
+
+// Close прекращает отражение, прерывая передачу файлов, и закрывает журнал. Неотраженные
+// изменения подхватит сверка при следующем запуске
+func (r *Replicator) Close() error {
+	r.mutex.Lock()
+	r.closed = true
+	r.wake.Broadcast()
+	r.mutex.Unlock()
+
+	r.cancel()
+	r.wg.Wait()
+	return r.state.Close()
+}
+
+// enqueue ставит файл в очередь; since - время изменения, от которого считается задержка
+func (r *Replicator) enqueue(id string, since time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return
+	}
+	if current, ok := r.pending[id]; !ok || since.Before(current) {
+		r.pending[id] = since
+	}
+	r.wake.Signal()
+}
+
+// next ждет файл, который не отражается другим обработчиком, и отмечает его как отражаемый.
+// Первым выбирается файл с самым старым изменением. false - отражение остановлено
+func (r *Replicator) next() (string, time.Time, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for {
+		if r.closed {
+			return "", time.Time{}, false
+		}
+
+		var (
+			id    string
+			since time.Time
+		)
+		for candidate, candidateSince := range r.pending {
+			if _, busy := r.inflight[candidate]; busy {
+				continue
+			}
+			if id == "" || candidateSince.Before(since) {
+				id, since = candidate, candidateSince
+			}
+		}
+		if id != "" {
+			delete(r.pending, id)
+			if retrySince, ok := r.retrying[id]; ok {
+				delete(r.retrying, id)
+				if retrySince.Before(since) {
+					since = retrySince
+				}
+			}
+			r.inflight[id] = since
+			return id, since, true
+		}
+		r.wake.Wait()
+	}
+}
+
+// done завершает отражение файла: при ошибке назначает повтор с удвоением задержки, а
+// после MaxRetries повторов оставляет изменение до следующей сверки
+func (r *Replicator) done(id string, since time.Time, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.inflight, id)
+	// Файл мог измениться во время отражения и ждать очереди
+	r.wake.Signal()
+
+	now := time.Now().UTC()
+	if err == nil {
+		delete(r.attempts, id)
+		r.status.Mirrored++
+		r.status.LastMirroredAt = &now
+		r.status.LastLagSeconds = now.Sub(since).Seconds()
+		return
+	}
+	if r.closed {
+		return
+	}
+
+	r.status.LastError = fmt.Sprintf("%s: %v", id, err)
+	r.status.LastErrorAt = &now
+
+	r.attempts[id]++
+	attempts := r.attempts[id]
+	if attempts > r.config.MaxRetries {
+		delete(r.attempts, id)
+		r.status.Failed++
+		log.Printf("Не удалось отразить файл %s после %d попыток: %v", id, attempts, err)
+		return
+	}
+
+	log.Printf("Не удалось отразить файл %s (попытка %d): %v", id, attempts, err)
+	r.retrying[id] = since
+	delay := r.config.RetryDelay << (attempts - 1)
+	time.AfterFunc(delay, func() {
+		r.mutex.Lock()
+		retrySince, ok := r.retrying[id]
+		delete(r.retrying, id)
+		r.mutex.Unlock()
+		if ok {
+			r.enqueue(id, retrySince)
+		}
+	})
+}
+
+// worker отражает файлы из очереди
+func (r *Replicator) worker() {
+	defer r.wg.Done()
+
+	for {
+		id, since, ok := r.next()
+		if !ok {
+			return
+		}
+		r.done(id, since, r.syncFile(r.ctx, id))
+	}
+}
+
+// runResync сверяет каталог с журналом при запуске, с периодом ResyncInterval и по запросу
+func (r *Replicator) runResync() {
+	defer r.wg.Done()
+
+	var tick <-chan time.Time
+	if r.config.ResyncInterval > 0 {
+		ticker := time.NewTicker(r.config.ResyncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := r.resyncOnce(r.ctx); err != nil && r.ctx.Err() == nil {
+			log.Printf("Не удалось сверить зеркало с каталогом: %v", err)
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-tick:
+		case <-r.resync:
+		}
+	}
+}
+
+// resyncOnce ставит в очередь файлы, копии которых в приемнике отсутствуют или устарели,
+// и файлы, удаленные из источника, но оставшиеся в приемнике
+func (r *Replicator) resyncOnce(ctx context.Context) error {
+	files, err := r.source.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	present := make(map[string]bool, len(files))
+	for _, metadata := range files {
+		present[metadata.ID] = true
+		if record, ok := r.state.get(metadata.ID); !ok || !sameContent(record, metadata) || !sameAccess(record, metadata) {
+			r.enqueue(metadata.ID, now)
+		}
+	}
+	for _, id := range r.state.ids() {
+		if !present[id] {
+			r.enqueue(id, now)
+		}
+	}
+
+	r.mutex.Lock()
+	r.status.LastResyncAt = &now
+	r.mutex.Unlock()
+	return nil
+}
+
+// syncFile приводит копию файла id в приемнике к текущему состоянию файла в источнике
+func (r *Replicator) syncFile(ctx context.Context, id string) error {
+	metadata, err := r.source.Get(ctx, id)
+	if errors.Is(err, catalog.ErrNotFound) {
+		return r.remove(ctx, id)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось получить метаданные: %w", err)
+	}
+
+	record, mirrored := r.state.get(id)
+	if mirrored && sameContent(record, metadata) {
+		if sameAccess(record, metadata) {
+			return nil
+		}
+		if err := r.updateAccess(ctx, record.TargetID, record, metadata); err != nil {
+			return err
+		}
+		record.Public, record.Grants = metadata.Public, metadata.Grants
+		record.MirroredAt = time.Now().UTC()
+		return r.state.put(record)
+	}
+
+	copied, err := r.copyFile(ctx, metadata)
+	if err != nil {
+		return err
+	}
+	if err := r.updateAccess(ctx, copied.ID, Record{}, metadata); err != nil {
+		r.discard(copied.ID)
+		return err
+	}
+
+	if err := r.state.put(Record{
+		SourceID:   id,
+		TargetID:   copied.ID,
+		Size:       metadata.Size,
+		Checksum:   metadata.Checksum,
+		Public:     metadata.Public,
+		Grants:     metadata.Grants,
+		MirroredAt: time.Now().UTC(),
+	}); err != nil {
+		r.discard(copied.ID)
+		return err
+	}
+
+	if mirrored {
+		// Прежняя копия хранит устаревшее содержимое
+		r.discard(record.TargetID)
+	}
+	return nil
+}
+
+// copyFile передает содержимое файла в приемник потоком и сверяет копию с источником
+func (r *Replicator) copyFile(ctx context.Context, metadata *chunking.FileMetadata) (*chunking.FileMetadata, error) {
+	body, err := r.source.Open(ctx, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+	defer body.Close()
+
+	opts := []client.TransferOption{client.WithPath(metadata.Path)}
+	if metadata.ContentType != "" {
+		opts = append(opts, client.WithContentType(metadata.ContentType))
+	}
+	if algorithmOf(metadata) == chunking.HashSHA256 {
+		// Приемник сверит содержимое с контрольной суммой источника до сохранения
+		opts = append(opts, client.WithContentSHA256(metadata.Checksum))
+	}
+
+	copied, err := r.target.UploadReader(ctx, metadata.OriginalName, body, metadata.Size, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить копию: %w", err)
+	}
+
+	if copied.Size != metadata.Size ||
+		(algorithmOf(copied) == algorithmOf(metadata) && copied.Checksum != metadata.Checksum) {
+		r.discard(copied.ID)
+		return nil, fmt.Errorf("копия не совпадает с файлом источника")
+	}
+	return copied, nil
+}
+
+// updateAccess приводит публичность и права копии targetID к файлу источника; record -
+// состояние копии до изменения
+func (r *Replicator) updateAccess(ctx context.Context, targetID string, record Record, metadata *chunking.FileMetadata) error {
+	if record.Public != metadata.Public {
+		if _, err := r.target.SetFileVisibilityContext(ctx, targetID, metadata.Public); err != nil {
+			return fmt.Errorf("не удалось изменить публичный доступ к копии: %w", err)
+		}
+	}
+
+	for _, principal := range sortedKeys(metadata.Grants) {
+		permission := metadata.Grants[principal]
+		if record.Grants[principal] == permission {
+			continue
+		}
+		if _, err := r.target.GrantFileAccessContext(ctx, targetID, principal, permission); err != nil {
+			return fmt.Errorf("не удалось выдать права %s на копию: %w", principal, err)
+		}
+	}
+	for _, principal := range sortedKeys(record.Grants) {
+		if _, kept := metadata.Grants[principal]; kept {
+			continue
+		}
+		if _, err := r.target.RevokeFileAccessContext(ctx, targetID, principal); err != nil {
+			return fmt.Errorf("не удалось отозвать права %s на копию: %w", principal, err)
+		}
+	}
+	return nil
+}
+
+// remove удаляет копию файла, удаленного из источника
+func (r *Replicator) remove(ctx context.Context, id string) error {
+	record, mirrored := r.state.get(id)
+	if !mirrored {
+		return nil
+	}
+
+	err := r.target.DeleteFileContext(ctx, record.TargetID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		return fmt.Errorf("не удалось удалить копию: %w", err)
+	}
+	return r.state.put(Record{SourceID: id, MirroredAt: time.Now().UTC(), Deleted: true})
+}
+
+// discard удаляет лишнюю копию из приемника; удаление не прерывается остановкой
+func (r *Replicator) discard(targetID string) {
+	err := r.target.DeleteFileContext(context.Background(), targetID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		log.Printf("Не удалось удалить копию %s из приемника: %v", targetID, err)
+	}
+}
+
+// sameContent сообщает, что копия хранит текущее содержимое файла
+func sameContent(record Record, metadata *chunking.FileMetadata) bool {
+	return record.Size == metadata.Size && record.Checksum == metadata.Checksum
+}
+
+// sameAccess сообщает, что публичность и права копии совпадают с файлом
+func sameAccess(record Record, metadata *chunking.FileMetadata) bool {
+	return record.Public == metadata.Public && maps.Equal(record.Grants, metadata.Grants)
+}
+
+// algorithmOf возвращает алгоритм контрольной суммы файла; пустой означает SHA256
+func algorithmOf(metadata *chunking.FileMetadata) chunking.HashAlgorithm {
+	if metadata.ChecksumAlgorithm == "" {
+		return chunking.HashSHA256
+	}
+	return metadata.ChecksumAlgorithm
+}
+
+// sortedKeys возвращает имена клиентов с правами в алфавитном порядке
+func sortedKeys(grants map[string]chunking.Permission) []string {
+	keys := make([]string, 0, len(grants))
+	for key := range grants {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/catalog"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/client"
+	"TestCase/pkg/client/clienttest"
+	"TestCase/pkg/events"
+)
+
+// testSource - источник с файлами в памяти
+type testSource struct {
+	mutex sync.Mutex
+	files map[string]*chunking.FileMetadata
+	data  map[string][]byte
+}
+
+func newTestSource() *testSource {
+	return &testSource{files: make(map[string]*chunking.FileMetadata), data: make(map[string][]byte)}
+}
+
+func (s *testSource) put(id, content string, public bool) *chunking.FileMetadata {
+	sum := sha256.Sum256([]byte(content))
+	metadata := &chunking.FileMetadata{
+		ID:           id,
+		OriginalName: id + ".txt",
+		Size:         int64(len(content)),
+		Checksum:     hex.EncodeToString(sum[:]),
+		Public:       public,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.files[id] = metadata
+	s.data[id] = []byte(content)
+	return metadata
+}
+
+func (s *testSource) remove(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.files, id)
+	delete(s.data, id)
+}
+
+func (s *testSource) Get(ctx context.Context, id string) (*chunking.FileMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	metadata, ok := s.files[id]
+	if !ok {
+		return nil, catalog.ErrNotFound
+	}
+	copied := *metadata
+	return &copied, nil
+}
+
+func (s *testSource) List(ctx context.Context) ([]*chunking.FileMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	files := make([]*chunking.FileMetadata, 0, len(s.files))
+	for _, metadata := range s.files {
+		copied := *metadata
+		files = append(files, &copied)
+	}
+	return files, nil
+}
+
+func (s *testSource) Open(ctx context.Context, metadata *chunking.FileMetadata) (io.ReadCloser, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return io.NopCloser(bytes.NewReader(s.data[metadata.ID])), nil
+}
+
+// testTarget - приемник в памяти с публичностью файлов
+type testTarget struct {
+	*clienttest.FileService
+}
+
+func (t testTarget) SetFileVisibilityContext(ctx context.Context, fileID string, public bool) (*client.Visibility, error) {
+	err := t.Update(fileID, func(metadata *chunking.FileMetadata) { metadata.Public = public })
+	return &client.Visibility{ID: fileID, Public: public}, err
+}
+
+func (t testTarget) GrantFileAccessContext(ctx context.Context, fileID, principal string, permission chunking.Permission) (*client.FileACL, error) {
+	return &client.FileACL{ID: fileID}, nil
+}
+
+func (t testTarget) RevokeFileAccessContext(ctx context.Context, fileID, principal string) (*client.FileACL, error) {
+	return &client.FileACL{ID: fileID}, nil
+}
+
+// waitSynced ждет, пока все изменения будут отражены
+func waitSynced(t *testing.T, r *Replicator, files int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		status := r.Status()
+		return status.Pending == 0 && status.Files == files
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// targetFiles возвращает содержимое файлов приемника
+func targetFiles(t *testing.T, target testTarget) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	ids, err := target.ListFilesFilteredContext(ctx, client.ListFilter{})
+	require.NoError(t, err)
+
+	files := make(map[string]string, len(ids))
+	for _, id := range ids {
+		metadata, err := target.GetFileInfoContext(ctx, id)
+		require.NoError(t, err)
+		data, err := target.Content(ctx, id)
+		require.NoError(t, err)
+		files[metadata.OriginalName] = string(data)
+	}
+	return files
+}
+
+func TestReplicatorMirrorsChanges(t *testing.T) {
+	source := newTestSource()
+	target := testTarget{clienttest.NewFileService()}
+	statePath := filepath.Join(t.TempDir(), "mirror.jsonl")
+	source.put("a", "first", true)
+
+	// Файл, загруженный до включения зеркала, отражается первой сверкой
+	r, err := New(Config{StatePath: statePath, Concurrency: 2, RetryDelay: 10 * time.Millisecond}, source, target)
+	require.NoError(t, err)
+	waitSynced(t, r, 1)
+	assert.Equal(t, map[string]string{"a.txt": "first"}, targetFiles(t, target))
+
+	ids, err := target.ListFilesFilteredContext(context.Background(), client.ListFilter{})
+	require.NoError(t, err)
+	copied, err := target.GetFileInfoContext(context.Background(), ids[0])
+	require.NoError(t, err)
+	assert.True(t, copied.Public)
+
+	ch := make(chan events.Event, 10)
+	go r.Consume(ch)
+
+	// Замена содержимого: новая копия вместо прежней
+	ch <- events.NewFileEvent(events.FileReplaced, source.put("a", "second", true))
+	ch <- events.NewFileEvent(events.FileUploaded, source.put("b", "other", false))
+	waitSynced(t, r, 2)
+	assert.Equal(t, map[string]string{"a.txt": "second", "b.txt": "other"}, targetFiles(t, target))
+
+	metadata, err := source.Get(context.Background(), "a")
+	require.NoError(t, err)
+	source.remove("a")
+	ch <- events.NewFileEvent(events.FileDeleted, metadata)
+	waitSynced(t, r, 1)
+	assert.Equal(t, map[string]string{"b.txt": "other"}, targetFiles(t, target))
+	assert.EqualValues(t, 4, r.Status().Mirrored)
+	close(ch)
+	require.NoError(t, r.Close())
+
+	// После перезапуска журнал не дает отразить файлы повторно, а сверка находит изменения
+	// без событий
+	source.put("c", "offline", false)
+	r, err = New(Config{StatePath: statePath}, source, target)
+	require.NoError(t, err)
+	defer r.Close()
+	waitSynced(t, r, 2)
+	assert.Equal(t, map[string]string{"b.txt": "other", "c.txt": "offline"}, targetFiles(t, target))
+}
+
+func TestReplicatorRetriesFailures(t *testing.T) {
+	source := newTestSource()
+	target := testTarget{clienttest.NewFileService()}
+	target.Err = assert.AnError
+	source.put("a", "data", false)
+
+	r, err := New(Config{MaxRetries: 1, RetryDelay: 10 * time.Millisecond}, source, target)
+	require.NoError(t, err)
+	defer r.Close()
+
+	require.Eventually(t, func() bool { return r.Status().Failed == 1 }, 5*time.Second, 10*time.Millisecond)
+	status := r.Status()
+	assert.Equal(t, 0, status.Files)
+	assert.Contains(t, status.LastError, assert.AnError.Error())
+
+	// Неотраженное изменение подхватывает следующая сверка
+	target.Err = nil
+	r.Resync()
+	waitSynced(t, r, 1)
+	assert.Equal(t, map[string]string{"a.txt": "data"}, targetFiles(t, target))
+}
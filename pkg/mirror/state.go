@@ -0,0 +1,180 @@
+package mirror
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// Record - файл, отраженный в приемник
+type Record struct {
+	SourceID   string                         `json:"source_id"`
+	TargetID   string                         `json:"target_id,omitempty"`
+	Size       int64                          `json:"size,omitempty"`
+	Checksum   string                         `json:"checksum,omitempty"` // контрольная сумма файла в источнике
+	Public     bool                           `json:"public,omitempty"`
+	Grants     map[string]chunking.Permission `json:"grants,omitempty"`
+	MirroredAt time.Time                      `json:"mirrored_at"`
+	Deleted    bool                           `json:"deleted,omitempty"` // копия удалена вслед за файлом источника
+}
+
+// state - соответствие файлов источника их копиям в приемнике. Хранится в журнале по
+// записи JSON в строке; при открытии журнал сжимается до последней записи каждого файла
+type state struct {
+	mutex   sync.Mutex
+	path    string
+	file    *os.File // nil - состояние только в памяти
+	records map[string]Record
+}
+
+// openState читает журнал path и открывает его для дописывания; пустой path - состояние
+// только в памяти. Оборванная последняя строка после аварийной остановки пропускается
+func openState(path string) (*state, error) {
+	s := &state{path: path, records: make(map[string]Record)}
+	if path == "" {
+		return s, nil
+	}
+
+	existing, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("не удалось открыть журнал зеркала: %w", err)
+	default:
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			var record Record
+			if json.Unmarshal(scanner.Bytes(), &record) != nil || record.SourceID == "" {
+				continue
+			}
+			if record.Deleted {
+				delete(s.records, record.SourceID)
+			} else {
+				s.records[record.SourceID] = record
+			}
+		}
+		err := scanner.Err()
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать журнал зеркала: %w", err)
+		}
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// compact переписывает журнал текущими записями и открывает его для дописывания
+func (s *state) compact() error {
+	ids := make([]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("не удалось записать журнал зеркала: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, id := range ids {
+		line, err := json.Marshal(s.records[id])
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать журнал зеркала: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать журнал зеркала: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("не удалось заменить журнал зеркала: %w", err)
+	}
+
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал зеркала: %w", err)
+	}
+	return nil
+}
+
+// get возвращает запись о копии файла источника
+func (s *state) get(sourceID string) (Record, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record, ok := s.records[sourceID]
+	return record, ok
+}
+
+// ids возвращает идентификаторы всех отраженных файлов источника
+func (s *state) ids() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := make([]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// len возвращает число отраженных файлов
+func (s *state) len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.records)
+}
+
+// put дописывает запись в журнал и сбрасывает его на диск; запись с Deleted удаляет файл
+// из состояния
+func (s *state) put(record Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := s.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("не удалось записать журнал зеркала: %w", err)
+		}
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("не удалось записать журнал зеркала: %w", err)
+		}
+	}
+
+	if record.Deleted {
+		delete(s.records, record.SourceID)
+	} else {
+		record.Grants = maps.Clone(record.Grants)
+		s.records[record.SourceID] = record
+	}
+	return nil
+}
+
+// Close закрывает журнал
+func (s *state) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
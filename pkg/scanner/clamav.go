@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize - размер частей, которыми данные передаются clamd
+const clamavChunkSize = 64 * 1024
+
+// ClamAV проверяет файлы демоном clamd по протоколу INSTREAM
+type ClamAV struct {
+	address string // host:port clamd
+	timeout time.Duration
+}
+
+// NewClamAV создает Scanner для clamd, слушающего TCP адрес address
+func NewClamAV(address string, timeout time.Duration) *ClamAV {
+	return &ClamAV{address: address, timeout: timeout}
+}
+
+// Scan передает данные clamd частями и разбирает его вердикт
+func (c *ClamAV) Scan(ctx context.Context, data io.Reader) (Result, error) {
+	conn, err := dial(ctx, c.address, c.timeout)
+	if err != nil {
+		return Result{}, fmt.Errorf("не удалось подключиться к clamd: %w", err)
+	}
+	defer conn.Close()
+
+	writer := bufio.NewWriter(conn)
+	if _, err := writer.WriteString("zINSTREAM\x00"); err != nil {
+		return Result{}, fmt.Errorf("не удалось отправить команду clamd: %w", err)
+	}
+
+	// Каждая часть предваряется длиной в 4 байта, нулевая длина завершает поток
+	buffer := make([]byte, clamavChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := data.Read(buffer)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := writer.Write(size); err != nil {
+				return Result{}, fmt.Errorf("не удалось передать данные clamd: %w", err)
+			}
+			if _, err := writer.Write(buffer[:n]); err != nil {
+				return Result{}, fmt.Errorf("не удалось передать данные clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, fmt.Errorf("не удалось прочитать файл: %w", readErr)
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := writer.Write(size); err != nil {
+		return Result{}, fmt.Errorf("не удалось передать данные clamd: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return Result{}, fmt.Errorf("не удалось передать данные clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return Result{}, fmt.Errorf("не удалось получить ответ clamd: %w", err)
+	}
+	return parseClamAVReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamAVReply разбирает ответ вида "stream: OK" или "stream: Eicar-Signature FOUND"
+func parseClamAVReply(reply string) (Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Threat: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("clamd вернул ошибку: %s", reply)
+	}
+}
+
+// dial подключается к адресу с учетом контекста и устанавливает срок всего обмена
+func dial(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
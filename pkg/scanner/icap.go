@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icapDefaultPort - стандартный порт ICAP
+const icapDefaultPort = "1344"
+
+// ICAP проверяет файлы ICAP сервером (RFC 3507) запросом RESPMOD: файл передается
+// как тело HTTP ответа, а сервер сообщает о найденной угрозе в заголовках
+type ICAP struct {
+	service *url.URL // icap://host:port/service
+	timeout time.Duration
+}
+
+// NewICAP создает Scanner для сервиса ICAP по адресу вида icap://host:1344/avscan
+func NewICAP(service string, timeout time.Duration) (*ICAP, error) {
+	parsed, err := url.Parse(service)
+	if err != nil || parsed.Scheme != "icap" || parsed.Host == "" {
+		return nil, fmt.Errorf("неверный адрес сервиса ICAP %q, ожидается icap://host:port/service", service)
+	}
+	if parsed.Port() == "" {
+		parsed.Host = net.JoinHostPort(parsed.Hostname(), icapDefaultPort)
+	}
+	return &ICAP{service: parsed, timeout: timeout}, nil
+}
+
+// icapInfectionHeaders - заголовки, которыми ICAP серверы разных производителей сообщают об угрозе
+var icapInfectionHeaders = []string{"X-Infection-Found", "X-Virus-Id", "X-Violations-Found"}
+
+// Scan передает данные ICAP серверу и разбирает его ответ
+func (s *ICAP) Scan(ctx context.Context, data io.Reader) (Result, error) {
+	conn, err := dial(ctx, s.service.Host, s.timeout)
+	if err != nil {
+		return Result{}, fmt.Errorf("не удалось подключиться к ICAP серверу: %w", err)
+	}
+	defer conn.Close()
+
+	httpHeader := "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nTransfer-Encoding: chunked\r\n\r\n"
+
+	writer := bufio.NewWriter(conn)
+	fmt.Fprintf(writer, "RESPMOD %s ICAP/1.0\r\n", s.service)
+	fmt.Fprintf(writer, "Host: %s\r\n", s.service.Host)
+	fmt.Fprintf(writer, "Allow: 204\r\n")
+	fmt.Fprintf(writer, "Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(httpHeader))
+	writer.WriteString(httpHeader)
+
+	// Тело передается в chunked кодировке, нулевая часть завершает его
+	buffer := make([]byte, 64*1024)
+	for {
+		n, readErr := data.Read(buffer)
+		if n > 0 {
+			fmt.Fprintf(writer, "%x\r\n", n)
+			writer.Write(buffer[:n])
+			writer.WriteString("\r\n")
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, fmt.Errorf("не удалось прочитать файл: %w", readErr)
+		}
+	}
+	writer.WriteString("0\r\n\r\n")
+	if err := writer.Flush(); err != nil {
+		return Result{}, fmt.Errorf("не удалось передать данные ICAP серверу: %w", err)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	statusLine, err := reader.ReadLine()
+	if err != nil {
+		return Result{}, fmt.Errorf("не удалось получить ответ ICAP сервера: %w", err)
+	}
+	header, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("не удалось разобрать ответ ICAP сервера: %w", err)
+	}
+
+	return parseICAPResponse(statusLine, header)
+}
+
+// parseICAPResponse определяет результат по статусу и заголовкам ответа ICAP сервера.
+// 204 означает, что файл не изменен и угроз нет
+func parseICAPResponse(statusLine string, header textproto.MIMEHeader) (Result, error) {
+	fields := strings.Fields(statusLine)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "ICAP/") {
+		return Result{}, fmt.Errorf("неверный ответ ICAP сервера: %q", statusLine)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Result{}, fmt.Errorf("неверный ответ ICAP сервера: %q", statusLine)
+	}
+
+	switch code {
+	case 204:
+		return Result{}, nil
+	case 200:
+		for _, name := range icapInfectionHeaders {
+			if value := header.Get(name); value != "" {
+				return Result{Infected: true, Threat: icapThreat(value)}, nil
+			}
+		}
+		return Result{}, nil
+	default:
+		return Result{}, fmt.Errorf("ICAP сервер вернул ошибку: %s", statusLine)
+	}
+}
+
+// icapThreat извлекает название угрозы из значения вида
+// "Type=0; Resolution=2; Threat=Eicar-Signature;" или возвращает значение как есть
+func icapThreat(value string) string {
+	for _, part := range strings.Split(value, ";") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(part), "Threat="); ok {
+			return name
+		}
+	}
+	return strings.TrimSpace(value)
+}
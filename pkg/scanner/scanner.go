@@ -0,0 +1,18 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Result - результат проверки файла
+type Result struct {
+	Infected bool   `json:"infected"`
+	Threat   string `json:"threat,omitempty"` // название найденной угрозы
+}
+
+// Scanner проверяет содержимое файла на вредоносное ПО.
+// Ошибка означает, что проверку выполнить не удалось, а не что файл заражен
+type Scanner interface {
+	Scan(ctx context.Context, data io.Reader) (Result, error)
+}
@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// serve принимает подключения и передает их обработчику до завершения теста
+func serve(t *testing.T, handle func(conn net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// fakeClamd отвечает как clamd на команду INSTREAM
+func fakeClamd(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	if command, err := reader.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+		return
+	}
+
+	var data bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, size); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, reader, int64(n)); err != nil {
+			return
+		}
+	}
+
+	if strings.Contains(data.String(), "EICAR-STANDARD") {
+		conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+	} else {
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
+
+func TestClamAV(t *testing.T) {
+	scanner := NewClamAV(serve(t, fakeClamd), 5*time.Second)
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader(strings.Repeat("clean ", 50000)))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Infected: true, Threat: "Eicar-Signature"}, result)
+
+	_, err = parseClamAVReply("INSTREAM size limit exceeded. ERROR")
+	assert.Error(t, err)
+}
+
+// fakeICAP отвечает как ICAP сервер на RESPMOD с chunked телом
+func fakeICAP(conn net.Conn) {
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	if line, err := reader.ReadLine(); err != nil || !strings.HasPrefix(line, "RESPMOD icap://") {
+		return
+	}
+	if _, err := reader.ReadMIMEHeader(); err != nil { // заголовки ICAP
+		return
+	}
+	if _, err := http.ReadResponse(reader.R, nil); err != nil { // заголовки вложенного HTTP ответа
+		return
+	}
+
+	var body bytes.Buffer
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		var size int
+		if _, err := fmt.Sscanf(line, "%x", &size); err != nil {
+			return
+		}
+		if size == 0 {
+			reader.ReadLine()
+			break
+		}
+		io.CopyN(&body, reader.R, int64(size))
+		reader.ReadLine()
+	}
+
+	if strings.Contains(body.String(), "EICAR-STANDARD") {
+		conn.Write([]byte("ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=Eicar-Test-Signature;\r\nEncapsulated: null-body=0\r\n\r\n"))
+	} else {
+		conn.Write([]byte("ICAP/1.0 204 No Content\r\nEncapsulated: null-body=0\r\n\r\n"))
+	}
+}
+
+func TestICAP(t *testing.T) {
+	scanner, err := NewICAP("icap://"+serve(t, fakeICAP)+"/avscan", 5*time.Second)
+	require.NoError(t, err)
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader(strings.Repeat("clean ", 50000)))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Infected: true, Threat: "Eicar-Test-Signature"}, result)
+
+	_, err = NewICAP("http://localhost/avscan", time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+
+	"TestCase/pkg/chunking"
+)
+
+// ChunkStore - операции с кусками на сервере хранения. Его реализует StorageClient, а
+// storagetest.ChunkStore хранит куски в памяти для модульных тестов без сервера
+type ChunkStore interface {
+	StoreChunkContext(ctx context.Context, chunk *chunking.FileChunk) error
+	GetChunkContext(ctx context.Context, chunkID string) (*chunking.FileChunk, error)
+	DeleteChunkContext(ctx context.Context, chunkID string) error
+	HealthCheckContext(ctx context.Context) error
+}
+
+var _ ChunkStore = (*StorageClient)(nil)
@@ -2,10 +2,13 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"TestCase/pkg/chunking"
@@ -15,38 +18,84 @@ import (
 type StorageClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	Secret     string      // общий ключ подписи внутренних запросов; пусто - запросы не подписываются
+	Retry      RetryPolicy // повтор запросов; нулевое значение - без повторов
+	Logger     Logger      // журнал запросов; nil - запросы не записываются
 }
 
-// NewStorageClient создает новый клиент для сервера хранения
-func NewStorageClient(baseURL string) *StorageClient {
+// defaultStorageTimeout - таймаут запроса к серверу хранения, если он не задан опцией
+const defaultStorageTimeout = 30 * time.Second
+
+// NewStorageClient создает новый клиент для сервера хранения. baseURL - адрес http://host:port
+// или unix:///path для сервера, слушающего unix сокет
+func NewStorageClient(baseURL string, opts ...ClientOption) *StorageClient {
+	// Сервер на unix сокете: запросы уходят на сокет, а хост в URL условный
+	if socket, ok := UnixSocketPath(baseURL); ok {
+		return newStorageClient(unixBaseURL, UnixTransport(socket), opts)
+	}
+	return newStorageClient(baseURL, nil, opts)
+}
+
+// newStorageClient создает клиент с транспортом transport, если опции не задают другой
+func newStorageClient(baseURL string, transport http.RoundTripper, opts []ClientOption) *StorageClient {
+	options := NewClientOptions(defaultStorageTimeout, transport, opts...)
 	return &StorageClient{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		BaseURL:    baseURL,
+		HTTPClient: options.HTTPClient(),
+		Retry:      options.Retry,
+		Logger:     options.Logger,
+	}
+}
+
+// do отправляет запрос, подписав его общим ключом, если он задан. Повтор подписывается
+// заново: сервер отклоняет уже предъявленную подпись
+func (c *StorageClient) do(req *http.Request) (*http.Response, error) {
+	var sign func(*http.Request) error
+	if c.Secret != "" {
+		sign = func(req *http.Request) error {
+			return SignRequest(req, c.Secret)
+		}
 	}
+	return c.Retry.Do(c.HTTPClient, req, c.Logger, sign)
 }
 
 // StoreChunk сохраняет кусок файла на сервере хранения
 func (c *StorageClient) StoreChunk(chunk *chunking.FileChunk) error {
+	return c.StoreChunkContext(context.Background(), chunk)
+}
+
+// StoreChunkContext сохраняет кусок файла на сервере хранения с учетом контекста
+func (c *StorageClient) StoreChunkContext(ctx context.Context, chunk *chunking.FileChunk) error {
+	return c.StoreChunkRefsContext(ctx, chunk, 0)
+}
+
+// StoreChunkRefsContext сохраняет кусок вместе с refs ссылками файлов, которые добавляются
+// к уже имеющимся на сервере. При refs == 0 поведение совпадает с StoreChunkContext
+func (c *StorageClient) StoreChunkRefsContext(ctx context.Context, chunk *chunking.FileChunk, refs int) error {
 	data, err := json.Marshal(chunk)
 	if err != nil {
 		return fmt.Errorf("не удалось сериализовать кусок: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Post(
-		fmt.Sprintf("%s/api/v1/chunks", c.BaseURL),
-		"application/json",
-		bytes.NewBuffer(data),
-	)
+	endpoint := fmt.Sprintf("%s/api/v1/chunks", c.BaseURL)
+	if refs > 0 {
+		endpoint = fmt.Sprintf("%s?refs=%d", endpoint, refs)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
 	}
 
 	return nil
@@ -54,15 +103,34 @@ func (c *StorageClient) StoreChunk(chunk *chunking.FileChunk) error {
 
 // GetChunk получает кусок файла с сервера хранения
 func (c *StorageClient) GetChunk(chunkID string) (*chunking.FileChunk, error) {
-	resp, err := c.HTTPClient.Get(fmt.Sprintf("%s/api/v1/chunks/%s", c.BaseURL, chunkID))
+	return c.GetChunkContext(context.Background(), chunkID)
+}
+
+// GetChunkContext получает кусок файла с сервера хранения с учетом контекста
+func (c *StorageClient) GetChunkContext(ctx context.Context, chunkID string) (*chunking.FileChunk, error) {
+	return c.ReadChunkContext(ctx, chunkID, "")
+}
+
+// ReadChunkContext получает кусок по токену чтения из манифеста файла. Токен нужен
+// клиентам, скачивающим кусок напрямую с сервера, который принимает только подписанные
+// внутренние запросы; пустой токен не передается
+func (c *StorageClient) ReadChunkContext(ctx context.Context, chunkID, readToken string) (*chunking.FileChunk, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/chunks/%s", c.BaseURL, chunkID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	if readToken != "" {
+		req.Header.Set("Authorization", "Bearer "+readToken)
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return nil, responseError(resp)
 	}
 
 	var chunk chunking.FileChunk
@@ -70,33 +138,128 @@ func (c *StorageClient) GetChunk(chunkID string) (*chunking.FileChunk, error) {
 		return nil, fmt.Errorf("не удалось декодировать ответ: %w", err)
 	}
 
+	// Проверяем, что кусок не был поврежден при хранении или передаче
+	if err := chunking.ValidateChunk(&chunk); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChunkCorrupted, err)
+	}
+
 	return &chunk, nil
 }
 
 // DeleteChunk удаляет кусок файла с сервера хранения
 func (c *StorageClient) DeleteChunk(chunkID string) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/chunks/%s", c.BaseURL, chunkID), nil)
+	return c.DeleteChunkContext(context.Background(), chunkID)
+}
+
+// DeleteChunkContext удаляет кусок файла с сервера хранения с учетом контекста
+func (c *StorageClient) DeleteChunkContext(ctx context.Context, chunkID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/api/v1/chunks/%s", c.BaseURL, chunkID), nil)
 	if err != nil {
 		return fmt.Errorf("не удалось создать запрос: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return responseError(resp)
 	}
 
 	return nil
 }
 
+// IncrementRefContext добавляет ссылку еще одного файла на кусок и возвращает новое число ссылок
+func (c *StorageClient) IncrementRefContext(ctx context.Context, chunkID string) (int, error) {
+	return c.changeRef(ctx, http.MethodPost, chunkID)
+}
+
+// DecrementRefContext снимает ссылку файла с куска и возвращает оставшееся число ссылок.
+// Сервер удаляет кусок, когда ссылок не остается
+func (c *StorageClient) DecrementRefContext(ctx context.Context, chunkID string) (int, error) {
+	return c.changeRef(ctx, http.MethodDelete, chunkID)
+}
+
+// changeRef изменяет число ссылок на кусок
+func (c *StorageClient) changeRef(ctx context.Context, method, chunkID string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/api/v1/chunks/%s/refs", c.BaseURL, chunkID), nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError(resp)
+	}
+
+	var result struct {
+		RefCount int `json:"ref_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("не удалось декодировать ответ: %w", err)
+	}
+
+	return result.RefCount, nil
+}
+
+// MigrateResult - результат передачи куска между серверами хранения
+type MigrateResult struct {
+	Size     int64 `json:"size"`
+	RefCount int   `json:"ref_count"` // ссылки, переданные вместе с куском
+	Deleted  bool  `json:"deleted"`   // кусок удален с исходного сервера
+}
+
+// MigrateChunkContext просит сервер хранения передать кусок напрямую на сервер target (host:port).
+// При keep исходный сервер сохраняет свою копию куска и ее ссылки
+func (c *StorageClient) MigrateChunkContext(ctx context.Context, chunkID, target string, keep bool) (*MigrateResult, error) {
+	query := url.Values{"target": {target}}
+	if keep {
+		query.Set("keep", "true")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/v1/chunks/%s/migrate?%s", c.BaseURL, chunkID, query.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var result MigrateResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось декодировать ответ: %w", err)
+	}
+
+	return &result, nil
+}
+
 // HealthCheck проверяет состояние сервера хранения
 func (c *StorageClient) HealthCheck() error {
-	resp, err := c.HTTPClient.Get(fmt.Sprintf("%s/health", c.BaseURL))
+	return c.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext проверяет состояние сервера хранения с учетом контекста
+func (c *StorageClient) HealthCheckContext(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/health", c.BaseURL), nil)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("не удалось подключиться к серверу: %w", err)
 	}
@@ -109,17 +272,59 @@ func (c *StorageClient) HealthCheck() error {
 	return nil
 }
 
+// NodeStatus - состояние сервера хранения из ответа /health
+type NodeStatus struct {
+	Status    string `json:"status"`
+	ServerID  string `json:"server_id"`
+	UsedBytes int64  `json:"used_bytes"`
+	FreeBytes *int64 `json:"free_bytes,omitempty"` // nil, если сервер не сообщает свободное место
+}
+
+// StatusContext получает состояние сервера хранения, включая занятое и свободное место
+func (c *StorageClient) StatusContext(ctx context.Context) (*NodeStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/health", c.BaseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к серверу: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("сервер вернул статус %d", resp.StatusCode)
+	}
+
+	var status NodeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("не удалось декодировать ответ: %w", err)
+	}
+
+	return &status, nil
+}
+
 // GetInfo получает информацию о сервере хранения
 func (c *StorageClient) GetInfo() (map[string]interface{}, error) {
-	resp, err := c.HTTPClient.Get(fmt.Sprintf("%s/api/v1/info", c.BaseURL))
+	return c.GetInfoContext(context.Background())
+}
+
+// GetInfoContext получает информацию о сервере хранения с учетом контекста
+func (c *StorageClient) GetInfoContext(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/info", c.BaseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, string(body))
+		return nil, responseError(resp)
 	}
 
 	var info map[string]interface{}
@@ -129,3 +334,29 @@ func (c *StorageClient) GetInfo() (map[string]interface{}, error) {
 
 	return info, nil
 }
+
+// responseError возвращает ошибку для неуспешного ответа сервера хранения. Отсутствие куска,
+// его повреждение и нехватка места возвращаются как ErrNotFound, ErrChunkCorrupted и
+// ErrQuotaExceeded, к которым добавляется сообщение сервера
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	message := strings.TrimSpace(string(body))
+	var envelope struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Message != "" {
+		message = envelope.Error.Message
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, message)
+	case http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrChunkCorrupted, message)
+	case http.StatusInsufficientStorage:
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, message)
+	}
+	return fmt.Errorf("сервер вернул ошибку %d: %s", resp.StatusCode, message)
+}
@@ -0,0 +1,14 @@
+package storage
+
+import "errors"
+
+var (
+	// ErrChunkCorrupted возвращается, если данные куска не совпадают с его контрольной суммой
+	ErrChunkCorrupted = errors.New("кусок поврежден")
+
+	// ErrNotFound возвращается, если куска нет на сервере хранения
+	ErrNotFound = errors.New("кусок не найден")
+
+	// ErrQuotaExceeded возвращается, если кусок не помещается в объем сервера хранения
+	ErrQuotaExceeded = errors.New("недостаточно места на сервере хранения")
+)
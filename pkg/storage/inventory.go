@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"TestCase/pkg/chunking"
+)
+
+// ChunkStat - сведения о куске на сервере хранения без его данных
+type ChunkStat struct {
+	ID        string                 `json:"id"`
+	Size      int64                  `json:"size"` // фактический размер данных
+	Checksum  string                 `json:"checksum"`
+	Algorithm chunking.HashAlgorithm `json:"algorithm,omitempty"`
+	RefCount  int                    `json:"ref_count"`
+	// Corrupted - данные не совпадают с контрольной суммой; проверяется только с verify
+	Corrupted bool   `json:"corrupted,omitempty"`
+	Error     string `json:"error,omitempty"` // причина повреждения
+}
+
+// Inventory возвращает сведения обо всех кусках, упорядоченные по идентификатору. С verify
+// контрольная сумма каждого куска пересчитывается по данным, что занимает время,
+// пропорциональное объему хранилища; запись на сервер при этом не блокируется
+func (ms *MemoryStorage) Inventory(verify bool) []ChunkStat {
+	ms.mutex.RLock()
+	stats := make([]ChunkStat, 0, len(ms.chunks))
+	data := make(map[string][]byte, len(ms.chunks))
+	for _, chunk := range ms.chunks {
+		stats = append(stats, ChunkStat{
+			ID:        chunk.ID,
+			Size:      int64(len(chunk.Data)),
+			Checksum:  chunk.Checksum,
+			Algorithm: chunk.Algorithm,
+			RefCount:  refCount(chunk),
+		})
+		if verify {
+			// Данные сохраненного куска не изменяются, поэтому их можно проверить без блокировки
+			data[chunk.ID] = chunk.Data
+		}
+	}
+	ms.mutex.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	if !verify {
+		return stats
+	}
+
+	for i := range stats {
+		checksum, err := chunking.Checksum(stats[i].Algorithm, data[stats[i].ID])
+		switch {
+		case err != nil:
+			stats[i].Corrupted = true
+			stats[i].Error = err.Error()
+		case checksum != stats[i].Checksum:
+			stats[i].Corrupted = true
+			stats[i].Error = "контрольная сумма куска не совпадает"
+		}
+	}
+	return stats
+}
+
+// InventoryContext получает сведения обо всех кусках сервера хранения. С verify сервер
+// пересчитывает контрольные суммы кусков
+func (c *StorageClient) InventoryContext(ctx context.Context, verify bool) ([]ChunkStat, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/inventory", c.BaseURL)
+	if verify {
+		endpoint += "?verify=true"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var result struct {
+		Chunks []ChunkStat `json:"chunks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось декодировать ответ: %w", err)
+	}
+	return result.Chunks, nil
+}
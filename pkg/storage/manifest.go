@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+// FileManifest - копия метаданных файла, которую API сервер хранит на серверах хранения
+// рядом с кусками, чтобы каталог можно было восстановить после полной потери метаданных
+type FileManifest struct {
+	Metadata  *chunking.FileMetadata `json:"metadata"`
+	UpdatedAt time.Time              `json:"updated_at"` // время записи; из нескольких копий новее та, что записана позже
+}
+
+// PutManifest сохраняет или заменяет манифест файла fileID в формате JSON.
+// Манифесты не учитываются в занятом объеме хранилища
+func (ms *MemoryStorage) PutManifest(fileID string, manifest json.RawMessage) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	stored := append(json.RawMessage(nil), manifest...)
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpManifest, FileID: fileID, Manifest: stored}); err != nil {
+			return err
+		}
+	}
+
+	ms.manifests[fileID] = stored
+	return nil
+}
+
+// DeleteManifest удаляет манифест файла fileID; отсутствие манифеста не ошибка
+func (ms *MemoryStorage) DeleteManifest(fileID string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if _, exists := ms.manifests[fileID]; !exists {
+		return nil
+	}
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpManifestDelete, FileID: fileID}); err != nil {
+			return err
+		}
+	}
+
+	delete(ms.manifests, fileID)
+	return nil
+}
+
+// ListManifests возвращает все манифесты, упорядоченные по идентификатору файла
+func (ms *MemoryStorage) ListManifests() []json.RawMessage {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	ids := make([]string, 0, len(ms.manifests))
+	for id := range ms.manifests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	manifests := make([]json.RawMessage, 0, len(ids))
+	for _, id := range ids {
+		manifests = append(manifests, ms.manifests[id])
+	}
+	return manifests
+}
+
+// PutManifestContext сохраняет манифест файла на сервере хранения
+func (c *StorageClient) PutManifestContext(ctx context.Context, manifest *FileManifest) error {
+	if manifest.Metadata == nil {
+		return fmt.Errorf("в манифесте нет метаданных файла")
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать манифест: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/api/v1/manifests/%s", c.BaseURL, manifest.Metadata.ID), bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// DeleteManifestContext удаляет манифест файла с сервера хранения
+func (c *StorageClient) DeleteManifestContext(ctx context.Context, fileID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/api/v1/manifests/%s", c.BaseURL, fileID), nil)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// ListManifestsContext получает все манифесты файлов с сервера хранения
+func (c *StorageClient) ListManifestsContext(ctx context.Context) ([]*FileManifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/manifests", c.BaseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var result struct {
+		Manifests []*FileManifest `json:"manifests"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось декодировать ответ: %w", err)
+	}
+	return result.Manifests, nil
+}
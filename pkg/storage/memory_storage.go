@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"sync"
+	"time"
 
 	"TestCase/pkg/chunking"
 )
@@ -11,20 +14,61 @@ import (
 type MemoryStorage struct {
 	chunks map[string]*chunking.FileChunk
 	mutex  sync.RWMutex
+
+	// Манифесты файлов в JSON по идентификатору файла; в объеме данных не учитываются
+	manifests map[string]json.RawMessage
+
+	// persistence задан, если хранилище сохраняет изменения на диск
+	persistence *persistence
+
+	// capacity ограничивает объем данных кусков в байтах; 0 - без ограничения
+	capacity int64
+
+	metrics *storageMetrics
 }
 
 // NewMemoryStorage создает новое хранилище в памяти
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		chunks: make(map[string]*chunking.FileChunk),
+		chunks:    make(map[string]*chunking.FileChunk),
+		manifests: make(map[string]json.RawMessage),
+		metrics:   newStorageMetrics(),
 	}
 }
 
+// SetCapacity ограничивает объем данных кусков в байтах. Новый кусок, который не помещается
+// в оставшийся объем, отклоняется с ErrQuotaExceeded; 0 снимает ограничение
+func (ms *MemoryStorage) SetCapacity(capacity int64) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.capacity = capacity
+}
+
 // StoreChunk сохраняет кусок файла в памяти
 func (ms *MemoryStorage) StoreChunk(chunk *chunking.FileChunk) error {
+	return ms.StoreChunkRefs(chunk, 0)
+}
+
+// StoreChunkRefs сохраняет кусок вместе с refs ссылками файлов, например при переносе
+// куска с другого сервера. Ссылки добавляются к уже имеющимся у куска.
+// При refs == 0 новый кусок получает одну ссылку, а у существующего число ссылок не меняется
+func (ms *MemoryStorage) StoreChunkRefs(chunk *chunking.FileChunk, refs int) (err error) {
+	started := time.Now()
+	defer func() { ms.metrics.operations[opStore].observe(started, int64(len(chunk.Data)), err) }()
+
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
 
+	if ms.capacity > 0 {
+		used := ms.usedLocked()
+		if existing, exists := ms.chunks[chunk.ID]; exists {
+			used -= int64(len(existing.Data))
+		}
+		if used+int64(len(chunk.Data)) > ms.capacity {
+			return fmt.Errorf("%w: занято %d из %d байт", ErrQuotaExceeded, used, ms.capacity)
+		}
+	}
+
 	// Создаем копию куска для хранения
 	chunkCopy := &chunking.FileChunk{
 		ID:       chunk.ID,
@@ -33,23 +77,52 @@ func (ms *MemoryStorage) StoreChunk(chunk *chunking.FileChunk) error {
 		Data:     make([]byte, len(chunk.Data)),
 		Checksum: chunk.Checksum,
 		Size:     chunk.Size,
+
+		Algorithm: chunk.Algorithm,
+		RefCount:  refs,
+	}
+	if existing, exists := ms.chunks[chunk.ID]; exists {
+		chunkCopy.RefCount += refCount(existing)
+	} else if refs == 0 {
+		chunkCopy.RefCount = 1
 	}
 
 	// Копируем данные
 	copy(chunkCopy.Data, chunk.Data)
 
+	// Сначала фиксируем операцию в журнале, затем применяем в памяти
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpStore, Chunk: chunkCopy}); err != nil {
+			return err
+		}
+	}
+
 	ms.chunks[chunk.ID] = chunkCopy
 	return nil
 }
 
 // GetChunk получает кусок файла из памяти
-func (ms *MemoryStorage) GetChunk(chunkID string) (*chunking.FileChunk, error) {
+func (ms *MemoryStorage) GetChunk(chunkID string) (result *chunking.FileChunk, err error) {
+	started := time.Now()
+	defer func() {
+		var bytes int64
+		if result != nil {
+			bytes = int64(len(result.Data))
+		}
+		ms.metrics.operations[opGet].observe(started, bytes, err)
+	}()
+
 	ms.mutex.RLock()
 	defer ms.mutex.RUnlock()
 
 	chunk, exists := ms.chunks[chunkID]
 	if !exists {
-		return nil, fmt.Errorf("кусок не найден")
+		return nil, ErrNotFound
+	}
+
+	// Проверяем целостность данных перед отдачей
+	if err := chunking.ValidateChunk(chunk); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChunkCorrupted, err)
 	}
 
 	// Создаем копию для возврата
@@ -60,6 +133,9 @@ func (ms *MemoryStorage) GetChunk(chunkID string) (*chunking.FileChunk, error) {
 		Data:     make([]byte, len(chunk.Data)),
 		Checksum: chunk.Checksum,
 		Size:     chunk.Size,
+
+		Algorithm: chunk.Algorithm,
+		RefCount:  refCount(chunk),
 	}
 
 	copy(chunkCopy.Data, chunk.Data)
@@ -67,19 +143,106 @@ func (ms *MemoryStorage) GetChunk(chunkID string) (*chunking.FileChunk, error) {
 	return chunkCopy, nil
 }
 
-// DeleteChunk удаляет кусок файла из памяти
+// DeleteChunk снимает с куска одну ссылку файла; данные освобождаются,
+// когда удален последний ссылающийся на кусок файл
 func (ms *MemoryStorage) DeleteChunk(chunkID string) error {
+	_, err := ms.DecrementRef(chunkID)
+	return err
+}
+
+// PurgeChunk удаляет кусок вместе со всеми ссылками, например после переноса на другой сервер
+func (ms *MemoryStorage) PurgeChunk(chunkID string) error {
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
 
 	if _, exists := ms.chunks[chunkID]; !exists {
-		return fmt.Errorf("кусок не найден")
+		return ErrNotFound
+	}
+
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpDelete, ChunkID: chunkID}); err != nil {
+			return err
+		}
 	}
 
 	delete(ms.chunks, chunkID)
+	ms.metrics.evictions.Add(1)
 	return nil
 }
 
+// IncrementRef добавляет ссылку еще одного файла на кусок и возвращает новое число ссылок
+func (ms *MemoryStorage) IncrementRef(chunkID string) (int, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	chunk, exists := ms.chunks[chunkID]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	count := refCount(chunk) + 1
+	if err := ms.setRefCount(chunk, count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DecrementRef снимает ссылку файла с куска и возвращает оставшееся число ссылок.
+// Кусок без ссылок удаляется
+func (ms *MemoryStorage) DecrementRef(chunkID string) (count int, err error) {
+	started := time.Now()
+	var freed int64 // байты, освобожденные вместе с последней ссылкой
+	defer func() { ms.metrics.operations[opDelete].observe(started, freed, err) }()
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	chunk, exists := ms.chunks[chunkID]
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	count = refCount(chunk) - 1
+	if count > 0 {
+		if err := ms.setRefCount(chunk, count); err != nil {
+			return 0, err
+		}
+		return count, nil
+	}
+
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpDelete, ChunkID: chunkID}); err != nil {
+			return 0, err
+		}
+	}
+
+	delete(ms.chunks, chunkID)
+	ms.metrics.evictions.Add(1)
+	freed = int64(len(chunk.Data))
+	return 0, nil
+}
+
+// setRefCount записывает в журнал и применяет новое число ссылок; вызывается под блокировкой
+func (ms *MemoryStorage) setRefCount(chunk *chunking.FileChunk, count int) error {
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpRefs, ChunkID: chunk.ID, RefCount: count}); err != nil {
+			return err
+		}
+	}
+
+	chunk.RefCount = count
+	return nil
+}
+
+// refCount возвращает число ссылок на кусок. Куски, сохраненные до появления
+// счетчика ссылок, считаются принадлежащими одному файлу
+func refCount(chunk *chunking.FileChunk) int {
+	if chunk.RefCount < 1 {
+		return 1
+	}
+	return chunk.RefCount
+}
+
 // ListChunks возвращает список всех кусков в памяти
 func (ms *MemoryStorage) ListChunks() ([]string, error) {
 	ms.mutex.RLock()
@@ -107,6 +270,9 @@ func (ms *MemoryStorage) GetStorageInfo() (map[string]interface{}, error) {
 		"chunk_count":  len(ms.chunks),
 		"total_size":   totalSize,
 		"storage_type": "memory",
+		"persistent":   ms.persistence != nil,
+		"operations":   ms.OperationStats(),
+		"evictions":    ms.Evictions(),
 	}
 
 	return info, nil
@@ -117,12 +283,16 @@ func (ms *MemoryStorage) GetMemoryUsage() (int64, error) {
 	ms.mutex.RLock()
 	defer ms.mutex.RUnlock()
 
+	return ms.usedLocked(), nil
+}
+
+// usedLocked возвращает объем данных всех кусков. Вызывается под блокировкой
+func (ms *MemoryStorage) usedLocked() int64 {
 	var totalSize int64
 	for _, chunk := range ms.chunks {
 		totalSize += int64(len(chunk.Data))
 	}
-
-	return totalSize, nil
+	return totalSize
 }
 
 // ClearAll очищает все данные из памяти
@@ -130,7 +300,15 @@ func (ms *MemoryStorage) ClearAll() {
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
 
+	if ms.persistence != nil {
+		if err := ms.persistence.append(&walRecord{Op: walOpClear}); err != nil {
+			log.Printf("Не удалось записать очистку в журнал: %v", err)
+		}
+	}
+
+	ms.metrics.evictions.Add(uint64(len(ms.chunks)))
 	ms.chunks = make(map[string]*chunking.FileChunk)
+	ms.manifests = make(map[string]json.RawMessage)
 }
 
 // CompactStorage очищает память от неиспользуемых кусков
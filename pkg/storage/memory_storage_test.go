@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChunkDetectsCorruption(t *testing.T) {
+	ms := NewMemoryStorage()
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("payload"))))
+
+	// Портим данные непосредственно в хранилище
+	ms.chunks["a"].Data[0] ^= 0xff
+
+	_, err := ms.GetChunk("a")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrChunkCorrupted))
+}
+
+func TestInventoryVerifiesChecksums(t *testing.T) {
+	ms := NewMemoryStorage()
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("payload"))))
+	require.NoError(t, ms.StoreChunk(newTestChunk("b", []byte("other"))))
+	ms.chunks["b"].Data[0] ^= 0xff
+
+	stats := ms.Inventory(false)
+	require.Len(t, stats, 2)
+	assert.Equal(t, "a", stats[0].ID)
+	assert.Equal(t, int64(7), stats[0].Size)
+	assert.Equal(t, 1, stats[0].RefCount)
+	assert.False(t, stats[1].Corrupted, "без verify данные не проверяются")
+
+	stats = ms.Inventory(true)
+	assert.False(t, stats[0].Corrupted)
+	assert.True(t, stats[1].Corrupted)
+}
+
+func TestDeleteChunkKeepsSharedChunk(t *testing.T) {
+	ms := NewMemoryStorage()
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("shared"))))
+
+	count, err := ms.IncrementRef("a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Повторная запись того же куска не добавляет ссылку
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("shared"))))
+
+	require.NoError(t, ms.DeleteChunk("a"))
+	chunk, err := ms.GetChunk("a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, chunk.RefCount)
+
+	count, err = ms.DecrementRef("a")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	_, err = ms.GetChunk("a")
+	assert.Error(t, err)
+
+	_, err = ms.IncrementRef("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStoreChunkRespectsCapacity(t *testing.T) {
+	ms := NewMemoryStorage()
+	ms.SetCapacity(10)
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("123456"))))
+
+	// Перезапись куска учитывает освобождаемое место
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("1234567"))))
+
+	err := ms.StoreChunk(newTestChunk("b", []byte("1234")))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = ms.GetChunk("b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, ms.StoreChunk(newTestChunk("c", []byte("123"))))
+}
+
+func TestStoreChunkRefsTransfersReferences(t *testing.T) {
+	ms := NewMemoryStorage()
+	require.NoError(t, ms.StoreChunkRefs(newTestChunk("a", []byte("moved")), 3))
+	chunk, err := ms.GetChunk("a")
+	require.NoError(t, err)
+	assert.Equal(t, 3, chunk.RefCount)
+
+	// Ссылки переносимого куска добавляются к уже имеющимся
+	require.NoError(t, ms.StoreChunkRefs(newTestChunk("a", []byte("moved")), 2))
+	chunk, err = ms.GetChunk("a")
+	require.NoError(t, err)
+	assert.Equal(t, 5, chunk.RefCount)
+
+	require.NoError(t, ms.PurgeChunk("a"))
+	_, err = ms.GetChunk("a")
+	assert.Error(t, err)
+	assert.Error(t, ms.PurgeChunk("a"))
+}
+
+func TestMemoryStorageMetrics(t *testing.T) {
+	ms := NewMemoryStorage()
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("hello"))))
+	_, err := ms.GetChunk("a")
+	require.NoError(t, err)
+	_, err = ms.GetChunk("missing")
+	require.Error(t, err)
+	require.NoError(t, ms.DeleteChunk("a"))
+
+	stats := ms.OperationStats()
+	assert.Equal(t, OperationStats{Count: 1, Bytes: 5}, withoutLatency(stats[opStore]))
+	assert.Equal(t, OperationStats{Count: 2, Errors: 1, Bytes: 5}, withoutLatency(stats[opGet]))
+	assert.Equal(t, OperationStats{Count: 1, Bytes: 5}, withoutLatency(stats[opDelete]))
+	assert.Equal(t, uint64(1), ms.Evictions())
+
+	var output strings.Builder
+	require.NoError(t, ms.WriteMetrics(&output))
+	assert.Contains(t, output.String(), `storage_operations_total{operation="get"} 2`)
+	assert.Contains(t, output.String(), `storage_operation_duration_seconds_bucket{operation="get",le="+Inf"} 2`)
+	assert.Contains(t, output.String(), "storage_evictions_total 1")
+}
+
+// withoutLatency обнуляет длительность, которая отличается от запуска к запуску
+func withoutLatency(stats OperationStats) OperationStats {
+	stats.AvgLatencyMs = 0
+	return stats
+}
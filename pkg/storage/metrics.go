@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets - верхние границы корзин гистограммы задержек операций в секундах
+var latencyBuckets = [...]float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// Операции хранилища, по которым собирается статистика
+const (
+	opStore  = "store"
+	opGet    = "get"
+	opDelete = "delete"
+)
+
+// operationMetrics - счетчики одной операции хранилища. Обновляются без блокировок,
+// поэтому не замедляют операции, выполняемые под блокировкой хранилища
+type operationMetrics struct {
+	count  atomic.Uint64
+	errors atomic.Uint64
+	bytes  atomic.Uint64 // записанные, прочитанные или освобожденные байты
+
+	buckets    [len(latencyBuckets)]atomic.Uint64 // операции, попавшие в корзину (не накопительно)
+	durationNs atomic.Uint64
+}
+
+// observe учитывает завершенную операцию
+func (m *operationMetrics) observe(started time.Time, bytes int64, err error) {
+	elapsed := time.Since(started)
+
+	m.count.Add(1)
+	if err != nil {
+		m.errors.Add(1)
+	} else if bytes > 0 {
+		m.bytes.Add(uint64(bytes))
+	}
+	m.durationNs.Add(uint64(elapsed.Nanoseconds()))
+
+	seconds := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			m.buckets[i].Add(1)
+			break
+		}
+	}
+}
+
+// OperationStats - статистика операции хранилища
+type OperationStats struct {
+	Count  uint64 `json:"count"`
+	Errors uint64 `json:"errors"`
+	Bytes  uint64 `json:"bytes"`
+
+	// AvgLatencyMs - средняя длительность операции в миллисекундах
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// stats возвращает текущие значения счетчиков
+func (m *operationMetrics) stats() OperationStats {
+	stats := OperationStats{
+		Count:  m.count.Load(),
+		Errors: m.errors.Load(),
+		Bytes:  m.bytes.Load(),
+	}
+	if stats.Count > 0 {
+		stats.AvgLatencyMs = float64(m.durationNs.Load()) / float64(stats.Count) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// storageMetrics - статистика операций MemoryStorage
+type storageMetrics struct {
+	operations map[string]*operationMetrics
+
+	// evictions - куски, данные которых освобождены из памяти: снята последняя ссылка,
+	// кусок перенесен на другой сервер или хранилище очищено
+	evictions atomic.Uint64
+}
+
+// newStorageMetrics создает нулевые счетчики для всех операций
+func newStorageMetrics() *storageMetrics {
+	return &storageMetrics{
+		operations: map[string]*operationMetrics{
+			opStore:  {},
+			opGet:    {},
+			opDelete: {},
+		},
+	}
+}
+
+// OperationStats возвращает статистику операций хранилища по их названиям
+func (ms *MemoryStorage) OperationStats() map[string]OperationStats {
+	result := make(map[string]OperationStats, len(ms.metrics.operations))
+	for name, operation := range ms.metrics.operations {
+		result[name] = operation.stats()
+	}
+	return result
+}
+
+// Evictions возвращает число кусков, освобожденных из памяти
+func (ms *MemoryStorage) Evictions() uint64 {
+	return ms.metrics.evictions.Load()
+}
+
+// WriteMetrics записывает статистику хранилища в текстовом формате Prometheus
+func (ms *MemoryStorage) WriteMetrics(w io.Writer) error {
+	var chunkCount int
+	var totalSize int64
+	ms.mutex.RLock()
+	chunkCount = len(ms.chunks)
+	for _, chunk := range ms.chunks {
+		totalSize += int64(len(chunk.Data))
+	}
+	ms.mutex.RUnlock()
+
+	writer := &metricsWriter{w: w}
+	writer.header("storage_chunks", "gauge", "Число кусков в хранилище")
+	writer.printf("storage_chunks %d\n", chunkCount)
+	writer.header("storage_bytes", "gauge", "Объем данных кусков в байтах")
+	writer.printf("storage_bytes %d\n", totalSize)
+	writer.header("storage_evictions_total", "counter", "Куски, освобожденные из памяти")
+	writer.printf("storage_evictions_total %d\n", ms.metrics.evictions.Load())
+
+	names := []string{opStore, opGet, opDelete}
+	writer.header("storage_operations_total", "counter", "Число операций хранилища")
+	for _, name := range names {
+		writer.printf("storage_operations_total{operation=%q} %d\n", name, ms.metrics.operations[name].count.Load())
+	}
+	writer.header("storage_operation_errors_total", "counter", "Число завершившихся ошибкой операций хранилища")
+	for _, name := range names {
+		writer.printf("storage_operation_errors_total{operation=%q} %d\n", name, ms.metrics.operations[name].errors.Load())
+	}
+	writer.header("storage_operation_bytes_total", "counter", "Байты, записанные, прочитанные или освобожденные операциями")
+	for _, name := range names {
+		writer.printf("storage_operation_bytes_total{operation=%q} %d\n", name, ms.metrics.operations[name].bytes.Load())
+	}
+
+	writer.header("storage_operation_duration_seconds", "histogram", "Длительность операций хранилища")
+	for _, name := range names {
+		operation := ms.metrics.operations[name]
+		count := operation.count.Load()
+
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += operation.buckets[i].Load()
+			writer.printf("storage_operation_duration_seconds_bucket{operation=%q,le=\"%g\"} %d\n", name, bound, cumulative)
+		}
+		writer.printf("storage_operation_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", name, count)
+		writer.printf("storage_operation_duration_seconds_sum{operation=%q} %g\n", name, float64(operation.durationNs.Load())/float64(time.Second))
+		writer.printf("storage_operation_duration_seconds_count{operation=%q} %d\n", name, count)
+	}
+
+	return writer.err
+}
+
+// metricsWriter запоминает первую ошибку записи, чтобы не проверять каждую строку
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// header записывает описание метрики
+func (m *metricsWriter) header(name, kind, help string) {
+	m.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// printf записывает строку, если предыдущие записи прошли успешно
+func (m *metricsWriter) printf(format string, args ...interface{}) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Logger принимает сообщения клиента о запросах и их повторах. Подходит *log.Logger
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// ClientOptions - настройки HTTP клиента, которым клиенты обращаются к серверам
+type ClientOptions struct {
+	Timeout   time.Duration     // таймаут всего запроса вместе с чтением ответа
+	Transport http.RoundTripper // транспорт запросов; nil - стандартный
+	TLSConfig *tls.Config       // настройки TLS для стандартного транспорта
+	Retry     RetryPolicy       // повтор запросов при сетевых ошибках и перегрузке сервера
+	Logger    Logger            // журнал запросов; nil - запросы не записываются
+}
+
+// ClientOption изменяет настройки HTTP клиента
+type ClientOption func(*ClientOptions)
+
+// WithTimeout задает таймаут запроса вместо принятого в клиенте по умолчанию
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *ClientOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// WithTransport задает транспорт запросов, например с прокси или инструментированием.
+// Транспорт заменяет выбранный клиентом, в том числе транспорт unix сокета и h2c
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *ClientOptions) {
+		o.Transport = transport
+	}
+}
+
+// WithTLSConfig задает настройки TLS: корневые сертификаты, клиентский сертификат и т.п.
+// Применяется к стандартному транспорту и к транспорту *http.Transport из WithTransport
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(o *ClientOptions) {
+		o.TLSConfig = config
+	}
+}
+
+// WithRetryPolicy включает повтор запросов по политике policy
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *ClientOptions) {
+		o.Retry = policy
+	}
+}
+
+// WithLogger задает журнал, в который записываются запросы клиента и их повторы
+func WithLogger(logger Logger) ClientOption {
+	return func(o *ClientOptions) {
+		o.Logger = logger
+	}
+}
+
+// NewClientOptions возвращает настройки с таймаутом timeout и транспортом transport,
+// измененные опциями opts
+func NewClientOptions(timeout time.Duration, transport http.RoundTripper, opts ...ClientOption) ClientOptions {
+	options := ClientOptions{Timeout: timeout, Transport: transport}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// HTTPClient создает HTTP клиент по настройкам
+func (o ClientOptions) HTTPClient() *http.Client {
+	transport := o.Transport
+	if o.TLSConfig != nil {
+		base, ok := transport.(*http.Transport)
+		if transport == nil {
+			base, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if ok {
+			configured := base.Clone()
+			configured.TLSClientConfig = o.TLSConfig
+			transport = configured
+		}
+	}
+	return &http.Client{Timeout: o.Timeout, Transport: transport}
+}
@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"TestCase/pkg/chunking"
+)
+
+const (
+	walFileName      = "chunks.wal"
+	snapshotFileName = "chunks.snapshot"
+
+	// Заголовок записи: длина полезной нагрузки и контрольная сумма CRC32
+	recordHeaderSize = 8
+)
+
+// Типы операций в журнале
+const (
+	walOpStore  = "store"
+	walOpDelete = "delete"
+	walOpClear  = "clear"
+	walOpRefs   = "refs" // новое число ссылок на кусок
+
+	walOpManifest       = "manifest"        // манифест файла сохранен или заменен
+	walOpManifestDelete = "manifest_delete" // манифест файла удален
+)
+
+// PersistenceOptions задает параметры сохранения хранилища на диск
+type PersistenceOptions struct {
+	Dir              string        // директория для журнала и снимков
+	SnapshotInterval time.Duration // период создания снимков (0 - только при закрытии)
+	SyncWrites       bool          // вызывать fsync после каждой записи в журнал
+}
+
+// walRecord представляет одну запись журнала или снимка
+type walRecord struct {
+	Op       string              `json:"op"`
+	ChunkID  string              `json:"chunk_id,omitempty"`
+	Chunk    *chunking.FileChunk `json:"chunk,omitempty"`
+	RefCount int                 `json:"ref_count,omitempty"`
+	FileID   string              `json:"file_id,omitempty"`
+	Manifest json.RawMessage     `json:"manifest,omitempty"`
+}
+
+// persistence управляет журналом упреждающей записи и снимками хранилища
+type persistence struct {
+	opts  PersistenceOptions
+	mutex sync.Mutex
+	wal   *os.File
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPersistentMemoryStorage создает хранилище в памяти с журналом и периодическими снимками.
+// При создании состояние восстанавливается из последнего снимка и журнала
+func NewPersistentMemoryStorage(opts PersistenceOptions) (*MemoryStorage, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("не задана директория для хранения журнала")
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию хранилища: %w", err)
+	}
+
+	ms := NewMemoryStorage()
+
+	// Восстанавливаем состояние: сначала снимок, затем журнал поверх него
+	if err := replayFile(filepath.Join(opts.Dir, snapshotFileName), ms, false); err != nil {
+		return nil, fmt.Errorf("не удалось загрузить снимок: %w", err)
+	}
+	if err := replayFile(filepath.Join(opts.Dir, walFileName), ms, true); err != nil {
+		return nil, fmt.Errorf("не удалось воспроизвести журнал: %w", err)
+	}
+
+	wal, err := os.OpenFile(filepath.Join(opts.Dir, walFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал: %w", err)
+	}
+
+	ms.persistence = &persistence{
+		opts: opts,
+		wal:  wal,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go ms.snapshotLoop()
+
+	log.Printf("Хранилище восстановлено из %s: %d кусков", opts.Dir, len(ms.chunks))
+	return ms, nil
+}
+
+// snapshotLoop периодически сохраняет снимок хранилища
+func (ms *MemoryStorage) snapshotLoop() {
+	p := ms.persistence
+	defer close(p.done)
+
+	if p.opts.SnapshotInterval <= 0 {
+		<-p.stop
+		return
+	}
+
+	ticker := time.NewTicker(p.opts.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ms.Snapshot(); err != nil {
+				log.Printf("Не удалось создать снимок хранилища: %v", err)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Snapshot записывает текущее состояние на диск и очищает журнал
+func (ms *MemoryStorage) Snapshot() error {
+	if ms.persistence == nil {
+		return nil
+	}
+
+	// Блокировка на чтение не дает писателям изменять данные и журнал во время снимка
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	return ms.persistence.writeSnapshot(ms.chunks, ms.manifests)
+}
+
+// Close сохраняет финальный снимок и закрывает журнал
+func (ms *MemoryStorage) Close() error {
+	p := ms.persistence
+	if p == nil {
+		return nil
+	}
+
+	close(p.stop)
+	<-p.done
+
+	if err := ms.Snapshot(); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.wal.Close()
+}
+
+// append добавляет запись в журнал
+func (p *persistence) append(record *walRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать запись журнала: %w", err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := writeRecord(p.wal, payload); err != nil {
+		return fmt.Errorf("не удалось записать в журнал: %w", err)
+	}
+
+	if p.opts.SyncWrites {
+		if err := p.wal.Sync(); err != nil {
+			return fmt.Errorf("не удалось синхронизировать журнал: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeSnapshot атомарно заменяет снимок и обрезает журнал
+func (p *persistence) writeSnapshot(chunks map[string]*chunking.FileChunk, manifests map[string]json.RawMessage) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	tmpPath := filepath.Join(p.opts.Dir, snapshotFileName+".tmp")
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл снимка: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, chunk := range chunks {
+		payload, err := json.Marshal(&walRecord{Op: walOpStore, Chunk: chunk})
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("не удалось сериализовать кусок %s: %w", chunk.ID, err)
+		}
+		if err := writeRecord(writer, payload); err != nil {
+			file.Close()
+			return fmt.Errorf("не удалось записать снимок: %w", err)
+		}
+	}
+	for fileID, manifest := range manifests {
+		payload, err := json.Marshal(&walRecord{Op: walOpManifest, FileID: fileID, Manifest: manifest})
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("не удалось сериализовать манифест файла %s: %w", fileID, err)
+		}
+		if err := writeRecord(writer, payload); err != nil {
+			file.Close()
+			return fmt.Errorf("не удалось записать снимок: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("не удалось записать снимок: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("не удалось синхронизировать снимок: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть снимок: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(p.opts.Dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("не удалось заменить снимок: %w", err)
+	}
+
+	// Все записи журнала уже отражены в снимке
+	if err := p.wal.Truncate(0); err != nil {
+		return fmt.Errorf("не удалось очистить журнал: %w", err)
+	}
+	if _, err := p.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("не удалось очистить журнал: %w", err)
+	}
+
+	return nil
+}
+
+// writeRecord записывает запись с заголовком длины и контрольной суммы
+func writeRecord(w io.Writer, payload []byte) error {
+	header := make([]byte, recordHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+
+	if _, err := w.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// replayFile применяет записи файла к кускам и манифестам хранилища.
+// Для журнала оборванная последняя запись отбрасывается вместе с хвостом файла
+func replayFile(path string, ms *MemoryStorage, truncateTail bool) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return handleTornRecord(file, path, offset, truncateTail)
+		}
+
+		size := binary.BigEndian.Uint32(header[0:4])
+		sum := binary.BigEndian.Uint32(header[4:8])
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil || crc32.ChecksumIEEE(payload) != sum {
+			return handleTornRecord(file, path, offset, truncateTail)
+		}
+
+		var record walRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return handleTornRecord(file, path, offset, truncateTail)
+		}
+
+		applyRecord(ms, &record)
+		offset += int64(recordHeaderSize) + int64(size)
+	}
+}
+
+// handleTornRecord обрабатывает поврежденную запись в конце файла
+func handleTornRecord(file *os.File, path string, offset int64, truncateTail bool) error {
+	if !truncateTail {
+		return fmt.Errorf("файл %s поврежден по смещению %d", path, offset)
+	}
+
+	log.Printf("Журнал %s оборван по смещению %d, хвост отброшен", path, offset)
+	return file.Truncate(offset)
+}
+
+// applyRecord применяет одну операцию к кускам и манифестам хранилища
+func applyRecord(ms *MemoryStorage, record *walRecord) {
+	chunks := ms.chunks
+	switch record.Op {
+	case walOpStore:
+		if record.Chunk != nil {
+			chunks[record.Chunk.ID] = record.Chunk
+		}
+	case walOpDelete:
+		delete(chunks, record.ChunkID)
+	case walOpRefs:
+		if chunk, exists := chunks[record.ChunkID]; exists {
+			chunk.RefCount = record.RefCount
+		}
+	case walOpManifest:
+		ms.manifests[record.FileID] = record.Manifest
+	case walOpManifestDelete:
+		delete(ms.manifests, record.FileID)
+	case walOpClear:
+		for id := range chunks {
+			delete(chunks, id)
+		}
+		for id := range ms.manifests {
+			delete(ms.manifests, id)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+)
+
+func newTestChunk(id string, data []byte) *chunking.FileChunk {
+	return &chunking.FileChunk{
+		ID:       id,
+		FileID:   "test-file",
+		Data:     data,
+		Size:     int64(len(data)),
+		Checksum: fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+}
+
+func TestPersistentStorageRecoversFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	opts := PersistenceOptions{Dir: dir}
+
+	ms, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("first"))))
+	require.NoError(t, ms.StoreChunk(newTestChunk("b", []byte("second"))))
+	require.NoError(t, ms.DeleteChunk("a"))
+
+	// Имитируем аварийную остановку: журнал не сворачивается в снимок
+	require.NoError(t, ms.persistence.wal.Close())
+
+	restored, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	_, err = restored.GetChunk("a")
+	assert.Error(t, err)
+
+	chunk, err := restored.GetChunk("b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), chunk.Data)
+}
+
+func TestPersistentStorageRecoversRefCounts(t *testing.T) {
+	dir := t.TempDir()
+	opts := PersistenceOptions{Dir: dir}
+
+	ms, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("shared"))))
+	require.NoError(t, ms.Snapshot())
+
+	// Ссылки добавлены после снимка и есть только в журнале
+	_, err = ms.IncrementRef("a")
+	require.NoError(t, err)
+	_, err = ms.IncrementRef("a")
+	require.NoError(t, err)
+	require.NoError(t, ms.DeleteChunk("a"))
+	require.NoError(t, ms.persistence.wal.Close())
+
+	restored, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	chunk, err := restored.GetChunk("a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, chunk.RefCount)
+}
+
+func TestPersistentStorageSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := PersistenceOptions{Dir: dir}
+
+	ms, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("data"))))
+	require.NoError(t, ms.Close())
+
+	// После закрытия все данные должны быть в снимке, а журнал пуст
+	walInfo, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Zero(t, walInfo.Size())
+
+	restored, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	chunk, err := restored.GetChunk("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), chunk.Data)
+}
+
+func TestPersistentStorageDropsTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	opts := PersistenceOptions{Dir: dir}
+
+	ms, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	require.NoError(t, ms.StoreChunk(newTestChunk("a", []byte("data"))))
+	require.NoError(t, ms.persistence.wal.Close())
+
+	// Дописываем оборванную запись в конец журнала
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = wal.Write([]byte{0, 0, 1, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	restored, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	_, err = restored.GetChunk("a")
+	assert.NoError(t, err)
+}
+
+func TestPersistentStorageRecoversManifests(t *testing.T) {
+	dir := t.TempDir()
+	opts := PersistenceOptions{Dir: dir}
+
+	ms, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	require.NoError(t, ms.PutManifest("a", []byte(`{"metadata":{"id":"a"}}`)))
+	require.NoError(t, ms.Snapshot())
+	require.NoError(t, ms.PutManifest("b", []byte(`{"metadata":{"id":"b"}}`)))
+	require.NoError(t, ms.DeleteManifest("a"))
+
+	// Снимок содержит манифест a, а журнал - его удаление и манифест b
+	require.NoError(t, ms.persistence.wal.Close())
+
+	restored, err := NewPersistentMemoryStorage(opts)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	manifests := restored.ListManifests()
+	require.Len(t, manifests, 1)
+	assert.JSONEq(t, `{"metadata":{"id":"b"}}`, string(manifests[0]))
+}
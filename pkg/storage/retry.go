@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy - политика повтора запросов. Повторяются только идемпотентные запросы
+// (GET, HEAD, PUT, DELETE, OPTIONS), тело которых можно отправить заново, и только при
+// сетевой ошибке или ответах 429, 502, 503 и 504. Нулевое значение отключает повторы
+type RetryPolicy struct {
+	MaxAttempts int           // число попыток вместе с первой; 0 и 1 - без повторов
+	Delay       time.Duration // задержка перед первым повтором, далее удваивается
+	MaxDelay    time.Duration // наибольшая задержка между попытками; 0 - без ограничения
+}
+
+// Do отправляет запрос клиентом httpClient, повторяя его по политике. prepare, если задан,
+// вызывается перед каждой попыткой, например чтобы заново подписать запрос. Каждая
+// попытка записывается в logger, если он задан
+func (p RetryPolicy) Do(httpClient *http.Client, req *http.Request, logger Logger, prepare func(*http.Request) error) (*http.Response, error) {
+	delay := p.Delay
+	for attempt := 1; ; attempt++ {
+		if prepare != nil {
+			if err := prepare(req); err != nil {
+				return nil, err
+			}
+		}
+
+		started := time.Now()
+		resp, err := httpClient.Do(req)
+		if logger != nil {
+			logRequest(logger, req, resp, err, attempt, time.Since(started))
+		}
+		if attempt >= p.MaxAttempts || !p.retryable(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		if delay *= 2; p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("не удалось повторить запрос: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retryable сообщает, можно ли повторить запрос после ответа resp или ошибки err
+func (p RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+	default:
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// logRequest записывает в журнал попытку запроса и ее результат. Параметры запроса не
+// записываются: в них передаются токены
+func logRequest(logger Logger, req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) {
+	target := url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path}
+	if err != nil {
+		logger.Printf("%s %s: попытка %d, ошибка за %v: %v", req.Method, target.String(), attempt, elapsed, err)
+		return
+	}
+	logger.Printf("%s %s: попытка %d, ответ %d за %v", req.Method, target.String(), attempt, resp.StatusCode, elapsed)
+}
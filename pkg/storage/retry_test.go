@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger запоминает сообщения журнала
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		// Тело отправляется заново при каждой попытке
+		assert.Equal(t, "data", string(body))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond}
+	logger := &recordingLogger{}
+
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/x?token=secret", bytes.NewReader([]byte("data")))
+	require.NoError(t, err)
+	resp, err := policy.Do(server.Client(), req, logger, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, attempts)
+	require.Len(t, logger.lines, 3)
+	assert.NotContains(t, logger.lines[0], "secret")
+
+	// POST не идемпотентен и не повторяется
+	attempts = 0
+	req, err = http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte("data")))
+	require.NoError(t, err)
+	resp, err = policy.Do(server.Client(), req, nil, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestStorageClientRetrySignsEachAttempt(t *testing.T) {
+	verifier := NewSignatureVerifier("secret", time.Minute)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Повтор с прежней подписью был бы отклонен как повторный запрос
+		if err := verifier.Verify(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewStorageClient(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, Delay: time.Millisecond}), WithTimeout(time.Second))
+	client.Secret = "secret"
+	assert.Equal(t, time.Second, client.HTTPClient.Timeout)
+
+	require.NoError(t, client.DeleteChunkContext(context.Background(), "a"))
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader - заголовок с подписью внутреннего запроса API сервера к серверу
+// хранения: "t=<время unix>,n=<одноразовое значение>,s=<HMAC-SHA256>"
+const SignatureHeader = "X-Internal-Signature"
+
+// ContentDigestHeader - заголовок с SHA-256 тела подписанного запроса в hex. Значение
+// входит в подпись, а сервер сверяет его с полученным телом
+const ContentDigestHeader = "X-Content-SHA256"
+
+// ReadTokenPurpose - назначение токена, разрешающего клиенту прочитать кусок напрямую с
+// сервера хранения, когда внутренние запросы подписываются
+const ReadTokenPurpose = "chunk-read"
+
+// ReadClaims - данные токена чтения куска. Токен выдает API сервер в манифесте файла
+type ReadClaims struct {
+	ChunkID string `json:"chunk_id"`
+}
+
+var (
+	// ErrSignatureMissing - запрос не подписан
+	ErrSignatureMissing = errors.New("внутренний запрос не подписан")
+
+	// ErrSignatureInvalid - подпись повреждена или сделана другим ключом
+	ErrSignatureInvalid = errors.New("неверная подпись внутреннего запроса")
+
+	// ErrSignatureExpired - время подписи выходит за допустимое расхождение часов
+	ErrSignatureExpired = errors.New("подпись внутреннего запроса устарела")
+
+	// ErrSignatureReplayed - запрос с той же подписью уже был принят
+	ErrSignatureReplayed = errors.New("подпись внутреннего запроса уже использована")
+
+	// ErrContentDigestMismatch - тело запроса отличается от подписанного
+	ErrContentDigestMismatch = errors.New("тело внутреннего запроса не совпадает с подписанным")
+)
+
+// SignRequest подписывает запрос общим ключом secret. Подпись покрывает метод, путь с
+// параметрами, время, одноразовое значение и SHA-256 тела, поэтому перехваченный
+// заголовок нельзя приложить к другому запросу, отправить повторно или с другим телом.
+// Тело без GetBody читается в память, чтобы запрос можно было отправить и повторить
+func SignRequest(req *http.Request, secret string) error {
+	digest, err := requestBodyDigest(req)
+	if err != nil {
+		return fmt.Errorf("не удалось подписать запрос: %w", err)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("не удалось подписать запрос: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	encoded := hex.EncodeToString(nonce)
+
+	sig := requestSignature(secret, req.Method, req.URL.RequestURI(), timestamp, encoded, digest)
+	req.Header.Set(ContentDigestHeader, digest)
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%s,n=%s,s=%s", timestamp, encoded, sig))
+	return nil
+}
+
+// requestBodyDigest вычисляет SHA-256 тела отправляемого запроса, не расходуя его
+func requestBodyDigest(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return contentDigest(nil), nil
+	}
+	if req.GetBody == nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		return contentDigest(data), nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// contentDigest возвращает SHA-256 данных в hex
+func contentDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// requestSignature вычисляет HMAC-SHA256 подписываемых частей запроса
+func requestSignature(secret, method, uri, timestamp, nonce, digest string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + digest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureVerifier проверяет подписи внутренних запросов и запоминает принятые
+// одноразовые значения на время их действия, чтобы отклонять повторы
+type SignatureVerifier struct {
+	secret  string
+	maxSkew time.Duration
+
+	mutex     sync.Mutex
+	seen      map[string]time.Time // одноразовое значение -> когда его можно забыть
+	lastPrune time.Time
+}
+
+// NewSignatureVerifier создает проверку подписей ключом secret. Запрос принимается, если
+// время подписи отличается от текущего не больше чем на maxSkew
+func NewSignatureVerifier(secret string, maxSkew time.Duration) *SignatureVerifier {
+	return &SignatureVerifier{secret: secret, maxSkew: maxSkew, seen: make(map[string]time.Time)}
+}
+
+// Verify проверяет подпись запроса, ее время, тело и то, что подпись не предъявлялась
+// раньше. Тело читается в память только после проверки подписи и остается доступным
+// обработчику
+func (v *SignatureVerifier) Verify(req *http.Request) error {
+	header := req.Header.Get(SignatureHeader)
+	if header == "" {
+		return ErrSignatureMissing
+	}
+
+	var timestamp, nonce, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "n":
+			nonce = value
+		case "s":
+			sig = value
+		}
+	}
+	if timestamp == "" || nonce == "" || sig == "" {
+		return ErrSignatureInvalid
+	}
+
+	uri := req.RequestURI
+	if uri == "" {
+		uri = req.URL.RequestURI()
+	}
+	digest := req.Header.Get(ContentDigestHeader)
+	expected := requestSignature(v.secret, req.Method, uri, timestamp, nonce, digest)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrSignatureInvalid
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	now := time.Now()
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-v.maxSkew)) || signedAt.After(now.Add(v.maxSkew)) {
+		return ErrSignatureExpired
+	}
+
+	var data []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if data, err = io.ReadAll(req.Body); err != nil {
+			return fmt.Errorf("не удалось прочитать тело внутреннего запроса: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+	if contentDigest(data) != digest {
+		return ErrContentDigestMismatch
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.pruneLocked(now)
+	if _, ok := v.seen[nonce]; ok {
+		return ErrSignatureReplayed
+	}
+	// Позже этого срока подпись отклоняется по времени, и значение можно забыть
+	v.seen[nonce] = signedAt.Add(v.maxSkew)
+	return nil
+}
+
+// pruneLocked удаляет одноразовые значения подписей, которые уже не пройдут проверку
+// времени. Выполняется не чаще раза в maxSkew
+func (v *SignatureVerifier) pruneLocked(now time.Time) {
+	if now.Sub(v.lastPrune) < v.maxSkew {
+		return
+	}
+	for nonce, expiresAt := range v.seen {
+		if now.After(expiresAt) {
+			delete(v.seen, nonce)
+		}
+	}
+	v.lastPrune = now
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	verifier := NewSignatureVerifier("secret", time.Minute)
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodDelete, "http://node1:8081/api/v1/chunks/a", nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	assert.ErrorIs(t, verifier.Verify(newRequest()), ErrSignatureMissing)
+
+	req := newRequest()
+	require.NoError(t, SignRequest(req, "secret"))
+	assert.NoError(t, verifier.Verify(req))
+	// Перехваченный запрос нельзя отправить повторно
+	assert.ErrorIs(t, verifier.Verify(req), ErrSignatureReplayed)
+
+	// Подпись не переносится на другой кусок
+	other, err := http.NewRequest(http.MethodDelete, "http://node1:8081/api/v1/chunks/b", nil)
+	require.NoError(t, err)
+	other.Header.Set(SignatureHeader, req.Header.Get(SignatureHeader))
+	assert.ErrorIs(t, verifier.Verify(other), ErrSignatureInvalid)
+
+	req = newRequest()
+	require.NoError(t, SignRequest(req, "other-secret"))
+	assert.ErrorIs(t, verifier.Verify(req), ErrSignatureInvalid)
+
+	// Подпись с устаревшим временем отклоняется, даже если она верна
+	req = newRequest()
+	timestamp := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	sig := requestSignature("secret", req.Method, req.URL.RequestURI(), timestamp, "nonce", contentDigest(nil))
+	req.Header.Set(ContentDigestHeader, contentDigest(nil))
+	req.Header.Set(SignatureHeader, "t="+timestamp+",n=nonce,s="+sig)
+	assert.ErrorIs(t, verifier.Verify(req), ErrSignatureExpired)
+}
+
+func TestSignatureCoversBody(t *testing.T) {
+	verifier := NewSignatureVerifier("secret", time.Minute)
+	newRequest := func(body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://node1:8081/api/v1/chunks", bytes.NewReader([]byte(body)))
+		require.NoError(t, err)
+		return req
+	}
+
+	// Подписанное тело остается доступным обработчику
+	req := newRequest(`{"id":"a"}`)
+	require.NoError(t, SignRequest(req, "secret"))
+	require.NoError(t, verifier.Verify(req))
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a"}`, string(body))
+
+	// Подпись и заголовок тела не переносятся на запрос с другим телом
+	signed := newRequest(`{"id":"a"}`)
+	require.NoError(t, SignRequest(signed, "secret"))
+	tampered := newRequest(`{"id":"b"}`)
+	tampered.Header = signed.Header.Clone()
+	assert.ErrorIs(t, verifier.Verify(tampered), ErrContentDigestMismatch)
+
+	// Заголовок тела нельзя заменить: он входит в подпись
+	tampered = newRequest(`{"id":"b"}`)
+	tampered.Header = signed.Header.Clone()
+	tampered.Header.Set(ContentDigestHeader, contentDigest([]byte(`{"id":"b"}`)))
+	assert.ErrorIs(t, verifier.Verify(tampered), ErrSignatureInvalid)
+
+	// Тело без GetBody подписывается и отправляется целиком
+	req = newRequest(`{"id":"c"}`)
+	req.GetBody = nil
+	require.NoError(t, SignRequest(req, "secret"))
+	require.NotNil(t, req.GetBody)
+	assert.NoError(t, verifier.Verify(req))
+}
@@ -0,0 +1,101 @@
+// Package storagetest содержит реализацию storage.ChunkStore в памяти для модульных тестов
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+// ChunkStore хранит куски в памяти и ведет себя как storage.StorageClient: поврежденный
+// кусок не сохраняется (storage.ErrChunkCorrupted), отсутствующий не читается
+// (storage.ErrNotFound), а удаление отсутствующего куска не считается ошибкой
+type ChunkStore struct {
+	// Err, если задана, возвращается каждым методом, например чтобы проверить поведение
+	// приложения при недоступном сервере
+	Err error
+
+	mutex  sync.Mutex
+	chunks map[string]chunking.FileChunk
+}
+
+var _ storage.ChunkStore = (*ChunkStore)(nil)
+
+// NewChunkStore создает пустое хранилище кусков
+func NewChunkStore() *ChunkStore {
+	return &ChunkStore{chunks: make(map[string]chunking.FileChunk)}
+}
+
+// StoreChunkContext сохраняет копию куска, проверив его контрольную сумму
+func (s *ChunkStore) StoreChunkContext(ctx context.Context, chunk *chunking.FileChunk) error {
+	if err := s.check(ctx); err != nil {
+		return err
+	}
+	if err := chunking.ValidateChunk(chunk); err != nil {
+		return fmt.Errorf("%w: %v", storage.ErrChunkCorrupted, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stored := *chunk
+	stored.Data = append([]byte(nil), chunk.Data...)
+	s.chunks[chunk.ID] = stored
+	return nil
+}
+
+// GetChunkContext возвращает копию сохраненного куска
+func (s *ChunkStore) GetChunkContext(ctx context.Context, chunkID string) (*chunking.FileChunk, error) {
+	if err := s.check(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	chunk, ok := s.chunks[chunkID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, chunkID)
+	}
+	chunk.Data = append([]byte(nil), chunk.Data...)
+	return &chunk, nil
+}
+
+// DeleteChunkContext удаляет кусок; отсутствующий кусок ошибкой не считается
+func (s *ChunkStore) DeleteChunkContext(ctx context.Context, chunkID string) error {
+	if err := s.check(ctx); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.chunks, chunkID)
+	return nil
+}
+
+// HealthCheckContext возвращает Err или ошибку отмененного контекста
+func (s *ChunkStore) HealthCheckContext(ctx context.Context) error {
+	return s.check(ctx)
+}
+
+// ChunkIDs возвращает отсортированные идентификаторы сохраненных кусков
+func (s *ChunkStore) ChunkIDs() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := make([]string, 0, len(s.chunks))
+	for id := range s.chunks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// check возвращает ошибку, с которой должен завершиться любой метод
+func (s *ChunkStore) check(ctx context.Context) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,35 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+)
+
+func TestChunkStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewChunkStore()
+
+	checksum, err := chunking.Checksum(chunking.HashSHA256, []byte("data"))
+	require.NoError(t, err)
+	chunk := &chunking.FileChunk{ID: "a", Data: []byte("data"), Size: 4, Checksum: checksum}
+	require.NoError(t, store.StoreChunkContext(ctx, chunk))
+	assert.Equal(t, []string{"a"}, store.ChunkIDs())
+
+	stored, err := store.GetChunkContext(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), stored.Data)
+
+	corrupted := &chunking.FileChunk{ID: "b", Data: []byte("data"), Size: 4, Checksum: "bad"}
+	assert.ErrorIs(t, store.StoreChunkContext(ctx, corrupted), storage.ErrChunkCorrupted)
+
+	require.NoError(t, store.DeleteChunkContext(ctx, "a"))
+	require.NoError(t, store.DeleteChunkContext(ctx, "a"))
+	_, err = store.GetChunkContext(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
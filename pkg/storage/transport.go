@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// UnixScheme - префикс адреса сервера на unix сокете: unix:///run/storage.sock
+const UnixScheme = "unix://"
+
+// unixBaseURL - базовый URL запросов через unix сокет. Имя хоста ни на что не влияет:
+// транспорт всегда соединяется с сокетом
+const unixBaseURL = "http://unix"
+
+// dialer - параметры соединений с серверами по TCP и через unix сокеты
+var dialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// h2cTransport - общий транспорт HTTP/2 без TLS: все клиенты процесса обращаются к
+// одному серверу через одно TCP соединение, в котором мультиплексируются запросы
+var h2cTransport = sync.OnceValue(func() http.RoundTripper {
+	return newH2CTransport(dialer.DialContext)
+})
+
+// newH2CTransport создает транспорт HTTP/2 без TLS, открывающий соединения функцией dial
+func newH2CTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error)) http.RoundTripper {
+	return &http2.Transport{
+		AllowHTTP: true,
+		// Соединение открывается без TLS: сервер заранее известно принимает h2c
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+		// Пинг обнаруживает оборванное соединение, чтобы не ждать таймаута запроса
+		ReadIdleTimeout: 30 * time.Second,
+		PingTimeout:     15 * time.Second,
+	}
+}
+
+// H2CTransport возвращает транспорт, отправляющий запросы по HTTP/2 без TLS (h2c) с
+// заранее известной поддержкой протокола. Сервер должен принимать h2c, иначе
+// запросы завершаются ошибкой
+func H2CTransport() http.RoundTripper {
+	return h2cTransport()
+}
+
+// NewH2CStorageClient создает клиент для сервера хранения, принимающего h2c. Запросы
+// к серверу от всех таких клиентов используют одно соединение
+func NewH2CStorageClient(baseURL string, opts ...ClientOption) *StorageClient {
+	if socket, ok := UnixSocketPath(baseURL); ok {
+		return newStorageClient(unixBaseURL, H2CUnixTransport(socket), opts)
+	}
+	return newStorageClient(baseURL, H2CTransport(), opts)
+}
+
+// UnixSocketPath возвращает путь к сокету из адреса unix:///path. ok ложно, если
+// адрес не указывает на unix сокет
+func UnixSocketPath(address string) (path string, ok bool) {
+	if !strings.HasPrefix(address, UnixScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, UnixScheme), true
+}
+
+// NodeURL возвращает базовый URL сервера хранения по адресу из storage_servers:
+// host:port обращается по HTTP, а адрес unix:///path остается как есть и
+// распознается конструкторами клиентов
+func NodeURL(node string) string {
+	if _, ok := UnixSocketPath(node); ok {
+		return node
+	}
+	return "http://" + node
+}
+
+// UnixBaseURL возвращает базовый URL для запросов через транспорт unix сокета
+func UnixBaseURL() string {
+	return unixBaseURL
+}
+
+// dialUnix возвращает функцию, соединяющуюся с сокетом path вместо адреса запроса
+func dialUnix(path string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", path)
+	}
+}
+
+// UnixTransport возвращает транспорт HTTP/1.1, отправляющий все запросы на unix
+// сокет path независимо от хоста в URL
+func UnixTransport(path string) http.RoundTripper {
+	return &http.Transport{
+		DialContext:         dialUnix(path),
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// H2CUnixTransport возвращает транспорт HTTP/2 без TLS через unix сокет path
+func H2CUnixTransport(path string) http.RoundTripper {
+	return newH2CTransport(dialUnix(path))
+}
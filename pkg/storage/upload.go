@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"TestCase/pkg/chunking"
+)
+
+// Назначения токенов, которыми обмениваются API сервер и серверы хранения
+const (
+	UploadTokenPurpose  = "chunk-upload"  // токен загрузки куска
+	ReceiptTokenPurpose = "chunk-receipt" // квитанция о сохраненном куске
+)
+
+// UploadClaims - данные токена, разрешающего загрузить один кусок напрямую на сервер
+// хранения. Токен выдает API сервер в плане загрузки
+type UploadClaims struct {
+	ChunkID   string                 `json:"chunk_id"`
+	FileID    string                 `json:"file_id"`
+	Index     int                    `json:"index"`
+	Size      int64                  `json:"size"`
+	Algorithm chunking.HashAlgorithm `json:"algorithm"`
+}
+
+// ChunkReceipt - данные квитанции сервера хранения о сохраненном куске. По квитанциям
+// API сервер при завершении загрузки убеждается, что куски действительно сохранены
+type ChunkReceipt struct {
+	ChunkID  string `json:"chunk_id"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// UploadResult - ответ сервера хранения на прямую загрузку куска
+type UploadResult struct {
+	ChunkID  string `json:"chunk_id"`
+	Checksum string `json:"checksum"`
+	Receipt  string `json:"receipt"` // подписанная квитанция для завершения загрузки
+	ServerID string `json:"server_id"`
+}
+
+// UploadChunkContext загружает данные куска напрямую на сервер хранения по токену из
+// плана загрузки. Сервер сам вычисляет контрольную сумму и возвращает квитанцию
+func (c *StorageClient) UploadChunkContext(ctx context.Context, chunkID, uploadToken string, data io.Reader, size int64) (*UploadResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/api/v1/chunks/%s", c.BaseURL, chunkID), data)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", "Bearer "+uploadToken)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var result UploadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("не удалось декодировать ответ: %w", err)
+	}
+
+	return &result, nil
+}
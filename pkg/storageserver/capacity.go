@@ -0,0 +1,44 @@
+package storageserver
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// freeBytes возвращает, сколько еще данных может принять сервер. При заданном
+// storage_capacity это остаток от него, иначе - доступная память системы.
+// false означает, что свободное место определить не удалось
+func (s *MemoryStorageServer) freeBytes(used int64) (int64, bool) {
+	if s.config.StorageCapacity > 0 {
+		if used >= s.config.StorageCapacity {
+			return 0, true
+		}
+		return s.config.StorageCapacity - used, true
+	}
+	return availableMemory()
+}
+
+// availableMemory читает объем доступной памяти из /proc/meminfo (только Linux)
+func availableMemory() (int64, bool) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Строка вида "MemAvailable:   16123456 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemAvailable:" && fields[2] == "kB" {
+			kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return kilobytes * 1024, true
+		}
+	}
+	return 0, false
+}
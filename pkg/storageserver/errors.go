@@ -0,0 +1,51 @@
+package storageserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/storage"
+)
+
+// requestIDKey - ключ контекста gin с идентификатором запроса
+const requestIDKey = "request_id"
+
+// requestIDMiddleware назначает запросу идентификатор и возвращает его в заголовке X-Request-ID
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := apierror.RequestID(c.GetHeader(apierror.RequestIDHeader))
+		c.Set(requestIDKey, requestID)
+		c.Header(apierror.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// errorBody возвращает тело ответа с ошибкой: код, не зависящий от языка, сообщение на
+// языке из заголовка Accept-Language и идентификатор запроса
+func errorBody(c *gin.Context, code apierror.Code, args ...interface{}) apierror.Envelope {
+	language := apierror.Negotiate(c.GetHeader("Accept-Language"))
+	return apierror.New(language, code, c.GetString(requestIDKey), args...)
+}
+
+// writeError отвечает ошибкой code с HTTP статусом status
+func writeError(c *gin.Context, status int, code apierror.Code, args ...interface{}) {
+	c.JSON(status, errorBody(c, code, args...))
+}
+
+// writeStoreError отвечает на ошибку сохранения куска: 507, если кусок не помещается в
+// storage_capacity, иначе 500
+func writeStoreError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		writeError(c, http.StatusInsufficientStorage, apierror.StorageFull, err)
+		return
+	}
+	writeError(c, http.StatusInternalServerError, apierror.ChunkStoreFailed, err)
+}
+
+// routeNotFound отвечает на запрос к несуществующему маршруту
+func routeNotFound(c *gin.Context) {
+	writeError(c, http.StatusNotFound, apierror.RouteNotFound, c.Request.Method, c.Request.URL.Path)
+}
@@ -0,0 +1,105 @@
+package storageserver
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/config"
+)
+
+// faultInjector вносит в ответы сервера хранения настроенные сбои: задержку, ошибки 500 и
+// оборванные на середине куски, чтобы проверить повторы, восстановление и репликацию
+type faultInjector struct {
+	latency      time.Duration
+	jitter       time.Duration
+	errorRate    float64
+	truncateRate float64
+}
+
+// newFaultInjector возвращает внесение сбоев по конфигурации; nil, если оно выключено
+func newFaultInjector(cfg *config.Config) *faultInjector {
+	if !cfg.FaultInjection {
+		return nil
+	}
+	return &faultInjector{
+		latency:      cfg.FaultLatency,
+		jitter:       cfg.FaultLatencyJitter,
+		errorRate:    cfg.FaultErrorRate,
+		truncateRate: cfg.FaultTruncateRate,
+	}
+}
+
+// middleware вносит сбои в обработку запроса
+func (f *faultInjector) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		delay := f.latency
+		if f.jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(f.jitter) + 1))
+		}
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+
+		if rand.Float64() < f.errorRate {
+			log.Printf("Внесен сбой: ошибка 500 на %s %s", c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorBody(c, apierror.FaultInjected))
+			return
+		}
+
+		// Обрываются только ответы с данными кусков
+		if c.Request.Method != http.MethodGet || c.FullPath() != "/api/v1/chunks/:id" || rand.Float64() >= f.truncateRate {
+			c.Next()
+			return
+		}
+
+		writer := &truncatingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if writer.truncate() {
+			log.Printf("Внесен сбой: ответ на %s %s оборван", c.Request.Method, c.Request.URL.Path)
+		}
+	}
+}
+
+// truncatingWriter накапливает ответ, чтобы отправить только его первую половину
+type truncatingWriter struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *truncatingWriter) Write(data []byte) (int, error) {
+	w.body = append(w.body, data...)
+	return len(data), nil
+}
+
+func (w *truncatingWriter) WriteString(s string) (int, error) {
+	w.body = append(w.body, s...)
+	return len(s), nil
+}
+
+// truncate отправляет накопленный ответ. Успешный ответ объявляется полной длины, но
+// отправляется наполовину, поэтому сервер закрывает соединение и клиент получает
+// обрыв, как при сбое сети. Сообщает, был ли ответ оборван
+func (w *truncatingWriter) truncate() bool {
+	if w.Status() != http.StatusOK || len(w.body) < 2 {
+		w.ResponseWriter.Write(w.body)
+		return false
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(w.body)))
+	w.ResponseWriter.Write(w.body[:len(w.body)/2])
+	return true
+}
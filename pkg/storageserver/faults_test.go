@@ -0,0 +1,52 @@
+package storageserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+	"TestCase/pkg/storage"
+)
+
+func TestFaultInjection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	cfg := config.Defaults()
+	cfg.FaultInjection = true
+	server, err := NewMemoryStorageServer(cfg, "1")
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+	client := storage.NewStorageClient(httpServer.URL)
+
+	checksum, err := chunking.Checksum(chunking.HashSHA256, []byte("data"))
+	require.NoError(t, err)
+	chunk := &chunking.FileChunk{ID: "a", Data: []byte("data"), Size: 4, Checksum: checksum}
+	require.NoError(t, client.StoreChunkContext(ctx, chunk))
+
+	// Кусок обрывается на середине ответа
+	server.faults.truncateRate = 1
+	_, err = client.GetChunkContext(ctx, "a")
+	assert.Error(t, err)
+
+	// Каждый запрос завершается ошибкой 500
+	server.faults.truncateRate = 0
+	server.faults.errorRate = 1
+	_, err = client.GetChunkContext(ctx, "a")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "500")
+	}
+
+	// Без сбоев кусок читается
+	server.faults.errorRate = 0
+	stored, err := client.GetChunkContext(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), stored.Data)
+}
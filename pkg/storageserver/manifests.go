@@ -0,0 +1,80 @@
+package storageserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/storage"
+)
+
+// maxManifestSize ограничивает размер манифеста файла
+const maxManifestSize = 4 << 20
+
+// putManifest сохраняет манифест файла, который API сервер записывает рядом с кусками.
+// Сервер проверяет только, что манифест относится к файлу из пути
+func (s *MemoryStorageServer) putManifest(c *gin.Context) {
+	fileID := c.Param("id")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxManifestSize+1))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidManifest, err)
+		return
+	}
+	if len(body) > maxManifestSize {
+		writeError(c, http.StatusBadRequest, apierror.InvalidManifest, errors.New("манифест слишком большой"))
+		return
+	}
+
+	var manifest storage.FileManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidManifest, err)
+		return
+	}
+	if manifest.Metadata == nil || manifest.Metadata.ID != fileID {
+		writeError(c, http.StatusBadRequest, apierror.InvalidManifest, errors.New("манифест относится к другому файлу"))
+		return
+	}
+
+	if err := s.memoryStorage.PutManifest(fileID, body); err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.ManifestStoreFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":   fileID,
+		"server_id": s.serverID,
+	})
+}
+
+// deleteManifest удаляет манифест файла; удаление отсутствующего манифеста успешно
+func (s *MemoryStorageServer) deleteManifest(c *gin.Context) {
+	fileID := c.Param("id")
+
+	if err := s.memoryStorage.DeleteManifest(fileID); err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.ManifestDeleteFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":   fileID,
+		"server_id": s.serverID,
+	})
+}
+
+// listManifests возвращает все манифесты файлов на сервере
+func (s *MemoryStorageServer) listManifests(c *gin.Context) {
+	manifests := s.memoryStorage.ListManifests()
+	log.Printf("Запрошены манифесты файлов на сервере %s: %d", s.serverID, len(manifests))
+
+	c.JSON(http.StatusOK, gin.H{
+		"manifests": manifests,
+		"count":     len(manifests),
+		"server_id": s.serverID,
+	})
+}
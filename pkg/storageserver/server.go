@@ -0,0 +1,453 @@
+// Package storageserver содержит сервер хранения кусков, который можно встроить в другую
+// программу
+package storageserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/internal/listen"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/config"
+	"TestCase/pkg/storage"
+)
+
+// MemoryStorageServer представляет сервер хранения с использованием памяти.
+// Сервер создается NewMemoryStorageServer, запускается Start и останавливается Shutdown
+type MemoryStorageServer struct {
+	config        *config.Config
+	memoryStorage *storage.MemoryStorage
+	serverID      string
+	signatures    *storage.SignatureVerifier // проверка подписей внутренних запросов; nil - подпись не требуется
+	faults        *faultInjector             // внесение сбоев для проверки отказоустойчивости; nil - выключено
+	httpServer    *http.Server               // сервер, запущенный Start; nil, если сервер встроен через Handler
+}
+
+// NewMemoryStorageServer создает новый сервер хранения в памяти
+func NewMemoryStorageServer(cfg *config.Config, serverID string) (*MemoryStorageServer, error) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	// При включенном сохранении восстанавливаем куски с диска
+	if cfg.PersistenceEnabled {
+		var err error
+		memoryStorage, err = storage.NewPersistentMemoryStorage(storage.PersistenceOptions{
+			Dir:              filepath.Join(cfg.StorageDir, fmt.Sprintf("server_%s", serverID)),
+			SnapshotInterval: cfg.SnapshotInterval,
+			SyncWrites:       cfg.SyncWrites,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	memoryStorage.SetCapacity(cfg.StorageCapacity)
+
+	server := &MemoryStorageServer{
+		config:        cfg,
+		memoryStorage: memoryStorage,
+		serverID:      serverID,
+		faults:        newFaultInjector(cfg),
+	}
+	if server.faults != nil {
+		log.Printf("Сервер хранения %s вносит сбои в ответы: задержка %v (+%v), ошибки %.0f%%, обрывы кусков %.0f%%",
+			serverID, cfg.FaultLatency, cfg.FaultLatencyJitter, cfg.FaultErrorRate*100, cfg.FaultTruncateRate*100)
+	}
+	if cfg.InternalSecret != "" {
+		server.signatures = storage.NewSignatureVerifier(cfg.InternalSecret, cfg.InternalSignatureMaxSkew)
+	}
+	return server, nil
+}
+
+// setupMemoryRoutes настраивает маршруты для сервера хранения в памяти
+func (s *MemoryStorageServer) setupMemoryRoutes() *gin.Engine {
+	router := gin.Default()
+
+	// Middleware для логирования
+	router.Use(gin.Logger())
+	router.Use(gin.Recovery())
+	router.Use(requestIDMiddleware())
+	router.NoRoute(routeNotFound)
+
+	// Проверка здоровья сервиса
+	router.GET("/health", s.healthCheck)
+
+	// Статистика операций в формате Prometheus
+	router.GET("/metrics", s.metrics)
+
+	// API для работы с кусками файлов
+	v1 := router.Group("/api/v1")
+	if s.faults != nil {
+		v1.Use(s.faults.middleware())
+	}
+	{
+		// Прямая загрузка от клиента проверяется токеном из плана загрузки
+		v1.PUT("/chunks/:id", s.uploadChunk)
+		// Остальные операции с кусками выполняет API сервер подписанными запросами
+		v1.POST("/chunks", s.requireSignature(false), s.storeChunk)
+		v1.GET("/chunks/:id", s.requireSignature(true), s.getChunk)
+		v1.DELETE("/chunks/:id", s.requireSignature(false), s.deleteChunk)
+		v1.POST("/chunks/:id/refs", s.requireSignature(false), s.incrementChunkRef)
+		v1.DELETE("/chunks/:id/refs", s.requireSignature(false), s.deleteChunk)
+		v1.POST("/chunks/:id/migrate", s.requireSignature(false), s.migrateChunk)
+		v1.GET("/chunks", s.requireSignature(false), s.listChunks)
+		v1.GET("/inventory", s.requireSignature(false), s.getInventory)
+		v1.PUT("/manifests/:id", s.requireSignature(false), s.putManifest)
+		v1.DELETE("/manifests/:id", s.requireSignature(false), s.deleteManifest)
+		v1.GET("/manifests", s.requireSignature(false), s.listManifests)
+		v1.GET("/info", s.getStorageInfo)
+		v1.GET("/memory", s.getMemoryUsage)
+		v1.POST("/compact", s.compactStorage)
+	}
+
+	return router
+}
+
+// healthCheck проверяет состояние сервиса хранения
+func (s *MemoryStorageServer) healthCheck(c *gin.Context) {
+	// Проверяем доступность хранилища в памяти
+	_, err := s.memoryStorage.GetStorageInfo()
+	status := "healthy"
+	if err != nil {
+		status = "unhealthy"
+		log.Printf("Проблема с хранилищем в памяти: %v", err)
+	}
+
+	response := gin.H{
+		"status":    status,
+		"server_id": s.serverID,
+		"timestamp": time.Now().Unix(),
+	}
+
+	// Свободное место учитывается API сервером при размещении новых кусков
+	if used, err := s.memoryStorage.GetMemoryUsage(); err == nil {
+		response["used_bytes"] = used
+		if free, ok := s.freeBytes(used); ok {
+			response["free_bytes"] = free
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// metrics отдает статистику операций хранилища для Prometheus
+func (s *MemoryStorageServer) metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := s.memoryStorage.WriteMetrics(c.Writer); err != nil {
+		log.Printf("Не удалось отдать метрики: %v", err)
+	}
+}
+
+// storeChunk сохраняет кусок файла в памяти
+func (s *MemoryStorageServer) storeChunk(c *gin.Context) {
+	var chunk chunking.FileChunk
+
+	if err := c.ShouldBindJSON(&chunk); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidChunk)
+		return
+	}
+
+	// Проверяем целостность куска
+	if err := chunking.ValidateChunk(&chunk); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.ChunkCorrupted, err)
+		return
+	}
+
+	// При переносе с другого сервера кусок приходит вместе с его ссылками
+	refs := 0
+	if value := c.Query("refs"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeError(c, http.StatusBadRequest, apierror.InvalidRefs)
+			return
+		}
+		refs = parsed
+	}
+
+	// Сохраняем кусок в памяти
+	if err := s.memoryStorage.StoreChunkRefs(&chunk, refs); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	log.Printf("Кусок %s сохранен в памяти на сервере %s", chunk.ID, s.serverID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Кусок успешно сохранен",
+		"chunk_id":  chunk.ID,
+		"server_id": s.serverID,
+	})
+}
+
+// getChunk получает кусок файла из памяти
+func (s *MemoryStorageServer) getChunk(c *gin.Context) {
+	chunkID := c.Param("id")
+
+	chunk, err := s.memoryStorage.GetChunk(chunkID)
+	if err != nil {
+		if errors.Is(err, storage.ErrChunkCorrupted) {
+			log.Printf("Кусок %s на сервере %s поврежден: %v", chunkID, s.serverID, err)
+			c.JSON(http.StatusUnprocessableEntity, errorBody(c, apierror.ChunkCorrupted, err).WithDetail("corrupted", true))
+		} else if errors.Is(err, storage.ErrNotFound) {
+			writeError(c, http.StatusNotFound, apierror.ChunkNotFound)
+		} else {
+			writeError(c, http.StatusInternalServerError, apierror.ChunkReadFailed, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, chunk)
+}
+
+// deleteChunk снимает с куска ссылку файла и удаляет кусок, если ссылок не осталось
+func (s *MemoryStorageServer) deleteChunk(c *gin.Context) {
+	chunkID := c.Param("id")
+
+	refCount, err := s.memoryStorage.DecrementRef(chunkID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(c, http.StatusNotFound, apierror.ChunkNotFound)
+		} else {
+			writeError(c, http.StatusInternalServerError, apierror.ChunkDeleteFailed, err)
+		}
+		return
+	}
+
+	message := "Кусок успешно удален"
+	if refCount > 0 {
+		message = "Ссылка на кусок снята, кусок используется другими файлами"
+		log.Printf("С куска %s на сервере %s снята ссылка, осталось %d", chunkID, s.serverID, refCount)
+	} else {
+		log.Printf("Кусок %s удален из памяти на сервере %s", chunkID, s.serverID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   message,
+		"chunk_id":  chunkID,
+		"ref_count": refCount,
+		"deleted":   refCount == 0,
+		"server_id": s.serverID,
+	})
+}
+
+// incrementChunkRef добавляет ссылку еще одного файла на уже сохраненный кусок
+func (s *MemoryStorageServer) incrementChunkRef(c *gin.Context) {
+	chunkID := c.Param("id")
+
+	refCount, err := s.memoryStorage.IncrementRef(chunkID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(c, http.StatusNotFound, apierror.ChunkNotFound)
+		} else {
+			writeError(c, http.StatusInternalServerError, apierror.ChunkLinkFailed, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chunk_id":  chunkID,
+		"ref_count": refCount,
+		"server_id": s.serverID,
+	})
+}
+
+// migrateChunk передает кусок вместе со всеми ссылками напрямую на другой сервер хранения,
+// чтобы данные при перераспределении не проходили через API сервер. По умолчанию кусок
+// удаляется с этого сервера после передачи; keep=true оставляет копию, например пока
+// API сервер переключает на новый сервер метаданные файлов
+func (s *MemoryStorageServer) migrateChunk(c *gin.Context) {
+	chunkID := c.Param("id")
+
+	target := c.Query("target")
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidTarget)
+		return
+	}
+	keep := c.Query("keep") == "true"
+
+	chunk, err := s.memoryStorage.GetChunk(chunkID)
+	if err != nil {
+		if errors.Is(err, storage.ErrChunkCorrupted) {
+			c.JSON(http.StatusUnprocessableEntity, errorBody(c, apierror.ChunkCorrupted, err).WithDetail("corrupted", true))
+		} else if errors.Is(err, storage.ErrNotFound) {
+			writeError(c, http.StatusNotFound, apierror.ChunkNotFound)
+		} else {
+			writeError(c, http.StatusInternalServerError, apierror.ChunkReadFailed, err)
+		}
+		return
+	}
+
+	client := storage.NewStorageClient(storage.NodeURL(target))
+	if s.config.HTTP2Cleartext {
+		client = storage.NewH2CStorageClient(storage.NodeURL(target))
+	}
+	client.Secret = s.config.InternalSecret
+	if err := client.StoreChunkRefsContext(c.Request.Context(), chunk, chunk.RefCount); err != nil {
+		// Нехватка места на целевом сервере - не сбой передачи: отвечаем тем же статусом 507
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			writeError(c, http.StatusInsufficientStorage, apierror.StorageFull, err)
+			return
+		}
+		writeError(c, http.StatusBadGateway, apierror.ChunkTransferFailed, target, err)
+		return
+	}
+
+	if !keep {
+		if err := s.memoryStorage.PurgeChunk(chunkID); err != nil {
+			writeError(c, http.StatusInternalServerError, apierror.ChunkCleanupFailed, err)
+			return
+		}
+	}
+
+	log.Printf("Кусок %s передан с сервера %s на %s", chunkID, s.serverID, target)
+	c.JSON(http.StatusOK, gin.H{
+		"chunk_id":  chunkID,
+		"target":    target,
+		"size":      chunk.Size,
+		"ref_count": chunk.RefCount,
+		"deleted":   !keep,
+		"server_id": s.serverID,
+	})
+}
+
+// listChunks возвращает список всех кусков в памяти
+func (s *MemoryStorageServer) listChunks(c *gin.Context) {
+	chunks, err := s.memoryStorage.ListChunks()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.ChunkListFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chunks":    chunks,
+		"count":     len(chunks),
+		"server_id": s.serverID,
+	})
+}
+
+// getInventory возвращает сведения обо всех кусках без данных для проверки согласованности
+// с каталогом. verify=true пересчитывает контрольные суммы кусков
+func (s *MemoryStorageServer) getInventory(c *gin.Context) {
+	verify := c.Query("verify") == "true"
+	chunks := s.memoryStorage.Inventory(verify)
+
+	c.JSON(http.StatusOK, gin.H{
+		"chunks":    chunks,
+		"count":     len(chunks),
+		"verified":  verify,
+		"server_id": s.serverID,
+	})
+}
+
+// getStorageInfo возвращает информацию о хранилище
+func (s *MemoryStorageServer) getStorageInfo(c *gin.Context) {
+	info, err := s.memoryStorage.GetStorageInfo()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.StorageInfoFailed, err)
+		return
+	}
+
+	info["server_id"] = s.serverID
+	c.JSON(http.StatusOK, info)
+}
+
+// getMemoryUsage возвращает информацию об использовании памяти
+func (s *MemoryStorageServer) getMemoryUsage(c *gin.Context) {
+	usage, err := s.memoryStorage.GetMemoryUsage()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.MemoryInfoFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"memory_usage_bytes": usage,
+		"memory_usage_mb":    float64(usage) / (1024 * 1024),
+		"server_id":          s.serverID,
+	})
+}
+
+// compactStorage очищает память от неиспользуемых кусков
+func (s *MemoryStorageServer) compactStorage(c *gin.Context) {
+	compacted := s.memoryStorage.CompactStorage()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Память очищена",
+		"chunks_removed": compacted,
+		"server_id":      s.serverID,
+	})
+}
+
+// Handler возвращает обработчик запросов сервера хранения, например чтобы подключить его к
+// своему HTTP серверу или к httptest.Server. Режим Gin (gin.SetMode) задается до вызова
+func (s *MemoryStorageServer) Handler() http.Handler {
+	router := s.setupMemoryRoutes()
+	// h2c принимается наряду с HTTP/1.1, поэтому старые клиенты продолжают работать
+	router.UseH2C = s.config.HTTP2Cleartext
+	return router.Handler()
+}
+
+// Start начинает принимать запросы на адресе из конфигурации (listen или storage_port) и
+// возвращает запущенный HTTP сервер. Запросы выполняются с контекстом ctx, поэтому его
+// отмена прерывает их. Сервер останавливается вызовом Shutdown
+func (s *MemoryStorageServer) Start(ctx context.Context) (*http.Server, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.httpServer != nil {
+		return nil, errors.New("сервер уже запущен")
+	}
+
+	address := fmt.Sprintf(":%s", s.config.StoragePort)
+	if s.config.Listen != "" {
+		address = s.config.Listen
+	}
+	listener, err := listen.Listen(address)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Запуск сервера хранения в памяти %s на адресе %s", s.serverID, address)
+
+	httpServer := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.config.HTTPReadHeaderTimeout,
+		ReadTimeout:       s.config.HTTPReadTimeout,
+		WriteTimeout:      s.config.HTTPWriteTimeout,
+		IdleTimeout:       s.config.HTTPIdleTimeout,
+		MaxHeaderBytes:    s.config.HTTPMaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	s.httpServer = httpServer
+
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Сервер хранения %s остановлен: %v", s.serverID, err)
+		}
+	}()
+
+	return httpServer, nil
+}
+
+// Shutdown дожидается завершения текущих запросов, пока не истечет ctx, останавливает
+// сервер и сохраняет снимок хранилища, если включено сохранение на диск
+func (s *MemoryStorageServer) Shutdown(ctx context.Context) error {
+	var errs []error
+	if s.httpServer != nil {
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("ошибка при остановке сервера: %w", err))
+		}
+	}
+	if err := s.memoryStorage.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("не удалось сохранить снимок хранилища: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,50 @@
+package storageserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/storage"
+	"TestCase/pkg/token"
+)
+
+// requireSignature пропускает к обработчику только запросы, подписанные общим ключом
+// internal_secret. Без ключа запросы принимаются как раньше. С allowReadToken кусок
+// можно получить и по токену чтения из манифеста: так клиенты скачивают куски
+// напрямую, не зная общего ключа
+func (s *MemoryStorageServer) requireSignature(allowReadToken bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.signatures == nil {
+			c.Next()
+			return
+		}
+
+		if readToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && allowReadToken {
+			var claims storage.ReadClaims
+			if err := token.Verify(s.config.InternalSecret, storage.ReadTokenPurpose, readToken, &claims); err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, apierror.ReadTokenRejected, err))
+				return
+			}
+			if claims.ChunkID != c.Param("id") {
+				c.AbortWithStatusJSON(http.StatusForbidden, errorBody(c, apierror.ChunkTokenMismatch))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if err := s.signatures.Verify(c.Request); err != nil {
+			if errors.Is(err, storage.ErrSignatureMissing) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, apierror.SignatureRequired))
+			} else {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody(c, apierror.SignatureRejected, err))
+			}
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,94 @@
+package storageserver
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"TestCase/internal/apierror"
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/storage"
+	"TestCase/pkg/token"
+)
+
+// uploadChunk принимает данные куска напрямую от клиента. Запрос должен содержать
+// токен из плана загрузки API сервера: он определяет идентификатор и размер куска.
+// В ответ сервер выдает подписанную квитанцию с контрольной суммой сохраненных данных
+func (s *MemoryStorageServer) uploadChunk(c *gin.Context) {
+	secret := s.config.UploadTokenSecret
+	if secret == "" {
+		writeError(c, http.StatusForbidden, apierror.ChunkUploadDisabled)
+		return
+	}
+
+	uploadToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		writeError(c, http.StatusUnauthorized, apierror.UploadTokenRequired)
+		return
+	}
+	var claims storage.UploadClaims
+	if err := token.Verify(secret, storage.UploadTokenPurpose, uploadToken, &claims); err != nil {
+		writeError(c, http.StatusUnauthorized, apierror.UploadTokenRejected, err)
+		return
+	}
+	if claims.ChunkID != c.Param("id") {
+		writeError(c, http.StatusForbidden, apierror.ChunkTokenMismatch)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, claims.Size))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(c, http.StatusRequestEntityTooLarge, apierror.ChunkTooLarge, claims.Size)
+			return
+		}
+		writeError(c, http.StatusBadRequest, apierror.ChunkBodyReadFailed, err)
+		return
+	}
+	if int64(len(data)) != claims.Size {
+		writeError(c, http.StatusBadRequest, apierror.ChunkTokenSizeMismatch, len(data), claims.Size)
+		return
+	}
+
+	checksum, err := chunking.Checksum(claims.Algorithm, data)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, apierror.InvalidRequest, err)
+		return
+	}
+
+	// Повторная загрузка того же куска перезаписывает его, не добавляя ссылок
+	chunk := &chunking.FileChunk{
+		ID:       claims.ChunkID,
+		FileID:   claims.FileID,
+		Index:    claims.Index,
+		Size:     claims.Size,
+		Checksum: checksum,
+		Data:     data,
+
+		Algorithm: claims.Algorithm,
+	}
+	if err := s.memoryStorage.StoreChunk(chunk); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	receipt, err := token.Sign(secret, storage.ReceiptTokenPurpose, storage.ChunkReceipt{ChunkID: chunk.ID, Size: chunk.Size, Checksum: checksum}, time.Now().Add(s.config.UploadTokenTTL))
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, apierror.ReceiptFailed, err)
+		return
+	}
+
+	log.Printf("Кусок %s загружен напрямую на сервер %s", chunk.ID, s.serverID)
+	c.JSON(http.StatusOK, storage.UploadResult{
+		ChunkID:  chunk.ID,
+		Checksum: checksum,
+		Receipt:  receipt,
+		ServerID: s.serverID,
+	})
+}
@@ -0,0 +1,40 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClaims struct {
+	ChunkID string `json:"chunk_id"`
+	Size    int64  `json:"size"`
+}
+
+func TestSignVerify(t *testing.T) {
+	signed, err := Sign("secret", "chunk", testClaims{ChunkID: "chunk", Size: 42}, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	var claims testClaims
+	require.NoError(t, Verify("secret", "chunk", signed, &claims))
+	assert.Equal(t, testClaims{ChunkID: "chunk", Size: 42}, claims)
+
+	// Другой ключ и измененные данные
+	assert.ErrorIs(t, Verify("other", "chunk", signed, &claims), ErrInvalid)
+	forged, err := Sign("other", "chunk", testClaims{ChunkID: "chunk", Size: 1 << 40}, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(signed, ".")
+	assert.ErrorIs(t, Verify("secret", "chunk", payload+"."+sig, &claims), ErrInvalid)
+	assert.ErrorIs(t, Verify("secret", "chunk", "garbage", &claims), ErrInvalid)
+
+	// Токен другого назначения
+	assert.ErrorIs(t, Verify("secret", "receipt", signed, &claims), ErrInvalid)
+
+	expired, err := Sign("secret", "chunk", testClaims{ChunkID: "chunk"}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.ErrorIs(t, Verify("secret", "chunk", expired, &claims), ErrExpired)
+}
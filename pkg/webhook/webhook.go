@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"TestCase/pkg/events"
+)
+
+const (
+	// Заголовки запроса с событием
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	// queueSize - размер очереди событий для каждого получателя
+	queueSize = 1000
+)
+
+// Config содержит настройки отправки webhook уведомлений
+type Config struct {
+	URLs           []string      // адреса получателей
+	Secret         string        // ключ для подписи HMAC-SHA256; пустой ключ отключает подпись
+	MaxRetries     int           // количество повторных попыток после первой неудачи
+	RetryDelay     time.Duration // задержка перед первым повтором, далее удваивается
+	Timeout        time.Duration // таймаут одного запроса
+	DeadLetterPath string        // файл для событий, которые не удалось доставить
+}
+
+// deadLetter описывает недоставленное событие в журнале
+type deadLetter struct {
+	URL      string       `json:"url"`
+	Event    events.Event `json:"event"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+	FailedAt time.Time    `json:"failed_at"`
+}
+
+// Dispatcher доставляет события получателям с повторными попытками.
+// Для каждого получателя используется своя очередь, чтобы медленный получатель не задерживал остальных
+type Dispatcher struct {
+	config     Config
+	httpClient *http.Client
+	queues     map[string]chan events.Event
+
+	deadLetterFile  *os.File
+	deadLetterMutex sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher создает диспетчер и запускает обработчики очередей
+func NewDispatcher(cfg Config) (*Dispatcher, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Second
+	}
+
+	d := &Dispatcher{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		queues:     make(map[string]chan events.Event, len(cfg.URLs)),
+	}
+
+	if cfg.DeadLetterPath != "" {
+		file, err := os.OpenFile(cfg.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть журнал недоставленных событий: %w", err)
+		}
+		d.deadLetterFile = file
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	for _, url := range cfg.URLs {
+		queue := make(chan events.Event, queueSize)
+		d.queues[url] = queue
+
+		d.wg.Add(1)
+		go d.worker(url, queue)
+	}
+
+	return d, nil
+}
+
+// Send ставит событие в очередь на доставку всем получателям
+func (d *Dispatcher) Send(event events.Event) {
+	for url, queue := range d.queues {
+		select {
+		case queue <- event:
+		default:
+			d.writeDeadLetter(url, event, 0, fmt.Errorf("очередь получателя переполнена"))
+		}
+	}
+}
+
+// Consume отправляет события из канала, пока он не будет закрыт
+func (d *Dispatcher) Consume(ch <-chan events.Event) {
+	for event := range ch {
+		d.Send(event)
+	}
+}
+
+// Close прекращает повторные попытки, доставляет уже полученные события по одному разу
+// и закрывает журнал недоставленных событий
+func (d *Dispatcher) Close() error {
+	d.cancel()
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.wg.Wait()
+
+	if d.deadLetterFile != nil {
+		return d.deadLetterFile.Close()
+	}
+	return nil
+}
+
+// worker последовательно доставляет события одному получателю
+func (d *Dispatcher) worker(url string, queue <-chan events.Event) {
+	defer d.wg.Done()
+
+	for event := range queue {
+		d.deliver(url, event)
+	}
+}
+
+// deliver отправляет событие с повторными попытками и экспоненциальной задержкой
+func (d *Dispatcher) deliver(url string, event events.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.writeDeadLetter(url, event, 0, fmt.Errorf("не удалось сериализовать событие: %w", err))
+		return
+	}
+
+	delay := d.config.RetryDelay
+	attempts := 0
+
+	for {
+		attempts++
+		err = d.post(url, event, body)
+		if err == nil {
+			return
+		}
+
+		if attempts > d.config.MaxRetries {
+			break
+		}
+
+		log.Printf("Не удалось доставить событие %s на %s (попытка %d): %v", event.ID, url, attempts, err)
+
+		select {
+		case <-d.ctx.Done():
+			d.writeDeadLetter(url, event, attempts, fmt.Errorf("доставка прервана при остановке: %w", err))
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	d.writeDeadLetter(url, event, attempts, err)
+}
+
+// post выполняет одну попытку доставки события
+func (d *Dispatcher) post(url string, event events.Event, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderID, event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if d.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.config.Secret, timestamp, body))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось отправить запрос: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("получатель вернул ошибку %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// writeDeadLetter записывает недоставленное событие в журнал
+func (d *Dispatcher) writeDeadLetter(url string, event events.Event, attempts int, deliveryErr error) {
+	log.Printf("Событие %s (%s) не доставлено на %s после %d попыток: %v", event.ID, event.Type, url, attempts, deliveryErr)
+
+	if d.deadLetterFile == nil {
+		return
+	}
+
+	line, err := json.Marshal(deadLetter{
+		URL:      url,
+		Event:    event,
+		Attempts: attempts,
+		Error:    deliveryErr.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Не удалось сериализовать недоставленное событие %s: %v", event.ID, err)
+		return
+	}
+
+	d.deadLetterMutex.Lock()
+	defer d.deadLetterMutex.Unlock()
+
+	if _, err := d.deadLetterFile.Write(append(line, '\n')); err != nil {
+		log.Printf("Не удалось записать недоставленное событие %s: %v", event.ID, err)
+	}
+}
+
+// Sign вычисляет подпись события: HMAC-SHA256 от строки "<timestamp>.<body>".
+// Метка времени входит в подпись, чтобы получатель мог отклонять повторно отправленные запросы
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись события на стороне получателя
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
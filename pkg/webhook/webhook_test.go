@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"TestCase/pkg/chunking"
+	"TestCase/pkg/events"
+)
+
+func newTestEvent() events.Event {
+	return events.NewFileEvent(events.FileUploaded, &chunking.FileMetadata{
+		ID:           "file-id",
+		OriginalName: "test.txt",
+		Size:         4,
+		Checksum:     "abcd",
+	})
+}
+
+func TestDispatcherRetriesAndSigns(t *testing.T) {
+	var attempts int32
+	received := make(chan events.Event, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Первая попытка завершается ошибкой, вторая должна пройти
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.True(t, Verify("secret", r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+		assert.Equal(t, string(events.FileUploaded), r.Header.Get(HeaderEvent))
+
+		var event events.Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		received <- event
+	}))
+	defer server.Close()
+
+	dispatcher, err := NewDispatcher(Config{
+		URLs:       []string{server.URL},
+		Secret:     "secret",
+		MaxRetries: 3,
+		RetryDelay: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer dispatcher.Close()
+
+	event := newTestEvent()
+	dispatcher.Send(event)
+
+	select {
+	case got := <-received:
+		assert.Equal(t, event.ID, got.ID)
+		assert.Equal(t, "file-id", got.File.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("событие не доставлено")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestDispatcherWritesDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	deadLetterPath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	dispatcher, err := NewDispatcher(Config{
+		URLs:           []string{server.URL},
+		MaxRetries:     1,
+		RetryDelay:     time.Millisecond,
+		DeadLetterPath: deadLetterPath,
+	})
+	require.NoError(t, err)
+
+	event := newTestEvent()
+	dispatcher.Send(event)
+
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(deadLetterPath)
+		return len(data) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, dispatcher.Close())
+
+	data, err := os.ReadFile(deadLetterPath)
+	require.NoError(t, err)
+
+	var entry deadLetter
+	require.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, server.URL, entry.URL)
+	assert.Equal(t, event.ID, entry.Event.ID)
+	assert.Equal(t, 2, entry.Attempts)
+}
@@ -117,7 +117,7 @@ print_status "Сборка приложений..."
 
 # Собираем API сервер
 print_status "Сборка API сервера..."
-if go build -o bin/api ./cmd/api/main.go; then
+if go build -o bin/api ./cmd/api; then
     print_status "API сервер собран успешно"
 else
     print_error "Ошибка сборки API сервера"